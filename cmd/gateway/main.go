@@ -9,104 +9,68 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/yewintnaing/ai-gateway/internal/api"
-	"github.com/yewintnaing/ai-gateway/internal/cache"
+	"github.com/yewintnaing/ai-gateway/internal/chatcli"
 	"github.com/yewintnaing/ai-gateway/internal/config"
-	"github.com/yewintnaing/ai-gateway/internal/governance"
+	"github.com/yewintnaing/ai-gateway/internal/loadtest"
 	"github.com/yewintnaing/ai-gateway/internal/observability"
-	"github.com/yewintnaing/ai-gateway/internal/providers"
-	"github.com/yewintnaing/ai-gateway/internal/providers/anthropic"
-	"github.com/yewintnaing/ai-gateway/internal/providers/openai"
-	"github.com/yewintnaing/ai-gateway/internal/ratelimit"
-	"github.com/yewintnaing/ai-gateway/internal/router"
-	"github.com/yewintnaing/ai-gateway/internal/usage"
+	"github.com/yewintnaing/ai-gateway/internal/secrets"
+	"github.com/yewintnaing/ai-gateway/pkg/gateway"
 )
 
 func main() {
-	// 1. Initial Context and OTEL
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	shutdownOTEL, err := observability.InitOTEL(ctx, "ai-gateway")
-	if err != nil {
-		log.Fatalf("Failed to initialize OTEL: %v", err)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "loadtest":
+			if err := loadtest.Main(os.Args[2:]); err != nil {
+				log.Fatalf("loadtest failed: %v", err)
+			}
+			return
+		case "chat":
+			if err := chatcli.Main(os.Args[2:]); err != nil {
+				log.Fatalf("chat failed: %v", err)
+			}
+			return
+		case "encrypt-secret":
+			if err := secrets.Main(os.Args[2:]); err != nil {
+				log.Fatalf("encrypt-secret failed: %v", err)
+			}
+			return
+		}
 	}
-	defer shutdownOTEL(context.Background())
 
-	// 2. Load Config
+	// 1. Load Config
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// 3. Initialize Usage Store (Postgres)
-	store, err := usage.NewStore(cfg.DatabaseURL)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
-	}
-	defer store.Close()
-
-	// 4. Run Migrations
-	if err := store.Migrate(ctx, "migrations/001_create_requests.sql"); err != nil {
-		log.Printf("Warning: Migration 001 failed: %v", err)
-	}
-	if err := store.Migrate(ctx, "migrations/002_create_provider_attempts.sql"); err != nil {
-		log.Printf("Warning: Migration 002 failed: %v", err)
-	}
-	if err := store.Migrate(ctx, "migrations/003_add_unique_to_requests.sql"); err != nil {
-		log.Printf("Warning: Migration 003 failed: %v", err)
-	}
-	if err := store.Migrate(ctx, "migrations/004_create_model_pricing.sql"); err != nil {
-		log.Printf("Warning: Migration 004 failed: %v", err)
-	}
+	// 2. Initial Context and OTEL
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	limiter, err := ratelimit.NewLimiter(cfg.RedisURL, cfg.TPM)
+	shutdownOTEL, err := observability.InitOTEL(ctx, "ai-gateway", cfg.TraceSampleRate)
 	if err != nil {
-		log.Printf("Warning: Redis not available, rate limiting disabled: %v", err)
+		log.Fatalf("Failed to initialize OTEL: %v", err)
 	}
+	defer shutdownOTEL(context.Background())
 
-	// 6. Initialize Cache
-	c, err := cache.NewCache(cfg.RedisURL, 1*time.Hour)
+	// 3. Build the gateway: storage, limits, providers, routes and API
+	gw, err := gateway.New(ctx, cfg, gateway.Options{MigrationsDir: "migrations"})
 	if err != nil {
-		log.Printf("Warning: Redis not available, caching disabled: %v", err)
-	}
-
-	// 7. Initialize Governance
-	detector := governance.NewDetector()
-
-	// 6. Initialize Providers
-	registry := providers.Registry{
-		"openai":    openai.NewProvider(cfg.OpenAIKey, cfg.OpenAIURL, cfg.OpenAIVersion),
-		"anthropic": anthropic.NewProvider(cfg.AnthropicKey, cfg.AnthropicURL, cfg.AnthropicVersion),
+		log.Fatalf("Failed to start gateway: %v", err)
 	}
+	defer gw.Close()
 
-	// 8. Initialize Components
-	rt := router.NewRouter(cfg.Routes)
-	h := api.NewHandler(rt, registry, store, limiter, c, detector)
-
-	// 8. Setup Router
-	r := chi.NewRouter()
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
-
-	r.Post("/v1/chat/completions", h.HandleChat)
-	r.Get("/health", func(w http.ResponseWriter, req *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("ok"))
-	})
-
-	// 9. Start Server
+	// 4. Start Server
 	server := &http.Server{
 		Addr:    ":" + cfg.Port,
-		Handler: r,
+		Handler: gw,
 	}
 
 	go func() {
+		if cfg.Env != "" {
+			log.Printf("Using config profile %s", cfg.Env)
+		}
 		log.Printf("AI Gateway Phase 2 starting on port %s", cfg.Port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("ListenAndServe failed: %v", err)
@@ -0,0 +1,259 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/webhook"
+)
+
+// Event kinds the gateway reports.
+const (
+	KindAttempt           = "error_rate" // one provider attempt; Failed marks errors
+	KindFallbackExhausted = "fallback_exhausted"
+	KindBudget            = "budget"
+	KindCircuitOpen       = "circuit_open"
+	KindProbeFailure      = "probe_failure" // a synthetic probe failed its assertions
+	KindAnomaly           = "anomaly"       // raised by the usage analyzer, not by rules
+)
+
+// Event is something that happened which alert rules may count.
+type Event struct {
+	Kind     string
+	Provider string
+	Route    string
+	Tenant   string
+	Failed   bool
+	Detail   string
+}
+
+// Alert is a fired rule, as sent to webhooks.
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Kind      string    `json:"kind"`
+	Key       string    `json:"key,omitempty"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Events    int       `json:"events"`
+	Window    string    `json:"window"`
+	Detail    string    `json:"detail,omitempty"`
+	FiredAt   time.Time `json:"fired_at"`
+	// Tenant is set on alerts about one tenant, which also go to the
+	// tenant's own webhook.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+func (a Alert) summary() string {
+	subject := a.Rule
+	if a.Key != "" {
+		subject += " (" + a.Key + ")"
+	}
+	if a.Kind == KindAnomaly {
+		return fmt.Sprintf("%s: %s (%.1f standard deviations, threshold %.1f)", subject, a.Detail, a.Value, a.Threshold)
+	}
+	if a.Kind == KindAttempt {
+		return fmt.Sprintf("%s: error rate %.0f%% over %d attempts in %s (threshold %.0f%%)",
+			subject, a.Value*100, a.Events, a.Window, a.Threshold*100)
+	}
+	return fmt.Sprintf("%s: %d %s events in %s (threshold %.0f)", subject, a.Events, a.Kind, a.Window, a.Threshold)
+}
+
+type sample struct {
+	at     time.Time
+	failed bool
+}
+
+type series struct {
+	samples []sample
+	fired   time.Time
+}
+
+// Alerter evaluates rules against observed events and posts alerts to the
+// configured webhooks. A nil *Alerter ignores everything.
+type Alerter struct {
+	rules     []config.AlertRule
+	webhooks  []config.AlertWebhook
+	tenants   map[string]*config.TenantWebhook
+	deliverer *webhook.Deliverer
+	now       func() time.Time
+	send      func(Alert)
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// New returns an Alerter for cfg, or nil if neither rules nor anomaly
+// detection are configured.
+// Alerts are delivered through d, to the configured webhooks and, for
+// tenant alerts, to the tenant's webhook.
+func New(cfg config.Alerts, tenants []config.Tenant, d *webhook.Deliverer) *Alerter {
+	if len(cfg.Rules) == 0 && cfg.Anomalies == nil {
+		return nil
+	}
+	a := &Alerter{
+		rules:     cfg.Rules,
+		webhooks:  cfg.Webhooks,
+		tenants:   make(map[string]*config.TenantWebhook),
+		deliverer: d,
+		now:       time.Now,
+		series:    make(map[string]*series),
+	}
+	for _, t := range tenants {
+		if t.Webhook != nil {
+			a.tenants[t.Name] = t.Webhook
+		}
+	}
+	a.send = a.post
+	return a
+}
+
+// Observe records an event and fires any rule it pushes over threshold.
+func (a *Alerter) Observe(e Event) {
+	if a == nil {
+		return
+	}
+	now := a.now()
+	var fired []Alert
+
+	a.mu.Lock()
+	for _, rule := range a.rules {
+		if rule.Kind != e.Kind || (rule.Provider != "" && rule.Provider != e.Provider) || (rule.Tenant != "" && rule.Tenant != e.Tenant) {
+			continue
+		}
+		key := e.Provider
+		switch e.Kind {
+		case KindBudget:
+			key = e.Tenant
+		case KindFallbackExhausted:
+			key = e.Route
+		}
+		window := seconds(rule.WindowSec, 5*time.Minute)
+
+		s := a.series[rule.Name+"\x00"+key]
+		if s == nil {
+			s = &series{}
+			a.series[rule.Name+"\x00"+key] = s
+		}
+		s.samples = append(prune(s.samples, now.Add(-window)), sample{at: now, failed: e.Failed})
+
+		value, ok := evaluate(rule, s.samples)
+		if !ok || now.Sub(s.fired) < seconds(rule.CooldownSec, 15*time.Minute) {
+			continue
+		}
+		s.fired = now
+		alert := Alert{
+			Rule: rule.Name, Kind: rule.Kind, Key: key, Value: value, Threshold: threshold(rule),
+			Events: len(s.samples), Window: window.String(), Detail: e.Detail, FiredAt: now,
+		}
+		if e.Kind == KindBudget {
+			alert.Tenant = e.Tenant
+		}
+		fired = append(fired, alert)
+	}
+	a.mu.Unlock()
+
+	for _, alert := range fired {
+		log.Printf("Alert: %s", alert.summary())
+		a.send(alert)
+	}
+}
+
+// Raise sends an alert decided elsewhere, such as by the usage analyzer.
+func (a *Alerter) Raise(alert Alert) {
+	if a == nil {
+		return
+	}
+	log.Printf("Alert: %s", alert.summary())
+	a.send(alert)
+}
+
+// evaluate reports the rule's current value and whether it crossed the
+// threshold.
+func evaluate(rule config.AlertRule, samples []sample) (float64, bool) {
+	if rule.Kind != KindAttempt {
+		n := float64(len(samples))
+		return n, n >= threshold(rule)
+	}
+	if len(samples) < max(rule.MinEvents, 1) {
+		return 0, false
+	}
+	failed := 0
+	for _, s := range samples {
+		if s.failed {
+			failed++
+		}
+	}
+	rate := float64(failed) / float64(len(samples))
+	return rate, failed > 0 && rate >= threshold(rule)
+}
+
+func threshold(rule config.AlertRule) float64 {
+	if rule.Threshold > 0 {
+		return rule.Threshold
+	}
+	if rule.Kind == KindAttempt {
+		return 0.5
+	}
+	return 1
+}
+
+func prune(samples []sample, cutoff time.Time) []sample {
+	i := 0
+	for i < len(samples) && samples[i].at.Before(cutoff) {
+		i++
+	}
+	return samples[i:]
+}
+
+func seconds(n int, fallback time.Duration) time.Duration {
+	if n <= 0 {
+		return fallback
+	}
+	return time.Duration(n) * time.Second
+}
+
+func (a *Alerter) post(alert Alert) {
+	ctx := context.Background()
+	for _, wh := range a.webhooks {
+		body, err := json.Marshal(payload(wh, alert))
+		if err != nil {
+			continue
+		}
+		go a.deliverer.Deliver(ctx, webhook.Delivery{URL: wh.URL, Event: "alert." + alert.Kind, Body: body, SecretEnv: wh.SecretEnv})
+	}
+	if wh := a.tenants[alert.Tenant]; alert.Tenant != "" && wh != nil {
+		body, err := json.Marshal(alert)
+		if err != nil {
+			return
+		}
+		go a.deliverer.Deliver(ctx, webhook.Delivery{Tenant: alert.Tenant, URL: wh.URL, Event: "alert." + alert.Kind, Body: body, SecretEnv: wh.SecretEnv})
+	}
+}
+
+// payload shapes an alert for the webhook's receiver.
+func payload(wh config.AlertWebhook, alert Alert) interface{} {
+	switch wh.Format {
+	case "slack":
+		return map[string]string{"text": ":rotating_light: " + alert.summary()}
+	case "pagerduty":
+		return map[string]interface{}{
+			"routing_key":  wh.RoutingKey,
+			"event_action": "trigger",
+			"dedup_key":    alert.Rule + "/" + alert.Key,
+			"payload": map[string]interface{}{
+				"summary":        alert.summary(),
+				"source":         "ai-gateway",
+				"severity":       "error",
+				"timestamp":      alert.FiredAt.UTC().Format(time.RFC3339),
+				"custom_details": alert,
+			},
+		}
+	default:
+		return alert
+	}
+}
@@ -0,0 +1,102 @@
+package alerting
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/webhook"
+)
+
+func newTestAlerter(rules ...config.AlertRule) (*Alerter, *[]Alert, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var sent []Alert
+	a := New(config.Alerts{Rules: rules}, nil, nil)
+	a.now = func() time.Time { return now }
+	a.send = func(alert Alert) { sent = append(sent, alert) }
+	return a, &sent, &now
+}
+
+func TestErrorRateRule(t *testing.T) {
+	a, sent, now := newTestAlerter(config.AlertRule{Name: "errors", Kind: KindAttempt, Threshold: 0.5, MinEvents: 4, WindowSec: 60, CooldownSec: 600})
+
+	for _, failed := range []bool{true, true, false} {
+		a.Observe(Event{Kind: KindAttempt, Provider: "openai", Failed: failed})
+	}
+	if len(*sent) != 0 {
+		t.Fatalf("expected no alert below min_events, got %v", *sent)
+	}
+	a.Observe(Event{Kind: KindAttempt, Provider: "openai", Failed: true})
+	if len(*sent) != 1 || (*sent)[0].Key != "openai" || (*sent)[0].Value != 0.75 {
+		t.Fatalf("expected one openai alert at 75%%, got %+v", *sent)
+	}
+
+	// Cooldown suppresses repeats; other providers are tracked separately.
+	a.Observe(Event{Kind: KindAttempt, Provider: "openai", Failed: true})
+	if len(*sent) != 1 {
+		t.Errorf("expected cooldown to suppress a second alert")
+	}
+
+	// Old samples leave the window.
+	*now = now.Add(2 * time.Minute)
+	a.Observe(Event{Kind: KindAttempt, Provider: "anthropic", Failed: true})
+	if len(*sent) != 1 {
+		t.Errorf("expected no alert for a single anthropic attempt")
+	}
+}
+
+func TestCountRule(t *testing.T) {
+	a, sent, _ := newTestAlerter(config.AlertRule{Name: "exhausted", Kind: KindFallbackExhausted, Threshold: 2})
+	a.Observe(Event{Kind: KindFallbackExhausted})
+	a.Observe(Event{Kind: KindBudget, Tenant: "acme"})
+	if len(*sent) != 0 {
+		t.Fatalf("expected no alert yet")
+	}
+	a.Observe(Event{Kind: KindFallbackExhausted})
+	if len(*sent) != 1 || (*sent)[0].Events != 2 {
+		t.Fatalf("expected one alert after two events, got %+v", *sent)
+	}
+}
+
+func TestPagerDutyPayload(t *testing.T) {
+	p := payload(config.AlertWebhook{Format: "pagerduty", RoutingKey: "rk"}, Alert{Rule: "r", Key: "openai"}).(map[string]interface{})
+	if p["routing_key"] != "rk" || p["dedup_key"] != "r/openai" || p["event_action"] != "trigger" {
+		t.Errorf("unexpected payload %v", p)
+	}
+}
+
+func TestNilAlerter(t *testing.T) {
+	var a *Alerter
+	a.Observe(Event{Kind: KindAttempt})
+}
+
+func TestTenantWebhookReceivesBudgetAlerts(t *testing.T) {
+	t.Setenv("ACME_HOOK_SECRET", "s3cret")
+	got := make(chan *http.Request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got <- r }))
+	defer srv.Close()
+
+	tenants := []config.Tenant{{Name: "acme", Webhook: &config.TenantWebhook{URL: srv.URL, SecretEnv: "ACME_HOOK_SECRET"}}}
+	a := New(config.Alerts{Rules: []config.AlertRule{{Name: "budget", Kind: KindBudget, Threshold: 1}}}, tenants, webhook.New(nil, 1))
+	a.Observe(Event{Kind: KindBudget, Tenant: "acme"})
+
+	select {
+	case r := <-got:
+		if r.Header.Get(webhook.SignatureHeader) == "" || r.Header.Get(webhook.EventHeader) != "alert.budget" {
+			t.Errorf("expected a signed alert.budget delivery, got headers %v", r.Header)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the tenant webhook to be called")
+	}
+
+	// Alerts about providers are not sent to tenants.
+	a.rules = append(a.rules, config.AlertRule{Name: "errors", Kind: KindAttempt, Threshold: 0.5})
+	a.Observe(Event{Kind: KindAttempt, Provider: "openai", Tenant: "acme", Failed: true})
+	select {
+	case <-got:
+		t.Error("expected no tenant delivery for a provider alert")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
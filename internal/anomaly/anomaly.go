@@ -0,0 +1,243 @@
+// Package anomaly watches tenants' usage for patterns that differ from
+// their own recent history: token spikes, error bursts and unusual volume
+// outside working hours.
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/alerting"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+const analyzeJob = "anomalies"
+
+// Anomalies the analyzer raises, used as the alerts' rule names.
+const (
+	TokenSpike     = "token_spike"
+	ErrorBurst     = "error_burst"
+	OffHoursVolume = "off_hours_volume"
+)
+
+// Store provides the tenants' traffic and claims intervals so that one
+// instance analyzes each.
+type Store interface {
+	TenantIntervals(ctx context.Context, from, to time.Time, interval time.Duration) ([]usage.TenantInterval, error)
+	ClaimReport(ctx context.Context, name, period string) (bool, error)
+}
+
+// Analyzer checks each complete interval once, across all instances.
+type Analyzer struct {
+	store    Store
+	alerts   *alerting.Alerter
+	cfg      config.Anomalies
+	interval time.Duration
+	lookback time.Duration
+	offHours *time.Location
+	now      func() time.Time
+}
+
+// New returns an analyzer for cfg, raising alerts through alerts.
+func New(store Store, alerts *alerting.Alerter, cfg config.Anomalies) (*Analyzer, error) {
+	if cfg.IntervalMin <= 0 {
+		cfg.IntervalMin = 60
+	}
+	if cfg.LookbackHours <= 0 {
+		cfg.LookbackHours = 168
+	}
+	if cfg.ZScore <= 0 {
+		cfg.ZScore = 4
+	}
+	if cfg.MinTokens <= 0 {
+		cfg.MinTokens = 10000
+	}
+	if cfg.MinErrors <= 0 {
+		cfg.MinErrors = 10
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 50
+	}
+	a := &Analyzer{
+		store: store, alerts: alerts, cfg: cfg,
+		interval: time.Duration(cfg.IntervalMin) * time.Minute,
+		lookback: time.Duration(cfg.LookbackHours) * time.Hour,
+		now:      time.Now,
+	}
+	if a.lookback < 2*a.interval {
+		return nil, fmt.Errorf("lookback_hours must cover at least two intervals")
+	}
+	if oh := cfg.OffHours; oh != nil {
+		if oh.From < 0 || oh.From > 23 || oh.To < 0 || oh.To > 23 {
+			return nil, fmt.Errorf("off_hours from and to must be hours from 0 to 23")
+		}
+		loc, err := time.LoadLocation(oh.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("off_hours timezone: %w", err)
+		}
+		a.offHours = loc
+	}
+	return a, nil
+}
+
+// Run analyzes the interval starting at start against the lookback before
+// it and raises an alert for each anomaly found.
+func (a *Analyzer) Run(ctx context.Context, start time.Time) ([]alerting.Alert, error) {
+	rows, err := a.store.TenantIntervals(ctx, start.Add(-a.lookback), start.Add(a.interval), a.interval)
+	if err != nil {
+		return nil, err
+	}
+	found := a.Detect(rows, start)
+	for _, alert := range found {
+		a.alerts.Raise(alert)
+	}
+	return found, nil
+}
+
+// Detect compares each tenant's traffic in the interval starting at start
+// with its traffic in the lookback before it. Intervals missing from rows
+// had no traffic. Tenants without traffic before start have no history to
+// compare with and are skipped.
+func (a *Analyzer) Detect(rows []usage.TenantInterval, start time.Time) []alerting.Alert {
+	n := int(a.lookback / a.interval)
+	type tenantHistory struct {
+		current usage.TenantInterval
+		past    map[int]usage.TenantInterval // by intervals before start
+	}
+	tenants := make(map[string]*tenantHistory)
+	for _, r := range rows {
+		th := tenants[r.Tenant]
+		if th == nil {
+			th = &tenantHistory{past: make(map[int]usage.TenantInterval)}
+			tenants[r.Tenant] = th
+		}
+		if ago := int(start.Sub(r.Start) / a.interval); r.Start.Equal(start) {
+			th.current = r
+		} else if ago >= 1 && ago <= n {
+			th.past[ago] = r
+		}
+	}
+
+	names := make([]string, 0, len(tenants))
+	for name := range tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var alerts []alerting.Alert
+	for _, tenant := range names {
+		th := tenants[tenant]
+		if len(th.past) == 0 {
+			continue
+		}
+		var tokens, errs, offHours []float64
+		for ago := 1; ago <= n; ago++ {
+			r := th.past[ago]
+			tokens = append(tokens, float64(r.Tokens))
+			errs = append(errs, float64(r.Errors))
+			if a.isOffHours(start.Add(-time.Duration(ago) * a.interval)) {
+				offHours = append(offHours, float64(r.Requests))
+			}
+		}
+		cur := th.current
+		if cur.Tokens >= a.cfg.MinTokens {
+			if alert, ok := a.check(TokenSpike, tenant, float64(cur.Tokens), tokens, "tokens", start); ok {
+				alerts = append(alerts, alert)
+			}
+		}
+		if cur.Errors >= a.cfg.MinErrors {
+			if alert, ok := a.check(ErrorBurst, tenant, float64(cur.Errors), errs, "5xx errors", start); ok {
+				alerts = append(alerts, alert)
+			}
+		}
+		if cur.Requests >= a.cfg.MinRequests && a.isOffHours(start) && len(offHours) > 0 {
+			if alert, ok := a.check(OffHoursVolume, tenant, float64(cur.Requests), offHours, "off-hours requests", start); ok {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	return alerts
+}
+
+// check raises rule when value lies ZScore standard deviations above the
+// mean of history. The deviation is taken as at least 1, so a flat
+// history does not make any increase anomalous.
+func (a *Analyzer) check(rule, tenant string, value float64, history []float64, what string, start time.Time) (alerting.Alert, bool) {
+	mean, sd := meanSD(history)
+	z := (value - mean) / math.Max(sd, 1)
+	if z < a.cfg.ZScore {
+		return alerting.Alert{}, false
+	}
+	return alerting.Alert{
+		Rule: rule, Kind: alerting.KindAnomaly, Key: tenant, Value: z, Threshold: a.cfg.ZScore,
+		Events: int(value), Window: a.interval.String(),
+		Detail:  fmt.Sprintf("%.0f %s in the %s from %s, against a mean of %.1f (sd %.1f)", value, what, a.interval, start.UTC().Format(time.RFC3339), mean, sd),
+		FiredAt: a.now(),
+	}, true
+}
+
+func meanSD(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)))
+}
+
+// isOffHours reports whether the interval starting at t begins outside
+// working hours.
+func (a *Analyzer) isOffHours(t time.Time) bool {
+	oh := a.cfg.OffHours
+	if oh == nil {
+		return false
+	}
+	local := t.In(a.offHours)
+	if oh.Weekends && (local.Weekday() == time.Saturday || local.Weekday() == time.Sunday) {
+		return true
+	}
+	h := local.Hour()
+	if oh.From <= oh.To {
+		return h >= oh.From && h < oh.To
+	}
+	return h >= oh.From || h < oh.To
+}
+
+// Start analyzes each interval once it is complete, until ctx is
+// cancelled.
+func (a *Analyzer) Start(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	var last time.Time
+	for {
+		// Intervals are aligned to the Unix epoch, as those of TenantIntervals are.
+		secs := int64(a.interval.Seconds())
+		start := time.Unix(a.now().Unix()/secs*secs, 0).Add(-a.interval)
+		if !start.Equal(last) {
+			if claimed, err := a.store.ClaimReport(ctx, analyzeJob, start.UTC().Format(time.RFC3339)); err != nil {
+				log.Printf("Warning: anomaly analysis claim failed: %v", err)
+			} else {
+				last = start
+				if claimed {
+					if _, err := a.Run(ctx, start); err != nil {
+						log.Printf("Warning: anomaly analysis failed: %v", err)
+					}
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
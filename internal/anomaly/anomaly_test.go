@@ -0,0 +1,84 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+func TestDetect(t *testing.T) {
+	a, err := New(nil, nil, config.Anomalies{LookbackHours: 24, ZScore: 3, MinTokens: 1000, MinErrors: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 3, 6, 14, 0, 0, 0, time.UTC)
+
+	var rows []usage.TenantInterval
+	for ago := 1; ago <= 24; ago++ {
+		at := start.Add(-time.Duration(ago) * time.Hour)
+		rows = append(rows,
+			usage.TenantInterval{Tenant: "acme", Start: at, Requests: 20, Tokens: 2000 + 100*(ago%3), Errors: ago % 2},
+			usage.TenantInterval{Tenant: "globex", Start: at, Requests: 20, Tokens: 2000 + 100*(ago%3)},
+		)
+	}
+	rows = append(rows,
+		usage.TenantInterval{Tenant: "acme", Start: start, Requests: 25, Tokens: 2200, Errors: 12},
+		usage.TenantInterval{Tenant: "globex", Start: start, Requests: 200, Tokens: 40000},
+		usage.TenantInterval{Tenant: "initech", Start: start, Requests: 200, Tokens: 40000}, // no history
+	)
+
+	alerts := a.Detect(rows, start)
+	if len(alerts) != 2 {
+		t.Fatalf("expected two anomalies, got %+v", alerts)
+	}
+	if alerts[0].Rule != ErrorBurst || alerts[0].Key != "acme" || alerts[0].Events != 12 {
+		t.Errorf("expected an error burst for acme, got %+v", alerts[0])
+	}
+	if alerts[1].Rule != TokenSpike || alerts[1].Key != "globex" || alerts[1].Value < 3 {
+		t.Errorf("expected a token spike for globex, got %+v", alerts[1])
+	}
+}
+
+func TestOffHoursVolume(t *testing.T) {
+	a, err := New(nil, nil, config.Anomalies{
+		LookbackHours: 72, MinRequests: 10,
+		OffHours: &config.OffHours{From: 20, To: 6, Timezone: "America/New_York"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 03:00 UTC is 22:00 in New York, off hours.
+	start := time.Date(2024, 3, 6, 3, 0, 0, 0, time.UTC)
+	var rows []usage.TenantInterval
+	for ago := 1; ago <= 72; ago++ {
+		at := start.Add(-time.Duration(ago) * time.Hour)
+		requests := 100 // busy working hours
+		if a.isOffHours(at) {
+			requests = 2
+		}
+		rows = append(rows, usage.TenantInterval{Tenant: "acme", Start: at, Requests: requests})
+	}
+
+	quiet := append(rows, usage.TenantInterval{Tenant: "acme", Start: start, Requests: 3})
+	if alerts := a.Detect(quiet, start); len(alerts) != 0 {
+		t.Errorf("expected usual off-hours volume to pass, got %+v", alerts)
+	}
+	busy := append(rows, usage.TenantInterval{Tenant: "acme", Start: start, Requests: 80})
+	if alerts := a.Detect(busy, start); len(alerts) != 1 || alerts[0].Rule != OffHoursVolume {
+		t.Errorf("expected working-hours volume off hours to be anomalous, got %+v", alerts)
+	}
+	if a.isOffHours(start.Add(-12 * time.Hour)) {
+		t.Error("expected 10:00 in New York to be working hours")
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	if _, err := New(nil, nil, config.Anomalies{IntervalMin: 60, LookbackHours: 1}); err == nil {
+		t.Error("expected a lookback of one interval to be refused")
+	}
+	if _, err := New(nil, nil, config.Anomalies{OffHours: &config.OffHours{From: 24}}); err == nil {
+		t.Error("expected an hour out of range to be refused")
+	}
+}
@@ -0,0 +1,848 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yewintnaing/ai-gateway/internal/cache"
+	"github.com/yewintnaing/ai-gateway/internal/canary"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/governance"
+	"github.com/yewintnaing/ai-gateway/internal/health"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/ratelimit"
+	"github.com/yewintnaing/ai-gateway/internal/rbac"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+	"github.com/yewintnaing/ai-gateway/internal/webhook"
+	"gopkg.in/yaml.v3"
+)
+
+// RequestStory is the response of GET /admin/requests/{request_id}: the stored
+// trace of a request plus the route it was matched to, as currently configured.
+type RequestStory struct {
+	*usage.RequestTrace
+	Route *config.Route `json:"route,omitempty"`
+}
+
+// HandleGetRequest lets support engineers inspect a request without access to
+// the tracing backend.
+func (h *Handler) HandleGetRequest(w http.ResponseWriter, r *http.Request) {
+	requestID := chi.URLParam(r, "request_id")
+
+	trace, err := h.usage.GetTrace(r.Context(), requestID)
+	if errors.Is(err, usage.ErrNotFound) {
+		// Authorize before revealing whether the request exists.
+		if h.permit(w, r, rbac.AdminRead, "") {
+			h.respondError(w, http.StatusNotFound, "request not found", requestID)
+		}
+		return
+	}
+	if err != nil {
+		logError(requestID, "failed to load request trace", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to load request", requestID)
+		return
+	}
+	if !h.permit(w, r, rbac.AdminRead, trace.Tenant) {
+		return
+	}
+
+	story := RequestStory{RequestTrace: trace}
+	if route, ok := h.router.Lookup(trace.RouteName); ok {
+		story.Route = &route
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(story)
+}
+
+func (h *Handler) HandleListWordRules(w http.ResponseWriter, r *http.Request) {
+	tenant := chi.URLParam(r, "tenant")
+	rules, err := h.usage.ListWordRules(r.Context(), tenant)
+	if err != nil {
+		logError("", "failed to list word rules", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to list word rules", "")
+		return
+	}
+	if rules == nil {
+		rules = []governance.WordRule{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"rules": rules})
+}
+
+func (h *Handler) HandleCreateWordRule(w http.ResponseWriter, r *http.Request) {
+	var rule governance.WordRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body", "")
+		return
+	}
+	rule.Tenant = chi.URLParam(r, "tenant")
+	if rule.Scope == "" {
+		rule.Scope = "both"
+	}
+	if err := rule.Validate(); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+
+	id, err := h.usage.CreateWordRule(r.Context(), rule)
+	if err != nil {
+		logError("", "failed to create word rule", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to create word rule", "")
+		return
+	}
+	rule.ID = id
+	h.wordLists.Invalidate(rule.Tenant)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+func (h *Handler) HandleDeleteWordRule(w http.ResponseWriter, r *http.Request) {
+	tenant := chi.URLParam(r, "tenant")
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid rule id", "")
+		return
+	}
+
+	ok, err := h.usage.DeleteWordRule(r.Context(), tenant, id)
+	if err != nil {
+		logError("", "failed to delete word rule", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to delete word rule", "")
+		return
+	}
+	if !ok {
+		h.respondError(w, http.StatusNotFound, "rule not found", "")
+		return
+	}
+	h.wordLists.Invalidate(tenant)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListRateLimitOverrides lists every tenant's exemption or burst
+// credit pool.
+func (h *Handler) HandleListRateLimitOverrides(w http.ResponseWriter, r *http.Request) {
+	overrides, err := h.usage.ListRateLimitOverrides(r.Context())
+	if err != nil {
+		logError("", "failed to list rate limit overrides", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to list rate limit overrides", "")
+		return
+	}
+	if overrides == nil {
+		overrides = []ratelimit.Override{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"overrides": overrides})
+}
+
+func (h *Handler) HandleGetRateLimitOverride(w http.ResponseWriter, r *http.Request) {
+	o, ok, err := h.usage.GetRateLimitOverride(r.Context(), chi.URLParam(r, "tenant"))
+	if err != nil {
+		logError("", "failed to load rate limit override", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to load rate limit override", "")
+		return
+	}
+	if !ok {
+		h.respondError(w, http.StatusNotFound, "override not found", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(o)
+}
+
+// HandlePutRateLimitOverride exempts a tenant from the rate limit or gives
+// it a burst credit pool, replacing any override it had. It applies on this
+// instance at once and on others within a minute.
+func (h *Handler) HandlePutRateLimitOverride(w http.ResponseWriter, r *http.Request) {
+	var o ratelimit.Override
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body", "")
+		return
+	}
+	o.Tenant = chi.URLParam(r, "tenant")
+	if err := o.Validate(); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+
+	o, err := h.usage.PutRateLimitOverride(r.Context(), o)
+	if err != nil {
+		logError("", "failed to save rate limit override", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to save rate limit override", "")
+		return
+	}
+	h.limiter.Invalidate(o.Tenant)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(o)
+}
+
+func (h *Handler) HandleDeleteRateLimitOverride(w http.ResponseWriter, r *http.Request) {
+	tenant := chi.URLParam(r, "tenant")
+	ok, err := h.usage.DeleteRateLimitOverride(r.Context(), tenant)
+	if err != nil {
+		logError("", "failed to delete rate limit override", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to delete rate limit override", "")
+		return
+	}
+	if !ok {
+		h.respondError(w, http.StatusNotFound, "override not found", "")
+		return
+	}
+	h.limiter.Invalidate(tenant)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleCreateAPIKey issues a tenant API key. The secret is in this
+// response only.
+func (h *Handler) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			h.respondError(w, http.StatusBadRequest, "invalid request body", "")
+			return
+		}
+	}
+	key, secret, err := h.usage.CreateAPIKey(r.Context(), chi.URLParam(r, "tenant"), body.Name)
+	if err != nil {
+		logError("", "failed to create API key", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to create API key", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		usage.APIKey
+		Key string `json:"key"`
+	}{key, secret})
+}
+
+func (h *Handler) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.usage.ListAPIKeys(r.Context(), chi.URLParam(r, "tenant"))
+	if err != nil {
+		logError("", "failed to list API keys", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to list API keys", "")
+		return
+	}
+	if keys == nil {
+		keys = []usage.APIKey{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+func (h *Handler) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid key id", "")
+		return
+	}
+	ok, err := h.usage.RevokeAPIKey(r.Context(), chi.URLParam(r, "tenant"), id)
+	if err != nil {
+		logError("", "failed to revoke API key", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to revoke API key", "")
+		return
+	}
+	if !ok {
+		h.respondError(w, http.StatusNotFound, "key not found", "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListRoles lists role assignments, filtered by the subject query
+// parameter when given.
+func (h *Handler) HandleListRoles(w http.ResponseWriter, r *http.Request) {
+	assignments, err := h.usage.ListRoleAssignments(r.Context(), r.URL.Query().Get("subject"))
+	if err != nil {
+		logError("", "failed to list role assignments", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to list role assignments", "")
+		return
+	}
+	if assignments == nil {
+		assignments = []usage.RoleAssignment{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"roles": assignments})
+}
+
+// HandleAssignRole grants a role to a subject: "key:<id>" for an API key or
+// a JWT sub claim.
+func (h *Handler) HandleAssignRole(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Subject string    `json:"subject"`
+		Role    rbac.Role `json:"role"`
+		Tenant  string    `json:"tenant"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body", "")
+		return
+	}
+	if body.Subject == "" {
+		h.respondError(w, http.StatusBadRequest, "subject is required", "")
+		return
+	}
+	grant := rbac.Grant{Role: body.Role, Tenant: body.Tenant}
+	if err := grant.Validate(); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+	if p, ok := requestPrincipal(r); ok && !p.CanGrant(grant) {
+		h.respondError(w, http.StatusForbidden, "forbidden: the grant is wider than your own", "")
+		return
+	}
+	a, err := h.usage.CreateRoleAssignment(r.Context(), body.Subject, grant)
+	if err != nil {
+		logError("", "failed to assign role", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to assign role", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(a)
+}
+
+func (h *Handler) HandleDeleteRole(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid role assignment id", "")
+		return
+	}
+	ok, err := h.usage.DeleteRoleAssignment(r.Context(), id)
+	if err != nil {
+		logError("", "failed to delete role assignment", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to delete role assignment", "")
+		return
+	}
+	if !ok {
+		h.respondError(w, http.StatusNotFound, "role assignment not found", "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetRetention shows the retention policies and the latest reports.
+func (h *Handler) HandleGetRetention(w http.ResponseWriter, r *http.Request) {
+	runs, err := h.usage.ListRetentionRuns(r.Context(), 100)
+	if err != nil {
+		logError("", "failed to list retention reports", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to list retention reports", "")
+		return
+	}
+	if runs == nil {
+		runs = []usage.RetentionRun{}
+	}
+	policies := h.retention.Policies()
+	if policies == nil {
+		policies = []config.RetentionPolicy{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"policies": policies, "runs": runs})
+}
+
+// HandleRunRetention applies the retention policies now. With dry_run=true
+// it only reports what would be purged or anonymized.
+func (h *Handler) HandleRunRetention(w http.ResponseWriter, r *http.Request) {
+	if len(h.retention.Policies()) == 0 {
+		h.respondError(w, http.StatusConflict, "no retention policies configured", "")
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	runs, err := h.retention.Run(r.Context(), dryRun)
+	if err != nil {
+		logError("", "retention run failed", err)
+		h.respondError(w, http.StatusInternalServerError, err.Error(), "")
+		return
+	}
+	if runs == nil {
+		runs = []usage.RetentionRun{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"dry_run": dryRun, "runs": runs})
+}
+
+// HandleListArchives lists the usage partitions archived to the archive
+// sink, newest first.
+func (h *Handler) HandleListArchives(w http.ResponseWriter, r *http.Request) {
+	archives, err := h.usage.ListArchives(r.Context())
+	if err != nil {
+		logError("", "failed to list archives", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to list archives", "")
+		return
+	}
+	if archives == nil {
+		archives = []usage.Archive{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"archives": archives})
+}
+
+// HandleDeleteUserData serves DELETE /admin/data?tenant=&user=: it deletes
+// (or with mode=anonymize, anonymizes) every stored request, attempt, event
+// and payload attributed to an end user through the metadata schema's
+// end-user key, drops their resumable streams, cached responses and the
+// session summaries of their conversations, and returns the deletion
+// manifest.
+func (h *Handler) HandleDeleteUserData(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	tenant, user, mode := q.Get("tenant"), q.Get("user"), q.Get("mode")
+	if tenant == "" || user == "" {
+		h.respondError(w, http.StatusBadRequest, "tenant and user are required", "")
+		return
+	}
+	if mode != "" && mode != "delete" && mode != "anonymize" {
+		h.respondError(w, http.StatusBadRequest, "mode must be delete or anonymize", "")
+		return
+	}
+
+	d, err := h.usage.DeleteUserData(r.Context(), tenant, h.metadata.UserKey(), user, mode == "anonymize")
+	if err != nil {
+		logError("", "failed to delete user data", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to delete user data", "")
+		return
+	}
+	for _, id := range d.RequestIDs {
+		if h.streams.Remove(id) {
+			d.StreamBuffers++
+		}
+	}
+	if h.cache != nil {
+		n, err := h.cache.Purge(r.Context(), cache.Filter{Tenant: tenant, User: usage.HashUser(user)})
+		if err != nil {
+			logError("", "failed to purge the user's cached responses", err)
+		}
+		d.CacheEntries = n
+		for _, conversation := range d.ConversationIDs {
+			if err := h.cache.DeleteAged(r.Context(), sessionKey(tenant, conversation)); err != nil {
+				logError("", "failed to delete a session summary", err)
+			}
+		}
+	}
+	if p, ok := requestPrincipal(r); ok {
+		d.RequestedBy = p.Subject
+	}
+	d.CompletedAt = time.Now().UTC()
+	if err := h.usage.RecordUserDeletion(r.Context(), &d); err != nil {
+		// The data is gone already; only the audit record is missing.
+		logError("", "failed to record user data deletion", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+// HandleListDeadLetters lists webhook events that could not be delivered,
+// filtered by the tenant query parameter when given.
+func (h *Handler) HandleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	letters, err := h.usage.ListDeadLetters(r.Context(), r.URL.Query().Get("tenant"), 100)
+	if err != nil {
+		logError("", "failed to list dead letters", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to list dead letters", "")
+		return
+	}
+	if letters == nil {
+		letters = []webhook.DeadLetter{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"dead_letters": letters})
+}
+
+// HandleRedeliverDeadLetter sends a dead letter again with a fresh
+// signature and the usual retries, for callers who manage its tenant. It leaves the dead letter queue right
+// away and comes back as a new one if delivery fails again.
+func (h *Handler) HandleRedeliverDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid dead letter id", "")
+		return
+	}
+	d, err := h.usage.GetDeadLetter(r.Context(), id)
+	if errors.Is(err, usage.ErrNoDeadLetter) {
+		// Authorize before revealing whether the dead letter exists.
+		if h.permit(w, r, rbac.TenantWrite, "") {
+			h.respondError(w, http.StatusNotFound, "dead letter not found", "")
+		}
+		return
+	}
+	if err != nil {
+		logError("", "failed to load dead letter", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to load dead letter", "")
+		return
+	}
+	if !h.permit(w, r, rbac.TenantWrite, d.Tenant) {
+		return
+	}
+	d, err = h.usage.TakeDeadLetter(r.Context(), id)
+	if errors.Is(err, usage.ErrNoDeadLetter) {
+		h.respondError(w, http.StatusNotFound, "dead letter not found", "")
+		return
+	}
+	if err != nil {
+		logError("", "failed to load dead letter", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to load dead letter", "")
+		return
+	}
+	go h.webhooks.Deliver(context.WithoutCancel(r.Context()), webhook.Delivery{
+		Tenant: d.Tenant, URL: d.URL, Event: d.Event, Body: d.Payload, SecretEnv: d.SecretEnv,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(d)
+}
+
+// HandleListRoutes lists the routes stored in the database. Routes from
+// configs/routes.yaml are not included.
+func (h *Handler) HandleListRoutes(w http.ResponseWriter, r *http.Request) {
+	routes, err := h.usage.ListRoutes(r.Context())
+	if err != nil {
+		logError("", "failed to list routes", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to list routes", "")
+		return
+	}
+	docs := make([]interface{}, 0, len(routes))
+	for _, route := range routes {
+		doc, err := routeDocument(route)
+		if err != nil {
+			logError("", "failed to encode route", err)
+			h.respondError(w, http.StatusInternalServerError, "failed to list routes", "")
+			return
+		}
+		docs = append(docs, doc)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"routes": docs})
+}
+
+// HandleGetRoute returns the route with the given name as the gateway
+// currently uses it, whether it comes from the database or the config file.
+func (h *Handler) HandleGetRoute(w http.ResponseWriter, r *http.Request) {
+	route, ok := h.router.Lookup(chi.URLParam(r, "name"))
+	if !ok {
+		h.respondError(w, http.StatusNotFound, "route not found", "")
+		return
+	}
+	doc, err := routeDocument(route)
+	if err != nil {
+		logError("", "failed to encode route", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to load route", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+// HandlePutRoute creates or replaces a stored route. The body uses the same
+// fields as a route in configs/routes.yaml, as JSON or YAML.
+func (h *Handler) HandlePutRoute(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	dec := yaml.NewDecoder(r.Body)
+	dec.KnownFields(true)
+	var route config.Route
+	if err := dec.Decode(&route); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid route: "+err.Error(), "")
+		return
+	}
+	if route.Name != "" && route.Name != name {
+		h.respondError(w, http.StatusBadRequest, "route name does not match the URL", "")
+		return
+	}
+	route.Name = name
+	if err := validateRoute(route, h.registry); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+	if err := config.ValidateAccounts(h.providerOpts, route.Accounts); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+
+	created, err := h.usage.PutRoute(r.Context(), route)
+	if err != nil {
+		logError("", "failed to store route", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to store route", "")
+		return
+	}
+	h.reloadRoutes(r.Context())
+
+	doc, err := routeDocument(route)
+	if err != nil {
+		logError("", "failed to encode route", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to store route", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(doc)
+}
+
+// HandleDeleteRoute removes a stored route. A file route of the same name,
+// if any, takes effect again.
+func (h *Handler) HandleDeleteRoute(w http.ResponseWriter, r *http.Request) {
+	ok, err := h.usage.DeleteRoute(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		logError("", "failed to delete route", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to delete route", "")
+		return
+	}
+	if !ok {
+		h.respondError(w, http.StatusNotFound, "route not found", "")
+		return
+	}
+	h.reloadRoutes(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleProviderHealth reports each provider's circuit and latency, as shared
+// across the cluster when coordination is enabled, and the queue depth its
+// backend reports.
+func (h *Handler) HandleProviderHealth(w http.ResponseWriter, r *http.Request) {
+	states, err := h.health.Snapshot(r.Context())
+	if err != nil {
+		logError("", "failed to load provider health", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to load provider health", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"providers": states})
+}
+
+// HandleProviderStats reports each target's success rate and p95 latency
+// over the last minute, 5 minutes and hour, with its provider's circuit.
+// The stats are this instance's, kept in memory.
+func (h *Handler) HandleProviderStats(w http.ResponseWriter, r *http.Request) {
+	type targetStats struct {
+		health.TargetStats
+		Circuit string `json:"circuit,omitempty"`
+	}
+	states, err := h.health.Snapshot(r.Context())
+	if err != nil {
+		logError("", "failed to load provider health", err)
+	}
+	targets := []targetStats{}
+	for _, t := range h.providerStats.Snapshot() {
+		targets = append(targets, targetStats{t, states[t.Provider].Circuit})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"targets": targets})
+}
+
+// HandleGetCanary reports how a route's canary compares with the current
+// definition on this instance.
+func (h *Handler) HandleGetCanary(w http.ResponseWriter, r *http.Request) {
+	status, ok := h.canary.Status(chi.URLParam(r, "name"))
+	if !ok {
+		h.respondError(w, http.StatusNotFound, "no canary traffic for route", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// rollbackCanary removes a regressed canary from the stored route so every
+// instance stops using it. Canaries in the config file stay rolled back on
+// this instance until their version changes.
+func (h *Handler) rollbackCanary(rb canary.Rollback) {
+	logError("", "canary rolled back", fmt.Errorf("route %s version %s: %s", rb.Route, rb.Version, rb.Reason))
+	go func() {
+		ctx := context.Background()
+		routes, err := h.usage.ListRoutes(ctx)
+		if err != nil {
+			logError("", "failed to load routes for canary rollback", err)
+			return
+		}
+		for _, route := range routes {
+			if route.Name != rb.Route || route.Canary == nil || route.Canary.Version != rb.Version {
+				continue
+			}
+			route.Canary = nil
+			if _, err := h.usage.PutRoute(ctx, route); err != nil {
+				logError("", "failed to store canary rollback", err)
+				return
+			}
+			h.reloadRoutes(ctx)
+		}
+	}()
+}
+
+// reloadRoutes applies a route change on this instance right away; other
+// instances pick it up on their next sync.
+func (h *Handler) reloadRoutes(ctx context.Context) {
+	routes, err := h.usage.ListRoutes(ctx)
+	if err != nil {
+		logError("", "failed to reload routes", err)
+		return
+	}
+	h.router.SetDynamic(routes)
+}
+
+// validateRoute rejects routes the handler could not serve.
+func validateRoute(route config.Route, reg providers.Registry) error {
+	if route.Primary.Provider == "" || route.Primary.Model == "" {
+		return errors.New("route needs a primary provider and model")
+	}
+	targets := append([]config.Target{route.Primary}, route.Fallbacks...)
+	for _, t := range targets {
+		if t.Provider == "" || t.Model == "" {
+			return errors.New("every target needs a provider and model")
+		}
+		if _, ok := reg[t.Provider]; !ok {
+			return fmt.Errorf("unknown provider %q", t.Provider)
+		}
+	}
+	if p := route.MaxTokensPolicy; p != "" && p != "clamp" && p != "reject" {
+		return fmt.Errorf("unknown max_tokens_policy %q", p)
+	}
+	if err := validateParamPolicy(route.ParamPolicy); err != nil {
+		return err
+	}
+	if err := validateTransform(route.Transform); err != nil {
+		return err
+	}
+	if err := validateFailurePolicy(route.FailurePolicy); err != nil {
+		return err
+	}
+	if c := route.Continuation; c != nil && c.MaxCostUSD <= 0 {
+		return errors.New("continuation needs a max_cost_usd")
+	}
+	if err := validateCompaction(route.Compaction, reg); err != nil {
+		return err
+	}
+	if s := route.SessionSummary; s != nil {
+		if s.BudgetTokens <= 0 {
+			return errors.New("session_summary needs a budget_tokens")
+		}
+		if s.Summarizer.Provider == "" || s.Summarizer.Model == "" {
+			return errors.New("session_summary needs a summarizer provider and model")
+		}
+		if _, ok := reg[s.Summarizer.Provider]; !ok {
+			return fmt.Errorf("unknown provider %q", s.Summarizer.Provider)
+		}
+	}
+	if p := route.PromptInjection; p != nil && p.Classifier != nil {
+		if p.Classifier.Provider == "" || p.Classifier.Model == "" {
+			return errors.New("prompt_injection classifier needs a provider and model")
+		}
+		if _, ok := reg[p.Classifier.Provider]; !ok {
+			return fmt.Errorf("unknown provider %q", p.Classifier.Provider)
+		}
+	}
+	if _, err := routeModerator(route); err != nil {
+		return err
+	}
+	if _, err := routeClassifier(route); err != nil {
+		return err
+	}
+	if route.Classifier != nil {
+		for _, c := range route.Classifier.Categories {
+			if c.Target.Provider == "" || c.Target.Model == "" {
+				return fmt.Errorf("classifier category %q needs a target provider and model", c.Name)
+			}
+			if _, ok := reg[c.Target.Provider]; !ok {
+				return fmt.Errorf("unknown provider %q", c.Target.Provider)
+			}
+		}
+	}
+	if c := route.Canary; c != nil {
+		if c.Route == nil {
+			return errors.New("canary needs a route")
+		}
+		if c.Percent < 0 || c.Percent > 100 {
+			return errors.New("canary percent must be between 0 and 100")
+		}
+		if c.Route.Canary != nil {
+			return errors.New("a canary route cannot have its own canary")
+		}
+		if err := validateRoute(*c.Route, reg); err != nil {
+			return fmt.Errorf("canary: %w", err)
+		}
+	}
+	return nil
+}
+
+// routeDocument renders a route with the field names of the config file.
+func routeDocument(route config.Route) (interface{}, error) {
+	b, err := yaml.Marshal(route)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	err = yaml.Unmarshal(b, &doc)
+	return doc, err
+}
+
+// HandleListReconciliations returns the latest comparisons of provider
+// bills with the gateway's cost estimates; ?flagged=true keeps only the
+// days that disagree.
+func (h *Handler) HandleListReconciliations(w http.ResponseWriter, r *http.Request) {
+	recs, err := h.usage.ListReconciliations(r.Context(), r.URL.Query().Get("flagged") == "true", 500)
+	if err != nil {
+		logError("", "failed to list reconciliations", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to list reconciliations", "")
+		return
+	}
+	if recs == nil {
+		recs = []usage.Reconciliation{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"reconciliations": recs})
+}
+
+// HandleCacheStats reports this instance's response cache lookups by route,
+// with their hit rate.
+func (h *Handler) HandleCacheStats(w http.ResponseWriter, r *http.Request) {
+	type routeStats struct {
+		cache.Counts
+		HitRate float64 `json:"hit_rate"`
+	}
+	var total cache.Counts
+	routes := map[string]routeStats{}
+	if h.cache != nil {
+		for route, c := range h.cache.Stats() {
+			routes[route] = routeStats{Counts: c, HitRate: c.HitRate()}
+			total.Hits += c.Hits
+			total.Stale += c.Stale
+			total.Misses += c.Misses
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": h.cache != nil,
+		"total":   routeStats{Counts: total, HitRate: total.HitRate()},
+		"routes":  routes,
+	})
+}
+
+// HandlePurgeCache deletes the cached responses matching the tenant, route,
+// model and key_prefix query parameters. At least one is required, so a
+// typo cannot empty the whole cache.
+func (h *Handler) HandlePurgeCache(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := cache.Filter{Tenant: q.Get("tenant"), Route: q.Get("route"), Model: q.Get("model"), KeyPrefix: q.Get("key_prefix")}
+	if f == (cache.Filter{}) {
+		h.respondError(w, http.StatusBadRequest, "one of tenant, route, model or key_prefix is required", "")
+		return
+	}
+	purged := 0
+	if h.cache != nil {
+		var err error
+		if purged, err = h.cache.Purge(r.Context(), f); err != nil {
+			logError("", "failed to purge cache", err)
+			h.respondError(w, http.StatusInternalServerError, "failed to purge cache", "")
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"purged": purged})
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateRoute(t *testing.T) {
+	reg := providers.Registry{"openai": nil, "anthropic": nil}
+	tests := []struct {
+		name    string
+		route   config.Route
+		wantErr bool
+	}{
+		{"valid", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, Fallbacks: []config.Target{{Provider: "anthropic", Model: "claude-3-5-sonnet"}}}, false},
+		{"no primary", config.Route{}, true},
+		{"unknown provider", config.Route{Primary: config.Target{Provider: "nope", Model: "x"}}, true},
+		{"fallback without model", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, Fallbacks: []config.Target{{Provider: "anthropic"}}}, true},
+		{"canary without route", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, Canary: &config.Canary{Percent: 10}}, true},
+		{"canary with unknown provider", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, Canary: &config.Canary{Percent: 10, Route: &config.Route{Primary: config.Target{Provider: "nope", Model: "x"}}}}, true},
+		{"bad max tokens policy", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, MaxTokensPolicy: "truncate"}, true},
+		{"unknown moderation category", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, Moderation: &config.Moderation{Categories: []string{"gossip"}}}, true},
+		{"bad moderation action", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, Moderation: &config.Moderation{Terms: []string{"x"}, Action: "block"}}, true},
+		{"classifier without exemplars", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, Classifier: &config.Classifier{Categories: []config.ClassifierCategory{{Name: "code", Target: config.Target{Provider: "openai", Model: "gpt-4o"}}}}}, true},
+		{"unknown compaction strategy", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, Compaction: &config.Compaction{Strategy: "newest"}}, true},
+		{"summarize without summarizer", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, Compaction: &config.Compaction{Strategy: "summarize"}}, true},
+		{"summarize", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, Compaction: &config.Compaction{Strategy: "summarize", Summarizer: &config.Target{Provider: "openai", Model: "gpt-4o-mini"}}}, false},
+		{"session summary without budget", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, SessionSummary: &config.SessionSummary{Summarizer: config.Target{Provider: "openai", Model: "gpt-4o-mini"}}}, true},
+		{"session summary with unknown provider", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, SessionSummary: &config.SessionSummary{BudgetTokens: 8000, Summarizer: config.Target{Provider: "nope", Model: "x"}}}, true},
+		{"prompt injection classifier without model", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, PromptInjection: &config.PromptInjection{Classifier: &config.Target{Provider: "openai"}}}, true},
+		{"prompt injection classifier with unknown provider", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, PromptInjection: &config.PromptInjection{Classifier: &config.Target{Provider: "nope", Model: "x"}}}, true},
+		{"prompt injection classifier", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, PromptInjection: &config.PromptInjection{Classifier: &config.Target{Provider: "anthropic", Model: "claude-3-haiku"}}}, false},
+		{"classifier with unknown provider", config.Route{Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, Classifier: &config.Classifier{Categories: []config.ClassifierCategory{{Name: "code", Exemplars: []string{"fix my bug"}, Target: config.Target{Provider: "nope", Model: "x"}}}}}, true},
+	}
+	for _, tt := range tests {
+		if err := validateRoute(tt.route, reg); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got err %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRouteDocumentRoundTrip(t *testing.T) {
+	body := `{"match": {"use_case": "triage"}, "primary": {"provider": "openai", "model": "gpt-4o-mini"}, "retries": 2}`
+	var route config.Route
+	if err := yaml.Unmarshal([]byte(body), &route); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := routeDocument(route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := doc.(map[string]interface{})
+	if m["retries"] != 2 {
+		t.Errorf("expected retries 2, got %v", m["retries"])
+	}
+	if primary, _ := m["primary"].(map[string]interface{}); primary["model"] != "gpt-4o-mini" {
+		t.Errorf("expected primary model in config field names, got %v", m["primary"])
+	}
+}
+
+func TestHandleDeleteUserDataValidatesQuery(t *testing.T) {
+	h := &Handler{}
+	for _, query := range []string{"", "?tenant=acme", "?user=u1", "?tenant=acme&user=u1&mode=shred"} {
+		w := httptest.NewRecorder()
+		h.HandleDeleteUserData(w, httptest.NewRequest("DELETE", "/admin/data"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, w.Code)
+		}
+	}
+}
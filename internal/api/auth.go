@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yewintnaing/ai-gateway/internal/rbac"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+var errUnauthenticated = errors.New("missing or invalid credentials")
+
+// Require guards an admin or usage endpoint with perm, checked against the
+// tenant in the URL path or, without one, across all tenants. Unless
+// AUTH_MODE is "rbac" every request is let through.
+func (h *Handler) Require(perm rbac.Permission) func(http.Handler) http.Handler {
+	return h.require(perm, func(r *http.Request) string { return chi.URLParam(r, "tenant") })
+}
+
+// RequireTenantQuery is Require for endpoints whose handler confines what
+// it reads or changes to the tenant query parameter: perm is checked for
+// that tenant, or across all tenants when it is absent.
+func (h *Handler) RequireTenantQuery(perm rbac.Permission) func(http.Handler) http.Handler {
+	return h.require(perm, func(r *http.Request) string { return r.URL.Query().Get("tenant") })
+}
+
+func (h *Handler) require(perm rbac.Permission, tenantOf func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if h.auth.Mode != "rbac" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			p, ok := h.authorize(w, r, perm, tenantOf(r))
+			if !ok {
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+		})
+	}
+}
+
+// permit is authorize for handlers that learn the tenant only from the
+// record they load; it lets every request through unless AUTH_MODE is
+// "rbac".
+func (h *Handler) permit(w http.ResponseWriter, r *http.Request, perm rbac.Permission, tenant string) bool {
+	if h.auth.Mode != "rbac" {
+		return true
+	}
+	_, ok := h.authorize(w, r, perm, tenant)
+	return ok
+}
+
+// authorize checks that the caller holds perm for tenant, writing the 401
+// or 403 response and returning false if not.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, perm rbac.Permission, tenant string) (rbac.Principal, bool) {
+	p, err := h.authenticate(r)
+	if errors.Is(err, errUnauthenticated) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		h.respondError(w, http.StatusUnauthorized, err.Error(), "")
+		return p, false
+	}
+	if err != nil {
+		logError("", "failed to authenticate", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to authenticate", "")
+		return p, false
+	}
+	if !p.Can(perm, tenant) {
+		h.respondError(w, http.StatusForbidden, "forbidden: requires "+string(perm), "")
+		return p, false
+	}
+	return p, true
+}
+
+type principalKey struct{}
+
+// requestPrincipal returns the caller authorized by Require, if any.
+func requestPrincipal(r *http.Request) (rbac.Principal, bool) {
+	p, ok := r.Context().Value(principalKey{}).(rbac.Principal)
+	return p, ok
+}
+
+// authenticate resolves the bearer credential to a principal: the bootstrap
+// admin token, a gateway API key, or a JWT signed with JWT_SECRET. API keys
+// get the roles assigned to them; JWTs get their claimed roles plus those
+// assigned to their subject.
+func (h *Handler) authenticate(r *http.Request) (rbac.Principal, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return rbac.Principal{}, errUnauthenticated
+	}
+
+	if h.auth.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.auth.AdminToken)) == 1 {
+		return rbac.Principal{Subject: "admin-token", Grants: []rbac.Grant{{Role: rbac.Admin}}}, nil
+	}
+
+	var p rbac.Principal
+	switch {
+	case usage.IsAPIKey(token):
+		key, err := h.usage.AuthenticateAPIKey(r.Context(), token)
+		if errors.Is(err, usage.ErrInvalidKey) {
+			return p, errUnauthenticated
+		}
+		if err != nil {
+			return p, err
+		}
+		p.Subject = rbac.KeySubject(key.ID)
+	case h.auth.JWTSecret != "":
+		claims, err := rbac.ParseJWT(token, []byte(h.auth.JWTSecret), time.Now())
+		if err != nil || claims.Subject == "" {
+			return p, errUnauthenticated
+		}
+		p.Subject = claims.Subject
+		p.Grants = claims.Grants()
+	default:
+		return p, errUnauthenticated
+	}
+
+	if h.roleGrants != nil {
+		grants, err := h.roleGrants(r.Context(), p.Subject)
+		if err != nil {
+			return p, err
+		}
+		p.Grants = append(p.Grants, grants...)
+	}
+	return p, nil
+}
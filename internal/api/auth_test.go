@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/rbac"
+)
+
+func testJWT(payload, secret string) string {
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(`{"alg":"HS256"}`)) + "." + enc.EncodeToString([]byte(payload))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func TestRequireEnforcesRoles(t *testing.T) {
+	h := &Handler{
+		auth: config.Auth{Mode: "rbac", AdminToken: "bootstrap", JWTSecret: "s3cret"},
+		roleGrants: func(ctx context.Context, subject string) ([]rbac.Grant, error) {
+			switch subject {
+			case "bob":
+				return []rbac.Grant{{Role: rbac.Operator}}, nil
+			case "erin":
+				return []rbac.Grant{{Role: rbac.Operator, Tenant: "acme"}}, nil
+			}
+			return nil, nil
+		},
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	r := chi.NewRouter()
+	r.With(h.Require(rbac.RoutesWrite)).Put("/admin/routes/{name}", ok)
+	r.With(h.Require(rbac.TenantWrite)).Post("/admin/tenants/{tenant}/keys", ok)
+	r.With(h.RequireTenantQuery(rbac.UsageRead)).Get("/v1/usage", ok)
+
+	viewer := testJWT(`{"sub":"carol","roles":["viewer"]}`, "s3cret")
+	owner := testJWT(`{"sub":"dave","roles":["tenant-owner"],"tenant":"acme"}`, "s3cret")
+	scoped := testJWT(`{"sub":"erin"}`, "s3cret")
+	cases := []struct {
+		method, path, token string
+		want                int
+	}{
+		{"PUT", "/admin/routes/chat", "", http.StatusUnauthorized},
+		{"PUT", "/admin/routes/chat", "nope", http.StatusUnauthorized},
+		{"PUT", "/admin/routes/chat", testJWT(`{"sub":"carol","roles":["admin"]}`, "wrong"), http.StatusUnauthorized},
+		{"PUT", "/admin/routes/chat", "bootstrap", http.StatusOK},
+		{"PUT", "/admin/routes/chat", viewer, http.StatusForbidden},
+		// bob's operator role comes from the database, not the token.
+		{"PUT", "/admin/routes/chat", testJWT(`{"sub":"bob"}`, "s3cret"), http.StatusOK},
+		// A tenant query parameter does not scope an endpoint that is not
+		// confined to it.
+		{"PUT", "/admin/routes/chat?tenant=acme", scoped, http.StatusForbidden},
+		{"POST", "/admin/tenants/acme/keys", scoped, http.StatusOK},
+		{"POST", "/admin/tenants/acme/keys", owner, http.StatusOK},
+		{"POST", "/admin/tenants/globex/keys", owner, http.StatusForbidden},
+		{"GET", "/v1/usage?tenant=acme", owner, http.StatusOK},
+		{"GET", "/v1/usage", owner, http.StatusForbidden},
+		{"GET", "/v1/usage", viewer, http.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.want {
+			t.Errorf("%s %s: expected %d, got %d", c.method, c.path, c.want, w.Code)
+		}
+	}
+}
+
+func TestHandleAssignRoleLimitsGrants(t *testing.T) {
+	h := &Handler{}
+	p := rbac.Principal{Subject: "erin", Grants: []rbac.Grant{{Role: rbac.Admin, Tenant: "acme"}}}
+	for _, body := range []string{
+		`{"subject": "erin", "role": "admin"}`,
+		`{"subject": "erin", "role": "admin", "tenant": "globex"}`,
+	} {
+		req := httptest.NewRequest("POST", "/admin/roles", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), principalKey{}, p))
+		w := httptest.NewRecorder()
+		h.HandleAssignRole(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestRequireOpenWithoutRBAC(t *testing.T) {
+	h := &Handler{}
+	called := false
+	handler := h.Require(rbac.RolesManage)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/roles", nil))
+	if !called {
+		t.Error("expected endpoints to stay open when AUTH_MODE is unset")
+	}
+}
+
+func TestHandleGetPayloadNeedsRBAC(t *testing.T) {
+	h := &Handler{}
+	w := httptest.NewRecorder()
+	h.HandleGetPayload(w, httptest.NewRequest("GET", "/admin/requests/req-1/payload", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected payloads to stay sealed without RBAC, got %d", w.Code)
+	}
+}
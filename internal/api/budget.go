@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// outputCap returns the effective max_tokens ceiling for a request: the
+// tighter of the route and tenant caps, or 0 when neither sets one.
+func outputCap(route config.Route, tenant config.Tenant) int {
+	cap := route.MaxOutputTokens
+	if t := tenant.MaxOutputTokens; t > 0 && (cap == 0 || t < cap) {
+		cap = t
+	}
+	return cap
+}
+
+// enforceOutputCap applies the cap to req.MaxTokens according to the route's
+// policy. Requests without max_tokens are given the cap so the provider stops
+// generating at the same point the gateway would cut the stream. It reports
+// whether the client's value was lowered.
+func enforceOutputCap(req *ChatRequest, cap int, policy string) (bool, error) {
+	if cap <= 0 {
+		return false, nil
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = cap
+		return false, nil
+	}
+	if req.MaxTokens <= cap {
+		return false, nil
+	}
+	if policy == "reject" {
+		return false, fmt.Errorf("max_tokens %d exceeds the limit of %d for this route", req.MaxTokens, cap)
+	}
+	req.MaxTokens = cap
+	return true, nil
+}
+
+// capTargetOutput lowers a provider request's max_tokens to the output
+// budget after a target's params have been merged in; target params never
+// lift the budget.
+func capTargetOutput(req *providers.ChatRequest, cap int) {
+	if cap > 0 && req.MaxTokens > cap {
+		req.MaxTokens = cap
+	}
+}
+
+// finishChunk builds a content-less chunk that ends the stream with reason.
+func finishChunk(from providers.ChatChunk, reason string) providers.ChatChunk {
+	final := providers.ChatChunk{ID: from.ID, Object: from.Object, Created: from.Created, Model: from.Model}
+	final.Choices = make([]providers.ChunkChoice, 1)
+	final.Choices[0].FinishReason = reason
+	return final
+}
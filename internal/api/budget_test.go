@@ -0,0 +1,71 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestOutputCap(t *testing.T) {
+	route := config.Route{MaxOutputTokens: 1000}
+	if got := outputCap(route, config.Tenant{}); got != 1000 {
+		t.Errorf("expected route cap 1000, got %d", got)
+	}
+	if got := outputCap(route, config.Tenant{MaxOutputTokens: 500}); got != 500 {
+		t.Errorf("expected tighter tenant cap 500, got %d", got)
+	}
+	if got := outputCap(config.Route{}, config.Tenant{MaxOutputTokens: 500}); got != 500 {
+		t.Errorf("expected tenant cap 500, got %d", got)
+	}
+}
+
+func TestEnforceOutputCap(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxTokens   int
+		policy      string
+		wantTokens  int
+		wantClamped bool
+		wantErr     bool
+	}{
+		{name: "Unset gets cap", maxTokens: 0, wantTokens: 100},
+		{name: "Under cap untouched", maxTokens: 50, wantTokens: 50},
+		{name: "Over cap clamped", maxTokens: 500, wantTokens: 100, wantClamped: true},
+		{name: "Over cap rejected", maxTokens: 500, policy: "reject", wantTokens: 500, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &ChatRequest{MaxTokens: tt.maxTokens}
+			clamped, err := enforceOutputCap(req, 100, tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if clamped != tt.wantClamped {
+				t.Errorf("expected clamped=%v, got %v", tt.wantClamped, clamped)
+			}
+			if req.MaxTokens != tt.wantTokens {
+				t.Errorf("expected max_tokens %d, got %d", tt.wantTokens, req.MaxTokens)
+			}
+		})
+	}
+}
+
+func TestCapTargetOutput(t *testing.T) {
+	req := providers.ChatRequest{MaxTokens: 4096}
+	capTargetOutput(&req, 100)
+	if req.MaxTokens != 100 {
+		t.Errorf("expected target params clamped to 100, got %d", req.MaxTokens)
+	}
+	req.MaxTokens = 50
+	capTargetOutput(&req, 100)
+	if req.MaxTokens != 50 {
+		t.Errorf("expected a lower value kept, got %d", req.MaxTokens)
+	}
+	req.MaxTokens = 4096
+	capTargetOutput(&req, 0)
+	if req.MaxTokens != 4096 {
+		t.Errorf("expected no budget to leave max_tokens alone, got %d", req.MaxTokens)
+	}
+}
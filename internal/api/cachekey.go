@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/yewintnaing/ai-gateway/internal/cache"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// cacheScope keeps cache entries apart per tenant and route. An entry has
+// been through the output guardrails of the request that stored it, its
+// tenant's word list and its route's secret scan and moderation, so it is
+// only served to requests those same guardrails apply to.
+type cacheScope struct {
+	Tenant string `json:"tenant"`
+	Route  string `json:"route"`
+}
+
+// cacheParams are the parameters sent to the provider with the messages,
+// as they stand after budgets and parameter policy. Requests that differ in
+// any of them get different completions (n choices, a stop sequence, a
+// seed), so they do not share an entry.
+type cacheParams struct {
+	Temperature      *float64                `json:"temperature,omitempty"`
+	MaxTokens        int                     `json:"max_tokens,omitempty"`
+	TopP             *float64                `json:"top_p,omitempty"`
+	Stop             providers.StopSequences `json:"stop,omitempty"`
+	PresencePenalty  *float64                `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64                `json:"frequency_penalty,omitempty"`
+	LogitBias        map[string]float64      `json:"logit_bias,omitempty"`
+	Seed             *int                    `json:"seed,omitempty"`
+	N                int                     `json:"n,omitempty"`
+	ReasoningEffort  string                  `json:"reasoning_effort,omitempty"`
+	Thinking         *providers.Thinking     `json:"thinking,omitempty"`
+	// Tools and ToolChoice decide whether the answer is text or tool
+	// calls. Tool calls and results already in the conversation, such as
+	// those converted from Anthropic content blocks, are part of the
+	// messages.
+	Tools      []providers.Tool       `json:"tools,omitempty"`
+	ToolChoice json.RawMessage        `json:"tool_choice,omitempty"`
+	Extra      map[string]interface{} `json:"extra,omitempty"`
+	Backend    map[string]interface{} `json:"backend,omitempty"`
+}
+
+// responseCacheKey is the key req's completion is cached under on route.
+func responseCacheKey(tenant string, route config.Route, req ChatRequest) (string, error) {
+	params := cacheParams{
+		Temperature: req.Temperature, MaxTokens: req.MaxTokens, TopP: req.TopP, Stop: req.Stop,
+		PresencePenalty: req.PresencePenalty, FrequencyPenalty: req.FrequencyPenalty, LogitBias: req.LogitBias,
+		Seed: req.Seed, N: req.N, ReasoningEffort: req.ReasoningEffort, Thinking: req.Thinking,
+		Tools: req.Tools, ToolChoice: req.ToolChoice,
+		Extra: req.extra, Backend: req.backend,
+	}
+	return cache.GenerateKey(route.Primary.Model, req.Messages, cacheScope{Tenant: tenant, Route: route.Name}, params)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestResponseCacheKey(t *testing.T) {
+	route := config.Route{Name: "support", Primary: config.Target{Provider: "openai", Model: "gpt-4o"}}
+	req := ChatRequest{Messages: []providers.Message{{Role: "user", Content: "hi"}}}
+	base, err := responseCacheKey("acme", route, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := responseCacheKey("acme", route, req); again != base {
+		t.Error("expected the same request to get the same key")
+	}
+
+	if other, _ := responseCacheKey("globex", route, req); other == base {
+		t.Error("expected another tenant to get its own key")
+	}
+	moderated := route
+	moderated.Name = "support-moderated"
+	if other, _ := responseCacheKey("acme", moderated, req); other == base {
+		t.Error("expected another route to get its own key")
+	}
+
+	one, three, seed := 1.0, 3, 7
+	variants := map[string]func(*ChatRequest){
+		"temperature": func(r *ChatRequest) { r.Temperature = &one },
+		"max_tokens":  func(r *ChatRequest) { r.MaxTokens = 64 },
+		"top_p":       func(r *ChatRequest) { r.TopP = &one },
+		"stop":        func(r *ChatRequest) { r.Stop = providers.StopSequences{"\n"} },
+		"penalty":     func(r *ChatRequest) { r.PresencePenalty = &one },
+		"logit_bias":  func(r *ChatRequest) { r.LogitBias = map[string]float64{"50256": -100} },
+		"seed":        func(r *ChatRequest) { r.Seed = &seed },
+		"n":           func(r *ChatRequest) { r.N = three },
+		"extra":       func(r *ChatRequest) { r.extra = map[string]interface{}{"safe_prompt": true} },
+		"tools": func(r *ChatRequest) {
+			r.Tools = []providers.Tool{{Type: "function", Function: providers.ToolFunction{Name: "lookup"}}}
+		},
+		"tool_choice": func(r *ChatRequest) { r.ToolChoice = json.RawMessage(`"required"`) },
+		"tool_result": func(r *ChatRequest) {
+			r.Messages = append(r.Messages[:1:1], providers.Message{Role: "tool", ToolCallID: "call_1", Content: "42"})
+		},
+	}
+	for name, change := range variants {
+		changed := req
+		change(&changed)
+		if key, _ := responseCacheKey("acme", route, changed); key == base {
+			t.Errorf("expected %s to change the key", name)
+		}
+	}
+}
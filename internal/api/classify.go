@@ -0,0 +1,58 @@
+package api
+
+import (
+	"github.com/yewintnaing/ai-gateway/internal/classify"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// routeClassifier builds the route's topic classifier, or nil when it has
+// none.
+func routeClassifier(route config.Route) (*classify.Classifier, error) {
+	if route.Classifier == nil {
+		return nil, nil
+	}
+	cats := make([]classify.Category, len(route.Classifier.Categories))
+	for i, c := range route.Classifier.Categories {
+		cats[i] = classify.Category{Name: c.Name, Exemplars: c.Exemplars}
+	}
+	return classify.New(cats, route.Classifier.MinSimilarity)
+}
+
+// classifyRoute assigns the last user message to one of the route's
+// categories and moves that category's target to the front of the route.
+// The route's own targets stay behind it as fallbacks. category is empty
+// when the route has no classifier or nothing matched.
+func classifyRoute(route config.Route, messages []providers.Message) (out config.Route, category string, similarity float64, err error) {
+	c, err := routeClassifier(route)
+	if c == nil || err != nil {
+		return route, "", 0, err
+	}
+	var text string
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			text = messages[i].Content
+			break
+		}
+	}
+	category, similarity, ok := c.Classify(text)
+	if !ok {
+		return route, "", similarity, nil
+	}
+
+	var target config.Target
+	for _, cat := range route.Classifier.Categories {
+		if cat.Name == category {
+			target = cat.Target
+		}
+	}
+	fallbacks := make([]config.Target, 0, len(route.Fallbacks)+1)
+	for _, t := range append([]config.Target{route.Primary}, route.Fallbacks...) {
+		if t.Provider != target.Provider || t.Model != target.Model {
+			fallbacks = append(fallbacks, t)
+		}
+	}
+	route.Primary = target
+	route.Fallbacks = fallbacks
+	return route, category, similarity, nil
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestClassifyRoute(t *testing.T) {
+	coder := config.Target{Provider: "anthropic", Model: "claude-3-5-sonnet"}
+	route := config.Route{
+		Primary:   config.Target{Provider: "openai", Model: "gpt-4o-mini"},
+		Fallbacks: []config.Target{coder},
+		Classifier: &config.Classifier{Categories: []config.ClassifierCategory{
+			{Name: "code", Target: coder, Exemplars: []string{
+				"Why does my Go function return a nil pointer error?",
+				"Write a Python script that parses a CSV file",
+			}},
+			{Name: "casual", Target: config.Target{Provider: "openai", Model: "gpt-4o-mini"}, Exemplars: []string{
+				"What should I cook for dinner tonight?",
+				"Tell me a fun fact about cats",
+			}},
+		}},
+	}
+
+	messages := []providers.Message{
+		{Role: "system", Content: "Be helpful."},
+		{Role: "user", Content: "My Python function throws a nil pointer error"},
+	}
+	got, category, _, err := classifyRoute(route, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if category != "code" {
+		t.Fatalf("category = %q, want code", category)
+	}
+	if got.Primary.Model != coder.Model {
+		t.Errorf("primary = %+v, want %+v", got.Primary, coder)
+	}
+	if len(got.Fallbacks) != 1 || got.Fallbacks[0].Model != route.Primary.Model {
+		t.Errorf("fallbacks = %+v, want only the route's primary", got.Fallbacks)
+	}
+
+	got, category, _, _ = classifyRoute(route, []providers.Message{{Role: "user", Content: "zzz qqq"}})
+	if category != "" || got.Primary.Model != route.Primary.Model {
+		t.Errorf("unmatched prompt: category %q, primary %+v", category, got.Primary)
+	}
+
+	route.Classifier = nil
+	if _, category, _, _ = classifyRoute(route, messages); category != "" {
+		t.Errorf("route without classifier got category %q", category)
+	}
+}
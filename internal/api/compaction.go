@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/yewintnaing/ai-gateway/internal/compact"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// summaryInstruction is the summarizer's system prompt.
+const summaryInstruction = "Summarize the conversation below so it can stand in for it as context. " +
+	"Keep facts, decisions, names, numbers, tool results and open questions. Be brief; do not address the reader."
+
+// contextFit fits a request's messages to its targets' context windows
+// under the route's compaction policy, compacting them once per budget
+// however many targets and retries share it.
+type contextFit struct {
+	mu        sync.Mutex // consensus fits targets at once
+	h         *Handler
+	req       ChatRequest
+	route     config.Route
+	residency string
+	messages  []providers.Message
+	data      headerData
+	fitted    map[int][]providers.Message
+}
+
+func (h *Handler) contextFit(req ChatRequest, route config.Route, residency string, messages []providers.Message, data headerData) *contextFit {
+	return &contextFit{h: h, req: req, route: route, residency: residency, messages: messages, data: data, fitted: map[int][]providers.Message{}}
+}
+
+// forTarget returns the messages to send target: the request's, or them
+// compacted when they would overflow its context window.
+func (f *contextFit) forTarget(ctx context.Context, target config.Target) ([]providers.Message, error) {
+	policy := f.route.Compaction
+	if policy == nil || target.ContextWindow <= 0 {
+		return f.messages, nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reserve := f.req.MaxTokens
+	if v, ok := target.Params["max_tokens"]; ok {
+		if n, ok := toInt(v); ok {
+			reserve = n
+		}
+	}
+	if reserve <= 0 {
+		reserve = policy.Reserve()
+	}
+	budget := target.ContextWindow - reserve
+	if msgs, ok := f.fitted[budget]; ok {
+		return msgs, nil
+	}
+
+	opts := compact.Options{
+		Strategy:      compact.Strategy(policy.Strategy),
+		Budget:        budget,
+		KeepFirst:     policy.KeepFirst,
+		SummaryTokens: policy.SummaryLength(),
+	}
+	if opts.Strategy == "" {
+		opts.Strategy = compact.SlidingWindow
+	}
+	if opts.Strategy == compact.Summarize && policy.Summarizer != nil {
+		summarizer, ok := f.h.summarizerRoute(f.route, *policy.Summarizer, f.data.Tenant, f.residency)
+		if !ok {
+			// The request's data may not go to the summarizer.
+			opts.Strategy = compact.SlidingWindow
+		}
+		opts.Summarize = func(ctx context.Context, messages []providers.Message) (string, error) {
+			record := usage.Record{
+				RequestID: uuid.New().String(), Tenant: f.data.Tenant, UseCase: f.data.UseCase, RouteName: f.route.Name,
+				Metadata: map[string]interface{}{"compaction_of": f.data.RequestID},
+			}
+			return f.h.summarize(ctx, summarizer, messages, opts.SummaryTokens, record)
+		}
+	}
+	res, err := compact.Compact(ctx, f.messages, opts)
+	if err != nil && opts.Strategy == compact.Summarize && !errors.Is(err, compact.ErrNoFit) {
+		logError(f.data.RequestID, "context summarization failed, dropping turns instead", err)
+		opts.Strategy = compact.SlidingWindow
+		res, err = compact.Compact(ctx, f.messages, opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", target.Model, err)
+	}
+	if res.Dropped > 0 {
+		f.h.usage.LogEvent(ctx, f.data.RequestID, usage.Event{
+			Kind: "context_compacted",
+			Detail: map[string]interface{}{
+				"model": target.Model, "strategy": string(opts.Strategy), "budget": budget,
+				"dropped": res.Dropped, "summarized": res.Summarized,
+			},
+		})
+	}
+	f.fitted[budget] = res.Messages
+	return res.Messages, nil
+}
+
+// summarizerRoute returns the route to have target summarize a request's
+// messages on, held to the same tenant policy, residency and zero
+// retention as the request. It reports false when they rule target out.
+func (h *Handler) summarizerRoute(route config.Route, target config.Target, tenant, residency string) (config.Route, bool) {
+	summarizer := config.Route{Name: route.Name, Primary: target, TimeoutMS: route.TimeoutMS, ZeroRetention: route.ZeroRetention, Accounts: route.Accounts}
+	summarizer, err := restrictTargets(summarizer, h.tenants[tenant], residency, h.providerOpts)
+	return summarizer, err == nil
+}
+
+// summarize has summarizer's target write a summary of messages in at most
+// maxTokens, logged as record's request so its cost is accounted for. The
+// summarizer sees the messages with PII masked, like any target.
+func (h *Handler) summarize(ctx context.Context, summarizer config.Route, messages []providers.Message, maxTokens int, record usage.Record) (string, error) {
+	var transcript strings.Builder
+	for _, m := range messages {
+		if m.Content != "" {
+			fmt.Fprintf(&transcript, "%s: %s\n", m.Role, m.Content)
+		}
+		for _, c := range m.ToolCalls {
+			fmt.Fprintf(&transcript, "%s called %s(%s)\n", m.Role, c.Function.Name, c.Function.Arguments)
+		}
+	}
+	req := ChatRequest{
+		Messages: []providers.Message{
+			{Role: "system", Content: summaryInstruction},
+			{Role: "user", Content: transcript.String()},
+		},
+		MaxTokens: maxTokens,
+	}
+	resp, _, _, _ := h.completeOutOfBand(ctx, summarizer, req, record)
+	if resp == nil {
+		return "", fmt.Errorf("summarizer %s failed", summarizer.Primary.Model)
+	}
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("summarizer %s returned no summary", summarizer.Primary.Model)
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// validateCompaction rejects compaction policies the handler could not
+// apply.
+func validateCompaction(c *config.Compaction, reg providers.Registry) error {
+	if c == nil {
+		return nil
+	}
+	known := c.Strategy == ""
+	for _, s := range compact.Strategies {
+		known = known || c.Strategy == string(s)
+	}
+	if !known {
+		return fmt.Errorf("unknown compaction strategy %q", c.Strategy)
+	}
+	if c.Strategy != string(compact.Summarize) {
+		return nil
+	}
+	if c.Summarizer == nil || c.Summarizer.Provider == "" || c.Summarizer.Model == "" {
+		return errors.New("compaction strategy summarize needs a summarizer provider and model")
+	}
+	if _, ok := reg[c.Summarizer.Provider]; !ok {
+		return fmt.Errorf("unknown provider %q", c.Summarizer.Provider)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/compact"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+func TestContextFit(t *testing.T) {
+	store := &usage.Store{}
+	ctx, _ := store.Begin(context.Background(), "r1")
+	h := &Handler{usage: store}
+
+	// Each message is about 100 tokens.
+	var messages []providers.Message
+	for i := range 10 {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages = append(messages, providers.Message{Role: role, Content: strings.Repeat("x", 400)})
+	}
+	messages[9].Content = "latest"
+	route := config.Route{Compaction: &config.Compaction{}}
+	fit := h.contextFit(ChatRequest{MaxTokens: 100}, route, "", messages, headerData{RequestID: "r1"})
+
+	small := config.Target{Model: "small", ContextWindow: 500}
+	got, err := fit.forTarget(ctx, small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := compact.Count(got); n > 400 || len(got) >= len(messages) {
+		t.Errorf("expected the prompt fitted to 400 tokens, got %d tokens in %d messages", n, len(got))
+	}
+	if got[len(got)-1].Content != "latest" {
+		t.Error("expected the latest message kept")
+	}
+	if again, _ := fit.forTarget(ctx, small); &again[0] != &got[0] {
+		t.Error("expected the compaction reused for the same budget")
+	}
+
+	if got, _ := fit.forTarget(ctx, config.Target{Model: "large"}); len(got) != len(messages) {
+		t.Error("expected a target without a context window sent everything")
+	}
+	// max_tokens in the target's params is what its completion needs room for.
+	if _, err := fit.forTarget(ctx, config.Target{Model: "tiny", ContextWindow: 500, Params: map[string]interface{}{"max_tokens": 450}}); !errors.Is(err, compact.ErrNoFit) {
+		t.Errorf("expected ErrNoFit, got %v", err)
+	}
+
+	// A summary that cannot be written falls back to dropping turns.
+	route.Compaction = &config.Compaction{Strategy: "summarize"}
+	fit = h.contextFit(ChatRequest{MaxTokens: 100}, route, "", messages, headerData{RequestID: "r1"})
+	if got, err := fit.forTarget(ctx, small); err != nil || len(got) >= len(messages) {
+		t.Errorf("expected turns dropped, got %d messages, err %v", len(got), err)
+	}
+
+	// So does a summarizer the request's data may not be sent to, without
+	// being called.
+	h.tenants = map[string]config.Tenant{"acme-eu": {Name: "acme-eu", Residency: "eu"}}
+	h.providerOpts = map[string]config.ProviderOptions{"openai": {Region: "us"}}
+	route.Compaction.Summarizer = &config.Target{Provider: "openai", Model: "gpt-4o-mini"}
+	fit = h.contextFit(ChatRequest{MaxTokens: 100}, route, "eu", messages, headerData{Tenant: "acme-eu", RequestID: "r1"})
+	if got, err := fit.forTarget(ctx, small); err != nil || len(got) >= len(messages) {
+		t.Errorf("expected turns dropped, got %d messages, err %v", len(got), err)
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Compress gzips JSON responses of at least minBytes for clients that
+// accept it. Output is held back until minBytes have been written, so
+// small responses go out as they are; a flush before then, as streams do,
+// sends the response uncompressed. A minBytes of 0 disables compression.
+func Compress(minBytes int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if minBytes <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, minBytes: minBytes}
+			defer cw.finish()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		return q > 0
+	}
+	return false
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	minBytes int
+	status   int
+	buf      []byte
+	decided  bool
+	gz       *gzip.Writer
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.decided {
+		if cw.gz != nil {
+			return cw.gz.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.minBytes {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(false)
+	}
+	if cw.gz != nil {
+		cw.gz.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter { return cw.ResponseWriter }
+
+// decide sends the status line and what was held back, gzipped if compress
+// is set and the response is JSON that is not already encoded.
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	h := cw.Header()
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	if compress && mediaType == "application/json" && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		cw.gz = gzip.NewWriter(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := cw.Write(buf)
+	return err
+}
+
+// finish sends a response that never reached minBytes and ends the gzip
+// stream.
+func (cw *compressWriter) finish() {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			return // nothing written; leave the default response alone
+		}
+		cw.decide(false)
+	}
+	if cw.gz != nil {
+		cw.gz.Close()
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	big := `{"text":"` + strings.Repeat("a", 2048) + `"}`
+	handler := Compress(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/big":
+			io.WriteString(w, big)
+		case "/small":
+			io.WriteString(w, `{"ok":true}`)
+		case "/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: hi\n\n")
+			w.(http.Flusher).Flush()
+		}
+	}))
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", accept)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/big", "br, gzip;q=0.8")
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a large JSON response to be gzipped, got %v", rec.Header())
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(gz); string(body) != big {
+		t.Errorf("got %d bytes back, want %d", len(body), len(big))
+	}
+
+	for _, c := range []struct{ path, accept string }{
+		{"/small", "gzip"},
+		{"/stream", "gzip"},
+		{"/big", "gzip;q=0"},
+		{"/big", ""},
+	} {
+		rec := get(c.path, c.accept)
+		if rec.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s with %q: expected no compression", c.path, c.accept)
+		}
+		if rec.Body.Len() == 0 {
+			t.Errorf("%s with %q: expected the body to be sent", c.path, c.accept)
+		}
+	}
+}
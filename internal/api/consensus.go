@@ -0,0 +1,314 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/alerting"
+	"github.com/yewintnaing/ai-gateway/internal/chaos"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/governance"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// consensusResult is one target's outcome in a fan-out.
+type consensusResult struct {
+	index  int
+	target config.Target
+	resp   *providers.ChatResponse
+	err    error
+}
+
+// consensusAnswer is one target's part of an "all" response.
+type consensusAnswer struct {
+	Provider string                  `json:"provider"`
+	Model    string                  `json:"model"`
+	Response *providers.ChatResponse `json:"response,omitempty"`
+	Error    string                  `json:"error,omitempty"`
+}
+
+type consensusResponse struct {
+	ID        string            `json:"id"`
+	Object    string            `json:"object"`
+	Created   int64             `json:"created"`
+	Strategy  string            `json:"strategy"`
+	Responses []consensusAnswer `json:"responses"`
+	Usage     providers.Usage   `json:"usage"`
+}
+
+// handleConsensus sends the request to every target at once and answers
+// according to the route's strategy. Each call is logged as its own attempt
+// with its usage. It reports whether a response was written; if not, the
+// returned error is the last failure.
+func (h *Handler) handleConsensus(ctx context.Context, w http.ResponseWriter, req ChatRequest, messages []providers.Message, fit *contextFit, unmaskMap map[string]string, wordList *governance.WordList, moderator *governance.Moderator, route config.Route, requestID, tenant, useCase string, maxOutput int, start time.Time) (bool, error) {
+	targets := append([]config.Target{route.Primary}, route.Fallbacks...)
+	strategy := route.Consensus.Strategy
+
+	// With "first" the losers finish after the response has gone out, so
+	// their logging must not depend on the request context.
+	logCtx := context.WithoutCancel(ctx)
+	results := make(chan consensusResult, len(targets))
+	for i, target := range targets {
+		go func() {
+			results <- h.consensusAttempt(logCtx, req, fit, route, target, requestID, tenant, useCase, maxOutput, i+1)
+		}()
+	}
+
+	collected := make([]consensusResult, 0, len(targets))
+	var lastErr error
+	for range targets {
+		res := <-results
+		if res.err == nil {
+			res.err = h.screenCompletion(logCtx, w, res.resp, unmaskMap, wordList, moderator, route, requestID)
+		}
+		if res.err == nil && !showReasoning(req, route) {
+			stripReasoning(res.resp)
+		}
+		if res.err != nil {
+			lastErr = res.err
+		}
+		collected = append(collected, res)
+		if strategy == "first" && res.err == nil {
+			break
+		}
+	}
+
+	var winners []consensusResult
+	for _, res := range collected {
+		if res.err == nil {
+			winners = append(winners, res)
+		}
+	}
+	if len(winners) == 0 {
+		return false, lastErr
+	}
+
+	// The request is billed for every call that returned a completion,
+	// including those its guardrails withheld.
+	var prompt, completion int
+	var cost float64
+	bill := func(res consensusResult) {
+		if res.resp == nil {
+			return
+		}
+		prompt += res.resp.Usage.PromptTokens
+		completion += res.resp.Usage.CompletionTokens
+		cost += h.usage.Cost(logCtx, res.target.Model, res.resp.Usage.PromptTokens, res.resp.Usage.CompletionTokens)
+	}
+	for _, res := range collected {
+		bill(res)
+	}
+	latency := time.Since(start)
+
+	roles := promptRoles(req.providerRequest("", messages), prompt)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("x-request-id", requestID)
+	w.Header().Set("x-gw-route", route.Name)
+
+	switch strategy {
+	case "all":
+		out := consensusResponse{
+			ID: requestID, Object: "chat.completion.consensus", Created: time.Now().Unix(), Strategy: strategy,
+			Usage: providers.Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion},
+		}
+		for _, res := range sortByIndex(collected) {
+			a := consensusAnswer{Provider: res.target.Provider, Model: res.target.Model, Response: res.resp}
+			if res.err != nil {
+				a.Response, a.Error = nil, res.err.Error()
+			}
+			out.Responses = append(out.Responses, a)
+		}
+		h.logConsensus(logCtx, route, requestID, tenant, useCase, "consensus", "all", prompt, completion, roles, cost, latency)
+		w.Header().Set("x-gw-consensus", fmt.Sprintf("all %d/%d", len(winners), len(targets)))
+		json.NewEncoder(w).Encode(out)
+		h.capturePayload(logCtx, requestID, tenant, req.Messages, out)
+		return true, nil
+
+	case "majority":
+		chosen, votes := majority(sortByIndex(winners))
+		h.logConsensus(logCtx, route, requestID, tenant, useCase, chosen.target.Provider, chosen.target.Model, prompt, completion, roles, cost, latency)
+		w.Header().Set("x-gw-provider", chosen.target.Provider)
+		w.Header().Set("x-gw-model", chosen.target.Model)
+		setRegion(w, h.region(chosen.target.Provider))
+		w.Header().Set("x-gw-consensus", fmt.Sprintf("majority %d/%d", votes, len(winners)))
+		json.NewEncoder(w).Encode(chosen.resp)
+		h.capturePayload(logCtx, requestID, tenant, req.Messages, chosen.resp)
+		return true, nil
+
+	default: // "first"
+		chosen := winners[0]
+		h.logConsensus(logCtx, route, requestID, tenant, useCase, chosen.target.Provider, chosen.target.Model, prompt, completion, roles, cost, latency)
+		if pending := len(targets) - len(collected); pending > 0 {
+			// The slower calls run on and are billed by their providers;
+			// the request's record is logged again with their usage once
+			// they finish.
+			go func() {
+				for range pending {
+					bill(<-results)
+				}
+				roles := promptRoles(req.providerRequest("", messages), prompt)
+				h.logConsensus(logCtx, route, requestID, tenant, useCase, chosen.target.Provider, chosen.target.Model, prompt, completion, roles, cost, latency)
+			}()
+		}
+		w.Header().Set("x-gw-provider", chosen.target.Provider)
+		w.Header().Set("x-gw-model", chosen.target.Model)
+		setRegion(w, h.region(chosen.target.Provider))
+		w.Header().Set("x-gw-consensus", "first")
+		json.NewEncoder(w).Encode(chosen.resp)
+		h.capturePayload(logCtx, requestID, tenant, req.Messages, chosen.resp)
+		return true, nil
+	}
+}
+
+// consensusAttempt makes one target's call and logs it as an attempt.
+func (h *Handler) consensusAttempt(ctx context.Context, req ChatRequest, fit *contextFit, route config.Route, target config.Target, requestID, tenant, useCase string, maxOutput, attemptNo int) consensusResult {
+	tCtx, tSpan := h.tracer.Start(ctx, "ProviderAttempt", trace.WithAttributes(
+		attribute.String("provider", target.Provider),
+		attribute.String("model", target.Model),
+		attribute.Int("attempt_no", attemptNo),
+		attribute.Bool("consensus", true),
+	))
+	defer tSpan.End()
+
+	res := consensusResult{index: attemptNo, target: target}
+	if !h.health.Allow(tCtx, target.Provider) {
+		res.err = errCircuitOpen(target.Provider)
+		return res
+	}
+	provider, err := h.registry.Get(target.Provider)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	if route.Chaos != nil {
+		provider = chaos.Wrap(target.Provider, provider, *route.Chaos)
+	}
+
+	messages, err := fit.forTarget(tCtx, target)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	provReq, err := h.targetRequest(req, messages, route, target)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	capTargetOutput(&provReq, maxOutput)
+	provReq.Timeout = h.targetTimeout(tCtx, route, target, provReq.MaxTokens)
+	provReq.Headers = h.outboundHeaders(tCtx, req, target, headerData{Tenant: tenant, UseCase: useCase, Route: route.Name, RequestID: requestID, zeroRetention: route.ZeroRetention, transform: route.Transform})
+	var account config.ProviderAccount
+	provReq.Account, account = h.providerAccount(route, tenant, target)
+
+	release, err := h.acquireTarget(tCtx, target)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	attemptStart := time.Now()
+	res.resp, res.err = h.complete(provider, provReq, route, target)
+	release()
+	attempt := usage.Attempt{
+		RequestID: requestID, AttemptNo: attemptNo, Provider: target.Provider, Model: target.Model,
+		LatencyMS:    int(time.Since(attemptStart).Milliseconds()),
+		StatusCode:   getStatusCode(res.err, res.resp != nil),
+		ErrorMessage: getErrorMessage(res.err),
+		Organization: account.Organization,
+		Project:      account.Project,
+	}
+	if res.resp != nil {
+		attempt.PromptTokens = res.resp.Usage.PromptTokens
+		attempt.CompletionTokens = res.resp.Usage.CompletionTokens
+		if res.resp.TTFT > 0 {
+			attempt.TTFTMS = int(res.resp.TTFT.Milliseconds())
+			h.recordTTFT(tCtx, target, res.resp.TTFT)
+		}
+	}
+	h.usage.LogAttempt(tCtx, requestID, attempt)
+	h.alerts.Observe(alerting.Event{Kind: alerting.KindAttempt, Provider: target.Provider, Tenant: tenant, Failed: res.err != nil, Detail: getErrorMessage(res.err)})
+	h.canary.Observe(tCtx, time.Since(attemptStart), res.err != nil)
+	h.recordHealth(tCtx, target, time.Since(attemptStart), res.err)
+	if res.err != nil {
+		tSpan.RecordError(res.err)
+		tSpan.SetStatus(codes.Error, res.err.Error())
+	}
+	return res
+}
+
+// screenCompletion unmasks a completion and runs the output guardrails on
+// it. A completion that must be withheld is reported as an error.
+func (h *Handler) screenCompletion(ctx context.Context, w http.ResponseWriter, resp *providers.ChatResponse, unmaskMap map[string]string, wordList *governance.WordList, moderator *governance.Moderator, route config.Route, requestID string) error {
+	if unmaskMap != nil && h.detector != nil {
+		for i, choice := range resp.Choices {
+			resp.Choices[i].Message.Content = h.detector.Unmask(choice.Message.Content, unmaskMap)
+		}
+	}
+	if blocked := h.applyCompletionWordList(ctx, w, wordList, resp, requestID); blocked {
+		return errors.New("blocked: tenant word list")
+	}
+	if route.SecretScan != nil {
+		if found := h.scanCompletion(resp); len(found) > 0 {
+			h.logSecretLeak(ctx, requestID, found, route.SecretScan.Action)
+			if route.SecretScan.Action == "block" {
+				return errors.New("blocked: credential in output")
+			}
+			w.Header().Set("x-gw-redacted", "secrets")
+		}
+	}
+	if blocked := h.moderateCompletion(ctx, w, moderator, route, resp, requestID); blocked {
+		return errors.New("blocked: moderation")
+	}
+	return nil
+}
+
+func (h *Handler) logConsensus(ctx context.Context, route config.Route, requestID, tenant, useCase, provider, model string, prompt, completion int, roles usage.PromptRoles, cost float64, latency time.Duration) {
+	h.usage.Log(ctx, usage.Record{
+		RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name,
+		Provider: provider, Model: model, Region: h.region(provider),
+		PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion, CostEstimate: cost,
+		PromptRoles: roles,
+		LatencyMS:   int(latency.Milliseconds()), StatusCode: http.StatusOK,
+	})
+}
+
+// majority picks the most common answer among results, comparing content
+// loosely (case, surrounding whitespace and trailing punctuation). Ties go to
+// the earliest target. It returns the chosen result and its vote count.
+func majority(results []consensusResult) (consensusResult, int) {
+	votes := make(map[string]int)
+	for _, res := range results {
+		votes[normalizeAnswer(res.resp)]++
+	}
+	best, bestVotes := results[0], 0
+	for _, res := range results {
+		if n := votes[normalizeAnswer(res.resp)]; n > bestVotes {
+			best, bestVotes = res, n
+		}
+	}
+	return best, bestVotes
+}
+
+func normalizeAnswer(resp *providers.ChatResponse) string {
+	if len(resp.Choices) == 0 {
+		return ""
+	}
+	s := strings.ToLower(strings.TrimSpace(resp.Choices[0].Message.Content))
+	return strings.TrimRight(s, ".!?\"' ")
+}
+
+// sortByIndex orders results by target position in the route.
+func sortByIndex(results []consensusResult) []consensusResult {
+	sorted := slices.Clone(results)
+	slices.SortFunc(sorted, func(a, b consensusResult) int { return a.index - b.index })
+	return sorted
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func answer(index int, provider, content string) consensusResult {
+	resp := &providers.ChatResponse{}
+	resp.Choices = make([]struct {
+		Index        int               `json:"index"`
+		Message      providers.Message `json:"message"`
+		FinishReason string            `json:"finish_reason"`
+	}, 1)
+	resp.Choices[0].Message.Content = content
+	return consensusResult{index: index, target: config.Target{Provider: provider}, resp: resp}
+}
+
+func TestMajority(t *testing.T) {
+	chosen, votes := majority([]consensusResult{
+		answer(1, "openai", "Positive"),
+		answer(2, "anthropic", "negative."),
+		answer(3, "mistral", " Negative "),
+	})
+	if chosen.target.Provider != "anthropic" || votes != 2 {
+		t.Errorf("expected anthropic with 2 votes, got %s with %d", chosen.target.Provider, votes)
+	}
+
+	// A tie goes to the earliest target.
+	chosen, votes = majority([]consensusResult{answer(1, "openai", "yes"), answer(2, "anthropic", "no")})
+	if chosen.target.Provider != "openai" || votes != 1 {
+		t.Errorf("expected openai to win the tie, got %s", chosen.target.Provider)
+	}
+}
+
+func TestSortByIndex(t *testing.T) {
+	sorted := sortByIndex([]consensusResult{answer(3, "c", ""), answer(1, "a", ""), answer(2, "b", "")})
+	for i, res := range sorted {
+		if res.index != i+1 {
+			t.Fatalf("expected results in target order, got %+v", sorted)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// defaultContinueInstruction asks the model to pick up a cut-off reply.
+const defaultContinueInstruction = "Continue exactly where your previous reply stopped. Do not repeat any of it or add commentary."
+
+// continuation is what continuing a completion did, recorded as a
+// continuation event. Stopped says why a completion still cut off was not
+// continued: "limit", "cost_cap" or "output_budget".
+type continuation struct {
+	Continuations int
+	CostUSD       float64
+	FinishReason  string
+	Stopped       string
+}
+
+func (c continuation) detail() map[string]interface{} {
+	d := map[string]interface{}{
+		"continuations": c.Continuations,
+		"cost_usd":      c.CostUSD,
+		"finish_reason": c.FinishReason,
+	}
+	if c.Stopped != "" {
+		d["stopped"] = c.Stopped
+	}
+	return d
+}
+
+// continuationRequest asks for the rest of a reply cut off after content.
+func continuationRequest(req providers.ChatRequest, content string, cfg config.Continuation) providers.ChatRequest {
+	instruction := cfg.Instruction
+	if instruction == "" {
+		instruction = defaultContinueInstruction
+	}
+	next := req
+	next.Messages = append(append([]providers.Message{}, req.Messages...),
+		providers.Message{Role: "assistant", Content: content},
+		providers.Message{Role: "user", Content: instruction})
+	return next
+}
+
+// stopContinuing returns why a completion cut off after n continuations,
+// costing cost with completion tokens so far, is not continued, or "".
+func stopContinuing(cfg config.Continuation, n int, cost float64, completion, maxOutput int) string {
+	switch {
+	case n >= cfg.Limit():
+		return "limit"
+	case cost >= cfg.MaxCostUSD:
+		return "cost_cap"
+	case maxOutput > 0 && completion >= maxOutput:
+		return "output_budget"
+	}
+	return ""
+}
+
+func cutOff(resp *providers.ChatResponse) bool {
+	return len(resp.Choices) > 0 && len(resp.Choices[0].Message.ToolCalls) == 0 &&
+		providers.NormalizeFinishReason(resp.Choices[0].FinishReason) == providers.FinishLength
+}
+
+// continueCompletion continues a completion cut off at max_tokens with call
+// until it finishes or a limit of cfg is reached, and returns the stitched
+// completion with the usage of every call. cost prices a call's tokens;
+// maxOutput is the request's output budget, never exceeded by continuing.
+func continueCompletion(call func(providers.ChatRequest) (*providers.ChatResponse, error), req providers.ChatRequest, resp *providers.ChatResponse, cfg config.Continuation, maxOutput int, cost func(prompt, completion int) float64) (*providers.ChatResponse, continuation) {
+	var c continuation
+	if !cutOff(resp) {
+		return resp, c
+	}
+	c.CostUSD = cost(resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	spent := resp.Usage
+	content := resp.Choices[0].Message.Content
+	for cutOff(resp) {
+		if c.Stopped = stopContinuing(cfg, c.Continuations, c.CostUSD, spent.CompletionTokens, maxOutput); c.Stopped != "" {
+			break
+		}
+		next, err := call(continuationRequest(req, content, cfg))
+		if err != nil || len(next.Choices) == 0 {
+			break
+		}
+		c.Continuations++
+		c.CostUSD += cost(next.Usage.PromptTokens, next.Usage.CompletionTokens)
+		spent.PromptTokens += next.Usage.PromptTokens
+		spent.CompletionTokens += next.Usage.CompletionTokens
+		spent.TotalTokens += next.Usage.TotalTokens
+		content += next.Choices[0].Message.Content
+		resp = next
+	}
+	if c.Continuations > 0 {
+		resp.Choices[0].Message.Content = content
+		resp.Usage = spent
+	}
+	c.FinishReason = resp.Choices[0].FinishReason
+	return resp, c
+}
+
+// continueStream streams a completion like p.ChatStream, but continues it
+// while it is cut off at max_tokens and cfg allows, in the same stream:
+// the finish chunk of a continued part goes out without its finish reason,
+// and the next part follows. Token counts are estimated, as for other
+// streams. done is called with what was continued once the stream ends.
+func continueStream(ctx context.Context, p providers.Provider, req providers.ChatRequest, cfg config.Continuation, maxOutput int, cost func(prompt, completion int) float64, done func(continuation)) (<-chan providers.ChatChunk, <-chan error) {
+	out := make(chan providers.ChatChunk)
+	errCh := make(chan error, 1)
+	send := func(chunk providers.ChatChunk) bool {
+		select {
+		case out <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		defer close(out)
+		var c continuation
+		content := ""
+		segReq := req
+		for {
+			chunks, errs := p.ChatStream(segReq)
+			segment, reason := "", ""
+			var finish *providers.ChatChunk // a length finish, held back
+		read:
+			for {
+				select {
+				case chunk, ok := <-chunks:
+					if !ok {
+						select {
+						case err := <-errs:
+							if err != nil {
+								errCh <- err
+								return
+							}
+						default:
+						}
+						break read
+					}
+					if len(chunk.Choices) > 0 {
+						segment += chunk.Choices[0].Delta.Content
+						if r := chunk.Choices[0].FinishReason; r != "" {
+							reason = providers.NormalizeFinishReason(r)
+							if reason == providers.FinishLength {
+								finish = &chunk
+								continue
+							}
+						}
+					}
+					if !send(chunk) {
+						go func() {
+							for range chunks {
+							}
+						}()
+						return
+					}
+				case err := <-errs:
+					if err != nil {
+						errCh <- err
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			content += segment
+			c.CostUSD += cost(usage.ApproximateTokens(fmt.Sprintf("%v", segReq.Messages)), usage.ApproximateTokens(segment))
+			if finish == nil {
+				c.FinishReason = reason
+				break
+			}
+			c.FinishReason = providers.FinishLength
+			if c.Stopped = stopContinuing(cfg, c.Continuations, c.CostUSD, usage.ApproximateTokens(content), maxOutput); c.Stopped != "" {
+				send(*finish)
+				break
+			}
+			finish.Choices[0].FinishReason = ""
+			if finish.Choices[0].Delta.Content != "" && !send(*finish) {
+				return
+			}
+			c.Continuations++
+			segReq = continuationRequest(req, content, cfg)
+		}
+		if done != nil {
+			done(c)
+		}
+	}()
+	return out, errCh
+}
+
+// streamContinued is continueStream for a call to target on route, with
+// the continuation recorded as an event of the request.
+func (h *Handler) streamContinued(ctx context.Context, p providers.Provider, req providers.ChatRequest, route config.Route, target config.Target, maxOutput int, requestID string) (<-chan providers.ChatChunk, <-chan error) {
+	cost := func(prompt, completion int) float64 { return h.usage.Cost(ctx, target.Model, prompt, completion) }
+	return continueStream(ctx, p, req, *route.Continuation, maxOutput, cost, func(c continuation) {
+		if c.Continuations > 0 || c.Stopped != "" {
+			h.usage.LogEvent(context.WithoutCancel(ctx), requestID, usage.Event{Kind: "continuation", Detail: c.detail()})
+		}
+	})
+}
+
+// completeContinued continues a completion of target on route when it was
+// cut off, and records the continuation as an event of the request.
+func (h *Handler) completeContinued(ctx context.Context, p providers.Provider, req providers.ChatRequest, resp *providers.ChatResponse, route config.Route, target config.Target, maxOutput int, requestID string) *providers.ChatResponse {
+	call := func(next providers.ChatRequest) (*providers.ChatResponse, error) {
+		return h.complete(p, next, route, target)
+	}
+	cost := func(prompt, completion int) float64 { return h.usage.Cost(ctx, target.Model, prompt, completion) }
+	resp, c := continueCompletion(call, req, resp, *route.Continuation, maxOutput, cost)
+	if c.Continuations > 0 || c.Stopped != "" {
+		h.usage.LogEvent(ctx, requestID, usage.Event{Kind: "continuation", Detail: c.detail()})
+	}
+	return resp
+}
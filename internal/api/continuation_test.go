@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// partsProvider answers each call with its next part, cut off at max_tokens
+// unless it is the last.
+type partsProvider struct {
+	parts []string
+	calls []providers.ChatRequest
+}
+
+func (p *partsProvider) next(req providers.ChatRequest) (string, string) {
+	n := len(p.calls)
+	p.calls = append(p.calls, req)
+	if n == len(p.parts)-1 {
+		return p.parts[n], "stop"
+	}
+	return p.parts[n], "length"
+}
+
+func (p *partsProvider) Chat(req providers.ChatRequest) (*providers.ChatResponse, error) {
+	if len(p.calls) == len(p.parts) {
+		return nil, errors.New("no more parts")
+	}
+	content, reason := p.next(req)
+	resp := completion(content)
+	resp.Choices[0].FinishReason = reason
+	resp.Usage = providers.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	return resp, nil
+}
+
+func (p *partsProvider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatChunk, <-chan error) {
+	content, reason := p.next(req)
+	chunks := make(chan providers.ChatChunk, 2)
+	chunks <- providers.ChatChunk{Choices: []providers.ChunkChoice{{Delta: providers.ChunkDelta{Content: content}}}}
+	chunks <- providers.ChatChunk{Choices: []providers.ChunkChoice{{FinishReason: reason}}}
+	close(chunks)
+	return chunks, make(chan error, 1)
+}
+
+func TestContinueCompletion(t *testing.T) {
+	p := &partsProvider{parts: []string{"The quick ", "brown fox ", "jumps."}}
+	req := providers.ChatRequest{Messages: []providers.Message{{Role: "user", Content: "Tell me."}}}
+	first, _ := p.Chat(req)
+	cost := func(prompt, completion int) float64 { return 0.01 }
+
+	resp, c := continueCompletion(p.Chat, req, first, config.Continuation{MaxCostUSD: 1}, 0, cost)
+	if got := resp.Choices[0].Message.Content; got != "The quick brown fox jumps." {
+		t.Errorf("expected the parts stitched, got %q", got)
+	}
+	if resp.Usage.CompletionTokens != 15 || c.Continuations != 2 || c.FinishReason != "stop" || c.Stopped != "" {
+		t.Errorf("unexpected usage %+v or continuation %+v", resp.Usage, c)
+	}
+	last := p.calls[2].Messages
+	if len(last) != 3 || last[1].Role != "assistant" || last[1].Content != "The quick brown fox " || last[2].Content != defaultContinueInstruction {
+		t.Errorf("unexpected continuation request %+v", last)
+	}
+
+	// The cost cap stops continuing, leaving the completion cut off.
+	p = &partsProvider{parts: []string{"a", "b", "c"}}
+	first, _ = p.Chat(req)
+	resp, c = continueCompletion(p.Chat, req, first, config.Continuation{MaxCostUSD: 0.02}, 0, cost)
+	if resp.Choices[0].Message.Content != "ab" || c.Stopped != "cost_cap" || c.FinishReason != "length" {
+		t.Errorf("expected to stop at the cost cap, got %q and %+v", resp.Choices[0].Message.Content, c)
+	}
+
+	// So does the output budget.
+	p = &partsProvider{parts: []string{"a", "b"}}
+	first, _ = p.Chat(req)
+	if _, c = continueCompletion(p.Chat, req, first, config.Continuation{MaxCostUSD: 1}, 5, cost); c.Stopped != "output_budget" || c.Continuations != 0 {
+		t.Errorf("expected to stop at the output budget, got %+v", c)
+	}
+}
+
+func TestContinueStream(t *testing.T) {
+	p := &partsProvider{parts: []string{"one ", "two ", "three"}}
+	var got continuation
+	chunks, errs := continueStream(context.Background(), p, providers.ChatRequest{}, config.Continuation{MaxContinuations: 1, MaxCostUSD: 1},
+		0, func(int, int) float64 { return 0 }, func(c continuation) { got = c })
+
+	content, finishes := "", []string{}
+	for chunk := range chunks {
+		content += chunk.Choices[0].Delta.Content
+		if r := chunk.Choices[0].FinishReason; r != "" {
+			finishes = append(finishes, r)
+		}
+	}
+	select {
+	case err := <-errs:
+		t.Fatalf("unexpected error %v", err)
+	default:
+	}
+	if content != "one two " || len(finishes) != 1 || finishes[0] != "length" {
+		t.Errorf("expected one continuation ending cut off, got %q with finishes %v", content, finishes)
+	}
+	if got.Continuations != 1 || got.Stopped != "limit" {
+		t.Errorf("unexpected continuation %+v", got)
+	}
+}
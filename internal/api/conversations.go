@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// maxConversationIDLen bounds the conversation ids clients may send.
+const maxConversationIDLen = 256
+
+// conversationID is the conversation a request belongs to: the
+// conversation_id metadata key, or the X-GW-Conversation-ID header.
+func conversationID(r *http.Request, meta map[string]interface{}) (string, error) {
+	id := r.Header.Get("X-GW-Conversation-ID")
+	if v, ok := meta["conversation_id"]; ok && v != nil {
+		s, ok := v.(string)
+		if !ok {
+			return "", errors.New("metadata.conversation_id must be a string")
+		}
+		id = s
+	}
+	if len(id) > maxConversationIDLen {
+		return "", fmt.Errorf("conversation id is longer than %d characters", maxConversationIDLen)
+	}
+	return id, nil
+}
+
+func parseConversationQuery(r *http.Request) (usage.ConversationQuery, error) {
+	uq, err := parseUsageQuery(r)
+	if err != nil {
+		return usage.ConversationQuery{}, err
+	}
+	q := usage.ConversationQuery{Tenant: uq.Tenant, From: uq.From, To: uq.To, Metadata: uq.Metadata, Limit: 100}
+	if s := r.URL.Query().Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 1 || q.Limit > 1000 {
+			return q, errors.New("limit must be between 1 and 1000")
+		}
+	}
+	return q, nil
+}
+
+// HandleListConversations serves GET /v1/usage/conversations: usage per
+// conversation, most recently active first, with totals and averages over
+// the conversations selected by tenant, from, to and meta.<key>.
+func (h *Handler) HandleListConversations(w http.ResponseWriter, r *http.Request) {
+	q, err := parseConversationQuery(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+	h.listConversations(w, r, q)
+}
+
+// HandleMyConversations is GET /v1/usage/conversations restricted to the
+// caller's tenant.
+func (h *Handler) HandleMyConversations(w http.ResponseWriter, r *http.Request) {
+	q, err := parseConversationQuery(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+	q.Tenant = requestTenant(r)
+	h.listConversations(w, r, q)
+}
+
+func (h *Handler) listConversations(w http.ResponseWriter, r *http.Request, q usage.ConversationQuery) {
+	list, totals, err := h.usage.ListConversations(r.Context(), q)
+	if err != nil {
+		logError("", "failed to query conversations", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to query conversations", "")
+		return
+	}
+	if list == nil {
+		list = []usage.ConversationSummary{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"totals":        totals,
+		"conversations": list,
+	})
+}
+
+// HandleGetConversation serves GET /v1/usage/conversations/{id}?tenant=:
+// a conversation's usage and its requests.
+func (h *Handler) HandleGetConversation(w http.ResponseWriter, r *http.Request) {
+	tenant := r.URL.Query().Get("tenant")
+	if tenant == "" {
+		h.respondError(w, http.StatusBadRequest, "tenant is required", "")
+		return
+	}
+	h.getConversation(w, r, tenant)
+}
+
+// HandleMyConversation is GET /v1/usage/conversations/{id} for the
+// caller's tenant.
+func (h *Handler) HandleMyConversation(w http.ResponseWriter, r *http.Request) {
+	h.getConversation(w, r, requestTenant(r))
+}
+
+func (h *Handler) getConversation(w http.ResponseWriter, r *http.Request, tenant string) {
+	id := chi.URLParam(r, "id")
+	c, err := h.usage.GetConversation(r.Context(), tenant, id)
+	if errors.Is(err, usage.ErrConversationNotFound) {
+		h.respondError(w, http.StatusNotFound, "conversation not found", "")
+		return
+	}
+	if err != nil {
+		logError("", "failed to load conversation", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to load conversation", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
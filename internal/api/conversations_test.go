@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConversationID(t *testing.T) {
+	r := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	if id, err := conversationID(r, nil); id != "" || err != nil {
+		t.Errorf("expected no conversation, got %q, %v", id, err)
+	}
+
+	r.Header.Set("X-GW-Conversation-ID", "from-header")
+	if id, _ := conversationID(r, map[string]interface{}{"tenant": "acme"}); id != "from-header" {
+		t.Errorf("expected the header's conversation, got %q", id)
+	}
+	if id, _ := conversationID(r, map[string]interface{}{"conversation_id": "from-metadata"}); id != "from-metadata" {
+		t.Errorf("expected metadata to take precedence, got %q", id)
+	}
+
+	if _, err := conversationID(r, map[string]interface{}{"conversation_id": 42.0}); err == nil {
+		t.Error("expected a non-string conversation_id refused")
+	}
+	if _, err := conversationID(r, map[string]interface{}{"conversation_id": strings.Repeat("x", maxConversationIDLen+1)}); err == nil {
+		t.Error("expected an overlong conversation id refused")
+	}
+}
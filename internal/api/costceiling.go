@@ -0,0 +1,97 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// costCeiling returns the tighter of the client's and the route's
+// max_cost_usd, or 0 when neither sets one.
+func costCeiling(req ChatRequest, route config.Route) float64 {
+	ceiling := route.MaxCostUSD
+	if req.MaxCostUSD > 0 && (ceiling == 0 || req.MaxCostUSD < ceiling) {
+		ceiling = req.MaxCostUSD
+	}
+	return ceiling
+}
+
+// planCost fits route's targets under a cost ceiling. Targets whose prompt
+// alone would exceed it are dropped, the rest get max_tokens lowered so the
+// worst-case cost stays under it, and they are ordered cheapest first for
+// the tokens asked for.
+// requested is the client's max_tokens, 0 if unset.
+func planCost(route config.Route, pricing func(model string) usage.Pricing, promptTokens, requested int, ceiling float64) (config.Route, error) {
+	type planned struct {
+		target config.Target
+		cost   float64 // at the uncapped max_tokens, for ranking
+		rate   float64
+	}
+	var plan []planned
+	for _, t := range append([]config.Target{route.Primary}, route.Fallbacks...) {
+		p := pricing(t.Model)
+		promptCost := float64(promptTokens) / 1e6 * p.InputRate1M
+		if promptCost >= ceiling {
+			continue
+		}
+
+		maxTokens := requested
+		if v, ok := t.Params["max_tokens"]; ok {
+			if n, ok := toInt(v); ok {
+				maxTokens = n
+			}
+		}
+		cost := promptCost + float64(maxTokens)/1e6*p.OutputRate1M
+		if p.OutputRate1M > 0 {
+			affordable := int(math.Floor((ceiling - promptCost) * 1e6 / p.OutputRate1M))
+			if affordable < 1 {
+				continue
+			}
+			if maxTokens == 0 || maxTokens > affordable {
+				maxTokens = affordable
+			}
+		}
+
+		if maxTokens > 0 {
+			params := make(map[string]interface{}, len(t.Params)+1)
+			for k, v := range t.Params {
+				params[k] = v
+			}
+			params["max_tokens"] = maxTokens
+			t.Params = params
+		}
+		plan = append(plan, planned{t, cost, p.OutputRate1M})
+	}
+	if len(plan) == 0 {
+		return route, fmt.Errorf("no target can serve this request within max_cost_usd %g", ceiling)
+	}
+
+	sort.SliceStable(plan, func(i, j int) bool {
+		if plan[i].cost != plan[j].cost {
+			return plan[i].cost < plan[j].cost
+		}
+		return plan[i].rate < plan[j].rate
+	})
+	route.Primary = plan[0].target
+	route.Fallbacks = nil
+	for _, p := range plan[1:] {
+		route.Fallbacks = append(route.Fallbacks, p.target)
+	}
+	return route, nil
+}
+
+// toInt reads a whole number from a config or JSON value.
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), n == math.Trunc(n)
+	}
+	return 0, false
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+var testPricing = map[string]usage.Pricing{
+	"gpt-4o":            {InputRate1M: 5, OutputRate1M: 15},
+	"gpt-4o-mini":       {InputRate1M: 0.15, OutputRate1M: 0.60},
+	"claude-3-5-sonnet": {InputRate1M: 3, OutputRate1M: 15},
+}
+
+func pricingFor(model string) usage.Pricing { return testPricing[model] }
+
+func TestPlanCost(t *testing.T) {
+	route := config.Route{
+		Primary: config.Target{Provider: "openai", Model: "gpt-4o"},
+		Fallbacks: []config.Target{
+			{Provider: "anthropic", Model: "claude-3-5-sonnet", Params: map[string]interface{}{"thinking": true}},
+			{Provider: "openai", Model: "gpt-4o-mini"},
+		},
+	}
+
+	// 1000 prompt tokens, $0.01 ceiling: gpt-4o affords 333 output tokens,
+	// sonnet 466, mini all 500 requested.
+	got, err := planCost(route, pricingFor, 1000, 500, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := []string{got.Primary.Model, got.Fallbacks[0].Model, got.Fallbacks[1].Model}
+	if order[0] != "gpt-4o-mini" || order[1] != "claude-3-5-sonnet" || order[2] != "gpt-4o" {
+		t.Fatalf("expected cheapest first, got %v", order)
+	}
+	if got.Primary.Params["max_tokens"] != 500 {
+		t.Errorf("expected the client's max_tokens to fit, got %v", got.Primary.Params["max_tokens"])
+	}
+	if got.Fallbacks[0].Params["max_tokens"] != 466 || got.Fallbacks[0].Params["thinking"] != true {
+		t.Errorf("expected sonnet capped to 466 keeping its params, got %v", got.Fallbacks[0].Params)
+	}
+	if got.Fallbacks[1].Params["max_tokens"] != 333 {
+		t.Errorf("expected gpt-4o capped to 333, got %v", got.Fallbacks[1].Params["max_tokens"])
+	}
+	if _, ok := route.Fallbacks[0].Params["max_tokens"]; ok || len(route.Fallbacks[0].Params) != 1 {
+		t.Error("expected the configured route to be left alone")
+	}
+
+	// A prompt costing more than the ceiling on gpt-4o drops it.
+	got, err = planCost(route, pricingFor, 2000, 0, 0.009)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range append([]config.Target{got.Primary}, got.Fallbacks...) {
+		if target.Model == "gpt-4o" {
+			t.Error("expected gpt-4o to be dropped")
+		}
+	}
+
+	if _, err := planCost(route, pricingFor, 100000, 0, 0.001); err == nil {
+		t.Error("expected rejection when no target fits")
+	}
+}
+
+func TestCostCeiling(t *testing.T) {
+	route := config.Route{MaxCostUSD: 0.05}
+	if got := costCeiling(ChatRequest{}, route); got != 0.05 {
+		t.Errorf("expected route ceiling, got %v", got)
+	}
+	if got := costCeiling(ChatRequest{MaxCostUSD: 0.01}, route); got != 0.01 {
+		t.Errorf("expected the tighter client ceiling, got %v", got)
+	}
+	if got := costCeiling(ChatRequest{MaxCostUSD: 1}, route); got != 0.05 {
+		t.Errorf("expected the client not to lift the route ceiling, got %v", got)
+	}
+}
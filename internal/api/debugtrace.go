@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/health"
+)
+
+// RoutingTrace explains where a request went and why. Requests sent with
+// an X-GW-Debug header get it in their completion's gateway_debug field.
+type RoutingTrace struct {
+	UseCase   string `json:"use_case"`
+	Route     string `json:"route"`
+	MatchedBy string `json:"matched_by"`
+	Canary    string `json:"canary_version,omitempty"`
+	Category  string `json:"category,omitempty"`
+	// Filters are the steps that removed or reordered targets, in order.
+	Filters []RoutingFilter `json:"filters,omitempty"`
+	// Candidates are the targets left, in the order they were tried, with
+	// their circuit breaker state when the request was routed.
+	Candidates []RoutingCandidate `json:"candidates"`
+	Attempts   []RoutingAttempt   `json:"attempts,omitempty"`
+	Cache      string             `json:"cache,omitempty"`
+}
+
+// RoutingFilter is one routing step that changed the targets.
+type RoutingFilter struct {
+	Name      string   `json:"name"`
+	Removed   []string `json:"removed,omitempty"` // provider/model
+	Added     []string `json:"added,omitempty"`
+	Reordered bool     `json:"reordered,omitempty"`
+}
+
+// RoutingCandidate is a target the request could be sent to.
+type RoutingCandidate struct {
+	Provider     string   `json:"provider"`
+	Model        string   `json:"model"`
+	Circuit      string   `json:"circuit"`
+	QueueWaiting *float64 `json:"queue_waiting,omitempty"`
+}
+
+// RoutingAttempt is a call to a target, or a target skipped; Error is
+// empty for the one that answered.
+type RoutingAttempt struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Error    string `json:"error,omitempty"`
+}
+
+// debugRequested reports whether r asks for a routing trace.
+func debugRequested(r *http.Request) bool {
+	v := strings.ToLower(strings.TrimSpace(r.Header.Get("X-GW-Debug")))
+	return v != "" && v != "0" && v != "false"
+}
+
+func targetName(t config.Target) string {
+	return t.Provider + "/" + t.Model
+}
+
+func routeTargets(route config.Route) []config.Target {
+	return append([]config.Target{route.Primary}, route.Fallbacks...)
+}
+
+// filter records the step name if it changed before into after.
+func (rt *RoutingTrace) filter(name string, before, after []config.Target) {
+	if rt == nil {
+		return
+	}
+	names := func(ts []config.Target) []string {
+		out := make([]string, len(ts))
+		for i, t := range ts {
+			out[i] = targetName(t)
+		}
+		return out
+	}
+	b, a := names(before), names(after)
+	if slices.Equal(b, a) {
+		return
+	}
+	f := RoutingFilter{Name: name}
+	for _, n := range b {
+		if !slices.Contains(a, n) {
+			f.Removed = append(f.Removed, n)
+		}
+	}
+	for _, n := range a {
+		if !slices.Contains(b, n) {
+			f.Added = append(f.Added, n)
+		}
+	}
+	f.Reordered = f.Removed == nil && f.Added == nil
+	rt.Filters = append(rt.Filters, f)
+}
+
+// candidates records targets with their providers' breaker states.
+func (rt *RoutingTrace) candidates(ctx context.Context, h *Handler, targets []config.Target) {
+	if rt == nil {
+		return
+	}
+	states, err := h.health.Snapshot(ctx)
+	if err != nil {
+		logError("", "failed to read provider health for a routing trace", err)
+	}
+	rt.Candidates = make([]RoutingCandidate, 0, len(targets))
+	for _, t := range targets {
+		c := RoutingCandidate{Provider: t.Provider, Model: t.Model, Circuit: "unknown"}
+		if s, ok := states[t.Provider]; ok {
+			c.Circuit, c.QueueWaiting = s.Circuit, s.QueueWaiting
+		} else if err == nil {
+			c.Circuit = health.Closed // not called yet
+		}
+		rt.Candidates = append(rt.Candidates, c)
+	}
+}
+
+// attempt records a call to target, or target being skipped, with err.
+func (rt *RoutingTrace) attempt(target config.Target, err error) {
+	if rt == nil {
+		return
+	}
+	a := RoutingAttempt{Provider: target.Provider, Model: target.Model}
+	if err != nil {
+		a.Error = err.Error()
+	}
+	rt.Attempts = append(rt.Attempts, a)
+}
+
+// traced returns a completion body with rt added as its gateway_debug
+// field, or body itself without a trace.
+func traced(body interface{}, rt *RoutingTrace) interface{} {
+	if rt == nil {
+		return body
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return body
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return body
+	}
+	if fields["gateway_debug"], err = json.Marshal(rt); err != nil {
+		return body
+	}
+	return fields
+}
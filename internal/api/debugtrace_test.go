@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestRoutingTrace(t *testing.T) {
+	r := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	if debugRequested(r) {
+		t.Error("expected no trace without the header")
+	}
+	r.Header.Set("X-GW-Debug", "true")
+	if !debugRequested(r) {
+		t.Error("expected a trace with the header")
+	}
+
+	a := config.Target{Provider: "openai", Model: "gpt-4o"}
+	b := config.Target{Provider: "anthropic", Model: "claude-3-5-sonnet"}
+	c := config.Target{Provider: "vllm", Model: "llama"}
+	rt := &RoutingTrace{}
+	rt.filter("unchanged", []config.Target{a, b}, []config.Target{a, b})
+	rt.filter("target_policy", []config.Target{a, b, c}, []config.Target{a, c})
+	rt.filter("queue_depth", []config.Target{a, c}, []config.Target{c, a})
+	rt.filter("classifier", []config.Target{a}, []config.Target{c})
+	want := []RoutingFilter{
+		{Name: "target_policy", Removed: []string{"anthropic/claude-3-5-sonnet"}},
+		{Name: "queue_depth", Reordered: true},
+		{Name: "classifier", Removed: []string{"openai/gpt-4o"}, Added: []string{"vllm/llama"}},
+	}
+	if !reflect.DeepEqual(rt.Filters, want) {
+		t.Errorf("got filters %+v, want %+v", rt.Filters, want)
+	}
+
+	h := &Handler{}
+	rt.candidates(context.Background(), h, []config.Target{c, a})
+	if len(rt.Candidates) != 2 || rt.Candidates[0].Provider != "vllm" || rt.Candidates[0].Circuit != "closed" {
+		t.Errorf("unexpected candidates %+v", rt.Candidates)
+	}
+	rt.attempt(c, errors.New("circuit open"))
+	rt.attempt(a, nil)
+	if len(rt.Attempts) != 2 || rt.Attempts[0].Error == "" || rt.Attempts[1].Error != "" {
+		t.Errorf("unexpected attempts %+v", rt.Attempts)
+	}
+
+	resp := &providers.ChatResponse{ID: "chatcmpl-1", Model: "gpt-4o"}
+	body, _ := json.Marshal(traced(degradedCompletion{resp, true}, rt))
+	var got struct {
+		ID       string        `json:"id"`
+		Degraded bool          `json:"degraded"`
+		Debug    *RoutingTrace `json:"gateway_debug"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "chatcmpl-1" || !got.Degraded || got.Debug == nil || len(got.Debug.Attempts) != 2 {
+		t.Errorf("unexpected traced body %s", body)
+	}
+
+	var nilTrace *RoutingTrace
+	nilTrace.filter("x", []config.Target{a}, nil)
+	nilTrace.attempt(a, nil)
+	if traced(resp, nilTrace) != interface{}(resp) {
+		t.Error("expected the body unchanged without a trace")
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// degradedCompletion and degradedChunk carry a "degraded" flag alongside the
+// usual body so clients can tell fallback content from a real completion.
+type degradedCompletion struct {
+	*providers.ChatResponse
+	Degraded bool `json:"degraded"`
+}
+
+type degradedChunk struct {
+	providers.ChatChunk
+	Degraded bool `json:"degraded"`
+}
+
+// degradedResponse renders the route's fallback content as a completion.
+func degradedResponse(cfg config.FallbackResponse, route, requestID string, cause error) (*providers.ChatResponse, error) {
+	tmpl, err := template.New("fallback").Parse(cfg.Content)
+	if err != nil {
+		return nil, err
+	}
+	var content strings.Builder
+	err = tmpl.Execute(&content, map[string]string{"Route": route, "RequestID": requestID, "Error": cause.Error()})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &providers.ChatResponse{
+		ID:      "degraded-" + requestID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   "gateway-fallback",
+	}
+	resp.Choices = make([]struct {
+		Index        int               `json:"index"`
+		Message      providers.Message `json:"message"`
+		FinishReason string            `json:"finish_reason"`
+	}, 1)
+	resp.Choices[0].Message = providers.Message{Role: "assistant", Content: content.String()}
+	resp.Choices[0].FinishReason = "stop"
+	return resp, nil
+}
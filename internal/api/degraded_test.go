@@ -0,0 +1,30 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func TestDegradedResponse(t *testing.T) {
+	cfg := config.FallbackResponse{Content: "Assistant unavailable on {{.Route}}, please retry (ref {{.RequestID}})."}
+	resp, err := degradedResponse(cfg, "support", "req-1", errors.New("openai error (status 503)"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := resp.Choices[0].Message.Content; got != "Assistant unavailable on support, please retry (ref req-1)." {
+		t.Errorf("unexpected content %q", got)
+	}
+
+	body, _ := json.Marshal(degradedCompletion{resp, true})
+	if !strings.Contains(string(body), `"degraded":true`) || !strings.Contains(string(body), `"finish_reason":"stop"`) {
+		t.Errorf("expected flagged completion body, got %s", body)
+	}
+
+	if _, err := degradedResponse(config.FallbackResponse{Content: "{{.Bad"}, "r", "id", errors.New("x")); err == nil {
+		t.Errorf("expected a template error")
+	}
+}
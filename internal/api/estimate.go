@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// Estimate is what a chat request would be routed to and could cost,
+// worked out without calling a provider.
+type Estimate struct {
+	Route    string `json:"route"`
+	Category string `json:"category,omitempty"`
+	// Provider and Model are the target tried first.
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// PromptTokens counts the messages with the route's system prompt.
+	PromptTokens int `json:"prompt_tokens"`
+	// MaxTokens is the completion length the first target is held to; 0
+	// when nothing bounds it.
+	MaxTokens int `json:"max_tokens,omitempty"`
+	// MaxCostLowUSD and MaxCostHighUSD bound the worst-case cost over the
+	// targets the request may end up on; the high end is left out when a
+	// target's completion length is unbounded.
+	MaxCostLowUSD  float64          `json:"max_cost_low_usd"`
+	MaxCostHighUSD *float64         `json:"max_cost_high_usd,omitempty"`
+	Targets        []TargetEstimate `json:"targets"`
+	RateLimit      RateLimitImpact  `json:"rate_limit"`
+}
+
+// TargetEstimate is one target in the order it would be tried.
+type TargetEstimate struct {
+	Provider      string   `json:"provider"`
+	Model         string   `json:"model"`
+	MaxTokens     int      `json:"max_tokens,omitempty"`
+	PromptCostUSD float64  `json:"prompt_cost_usd"`
+	MaxCostUSD    *float64 `json:"max_cost_usd,omitempty"` // nil when unbounded
+}
+
+// RateLimitImpact is what the request would take from the caller's
+// tokens-per-minute window, and whether it would fit now.
+type RateLimitImpact struct {
+	Tokens       int  `json:"tokens"`
+	Limit        int  `json:"limit,omitempty"`
+	Remaining    int  `json:"remaining"`
+	ResetSeconds int  `json:"reset_seconds,omitempty"`
+	Exempt       bool `json:"exempt,omitempty"`
+	// Allowed is false when the request would be refused with a 429;
+	// Burst means it would only be let through on burst credit.
+	Allowed     bool `json:"allowed"`
+	Burst       bool `json:"burst,omitempty"`
+	BurstCredit *int `json:"burst_credit,omitempty"`
+}
+
+// HandleEstimate takes a chat completion request and reports the route,
+// targets, prompt tokens, cost range and rate limit use it would have,
+// without dispatching it or charging the caller. Requests the gateway would
+// refuse before calling a provider are refused the same way. Canary splits,
+// circuit breakers and guardrails are not applied.
+func (h *Handler) HandleEstimate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var body json.RawMessage
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || json.Unmarshal(body, &req) != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body", "")
+		return
+	}
+	req.inbound = r.Header
+	tenant, _ := req.Metadata["tenant"].(string)
+	if tenant == "" {
+		tenant = "anonymous"
+	}
+	useCase, _ := req.Metadata["use_case"].(string)
+
+	// Routed as HandleChat routes it, so an estimate is refused wherever the
+	// request would be.
+	charged := usage.ApproximateTokens(fmt.Sprintf("%v", req.Messages))
+	routing, refused := h.routeRequest(ctx, &req, h.router.Route(useCase), tenant, useCase, r.Header.Get("X-GW-Residency"), charged, nil)
+	if refused != nil {
+		if refused.cause != nil {
+			logError("", refused.record, refused.cause)
+		}
+		h.respondError(w, refused.status, refused.message, "")
+		return
+	}
+	route := routing.route
+	prompt := charged
+	if route.SystemPrompt != nil {
+		prompt += usage.ApproximateTokens(route.SystemPrompt.Content)
+	}
+	pricing := func(model string) usage.Pricing { return h.usage.Pricing(ctx, model) }
+	targets := h.orderTargets(ctx, route, nil)
+
+	est := estimateTargets(targets, pricing, prompt, req.MaxTokens, routing.maxOutput)
+	est.Route, est.Category = route.Name, routing.category
+	rl, err := h.rateLimitImpact(ctx, tenant, charged)
+	if err != nil {
+		logError("", "failed to read rate limit", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to read rate limit", "")
+		return
+	}
+	est.RateLimit = rl
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(est)
+}
+
+// estimateTargets prices targets, in the order they would be tried, for
+// promptTokens and up to maxTokens of completion, with each target's own
+// max_tokens param and the output cap applied as a call would.
+func estimateTargets(targets []config.Target, pricing func(model string) usage.Pricing, promptTokens, maxTokens, maxOutput int) Estimate {
+	est := Estimate{Provider: targets[0].Provider, Model: targets[0].Model, PromptTokens: promptTokens}
+	bounded := true
+	var high float64
+	for i, t := range targets {
+		n := maxTokens
+		if v, ok := t.Params["max_tokens"]; ok {
+			if m, ok := toInt(v); ok {
+				n = m
+			}
+		}
+		if maxOutput > 0 && (n == 0 || n > maxOutput) {
+			n = maxOutput
+		}
+		p := pricing(t.Model)
+		te := TargetEstimate{Provider: t.Provider, Model: t.Model, MaxTokens: n, PromptCostUSD: targetCost(p, promptTokens, 0)}
+		if n > 0 || p.OutputRate1M == 0 {
+			c := targetCost(p, promptTokens, n)
+			te.MaxCostUSD = &c
+			if i == 0 || c < est.MaxCostLowUSD {
+				est.MaxCostLowUSD = c
+			}
+			high = max(high, c)
+		} else {
+			bounded = false
+			if i == 0 || te.PromptCostUSD < est.MaxCostLowUSD {
+				est.MaxCostLowUSD = te.PromptCostUSD
+			}
+		}
+		if i == 0 {
+			est.MaxTokens = n
+		}
+		est.Targets = append(est.Targets, te)
+	}
+	if bounded {
+		est.MaxCostHighUSD = &high
+	}
+	return est
+}
+
+// rateLimitImpact reports how charging tokens to caller's window would go,
+// without charging them.
+func (h *Handler) rateLimitImpact(ctx context.Context, caller string, tokens int) (RateLimitImpact, error) {
+	rl := RateLimitImpact{Tokens: tokens}
+	// Charging nothing reads the window without using any of it.
+	quota, err := h.limiter.Check(ctx, caller, 0)
+	if err != nil {
+		return rl, err
+	}
+	rl.Limit, rl.Remaining, rl.Exempt = quota.Limit, quota.Remaining, quota.Exempt
+	rl.ResetSeconds = int(quota.Reset.Seconds() + 0.999)
+	rl.Allowed = quota.Limit <= 0 || quota.Exempt || tokens <= quota.Remaining
+	if rl.Allowed {
+		return rl, nil
+	}
+	balance, ok, err := h.limiter.BurstBalance(ctx, caller)
+	if err != nil {
+		return rl, err
+	}
+	if ok {
+		rl.BurstCredit = &balance
+		fits := tokens <= balance
+		rl.Allowed, rl.Burst = fits, fits
+	}
+	return rl, nil
+}
+
+// targetCost is the cost of a call at p's rates, in USD.
+func targetCost(p usage.Pricing, promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.InputRate1M + float64(completionTokens)*p.OutputRate1M) / 1e6
+}
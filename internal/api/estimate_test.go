@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/ratelimit"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+func TestEstimateTargets(t *testing.T) {
+	prices := map[string]usage.Pricing{
+		"gpt-4o":      {InputRate1M: 2.5, OutputRate1M: 10},
+		"gpt-4o-mini": {InputRate1M: 0.15, OutputRate1M: 0.6},
+	}
+	pricing := func(model string) usage.Pricing { return prices[model] }
+	targets := []config.Target{
+		{Provider: "openai", Model: "gpt-4o"},
+		{Provider: "openai", Model: "gpt-4o-mini", Params: map[string]interface{}{"max_tokens": 2000}},
+	}
+
+	est := estimateTargets(targets, pricing, 1000, 500, 0)
+	if est.Model != "gpt-4o" || est.MaxTokens != 500 || len(est.Targets) != 2 {
+		t.Fatalf("unexpected estimate %+v", est)
+	}
+	// gpt-4o: 1000*2.5/1e6 + 500*10/1e6; gpt-4o-mini: 1000*0.15/1e6 + 2000*0.6/1e6
+	if est.MaxCostHighUSD == nil || !near(*est.MaxCostHighUSD, 0.0075) || !near(est.MaxCostLowUSD, 0.00135) {
+		t.Errorf("unexpected cost range %v to %v", est.MaxCostLowUSD, est.MaxCostHighUSD)
+	}
+	if est.Targets[1].MaxTokens != 2000 || !near(est.Targets[1].PromptCostUSD, 0.00015) {
+		t.Errorf("unexpected fallback estimate %+v", est.Targets[1])
+	}
+
+	est = estimateTargets(targets[:1], pricing, 1000, 0, 0)
+	if est.MaxCostHighUSD != nil || est.Targets[0].MaxCostUSD != nil || !near(est.MaxCostLowUSD, 0.0025) {
+		t.Errorf("expected an unbounded completion to leave the high end open, got %+v", est)
+	}
+	if est = estimateTargets(targets[:1], pricing, 1000, 0, 100); est.MaxTokens != 100 || est.MaxCostHighUSD == nil {
+		t.Errorf("expected the output cap to bound the cost, got %+v", est)
+	}
+}
+
+func near(a, b float64) bool { return a-b < 1e-9 && b-a < 1e-9 }
+
+func TestRateLimitImpact(t *testing.T) {
+	ctx := context.Background()
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), 1000)
+	h := &Handler{limiter: limiter}
+	if _, err := limiter.Check(ctx, "acme", 700); err != nil {
+		t.Fatal(err)
+	}
+
+	rl, err := h.rateLimitImpact(ctx, "acme", 200)
+	if err != nil || !rl.Allowed || rl.Remaining != 300 || rl.Limit != 1000 {
+		t.Fatalf("unexpected impact %+v (%v)", rl, err)
+	}
+	if rl, _ := h.rateLimitImpact(ctx, "acme", 200); rl.Remaining != 300 {
+		t.Errorf("expected an estimate not to charge the window, got %+v", rl)
+	}
+	if rl, _ := h.rateLimitImpact(ctx, "acme", 400); rl.Allowed {
+		t.Errorf("expected a request past the window refused, got %+v", rl)
+	}
+
+	limiter.SetOverrides(func(context.Context, string) (ratelimit.Override, bool, error) {
+		return ratelimit.Override{Tenant: "acme", BurstTokens: 5000, UpdatedAt: time.Now()}, true, nil
+	})
+	if rl, _ := h.rateLimitImpact(ctx, "acme", 400); !rl.Allowed || !rl.Burst || rl.BurstCredit == nil || *rl.BurstCredit != 5000 {
+		t.Errorf("expected the request let through on burst credit, got %+v", rl)
+	}
+}
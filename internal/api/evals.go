@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/yewintnaing/ai-gateway/internal/eval"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// maxEvalTargets bounds the targets one eval request runs a dataset against.
+const maxEvalTargets = 10
+
+type startEvalRequest struct {
+	Dataset eval.Dataset  `json:"dataset"`
+	Targets []eval.Target `json:"targets"`
+}
+
+// evalCase sends one case of an eval run through target's route, with the
+// route's system prompt, target params and fallbacks, and logs it as its
+// own request with the run and case in its metadata.
+func (h *Handler) evalCase(ctx context.Context, runID string, target eval.Target, c eval.Case) (eval.Completion, error) {
+	route, ok := h.router.Lookup(target.Route)
+	if !ok {
+		return eval.Completion{}, fmt.Errorf("route %q not found", target.Route)
+	}
+	route = withModel(route, target.Provider, target.Model)
+	req := ChatRequest{Messages: c.Conversation(), MaxTokens: c.MaxTokens}
+	if route.SystemPrompt != nil {
+		req.Messages = applySystemPrompt(req.Messages, *route.SystemPrompt)
+	}
+	requestID := uuid.New().String()
+	resp, used, latency, attempts := h.completeOutOfBand(ctx, route, req, usage.Record{
+		RequestID: requestID, RouteName: route.Name,
+		Metadata: map[string]interface{}{"eval_run": runID, "eval_case": c.Name},
+	})
+	if resp == nil {
+		err := errors.New("every target failed")
+		if len(attempts) > 0 {
+			err = fmt.Errorf("every target failed, last: %s", attempts[len(attempts)-1].Error)
+		}
+		return eval.Completion{RequestID: requestID, LatencyMS: latency}, err
+	}
+	completion := eval.Completion{
+		RequestID: requestID, Provider: used.Provider, Model: used.Model, LatencyMS: latency,
+		PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens,
+		CostUSD: h.usage.Cost(ctx, used.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens),
+	}
+	if len(resp.Choices) > 0 {
+		completion.Content = resp.Choices[0].Message.Content
+	}
+	return completion, nil
+}
+
+// HandleStartEval runs a dataset against each target in the background and
+// answers 202 with the started runs.
+func (h *Handler) HandleStartEval(w http.ResponseWriter, r *http.Request) {
+	var body startEvalRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body", "")
+		return
+	}
+	if len(body.Targets) == 0 || len(body.Targets) > maxEvalTargets {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("an eval needs 1 to %d targets", maxEvalTargets), "")
+		return
+	}
+	for _, t := range body.Targets {
+		route, ok := h.router.Lookup(t.Route)
+		if !ok {
+			h.respondError(w, http.StatusBadRequest, fmt.Sprintf("route %q not found", t.Route), "")
+			return
+		}
+		if t.Provider != "" && t.Model == "" {
+			h.respondError(w, http.StatusBadRequest, "provider needs a model", "")
+			return
+		}
+		if err := validateRoute(withModel(route, t.Provider, t.Model), h.registry); err != nil {
+			h.respondError(w, http.StatusBadRequest, err.Error(), "")
+			return
+		}
+	}
+	if err := body.Dataset.Validate(); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+	runs, err := h.evals.Start(r.Context(), body.Dataset, body.Targets)
+	if err != nil {
+		logError("", "failed to start eval", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to start eval", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"runs": runs})
+}
+
+// HandleListEvals returns eval runs, newest first, filtered by the dataset
+// query parameter.
+func (h *Handler) HandleListEvals(w http.ResponseWriter, r *http.Request) {
+	runs, err := h.usage.ListEvalRuns(r.Context(), r.URL.Query().Get("dataset"), 100)
+	if err != nil {
+		logError("", "failed to list eval runs", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to list eval runs", "")
+		return
+	}
+	if runs == nil {
+		runs = []eval.Run{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"runs": runs})
+}
+
+// HandleGetEval returns an eval run with the results scored so far.
+func (h *Handler) HandleGetEval(w http.ResponseWriter, r *http.Request) {
+	run, ok := h.loadEvalRun(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
+// HandleCompareEvals compares the candidate run with the base run, case by
+// case.
+func (h *Handler) HandleCompareEvals(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("base") == "" || q.Get("candidate") == "" {
+		h.respondError(w, http.StatusBadRequest, "base and candidate run ids are required", "")
+		return
+	}
+	base, ok := h.loadEvalRun(w, r, q.Get("base"))
+	if !ok {
+		return
+	}
+	candidate, ok := h.loadEvalRun(w, r, q.Get("candidate"))
+	if !ok {
+		return
+	}
+	cmp, err := eval.Compare(base, candidate)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cmp)
+}
+
+func (h *Handler) loadEvalRun(w http.ResponseWriter, r *http.Request, id string) (eval.Run, bool) {
+	run, err := h.usage.GetEvalRun(r.Context(), id)
+	if errors.Is(err, usage.ErrEvalRunNotFound) {
+		h.respondError(w, http.StatusNotFound, fmt.Sprintf("eval run %q not found", id), "")
+		return run, false
+	}
+	if err != nil {
+		logError("", "failed to load eval run", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to load eval run", "")
+		return run, false
+	}
+	return run, true
+}
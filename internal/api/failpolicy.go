@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/governance"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Dependencies a failure policy covers, by their config.FailurePolicy key.
+var failureDependencies = []string{"rate_limit", "classifier", "classifier_timeout"}
+
+// failsClosed reports whether a request of tenant on route is refused when
+// dependency fails. The route's policy takes precedence over the tenant's;
+// without either, requests fail open.
+func failsClosed(tenant config.Tenant, route config.Route, dependency string) bool {
+	if p := route.FailurePolicy.For(dependency); p != "" {
+		return p == "closed"
+	}
+	return tenant.FailurePolicy.For(dependency) == "closed"
+}
+
+// dependencyFailed applies the failure policy to a request whose
+// dependency failed. Failing open, it records a warning on the request and
+// returns false; failing closed, it writes a 503 and returns true.
+func (h *Handler) dependencyFailed(ctx context.Context, w http.ResponseWriter, dependency string, err error, route config.Route, requestID, tenant, useCase string) bool {
+	closed := failsClosed(h.tenants[tenant], route, dependency)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("dependency_failed", dependency), attribute.Bool("failed_closed", closed))
+	logError(requestID, dependency+" unavailable", err)
+	if !closed {
+		h.usage.Warn(ctx, requestID, "failed open: "+dependency)
+		w.Header().Add("x-gw-failed-open", dependency)
+		return false
+	}
+	h.usage.Log(ctx, usage.Record{RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name, StatusCode: http.StatusServiceUnavailable, ErrorMessage: "failed closed: " + dependency})
+	h.respondError(w, http.StatusServiceUnavailable, dependency+" unavailable", requestID)
+	return true
+}
+
+// validateFailurePolicy rejects policies other than "open" and "closed".
+func validateFailurePolicy(p *config.FailurePolicy) error {
+	for _, dependency := range failureDependencies {
+		if v := p.For(dependency); v != "" && v != "open" && v != "closed" {
+			return fmt.Errorf("unknown failure_policy %s %q: want open or closed", dependency, v)
+		}
+	}
+	return nil
+}
+
+// errClassifierTimeout is a prompt injection classifier that did not answer
+// in time.
+var errClassifierTimeout = errors.New("prompt injection classifier timed out")
+
+// timeoutClassifier gives up on a classifier after timeout.
+type timeoutClassifier struct {
+	governance.Classifier
+	timeout time.Duration
+}
+
+func (c timeoutClassifier) Classify(text string) (float64, error) {
+	type result struct {
+		score float64
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		score, err := c.Classifier.Classify(text)
+		done <- result{score, err}
+	}()
+	select {
+	case r := <-done:
+		return r.score, r.err
+	case <-time.After(c.timeout):
+		return 0, errClassifierTimeout
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+func TestDependencyFailed(t *testing.T) {
+	store := &usage.Store{}
+	h := &Handler{usage: store, tenants: map[string]config.Tenant{
+		"strict": {Name: "strict", FailurePolicy: &config.FailurePolicy{RateLimit: "closed", Classifier: "closed"}},
+	}}
+	route := config.Route{Name: "chat", FailurePolicy: &config.FailurePolicy{Classifier: "open"}}
+	down := errors.New("connection refused")
+
+	ctx, _ := store.Begin(context.Background(), "r1")
+	w := httptest.NewRecorder()
+	if h.dependencyFailed(ctx, w, "rate_limit", down, route, "r1", "relaxed", "") {
+		t.Fatal("expected requests to fail open by default")
+	}
+	if got := w.Header().Get("x-gw-failed-open"); got != "rate_limit" {
+		t.Errorf("expected the failed-open header, got %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	if !h.dependencyFailed(ctx, w, "rate_limit", down, route, "r1", "strict", "") || w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected the tenant's policy to fail closed with 503, got %d", w.Code)
+	}
+
+	// The route's policy takes precedence over the tenant's.
+	w = httptest.NewRecorder()
+	if h.dependencyFailed(ctx, w, "classifier", down, route, "r1", "strict", "") {
+		t.Error("expected the route's policy to fail open")
+	}
+}
+
+func TestValidateFailurePolicy(t *testing.T) {
+	if err := validateFailurePolicy(&config.FailurePolicy{RateLimit: "closed", ClassifierTimeout: "open"}); err != nil {
+		t.Errorf("expected a valid policy, got %v", err)
+	}
+	if err := validateFailurePolicy(&config.FailurePolicy{Classifier: "retry"}); err == nil {
+		t.Error("expected an unknown policy rejected")
+	}
+}
+
+type slowClassifier struct{ delay time.Duration }
+
+func (c slowClassifier) Classify(text string) (float64, error) {
+	time.Sleep(c.delay)
+	return 0.9, nil
+}
+
+func TestTimeoutClassifier(t *testing.T) {
+	if _, err := (timeoutClassifier{slowClassifier{time.Second}, 10 * time.Millisecond}).Classify("x"); !errors.Is(err, errClassifierTimeout) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if score, err := (timeoutClassifier{slowClassifier{0}, time.Second}).Classify("x"); err != nil || score != 0.9 {
+		t.Errorf("expected the classifier's score, got %v, %v", score, err)
+	}
+}
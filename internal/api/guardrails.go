@@ -0,0 +1,257 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/governance"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// checkPromptInjection scans the request and records any detection. The
+// classifier is held to the same tenant policy, residency and zero
+// retention as the request's targets, and sees the messages with PII
+// masked. Its call is logged as its own request, with prompt_injection_of
+// metadata, so its cost is accounted for. A classifier that fails, times
+// out or that the tenant's policy rules out is handled by the failure
+// policy. It writes the error response and returns true when the request
+// is blocked.
+func (h *Handler) checkPromptInjection(ctx context.Context, w http.ResponseWriter, messages []providers.Message, route config.Route, requestID, tenant, residency, useCase string) bool {
+	cfg := *route.PromptInjection
+	routeName := route.Name
+	var classifier governance.Classifier
+	var unavailable error
+	if c := cfg.Classifier; c != nil {
+		if target, ok := h.summarizerRoute(route, *c, tenant, residency); ok {
+			record := usage.Record{
+				RequestID: uuid.New().String(), Tenant: tenant, UseCase: useCase, RouteName: routeName,
+				Metadata: map[string]interface{}{"prompt_injection_of": requestID},
+			}
+			classifier = &governance.ModelClassifier{Provider: classifierProvider{h: h, ctx: ctx, route: target, record: record}, Model: c.Model}
+			if cfg.TimeoutMS > 0 {
+				classifier = timeoutClassifier{classifier, time.Duration(cfg.TimeoutMS) * time.Millisecond}
+			}
+		} else {
+			unavailable = fmt.Errorf("the tenant's policy rules out classifier %s/%s", c.Provider, c.Model)
+		}
+	}
+
+	threshold := cfg.Threshold
+	if threshold <= 0 {
+		threshold = 0.5
+	}
+
+	res := h.inject.Scan(messages, classifier)
+	if res.ClassifierErr != nil {
+		unavailable = res.ClassifierErr
+	}
+	if err := unavailable; err != nil {
+		dependency := "classifier"
+		if errors.Is(err, errClassifierTimeout) {
+			dependency = "classifier_timeout"
+		}
+		if h.dependencyFailed(ctx, w, dependency, err, route, requestID, tenant, useCase) {
+			return true
+		}
+	}
+	if res.Score < threshold {
+		return false
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Float64("prompt_injection_score", res.Score),
+		attribute.String("prompt_injection_action", cfg.Action),
+	)
+
+	// The request row must exist before events can reference it.
+	blocked := cfg.Action == "block"
+	rec := usage.Record{RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: routeName}
+	if blocked {
+		rec.StatusCode = http.StatusBadRequest
+		rec.ErrorMessage = "blocked: prompt injection"
+	}
+	h.usage.Log(ctx, rec)
+	h.usage.LogEvent(ctx, requestID, usage.Event{
+		Kind: "prompt_injection",
+		Detail: map[string]interface{}{
+			"score":   res.Score,
+			"source":  res.Source,
+			"matches": res.Matches,
+			"action":  cfg.Action,
+		},
+	})
+
+	if blocked {
+		h.respondError(w, http.StatusBadRequest, fmt.Sprintf("request blocked by policy: likely prompt injection (score %.2f)", res.Score), requestID)
+		return true
+	}
+	w.Header().Set("x-gw-prompt-injection", "flagged")
+	return false
+}
+
+// classifierProvider makes the prompt injection classifier's calls on route
+// through completeOutOfBand, which masks PII and logs them as record's
+// request.
+type classifierProvider struct {
+	h      *Handler
+	ctx    context.Context
+	route  config.Route
+	record usage.Record
+}
+
+func (p classifierProvider) Chat(req providers.ChatRequest) (*providers.ChatResponse, error) {
+	resp, _, _, attempts := p.h.completeOutOfBand(p.ctx, p.route, ChatRequest{Messages: req.Messages, MaxTokens: req.MaxTokens}, p.record)
+	if resp == nil {
+		if len(attempts) > 0 {
+			return nil, errors.New(attempts[len(attempts)-1].Error)
+		}
+		return nil, fmt.Errorf("classifier %s failed", p.route.Primary.Model)
+	}
+	return resp, nil
+}
+
+func (p classifierProvider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatChunk, <-chan error) {
+	chunks, errs := make(chan providers.ChatChunk), make(chan error, 1)
+	close(chunks)
+	errs <- errors.New("the classifier does not stream")
+	return chunks, errs
+}
+
+// scanCompletion redacts credentials from a completion in place and reports
+// what was found. The caller withholds the response when cfg blocks.
+func (h *Handler) scanCompletion(resp *providers.ChatResponse) []governance.SecretType {
+	var found []governance.SecretType
+	for i, choice := range resp.Choices {
+		redacted, f := h.secrets.Redact(choice.Message.Content)
+		resp.Choices[i].Message.Content = redacted
+		found = append(found, f...)
+	}
+	return found
+}
+
+// logSecretLeak records a credential detection on the request.
+func (h *Handler) logSecretLeak(ctx context.Context, requestID string, found []governance.SecretType, action string) {
+	h.usage.LogEvent(ctx, requestID, usage.Event{
+		Kind:   "secret_leak",
+		Detail: map[string]interface{}{"types": found, "action": action},
+	})
+}
+
+// applyPromptWordList evaluates the tenant's word list against the prompt,
+// redacting messages in place. It writes the error response and returns true
+// when the request is blocked.
+func (h *Handler) applyPromptWordList(ctx context.Context, w http.ResponseWriter, wl *governance.WordList, messages []providers.Message, requestID, tenant, useCase, routeName string) bool {
+	var matched, annotations []string
+	blocked := false
+	for i, m := range messages {
+		if m.Role == "system" {
+			continue
+		}
+		res := wl.Evaluate(m.Content, "prompt")
+		messages[i].Content = res.Text
+		matched = append(matched, res.Matched...)
+		annotations = append(annotations, res.Annotations...)
+		blocked = blocked || res.Blocked
+	}
+	if len(matched) == 0 {
+		return false
+	}
+
+	rec := usage.Record{RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: routeName}
+	if blocked {
+		rec.StatusCode = http.StatusBadRequest
+		rec.ErrorMessage = "blocked: tenant word list"
+	}
+	h.usage.Log(ctx, rec)
+	h.logWordListMatch(ctx, requestID, "prompt", matched, blocked)
+
+	if blocked {
+		h.respondError(w, http.StatusBadRequest, "request blocked by tenant content policy", requestID)
+		return true
+	}
+	if len(annotations) > 0 {
+		w.Header().Set("x-gw-policy-annotations", strings.Join(annotations, ","))
+	}
+	return false
+}
+
+// applyCompletionWordList redacts a completion in place and reports whether
+// it must be withheld.
+func (h *Handler) applyCompletionWordList(ctx context.Context, w http.ResponseWriter, wl *governance.WordList, resp *providers.ChatResponse, requestID string) bool {
+	var matched, annotations []string
+	blocked := false
+	for i, choice := range resp.Choices {
+		res := wl.Evaluate(choice.Message.Content, "completion")
+		resp.Choices[i].Message.Content = res.Text
+		matched = append(matched, res.Matched...)
+		annotations = append(annotations, res.Annotations...)
+		blocked = blocked || res.Blocked
+	}
+	if len(matched) == 0 {
+		return false
+	}
+	h.logWordListMatch(ctx, requestID, "completion", matched, blocked)
+	if len(annotations) > 0 {
+		w.Header().Set("x-gw-policy-annotations", strings.Join(annotations, ","))
+	}
+	return blocked
+}
+
+func (h *Handler) logWordListMatch(ctx context.Context, requestID, scope string, matched []string, blocked bool) {
+	h.usage.LogEvent(ctx, requestID, usage.Event{
+		Kind:   "word_list",
+		Detail: map[string]interface{}{"scope": scope, "matched": matched, "blocked": blocked},
+	})
+}
+
+// routeModerator compiles the route's moderation checks; nil when the route
+// has none.
+func routeModerator(route config.Route) (*governance.Moderator, error) {
+	m := route.Moderation
+	if m == nil {
+		return nil, nil
+	}
+	if m.Action != "" && m.Action != "redact" && m.Action != "terminate" {
+		return nil, fmt.Errorf("unknown moderation action %q", m.Action)
+	}
+	return governance.NewModerator(m.Categories, m.Terms, m.WindowChars)
+}
+
+// moderateCompletion redacts disallowed content from a completion in place
+// and reports whether it must be withheld instead.
+func (h *Handler) moderateCompletion(ctx context.Context, w http.ResponseWriter, mod *governance.Moderator, route config.Route, resp *providers.ChatResponse, requestID string) bool {
+	if mod == nil {
+		return false
+	}
+	var found []string
+	for i, choice := range resp.Choices {
+		redacted, f := mod.Redact(choice.Message.Content)
+		resp.Choices[i].Message.Content = redacted
+		found = append(found, f...)
+	}
+	if len(found) == 0 {
+		return false
+	}
+	terminate := route.Moderation.Action == "terminate"
+	h.logModeration(ctx, requestID, found, terminate)
+	if !terminate {
+		w.Header().Set("x-gw-moderated", strings.Join(found, ","))
+	}
+	return terminate
+}
+
+func (h *Handler) logModeration(ctx context.Context, requestID string, categories []string, terminated bool) {
+	h.usage.LogEvent(ctx, requestID, usage.Event{
+		Kind:   "moderation",
+		Detail: map[string]interface{}{"categories": categories, "terminated": terminated},
+	})
+}
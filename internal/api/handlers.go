@@ -3,69 +3,214 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid" // Placeholder if needed
+	"github.com/yewintnaing/ai-gateway/internal/alerting"
 	"github.com/yewintnaing/ai-gateway/internal/cache"
+	"github.com/yewintnaing/ai-gateway/internal/canary"
+	"github.com/yewintnaing/ai-gateway/internal/chaos"
+	"github.com/yewintnaing/ai-gateway/internal/coalesce"
+	"github.com/yewintnaing/ai-gateway/internal/concurrency"
 	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/eval"
 	"github.com/yewintnaing/ai-gateway/internal/governance"
+	"github.com/yewintnaing/ai-gateway/internal/health"
+	"github.com/yewintnaing/ai-gateway/internal/payloads"
+	"github.com/yewintnaing/ai-gateway/internal/predict"
 	"github.com/yewintnaing/ai-gateway/internal/providers"
 	"github.com/yewintnaing/ai-gateway/internal/ratelimit"
+	"github.com/yewintnaing/ai-gateway/internal/rbac"
+	"github.com/yewintnaing/ai-gateway/internal/retention"
+	"github.com/yewintnaing/ai-gateway/internal/retrybudget"
 	"github.com/yewintnaing/ai-gateway/internal/router"
+	"github.com/yewintnaing/ai-gateway/internal/shaping"
+	"github.com/yewintnaing/ai-gateway/internal/streambuf"
+	"github.com/yewintnaing/ai-gateway/internal/tools"
 	"github.com/yewintnaing/ai-gateway/internal/usage"
+	"github.com/yewintnaing/ai-gateway/internal/webhook"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type Handler struct {
-	router   *router.Router
-	registry providers.Registry
-	usage    *usage.Store
-	limiter  *ratelimit.Limiter
-	cache    *cache.Cache
-	detector *governance.Detector
-	tracer   trace.Tracer
+	router    *router.Router
+	registry  providers.Registry
+	usage     *usage.Store
+	limiter   *ratelimit.Limiter
+	cache     *cache.Cache
+	detector  *governance.Detector
+	inject    *governance.InjectionDetector
+	secrets   *governance.SecretScanner
+	wordLists *governance.WordListCache
+	lengths   *predict.Estimator
+	streams   *streambuf.Buffer
+	tenants   map[string]config.Tenant
+	metadata  config.MetadataSchema
+	snippets  int
+	alerts    *alerting.Alerter
+	tools     *tools.Registry
+	canary    *canary.Controller
+	health    *health.Tracker
+	inflight  *concurrency.Limiter
+	retries   *retrybudget.Budget
+	coalesce  *coalesce.Group
+	ttft      metric.Float64Histogram
+	auth      config.Auth
+	retention *retention.Job
+	payloads  *payloads.Sealer // nil unless payload capture is on
+	webhooks  *webhook.Deliverer
+	// roleGrants loads the roles assigned to a subject.
+	roleGrants func(ctx context.Context, subject string) ([]rbac.Grant, error)
+	// providerOpts are per-provider extra headers, keyed by provider name.
+	providerOpts map[string]config.ProviderOptions
+	tracer       trace.Tracer
+	// retryDenied counts retries refused by the retry budget.
+	retryDenied metric.Int64Counter
+	// streamStats time streamed chunks and whole streams.
+	streamStats streamMetrics
+	// evals runs golden datasets started through the admin API.
+	evals *eval.Runner
+	// providerStats are this instance's rolling attempt stats per target.
+	providerStats *health.Stats
 }
 
-func NewHandler(r *router.Router, reg providers.Registry, s *usage.Store, l *ratelimit.Limiter, c *cache.Cache, d *governance.Detector) *Handler {
-	return &Handler{
-		router:   r,
-		registry: reg,
-		usage:    s,
-		limiter:  l,
-		cache:    c,
-		detector: d,
-		tracer:   otel.Tracer("gateway-handler"),
+func NewHandler(r *router.Router, reg providers.Registry, s *usage.Store, l *ratelimit.Limiter, c *cache.Cache, d *governance.Detector, sb *streambuf.Buffer, tenants []config.Tenant, schema config.MetadataSchema, snippetLen int, alerts *alerting.Alerter, tr *tools.Registry, providerOpts map[string]config.ProviderOptions, ht *health.Tracker, inflight *concurrency.Limiter, rb *retrybudget.Budget, auth config.Auth, rj *retention.Job, ps *payloads.Sealer, wd *webhook.Deliverer) *Handler {
+	tenantMap := make(map[string]config.Tenant, len(tenants))
+	for _, t := range tenants {
+		tenantMap[t.Name] = t
 	}
+	h := &Handler{
+		router:       r,
+		registry:     reg,
+		usage:        s,
+		limiter:      l,
+		cache:        c,
+		detector:     d,
+		inject:       governance.NewInjectionDetector(),
+		secrets:      governance.NewSecretScanner(),
+		wordLists:    governance.NewWordListCache(time.Minute, s.ListWordRules),
+		lengths:      predict.New(10*time.Minute, s.CompletionLength),
+		streams:      sb,
+		tenants:      tenantMap,
+		metadata:     schema,
+		snippets:     snippetLen,
+		alerts:       alerts,
+		tools:        tr,
+		providerOpts: providerOpts,
+		health:       ht,
+		inflight:     inflight,
+		retries:      rb,
+		coalesce:     coalesce.New(),
+		auth:         auth,
+		retention:    rj,
+		payloads:     ps,
+		webhooks:     wd,
+		roleGrants:   s.RoleGrants,
+		tracer:       otel.Tracer("gateway-handler"),
+	}
+	h.canary = canary.New(h.rollbackCanary)
+	h.ttft, _ = otel.Meter("gateway-handler").Float64Histogram("gateway.provider.ttft",
+		metric.WithDescription("Time to first streamed token per provider attempt"), metric.WithUnit("ms"))
+	h.streamStats = newStreamMetrics()
+	h.evals = eval.New(s, h.evalCase)
+	h.providerStats = health.NewStats()
+	h.retryDenied, _ = otel.Meter("gateway-handler").Int64Counter("gateway.retry_budget.exhausted",
+		metric.WithDescription("Provider retries skipped because the retry budget was spent"))
+	return h
 }
 
 type ChatRequest struct {
-	Model       string                 `json:"model"`
-	Messages    []providers.Message    `json:"messages"`
-	Temperature float64                `json:"temperature"`
-	MaxTokens   int                    `json:"max_tokens"`
-	Stream      bool                   `json:"stream"`
-	Metadata    map[string]interface{} `json:"metadata"`
+	Model            string                  `json:"model"`
+	Messages         []providers.Message     `json:"messages"`
+	Temperature      *float64                `json:"temperature"`
+	MaxTokens        int                     `json:"max_tokens"`
+	Stream           bool                    `json:"stream"`
+	TopP             *float64                `json:"top_p"`
+	Stop             providers.StopSequences `json:"stop"`
+	PresencePenalty  *float64                `json:"presence_penalty"`
+	FrequencyPenalty *float64                `json:"frequency_penalty"`
+	LogitBias        map[string]float64      `json:"logit_bias"`
+	Seed             *int                    `json:"seed"`
+	N                int                     `json:"n"`
+	Tools            []providers.Tool        `json:"tools"`
+	ToolChoice       json.RawMessage         `json:"tool_choice"`
+	Metadata         map[string]interface{}  `json:"metadata"`
+	MaxCostUSD       float64                 `json:"max_cost_usd"`
+	ReasoningEffort  string                  `json:"reasoning_effort"`
+	// Thinking sets an Anthropic thinking budget directly.
+	Thinking *providers.Thinking `json:"thinking"`
+	// IncludeReasoning returns the model's reasoning with the answer
+	// instead of stripping it.
+	IncludeReasoning bool `json:"include_reasoning"`
+
+	// inbound are the client's request headers, for providers that forward
+	// some of them.
+	inbound http.Header
+	// extra are body params a route transform set that ChatRequest has no
+	// field for, sent to the provider as they are.
+	extra map[string]interface{}
+	// backend are body params the client sent that ChatRequest has no
+	// field for, such as vLLM's best_of, sent only to providers that pass
+	// them through.
+	backend map[string]interface{}
 }
 
 func (h *Handler) HandleChat(w http.ResponseWriter, r *http.Request) {
 	ctx, span := h.tracer.Start(r.Context(), "HandleChat")
 	defer span.End()
 
-	start := time.Now()
-	requestID := r.Header.Get("x-request-id")
-	if requestID == "" {
-		requestID = uuid.New().String()
+	// Reconnect of a dropped stream: serve the rest from the buffer rather
+	// than running the completion again. Only the tenant that started the
+	// stream can resume it.
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" && h.streams != nil {
+		token, seq, err := streambuf.ParseEventID(lastEventID)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, err.Error(), "")
+			return
+		}
+		var resume ChatRequest
+		json.NewDecoder(r.Body).Decode(&resume)
+		tenant, _ := resume.Metadata["tenant"].(string)
+		if tenant == "" {
+			tenant = "anonymous"
+		}
+		span.SetAttributes(attribute.String("tenant", tenant), attribute.Int("resume_seq", seq))
+		if !h.resumeStream(w, r, token, tenant, seq) {
+			h.respondError(w, http.StatusGone, "stream is no longer available for resume", "")
+		}
+		return
 	}
 
+	start := time.Now()
+	// Usage is keyed on an ID of the gateway's own, so a client reusing its
+	// x-request-id cannot have a request's usage dropped as a replay. The
+	// client's ID is kept in the record's metadata.
+	requestID := uuid.New().String()
+	clientRequestID := r.Header.Get("x-request-id")
+
+	// The request's usage is collected and written in one transaction once
+	// it has been handled.
+	ctx, entry := h.usage.Begin(ctx, requestID)
+	defer entry.Commit(context.WithoutCancel(ctx))
+	r = r.WithContext(ctx)
+
+	var body json.RawMessage
 	var req ChatRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || json.Unmarshal(body, &req) != nil {
 		h.respondError(w, http.StatusBadRequest, "invalid request body", requestID)
 		return
 	}
+	req.inbound = r.Header
+	req.backend = backendParams(body)
 
 	tenant, _ := req.Metadata["tenant"].(string)
 	if tenant == "" {
@@ -79,54 +224,262 @@ func (h *Handler) HandleChat(w http.ResponseWriter, r *http.Request) {
 		attribute.String("use_case", useCase),
 	)
 
+	// Priority in the queue for saturated targets
+	priority, status, err := requestPriority(r, h.tenants[tenant])
+	if err != nil {
+		h.usage.Log(ctx, usage.Record{RequestID: requestID, Tenant: tenant, UseCase: useCase, StatusCode: status, ErrorMessage: err.Error()})
+		h.respondError(w, status, err.Error(), requestID)
+		return
+	}
+	ctx = concurrency.WithPriority(ctx, priority)
+	span.SetAttributes(attribute.String("priority", priority.String()))
+
 	// Rate Limiting
 	caller := tenant // Simplification: use tenant as caller
 	promptTokens := usage.ApproximateTokens(fmt.Sprintf("%v", req.Messages))
-	allowed, err := h.limiter.Allow(ctx, caller, promptTokens)
-	if err != nil {
-		logError(requestID, "rate limit check failed", err)
+	quota, limitErr := h.limiter.Check(ctx, caller, promptTokens)
+	if limitErr != nil {
+		// The failure policy decides once the route is known.
+		quota.Allowed = true
 	}
-	if !allowed {
+	quota.SetHeaders(w.Header())
+	if !quota.Allowed {
+		h.alerts.Observe(alerting.Event{Kind: alerting.KindBudget, Tenant: tenant, Detail: "tokens-per-minute limit reached"})
 		h.usage.Log(ctx, usage.Record{RequestID: requestID, Tenant: tenant, UseCase: useCase, StatusCode: http.StatusTooManyRequests, ErrorMessage: "rate limited"})
 		h.respondError(w, http.StatusTooManyRequests, "rate limited", requestID)
 		return
 	}
 
 	// Routing
-	route := h.router.Route(useCase)
+	route, matchedBy := h.router.Match(useCase)
+	var rt *RoutingTrace
+	if debugRequested(r) {
+		rt = &RoutingTrace{UseCase: useCase, MatchedBy: matchedBy}
+	}
+	before := routeTargets(route)
+	ctx, route = h.canary.Select(ctx, route)
 	span.SetAttributes(attribute.String("route_name", route.Name))
+	if version, ok := canary.Version(ctx); ok {
+		span.SetAttributes(attribute.String("canary_version", version))
+		w.Header().Set("x-gw-canary", version)
+		if rt != nil {
+			rt.Canary = version
+			rt.filter("canary", before, routeTargets(route))
+		}
+	}
+	if limitErr != nil && h.dependencyFailed(ctx, w, "rate_limit", limitErr, route, requestID, tenant, useCase) {
+		return
+	}
+
+	// Classification, transform and metadata, tenant policy and residency,
+	// output budget, parameter policy and cost ceiling
+	routing, refused := h.routeRequest(ctx, &req, route, tenant, useCase, r.Header.Get("X-GW-Residency"), promptTokens, rt)
+	route = routing.route
+	category, similarity, transformed := routing.category, routing.similarity, routing.transformed
+	residency, maxOutput, paramChanges := routing.residency, routing.maxOutput, routing.paramChanges
+	if category != "" {
+		span.SetAttributes(attribute.String("category", category), attribute.Float64("category_similarity", similarity))
+		w.Header().Set("x-gw-category", category)
+		if rt != nil {
+			rt.Category = category
+		}
+	}
+	if len(transformed) > 0 {
+		span.SetAttributes(attribute.StringSlice("transformed", transformed))
+	}
+	if residency != "" {
+		span.SetAttributes(attribute.String("residency", residency))
+	}
+	if route.ZeroRetention {
+		span.SetAttributes(attribute.Bool("zero_retention", true))
+	}
+	if routing.clamped {
+		span.SetAttributes(attribute.Int("max_tokens_clamped_to", maxOutput))
+	}
+	if maxOutput > 0 {
+		w.Header().Set("x-gw-budget-output-tokens", strconv.Itoa(maxOutput))
+	}
+	if routing.predicted > 0 {
+		span.SetAttributes(attribute.Int("max_tokens_predicted", routing.predicted))
+		w.Header().Set("x-gw-predicted-max-tokens", strconv.Itoa(routing.predicted))
+	}
+	for _, c := range paramChanges {
+		span.SetAttributes(attribute.Float64(c.Param+"_clamped_to", c.To))
+	}
+	if routing.ceiling > 0 {
+		span.SetAttributes(attribute.Float64("max_cost_usd", routing.ceiling))
+	}
+	if refused != nil {
+		if refused.cause != nil {
+			logError(requestID, refused.record, refused.cause)
+		}
+		h.usage.Log(ctx, usage.Record{RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name, StatusCode: refused.status, ErrorMessage: refused.record})
+		h.respondError(w, refused.status, refused.message, requestID)
+		return
+	}
+
+	if clientRequestID != "" {
+		if req.Metadata == nil {
+			req.Metadata = map[string]interface{}{}
+		}
+		req.Metadata["client_request_id"] = clientRequestID
+	}
+	conversation, err := conversationID(r, req.Metadata)
+	if err != nil {
+		h.usage.Log(ctx, usage.Record{RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name, StatusCode: http.StatusBadRequest, ErrorMessage: err.Error()})
+		h.respondError(w, http.StatusBadRequest, err.Error(), requestID)
+		return
+	}
+	if conversation != "" {
+		span.SetAttributes(attribute.String("conversation_id", conversation))
+	}
+
+	// Prompt injection detection
+	if route.PromptInjection != nil {
+		if blocked := h.checkPromptInjection(ctx, w, req.Messages, route, requestID, tenant, residency, useCase); blocked {
+			return
+		}
+	}
+
+	// Tenant word lists (may redact the prompt, so work on a copy)
+	wordList := h.wordLists.Get(ctx, tenant)
+	req.Messages = append([]providers.Message(nil), req.Messages...)
+	if blocked := h.applyPromptWordList(ctx, w, wordList, req.Messages, requestID, tenant, useCase, route.Name); blocked {
+		return
+	}
+	if conversation != "" && route.SessionSummary != nil && h.cache != nil {
+		h.applySessionSummary(ctx, &req, route, tenant, residency, useCase, conversation, requestID)
+	}
+
+	// Output moderation
+	moderator, err := routeModerator(route)
+	if err != nil {
+		logError(requestID, "invalid moderation config", err)
+		h.usage.Log(ctx, usage.Record{RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name, StatusCode: http.StatusInternalServerError, ErrorMessage: "invalid moderation config"})
+		h.respondError(w, http.StatusInternalServerError, "route has an invalid moderation config", requestID)
+		return
+	}
+
+	// Managed system prompt
+	if route.SystemPrompt != nil {
+		req.Messages = applySystemPrompt(req.Messages, *route.SystemPrompt)
+		span.SetAttributes(attribute.String("system_prompt_version", route.SystemPrompt.Version))
+		w.Header().Set("x-gw-system-prompt-version", route.SystemPrompt.Version)
+	}
 
 	// Cache Check (only for non-streaming)
 	var cacheKey string
 	if !req.Stream && h.cache != nil {
 		var err error
-		cacheKey, err = cache.GenerateKey(route.Primary.Model, req.Messages)
+		cacheKey, err = responseCacheKey(tenant, route, req)
 		if err == nil {
 			var cachedResp providers.ChatResponse
-			found, _ := h.cache.Get(ctx, cacheKey, &cachedResp)
+			status := "HIT"
+			var found bool
+			freshness := cache.Fresh
+			if route.Cache != nil {
+				var age time.Duration
+				found, age, _ = h.cache.GetWithAge(ctx, cacheKey, &cachedResp)
+				freshness = cache.Classify(age, route.Cache.Fresh(), route.Cache.Stale())
+				switch freshness {
+				case cache.Stale:
+					status = "STALE"
+					go h.revalidate(req, route, cacheKey, tenant, useCase, wordList, moderator)
+				case cache.Expired:
+					found = false
+				}
+			} else {
+				found, _ = h.cache.Get(ctx, cacheKey, &cachedResp)
+			}
+			h.cache.Observe(route.Name, freshness, found)
+			w.Header().Set("x-gw-cache-key", cacheKey)
 			if found {
 				span.SetAttributes(attribute.Bool("cache_hit", true))
+				w.Header().Set("Content-Type", "application/json")
 				w.Header().Set("x-request-id", requestID)
-				w.Header().Set("x-gw-cache", "HIT")
-				json.NewEncoder(w).Encode(cachedResp)
+				w.Header().Set("x-gw-cache", status)
+				if !showReasoning(req, route) {
+					stripReasoning(&cachedResp)
+				}
+				if rt != nil {
+					rt.Route, rt.Cache = route.Name, status
+					rt.candidates(ctx, h, routeTargets(route))
+				}
+				json.NewEncoder(w).Encode(traced(cachedResp, rt))
 				return
 			}
 		}
 	}
 
-	// Ensure request row exists for attempts
+	// The request as routed, completed once a target answers
+	var promptVersion string
+	if route.SystemPrompt != nil {
+		promptVersion = route.SystemPrompt.Version
+	}
 	h.usage.Log(ctx, usage.Record{
-		RequestID: requestID,
-		Tenant:    tenant,
-		UseCase:   useCase,
-		RouteName: route.Name,
+		RequestID:           requestID,
+		Tenant:              tenant,
+		UseCase:             useCase,
+		RouteName:           route.Name,
+		SystemPromptVersion: promptVersion,
+		Metadata:            req.Metadata,
+		ConversationID:      conversation,
 	})
+	if category != "" {
+		h.usage.LogEvent(ctx, requestID, usage.Event{
+			Kind: "classification",
+			Detail: map[string]interface{}{
+				"category":   category,
+				"similarity": similarity,
+				"provider":   route.Primary.Provider,
+				"model":      route.Primary.Model,
+			},
+		})
+	}
+
+	if len(transformed) > 0 {
+		h.usage.LogEvent(ctx, requestID, usage.Event{
+			Kind:   "transform",
+			Detail: map[string]interface{}{"fields": transformed},
+		})
+	}
+	if len(paramChanges) > 0 {
+		h.usage.LogEvent(ctx, requestID, usage.Event{
+			Kind:   "param_policy",
+			Detail: map[string]interface{}{"changes": paramChanges},
+		})
+	}
+
+	// PII Masking (once per request, so retries and fallbacks share the same
+	// unmask map)
+	messages, unmaskMap := h.maskMessages(req.Messages)
+	if len(unmaskMap) > 0 {
+		h.usage.LogEvent(ctx, requestID, usage.Event{
+			Kind:   "pii_masked",
+			Detail: map[string]interface{}{"tokens": len(unmaskMap)},
+		})
+	}
 
 	// Attempt coordination
 	var lastErr error
 
-	targets := append([]config.Target{route.Primary}, route.Fallbacks...)
+	targets := h.orderTargets(ctx, route, rt)
+	if rt != nil {
+		rt.Route = route.Name
+		rt.candidates(ctx, h, targets)
+	}
 	attemptNo := 1
+	h.retries.Request(tenant)
+	fit := h.contextFit(req, route, residency, messages, headerData{Tenant: tenant, UseCase: useCase, Route: route.Name, RequestID: requestID})
+
+	if route.Consensus != nil && !req.Stream {
+		var done bool
+		done, lastErr = h.handleConsensus(ctx, w, req, messages, fit, unmaskMap, wordList, moderator, route, requestID, tenant, useCase, maxOutput, start)
+		if done {
+			return
+		}
+		targets = nil
+	}
 
 	for _, target := range targets {
 		for i := 0; i <= route.Retries; i++ {
@@ -136,50 +489,98 @@ func (h *Handler) HandleChat(w http.ResponseWriter, r *http.Request) {
 				attribute.Int("attempt_no", attemptNo),
 			))
 
+			if !h.health.Allow(tCtx, target.Provider) {
+				tSpan.SetAttributes(attribute.Bool("circuit_open", true))
+				tSpan.End()
+				lastErr = errCircuitOpen(target.Provider)
+				rt.attempt(target, lastErr)
+				break
+			}
 			provider, pErr := h.registry.Get(target.Provider)
 			if pErr != nil {
 				tSpan.End()
 				lastErr = pErr
 				break
 			}
-
-			provReq := providers.ChatRequest{
-				Model:       target.Model,
-				Messages:    req.Messages,
-				Temperature: req.Temperature,
-				MaxTokens:   req.MaxTokens,
-				Stream:      req.Stream,
+			if route.Chaos != nil {
+				provider = chaos.Wrap(target.Provider, provider, *route.Chaos)
 			}
 
-			// PII Masking
-			var unmaskMap map[string]string
-			if h.detector != nil {
-				for i, msg := range provReq.Messages {
-					masked, m := h.detector.Mask(msg.Content)
-					provReq.Messages[i].Content = masked
-					// Merge unmask maps (simplification: assume no token collisions across messages)
-					if unmaskMap == nil {
-						unmaskMap = m
-					} else {
-						for k, v := range m {
-							unmaskMap[k] = v
-						}
-					}
-				}
+			fitted, pErr := fit.forTarget(tCtx, target)
+			if pErr != nil {
+				tSpan.End()
+				lastErr = pErr
+				break
+			}
+			provReq, pErr := h.targetRequest(req, fitted, route, target)
+			if pErr != nil {
+				tSpan.End()
+				lastErr = pErr
+				break
+			}
+			release, pErr := h.acquireTarget(tCtx, target)
+			if pErr != nil {
+				tSpan.SetAttributes(attribute.Bool("concurrency_saturated", true))
+				tSpan.End()
+				lastErr = pErr
+				rt.attempt(target, pErr)
+				break
+			}
+			capTargetOutput(&provReq, maxOutput)
+			provReq.Timeout = h.targetTimeout(tCtx, route, target, provReq.MaxTokens)
+			provReq.Headers = h.outboundHeaders(tCtx, req, target, headerData{Tenant: tenant, UseCase: useCase, Route: route.Name, RequestID: requestID, zeroRetention: route.ZeroRetention, transform: route.Transform})
+			var account config.ProviderAccount
+			provReq.Account, account = h.providerAccount(route, tenant, target)
+			if route.Tools != nil && !req.Stream {
+				provReq.Tools = append(provReq.Tools, h.tools.Definitions(tCtx, route.Tools.Enabled)...)
 			}
 
 			attemptStart := time.Now()
 
 			if req.Stream {
-				h.handleStream(tCtx, w, r, provider, provReq, requestID, route, target, tenant, useCase, attemptNo)
+				h.handleStream(tCtx, w, r, provider, provReq, requestID, route, target, tenant, useCase, attemptNo, maxOutput, wordList, moderator, showReasoning(req, route))
+				release()
 				tSpan.End()
 				return // handleStream takes over the response
 			}
 
-			resp, err := provider.Chat(provReq)
+			var key string
+			if route.Coalesce {
+				key, _ = coalesce.Key(target.Provider, provReq)
+			}
+			resp, shared, err := h.coalesce.Do(key, func() (*providers.ChatResponse, error) {
+				return h.complete(provider, provReq, route, target)
+			})
+			if shared {
+				tSpan.SetAttributes(attribute.Bool("coalesced", true))
+				w.Header().Set("x-gw-coalesced", "true")
+			}
+			if err == nil && !shared {
+				h.recordSpeed(tCtx, target, resp.Usage.CompletionTokens, time.Since(attemptStart), resp.TTFT)
+			}
+			if err == nil && route.Tools != nil {
+				resp, err = h.runTools(tCtx, provider, provReq, resp, *route.Tools, unmaskMap, requestID)
+			}
+			if err == nil && route.Language != nil {
+				resp = h.enforceLanguage(tCtx, provider, provReq, resp, *route.Language, promptLanguage(req.Messages), requestID)
+			}
+			if err == nil && route.Continuation != nil {
+				resp = h.completeContinued(tCtx, provider, provReq, resp, route, target, maxOutput, requestID)
+			}
+			if err == nil && route.Repair != nil {
+				first := resp
+				resp, err = h.repairCompletion(tCtx, provider, provReq, resp, route, target, requestID)
+				if resp != first {
+					tSpan.SetAttributes(attribute.Bool("repair_attempted", true))
+				}
+				if err == nil && resp != first {
+					w.Header().Set("x-gw-repaired", "true")
+				}
+			}
+			release()
 			latency := int(time.Since(attemptStart).Milliseconds())
 
-			h.usage.LogAttempt(tCtx, requestID, usage.Attempt{
+			attempt := usage.Attempt{
 				RequestID:    requestID,
 				AttemptNo:    attemptNo,
 				Provider:     target.Provider,
@@ -187,24 +588,39 @@ func (h *Handler) HandleChat(w http.ResponseWriter, r *http.Request) {
 				LatencyMS:    latency,
 				StatusCode:   getStatusCode(err, resp != nil),
 				ErrorMessage: getErrorMessage(err),
-			})
+				Organization: account.Organization,
+				Project:      account.Project,
+			}
+			if resp != nil && resp.TTFT > 0 {
+				attempt.TTFTMS = int(resp.TTFT.Milliseconds())
+				h.recordTTFT(tCtx, target, resp.TTFT)
+			}
+			h.usage.LogAttempt(tCtx, requestID, attempt)
+			rt.attempt(target, err)
+			h.alerts.Observe(alerting.Event{Kind: alerting.KindAttempt, Provider: target.Provider, Tenant: tenant, Failed: err != nil, Detail: getErrorMessage(err)})
+			h.canary.Observe(tCtx, time.Since(attemptStart), err != nil)
+			h.recordHealth(tCtx, target, time.Since(attemptStart), err)
 
 			if err == nil {
+				// Only a cut-off that our clamp or prediction caused counts
+				// as truncation.
+				truncated := (routing.clamped || routing.predicted > 0) && len(resp.Choices) > 0 &&
+					providers.NormalizeFinishReason(resp.Choices[0].FinishReason) == providers.FinishLength
 				h.usage.Log(tCtx, usage.Record{
 					RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name,
-					Provider: target.Provider, Model: target.Model,
+					Provider: target.Provider, Model: target.Model, Region: h.region(target.Provider),
 					PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens, TotalTokens: resp.Usage.TotalTokens,
-					LatencyMS: int(time.Since(start).Milliseconds()), StatusCode: http.StatusOK,
+					PromptRoles:     promptRoles(provReq, resp.Usage.PromptTokens),
+					ReasoningTokens: resp.Usage.ReasoningTokens(),
+					LatencyMS:       int(time.Since(start).Milliseconds()), StatusCode: http.StatusOK,
+					Truncated: truncated,
 				})
 
-				// Store in cache if applicable
-				if cacheKey != "" && h.cache != nil {
-					h.cache.Set(ctx, cacheKey, resp)
-				}
-
+				w.Header().Set("Content-Type", "application/json")
 				w.Header().Set("x-request-id", requestID)
 				w.Header().Set("x-gw-route", route.Name)
 				w.Header().Set("x-gw-provider", target.Provider)
+				setRegion(w, h.region(target.Provider))
 				w.Header().Set("x-gw-model", target.Model)
 				w.Header().Set("x-gw-cache", "MISS")
 
@@ -215,12 +631,82 @@ func (h *Handler) HandleChat(w http.ResponseWriter, r *http.Request) {
 					}
 				}
 
-				json.NewEncoder(w).Encode(resp)
+				if blocked := h.applyCompletionWordList(tCtx, w, wordList, resp, requestID); blocked {
+					h.usage.Log(tCtx, usage.Record{
+						RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name,
+						Provider: target.Provider, Model: target.Model, Region: h.region(target.Provider),
+						PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens, TotalTokens: resp.Usage.TotalTokens,
+						PromptRoles: promptRoles(provReq, resp.Usage.PromptTokens),
+						LatencyMS:   int(time.Since(start).Milliseconds()), StatusCode: http.StatusBadGateway,
+						ErrorMessage: "blocked: tenant word list",
+					})
+					h.respondError(w, http.StatusBadGateway, "response blocked by tenant content policy", requestID)
+					tSpan.End()
+					return
+				}
+
+				// Output guardrail: credentials echoed back by the model
+				if route.SecretScan != nil {
+					if found := h.scanCompletion(resp); len(found) > 0 {
+						h.logSecretLeak(tCtx, requestID, found, route.SecretScan.Action)
+						if route.SecretScan.Action == "block" {
+							h.usage.Log(tCtx, usage.Record{
+								RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name,
+								Provider: target.Provider, Model: target.Model, Region: h.region(target.Provider),
+								PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens, TotalTokens: resp.Usage.TotalTokens,
+								PromptRoles: promptRoles(provReq, resp.Usage.PromptTokens),
+								LatencyMS:   int(time.Since(start).Milliseconds()), StatusCode: http.StatusBadGateway,
+								ErrorMessage: "blocked: credential in output",
+							})
+							h.respondError(w, http.StatusBadGateway, "response blocked by policy: completion contained a credential", requestID)
+							tSpan.End()
+							return
+						}
+						w.Header().Set("x-gw-redacted", "secrets")
+					}
+				}
+
+				if blocked := h.moderateCompletion(tCtx, w, moderator, route, resp, requestID); blocked {
+					h.usage.Log(tCtx, usage.Record{
+						RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name,
+						Provider: target.Provider, Model: target.Model, Region: h.region(target.Provider),
+						PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens, TotalTokens: resp.Usage.TotalTokens,
+						PromptRoles: promptRoles(provReq, resp.Usage.PromptTokens),
+						LatencyMS:   int(time.Since(start).Milliseconds()), StatusCode: http.StatusBadGateway,
+						ErrorMessage: "blocked: moderation",
+					})
+					h.respondError(w, http.StatusBadGateway, "response blocked by content moderation", requestID)
+					tSpan.End()
+					return
+				}
+
+				// Cache the completion as the output guardrails left it, so a
+				// hit serves nothing they blocked or redacted. Reasoning is
+				// stripped per request.
+				if cacheKey != "" && h.cache != nil {
+					tags := h.cacheTags(req, tenant, route.Name, target.Model)
+					if route.Cache != nil {
+						h.cache.SetFor(ctx, cacheKey, tags, resp, route.Cache.Fresh()+route.Cache.Stale())
+					} else {
+						h.cache.Set(ctx, cacheKey, tags, resp)
+					}
+				}
+
+				if !showReasoning(req, route) {
+					stripReasoning(resp)
+				}
+				json.NewEncoder(w).Encode(traced(resp, rt))
+				h.capturePayload(tCtx, requestID, tenant, req.Messages, resp)
+				if len(resp.Choices) > 0 {
+					h.traceSnippets(tSpan, req.Messages, resp.Choices[0].Message.Content)
+				}
 				tSpan.End()
 				return
 			}
 
 			tSpan.RecordError(err)
+			tSpan.SetStatus(codes.Error, err.Error())
+			h.traceSnippets(tSpan, req.Messages, "")
 			tSpan.End()
 			lastErr = err
 			attemptNo++
@@ -229,65 +715,480 @@ func (h *Handler) HandleChat(w http.ResponseWriter, r *http.Request) {
 				logError(requestID, "non-retryable error", err)
 				break
 			}
+			if i < route.Retries {
+				// Retrying a provider that is failing for everyone only
+				// adds to its load; once the budget is spent, move on to
+				// the next target instead.
+				if ok, scope := h.retries.Retry(tenant); !ok {
+					span.SetAttributes(attribute.String("retry_budget_exhausted", scope))
+					if h.retryDenied != nil {
+						h.retryDenied.Add(ctx, 1, metric.WithAttributes(attribute.String("scope", scope), attribute.String("tenant", tenant)))
+					}
+					break
+				}
+			}
+		}
+	}
+	// A parameter or request no target could honour is a client error, not
+	// an upstream one.
+	span.SetStatus(codes.Error, lastErr.Error())
+	var unsupported *providers.UnsupportedParamError
+	var constraint *providers.ConstraintError
+	if errors.As(lastErr, &unsupported) || errors.As(lastErr, &constraint) {
+		h.respondError(w, http.StatusBadRequest, lastErr.Error(), requestID)
+		return
+	}
+	h.alerts.Observe(alerting.Event{Kind: alerting.KindFallbackExhausted, Route: route.Name, Tenant: tenant, Detail: lastErr.Error()})
+	if route.FallbackResponse != nil {
+		resp, err := degradedResponse(*route.FallbackResponse, route.Name, requestID, lastErr)
+		if err == nil {
+			h.usage.Log(ctx, usage.Record{
+				RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name,
+				LatencyMS: int(time.Since(start).Milliseconds()), StatusCode: http.StatusOK,
+				ErrorMessage: "degraded: " + lastErr.Error(),
+			})
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("x-request-id", requestID)
+			w.Header().Set("x-gw-route", route.Name)
+			w.Header().Set("x-gw-degraded", "true")
+			json.NewEncoder(w).Encode(traced(degradedCompletion{resp, true}, rt))
+			return
 		}
+		logError(requestID, "fallback response template failed", err)
 	}
 	h.respondError(w, http.StatusBadGateway, lastErr.Error(), requestID)
 }
 
-func (h *Handler) handleStream(ctx context.Context, w http.ResponseWriter, r *http.Request, p providers.Provider, req providers.ChatRequest, requestID string, route config.Route, target config.Target, tenant, useCase string, attemptNo int) {
-	chunkCh, errCh := p.ChatStream(req)
+// providerRequest builds the upstream request for model from the client's
+// parameters.
+func (req ChatRequest) providerRequest(model string, messages []providers.Message) providers.ChatRequest {
+	return providers.ChatRequest{
+		Model:            model,
+		Messages:         messages,
+		Temperature:      req.Temperature,
+		MaxTokens:        req.MaxTokens,
+		Stream:           req.Stream,
+		TopP:             req.TopP,
+		Stop:             req.Stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		LogitBias:        req.LogitBias,
+		Seed:             req.Seed,
+		N:                req.N,
+		Tools:            req.Tools,
+		ToolChoice:       req.ToolChoice,
+		ReasoningEffort:  req.ReasoningEffort,
+		Thinking:         req.Thinking,
+		Extra:            req.extra,
+	}
+}
 
-	w.Header().Set("Content-Type", "text/event-stream")
+// maskMessages returns a copy of messages with PII masked, and the map to
+// unmask the completion with.
+func (h *Handler) maskMessages(messages []providers.Message) ([]providers.Message, map[string]string) {
+	if h.detector == nil {
+		return messages, nil
+	}
+	unmaskMap := make(map[string]string)
+	masked := make([]providers.Message, len(messages))
+	copy(masked, messages)
+	for i, msg := range masked {
+		content, m := h.detector.Mask(msg.Content)
+		masked[i].Content = content
+		// Merge unmask maps (simplification: assume no token collisions across messages)
+		for k, v := range m {
+			unmaskMap[k] = v
+		}
+	}
+	return masked, unmaskMap
+}
+
+func (h *Handler) handleStream(ctx context.Context, w http.ResponseWriter, r *http.Request, p providers.Provider, req providers.ChatRequest, requestID string, route config.Route, target config.Target, tenant, useCase string, attemptNo int, maxOutput int, wordList *governance.WordList, moderator *governance.Moderator, includeReasoning bool) {
+	// Identical deterministic streams share one upstream call. A resumable
+	// stream is read to the end after its client drops, so its share of a
+	// coalesced one must outlive the request too.
+	var key string
+	if route.Coalesce {
+		key, _ = coalesce.Key(target.Provider, req)
+	}
+	subCtx := ctx
+	if h.streams != nil && !acceptsNDJSON(r) {
+		subCtx = context.WithoutCancel(ctx)
+	}
+	chunkCh, errCh, shared := h.coalesce.Stream(subCtx, key, func() (<-chan providers.ChatChunk, <-chan error) {
+		if route.Continuation != nil {
+			return h.streamContinued(subCtx, p, req, route, target, maxOutput, requestID)
+		}
+		return p.ChatStream(req)
+	})
+	if shared {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("coalesced", true))
+		w.Header().Set("x-gw-coalesced", "true")
+	}
+	if route.Shaping != nil {
+		chunkCh = shaping.Apply(chunkCh, *route.Shaping)
+	}
+
+	// NDJSON clients get one chunk per line and no [DONE] sentinel; the end
+	// of the body marks the end of the stream.
+	ndjson := acceptsNDJSON(r)
+	if ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("x-request-id", requestID)
 	w.Header().Set("x-gw-route", route.Name)
 	w.Header().Set("x-gw-provider", target.Provider)
 	w.Header().Set("x-gw-model", target.Model)
+	setRegion(w, h.region(target.Provider))
 
 	flusher, _ := w.(http.Flusher)
-
 	fullContent := ""
+	reasoning := ""
 	start := time.Now()
+	var ttft time.Duration // until the first chunk
+	meter := h.streamStats.start(target, start)
+	outcome := streamCancelled
+	defer func() {
+		meter.finish(ctx, outcome, usage.ApproximateTokens(fullContent)+usage.ApproximateTokens(reasoning), ttft)
+	}()
+
+	// When resumable streams are enabled every event carries an id and is
+	// buffered, and the stream keeps running after the client drops so that a
+	// reconnect with Last-Event-ID can pick up the rest. NDJSON has no event
+	// ids, so it is never resumable.
+	resumable := h.streams != nil && !ndjson
+	logCtx := r.Context()
+	var resumeToken string
+	if resumable {
+		logCtx = context.WithoutCancel(r.Context())
+		resumeToken = h.streams.Open(requestID, tenant)
+		defer h.streams.Finish(resumeToken)
+	}
+	emit := func(data string) {
+		if ndjson {
+			if data != "[DONE]" {
+				fmt.Fprintf(w, "%s\n", data)
+				flusher.Flush()
+			}
+			return
+		}
+		if resumable {
+			if seq := h.streams.Append(resumeToken, []byte(data)); seq > 0 {
+				fmt.Fprintf(w, "id: %s\n", streambuf.EventID(resumeToken, seq))
+			}
+		}
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+	span := trace.SpanFromContext(ctx)
+	account := h.providerOpts[target.Provider].Account(req.Account)
+	fail := func(err error) {
+		outcome = streamFailed
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		h.traceSnippets(span, req.Messages, fullContent)
+		h.alerts.Observe(alerting.Event{Kind: alerting.KindAttempt, Provider: target.Provider, Tenant: tenant, Failed: true, Detail: err.Error()})
+		h.canary.Observe(ctx, time.Since(start), true)
+		h.recordHealth(ctx, target, time.Since(start), err)
+		h.usage.LogAttempt(logCtx, requestID, usage.Attempt{
+			RequestID: requestID, AttemptNo: attemptNo, Provider: target.Provider, Model: target.Model,
+			LatencyMS: int(time.Since(start).Milliseconds()), TTFTMS: int(ttft.Milliseconds()),
+			StatusCode: http.StatusBadGateway, ErrorMessage: err.Error(),
+			Organization: account.Organization, Project: account.Project,
+		})
+		// Nothing sent yet: the route's fallback content can stand in for
+		// the completion.
+		if fullContent == "" && route.FallbackResponse != nil {
+			if resp, rErr := degradedResponse(*route.FallbackResponse, route.Name, requestID, err); rErr == nil {
+				h.usage.Log(logCtx, usage.Record{
+					RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name,
+					LatencyMS: int(time.Since(start).Milliseconds()), StatusCode: http.StatusOK,
+					ErrorMessage: "degraded: " + err.Error(),
+				})
+				w.Header().Set("x-gw-degraded", "true")
+				chunk := finishChunk(providers.ChatChunk{ID: resp.ID, Object: "chat.completion.chunk", Created: resp.Created, Model: resp.Model}, "stop")
+				chunk.Choices[0].Delta.Content = resp.Choices[0].Message.Content
+				data, _ := json.Marshal(degradedChunk{chunk, true})
+				emit(string(data))
+				emit("[DONE]")
+				return
+			}
+		}
+		// Mid-stream error handling: send error event
+		emit(fmt.Sprintf("{\"error\": {\"message\": %q}}", err.Error()))
+	}
+
+	words := wordList.NewStream()
+	var scan *governance.StreamScanner
+	if route.SecretScan != nil {
+		scan = h.secrets.NewStream()
+	}
+	var mod *governance.StreamModerator
+	if moderator != nil {
+		mod = moderator.NewStream()
+	}
+	var last providers.ChatChunk
+	clientGone := r.Context().Done()
 
 	for {
 		select {
 		case chunk, ok := <-chunkCh:
 			if !ok {
+				// A provider may report an error and close the chunk channel
+				// back to back; don't mistake that for a clean finish.
+				select {
+				case err := <-errCh:
+					if err != nil {
+						fail(err)
+						return
+					}
+				default:
+				}
+				// Release what the word list and moderation windows and the
+				// secret scanner still hold back.
+				var rest string
+				if words != nil {
+					rest = words.Flush()
+					if len(words.Matched) > 0 {
+						h.logWordListMatch(logCtx, requestID, "completion", words.Matched, false)
+					}
+				}
+				if mod != nil {
+					out, _ := mod.Push(rest)
+					rest = out + mod.Flush()
+					if len(mod.Found) > 0 {
+						h.logModeration(logCtx, requestID, mod.Found, false)
+					}
+				}
+				if scan != nil {
+					rest = scan.Push(rest) + scan.Flush()
+					if len(scan.Found) > 0 {
+						h.logSecretLeak(logCtx, requestID, scan.Found, "redact")
+					}
+				}
+				if rest != "" {
+					tail := finishChunk(last, "")
+					tail.Choices[0].Delta.Content = rest
+					data, _ := json.Marshal(tail)
+					emit(string(data))
+				}
+				outcome = streamCompleted
 				// Log final success record for stream
-				h.usage.Log(r.Context(), usage.Record{
+				h.usage.LogAttempt(logCtx, requestID, usage.Attempt{
+					RequestID: requestID, AttemptNo: attemptNo, Provider: target.Provider, Model: target.Model,
+					LatencyMS: int(time.Since(start).Milliseconds()), TTFTMS: int(ttft.Milliseconds()),
+					StatusCode:   http.StatusOK,
+					Organization: account.Organization, Project: account.Project,
+				})
+				completion := usage.ApproximateTokens(fullContent) + usage.ApproximateTokens(reasoning)
+				h.recordSpeed(ctx, target, completion, time.Since(start), ttft)
+				h.usage.Log(logCtx, usage.Record{
 					RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name,
-					Provider: target.Provider, Model: target.Model,
+					Provider: target.Provider, Model: target.Model, Region: h.region(target.Provider),
 					PromptTokens:     usage.ApproximateTokens(fmt.Sprintf("%v", req.Messages)),
-					CompletionTokens: usage.ApproximateTokens(fullContent),
-					TotalTokens:      usage.ApproximateTokens(fmt.Sprintf("%v", req.Messages)) + usage.ApproximateTokens(fullContent),
+					PromptRoles:      promptRoles(req, usage.ApproximateTokens(fmt.Sprintf("%v", req.Messages))),
+					CompletionTokens: completion,
+					TotalTokens:      usage.ApproximateTokens(fmt.Sprintf("%v", req.Messages)) + completion,
+					ReasoningTokens:  usage.ApproximateTokens(reasoning),
 					LatencyMS:        int(time.Since(start).Milliseconds()), StatusCode: http.StatusOK,
 				})
-				fmt.Fprintf(w, "data: [DONE]\n\n")
-				flusher.Flush()
+				emit("[DONE]")
+				h.capturePayload(logCtx, requestID, tenant, req.Messages, fullContent)
+				h.traceSnippets(span, req.Messages, fullContent)
+				h.alerts.Observe(alerting.Event{Kind: alerting.KindAttempt, Provider: target.Provider, Tenant: tenant})
+				h.canary.Observe(ctx, time.Since(start), false)
+				h.recordHealth(ctx, target, time.Since(start), nil)
 				return
 			}
+			meter.chunk(ctx, time.Now())
+			if ttft == 0 {
+				ttft = time.Since(start)
+				h.recordTTFT(ctx, target, ttft)
+			}
 			if len(chunk.Choices) > 0 {
 				fullContent += chunk.Choices[0].Delta.Content
+				reasoning += chunk.Choices[0].Delta.Reasoning
+			}
+			last = chunk
+			if !includeReasoning && !stripChunkReasoning(&chunk) {
+				continue
+			}
+
+			// Tenant word lists: redact the deltas, holding back enough to
+			// catch a word split between them, and stop the stream as soon
+			// as the completion so far hits a block rule.
+			if len(chunk.Choices) > 0 {
+				if res := wordList.Evaluate(fullContent, "completion"); res.Blocked {
+					go func() {
+						for range chunkCh {
+						}
+					}()
+					h.logWordListMatch(logCtx, requestID, "completion", res.Matched, true)
+					h.usage.Log(logCtx, usage.Record{
+						RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name,
+						Provider: target.Provider, Model: target.Model, Region: h.region(target.Provider),
+						LatencyMS: int(time.Since(start).Milliseconds()), StatusCode: http.StatusBadGateway,
+						ErrorMessage: "terminated: tenant word list",
+					})
+					outcome = streamTerminated
+					emit(`{"error": {"message": "response terminated by tenant content policy", "type": "policy_violation"}}`)
+					return
+				}
+				if words != nil {
+					out := words.Push(chunk.Choices[0].Delta.Content)
+					if chunk.Choices[0].FinishReason != "" {
+						out += words.Flush()
+					}
+					chunk.Choices[0].Delta.Content = out
+				}
+			}
+
+			// Moderation: hold back a window of text, and stop the stream as
+			// soon as the window shows disallowed content on terminate routes.
+			if mod != nil && len(chunk.Choices) > 0 {
+				out, violations := mod.Push(chunk.Choices[0].Delta.Content)
+				if len(violations) > 0 && route.Moderation.Action == "terminate" {
+					go func() {
+						for range chunkCh {
+						}
+					}()
+					h.logModeration(logCtx, requestID, violations, true)
+					h.usage.Log(logCtx, usage.Record{
+						RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name,
+						Provider: target.Provider, Model: target.Model, Region: h.region(target.Provider),
+						LatencyMS: int(time.Since(start).Milliseconds()), StatusCode: http.StatusBadGateway,
+						ErrorMessage: "terminated: moderation",
+					})
+					outcome = streamTerminated
+					emit(`{"error": {"message": "response terminated by content moderation", "type": "policy_violation"}}`)
+					return
+				}
+				if chunk.Choices[0].FinishReason != "" {
+					out += mod.Flush()
+				}
+				chunk.Choices[0].Delta.Content = out
+			}
+
+			if scan != nil && len(chunk.Choices) > 0 {
+				out := scan.Push(chunk.Choices[0].Delta.Content)
+				if chunk.Choices[0].FinishReason != "" {
+					out += scan.Flush()
+				}
+				if len(scan.Found) > 0 && route.SecretScan.Action == "block" {
+					go func() {
+						for range chunkCh {
+						}
+					}()
+					h.logSecretLeak(logCtx, requestID, scan.Found, "block")
+					h.usage.Log(logCtx, usage.Record{
+						RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name,
+						Provider: target.Provider, Model: target.Model, Region: h.region(target.Provider),
+						LatencyMS: int(time.Since(start).Milliseconds()), StatusCode: http.StatusBadGateway,
+						ErrorMessage: "terminated: credential in output",
+					})
+					outcome = streamTerminated
+					emit(`{"error": {"message": "response terminated by policy: completion contained a credential", "type": "policy_violation"}}`)
+					return
+				}
+				chunk.Choices[0].Delta.Content = out
 			}
+
 			data, _ := json.Marshal(chunk)
-			fmt.Fprintf(w, "data: %s\n\n", string(data))
-			flusher.Flush()
+			emit(string(data))
+
+			// Cut the stream off once it exceeds the completion budget.
+			if maxOutput > 0 && usage.ApproximateTokens(fullContent) > maxOutput {
+				go func() {
+					for range chunkCh {
+					}
+				}()
+				outcome = streamTruncated
+				data, _ := json.Marshal(finishChunk(chunk, "length"))
+				emit(string(data))
+				completion := usage.ApproximateTokens(fullContent)
+				h.usage.Log(logCtx, usage.Record{
+					RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name,
+					Provider: target.Provider, Model: target.Model, Region: h.region(target.Provider),
+					PromptTokens:     usage.ApproximateTokens(fmt.Sprintf("%v", req.Messages)),
+					PromptRoles:      promptRoles(req, usage.ApproximateTokens(fmt.Sprintf("%v", req.Messages))),
+					CompletionTokens: completion,
+					TotalTokens:      usage.ApproximateTokens(fmt.Sprintf("%v", req.Messages)) + completion,
+					LatencyMS:        int(time.Since(start).Milliseconds()), StatusCode: http.StatusOK,
+					Truncated: true,
+				})
+				emit("[DONE]")
+				return
+			}
 		case err := <-errCh:
 			if err != nil {
-				h.usage.LogAttempt(r.Context(), requestID, usage.Attempt{
-					RequestID: requestID, AttemptNo: attemptNo, Provider: target.Provider, Model: target.Model,
-					StatusCode: http.StatusBadGateway, ErrorMessage: err.Error(),
-				})
-				// Mid-stream error handling: send error event
-				fmt.Fprintf(w, "data: {\"error\": {\"message\": %q}}\n\n", err.Error())
-				flusher.Flush()
+				fail(err)
+				return
+			}
+		case <-clientGone:
+			if !resumable {
 				return
 			}
+			clientGone = nil
+		}
+	}
+}
+
+// resumeStream replays buffered events after seq for a stream and, if the
+// stream is still running, follows it live. It returns false when the stream
+// is no longer buffered or belongs to another tenant.
+func (h *Handler) resumeStream(w http.ResponseWriter, r *http.Request, token, tenant string, seq int) bool {
+	requestID, ok := h.streams.Resume(token, tenant)
+	if !ok {
+		return false
+	}
+	events, done, wait, ok := h.streams.Since(token, seq)
+	if !ok {
+		return false
+	}
+	trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("request_id", requestID))
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("x-request-id", requestID)
+	w.Header().Set("x-gw-resumed-from", strconv.Itoa(seq))
+
+	flusher, _ := w.(http.Flusher)
+	for {
+		for _, data := range events {
+			seq++
+			fmt.Fprintf(w, "id: %s\ndata: %s\n\n", streambuf.EventID(token, seq), data)
+		}
+		flusher.Flush()
+		if done {
+			return true
+		}
+		select {
+		case <-wait:
 		case <-r.Context().Done():
-			return
+			return true
+		}
+		events, done, wait, ok = h.streams.Since(token, seq)
+		if !ok {
+			return true
+		}
+	}
+}
+
+// acceptsNDJSON reports whether the client asked for newline-delimited JSON
+// instead of server-sent events.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if mediaType == "application/x-ndjson" || mediaType == "application/ndjson" {
+			return true
 		}
 	}
+	return false
 }
 
 func (h *Handler) respondError(w http.ResponseWriter, code int, msg string, requestID string) {
@@ -313,6 +1214,108 @@ func getErrorMessage(err error) string {
 	return err.Error()
 }
 
+// errCircuitOpen is the error for a target skipped because its provider's
+// circuit is open.
+func errCircuitOpen(provider string) error {
+	return &providers.StatusError{Provider: provider, Code: http.StatusServiceUnavailable, Message: "circuit open"}
+}
+
+// complete makes a non-streaming call to target, through its streaming API
+// when the route asks for it.
+func (h *Handler) complete(p providers.Provider, req providers.ChatRequest, route config.Route, target config.Target) (*providers.ChatResponse, error) {
+	if route.StreamUpstream == nil {
+		return p.Chat(req)
+	}
+	resp, err := providers.Collect(target.Provider, p, req, route.StreamUpstream.FirstChunk())
+	if err != nil {
+		return nil, err
+	}
+	// Streams report no usage; estimate it as for streamed requests.
+	prompt := usage.ApproximateTokens(fmt.Sprintf("%v", req.Messages))
+	completion := usage.ApproximateTokens(resp.Choices[0].Message.Content)
+	resp.Usage = providers.Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+	return resp, nil
+}
+
+// recordTTFT reports a target's time to first token to metrics and to the
+// health tracker that TTFT SLO routing reads.
+func (h *Handler) recordTTFT(ctx context.Context, target config.Target, ttft time.Duration) {
+	if h.ttft != nil {
+		h.ttft.Record(ctx, float64(ttft)/float64(time.Millisecond), metric.WithAttributes(
+			attribute.String("provider", target.Provider),
+			attribute.String("model", target.Model),
+		))
+	}
+	h.health.RecordTTFT(ctx, target.Provider+"/"+target.Model, ttft)
+}
+
+// orderByTTFT moves targets whose average time to first token misses slo
+// behind the ones meeting it, keeping the configured order otherwise.
+// Targets without samples count as meeting it.
+func (h *Handler) orderByTTFT(ctx context.Context, targets []config.Target, slo time.Duration) []config.Target {
+	var meeting, missing []config.Target
+	for _, t := range targets {
+		if avg, ok := h.health.TTFT(ctx, t.Provider+"/"+t.Model); ok && avg > slo {
+			missing = append(missing, t)
+			continue
+		}
+		meeting = append(meeting, t)
+	}
+	if len(missing) > 0 {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int("ttft_slo_demoted", len(missing)))
+	}
+	return append(meeting, missing...)
+}
+
+// orderByQueue moves targets whose backend has more requests waiting than
+// its provider's max_waiting behind the others, keeping the order
+// otherwise. Backends that report no depth count as not backlogged.
+func (h *Handler) orderByQueue(ctx context.Context, targets []config.Target) []config.Target {
+	var ready, backlogged []config.Target
+	for _, t := range targets {
+		q := h.providerOpts[t.Provider].Queue
+		if q != nil && q.MaxWaiting > 0 {
+			if waiting, ok := h.health.QueueDepth(t.Provider); ok && waiting > float64(q.MaxWaiting) {
+				backlogged = append(backlogged, t)
+				continue
+			}
+		}
+		ready = append(ready, t)
+	}
+	if len(backlogged) == 0 {
+		return targets
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("queue_demoted", len(backlogged)))
+	return append(ready, backlogged...)
+}
+
+// acquireTarget takes one of the target's in-flight slots when it is capped,
+// waiting up to its queue timeout. The returned function frees the slot.
+func (h *Handler) acquireTarget(ctx context.Context, target config.Target) (func(), error) {
+	c := target.Concurrency
+	if c == nil {
+		return func() {}, nil
+	}
+	release, err := h.inflight.Acquire(ctx, target.Provider+"/"+target.Model, c.MaxInFlight, time.Duration(c.QueueTimeoutMS)*time.Millisecond)
+	if err != nil {
+		return nil, &providers.StatusError{Provider: target.Provider, Code: http.StatusServiceUnavailable, Message: err.Error()}
+	}
+	return release, nil
+}
+
+// recordHealth feeds an attempt's outcome to the provider's circuit breaker
+// and the target's rolling stats. Errors that are the client's fault say
+// nothing about the provider and are not recorded.
+func (h *Handler) recordHealth(ctx context.Context, target config.Target, latency time.Duration, err error) {
+	if err != nil && !router.IsRetryable(err) {
+		return
+	}
+	h.providerStats.Record(target.Provider, target.Model, latency, err != nil)
+	if h.health.Record(ctx, target.Provider, latency, err != nil) {
+		h.alerts.Observe(alerting.Event{Kind: alerting.KindCircuitOpen, Provider: target.Provider, Detail: getErrorMessage(err)})
+	}
+}
+
 func logError(requestID, msg string, err error) {
 	println(fmt.Sprintf("[%s] %s: %v", requestID, msg, err))
 }
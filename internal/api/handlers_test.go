@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcceptsNDJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"text/event-stream", false},
+		{"application/x-ndjson", true},
+		{"text/event-stream;q=0.5, application/x-ndjson", true},
+		{"application/ndjson; charset=utf-8", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+		r.Header.Set("Accept", tt.accept)
+		if got := acceptsNDJSON(r); got != tt.want {
+			t.Errorf("Accept %q: expected %v, got %v", tt.accept, tt.want, got)
+		}
+	}
+}
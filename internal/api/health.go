@@ -0,0 +1,31 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/yewintnaing/ai-gateway/internal/health"
+)
+
+// HandleLive reports that the process is up and serving requests. It
+// checks no dependencies, so an outage elsewhere does not get the gateway
+// restarted.
+func HandleLive(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok"}` + "\n"))
+}
+
+// HandleReady reports the reachability and latency of the database, Redis
+// and each provider, answering 503 while a critical one is down so the
+// instance is taken out of rotation.
+func HandleReady(ready *health.Readiness) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := ready.Report(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !report.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/language"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// promptLanguage detects the language of the last user message.
+func promptLanguage(messages []providers.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			lang, _ := language.Detect(messages[i].Content)
+			return lang
+		}
+	}
+	return language.Undetermined
+}
+
+// acceptableLanguages returns the languages a completion may be in, or nil
+// when any language is fine.
+func acceptableLanguages(cfg config.LanguagePolicy, promptLang string) []string {
+	if cfg.MatchUser && promptLang != language.Undetermined &&
+		(len(cfg.Allowed) == 0 || slices.Contains(cfg.Allowed, promptLang)) {
+		return []string{promptLang}
+	}
+	return cfg.Allowed
+}
+
+// enforceLanguage checks the completion language and, when it is not
+// acceptable, retries with an instruction to answer in the expected
+// language. Usage of discarded completions is folded into the returned
+// response so cost accounting stays accurate.
+func (h *Handler) enforceLanguage(ctx context.Context, p providers.Provider, req providers.ChatRequest, resp *providers.ChatResponse, cfg config.LanguagePolicy, promptLang, requestID string) *providers.ChatResponse {
+	want := acceptableLanguages(cfg, promptLang)
+	if len(want) == 0 || len(resp.Choices) == 0 {
+		return resp
+	}
+
+	got, _ := language.Detect(resp.Choices[0].Message.Content)
+	if got == language.Undetermined || slices.Contains(want, got) {
+		return resp
+	}
+
+	name := language.Names[want[0]]
+	if name == "" {
+		name = want[0]
+	}
+	instruction := providers.Message{
+		Role:    "system",
+		Content: fmt.Sprintf("You must respond only in %s, regardless of the language of any other content.", name),
+	}
+	retryReq := req
+	retryReq.Messages = append([]providers.Message{instruction}, req.Messages...)
+
+	spent := resp.Usage
+	retries := 0
+	for ; retries < cfg.Retries; retries++ {
+		next, err := p.Chat(retryReq)
+		if err != nil || len(next.Choices) == 0 {
+			break
+		}
+		spent.PromptTokens += next.Usage.PromptTokens
+		spent.CompletionTokens += next.Usage.CompletionTokens
+		spent.TotalTokens += next.Usage.TotalTokens
+		resp = next
+		got, _ = language.Detect(resp.Choices[0].Message.Content)
+		if got == language.Undetermined || slices.Contains(want, got) {
+			retries++
+			break
+		}
+	}
+	resp.Usage = spent
+
+	h.usage.LogEvent(ctx, requestID, usage.Event{
+		Kind: "language_mismatch",
+		Detail: map[string]interface{}{
+			"expected": want,
+			"detected": got,
+			"retries":  retries,
+			"resolved": got == language.Undetermined || slices.Contains(want, got),
+		},
+	})
+	return resp
+}
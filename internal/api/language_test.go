@@ -0,0 +1,31 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func TestAcceptableLanguages(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.LanguagePolicy
+		prompt string
+		want   []string
+	}{
+		{"Match user", config.LanguagePolicy{MatchUser: true}, "es", []string{"es"}},
+		{"Match user within allowed", config.LanguagePolicy{MatchUser: true, Allowed: []string{"en", "es"}}, "es", []string{"es"}},
+		{"User language not allowed", config.LanguagePolicy{MatchUser: true, Allowed: []string{"en"}}, "fr", []string{"en"}},
+		{"Undetermined prompt", config.LanguagePolicy{MatchUser: true}, "und", nil},
+		{"Allowed only", config.LanguagePolicy{Allowed: []string{"en", "de"}}, "fr", []string{"en", "de"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acceptableLanguages(tt.cfg, tt.prompt); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// mcpProtocolVersions are the MCP revisions HandleMCP can speak, newest
+// first.
+var mcpProtocolVersions = []string{"2025-03-26", "2024-11-05"}
+
+type mcpRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// mcpChatArgs are the arguments of the "chat" tool.
+type mcpChatArgs struct {
+	Prompt      string                 `json:"prompt"`
+	System      string                 `json:"system"`
+	Messages    []providers.Message    `json:"messages"`
+	UseCase     string                 `json:"use_case"`
+	MaxTokens   int                    `json:"max_tokens"`
+	Temperature *float64               `json:"temperature"`
+	Metadata    map[string]interface{} `json:"metadata"`
+}
+
+// HandleMCP serves the gateway as an MCP server over streamable HTTP. It
+// offers a single "chat" tool that runs through HandleChat, so routing,
+// budgets and guardrails apply as for any other client. Default tenant and
+// use case can be set on the endpoint URL (?tenant=acme&use_case=code_review)
+// for clients that can only be given a URL.
+func (h *Handler) HandleMCP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		// No server-initiated messages, so no SSE stream to offer.
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req mcpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeMCP(w, nil, nil, &mcpError{Code: -32700, Message: "parse error"})
+		return
+	}
+	if len(req.ID) == 0 {
+		// Notifications need no answer.
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		version := mcpProtocolVersions[0]
+		if slices.Contains(mcpProtocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		writeMCP(w, req.ID, map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": "ai-gateway", "version": "1.0"},
+		}, nil)
+
+	case "ping":
+		writeMCP(w, req.ID, map[string]interface{}{}, nil)
+
+	case "tools/list":
+		writeMCP(w, req.ID, map[string]interface{}{"tools": []interface{}{h.mcpChatTool()}}, nil)
+
+	case "tools/call":
+		var params struct {
+			Name      string      `json:"name"`
+			Arguments mcpChatArgs `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.Name != "chat" {
+			writeMCP(w, req.ID, nil, &mcpError{Code: -32602, Message: "unknown tool or invalid arguments"})
+			return
+		}
+		writeMCP(w, req.ID, h.mcpChat(r, params.Arguments), nil)
+
+	default:
+		writeMCP(w, req.ID, nil, &mcpError{Code: -32601, Message: "method not found: " + req.Method})
+	}
+}
+
+func (h *Handler) mcpChatTool() map[string]interface{} {
+	useCase := map[string]interface{}{"type": "string", "description": "Gateway route to use"}
+	if cases := h.router.UseCases(); len(cases) > 0 {
+		useCase["enum"] = cases
+	}
+	return map[string]interface{}{
+		"name":        "chat",
+		"description": "Get a completion from a gateway-managed model. Give either prompt or messages.",
+		"inputSchema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"prompt":   map[string]interface{}{"type": "string", "description": "User message"},
+				"system":   map[string]interface{}{"type": "string", "description": "Optional system message"},
+				"use_case": useCase,
+				"messages": map[string]interface{}{
+					"type":        "array",
+					"description": "Full conversation, as OpenAI chat messages",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"role":    map[string]interface{}{"type": "string"},
+							"content": map[string]interface{}{"type": "string"},
+						},
+						"required": []string{"role", "content"},
+					},
+				},
+				"max_tokens":  map[string]interface{}{"type": "integer"},
+				"temperature": map[string]interface{}{"type": "number"},
+				"metadata":    map[string]interface{}{"type": "object", "description": "Request metadata, e.g. tenant"},
+			},
+		},
+	}
+}
+
+// mcpChat runs a chat tool call through HandleChat and turns the outcome
+// into an MCP tool result.
+func (h *Handler) mcpChat(r *http.Request, args mcpChatArgs) map[string]interface{} {
+	messages := args.Messages
+	if len(messages) == 0 {
+		if args.System != "" {
+			messages = append(messages, providers.Message{Role: "system", Content: args.System})
+		}
+		messages = append(messages, providers.Message{Role: "user", Content: args.Prompt})
+	}
+
+	metadata := map[string]interface{}{}
+	for k, v := range args.Metadata {
+		metadata[k] = v
+	}
+	if _, ok := metadata["tenant"]; !ok && r.URL.Query().Get("tenant") != "" {
+		metadata["tenant"] = r.URL.Query().Get("tenant")
+	}
+	if args.UseCase != "" {
+		metadata["use_case"] = args.UseCase
+	} else if _, ok := metadata["use_case"]; !ok && r.URL.Query().Get("use_case") != "" {
+		metadata["use_case"] = r.URL.Query().Get("use_case")
+	}
+
+	body, _ := json.Marshal(ChatRequest{
+		Messages: messages, MaxTokens: args.MaxTokens, Temperature: args.Temperature, Metadata: metadata,
+	})
+	inner, _ := http.NewRequestWithContext(r.Context(), http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	inner.Header.Set("Content-Type", "application/json")
+	if id := r.Header.Get("x-request-id"); id != "" {
+		inner.Header.Set("x-request-id", id)
+	}
+
+	rec := newBufferedResponse()
+	h.HandleChat(rec, inner)
+
+	if rec.status != http.StatusOK {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(rec.body.Bytes(), &failure)
+		return map[string]interface{}{
+			"content": []map[string]string{{"type": "text", "text": failure.Error.Message}},
+			"isError": true,
+		}
+	}
+
+	var resp providers.ChatResponse
+	json.Unmarshal(rec.body.Bytes(), &resp)
+	text := ""
+	if len(resp.Choices) > 0 {
+		text = resp.Choices[0].Message.Content
+	}
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"_meta": map[string]interface{}{
+			"request_id": rec.Header().Get("x-request-id"),
+			"route":      rec.Header().Get("x-gw-route"),
+			"provider":   rec.Header().Get("x-gw-provider"),
+			"model":      rec.Header().Get("x-gw-model"),
+			"usage":      resp.Usage,
+		},
+	}
+}
+
+func writeMCP(w http.ResponseWriter, id json.RawMessage, result interface{}, rpcErr *mcpError) {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if rpcErr != nil {
+		msg["error"] = rpcErr
+	} else {
+		msg["result"] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
+
+// bufferedResponse captures a handler's response in memory.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(code int)        { b.status = code }
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/router"
+)
+
+func mcpCall(t *testing.T, h *Handler, body string) (int, map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.HandleMCP(w, httptest.NewRequest("POST", "/mcp", strings.NewReader(body)))
+	var out map[string]interface{}
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatalf("invalid JSON response: %s", w.Body)
+		}
+	}
+	return w.Code, out
+}
+
+func TestHandleMCP(t *testing.T) {
+	h := &Handler{router: router.NewRouter([]config.Route{{Name: "code", Match: config.Match{UseCase: "code_review"}}})}
+
+	_, out := mcpCall(t, h, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`)
+	result := out["result"].(map[string]interface{})
+	if result["protocolVersion"] != "2024-11-05" {
+		t.Errorf("expected the client's supported version to be echoed, got %v", result["protocolVersion"])
+	}
+
+	if code, _ := mcpCall(t, h, `{"jsonrpc":"2.0","method":"notifications/initialized"}`); code != http.StatusAccepted {
+		t.Errorf("expected 202 for a notification, got %d", code)
+	}
+
+	_, out = mcpCall(t, h, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	tools := out["result"].(map[string]interface{})["tools"].([]interface{})
+	chat := tools[0].(map[string]interface{})
+	useCase := chat["inputSchema"].(map[string]interface{})["properties"].(map[string]interface{})["use_case"].(map[string]interface{})
+	if chat["name"] != "chat" || useCase["enum"].([]interface{})[0] != "code_review" {
+		t.Errorf("unexpected tool list %v", tools)
+	}
+
+	_, out = mcpCall(t, h, `{"jsonrpc":"2.0","id":3,"method":"resources/list"}`)
+	if out["error"].(map[string]interface{})["code"].(float64) != -32601 {
+		t.Errorf("expected method not found, got %v", out)
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+type tenantKey struct{}
+
+// RequireTenantKey authenticates the /v1/me endpoints with a tenant API key
+// sent as "Authorization: Bearer <key>", scoping them to its tenant.
+func (h *Handler) RequireTenantKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || secret == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			h.respondError(w, http.StatusUnauthorized, "missing API key", "")
+			return
+		}
+		key, err := h.usage.AuthenticateAPIKey(r.Context(), secret)
+		if errors.Is(err, usage.ErrInvalidKey) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			h.respondError(w, http.StatusUnauthorized, "invalid API key", "")
+			return
+		}
+		if err != nil {
+			logError("", "failed to authenticate API key", err)
+			h.respondError(w, http.StatusInternalServerError, "failed to authenticate", "")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(contextWithTenant(r, key.Tenant)))
+	})
+}
+
+func contextWithTenant(r *http.Request, tenant string) context.Context {
+	return context.WithValue(r.Context(), tenantKey{}, tenant)
+}
+
+func requestTenant(r *http.Request) string {
+	tenant, _ := r.Context().Value(tenantKey{}).(string)
+	return tenant
+}
+
+// HandleMyUsage is GET /v1/usage restricted to the caller's tenant.
+func (h *Handler) HandleMyUsage(w http.ResponseWriter, r *http.Request) {
+	q, err := parseUsageQuery(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+	q.Tenant = requestTenant(r)
+	if q.GroupBy == "tenant" {
+		q.GroupBy = ""
+	}
+
+	rows, err := h.usage.QueryUsage(r.Context(), q)
+	if err != nil {
+		logError("", "failed to query usage", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to query usage", "")
+		return
+	}
+	if rows == nil {
+		rows = []usage.UsageRow{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tenant":   q.Tenant,
+		"group_by": q.GroupBy,
+		"rows":     rows,
+	})
+}
+
+// TenantLimits is the response of GET /v1/me/limits.
+type TenantLimits struct {
+	Tenant string `json:"tenant"`
+	// TokensPerMinute is zero when rate limiting is off.
+	TokensPerMinute int `json:"tokens_per_minute"`
+	RemainingTokens int `json:"remaining_tokens"`
+	ResetSeconds    int `json:"reset_seconds"`
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"`
+	// Exempt is set while the tenant is exempt from the rate limit.
+	Exempt bool `json:"exempt,omitempty"`
+	// BurstCredit is what is left of the tenant's burst credit pool, if it
+	// has one.
+	BurstCredit *int `json:"burst_credit,omitempty"`
+}
+
+// HandleMyLimits reports the caller's budget and what is left of it in the
+// current window.
+func (h *Handler) HandleMyLimits(w http.ResponseWriter, r *http.Request) {
+	tenant := requestTenant(r)
+	// Charging nothing reads the window without using any of it.
+	quota, err := h.limiter.Check(r.Context(), tenant, 0)
+	if err != nil {
+		logError("", "failed to read rate limit", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to read limits", "")
+		return
+	}
+	limits := TenantLimits{
+		Tenant:          tenant,
+		TokensPerMinute: quota.Limit,
+		RemainingTokens: quota.Remaining,
+		ResetSeconds:    int(quota.Reset.Seconds() + 0.999),
+		MaxOutputTokens: h.tenants[tenant].MaxOutputTokens,
+		Exempt:          quota.Exempt,
+	}
+	if balance, ok, err := h.limiter.BurstBalance(r.Context(), tenant); err != nil {
+		logError("", "failed to read burst credit", err)
+	} else if ok {
+		limits.BurstCredit = &balance
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(limits)
+}
+
+// HandleMyKeys lists the caller's API keys, without their secrets.
+func (h *Handler) HandleMyKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.usage.ListAPIKeys(r.Context(), requestTenant(r))
+	if err != nil {
+		logError("", "failed to list API keys", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to list API keys", "")
+		return
+	}
+	if keys == nil {
+		keys = []usage.APIKey{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
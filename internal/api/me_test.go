@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func TestRequireTenantKeyRejectsMissingKey(t *testing.T) {
+	h := &Handler{}
+	called := false
+	handler := h.RequireTenantKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	for _, auth := range []string{"", "Basic dXNlcjpwYXNz", "Bearer "} {
+		r := httptest.NewRequest("GET", "/v1/me/usage", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("Authorization %q: expected 401 with a Bearer challenge, got %d", auth, w.Code)
+		}
+	}
+	if called {
+		t.Error("expected the endpoint not to run without a key")
+	}
+}
+
+func TestHandleMyLimitsWithoutRateLimiting(t *testing.T) {
+	h := &Handler{tenants: map[string]config.Tenant{"acme": {Name: "acme", MaxOutputTokens: 2048}}}
+	r := httptest.NewRequest("GET", "/v1/me/limits", nil)
+	r = r.WithContext(contextWithTenant(r, "acme"))
+	w := httptest.NewRecorder()
+	h.HandleMyLimits(w, r)
+
+	var got TenantLimits
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Tenant != "acme" || got.MaxOutputTokens != 2048 || got.TokensPerMinute != 0 {
+		t.Errorf("unexpected limits %+v", got)
+	}
+}
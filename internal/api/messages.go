@@ -0,0 +1,510 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// messagesRequest is an inbound Anthropic Messages API request.
+type messagesRequest struct {
+	Model         string                 `json:"model"`
+	System        json.RawMessage        `json:"system"`
+	Messages      []anthropicMessage     `json:"messages"`
+	MaxTokens     int                    `json:"max_tokens"`
+	Temperature   *float64               `json:"temperature"`
+	TopP          *float64               `json:"top_p"`
+	StopSequences []string               `json:"stop_sequences"`
+	Stream        bool                   `json:"stream"`
+	Tools         []anthropicTool        `json:"tools"`
+	ToolChoice    *anthropicToolChoice   `json:"tool_choice"`
+	Metadata      map[string]interface{} `json:"metadata"`
+	Thinking      *providers.Thinking    `json:"thinking"`
+}
+
+type anthropicMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	Thinking  string          `json:"thinking,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// HandleMessages serves the Anthropic Messages API on top of HandleChat, so
+// clients built for Anthropic can be routed to any provider. Tenant and use
+// case come from metadata as on /v1/chat/completions, or from the
+// x-gw-tenant and x-gw-use-case headers for SDKs that only send user_id.
+func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
+	var in messagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req, err := in.toChatRequest()
+	if err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Metadata == nil {
+		req.Metadata = map[string]interface{}{}
+	}
+	for key, header := range map[string]string{"tenant": "x-gw-tenant", "use_case": "x-gw-use-case"} {
+		if _, ok := req.Metadata[key]; !ok && r.Header.Get(header) != "" {
+			req.Metadata[key] = r.Header.Get(header)
+		}
+	}
+
+	body, _ := json.Marshal(req)
+	inner, _ := http.NewRequestWithContext(r.Context(), http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	inner.Header = r.Header.Clone()
+	inner.Header.Set("Content-Type", "application/json")
+	inner.Header.Del("Last-Event-ID")
+
+	if in.Stream {
+		// NDJSON keeps the inner stream free of SSE framing and [DONE].
+		inner.Header.Set("Accept", "application/x-ndjson")
+		sw := newAnthropicStream(w)
+		h.HandleChat(sw, inner)
+		sw.Close()
+		return
+	}
+
+	rec := newBufferedResponse()
+	h.HandleChat(rec, inner)
+	for k, v := range rec.Header() {
+		if k != "Content-Length" {
+			w.Header()[k] = v
+		}
+	}
+	if rec.status != http.StatusOK {
+		writeAnthropicError(w, rec.status, errorMessage(rec.body.Bytes()))
+		return
+	}
+
+	var resp providers.ChatResponse
+	if err := json.Unmarshal(rec.body.Bytes(), &resp); err != nil || len(resp.Choices) == 0 {
+		writeAnthropicError(w, http.StatusBadGateway, "upstream returned no completion")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toAnthropicMessage(&resp))
+}
+
+// toChatRequest converts an Anthropic request into the gateway's OpenAI
+// shape: the system prompt becomes a system message, tool_use blocks become
+// tool calls and tool_result blocks become tool messages. Enabling thinking
+// also returns it, as the Messages API does; thinking blocks sent back
+// become the assistant message's reasoning.
+func (m messagesRequest) toChatRequest() (ChatRequest, error) {
+	req := ChatRequest{
+		Model:       m.Model,
+		MaxTokens:   m.MaxTokens,
+		Temperature: m.Temperature,
+		TopP:        m.TopP,
+		Stop:        m.StopSequences,
+		Stream:      m.Stream,
+		Metadata:    m.Metadata,
+		Thinking:    m.Thinking,
+	}
+	req.IncludeReasoning = m.Thinking != nil && m.Thinking.Type == "enabled"
+
+	if len(m.System) > 0 && string(m.System) != "null" {
+		system, err := blockText(m.System)
+		if err != nil {
+			return req, fmt.Errorf("system: %w", err)
+		}
+		req.Messages = append(req.Messages, providers.Message{Role: "system", Content: system})
+	}
+
+	for i, msg := range m.Messages {
+		var text string
+		if err := json.Unmarshal(msg.Content, &text); err == nil {
+			req.Messages = append(req.Messages, providers.Message{Role: msg.Role, Content: text})
+			continue
+		}
+
+		var blocks []anthropicBlock
+		if err := json.Unmarshal(msg.Content, &blocks); err != nil {
+			return req, fmt.Errorf("messages[%d]: content must be a string or an array of content blocks", i)
+		}
+		out := providers.Message{Role: msg.Role}
+		var parts []string
+		for _, b := range blocks {
+			switch b.Type {
+			case "text":
+				parts = append(parts, b.Text)
+			case "thinking":
+				out.Reasoning, out.ReasoningSignature = b.Thinking, b.Signature
+			case "redacted_thinking":
+				// Encrypted; only Anthropic itself can use it, and the
+				// model continues without it.
+			case "tool_use":
+				args := string(b.Input)
+				if args == "" {
+					args = "{}"
+				}
+				out.ToolCalls = append(out.ToolCalls, providers.ToolCall{
+					ID: b.ID, Type: "function", Function: providers.ToolCallFunction{Name: b.Name, Arguments: args},
+				})
+			case "tool_result":
+				result, err := blockText(b.Content)
+				if err != nil {
+					return req, fmt.Errorf("messages[%d]: tool_result: %w", i, err)
+				}
+				req.Messages = append(req.Messages, providers.Message{Role: "tool", ToolCallID: b.ToolUseID, Content: result})
+			default:
+				return req, fmt.Errorf("messages[%d]: content block type %q is not supported", i, b.Type)
+			}
+		}
+		out.Content = strings.Join(parts, "")
+		if out.Content != "" || len(out.ToolCalls) > 0 {
+			req.Messages = append(req.Messages, out)
+		}
+	}
+
+	for _, t := range m.Tools {
+		req.Tools = append(req.Tools, providers.Tool{
+			Type:     "function",
+			Function: providers.ToolFunction{Name: t.Name, Description: t.Description, Parameters: t.InputSchema},
+		})
+	}
+	if m.ToolChoice != nil {
+		var choice interface{}
+		switch m.ToolChoice.Type {
+		case "auto", "none":
+			choice = m.ToolChoice.Type
+		case "any":
+			choice = "required"
+		case "tool":
+			choice = map[string]interface{}{"type": "function", "function": map[string]string{"name": m.ToolChoice.Name}}
+		default:
+			return req, fmt.Errorf("tool_choice type %q is not supported", m.ToolChoice.Type)
+		}
+		req.ToolChoice, _ = json.Marshal(choice)
+	}
+
+	return req, nil
+}
+
+// blockText reads content that is either a string or a list of text blocks.
+func blockText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var blocks []anthropicBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return "", fmt.Errorf("content must be a string or an array of text blocks")
+	}
+	var parts []string
+	for _, b := range blocks {
+		if b.Type != "text" {
+			return "", fmt.Errorf("content block type %q is not supported", b.Type)
+		}
+		parts = append(parts, b.Text)
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// stopReason maps an OpenAI finish_reason onto Anthropic's stop_reason.
+func stopReason(finishReason string) string {
+	switch finishReason {
+	case "stop":
+		return "end_turn"
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "content_filter":
+		return "refusal"
+	}
+	return finishReason
+}
+
+// toAnthropicMessage converts a completion into a Messages API reply.
+func toAnthropicMessage(resp *providers.ChatResponse) map[string]interface{} {
+	choice := resp.Choices[0]
+	blocks := []anthropicBlock{}
+	if choice.Message.Reasoning != "" {
+		blocks = append(blocks, anthropicBlock{Type: "thinking", Thinking: choice.Message.Reasoning, Signature: choice.Message.ReasoningSignature})
+	}
+	if choice.Message.Content != "" {
+		blocks = append(blocks, anthropicBlock{Type: "text", Text: choice.Message.Content})
+	}
+	for _, tc := range choice.Message.ToolCalls {
+		input := json.RawMessage(tc.Function.Arguments)
+		if !json.Valid(input) {
+			input = json.RawMessage("{}")
+		}
+		blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+	}
+	return map[string]interface{}{
+		"id":            resp.ID,
+		"type":          "message",
+		"role":          "assistant",
+		"model":         resp.Model,
+		"content":       blocks,
+		"stop_reason":   stopReason(choice.FinishReason),
+		"stop_sequence": nil,
+		"usage": map[string]int{
+			"input_tokens":  resp.Usage.PromptTokens,
+			"output_tokens": resp.Usage.CompletionTokens,
+		},
+	}
+}
+
+// anthropicErrorTypes maps gateway status codes onto Anthropic error types.
+var anthropicErrorTypes = map[int]string{
+	http.StatusBadRequest:      "invalid_request_error",
+	http.StatusUnauthorized:    "authentication_error",
+	http.StatusForbidden:       "permission_error",
+	http.StatusNotFound:        "not_found_error",
+	http.StatusTooManyRequests: "rate_limit_error",
+}
+
+func anthropicError(code int, msg string) map[string]interface{} {
+	kind, ok := anthropicErrorTypes[code]
+	if !ok {
+		kind = "api_error"
+	}
+	return map[string]interface{}{
+		"type":  "error",
+		"error": map[string]string{"type": kind, "message": msg},
+	}
+}
+
+func writeAnthropicError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(anthropicError(code, msg))
+}
+
+// errorMessage pulls the message out of a gateway error body.
+func errorMessage(body []byte) string {
+	var failure struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(body, &failure)
+	return failure.Error.Message
+}
+
+// anthropicStream is a ResponseWriter that turns HandleChat's NDJSON chunk
+// stream into Messages API server-sent events.
+type anthropicStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	status  int
+	line    []byte
+	failure bytes.Buffer
+
+	wrote     bool
+	started   bool
+	ended     bool
+	block     int    // index of the open content block, or -1
+	blockType string // type of the open content block
+	blocks    int    // content blocks started so far
+	tools     map[int]int
+	stop      string
+	text      string
+}
+
+func newAnthropicStream(w http.ResponseWriter) *anthropicStream {
+	flusher, _ := w.(http.Flusher)
+	return &anthropicStream{w: w, flusher: flusher, status: http.StatusOK, block: -1, tools: map[int]int{}}
+}
+
+func (s *anthropicStream) Header() http.Header { return s.w.Header() }
+
+func (s *anthropicStream) WriteHeader(code int) { s.status = code }
+
+func (s *anthropicStream) Flush() {
+	if s.flusher != nil && s.status == http.StatusOK {
+		s.flusher.Flush()
+	}
+}
+
+func (s *anthropicStream) Write(p []byte) (int, error) {
+	if s.status != http.StatusOK {
+		// Rejected before streaming: keep the body to convert in Close.
+		return s.failure.Write(p)
+	}
+	s.line = append(s.line, p...)
+	for {
+		i := bytes.IndexByte(s.line, '\n')
+		if i < 0 {
+			break
+		}
+		s.handle(s.line[:i])
+		s.line = s.line[i+1:]
+	}
+	return len(p), nil
+}
+
+func (s *anthropicStream) handle(line []byte) {
+	if len(bytes.TrimSpace(line)) == 0 || s.ended {
+		return
+	}
+	var chunk struct {
+		providers.ChatChunk
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(line, &chunk); err != nil {
+		return
+	}
+	if chunk.Error != nil {
+		s.event("error", anthropicError(http.StatusBadGateway, chunk.Error.Message))
+		s.ended = true
+		return
+	}
+
+	if !s.started {
+		s.started = true
+		id := chunk.ID
+		if id == "" {
+			id = "msg_" + uuid.New().String()
+		}
+		s.event("message_start", map[string]interface{}{
+			"type": "message_start",
+			"message": map[string]interface{}{
+				"id": id, "type": "message", "role": "assistant", "model": chunk.Model,
+				"content": []interface{}{}, "stop_reason": nil, "stop_sequence": nil,
+				"usage": map[string]int{"input_tokens": 0, "output_tokens": 0},
+			},
+		})
+	}
+	if len(chunk.Choices) == 0 {
+		return
+	}
+
+	delta := chunk.Choices[0].Delta
+	if delta.Reasoning != "" {
+		if s.blockType != "thinking" {
+			s.open("thinking", map[string]interface{}{"type": "thinking", "thinking": ""})
+		}
+		s.text += delta.Reasoning
+		s.event("content_block_delta", map[string]interface{}{
+			"type": "content_block_delta", "index": s.block,
+			"delta": map[string]string{"type": "thinking_delta", "thinking": delta.Reasoning},
+		})
+	}
+	if delta.ReasoningSignature != "" && s.blockType == "thinking" {
+		s.event("content_block_delta", map[string]interface{}{
+			"type": "content_block_delta", "index": s.block,
+			"delta": map[string]string{"type": "signature_delta", "signature": delta.ReasoningSignature},
+		})
+	}
+	if delta.Content != "" {
+		if s.blockType != "text" {
+			s.open("text", map[string]interface{}{"type": "text", "text": ""})
+		}
+		s.text += delta.Content
+		s.event("content_block_delta", map[string]interface{}{
+			"type": "content_block_delta", "index": s.block,
+			"delta": map[string]string{"type": "text_delta", "text": delta.Content},
+		})
+	}
+	for _, tc := range delta.ToolCalls {
+		if _, ok := s.tools[tc.Index]; !ok || tc.ID != "" {
+			s.open("tool_use", map[string]interface{}{
+				"type": "tool_use", "id": tc.ID, "name": tc.Function.Name, "input": map[string]interface{}{},
+			})
+			s.tools[tc.Index] = s.block
+		}
+		if tc.Function.Arguments != "" {
+			s.event("content_block_delta", map[string]interface{}{
+				"type": "content_block_delta", "index": s.tools[tc.Index],
+				"delta": map[string]string{"type": "input_json_delta", "partial_json": tc.Function.Arguments},
+			})
+		}
+	}
+	if reason := chunk.Choices[0].FinishReason; reason != "" {
+		s.stop = stopReason(reason)
+	}
+}
+
+// open closes the current content block and starts a new one.
+func (s *anthropicStream) open(kind string, block map[string]interface{}) {
+	s.closeBlock()
+	s.block, s.blockType = s.blocks, kind
+	s.blocks++
+	s.event("content_block_start", map[string]interface{}{"type": "content_block_start", "index": s.block, "content_block": block})
+}
+
+func (s *anthropicStream) closeBlock() {
+	if s.block >= 0 {
+		s.event("content_block_stop", map[string]interface{}{"type": "content_block_stop", "index": s.block})
+		s.block, s.blockType = -1, ""
+	}
+}
+
+// Close finishes the message once HandleChat has returned, or converts an
+// error response that was produced before any streaming began.
+func (s *anthropicStream) Close() {
+	if s.status != http.StatusOK {
+		writeAnthropicError(s.w, s.status, errorMessage(s.failure.Bytes()))
+		return
+	}
+	if len(s.line) > 0 {
+		s.handle(s.line)
+		s.line = nil
+	}
+	if s.ended || !s.started {
+		return
+	}
+	s.closeBlock()
+	if s.stop == "" {
+		s.stop = "end_turn"
+	}
+	s.event("message_delta", map[string]interface{}{
+		"type":  "message_delta",
+		"delta": map[string]interface{}{"stop_reason": s.stop, "stop_sequence": nil},
+		"usage": map[string]int{"output_tokens": usage.ApproximateTokens(s.text)},
+	})
+	s.event("message_stop", map[string]interface{}{"type": "message_stop"})
+	s.ended = true
+}
+
+func (s *anthropicStream) event(name string, data interface{}) {
+	if !s.wrote {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.wrote = true
+	}
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, payload)
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestMessagesRequestToChatRequest(t *testing.T) {
+	var in messagesRequest
+	body := `{
+		"model": "claude-3-5-sonnet",
+		"system": [{"type": "text", "text": "Be terse."}],
+		"max_tokens": 100,
+		"stop_sequences": ["END"],
+		"messages": [
+			{"role": "user", "content": "Weather in Paris?"},
+			{"role": "assistant", "content": [
+				{"type": "text", "text": "Checking."},
+				{"type": "tool_use", "id": "toolu_1", "name": "weather", "input": {"city": "Paris"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": "18C"},
+				{"type": "text", "text": "Thanks"}
+			]}
+		],
+		"tools": [{"name": "weather", "input_schema": {"type": "object"}}],
+		"tool_choice": {"type": "any"},
+		"metadata": {"tenant": "acme"}
+	}`
+	if err := json.Unmarshal([]byte(body), &in); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := in.toChatRequest()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []providers.Message{
+		{Role: "system", Content: "Be terse."},
+		{Role: "user", Content: "Weather in Paris?"},
+		{Role: "assistant", Content: "Checking.", ToolCalls: []providers.ToolCall{
+			{ID: "toolu_1", Type: "function", Function: providers.ToolCallFunction{Name: "weather", Arguments: `{"city": "Paris"}`}},
+		}},
+		{Role: "tool", ToolCallID: "toolu_1", Content: "18C"},
+		{Role: "user", Content: "Thanks"},
+	}
+	got, _ := json.Marshal(req.Messages)
+	expected, _ := json.Marshal(want)
+	if string(got) != string(expected) {
+		t.Errorf("unexpected messages:\n got %s\nwant %s", got, expected)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Function.Name != "weather" || string(req.ToolChoice) != `"required"` {
+		t.Errorf("unexpected tools %+v / tool_choice %s", req.Tools, req.ToolChoice)
+	}
+	if len(req.Stop) != 1 || req.Metadata["tenant"] != "acme" {
+		t.Errorf("expected stop sequences and metadata to carry over, got %v %v", req.Stop, req.Metadata)
+	}
+
+	in.Messages = []anthropicMessage{{Role: "user", Content: json.RawMessage(`[{"type":"image"}]`)}}
+	if _, err := in.toChatRequest(); err == nil {
+		t.Error("expected unsupported content blocks to be rejected")
+	}
+}
+
+func TestToAnthropicMessage(t *testing.T) {
+	resp := &providers.ChatResponse{ID: "chatcmpl-1", Model: "gpt-4o", Usage: providers.Usage{PromptTokens: 7, CompletionTokens: 3}}
+	resp.Choices = make([]struct {
+		Index        int               `json:"index"`
+		Message      providers.Message `json:"message"`
+		FinishReason string            `json:"finish_reason"`
+	}, 1)
+	resp.Choices[0].Message.ToolCalls = []providers.ToolCall{
+		{ID: "call_1", Type: "function", Function: providers.ToolCallFunction{Name: "weather", Arguments: `{"city":"Paris"}`}},
+	}
+	resp.Choices[0].FinishReason = "tool_calls"
+
+	data, _ := json.Marshal(toAnthropicMessage(resp))
+	var out struct {
+		Type       string           `json:"type"`
+		StopReason string           `json:"stop_reason"`
+		Content    []anthropicBlock `json:"content"`
+		Usage      map[string]int   `json:"usage"`
+	}
+	json.Unmarshal(data, &out)
+	if out.Type != "message" || out.StopReason != "tool_use" || out.Usage["input_tokens"] != 7 {
+		t.Errorf("unexpected message %s", data)
+	}
+	if len(out.Content) != 1 || out.Content[0].Type != "tool_use" || string(out.Content[0].Input) != `{"city":"Paris"}` {
+		t.Errorf("expected a single tool_use block, got %s", data)
+	}
+}
+
+func TestAnthropicStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	s := newAnthropicStream(rec)
+	s.Header().Set("Content-Type", "application/x-ndjson")
+	lines := []string{
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"content":"Let me check."}}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"content":"","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"weather","arguments":""}}]}}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"content":"","tool_calls":[{"index":0,"function":{"name":"","arguments":"{\"city\":\"Paris\"}"}}]}}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"content":""},"finish_reason":"tool_calls"}]}`,
+	}
+	// Split writes mid-line, as a flushing handler may.
+	all := strings.Join(lines, "\n") + "\n"
+	s.Write([]byte(all[:30]))
+	s.Write([]byte(all[30:]))
+	s.Close()
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %s", ct)
+	}
+	var names []string
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			names = append(names, name)
+		}
+	}
+	want := "message_start content_block_start content_block_delta content_block_stop content_block_start content_block_delta content_block_stop message_delta message_stop"
+	if strings.Join(names, " ") != want {
+		t.Errorf("unexpected events:\n got %s\nwant %s", strings.Join(names, " "), want)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `"partial_json":"{\"city\":\"Paris\"}"`) || !strings.Contains(body, `"stop_reason":"tool_use"`) {
+		t.Errorf("unexpected stream body:\n%s", body)
+	}
+}
+
+func TestAnthropicStreamRejected(t *testing.T) {
+	rec := httptest.NewRecorder()
+	s := newAnthropicStream(rec)
+	s.WriteHeader(http.StatusTooManyRequests)
+	s.Write([]byte(`{"error":{"message":"rate limited"}}`))
+	s.Close()
+
+	var out struct {
+		Type  string            `json:"type"`
+		Error map[string]string `json:"error"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	if rec.Code != http.StatusTooManyRequests || out.Type != "error" || out.Error["type"] != "rate_limit_error" || out.Error["message"] != "rate limited" {
+		t.Errorf("unexpected error response %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestMessagesThinking(t *testing.T) {
+	var in messagesRequest
+	body := `{
+		"model": "claude-sonnet-4",
+		"max_tokens": 8000,
+		"thinking": {"type": "enabled", "budget_tokens": 2048},
+		"messages": [
+			{"role": "user", "content": "2+2?"},
+			{"role": "assistant", "content": [
+				{"type": "thinking", "thinking": "Simple sum.", "signature": "sig"},
+				{"type": "text", "text": "4"}
+			]},
+			{"role": "user", "content": "And 3+3?"}
+		]
+	}`
+	if err := json.Unmarshal([]byte(body), &in); err != nil {
+		t.Fatal(err)
+	}
+	req, err := in.toChatRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !req.IncludeReasoning || req.Thinking == nil || req.Thinking.BudgetTokens != 2048 {
+		t.Errorf("expected thinking to be requested and returned, got %+v", req)
+	}
+	if m := req.Messages[1]; m.Reasoning != "Simple sum." || m.ReasoningSignature != "sig" || m.Content != "4" {
+		t.Errorf("expected the thinking block to carry over, got %+v", m)
+	}
+
+	rec := httptest.NewRecorder()
+	s := newAnthropicStream(rec)
+	for _, line := range []string{
+		`{"id":"msg_1","model":"claude-sonnet-4","choices":[{"index":0,"delta":{"content":"","reasoning_content":"Three and three"}}]}`,
+		`{"id":"msg_1","model":"claude-sonnet-4","choices":[{"index":0,"delta":{"content":"","reasoning_signature":"sig2"}}]}`,
+		`{"id":"msg_1","model":"claude-sonnet-4","choices":[{"index":0,"delta":{"content":"6"},"finish_reason":"stop"}]}`,
+	} {
+		s.Write([]byte(line + "\n"))
+	}
+	s.Close()
+	out := rec.Body.String()
+	for _, want := range []string{`"type":"thinking"`, `"thinking_delta"`, `"signature":"sig2"`, `"text_delta"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in stream:\n%s", want, out)
+		}
+	}
+}
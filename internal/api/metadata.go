@@ -0,0 +1,74 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/yewintnaing/ai-gateway/internal/cache"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// validateMetadata checks request metadata against the configured schema.
+// tenant, use_case and conversation_id are gateway-defined keys and always
+// allowed.
+func validateMetadata(schema config.MetadataSchema, meta map[string]interface{}) error {
+	known := map[string]bool{"tenant": true, "use_case": true, "conversation_id": true}
+	for _, f := range schema.Fields {
+		known[f.Name] = true
+
+		v, ok := meta[f.Name]
+		if !ok || v == nil {
+			if f.Required {
+				return fmt.Errorf("metadata.%s is required", f.Name)
+			}
+			continue
+		}
+
+		var str string
+		switch f.Type {
+		case "number":
+			n, ok := v.(float64)
+			if !ok {
+				return fmt.Errorf("metadata.%s must be a number", f.Name)
+			}
+			str = fmt.Sprintf("%g", n)
+		case "bool":
+			b, ok := v.(bool)
+			if !ok {
+				return fmt.Errorf("metadata.%s must be a boolean", f.Name)
+			}
+			str = fmt.Sprintf("%t", b)
+		default:
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("metadata.%s must be a string", f.Name)
+			}
+			str = s
+		}
+
+		if len(f.Enum) > 0 && !slices.Contains(f.Enum, str) {
+			return fmt.Errorf("metadata.%s must be one of %v", f.Name, f.Enum)
+		}
+	}
+
+	if schema.Strict {
+		for k := range meta {
+			if !known[k] {
+				return fmt.Errorf("metadata.%s is not an allowed field", k)
+			}
+		}
+	}
+	return nil
+}
+
+// cacheTags tags a cache entry stored for req, with the end user it was
+// made for hashed as deletion logs record them, so deleting the user's
+// data can purge it.
+func (h *Handler) cacheTags(req ChatRequest, tenant, route, model string) cache.Tags {
+	tags := cache.Tags{Tenant: tenant, Route: route, Model: model}
+	if user, ok := req.Metadata[h.metadata.UserKey()]; ok && user != nil {
+		tags.User = usage.HashUser(fmt.Sprint(user))
+	}
+	return tags
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+func TestValidateMetadata(t *testing.T) {
+	schema := config.MetadataSchema{
+		Fields: []config.MetadataField{
+			{Name: "cost_center", Type: "string", Required: true, Enum: []string{"eng", "sales"}},
+			{Name: "priority", Type: "number"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		schema  config.MetadataSchema
+		meta    map[string]interface{}
+		wantErr bool
+	}{
+		{"Valid", schema, map[string]interface{}{"cost_center": "eng", "priority": 2.0}, false},
+		{"Missing required", schema, map[string]interface{}{"priority": 2.0}, true},
+		{"Not in enum", schema, map[string]interface{}{"cost_center": "legal"}, true},
+		{"Wrong type", schema, map[string]interface{}{"cost_center": "eng", "priority": "high"}, true},
+		{"Unknown field allowed", schema, map[string]interface{}{"cost_center": "eng", "team": "x"}, false},
+		{"Unknown field strict", config.MetadataSchema{Strict: true, Fields: schema.Fields}, map[string]interface{}{"cost_center": "eng", "team": "x"}, true},
+		{"Gateway keys always allowed", config.MetadataSchema{Strict: true}, map[string]interface{}{"tenant": "a", "use_case": "b"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateMetadata(tt.schema, tt.meta); (err != nil) != tt.wantErr {
+				t.Errorf("expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCacheTags(t *testing.T) {
+	h := &Handler{metadata: config.MetadataSchema{EndUserKey: "customer"}}
+	tags := h.cacheTags(ChatRequest{Metadata: map[string]interface{}{"customer": "c-42"}}, "acme", "support", "gpt-4o")
+	if tags.Tenant != "acme" || tags.Route != "support" || tags.Model != "gpt-4o" || tags.User != usage.HashUser("c-42") {
+		t.Errorf("unexpected tags %+v", tags)
+	}
+	if tags := h.cacheTags(ChatRequest{Metadata: map[string]interface{}{"user_id": "u1"}}, "acme", "support", "gpt-4o"); tags.User != "" {
+		t.Errorf("expected no user without the end-user key, got %+v", tags)
+	}
+}
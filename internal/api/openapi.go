@@ -0,0 +1,605 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// obj is a fragment of the OpenAPI document.
+type obj = map[string]interface{}
+
+// endpoint describes one operation for the OpenAPI document.
+type endpoint struct {
+	method  string
+	path    string
+	tag     string
+	summary string
+	// params name shared parameters in components.parameters.
+	params []string
+	// body and response name schemas in components.schemas; an empty
+	// response means the operation answers 204.
+	body     string
+	response string
+	// status is the success status, when not 200 or 204.
+	status string
+	// text describes a text/plain response, in place of response.
+	text string
+	// stream adds text/event-stream and application/x-ndjson responses.
+	stream bool
+	// gateway adds the x-gw-* and rate limit response headers.
+	gateway bool
+	// open marks operations that need no credentials.
+	open bool
+}
+
+// endpoints are the gateway's routes, as main registers them.
+var endpoints = []endpoint{
+	{method: "post", path: "/v1/chat/completions", tag: "chat", summary: "Create a chat completion in OpenAI's format, routed by metadata.use_case", params: []string{"Priority", "Residency", "ConvHeader", "LastEventID", "Debug"}, body: "ChatCompletionRequest", response: "ChatCompletion", stream: true, gateway: true, open: true},
+	{method: "post", path: "/v1/messages", tag: "chat", summary: "Create a message in Anthropic's Messages format", params: []string{"Priority", "Residency", "ConvHeader"}, body: "AnthropicMessagesRequest", response: "AnthropicMessage", stream: true, gateway: true, open: true},
+	{method: "post", path: "/v1/tokenize", tag: "chat", summary: "Count the tokens of text or messages as the gateway accounts for them, with token IDs from backends that have a tokenizer", body: "TokenizeRequest", response: "Tokenize", open: true},
+	{method: "post", path: "/v1/estimate", tag: "chat", summary: "Route and price a chat completion request without dispatching it: target, prompt tokens, worst-case cost range and rate limit use", params: []string{"Residency"}, body: "ChatCompletionRequest", response: "Estimate", open: true},
+	{method: "get", path: "/v1/usage", tag: "usage", summary: "Aggregate requests, tokens and estimated cost", params: []string{"TenantQuery", "From", "To", "GroupBy"}, response: "UsageReport"},
+	{method: "get", path: "/v1/usage/conversations", tag: "usage", summary: "Usage per conversation, most recently active first, with totals and averages", params: []string{"TenantQuery", "From", "To", "Limit"}, response: "Conversations"},
+	{method: "get", path: "/v1/usage/conversations/{id}", tag: "usage", summary: "A tenant's conversation with its requests", params: []string{"TenantQuery", "ConvID"}, response: "Conversation"},
+	{method: "get", path: "/v1/me/usage", tag: "usage", summary: "Usage for the tenant of the API key", params: []string{"From", "To", "GroupBy"}, response: "UsageReport"},
+	{method: "get", path: "/v1/me/conversations", tag: "usage", summary: "Usage per conversation for the tenant of the API key", params: []string{"From", "To", "Limit"}, response: "Conversations"},
+	{method: "get", path: "/v1/me/conversations/{id}", tag: "usage", summary: "A conversation of the tenant of the API key, with its requests", params: []string{"ConvID"}, response: "Conversation"},
+	{method: "get", path: "/v1/me/limits", tag: "usage", summary: "Token budget and output cap for the tenant of the API key", response: "Limits"},
+	{method: "get", path: "/v1/me/keys", tag: "usage", summary: "API keys of the tenant of the API key", response: "APIKeyList"},
+	{method: "post", path: "/mcp", tag: "mcp", summary: "MCP server (streamable HTTP) exposing the gateway's routes as tools", body: "JSONRPCRequest", response: "JSONRPCResponse", open: true},
+	{method: "get", path: "/admin/requests/{request_id}", tag: "admin", summary: "Trace of a request: route, provider attempts, usage and guardrail events", params: []string{"RequestID"}, response: "RequestTrace"},
+	{method: "get", path: "/admin/requests/{request_id}/payload", tag: "admin", summary: "Decrypted prompt and completion captured for a request", params: []string{"RequestID"}, response: "Payload"},
+	{method: "post", path: "/admin/requests/{request_id}/replay", tag: "admin", summary: "Replay a captured request, optionally to another route or model, and diff the completions", params: []string{"RequestID"}, body: "ReplayRequest", response: "Replay"},
+	{method: "get", path: "/admin/routes", tag: "admin", summary: "List routes stored in the database", response: "RouteList"},
+	{method: "get", path: "/admin/routes/{name}", tag: "admin", summary: "Route in effect under a name, from the database or the routes file", params: []string{"RouteName"}, response: "Route"},
+	{method: "put", path: "/admin/routes/{name}", tag: "admin", summary: "Create (201) or replace (200) a stored route", params: []string{"RouteName"}, body: "Route", response: "Route"},
+	{method: "delete", path: "/admin/routes/{name}", tag: "admin", summary: "Delete a stored route", params: []string{"RouteName"}},
+	{method: "post", path: "/admin/route/test", tag: "admin", summary: "Dry-run routing: the route and targets a hypothetical request would get under the current config", body: "RouteTestRequest", response: "RouteTest"},
+	{method: "get", path: "/admin/routes/{name}/canary", tag: "admin", summary: "Canary comparison for the current window", params: []string{"RouteName"}, response: "CanaryStatus"},
+	{method: "get", path: "/admin/providers/health", tag: "admin", summary: "Circuit state and latency of each provider", response: "ProviderHealth"},
+	{method: "get", path: "/admin/providers/stats", tag: "admin", summary: "Rolling success rates and p95 latency of each provider and model, on this instance", response: "ProviderStats"},
+	{method: "get", path: "/admin/rate-limits", tag: "admin", summary: "Tenants exempt from the rate limit or given a burst credit pool", response: "RateLimitOverrideList"},
+	{method: "get", path: "/admin/tenants/{tenant}/word-rules", tag: "admin", summary: "List a tenant's word rules", params: []string{"Tenant"}, response: "WordRuleList"},
+	{method: "post", path: "/admin/tenants/{tenant}/word-rules", status: "201", tag: "admin", summary: "Add a word rule", params: []string{"Tenant"}, body: "WordRule", response: "WordRule"},
+	{method: "delete", path: "/admin/tenants/{tenant}/word-rules/{id}", tag: "admin", summary: "Delete a word rule", params: []string{"Tenant", "ID"}},
+	{method: "get", path: "/admin/tenants/{tenant}/keys", tag: "admin", summary: "List a tenant's API keys", params: []string{"Tenant"}, response: "APIKeyList"},
+	{method: "post", path: "/admin/tenants/{tenant}/keys", status: "201", tag: "admin", summary: "Issue an API key; the secret is returned only here", params: []string{"Tenant"}, body: "NewAPIKey", response: "CreatedAPIKey"},
+	{method: "delete", path: "/admin/tenants/{tenant}/keys/{id}", tag: "admin", summary: "Revoke an API key", params: []string{"Tenant", "ID"}},
+	{method: "get", path: "/admin/tenants/{tenant}/rate-limit", tag: "admin", summary: "A tenant's rate limit exemption or burst credit pool", params: []string{"Tenant"}, response: "RateLimitOverride"},
+	{method: "put", path: "/admin/tenants/{tenant}/rate-limit", tag: "admin", summary: "Exempt a tenant from the rate limit or give it a fresh burst credit pool", params: []string{"Tenant"}, body: "RateLimitOverride", response: "RateLimitOverride"},
+	{method: "delete", path: "/admin/tenants/{tenant}/rate-limit", tag: "admin", summary: "Remove a tenant's rate limit override", params: []string{"Tenant"}},
+	{method: "get", path: "/admin/retention", tag: "admin", summary: "Retention policy and recent runs", response: "Retention"},
+	{method: "post", path: "/admin/retention/run", tag: "admin", summary: "Apply the retention policy now", params: []string{"DryRun"}, response: "Retention"},
+	{method: "get", path: "/admin/archives", tag: "admin", summary: "Usage partitions archived as Parquet, and whether they were dropped", response: "Archives"},
+	{method: "delete", path: "/admin/data", tag: "admin", summary: "Delete or anonymize the records of a user", body: "UserDeletionRequest", response: "UserDeletion"},
+	{method: "get", path: "/admin/webhooks/dead-letters", tag: "admin", summary: "Webhook events that could not be delivered", params: []string{"TenantQuery"}, response: "DeadLetterList"},
+	{method: "post", path: "/admin/webhooks/dead-letters/{id}/redeliver", status: "202", tag: "admin", summary: "Deliver a dead-lettered webhook event again", params: []string{"ID"}, response: "DeadLetter"},
+	{method: "get", path: "/admin/cache/stats", tag: "admin", summary: "Response cache lookups and hit rate by route, on this instance", response: "CacheStats"},
+	{method: "delete", path: "/admin/cache", tag: "admin", summary: "Purge cached responses by tenant, route, model or prompt-hash prefix", params: []string{"TenantQuery", "RouteQuery", "ModelQuery", "KeyPrefix"}, response: "CachePurge"},
+	{method: "get", path: "/admin/probes", tag: "admin", summary: "Recent synthetic probe results, newest first", params: []string{"ProbeQuery", "RouteQuery", "FailedQuery"}, response: "ProbeResults"},
+	{method: "get", path: "/admin/evals", tag: "admin", summary: "Eval runs without their results, newest first", params: []string{"Dataset"}, response: "EvalRunList"},
+	{method: "post", path: "/admin/evals", status: "202", tag: "admin", summary: "Run a golden dataset against routes or models in the background", body: "EvalRequest", response: "EvalRunList"},
+	{method: "get", path: "/admin/evals/compare", tag: "admin", summary: "Compare a candidate eval run with a base run of the same dataset, case by case", params: []string{"BaseRun", "Candidate"}, response: "EvalComparison"},
+	{method: "get", path: "/admin/evals/{id}", tag: "admin", summary: "An eval run with the results scored so far", params: []string{"EvalID"}, response: "EvalRun"},
+	{method: "get", path: "/admin/reconciliation", tag: "admin", summary: "Daily cost estimates compared with provider invoices", params: []string{"Flagged"}, response: "ReconciliationList"},
+	{method: "get", path: "/admin/roles", tag: "admin", summary: "List role assignments", params: []string{"Subject"}, response: "RoleAssignmentList"},
+	{method: "post", path: "/admin/roles", status: "201", tag: "admin", summary: "Assign a role", body: "RoleAssignment", response: "RoleAssignment"},
+	{method: "delete", path: "/admin/roles/{id}", tag: "admin", summary: "Remove a role assignment", params: []string{"ID"}},
+	{method: "get", path: "/health/live", tag: "health", summary: "Liveness: the process is serving", response: "Status", open: true},
+	{method: "get", path: "/metrics", tag: "meta", summary: "Metrics in the Prometheus text format", text: "Prometheus text exposition format 0.0.4", open: true},
+	{method: "get", path: "/openapi.json", tag: "meta", summary: "This OpenAPI document", response: "OpenAPIDocument", open: true},
+	{method: "get", path: "/health/ready", tag: "health", summary: "Readiness: dependencies reachable; 503 while a critical one is down", response: "Readiness", open: true},
+}
+
+func ref(kind, name string) obj { return obj{"$ref": "#/components/" + kind + "/" + name} }
+
+func jsonContent(schema string) obj {
+	return obj{"application/json": obj{"schema": ref("schemas", schema)}}
+}
+
+func schemaString(desc string) obj  { return obj{"type": "string", "description": desc} }
+func schemaInteger(desc string) obj { return obj{"type": "integer", "description": desc} }
+func schemaNumber(desc string) obj  { return obj{"type": "number", "description": desc} }
+func schemaObject(desc string) obj {
+	return obj{"type": "object", "description": desc, "additionalProperties": true}
+}
+func schemaArray(items obj) obj { return obj{"type": "array", "items": items} }
+func schemaProps(required []string, p obj) obj {
+	o := obj{"type": "object", "properties": p}
+	if len(required) > 0 {
+		o["required"] = required
+	}
+	return o
+}
+func schemaList(field, schema string) obj {
+	return schemaProps([]string{field}, obj{field: schemaArray(ref("schemas", schema))})
+}
+
+var gatewayHeaders = map[string]string{
+	"x-request-id":               "The request's ID, generated by the gateway",
+	"x-gw-route":                 "Route that served the request",
+	"x-gw-provider":              "Provider that served the request",
+	"x-gw-region":                "Region of the provider that served the request, when it declares one",
+	"x-gw-model":                 "Model that served the request",
+	"x-gw-cache":                 "hit when the response came from the cache",
+	"x-gw-cache-key":             "Prompt hash the response is cached under, for purging by key_prefix",
+	"x-gw-coalesced":             "true when the response was shared with an identical concurrent request",
+	"x-gw-degraded":              "Set when a fallback response was served because every provider failed",
+	"x-gw-burst-credit":          "Tokens left in the tenant's burst credit pool, when the request went past the rate limit on credit",
+	"x-gw-failed-open":           "Dependencies that failed and were gone without, per the failure policy",
+	"x-gw-canary":                "Canary version of the route that served the request",
+	"x-gw-category":              "Category picked by the route's classifier",
+	"x-gw-consensus":             "Agreement among the calls of a consensus route",
+	"x-gw-budget-output-tokens":  "Output token cap applied to the request",
+	"x-gw-predicted-max-tokens":  "max_tokens predicted for the use case",
+	"x-gw-redacted":              "Number of redactions made by guardrails",
+	"x-gw-moderated":             "Set when moderation changed the completion",
+	"x-gw-prompt-injection":      "Prompt injection score, when flagged",
+	"x-gw-policy-annotations":    "Word rules that annotated the request",
+	"x-gw-system-prompt-version": "Version of the managed system prompt",
+	"x-gw-resumed-from":          "Sequence number a resumed stream continued from",
+	"RateLimit-Limit":            "Tokens allowed per one-minute window",
+	"RateLimit-Remaining":        "Tokens left in the current window",
+	"RateLimit-Reset":            "Seconds until the window resets",
+	"RateLimit-Policy":           "The limit policy, e.g. 50000;w=60",
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+)
+
+// OpenAPI returns the gateway's OpenAPI 3.1 document, built from endpoints.
+func OpenAPI() []byte {
+	openAPIOnce.Do(func() {
+		openAPIDoc, _ = json.MarshalIndent(openAPI(), "", "  ")
+	})
+	return openAPIDoc
+}
+
+// HandleOpenAPI serves the OpenAPI document.
+func HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(OpenAPI())
+}
+
+func openAPI() obj {
+	headers := obj{}
+	names := make([]string, 0, len(gatewayHeaders))
+	for name, desc := range gatewayHeaders {
+		headers[name] = obj{"description": desc, "schema": obj{"type": "string"}}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errorResponse := func(desc string) obj {
+		return obj{"description": desc, "content": jsonContent("Error"), "headers": obj{"x-request-id": ref("headers", "x-request-id")}}
+	}
+	responses := obj{
+		"BadRequest":   errorResponse("The request is invalid"),
+		"Unauthorized": errorResponse("Credentials are missing or invalid"),
+		"Forbidden":    errorResponse("The credentials lack the role needed"),
+		"NotFound":     errorResponse("No such resource"),
+		"RateLimited": obj{
+			"description": "The tenant's tokens-per-minute budget is spent",
+			"content":     jsonContent("Error"),
+			"headers": obj{
+				"Retry-After":     obj{"description": "Seconds until the budget resets", "schema": obj{"type": "integer"}},
+				"RateLimit-Reset": ref("headers", "RateLimit-Reset"),
+			},
+		},
+		"ProviderError": errorResponse("Every provider attempt failed"),
+	}
+
+	paths := obj{}
+	for _, e := range endpoints {
+		op := obj{"tags": []string{e.tag}, "summary": e.summary, "operationId": operationID(e)}
+		if len(e.params) > 0 {
+			var params []obj
+			for _, p := range e.params {
+				params = append(params, ref("parameters", p))
+			}
+			op["parameters"] = params
+		}
+		if e.body != "" {
+			op["requestBody"] = obj{"required": true, "content": jsonContent(e.body)}
+		}
+
+		ok := obj{"description": "Success"}
+		status := "200"
+		switch {
+		case e.text != "":
+			ok["content"] = obj{"text/plain": obj{"schema": schemaString(e.text)}}
+		case e.response == "":
+			status = "204"
+			ok["description"] = "Done"
+			if e.status != "" {
+				status = e.status
+			}
+		case e.stream:
+			content := jsonContent(e.response)
+			content["text/event-stream"] = obj{"schema": schemaString("Server-sent events, each data line a ChatCompletionChunk, ending with data: [DONE]")}
+			content["application/x-ndjson"] = obj{"schema": schemaString("One ChatCompletionChunk per line")}
+			ok["content"] = content
+		default:
+			ok["content"] = jsonContent(e.response)
+			if e.status != "" {
+				status = e.status
+			}
+		}
+		if e.gateway {
+			h := obj{}
+			for _, name := range names {
+				h[name] = ref("headers", name)
+			}
+			ok["headers"] = h
+		}
+		resp := obj{status: ok, "400": ref("responses", "BadRequest")}
+		if e.gateway {
+			resp["429"] = ref("responses", "RateLimited")
+			resp["502"] = ref("responses", "ProviderError")
+		}
+		if !e.open {
+			resp["401"] = ref("responses", "Unauthorized")
+			resp["403"] = ref("responses", "Forbidden")
+			op["security"] = []obj{{"bearerAuth": []string{}}}
+		}
+		if strings.Contains(e.path, "{") {
+			resp["404"] = ref("responses", "NotFound")
+		}
+		op["responses"] = resp
+
+		item, _ := paths[e.path].(obj)
+		if item == nil {
+			item = obj{}
+			paths[e.path] = item
+		}
+		item[e.method] = op
+	}
+
+	return obj{
+		"openapi": "3.1.0",
+		"info": obj{
+			"title":       "AI Gateway",
+			"version":     "1.0.0",
+			"description": "Routes chat completions across LLM providers with fallbacks, rate limits, guardrails and usage accounting.",
+		},
+		"paths": paths,
+		"components": obj{
+			"schemas":    schemas(),
+			"parameters": parameters(),
+			"headers":    headers,
+			"responses":  responses,
+			"securitySchemes": obj{
+				"bearerAuth": obj{"type": "http", "scheme": "bearer", "description": "ADMIN_TOKEN, a gateway API key (gwk_...) or a JWT, when AUTH_MODE=rbac"},
+			},
+		},
+	}
+}
+
+// operationID is the method followed by the path's words, e.g.
+// getAdminRoutesName.
+func operationID(e endpoint) string {
+	id := e.method
+	for _, part := range strings.FieldsFunc(e.path, func(r rune) bool { return strings.ContainsRune("/{}-_.", r) }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+func parameters() obj {
+	query := func(name, desc string, schema obj) obj {
+		return obj{"name": name, "in": "query", "description": desc, "schema": schema}
+	}
+	path := func(name, desc string) obj {
+		return obj{"name": name, "in": "path", "required": true, "description": desc, "schema": obj{"type": "string"}}
+	}
+	return obj{
+		"Priority":    obj{"name": "X-GW-Priority", "in": "header", "description": "Queue priority for saturated targets: low, normal or high, within the tenant's allowance", "schema": obj{"type": "string", "enum": []string{"low", "normal", "high"}}},
+		"Residency":   obj{"name": "X-GW-Residency", "in": "header", "description": "Region the request must be processed in; only providers declaring it are used. Defaults to the tenant's residency, which may not be overridden", "schema": obj{"type": "string"}},
+		"Debug":       obj{"name": "X-GW-Debug", "in": "header", "description": "Any value but 0 or false adds a routing trace to non-streamed completions, in gateway_debug", "schema": obj{"type": "string"}},
+		"LastEventID": obj{"name": "Last-Event-ID", "in": "header", "description": "Resume a dropped stream after this event", "schema": obj{"type": "string"}},
+		"ConvHeader":  obj{"name": "X-GW-Conversation-ID", "in": "header", "description": "Conversation the request belongs to, for per-conversation usage; metadata.conversation_id takes precedence", "schema": obj{"type": "string"}},
+		"Limit":       query("limit", "Most items to return, 1 to 1000 (default 100)", obj{"type": "integer"}),
+		"TenantQuery": query("tenant", "Only this tenant", obj{"type": "string"}),
+		"From":        query("from", "Start, as a date or RFC 3339 time", obj{"type": "string"}),
+		"To":          query("to", "End, as a date or RFC 3339 time", obj{"type": "string"}),
+		"GroupBy":     query("group_by", "tenant, use_case, route_name, provider, model or a metadata key; meta.<key>=<value> filters on metadata", obj{"type": "string"}),
+		"DryRun":      query("dry_run", "Count the rows affected without changing them", obj{"type": "boolean"}),
+		"Flagged":     query("flagged", "Only days whose difference exceeds the threshold", obj{"type": "boolean"}),
+		"Subject":     query("subject", "Only this subject", obj{"type": "string"}),
+		"RouteQuery":  query("route", "Only this route", obj{"type": "string"}),
+		"ModelQuery":  query("model", "Only this model", obj{"type": "string"}),
+		"ProbeQuery":  query("probe", "Only this probe", obj{"type": "string"}),
+		"FailedQuery": query("failed", "Only failed results", obj{"type": "boolean"}),
+		"Dataset":     query("dataset", "Only runs of this dataset", obj{"type": "string"}),
+		"BaseRun":     query("base", "Eval run to compare against", obj{"type": "string"}),
+		"Candidate":   query("candidate", "Eval run to compare", obj{"type": "string"}),
+		"KeyPrefix":   query("key_prefix", "Only cache keys (the x-gw-cache-key prompt hash) starting with this", obj{"type": "string"}),
+		"RequestID":   path("request_id", "Request ID"),
+		"RouteName":   path("name", "Route name"),
+		"Tenant":      path("tenant", "Tenant name"),
+		"ID":          path("id", "Numeric ID"),
+		"EvalID":      path("id", "Eval run ID"),
+		"ConvID":      path("id", "Conversation ID"),
+	}
+}
+
+func schemas() obj {
+	return obj{
+		"Error": schemaProps([]string{"error"}, obj{
+			"error": schemaProps([]string{"message"}, obj{
+				"message":    schemaString("What went wrong"),
+				"request_id": schemaString("ID of the failed request"),
+			}),
+		}),
+		"Message": schemaProps([]string{"role"}, obj{
+			"role":              obj{"type": "string", "enum": []string{"system", "user", "assistant", "tool"}},
+			"content":           schemaString("Message text"),
+			"tool_calls":        schemaArray(ref("schemas", "ToolCall")),
+			"tool_call_id":      schemaString("The call a tool message answers"),
+			"reasoning_content": schemaString("The model's reasoning, when requested with include_reasoning"),
+		}),
+		"ToolCall": schemaProps([]string{"id", "type", "function"}, obj{
+			"id":   schemaString("Call ID"),
+			"type": obj{"type": "string", "const": "function"},
+			"function": schemaProps([]string{"name", "arguments"}, obj{
+				"name":      schemaString("Function name"),
+				"arguments": schemaString("Arguments as a JSON object encoded in a string"),
+			}),
+		}),
+		"Tool": schemaProps([]string{"type", "function"}, obj{
+			"type": obj{"type": "string", "const": "function"},
+			"function": schemaProps([]string{"name"}, obj{
+				"name":        schemaString("Function name"),
+				"description": schemaString("What the function does"),
+				"parameters":  schemaObject("JSON Schema of the arguments"),
+			}),
+		}),
+		"ChatCompletionRequest": schemaProps([]string{"messages"}, obj{
+			"model":             schemaString("Model hint; the route decides the model actually used"),
+			"messages":          schemaArray(ref("schemas", "Message")),
+			"temperature":       schemaNumber("Sampling temperature"),
+			"max_tokens":        schemaInteger("Completion length limit, capped by route and tenant budgets"),
+			"stream":            obj{"type": "boolean", "description": "Stream the completion as server-sent events, or NDJSON with Accept: application/x-ndjson"},
+			"top_p":             schemaNumber("Nucleus sampling"),
+			"stop":              obj{"oneOf": []obj{{"type": "string"}, schemaArray(obj{"type": "string"})}, "description": "Stop sequences"},
+			"presence_penalty":  schemaNumber("Presence penalty"),
+			"frequency_penalty": schemaNumber("Frequency penalty"),
+			"logit_bias":        obj{"type": "object", "additionalProperties": obj{"type": "number"}},
+			"seed":              schemaInteger("Sampling seed"),
+			"n":                 schemaInteger("Number of choices"),
+			"tools":             schemaArray(ref("schemas", "Tool")),
+			"tool_choice":       obj{"description": "auto, none, required, or a function to call"},
+			"reasoning_effort":  obj{"type": "string", "enum": []string{"low", "medium", "high"}},
+			"thinking":          schemaObject("Anthropic thinking budget, e.g. {\"type\": \"enabled\", \"budget_tokens\": 2048}"),
+			"include_reasoning": obj{"type": "boolean", "description": "Return the model's reasoning instead of stripping it"},
+			"max_cost_usd":      schemaNumber("Cost ceiling for the request"),
+			"metadata": obj{
+				"type":                 "object",
+				"description":          "Routing and accounting metadata, validated against the metadata schema",
+				"additionalProperties": true,
+				"properties": obj{
+					"tenant":   schemaString("Tenant the request is billed and rate limited to"),
+					"use_case": schemaString("Use case that picks the route"),
+				},
+			},
+		}),
+		"Usage": schemaProps(nil, obj{
+			"prompt_tokens":     schemaInteger("Prompt tokens"),
+			"completion_tokens": schemaInteger("Completion tokens"),
+			"total_tokens":      schemaInteger("Prompt and completion tokens"),
+		}),
+		"ChatCompletion": schemaProps([]string{"id", "object", "choices"}, obj{
+			"id":      schemaString("Completion ID"),
+			"object":  obj{"type": "string", "const": "chat.completion"},
+			"created": schemaInteger("Unix time"),
+			"model":   schemaString("Model that answered"),
+			"choices": schemaArray(schemaProps(nil, obj{
+				"index":         schemaInteger("Choice index"),
+				"message":       ref("schemas", "Message"),
+				"finish_reason": obj{"type": "string", "enum": []string{"stop", "length", "tool_calls", "content_filter"}},
+			})),
+			"usage":         ref("schemas", "Usage"),
+			"gateway_debug": ref("schemas", "RoutingTrace"),
+		}),
+		"RouteTestRequest": schemaProps(nil, obj{
+			"use_case":      schemaString("Use case to route; defaults to metadata.use_case"),
+			"tenant":        schemaString("Tenant whose policy applies (default anonymous)"),
+			"metadata":      obj{"type": "object", "description": "Request metadata, checked against the metadata schema"},
+			"prompt_tokens": schemaInteger("Prompt length, for cost ceilings"),
+			"max_tokens":    schemaInteger("Client max_tokens"),
+			"max_cost_usd":  schemaNumber("Client cost ceiling"),
+			"residency":     schemaString("Residency as X-GW-Residency would ask for"),
+			"messages":      schemaArray(ref("schemas", "Message")),
+			"canary":        obj{"type": "boolean", "description": "Route as the canary definition of the route would"},
+		}),
+		"RouteTest": schemaProps([]string{"route", "matched_by", "targets"}, obj{
+			"route":      schemaString("Route the request would be served on"),
+			"matched_by": obj{"type": "string", "enum": []string{"match.use_case", "default_route", "builtin_default"}, "description": "Rule that picked the route"},
+			"canary": schemaProps(nil, obj{
+				"version":     schemaString("Canary version"),
+				"percent":     schemaNumber("Share of traffic sent to the canary"),
+				"rolled_back": obj{"type": "boolean"},
+				"evaluated":   obj{"type": "boolean", "description": "Whether the result is for the canary definition"},
+				"targets":     schemaArray(schemaString("provider/model")),
+			}),
+			"category":   schemaString("Classifier category that picked the target"),
+			"residency":  schemaString("Residency the request would be held to"),
+			"max_tokens": schemaInteger("Completion length the request would be held to"),
+			"filters":    obj{"$ref": "#/components/schemas/RoutingTrace/properties/filters"},
+			"targets":    obj{"$ref": "#/components/schemas/RoutingTrace/properties/candidates"},
+			"refused": schemaProps([]string{"status", "error"}, obj{
+				"status": schemaInteger("HTTP status the request would be refused with"),
+				"error":  schemaString("Error message"),
+			}),
+		}),
+		"RoutingTrace": schemaProps([]string{"route", "matched_by", "candidates"}, obj{
+			"use_case":       schemaString("metadata.use_case of the request"),
+			"route":          schemaString("Route the request was served on"),
+			"matched_by":     obj{"type": "string", "enum": []string{"match.use_case", "default_route", "builtin_default"}, "description": "Rule that picked the route"},
+			"canary_version": schemaString("Canary version the request was assigned to"),
+			"category":       schemaString("Classifier category that picked the target"),
+			"filters": schemaArray(schemaProps([]string{"name"}, obj{
+				"name":      obj{"type": "string", "enum": []string{"canary", "classifier", "target_policy", "cost_ceiling", "ttft_slo", "queue_depth"}},
+				"removed":   schemaArray(schemaString("provider/model")),
+				"added":     schemaArray(schemaString("provider/model")),
+				"reordered": obj{"type": "boolean"},
+			})),
+			"candidates": schemaArray(schemaProps([]string{"provider", "model", "circuit"}, obj{
+				"provider":      schemaString("Provider"),
+				"model":         schemaString("Model"),
+				"circuit":       obj{"type": "string", "enum": []string{"closed", "open", "half_open", "unknown"}},
+				"queue_waiting": schemaNumber("Requests waiting in a self-hosted backend's queue"),
+			})),
+			"attempts": schemaArray(schemaProps([]string{"provider", "model"}, obj{
+				"provider": schemaString("Provider"),
+				"model":    schemaString("Model"),
+				"error":    schemaString("Why the call failed or the target was skipped"),
+			})),
+			"cache": obj{"type": "string", "enum": []string{"HIT", "STALE"}, "description": "Set when the completion came from the response cache"},
+		}),
+		"ChatCompletionChunk": schemaProps([]string{"id", "object", "choices"}, obj{
+			"id":      schemaString("Completion ID"),
+			"object":  obj{"type": "string", "const": "chat.completion.chunk"},
+			"created": schemaInteger("Unix time"),
+			"model":   schemaString("Model that answered"),
+			"choices": schemaArray(schemaProps(nil, obj{
+				"index":         schemaInteger("Choice index"),
+				"delta":         ref("schemas", "Message"),
+				"finish_reason": obj{"type": []string{"string", "null"}},
+			})),
+		}),
+		"TokenizeRequest": schemaProps(nil, obj{
+			"model":     schemaString("Model to count for; defaults to the primary model of the route metadata.use_case selects"),
+			"text":      schemaString("Text to count; give this or messages"),
+			"messages":  schemaArray(ref("schemas", "Message")),
+			"token_ids": obj{"type": "boolean", "description": "Return token IDs from the backend's tokenizer; fails for models without one"},
+			"metadata":  obj{"type": "object", "additionalProperties": true},
+		}),
+		"Tokenize": schemaProps([]string{"model", "tokens"}, obj{
+			"model":          schemaString("Model counted for"),
+			"provider":       schemaString("Provider of the model, when a route serves it"),
+			"route":          schemaString("Route that chose the model"),
+			"tokens":         schemaInteger("Token count the gateway's rate limits, budgets and estimates use"),
+			"token_ids":      schemaArray(obj{"type": "integer"}),
+			"context_window": schemaInteger("Context window the backend's tokenizer reports"),
+		}),
+		"Estimate": schemaProps([]string{"route", "provider", "model", "prompt_tokens", "targets", "rate_limit"}, obj{
+			"route":             schemaString("Route the request matches"),
+			"category":          schemaString("Topic the route's classifier picked"),
+			"provider":          schemaString("Provider of the target tried first"),
+			"model":             schemaString("Model of the target tried first"),
+			"prompt_tokens":     schemaInteger("Prompt tokens with the route's system prompt"),
+			"max_tokens":        schemaInteger("Completion length the first target is held to; absent when unbounded"),
+			"max_cost_low_usd":  schemaNumber("Lowest worst-case cost over the targets"),
+			"max_cost_high_usd": schemaNumber("Highest worst-case cost over the targets; absent when a target's completion is unbounded"),
+			"targets": schemaArray(schemaProps(nil, obj{
+				"provider":        schemaString("Provider"),
+				"model":           schemaString("Model"),
+				"max_tokens":      schemaInteger("Completion length limit"),
+				"prompt_cost_usd": schemaNumber("Cost of the prompt"),
+				"max_cost_usd":    schemaNumber("Cost with a completion of max_tokens; absent when unbounded"),
+			})),
+			"rate_limit": schemaProps([]string{"tokens", "allowed"}, obj{
+				"tokens":        schemaInteger("Tokens the request is charged against the window"),
+				"limit":         schemaInteger("Tokens per minute"),
+				"remaining":     schemaInteger("Tokens left in the window"),
+				"reset_seconds": schemaInteger("Seconds until the window resets"),
+				"exempt":        obj{"type": "boolean"},
+				"allowed":       obj{"type": "boolean", "description": "Whether the request would be let through now"},
+				"burst":         obj{"type": "boolean", "description": "Whether it would only go through on burst credit"},
+				"burst_credit":  schemaInteger("Burst credit left"),
+			}),
+		}),
+		"AnthropicMessagesRequest": schemaObject("A request in Anthropic's Messages API format; metadata routes it as for chat completions"),
+		"AnthropicMessage":         schemaObject("A response in Anthropic's Messages API format"),
+		"UsageRow": schemaProps(nil, obj{
+			"group":              schemaString("Value of the group_by field"),
+			"requests":           schemaInteger("Requests"),
+			"prompt_tokens":      schemaInteger("Prompt tokens"),
+			"completion_tokens":  schemaInteger("Completion tokens"),
+			"total_tokens":       schemaInteger("All tokens"),
+			"cost_estimate_usd":  schemaNumber("Estimated cost"),
+			"reasoning_tokens":   schemaInteger("Completion tokens spent on reasoning"),
+			"reasoning_cost_usd": schemaNumber("Cost of the reasoning tokens"),
+			"system_tokens":      schemaInteger("Prompt tokens from system content"),
+			"user_tokens":        schemaInteger("Prompt tokens from user turns"),
+			"history_tokens":     schemaInteger("Prompt tokens from earlier assistant turns and tool results"),
+			"system_cost_usd":    schemaNumber("Cost of the system share"),
+		}),
+		"UsageReport": schemaProps([]string{"rows"}, obj{
+			"group_by": schemaString("The grouping applied"),
+			"rows":     schemaArray(ref("schemas", "UsageRow")),
+		}),
+		"Conversations":   schemaObject("Totals and averages over the selected conversations, and each conversation's requests, errors, tokens, cost, models and first and last request times"),
+		"Conversation":    schemaObject("A conversation's usage summary and its requests in order"),
+		"Limits":          schemaObject("Tokens-per-minute budget, what is left of it, the output token cap, and any rate limit exemption or burst credit left"),
+		"APIKeyList":      schemaList("keys", "APIKey"),
+		"APIKey":          schemaObject("An API key's name, prefix and creation, last use and revocation times"),
+		"NewAPIKey":       schemaProps([]string{"name"}, obj{"name": schemaString("What the key is for")}),
+		"CreatedAPIKey":   schemaObject("The new key, with its secret"),
+		"JSONRPCRequest":  schemaObject("A JSON-RPC 2.0 request of the Model Context Protocol"),
+		"JSONRPCResponse": schemaObject("A JSON-RPC 2.0 response of the Model Context Protocol"),
+		"RequestTrace":    schemaObject("A request's route, attempts, usage and events"),
+		"Payload":         schemaObject("A request's captured prompt and completion"),
+		"ReplayRequest": schemaProps(nil, obj{
+			"route":    schemaString("Route to replay through; defaults to the original request's"),
+			"provider": schemaString("Provider of model; defaults to the route's primary"),
+			"model":    schemaString("Model to replay to instead of the route's targets"),
+		}),
+		"Replay":         schemaObject("The original and replayed completions, whether they match, their similarity and a line diff"),
+		"Route":          schemaObject("A route, with the fields of a route in the routes file"),
+		"RouteList":      schemaList("routes", "Route"),
+		"CanaryStatus":   schemaObject("Error rates and latencies of the current and canary definitions"),
+		"ProviderHealth": schemaObject("Circuit state, latency and reported backend queue depth by provider"),
+		"ProviderStats":  schemaList("targets", "TargetStats"),
+		"TargetStats":    schemaObject("A provider and model, its circuit, and attempts, errors, success_rate and p95_latency_ms over the 1m, 5m and 1h windows"),
+		"WordRule":       schemaObject("A term, regex or topic rule and its action"),
+		"WordRuleList":   schemaList("rules", "WordRule"),
+		"RateLimitOverride": schemaProps(nil, obj{
+			"exempt":                  obj{"type": "boolean", "description": "Not rate limited at all"},
+			"burst_tokens":            schemaInteger("Tokens the pool holds when full, spent once the per-minute limit is reached"),
+			"burst_refill_per_minute": schemaInteger("Tokens the pool regains per minute; zero never refills it"),
+			"expires_at":              schemaString("When the override stops applying; never when unset"),
+			"reason":                  schemaString("Why it was granted, e.g. a launch"),
+		}),
+		"RateLimitOverrideList": schemaList("overrides", "RateLimitOverride"),
+		"Retention":             schemaObject("Retention policy and runs"),
+		"Archives":              schemaObject("Archived partitions with table, object name, row count and times"),
+		"EvalRequest": schemaProps([]string{"dataset", "targets"}, obj{
+			"dataset": schemaObject("A name and cases: a prompt or messages, with expected, contains, not_contains, matches, json and max_latency_ms checks"),
+			"targets": obj{"type": "array", "items": schemaObject("A route, optionally with a provider and model in place of its targets")},
+		}),
+		"EvalRun":        schemaObject("An eval run's totals and scored results"),
+		"EvalRunList":    schemaList("runs", "EvalRun"),
+		"EvalComparison": schemaObject("Score, latency and cost deltas, regressed and improved cases, and each case's scores"),
+		"ProbeResults":   schemaObject("Synthetic probe results with probe, route, target, latency and failure"),
+		"UserDeletionRequest": schemaProps([]string{"tenant", "key", "user"}, obj{
+			"tenant":    schemaString("Tenant"),
+			"key":       schemaString("Metadata key that identifies users"),
+			"user":      schemaString("The user"),
+			"anonymize": obj{"type": "boolean", "description": "Clear identifying columns instead of deleting rows"},
+		}),
+		"UserDeletion":       schemaObject("Rows deleted or anonymized"),
+		"DeadLetterList":     schemaList("dead_letters", "DeadLetter"),
+		"DeadLetter":         schemaObject("A webhook event that could not be delivered"),
+		"ReconciliationList": schemaList("reconciliations", "Reconciliation"),
+		"Reconciliation":     schemaObject("A day's estimated and invoiced cost for a provider"),
+		"CacheStats":         schemaObject("Hits, stale hits, misses and hit rate, in total and by route"),
+		"CachePurge":         schemaProps([]string{"purged"}, obj{"purged": schemaInteger("Entries deleted")}),
+		"RoleAssignment": schemaProps([]string{"subject", "role"}, obj{
+			"id":      schemaInteger("Assignment ID"),
+			"subject": schemaString("key:<id> or a JWT subject"),
+			"role":    obj{"type": "string", "enum": []string{"admin", "operator", "viewer", "tenant-owner", "payload-reader"}},
+			"tenant":  schemaString("Tenant the role is limited to"),
+		}),
+		"RoleAssignmentList": schemaList("roles", "RoleAssignment"),
+		"Status":             schemaProps([]string{"status"}, obj{"status": obj{"type": "string", "const": "ok"}}),
+		"OpenAPIDocument":    schemaObject("An OpenAPI 3.1 document"),
+		"Readiness":          schemaObject("Whether the instance is ready, with the status and latency of each dependency"),
+	}
+}
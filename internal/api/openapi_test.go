@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(OpenAPI(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc["openapi"] != "3.1.0" {
+		t.Errorf("openapi = %v", doc["openapi"])
+	}
+
+	paths := doc["paths"].(map[string]interface{})
+	for _, e := range endpoints {
+		item, ok := paths[e.path].(map[string]interface{})
+		if !ok || item[e.method] == nil {
+			t.Errorf("%s %s missing", e.method, e.path)
+		}
+	}
+	chat := paths["/v1/chat/completions"].(map[string]interface{})["post"].(map[string]interface{})
+	ok := chat["responses"].(map[string]interface{})["200"].(map[string]interface{})
+	if _, found := ok["headers"].(map[string]interface{})["x-gw-provider"]; !found {
+		t.Error("chat completions do not document x-gw-provider")
+	}
+
+	// Every reference resolves.
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if r, ok := v["$ref"].(string); ok {
+				var target interface{} = doc
+				for _, part := range strings.Split(strings.TrimPrefix(r, "#/"), "/") {
+					m, _ := target.(map[string]interface{})
+					target = m[part]
+				}
+				if target == nil {
+					t.Errorf("unresolved $ref %s", r)
+				}
+			}
+			for _, c := range v {
+				walk(c)
+			}
+		case []interface{}:
+			for _, c := range v {
+				walk(c)
+			}
+		}
+	}
+	walk(doc)
+}
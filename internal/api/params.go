@@ -0,0 +1,136 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+// paramChange is a parameter a route's param policy changed.
+type paramChange struct {
+	Param string   `json:"param"`
+	From  *float64 `json:"from"` // nil when the client left it unset
+	To    float64  `json:"to"`
+}
+
+// applyParamPolicy moves temperature, top_p and max_tokens into the ranges
+// the policy allows and returns what it changed. With the reject action a
+// value the client sent outside a range refuses the request instead. Unset
+// values are moved into range too: temperature counts as 0, the value
+// sent when a client leaves it out, top_p as 1, the providers' default,
+// and max_tokens as unlimited. clientMax is the client's own max_tokens;
+// a value set by the output budget or a prediction is always clamped.
+func applyParamPolicy(req *ChatRequest, policy *config.ParamPolicy, clientMax int) ([]paramChange, error) {
+	if policy == nil {
+		return nil, nil
+	}
+	var changes []paramChange
+	apply := func(name string, r *config.ParamRange, v float64, sent bool) (float64, error) {
+		to := clampParam(r, v)
+		if to == v {
+			return v, nil
+		}
+		if sent && policy.Action == "reject" {
+			return v, fmt.Errorf("%s %s is outside the range allowed on this route (%s)", name, formatParam(v), describeRange(r))
+		}
+		change := paramChange{Param: name, To: to}
+		if sent {
+			change.From = &v
+		}
+		changes = append(changes, change)
+		return to, nil
+	}
+
+	temperature := 0.0
+	if req.Temperature != nil {
+		temperature = *req.Temperature
+	}
+	if to, err := apply("temperature", policy.Temperature, temperature, req.Temperature != nil); err != nil {
+		return nil, err
+	} else if to != temperature {
+		req.Temperature = &to
+	}
+	topP := 1.0
+	if req.TopP != nil {
+		topP = *req.TopP
+	}
+	if to, err := apply("top_p", policy.TopP, topP, req.TopP != nil); err != nil {
+		return nil, err
+	} else if to != topP {
+		req.TopP = &to
+	}
+	maxTokens := math.Inf(1)
+	if req.MaxTokens > 0 {
+		maxTokens = float64(req.MaxTokens)
+	}
+	if to, err := apply("max_tokens", policy.MaxTokens, maxTokens, clientMax > 0); err != nil {
+		return nil, err
+	} else if to != maxTokens {
+		req.MaxTokens = int(to)
+	}
+	return changes, nil
+}
+
+// clampParam returns v moved into r, or v when r is nil.
+func clampParam(r *config.ParamRange, v float64) float64 {
+	if r == nil {
+		return v
+	}
+	if r.Value != nil {
+		return *r.Value
+	}
+	if r.Min != nil && v < *r.Min {
+		v = *r.Min
+	}
+	if r.Max != nil && v > *r.Max {
+		v = *r.Max
+	}
+	return v
+}
+
+func describeRange(r *config.ParamRange) string {
+	switch {
+	case r.Value != nil:
+		return "must be " + formatParam(*r.Value)
+	case r.Min != nil && r.Max != nil:
+		return formatParam(*r.Min) + " to " + formatParam(*r.Max)
+	case r.Min != nil:
+		return "at least " + formatParam(*r.Min)
+	default:
+		return "at most " + formatParam(*r.Max)
+	}
+}
+
+func formatParam(v float64) string {
+	if math.IsInf(v, 1) {
+		return "unlimited"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// validateParamPolicy rejects param policies that allow no value.
+func validateParamPolicy(p *config.ParamPolicy) error {
+	if p == nil {
+		return nil
+	}
+	if p.Action != "" && p.Action != "clamp" && p.Action != "reject" {
+		return fmt.Errorf("unknown param_policy action %q", p.Action)
+	}
+	for name, r := range map[string]*config.ParamRange{"temperature": p.Temperature, "top_p": p.TopP, "max_tokens": p.MaxTokens} {
+		if r == nil {
+			continue
+		}
+		if r.Value == nil && r.Min == nil && r.Max == nil {
+			return fmt.Errorf("param_policy %s needs a min, max or value", name)
+		}
+		if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			return fmt.Errorf("param_policy %s min is above its max", name)
+		}
+		if name == "max_tokens" && r.Value != nil && *r.Value < 1 {
+			return fmt.Errorf("param_policy max_tokens value must be at least 1")
+		}
+	}
+	return nil
+}
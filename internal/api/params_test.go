@@ -0,0 +1,81 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+func TestApplyParamPolicy(t *testing.T) {
+	policy := &config.ParamPolicy{
+		Temperature: &config.ParamRange{Max: ptr(0.3)},
+		TopP:        &config.ParamRange{Max: ptr(0.9)},
+		MaxTokens:   &config.ParamRange{Min: ptr(16), Max: ptr(1024)},
+	}
+
+	req := ChatRequest{Temperature: ptr(0.9), TopP: ptr(0.5), MaxTokens: 4}
+	changes, err := applyParamPolicy(&req, policy, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *req.Temperature != 0.3 || *req.TopP != 0.5 || req.MaxTokens != 16 {
+		t.Errorf("unexpected params %v %v %d", *req.Temperature, *req.TopP, req.MaxTokens)
+	}
+	if len(changes) != 2 || changes[0].Param != "temperature" || *changes[0].From != 0.9 || changes[1].Param != "max_tokens" || changes[1].To != 16 {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+
+	// Unset top_p and max_tokens are brought within range too.
+	req = ChatRequest{}
+	changes, err = applyParamPolicy(&req, policy, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.TopP == nil || *req.TopP != 0.9 || req.MaxTokens != 1024 || len(changes) != 2 || changes[0].From != nil {
+		t.Errorf("expected top_p 0.9 and max_tokens 1024 set, got %+v and changes %+v", req, changes)
+	}
+
+	// Within range nothing changes.
+	req = ChatRequest{Temperature: ptr(0.2), TopP: ptr(0.9), MaxTokens: 100}
+	if changes, _ := applyParamPolicy(&req, policy, 100); len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestApplyParamPolicyReject(t *testing.T) {
+	policy := &config.ParamPolicy{Action: "reject", Temperature: &config.ParamRange{Value: ptr(0)}, MaxTokens: &config.ParamRange{Max: ptr(500)}}
+
+	req := ChatRequest{Temperature: ptr(0.7)}
+	if _, err := applyParamPolicy(&req, policy, 0); err == nil || err.Error() != "temperature 0.7 is outside the range allowed on this route (must be 0)" {
+		t.Errorf("expected the client's temperature refused, got %v", err)
+	}
+	req = ChatRequest{MaxTokens: 800}
+	if _, err := applyParamPolicy(&req, policy, 800); err == nil {
+		t.Error("expected the client's max_tokens refused")
+	}
+
+	// A value the client did not send is clamped even when rejecting.
+	req = ChatRequest{MaxTokens: 800}
+	changes, err := applyParamPolicy(&req, policy, 0)
+	if err != nil || req.MaxTokens != 500 || len(changes) != 1 {
+		t.Errorf("expected a predicted max_tokens clamped, got %d, %+v, %v", req.MaxTokens, changes, err)
+	}
+}
+
+func TestValidateParamPolicy(t *testing.T) {
+	for _, p := range []*config.ParamPolicy{
+		{Action: "drop"},
+		{Temperature: &config.ParamRange{}},
+		{TopP: &config.ParamRange{Min: ptr(0.9), Max: ptr(0.1)}},
+		{MaxTokens: &config.ParamRange{Value: ptr(0)}},
+	} {
+		if err := validateParamPolicy(p); err == nil {
+			t.Errorf("expected %+v to be refused", p)
+		}
+	}
+	if err := validateParamPolicy(&config.ParamPolicy{Action: "clamp", TopP: &config.ParamRange{Max: ptr(0.9)}}); err != nil {
+		t.Error(err)
+	}
+}
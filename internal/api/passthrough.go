@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"maps"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// backendParams returns the params of a chat request body that ChatRequest
+// has no field for. They only go to providers that pass them through.
+func backendParams(body []byte) map[string]interface{} {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil
+	}
+	for name := range fields {
+		if chatRequestFields[name] {
+			delete(fields, name)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// targetRequest builds the upstream request for target from the client's
+// parameters, adding the backend params its provider passes through, then
+// applies the target's params.
+func (h *Handler) targetRequest(req ChatRequest, messages []providers.Message, route config.Route, target config.Target) (providers.ChatRequest, error) {
+	provReq := req.providerRequest(target.Model, messages)
+	opts := h.providerOpts[target.Provider]
+	var passed map[string]interface{}
+	for name, v := range req.backend {
+		if opts.Passes(name) {
+			if passed == nil {
+				passed = map[string]interface{}{}
+			}
+			passed[name] = v
+		}
+	}
+	if passed != nil {
+		maps.Copy(passed, provReq.Extra) // a route transform's params win
+		provReq.Extra = passed
+	}
+	return provReq.WithParams(h.targetParams(route, target))
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/health"
+)
+
+func TestTargetRequestPassthrough(t *testing.T) {
+	body := []byte(`{"model":"llama","messages":[{"role":"user","content":"hi"}],"temperature":0.2,"best_of":3,"guided_choice":["yes","no"],"priority":-1,"top_k":5}`)
+	var req ChatRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatal(err)
+	}
+	req.backend = backendParams(body)
+	if len(req.backend) != 4 {
+		t.Fatalf("expected the four unknown params kept, got %v", req.backend)
+	}
+	req.extra = map[string]interface{}{"priority": 10}
+
+	h := &Handler{providerOpts: map[string]config.ProviderOptions{
+		"vllm": {Passthrough: []string{"vllm"}},
+		"tgi":  {Passthrough: []string{"top_k"}},
+	}}
+	route := config.Route{}
+	check := func(provider string, want map[string]interface{}) {
+		t.Helper()
+		provReq, err := h.targetRequest(req, req.Messages, route, config.Target{Provider: provider, Model: "m", Params: map[string]interface{}{"seed": 1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := json.Marshal(provReq.Extra)
+		exp, _ := json.Marshal(want)
+		if string(got) != string(exp) {
+			t.Errorf("%s: expected extra %s, got %s", provider, exp, got)
+		}
+		if provReq.Seed == nil || *provReq.Seed != 1 {
+			t.Errorf("%s: expected the target params applied", provider)
+		}
+	}
+	check("vllm", map[string]interface{}{"best_of": 3, "guided_choice": []string{"yes", "no"}, "priority": 10})
+	check("tgi", map[string]interface{}{"priority": 10, "top_k": 5})
+	check("openai", map[string]interface{}{"priority": 10})
+}
+
+func TestOrderByQueue(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "vllm:num_requests_waiting{model_name=\"llama\"} 12\n")
+	}))
+	defer srv.Close()
+
+	tracker := health.NewTracker(health.NewMemoryStore(), 0, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tracker.WatchQueue(ctx, "vllm-a", health.QueueSource{URL: srv.URL, Metric: "vllm:num_requests_waiting", Interval: time.Minute})
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, ok := tracker.QueueDepth("vllm-a"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("queue depth never read")
+		}
+	}
+
+	h := &Handler{health: tracker, providerOpts: map[string]config.ProviderOptions{
+		"vllm-a": {Queue: &config.BackendQueue{MetricsURL: srv.URL, MaxWaiting: 8}},
+		"vllm-b": {Queue: &config.BackendQueue{MetricsURL: srv.URL, MaxWaiting: 8}},
+	}}
+	targets := []config.Target{{Provider: "vllm-a"}, {Provider: "vllm-b"}, {Provider: "openai"}}
+	got := h.orderByQueue(context.Background(), targets)
+	if got[0].Provider != "vllm-b" || got[1].Provider != "openai" || got[2].Provider != "vllm-a" {
+		t.Errorf("expected the backlogged backend tried last, got %+v", got)
+	}
+
+	h.providerOpts["vllm-a"] = config.ProviderOptions{Queue: &config.BackendQueue{MetricsURL: srv.URL, MaxWaiting: 20}}
+	if got := h.orderByQueue(context.Background(), targets); got[0].Provider != "vllm-a" {
+		t.Errorf("expected the configured order under max_waiting, got %+v", got)
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/rbac"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// capturedPayload is what payload capture stores for a request.
+type capturedPayload struct {
+	Messages []providers.Message `json:"messages"`
+	// Response is the response as sent to the client; for streams, the
+	// assembled completion text.
+	Response interface{} `json:"response"`
+}
+
+// capturePayload stores the request's messages and response encrypted with
+// the tenant's data key, when payload capture is on. Failures are logged;
+// they never fail the request.
+func (h *Handler) capturePayload(ctx context.Context, requestID, tenant string, messages []providers.Message, response interface{}) {
+	if h.payloads == nil {
+		return
+	}
+	plain, err := json.Marshal(capturedPayload{Messages: messages, Response: response})
+	if err != nil {
+		logError(requestID, "failed to encode payload", err)
+		return
+	}
+	keyID, ciphertext, err := h.payloads.Seal(ctx, tenant, plain)
+	if err != nil {
+		logError(requestID, "failed to encrypt payload", err)
+		return
+	}
+	if err := h.usage.SavePayload(ctx, requestID, usage.SealedPayload{Tenant: tenant, DataKeyID: keyID, Ciphertext: ciphertext}); err != nil {
+		logError(requestID, "failed to store payload", err)
+	}
+}
+
+// HandleGetPayload decrypts the captured payload of a request. It needs the
+// payload-reader role for the request's tenant, so it is unavailable
+// without AUTH_MODE=rbac.
+func (h *Handler) HandleGetPayload(w http.ResponseWriter, r *http.Request) {
+	requestID := chi.URLParam(r, "request_id")
+	tenant, plain, ok := h.openPayload(w, r, requestID)
+	if !ok {
+		return
+	}
+	h.usage.LogEvent(r.Context(), requestID, usage.Event{
+		Kind:   "payload_decrypted",
+		Detail: map[string]interface{}{"tenant": tenant},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"request_id": requestID,
+		"tenant":     tenant,
+		"payload":    json.RawMessage(plain),
+	})
+}
+
+// openPayload loads and decrypts a request's captured payload for a caller
+// with the payload-reader role for its tenant. It writes the error
+// response and returns false when it cannot.
+func (h *Handler) openPayload(w http.ResponseWriter, r *http.Request, requestID string) (string, []byte, bool) {
+	if h.auth.Mode != "rbac" {
+		h.respondError(w, http.StatusForbidden, "decrypting payloads requires AUTH_MODE=rbac", requestID)
+		return "", nil, false
+	}
+	if h.payloads == nil {
+		h.respondError(w, http.StatusNotFound, "payload capture is disabled", requestID)
+		return "", nil, false
+	}
+
+	sealed, err := h.usage.Payload(r.Context(), requestID)
+	if errors.Is(err, usage.ErrNoPayload) {
+		// Authorize before revealing whether a payload exists.
+		if _, ok := h.authorize(w, r, rbac.PayloadDecrypt, ""); ok {
+			h.respondError(w, http.StatusNotFound, "no payload captured for request", requestID)
+		}
+		return "", nil, false
+	}
+	if err != nil {
+		logError(requestID, "failed to load payload", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to load payload", requestID)
+		return "", nil, false
+	}
+	if _, ok := h.authorize(w, r, rbac.PayloadDecrypt, sealed.Tenant); !ok {
+		return "", nil, false
+	}
+
+	plain, err := h.payloads.Open(r.Context(), sealed.Tenant, sealed.DataKeyID, sealed.Ciphertext)
+	if err != nil {
+		logError(requestID, "failed to decrypt payload", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to decrypt payload", requestID)
+		return "", nil, false
+	}
+	return sealed.Tenant, plain, true
+}
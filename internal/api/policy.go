@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+// errPolicyViolation is returned when a tenant's policy or the request's
+// residency allows none of a route's targets.
+type errPolicyViolation struct {
+	tenant  string
+	route   string
+	reasons []string
+}
+
+func (e *errPolicyViolation) Error() string {
+	return fmt.Sprintf("policy violation: tenant %s may not use any target of route %s (%s)", e.tenant, e.route, strings.Join(e.reasons, "; "))
+}
+
+// requestResidency reads the X-GW-Residency header, the region the request
+// must be processed in, defaulting to the tenant's residency. A tenant with
+// a residency may not ask for another one. The returned status is the one
+// to fail the request with when err is set.
+func requestResidency(r *http.Request, tenant config.Tenant) (string, int, error) {
+	return residencyFor(r.Header.Get("X-GW-Residency"), tenant)
+}
+
+// residencyFor checks a requested residency against the tenant's.
+func residencyFor(requested string, tenant config.Tenant) (string, int, error) {
+	residency := strings.ToLower(strings.TrimSpace(requested))
+	if residency == "" {
+		return tenant.Residency, 0, nil
+	}
+	if tenant.Residency != "" && residency != tenant.Residency {
+		return "", http.StatusForbidden, fmt.Errorf("residency %q is not allowed for this tenant (requires %q)", residency, tenant.Residency)
+	}
+	return residency, 0, nil
+}
+
+// restrictTargets drops the route's targets the tenant's policy does not
+// allow, whose provider is outside residency (a region, or "" for any) or,
+// when the route or tenant requires zero retention, whose provider has no
+// such agreement. The first remaining fallback is promoted when the
+// primary is dropped, and the returned route requires zero retention if
+// either did.
+func restrictTargets(route config.Route, tenant config.Tenant, residency string, providerOpts map[string]config.ProviderOptions) (config.Route, error) {
+	route.ZeroRetention = route.ZeroRetention || tenant.ZeroRetention
+	if tenant.Allowed == nil && residency == "" && !route.ZeroRetention {
+		return route, nil
+	}
+	var allowed []config.Target
+	var reasons []string
+	for _, t := range append([]config.Target{route.Primary}, route.Fallbacks...) {
+		region := providerOpts[t.Provider].Region
+		reason := ""
+		if tenant.Allowed != nil {
+			reason = tenant.Allowed.Check(t, region)
+		}
+		if reason == "" && residency != "" && region != residency {
+			reason = fmt.Sprintf("region %q of provider %s does not meet residency %s", region, t.Provider, residency)
+		}
+		if zdr := providerOpts[t.Provider].ZeroRetention; reason == "" && route.ZeroRetention && (zdr == nil || !zdr.Agreement) {
+			reason = fmt.Sprintf("provider %s has no zero-retention agreement", t.Provider)
+		}
+		if reason != "" {
+			reasons = append(reasons, t.Provider+"/"+t.Model+": "+reason)
+			continue
+		}
+		allowed = append(allowed, t)
+	}
+	if len(allowed) == 0 {
+		return route, &errPolicyViolation{tenant: tenant.Name, route: route.Name, reasons: reasons}
+	}
+	route.Primary, route.Fallbacks = allowed[0], allowed[1:]
+	return route, nil
+}
+
+// region returns the region a provider declares, or "".
+func (h *Handler) region(provider string) string {
+	return h.providerOpts[provider].Region
+}
+
+// setRegion reports the serving provider's region, when it declares one.
+func setRegion(w http.ResponseWriter, region string) {
+	if region != "" {
+		w.Header().Set("x-gw-region", region)
+	}
+}
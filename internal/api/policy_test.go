@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func TestRestrictTargets(t *testing.T) {
+	route := config.Route{
+		Name:    "support",
+		Primary: config.Target{Provider: "openai", Model: "gpt-4o"},
+		Fallbacks: []config.Target{
+			{Provider: "azure-eu", Model: "gpt-4o"},
+			{Provider: "mistral", Model: "mistral-large"},
+			{Provider: "anthropic", Model: "claude-3-5-sonnet"},
+		},
+	}
+	providerOpts := map[string]config.ProviderOptions{
+		"openai":   {Region: "us"},
+		"azure-eu": {Region: "eu"},
+		"mistral":  {Region: "eu"},
+	}
+
+	eu := config.Tenant{Name: "acme", Allowed: &config.ProviderPolicy{Regions: []string{"eu"}, Models: []string{"gpt-*"}}}
+	got, err := restrictTargets(route, eu, "", providerOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Primary.Provider != "azure-eu" || len(got.Fallbacks) != 0 {
+		t.Errorf("expected only azure-eu, got %+v then %+v", got.Primary, got.Fallbacks)
+	}
+
+	got, err = restrictTargets(route, config.Tenant{Name: "globex"}, "", providerOpts)
+	if err != nil || got.Primary.Provider != "openai" || len(got.Fallbacks) != 3 {
+		t.Errorf("expected a tenant without a policy to keep every target, got %+v, %v", got, err)
+	}
+
+	strict := config.Tenant{Name: "acme", Allowed: &config.ProviderPolicy{Providers: []string{"cohere"}}}
+	_, err = restrictTargets(route, strict, "", providerOpts)
+	if err == nil || !strings.Contains(err.Error(), "policy violation") || !strings.Contains(err.Error(), "provider openai is not allowed") {
+		t.Errorf("expected a policy violation naming the rejected targets, got %v", err)
+	}
+}
+
+func TestResidency(t *testing.T) {
+	route := config.Route{
+		Name:      "support",
+		Primary:   config.Target{Provider: "openai", Model: "gpt-4o"},
+		Fallbacks: []config.Target{{Provider: "anthropic", Model: "claude"}, {Provider: "mistral", Model: "mistral-large"}},
+	}
+	providerOpts := map[string]config.ProviderOptions{"openai": {Region: "us"}, "mistral": {Region: "eu"}}
+
+	got, err := restrictTargets(route, config.Tenant{Name: "acme"}, "eu", providerOpts)
+	if err != nil || got.Primary.Provider != "mistral" || len(got.Fallbacks) != 0 {
+		t.Errorf("expected only mistral to meet eu residency, got %+v, %v", got, err)
+	}
+	if _, err := restrictTargets(route, config.Tenant{Name: "acme"}, "uk", providerOpts); err == nil {
+		t.Error("expected no target to meet uk residency")
+	}
+
+	tenant := config.Tenant{Residency: "eu"}
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	if residency, _, err := requestResidency(r, tenant); residency != "eu" || err != nil {
+		t.Errorf("expected the tenant's residency by default, got %q, %v", residency, err)
+	}
+	r.Header.Set("X-GW-Residency", "US")
+	if _, status, err := requestResidency(r, tenant); status != http.StatusForbidden || err == nil {
+		t.Errorf("expected a different residency to be refused, got %d, %v", status, err)
+	}
+	if residency, _, _ := requestResidency(r, config.Tenant{}); residency != "us" {
+		t.Errorf("expected the header's residency, got %q", residency)
+	}
+}
+
+func TestZeroRetention(t *testing.T) {
+	route := config.Route{
+		Name:      "support",
+		Primary:   config.Target{Provider: "openai", Model: "gpt-4o"},
+		Fallbacks: []config.Target{{Provider: "anthropic", Model: "claude", Params: map[string]interface{}{"temperature": 0.2}}},
+	}
+	h := &Handler{providerOpts: map[string]config.ProviderOptions{
+		"openai": {ZeroRetention: &config.ZeroRetention{}},
+		"anthropic": {ZeroRetention: &config.ZeroRetention{
+			Agreement: true,
+			Headers:   map[string]string{"x-zdr": "{{.Tenant}}"},
+			Params:    map[string]interface{}{"store": false, "temperature": 1.0},
+		}},
+	}}
+
+	got, err := restrictTargets(route, config.Tenant{Name: "acme", ZeroRetention: true}, "", h.providerOpts)
+	if err != nil || got.Primary.Provider != "anthropic" || !got.ZeroRetention {
+		t.Fatalf("expected only anthropic to serve a zero-retention tenant, got %+v, %v", got, err)
+	}
+	params := h.targetParams(got, got.Primary)
+	if params["store"] != false || params["temperature"] != 0.2 {
+		t.Errorf("expected zero-retention params under the target's, got %v", params)
+	}
+	headers := h.outboundHeaders(context.Background(), ChatRequest{}, got.Primary, headerData{Tenant: "acme", zeroRetention: true})
+	if headers["X-Zdr"] != "acme" {
+		t.Errorf("expected the zero-retention header, got %v", headers)
+	}
+
+	got, err = restrictTargets(route, config.Tenant{Name: "acme"}, "", h.providerOpts)
+	if err != nil || got.Primary.Provider != "openai" || got.ZeroRetention {
+		t.Errorf("expected targets untouched without a requirement, got %+v, %v", got, err)
+	}
+	if params := h.targetParams(got, got.Fallbacks[0]); params["store"] != nil {
+		t.Errorf("expected no zero-retention params without a requirement, got %v", params)
+	}
+}
+
+func TestProviderPolicyCheck(t *testing.T) {
+	p := config.ProviderPolicy{Regions: []string{"eu"}}
+	if reason := p.Check(config.Target{Provider: "anthropic", Model: "claude"}, ""); reason != "provider anthropic has no region" {
+		t.Errorf("expected a provider without a region to be refused, got %q", reason)
+	}
+	if reason := p.Check(config.Target{Provider: "mistral", Model: "m"}, "eu"); reason != "" {
+		t.Errorf("expected eu to be allowed, got %q", reason)
+	}
+}
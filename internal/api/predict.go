@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/predict"
+)
+
+// predictionQuery fills in the defaults of a route's max_tokens prediction.
+func predictionQuery(useCase string, p config.MaxTokensPrediction) (q predict.Query, headroom float64, minSamples int) {
+	q = predict.Query{UseCase: useCase, Percentile: p.Percentile, Lookback: time.Duration(p.LookbackHours) * time.Hour}
+	if q.Percentile <= 0 || q.Percentile > 1 {
+		q.Percentile = 0.99
+	}
+	if q.Lookback <= 0 {
+		q.Lookback = 7 * 24 * time.Hour
+	}
+	headroom, minSamples = p.Headroom, p.MinSamples
+	if headroom < 1 {
+		headroom = 1.2
+	}
+	if minSamples <= 0 {
+		minSamples = 100
+	}
+	return q, headroom, minSamples
+}
+
+// predictMaxTokens lowers max_tokens on a request whose client left it
+// unset (clientMax is 0) to the length predicted for its use case, never
+// raising a value the output budget already set. It returns the value
+// applied, or 0 when there was no prediction to apply.
+func (h *Handler) predictMaxTokens(ctx context.Context, req *ChatRequest, clientMax int, route config.Route, useCase string) int {
+	if route.PredictMaxTokens == nil || clientMax > 0 {
+		return 0
+	}
+	q, headroom, minSamples := predictionQuery(useCase, *route.PredictMaxTokens)
+	tokens, ok := h.lengths.Predict(ctx, q, minSamples)
+	if !ok {
+		return 0
+	}
+	predicted := int(float64(tokens)*headroom + 0.5)
+	if req.MaxTokens > 0 && predicted >= req.MaxTokens {
+		return 0
+	}
+	req.MaxTokens = predicted
+	return predicted
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/predict"
+)
+
+func TestPredictMaxTokens(t *testing.T) {
+	h := &Handler{lengths: predict.New(time.Minute, func(ctx context.Context, useCase string, percentile float64, since time.Time) (int, int, error) {
+		return 500, 200, nil
+	})}
+	ctx := context.Background()
+	route := config.Route{PredictMaxTokens: &config.MaxTokensPrediction{}}
+
+	req := ChatRequest{}
+	if got := h.predictMaxTokens(ctx, &req, 0, route, "chat"); got != 600 || req.MaxTokens != 600 {
+		t.Errorf("expected 500 tokens plus 20%% headroom, got %d (max_tokens %d)", got, req.MaxTokens)
+	}
+
+	req = ChatRequest{MaxTokens: 2000}
+	if got := h.predictMaxTokens(ctx, &req, 2000, route, "chat"); got != 0 || req.MaxTokens != 2000 {
+		t.Errorf("expected the client's max_tokens to win, got %d", req.MaxTokens)
+	}
+
+	// A tighter output budget is kept.
+	req = ChatRequest{MaxTokens: 256}
+	if got := h.predictMaxTokens(ctx, &req, 0, route, "chat"); got != 0 || req.MaxTokens != 256 {
+		t.Errorf("expected the output budget to stand, got %d", req.MaxTokens)
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/yewintnaing/ai-gateway/internal/concurrency"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+// requestPriority reads the X-GW-Priority header, which decides the order
+// in which requests waiting on a saturated target get a slot. A tenant may
+// not ask for more than its max_priority. The returned status is the one to
+// fail the request with when err is set.
+func requestPriority(r *http.Request, tenant config.Tenant) (concurrency.Priority, int, error) {
+	p, err := concurrency.ParsePriority(r.Header.Get("X-GW-Priority"))
+	if err != nil {
+		return p, http.StatusBadRequest, err
+	}
+	allowed, err := concurrency.ParsePriority(tenant.MaxPriority)
+	if err != nil {
+		allowed = concurrency.Standard
+	}
+	if p > allowed {
+		return p, http.StatusForbidden, fmt.Errorf("priority %q is not allowed for this tenant (max %q)", p, allowed)
+	}
+	return p, 0, nil
+}
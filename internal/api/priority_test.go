@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/concurrency"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func TestRequestPriority(t *testing.T) {
+	tests := []struct {
+		header string
+		max    string
+		want   concurrency.Priority
+		status int
+	}{
+		{"", "", concurrency.Standard, 0},
+		{"batch", "", concurrency.Batch, 0},
+		{"interactive", "", 0, http.StatusForbidden},
+		{"interactive", "interactive", concurrency.Interactive, 0},
+		{"standard", "batch", 0, http.StatusForbidden},
+		{"urgent", "interactive", 0, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if tt.header != "" {
+			r.Header.Set("X-GW-Priority", tt.header)
+		}
+		p, status, err := requestPriority(r, config.Tenant{MaxPriority: tt.max})
+		if status != tt.status || (err == nil) != (tt.status == 0) {
+			t.Errorf("%q with max %q: status %d, err %v; want status %d", tt.header, tt.max, status, err, tt.status)
+			continue
+		}
+		if err == nil && p != tt.want {
+			t.Errorf("%q with max %q: got %v, want %v", tt.header, tt.max, p, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/probe"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// Probe sends a synthetic prompt to one target of route, with the route's
+// system prompt and target params as live requests get, and returns the
+// completion. It is logged as its own request with the probe's name in
+// its metadata, so its cost is accounted for, and its failures count
+// towards the provider's health.
+func (h *Handler) Probe(ctx context.Context, name string, route config.Route, target config.Target, prompt string, maxTokens int) (string, string, error) {
+	requestID := uuid.New().String()
+	record := usage.Record{RequestID: requestID, RouteName: route.Name, Metadata: map[string]interface{}{"probe": name}}
+	h.usage.Log(ctx, record)
+
+	req := ChatRequest{Messages: []providers.Message{{Role: "user", Content: prompt}}, MaxTokens: maxTokens}
+	if route.SystemPrompt != nil {
+		req.Messages = applySystemPrompt(req.Messages, *route.SystemPrompt)
+	}
+	start := time.Now()
+	resp, err := h.callTarget(ctx, req, req.Messages, route, target, requestID, "", "")
+	_, account := h.providerAccount(route, "", target)
+	h.usage.LogAttempt(ctx, requestID, usage.Attempt{
+		RequestID: requestID, AttemptNo: 1, Provider: target.Provider, Model: target.Model,
+		LatencyMS: int(time.Since(start).Milliseconds()), StatusCode: getStatusCode(err, resp != nil), ErrorMessage: getErrorMessage(err),
+		Organization: account.Organization, Project: account.Project,
+	})
+
+	record.Provider, record.Model, record.Region = target.Provider, target.Model, h.region(target.Provider)
+	record.LatencyMS, record.StatusCode, record.ErrorMessage = int(time.Since(start).Milliseconds()), getStatusCode(err, resp != nil), getErrorMessage(err)
+	if err != nil {
+		h.usage.Log(ctx, record)
+		return "", requestID, err
+	}
+	record.PromptTokens, record.CompletionTokens, record.TotalTokens = resp.Usage.PromptTokens, resp.Usage.CompletionTokens, resp.Usage.TotalTokens
+	h.usage.Log(ctx, record)
+	if len(resp.Choices) == 0 {
+		return "", requestID, nil
+	}
+	return resp.Choices[0].Message.Content, requestID, nil
+}
+
+// HandleListProbes returns recent probe results, newest first, filtered
+// by the probe, route and failed query parameters.
+func (h *Handler) HandleListProbes(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	results, err := h.usage.ListProbeResults(r.Context(), q.Get("probe"), q.Get("route"), q.Get("failed") == "true", 500)
+	if err != nil {
+		logError("", "failed to list probe results", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to list probe results", "")
+		return
+	}
+	if results == nil {
+		results = []probe.Result{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+}
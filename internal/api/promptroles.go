@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// promptRoles splits a prompt's total token count between system content,
+// user messages and conversation history, in proportion to the length of
+// each. Providers only report the total, so the split is an estimate that
+// always adds up to it.
+func promptRoles(req providers.ChatRequest, total int) usage.PromptRoles {
+	var system, user, history int
+	for _, m := range req.Messages {
+		n := len(m.Content)
+		switch m.Role {
+		case "system", "developer":
+			system += n
+		case "user":
+			user += n
+		default:
+			for _, tc := range m.ToolCalls {
+				n += len(tc.Function.Name) + len(tc.Function.Arguments)
+			}
+			history += n
+		}
+	}
+	for _, t := range req.Tools {
+		if data, err := json.Marshal(t); err == nil {
+			system += len(data)
+		}
+	}
+
+	all := system + user + history
+	if all == 0 || total <= 0 {
+		return usage.PromptRoles{}
+	}
+	r := usage.PromptRoles{
+		System: total * system / all,
+		User:   total * user / all,
+	}
+	r.History = total - r.System - r.User
+	return r
+}
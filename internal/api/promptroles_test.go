@@ -0,0 +1,34 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestPromptRoles(t *testing.T) {
+	req := providers.ChatRequest{Messages: []providers.Message{
+		{Role: "system", Content: strings.Repeat("s", 600)},
+		{Role: "user", Content: strings.Repeat("u", 200)},
+		{Role: "assistant", Content: strings.Repeat("a", 100)},
+		{Role: "user", Content: strings.Repeat("u", 100)},
+	}}
+	got := promptRoles(req, 100)
+	if got.System != 60 || got.User != 30 || got.History != 10 {
+		t.Errorf("expected 60/30/10, got %+v", got)
+	}
+
+	// Tool definitions count as system overhead, and the parts always add
+	// up to the provider's total.
+	req.Tools = []providers.Tool{{Type: "function", Function: providers.ToolFunction{Name: "lookup", Parameters: json.RawMessage(`{"type":"object"}`)}}}
+	got = promptRoles(req, 333)
+	if got.System+got.User+got.History != 333 || got.System <= 199 {
+		t.Errorf("unexpected split %+v", got)
+	}
+
+	if got := promptRoles(providers.ChatRequest{}, 50); got.System != 0 || got.History != 0 {
+		t.Errorf("expected no split for an empty prompt, got %+v", got)
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/observability"
+)
+
+// headerData is what provider header templates can refer to.
+type headerData struct {
+	Tenant    string
+	UseCase   string
+	Route     string
+	RequestID string
+	Model     string
+
+	zeroRetention bool              // add the provider's zero-retention headers
+	transform     *config.Transform // the route's header rules, applied last
+}
+
+var headerFuncs = template.FuncMap{"env": os.Getenv}
+
+// outboundHeaders returns the extra headers for a call to target: trace
+// context, any inbound headers the provider is set to forward, and its
+// configured headers, which take precedence, then the route transform's
+// header rules.
+func (h *Handler) outboundHeaders(ctx context.Context, req ChatRequest, target config.Target, data headerData) map[string]string {
+	headers := observability.TraceHeaders(ctx)
+	data.Model = target.Model
+	opts, ok := h.providerOpts[target.Provider]
+	if !ok {
+		return transformHeaders(headers, data.transform, data)
+	}
+	if headers == nil {
+		headers = map[string]string{}
+	}
+
+	for _, name := range opts.ForwardHeaders {
+		if v := req.inbound.Get(name); v != "" {
+			headers[http.CanonicalHeaderKey(name)] = v
+		}
+	}
+
+	for name, value := range opts.Headers {
+		rendered, err := renderHeader(value, data)
+		if err != nil {
+			logError(data.RequestID, "provider header "+name+" not rendered", err)
+			continue
+		}
+		headers[http.CanonicalHeaderKey(name)] = rendered
+	}
+	if data.zeroRetention && opts.ZeroRetention != nil {
+		for name, value := range opts.ZeroRetention.Headers {
+			rendered, err := renderHeader(value, data)
+			if err != nil {
+				logError(data.RequestID, "zero-retention header "+name+" not rendered", err)
+				continue
+			}
+			headers[http.CanonicalHeaderKey(name)] = rendered
+		}
+	}
+	return transformHeaders(headers, data.transform, data)
+}
+
+// targetParams returns the params to apply to a call to target: its own,
+// plus its provider's zero-retention params when the route requires zero
+// retention. The target's params win.
+func (h *Handler) targetParams(route config.Route, target config.Target) map[string]interface{} {
+	zdr := h.providerOpts[target.Provider].ZeroRetention
+	if !route.ZeroRetention || zdr == nil || len(zdr.Params) == 0 {
+		return target.Params
+	}
+	params := maps.Clone(zdr.Params)
+	maps.Copy(params, target.Params)
+	return params
+}
+
+func renderHeader(value string, data headerData) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+	tmpl, err := template.New("header").Funcs(headerFuncs).Parse(value)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// providerAccount returns the name of the account a call to target is
+// billed to, and its organization and project.
+func (h *Handler) providerAccount(route config.Route, tenant string, target config.Target) (string, config.ProviderAccount) {
+	name := config.AccountFor(route, h.tenants[tenant], target.Provider)
+	return name, h.providerOpts[target.Provider].Account(name)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func TestOutboundHeaders(t *testing.T) {
+	t.Setenv("OPENAI_PROJECT", "proj_123")
+	h := &Handler{providerOpts: map[string]config.ProviderOptions{
+		"openai": {
+			Headers: map[string]string{
+				"OpenAI-Organization": "org-abc",
+				"OpenAI-Project":      `{{env "OPENAI_PROJECT"}}`,
+				"x-gw-caller":         "{{.Tenant}}/{{.UseCase}} via {{.Route}} ({{.Model}})",
+				"x-correlation-id":    "gateway",
+			},
+			ForwardHeaders: []string{"X-Correlation-Id", "X-Department"},
+		},
+	}}
+	req := ChatRequest{inbound: http.Header{"X-Correlation-Id": {"abc"}, "X-Department": {"legal"}, "Authorization": {"Bearer secret"}}}
+	data := headerData{Tenant: "acme", UseCase: "support", Route: "support-route", RequestID: "req-1"}
+
+	got := h.outboundHeaders(context.Background(), req, config.Target{Provider: "openai", Model: "gpt-4o"}, data)
+	want := map[string]string{
+		"Openai-Organization": "org-abc",
+		"Openai-Project":      "proj_123",
+		"X-Gw-Caller":         "acme/support via support-route (gpt-4o)",
+		"X-Correlation-Id":    "gateway",
+		"X-Department":        "legal",
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %d headers, got %v", len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, got[k])
+		}
+	}
+
+	if got := h.outboundHeaders(context.Background(), req, config.Target{Provider: "anthropic"}, data); len(got) != 0 {
+		t.Errorf("expected no headers for an unconfigured provider, got %v", got)
+	}
+}
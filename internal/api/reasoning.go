@@ -0,0 +1,35 @@
+package api
+
+import (
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// showReasoning reports whether the client gets the model's reasoning.
+// Clients opt in with include_reasoning. Output guardrails only screen the
+// answer, so routes that moderate or scan completions never pass reasoning
+// through.
+func showReasoning(req ChatRequest, route config.Route) bool {
+	return req.IncludeReasoning && route.Moderation == nil && route.SecretScan == nil
+}
+
+// stripReasoning removes reasoning from a completion in place. The reasoning
+// token counts in its usage stay.
+func stripReasoning(resp *providers.ChatResponse) {
+	for i := range resp.Choices {
+		resp.Choices[i].Message.Reasoning = ""
+		resp.Choices[i].Message.ReasoningSignature = ""
+	}
+}
+
+// stripChunkReasoning removes reasoning from a stream chunk in place and
+// reports whether anything is left worth sending.
+func stripChunkReasoning(chunk *providers.ChatChunk) bool {
+	if len(chunk.Choices) == 0 {
+		return true
+	}
+	c := &chunk.Choices[0]
+	hadReasoning := c.Delta.Reasoning != "" || c.Delta.ReasoningSignature != ""
+	c.Delta.Reasoning, c.Delta.ReasoningSignature = "", ""
+	return !hadReasoning || c.Delta.Content != "" || len(c.Delta.ToolCalls) > 0 || c.FinishReason != ""
+}
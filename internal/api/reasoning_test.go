@@ -0,0 +1,22 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestStripChunkReasoning(t *testing.T) {
+	chunk := providers.ChatChunk{Choices: []providers.ChunkChoice{{Delta: providers.ChunkDelta{Reasoning: "hmm"}}}}
+	if stripChunkReasoning(&chunk) {
+		t.Error("expected a reasoning-only chunk to be dropped")
+	}
+	chunk = providers.ChatChunk{Choices: []providers.ChunkChoice{{Delta: providers.ChunkDelta{Reasoning: "so", Content: "Yes"}}}}
+	if !stripChunkReasoning(&chunk) || chunk.Choices[0].Delta.Reasoning != "" || chunk.Choices[0].Delta.Content != "Yes" {
+		t.Errorf("expected the content to be kept without reasoning, got %+v", chunk)
+	}
+	if !showReasoning(ChatRequest{IncludeReasoning: true}, config.Route{}) || showReasoning(ChatRequest{IncludeReasoning: true}, config.Route{SecretScan: &config.SecretScan{}}) {
+		t.Error("expected reasoning only on opt-in and unscreened routes")
+	}
+}
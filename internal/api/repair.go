@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// malformedError is a completion that a repair retry did not fix. It is not
+// retryable, so the request moves on to the next target.
+type malformedError struct {
+	reason string
+}
+
+func (e *malformedError) Error() string {
+	return "malformed completion: " + e.reason
+}
+
+// repairInstructions are the default repair instructions by reason.
+var repairInstructions = map[string]string{
+	"empty":          "Your previous reply was empty. Answer the request above.",
+	"not valid JSON": "Your previous reply was not valid JSON. Reply again with only valid JSON, without code fences or any other text.",
+}
+
+// wantsJSON reports whether a completion of req must be JSON.
+func wantsJSON(req providers.ChatRequest, cfg config.RepairRetry) bool {
+	if cfg.JSON {
+		return true
+	}
+	format, _ := req.Extra["response_format"].(map[string]interface{})
+	kind, _ := format["type"].(string)
+	return kind == "json_object" || kind == "json_schema"
+}
+
+// malformedReason says why a completion is malformed, or "" when it is not.
+// A reply with tool calls is never malformed.
+func malformedReason(req providers.ChatRequest, resp *providers.ChatResponse, cfg config.RepairRetry) string {
+	if len(resp.Choices) == 0 {
+		return "empty"
+	}
+	msg := resp.Choices[0].Message
+	if len(msg.ToolCalls) > 0 {
+		return ""
+	}
+	content := strings.TrimSpace(msg.Content)
+	switch {
+	case content == "":
+		return "empty"
+	case wantsJSON(req, cfg) && !json.Valid([]byte(content)):
+		return "not valid JSON"
+	}
+	return ""
+}
+
+// repairCompletion retries a malformed completion once on the same target,
+// with the bad reply and a repair instruction appended to the
+// conversation, and records the attempt as a repair event. Usage of the
+// discarded completion is folded into the returned response. It returns a
+// malformedError when the retry does not fix the completion.
+func (h *Handler) repairCompletion(ctx context.Context, p providers.Provider, req providers.ChatRequest, resp *providers.ChatResponse, route config.Route, target config.Target, requestID string) (*providers.ChatResponse, error) {
+	cfg := *route.Repair
+	reason := malformedReason(req, resp, cfg)
+	if reason == "" {
+		return resp, nil
+	}
+
+	instruction := cfg.Instruction
+	if instruction == "" {
+		instruction = repairInstructions[reason]
+	}
+	retryReq := req
+	retryReq.Messages = append([]providers.Message{}, req.Messages...)
+	if len(resp.Choices) > 0 && strings.TrimSpace(resp.Choices[0].Message.Content) != "" {
+		retryReq.Messages = append(retryReq.Messages, providers.Message{Role: "assistant", Content: resp.Choices[0].Message.Content})
+	}
+	retryReq.Messages = append(retryReq.Messages, providers.Message{Role: "user", Content: instruction})
+
+	spent := resp.Usage
+	next, err := h.complete(p, retryReq, route, target)
+	remaining := reason
+	if err == nil {
+		spent.PromptTokens += next.Usage.PromptTokens
+		spent.CompletionTokens += next.Usage.CompletionTokens
+		spent.TotalTokens += next.Usage.TotalTokens
+		next.Usage = spent
+		remaining = malformedReason(req, next, cfg)
+	}
+
+	detail := map[string]interface{}{
+		"provider": target.Provider,
+		"model":    target.Model,
+		"reason":   reason,
+		"repaired": remaining == "",
+	}
+	if err != nil {
+		detail["error"] = err.Error()
+	}
+	h.usage.LogEvent(ctx, requestID, usage.Event{Kind: "repair", Detail: detail})
+
+	if remaining != "" {
+		return nil, &malformedError{reason: remaining}
+	}
+	return next, nil
+}
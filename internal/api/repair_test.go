@@ -0,0 +1,47 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func completion(content string) *providers.ChatResponse {
+	resp := &providers.ChatResponse{}
+	resp.Choices = append(resp.Choices, struct {
+		Index        int               `json:"index"`
+		Message      providers.Message `json:"message"`
+		FinishReason string            `json:"finish_reason"`
+	}{Message: providers.Message{Role: "assistant", Content: content}})
+	return resp
+}
+
+func TestMalformedReason(t *testing.T) {
+	jsonMode := providers.ChatRequest{Extra: map[string]interface{}{"response_format": map[string]interface{}{"type": "json_object"}}}
+	cases := []struct {
+		name string
+		req  providers.ChatRequest
+		resp *providers.ChatResponse
+		cfg  config.RepairRetry
+		want string
+	}{
+		{"no choices", providers.ChatRequest{}, &providers.ChatResponse{}, config.RepairRetry{}, "empty"},
+		{"blank", providers.ChatRequest{}, completion("  \n"), config.RepairRetry{}, "empty"},
+		{"text", providers.ChatRequest{}, completion("Sure."), config.RepairRetry{}, ""},
+		{"json mode, prose", jsonMode, completion("Here you go: {}"), config.RepairRetry{}, "not valid JSON"},
+		{"json mode, json", jsonMode, completion(` {"ok": true} `), config.RepairRetry{}, ""},
+		{"json config", providers.ChatRequest{}, completion("```json\n{}\n```"), config.RepairRetry{JSON: true}, "not valid JSON"},
+	}
+	for _, c := range cases {
+		if got := malformedReason(c.req, c.resp, c.cfg); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.name, c.want, got)
+		}
+	}
+
+	calls := completion("")
+	calls.Choices[0].Message.ToolCalls = []providers.ToolCall{{ID: "call_1"}}
+	if got := malformedReason(jsonMode, calls, config.RepairRetry{}); got != "" {
+		t.Errorf("expected tool calls accepted, got %q", got)
+	}
+}
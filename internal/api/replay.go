@@ -0,0 +1,324 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/yewintnaing/ai-gateway/internal/classify"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// maxDiffLines bounds the lines of each side compared line by line; longer
+// completions are compared by similarity only.
+const maxDiffLines = 2000
+
+// replayRequest overrides where a captured request is replayed to. Empty
+// fields keep the original request's route and the route's own targets.
+type replayRequest struct {
+	Route    string `json:"route"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// ReplayResult compares a replayed request's completion with the original.
+type ReplayResult struct {
+	RequestID       string           `json:"request_id"`
+	ReplayRequestID string           `json:"replay_request_id"`
+	Route           string           `json:"route"`
+	Original        ReplayCompletion `json:"original"`
+	Replay          ReplayCompletion `json:"replay"`
+	Identical       bool             `json:"identical"`
+	Similarity      float64          `json:"similarity"`
+	Diff            []string         `json:"diff,omitempty"`
+	Attempts        []ReplayAttempt  `json:"attempts,omitempty"`
+}
+
+// ReplayCompletion is one side of a replay comparison.
+type ReplayCompletion struct {
+	Provider         string `json:"provider"`
+	Model            string `json:"model"`
+	Content          string `json:"content"`
+	FinishReason     string `json:"finish_reason,omitempty"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	LatencyMS        int    `json:"latency_ms"`
+}
+
+// ReplayAttempt is a target that failed before the replay succeeded.
+type ReplayAttempt struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Error    string `json:"error"`
+}
+
+// HandleReplayRequest sends a request's captured messages again, through
+// its route as currently configured or the route, provider or model in the
+// body, and diffs the new completion against the captured one. The
+// tenant's provider policy still applies. Only the messages are captured,
+// so the client's sampling parameters are not replayed; the route's target
+// params are. The replay is logged as its own request of the same tenant,
+// so its cost is accounted for, and needs the same role as reading the
+// payload.
+func (h *Handler) HandleReplayRequest(w http.ResponseWriter, r *http.Request) {
+	requestID := chi.URLParam(r, "request_id")
+	var body replayRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		h.respondError(w, http.StatusBadRequest, "invalid request body", requestID)
+		return
+	}
+	if body.Provider != "" && body.Model == "" {
+		h.respondError(w, http.StatusBadRequest, "provider needs a model", requestID)
+		return
+	}
+
+	tenant, plain, ok := h.openPayload(w, r, requestID)
+	if !ok {
+		return
+	}
+	var captured struct {
+		Messages []providers.Message `json:"messages"`
+		Response json.RawMessage     `json:"response"`
+	}
+	if err := json.Unmarshal(plain, &captured); err != nil {
+		logError(requestID, "failed to decode payload", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to decode payload", requestID)
+		return
+	}
+	trace, err := h.usage.GetTrace(r.Context(), requestID)
+	if err != nil {
+		logError(requestID, "failed to load request trace", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to load request", requestID)
+		return
+	}
+
+	name := body.Route
+	if name == "" {
+		name = trace.RouteName
+	}
+	route, ok := h.router.Lookup(name)
+	if !ok {
+		h.respondError(w, http.StatusNotFound, fmt.Sprintf("route %q not found", name), requestID)
+		return
+	}
+	route = withModel(route, body.Provider, body.Model)
+	route, err = restrictTargets(route, h.tenants[tenant], h.tenants[tenant].Residency, h.providerOpts)
+	if err != nil {
+		h.respondError(w, http.StatusForbidden, err.Error(), requestID)
+		return
+	}
+
+	messages := captured.Messages
+	if route.SystemPrompt != nil {
+		// The captured messages carry the system prompt the original
+		// request was sent with; a managed one is replaced by the route's.
+		if trace.SystemPromptVer != "" {
+			messages = withoutSystem(messages)
+		}
+		messages = applySystemPrompt(messages, *route.SystemPrompt)
+	}
+
+	result := ReplayResult{
+		RequestID:       requestID,
+		ReplayRequestID: uuid.New().String(),
+		Route:           route.Name,
+		Original:        originalCompletion(trace, captured.Response),
+	}
+	resp, target, latency, attempts := h.completeOutOfBand(r.Context(), route, ChatRequest{Messages: messages}, usage.Record{
+		RequestID: result.ReplayRequestID, Tenant: tenant, UseCase: trace.UseCase, RouteName: route.Name,
+		Metadata: map[string]interface{}{"replay_of": requestID},
+	})
+	result.Attempts = attempts
+	h.usage.LogEvent(r.Context(), requestID, usage.Event{
+		Kind:   "payload_decrypted",
+		Detail: map[string]interface{}{"tenant": tenant, "replay_request_id": result.ReplayRequestID},
+	})
+	if resp == nil {
+		h.respondError(w, http.StatusBadGateway, "replay failed on every target", requestID)
+		return
+	}
+
+	result.Replay = ReplayCompletion{
+		Provider: target.Provider, Model: target.Model,
+		PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens,
+		LatencyMS: latency,
+	}
+	if len(resp.Choices) > 0 {
+		result.Replay.Content = resp.Choices[0].Message.Content
+		result.Replay.FinishReason = resp.Choices[0].FinishReason
+	}
+	result.Identical = result.Original.Content == result.Replay.Content
+	result.Similarity = classify.Similarity(classify.Embed(result.Original.Content), classify.Embed(result.Replay.Content))
+	if !result.Identical {
+		result.Diff = lineDiff(result.Original.Content, result.Replay.Content)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(result)
+}
+
+// completeOutOfBand calls the route's targets in order until one
+// completes, outside a client request, logging the calls as record's
+// request. It returns a nil response when every target failed.
+func (h *Handler) completeOutOfBand(ctx context.Context, route config.Route, req ChatRequest, record usage.Record) (*providers.ChatResponse, config.Target, int, []ReplayAttempt) {
+	h.usage.Log(ctx, record)
+
+	masked, unmaskMap := h.maskMessages(req.Messages)
+	start := time.Now()
+	var attempts []ReplayAttempt
+	for i, target := range append([]config.Target{route.Primary}, route.Fallbacks...) {
+		attemptStart := time.Now()
+		resp, err := h.callTarget(ctx, req, masked, route, target, record.RequestID, record.Tenant, record.UseCase)
+		_, account := h.providerAccount(route, record.Tenant, target)
+		h.usage.LogAttempt(ctx, record.RequestID, usage.Attempt{
+			RequestID: record.RequestID, AttemptNo: i + 1, Provider: target.Provider, Model: target.Model,
+			LatencyMS: int(time.Since(attemptStart).Milliseconds()), StatusCode: getStatusCode(err, resp != nil), ErrorMessage: getErrorMessage(err),
+			Organization: account.Organization, Project: account.Project,
+		})
+		if err != nil {
+			attempts = append(attempts, ReplayAttempt{Provider: target.Provider, Model: target.Model, Error: err.Error()})
+			continue
+		}
+		if unmaskMap != nil && h.detector != nil {
+			for i, choice := range resp.Choices {
+				resp.Choices[i].Message.Content = h.detector.Unmask(choice.Message.Content, unmaskMap)
+			}
+		}
+		latency := int(time.Since(start).Milliseconds())
+		record.Provider, record.Model, record.Region = target.Provider, target.Model, h.region(target.Provider)
+		record.PromptTokens, record.CompletionTokens, record.TotalTokens = resp.Usage.PromptTokens, resp.Usage.CompletionTokens, resp.Usage.TotalTokens
+		record.LatencyMS, record.StatusCode = latency, http.StatusOK
+		h.usage.Log(ctx, record)
+		return resp, target, latency, attempts
+	}
+	record.LatencyMS, record.StatusCode = int(time.Since(start).Milliseconds()), http.StatusBadGateway
+	record.ErrorMessage = "every target failed"
+	h.usage.Log(ctx, record)
+	return nil, config.Target{}, 0, attempts
+}
+
+// callTarget makes one non-streaming call to target outside a client
+// request, as revalidate does, under the target's circuit breaker and
+// concurrency limit.
+func (h *Handler) callTarget(ctx context.Context, req ChatRequest, messages []providers.Message, route config.Route, target config.Target, replayID, tenant, useCase string) (*providers.ChatResponse, error) {
+	if !h.health.Allow(ctx, target.Provider) {
+		return nil, errCircuitOpen(target.Provider)
+	}
+	provider, err := h.registry.Get(target.Provider)
+	if err != nil {
+		return nil, err
+	}
+	provReq, err := h.targetRequest(req, messages, route, target)
+	if err != nil {
+		return nil, err
+	}
+	capTargetOutput(&provReq, outputCap(route, h.tenants[tenant]))
+	provReq.Timeout = h.targetTimeout(ctx, route, target, provReq.MaxTokens)
+	provReq.Headers = h.outboundHeaders(ctx, req, target, headerData{Tenant: tenant, UseCase: useCase, Route: route.Name, RequestID: replayID, zeroRetention: route.ZeroRetention, transform: route.Transform})
+	provReq.Account, _ = h.providerAccount(route, tenant, target)
+	release, err := h.acquireTarget(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	start := time.Now()
+	resp, err := provider.Chat(provReq)
+	h.recordHealth(ctx, target, time.Since(start), err)
+	return resp, err
+}
+
+// withModel returns route with model on provider, by default the route's
+// primary provider, in place of its targets, or route itself without a
+// model.
+func withModel(route config.Route, provider, model string) config.Route {
+	if model == "" {
+		return route
+	}
+	if provider == "" {
+		provider = route.Primary.Provider
+	}
+	route.Primary, route.Fallbacks = config.Target{Provider: provider, Model: model}, nil
+	return route
+}
+
+// originalCompletion reads the original request's side of a replay from
+// its trace and captured response, which is the response body sent or,
+// for a stream, the completion text.
+func originalCompletion(trace *usage.RequestTrace, response json.RawMessage) ReplayCompletion {
+	c := ReplayCompletion{
+		Provider: trace.Provider, Model: trace.Model,
+		PromptTokens: trace.PromptTokens, CompletionTokens: trace.CompletionTokens,
+		LatencyMS: trace.LatencyMS,
+	}
+	if json.Unmarshal(response, &c.Content) == nil {
+		return c
+	}
+	var resp providers.ChatResponse
+	if json.Unmarshal(response, &resp) == nil && len(resp.Choices) > 0 {
+		c.Content = resp.Choices[0].Message.Content
+		c.FinishReason = resp.Choices[0].FinishReason
+	}
+	return c
+}
+
+// withoutSystem drops the system messages.
+func withoutSystem(messages []providers.Message) []providers.Message {
+	out := make([]providers.Message, 0, len(messages))
+	for _, m := range messages {
+		if m.Role != "system" {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// lineDiff compares a and b line by line and returns every line prefixed
+// with "  " when both have it, "- " when only a does and "+ " when only b
+// does. Texts longer than maxDiffLines are not diffed.
+func lineDiff(a, b string) []string {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	if len(x) > maxDiffLines || len(y) > maxDiffLines {
+		return nil
+	}
+	// lcs[i][j] is the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out []string
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			out = append(out, "  "+x[i])
+			i++
+			j++
+		case j < len(y) && (i == len(x) || lcs[i][j+1] >= lcs[i+1][j]):
+			out = append(out, "+ "+y[j])
+			j++
+		default:
+			out = append(out, "- "+x[i])
+			i++
+		}
+	}
+	return out
+}
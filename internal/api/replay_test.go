@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+func TestLineDiff(t *testing.T) {
+	got := lineDiff("a\nb\nc", "a\nx\nc\nd")
+	want := []string{"  a", "+ x", "- b", "  c", "+ d"}
+	if !slices.Equal(got, want) {
+		t.Errorf("lineDiff = %q, want %q", got, want)
+	}
+	if got := lineDiff("same", "same"); !slices.Equal(got, []string{"  same"}) {
+		t.Errorf("unexpected diff of equal texts %q", got)
+	}
+}
+
+func TestOriginalCompletion(t *testing.T) {
+	trace := &usage.RequestTrace{Provider: "openai", Model: "gpt-4o", CompletionTokens: 3}
+
+	// Streams capture the completion text.
+	if c := originalCompletion(trace, json.RawMessage(`"streamed text"`)); c.Content != "streamed text" || c.Model != "gpt-4o" {
+		t.Errorf("unexpected stream completion %+v", c)
+	}
+
+	resp := json.RawMessage(`{"choices": [{"message": {"role": "assistant", "content": "hello"}, "finish_reason": "stop"}]}`)
+	if c := originalCompletion(trace, resp); c.Content != "hello" || c.FinishReason != "stop" || c.CompletionTokens != 3 {
+		t.Errorf("unexpected completion %+v", c)
+	}
+}
+
+func TestWithoutSystem(t *testing.T) {
+	got := withoutSystem([]providers.Message{{Role: "system", Content: "old prompt"}, {Role: "user", Content: "hi"}})
+	if len(got) != 1 || got[0].Role != "user" {
+		t.Errorf("expected only the user message, got %+v", got)
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/governance"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// revalidate refreshes a stale cache entry from the route's primary target
+// after the stale copy has been served. A lock keeps concurrent requests
+// and other instances from refreshing the same key at once, and an open
+// circuit breaker skips the refresh. The completion goes through the same
+// output guardrails as one served to a client, and is not cached when they
+// withhold it. The refresh is logged as its own request so its cost is
+// accounted for.
+func (h *Handler) revalidate(req ChatRequest, route config.Route, cacheKey, tenant, useCase string, wordList *governance.WordList, moderator *governance.Moderator) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if !h.health.Allow(ctx, route.Primary.Provider) {
+		return
+	}
+	if !h.cache.TryLock(ctx, cacheKey, time.Minute) {
+		return
+	}
+	provider, err := h.registry.Get(route.Primary.Provider)
+	if err != nil {
+		return
+	}
+
+	requestID := uuid.New().String()
+	messages, unmaskMap := h.maskMessages(req.Messages)
+	start := time.Now()
+	provReq, err := h.targetRequest(req, messages, route, route.Primary)
+	if err != nil {
+		logError(requestID, "cache revalidation failed", err)
+		return
+	}
+	capTargetOutput(&provReq, outputCap(route, h.tenants[tenant]))
+	provReq.Timeout = h.targetTimeout(ctx, route, route.Primary, provReq.MaxTokens)
+	provReq.Headers = h.outboundHeaders(ctx, req, route.Primary, headerData{Tenant: tenant, UseCase: useCase, Route: route.Name, RequestID: requestID, zeroRetention: route.ZeroRetention, transform: route.Transform})
+	provReq.Account, _ = h.providerAccount(route, tenant, route.Primary)
+	release, err := h.acquireTarget(ctx, route.Primary)
+	if err != nil {
+		logError(requestID, "cache revalidation skipped", err)
+		return
+	}
+	resp, err := provider.Chat(provReq)
+	release()
+	h.recordHealth(ctx, route.Primary, time.Since(start), err)
+	if err != nil {
+		logError(requestID, "cache revalidation failed", err)
+		return
+	}
+
+	record := usage.Record{
+		RequestID: requestID, Tenant: tenant, UseCase: useCase, RouteName: route.Name,
+		Provider: route.Primary.Provider, Model: route.Primary.Model, Region: h.region(route.Primary.Provider),
+		PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens, TotalTokens: resp.Usage.TotalTokens,
+		PromptRoles: promptRoles(provReq, resp.Usage.PromptTokens),
+		StatusCode:  http.StatusOK,
+		Metadata:    map[string]interface{}{"cache_revalidation": true},
+	}
+	if err := h.screenCompletion(ctx, headerSink(http.Header{}), resp, unmaskMap, wordList, moderator, route, requestID); err != nil {
+		record.LatencyMS, record.StatusCode, record.ErrorMessage = int(time.Since(start).Milliseconds()), http.StatusBadGateway, err.Error()
+		h.usage.Log(ctx, record)
+		return
+	}
+	tags := h.cacheTags(req, tenant, route.Name, route.Primary.Model)
+	h.cache.SetFor(ctx, cacheKey, tags, resp, route.Cache.Fresh()+route.Cache.Stale())
+
+	record.LatencyMS = int(time.Since(start).Milliseconds())
+	h.usage.Log(ctx, record)
+}
+
+// headerSink takes the headers guardrails set on a response that no client
+// will receive.
+type headerSink http.Header
+
+func (s headerSink) Header() http.Header       { return http.Header(s) }
+func (headerSink) Write(b []byte) (int, error) { return len(b), nil }
+func (headerSink) WriteHeader(int)             {}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// RouteTestRequest is a hypothetical chat request to route. Messages are
+// only needed for routes with a classifier; PromptTokens stands in for
+// them elsewhere, and is counted from them when left out. Canary routes the
+// request as the canary definition of its route would.
+type RouteTestRequest struct {
+	UseCase      string                 `json:"use_case"`
+	Tenant       string                 `json:"tenant"`
+	Metadata     map[string]interface{} `json:"metadata"`
+	PromptTokens int                    `json:"prompt_tokens"`
+	MaxTokens    int                    `json:"max_tokens"`
+	MaxCostUSD   float64                `json:"max_cost_usd"`
+	Residency    string                 `json:"residency"`
+	Messages     []providers.Message    `json:"messages"`
+	Canary       bool                   `json:"canary"`
+}
+
+// RouteTest is where a hypothetical request would be routed under the
+// current config and provider health.
+type RouteTest struct {
+	Route     string           `json:"route"`
+	MatchedBy string           `json:"matched_by"`
+	Canary    *RouteTestCanary `json:"canary,omitempty"`
+	Category  string           `json:"category,omitempty"`
+	Residency string           `json:"residency,omitempty"`
+	// MaxTokens is the completion length the request would be held to; 0
+	// when nothing bounds it.
+	MaxTokens int             `json:"max_tokens,omitempty"`
+	Filters   []RoutingFilter `json:"filters,omitempty"`
+	// Targets are the targets in the order they would be tried.
+	Targets []RoutingCandidate `json:"targets"`
+	// Refused is set when the gateway would refuse the request before
+	// calling a provider; routing stops there.
+	Refused *RouteTestRefusal `json:"refused,omitempty"`
+}
+
+// RouteTestCanary is the route's canary split. Evaluated says whether the
+// result is for the canary definition.
+type RouteTestCanary struct {
+	Version    string   `json:"version"`
+	Percent    float64  `json:"percent"`
+	RolledBack bool     `json:"rolled_back,omitempty"`
+	Evaluated  bool     `json:"evaluated"`
+	Targets    []string `json:"targets"`
+}
+
+// RouteTestRefusal is the error response the request would get.
+type RouteTestRefusal struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// HandleRouteTest routes a hypothetical request without sending it, so
+// routing rules can be checked before they are rolled out.
+func (h *Handler) HandleRouteTest(w http.ResponseWriter, r *http.Request) {
+	var in RouteTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body", "")
+		return
+	}
+	if in.PromptTokens < 0 || in.MaxTokens < 0 || in.MaxCostUSD < 0 {
+		h.respondError(w, http.StatusBadRequest, "prompt_tokens, max_tokens and max_cost_usd may not be negative", "")
+		return
+	}
+	res, err := h.testRoute(r.Context(), in)
+	if err != nil {
+		logError("", "route test failed", err)
+		h.respondError(w, http.StatusInternalServerError, err.Error(), "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// testRoute routes in through routeRequest and orderTargets, as HandleChat
+// does up to the first provider call. It fails only on a route config the
+// gateway could not serve.
+func (h *Handler) testRoute(ctx context.Context, in RouteTestRequest) (RouteTest, error) {
+	tenant := in.Tenant
+	if tenant == "" {
+		tenant = "anonymous"
+	}
+	req := ChatRequest{Messages: in.Messages, MaxTokens: in.MaxTokens, MaxCostUSD: in.MaxCostUSD, Metadata: maps.Clone(in.Metadata)}
+	useCase := in.UseCase
+	if useCase == "" {
+		useCase, _ = req.Metadata["use_case"].(string)
+	} else if _, ok := req.Metadata["use_case"]; !ok {
+		if req.Metadata == nil {
+			req.Metadata = map[string]interface{}{}
+		}
+		req.Metadata["use_case"] = useCase
+	}
+
+	route, matchedBy := h.router.Match(useCase)
+	rt := &RoutingTrace{}
+	res := RouteTest{MatchedBy: matchedBy}
+	refuse := func(status int, message string) (RouteTest, error) {
+		res.Route, res.Filters = route.Name, rt.Filters
+		res.Refused = &RouteTestRefusal{Status: status, Error: message}
+		res.Targets = []RoutingCandidate{}
+		return res, nil
+	}
+
+	if c := route.Canary; c != nil && c.Route != nil && c.Percent > 0 {
+		split := &RouteTestCanary{Version: c.Version, Percent: c.Percent}
+		if st, ok := h.canary.Status(route.Name); ok && st.Version == c.Version {
+			split.RolledBack = st.RolledBack
+		}
+		for _, t := range routeTargets(*c.Route) {
+			split.Targets = append(split.Targets, targetName(t))
+		}
+		if in.Canary {
+			candidate := *c.Route
+			candidate.Name, candidate.Match, candidate.Canary = route.Name, route.Match, nil
+			rt.filter("canary", routeTargets(route), routeTargets(candidate))
+			route, split.Evaluated = candidate, true
+		}
+		res.Canary = split
+	}
+
+	prompt := in.PromptTokens
+	if prompt == 0 && len(in.Messages) > 0 {
+		prompt = usage.ApproximateTokens(fmt.Sprintf("%v", in.Messages))
+	}
+	routing, refused := h.routeRequest(ctx, &req, route, tenant, useCase, in.Residency, prompt, rt)
+	route = routing.route
+	res.Category, res.Residency = routing.category, routing.residency
+	if refused != nil {
+		if refused.cause != nil {
+			return res, refused.cause
+		}
+		return refuse(refused.status, refused.message)
+	}
+	rt.candidates(ctx, h, h.orderTargets(ctx, route, rt))
+
+	res.Route, res.MaxTokens = route.Name, req.MaxTokens
+	res.Filters, res.Targets = rt.Filters, rt.Candidates
+	return res, nil
+}
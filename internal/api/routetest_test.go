@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/router"
+)
+
+func TestRouteTest(t *testing.T) {
+	ctx := context.Background()
+	openai := config.Target{Provider: "openai", Model: "gpt-4o"}
+	mistral := config.Target{Provider: "mistral", Model: "mistral-large"}
+	support := config.Route{
+		Name:      "support",
+		Match:     config.Match{UseCase: "support"},
+		Primary:   openai,
+		Fallbacks: []config.Target{mistral},
+		Canary: &config.Canary{
+			Version: "v2", Percent: 10,
+			Route: &config.Route{Primary: mistral},
+		},
+	}
+	h := &Handler{
+		router:  router.NewRouter([]config.Route{support}),
+		tenants: map[string]config.Tenant{"acme-eu": {Name: "acme-eu", Residency: "eu"}},
+		providerOpts: map[string]config.ProviderOptions{
+			"openai":  {Region: "us"},
+			"mistral": {Region: "eu"},
+		},
+	}
+
+	res, err := h.testRoute(ctx, RouteTestRequest{UseCase: "support", PromptTokens: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Route != "support" || res.MatchedBy != router.MatchedUseCase || res.Refused != nil || len(res.Targets) != 2 || res.Targets[0].Provider != "openai" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if res.Canary == nil || res.Canary.Evaluated || res.Canary.Version != "v2" || len(res.Canary.Targets) != 1 {
+		t.Errorf("expected the canary split to be reported, got %+v", res.Canary)
+	}
+
+	res, _ = h.testRoute(ctx, RouteTestRequest{Tenant: "acme-eu", Metadata: map[string]interface{}{"use_case": "support"}})
+	if res.Route != "support" || res.Residency != "eu" || len(res.Targets) != 1 || res.Targets[0].Provider != "mistral" {
+		t.Fatalf("expected residency to leave mistral, got %+v", res)
+	}
+	if len(res.Filters) != 1 || res.Filters[0].Name != "target_policy" || res.Filters[0].Removed[0] != "openai/gpt-4o" {
+		t.Errorf("unexpected filters %+v", res.Filters)
+	}
+
+	res, _ = h.testRoute(ctx, RouteTestRequest{UseCase: "support", Tenant: "acme-eu", Residency: "us"})
+	if res.Refused == nil || res.Refused.Status != http.StatusForbidden || len(res.Targets) != 0 {
+		t.Errorf("expected the residency request to be refused, got %+v", res)
+	}
+
+	res, _ = h.testRoute(ctx, RouteTestRequest{UseCase: "support", Canary: true})
+	if !res.Canary.Evaluated || len(res.Targets) != 1 || res.Targets[0].Provider != "mistral" {
+		t.Errorf("expected the canary definition to be routed, got %+v", res)
+	}
+
+	if res, _ = h.testRoute(ctx, RouteTestRequest{UseCase: "billing"}); res.Route != "default" || res.MatchedBy != router.MatchedBuiltin {
+		t.Errorf("expected the built-in default, got %+v", res)
+	}
+
+	w := httptest.NewRecorder()
+	h.HandleRouteTest(w, httptest.NewRequest("POST", "/admin/route/test", strings.NewReader(`{"prompt_tokens":-1}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for negative tokens, got %d", w.Code)
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// routed is where routeRequest sent a request: the route with its targets
+// narrowed, and what each step decided on the way.
+type routed struct {
+	route        config.Route
+	category     string
+	similarity   float64
+	transformed  []string
+	residency    string
+	maxOutput    int
+	clamped      bool
+	predicted    int
+	paramChanges []paramChange
+	ceiling      float64
+}
+
+// routeRefusal is the response to a request routing refuses. cause is set
+// when the route's config is at fault rather than the request; record is
+// the error kept in the usage record.
+type routeRefusal struct {
+	status  int
+	message string
+	record  string
+	cause   error
+}
+
+func refusal(status int, err error) *routeRefusal {
+	return &routeRefusal{status: status, message: err.Error(), record: err.Error()}
+}
+
+// routeRequest runs the routing steps of a chat request on the route it
+// matched, after the canary split: topic classification, the route's
+// transform and metadata validation, the tenant's target policy and data
+// residency, the output budget and parameter policy, and the cost ceiling.
+// It changes req as the steps do, and records in rt the targets each step
+// filtered. HandleChat and the route test both route through it, so what
+// the test reports is what a request gets.
+func (h *Handler) routeRequest(ctx context.Context, req *ChatRequest, route config.Route, tenant, useCase, requestedResidency string, promptTokens int, rt *RoutingTrace) (routed, *routeRefusal) {
+	res := routed{route: route}
+
+	// Topic classification picks the target model
+	classified, category, similarity, err := classifyRoute(route, req.Messages)
+	if err != nil {
+		return res, &routeRefusal{status: http.StatusInternalServerError, message: "route has an invalid classifier config", record: "invalid classifier config", cause: err}
+	}
+	rt.filter("classifier", routeTargets(route), routeTargets(classified))
+	res.route, res.category, res.similarity = classified, category, similarity
+
+	// Route transform, then metadata validation, so that renamed keys are
+	// checked against the schema
+	if res.transformed, err = applyTransform(req, res.route.Transform); err != nil {
+		return res, &routeRefusal{status: http.StatusInternalServerError, message: "route has an invalid transform", record: "invalid route transform", cause: err}
+	}
+	if err := validateMetadata(h.metadata, req.Metadata); err != nil {
+		return res, refusal(http.StatusBadRequest, err)
+	}
+
+	// Tenant policy, data residency and zero retention: only the targets the
+	// request's data may be sent to
+	residency, status, err := residencyFor(requestedResidency, h.tenants[tenant])
+	if err != nil {
+		return res, refusal(status, err)
+	}
+	res.residency = residency
+	restricted, err := restrictTargets(res.route, h.tenants[tenant], residency, h.providerOpts)
+	if err != nil {
+		return res, refusal(http.StatusForbidden, err)
+	}
+	rt.filter("target_policy", routeTargets(res.route), routeTargets(restricted))
+	res.route = restricted
+
+	// Output token budget and parameter policy
+	res.maxOutput = outputCap(res.route, h.tenants[tenant])
+	clientMax := req.MaxTokens
+	if res.clamped, err = enforceOutputCap(req, res.maxOutput, res.route.MaxTokensPolicy); err != nil {
+		return res, refusal(http.StatusBadRequest, err)
+	}
+	res.predicted = h.predictMaxTokens(ctx, req, clientMax, res.route, useCase)
+	if res.paramChanges, err = applyParamPolicy(req, res.route.ParamPolicy, clientMax); err != nil {
+		return res, refusal(http.StatusBadRequest, err)
+	}
+
+	// Cost ceiling: cheapest targets first, each held to what it can afford
+	if ceiling := costCeiling(*req, res.route); ceiling > 0 {
+		prompt := promptTokens
+		if res.route.SystemPrompt != nil {
+			prompt += usage.ApproximateTokens(res.route.SystemPrompt.Content)
+		}
+		pricing := func(model string) usage.Pricing { return h.usage.Pricing(ctx, model) }
+		planned, err := planCost(res.route, pricing, prompt, req.MaxTokens, ceiling)
+		if err != nil {
+			return res, refusal(http.StatusBadRequest, err)
+		}
+		rt.filter("cost_ceiling", routeTargets(res.route), routeTargets(planned))
+		res.route, res.ceiling = planned, ceiling
+	}
+	return res, nil
+}
+
+// orderTargets returns route's targets in the order they are tried: those
+// meeting the route's time-to-first-token SLO first, then by queue depth.
+func (h *Handler) orderTargets(ctx context.Context, route config.Route, rt *RoutingTrace) []config.Target {
+	targets := routeTargets(route)
+	if route.TTFTSLOMS > 0 {
+		before := targets
+		targets = h.orderByTTFT(ctx, targets, time.Duration(route.TTFTSLOMS)*time.Millisecond)
+		rt.filter("ttft_slo", before, targets)
+	}
+	before := targets
+	targets = h.orderByQueue(ctx, targets)
+	rt.filter("queue_depth", before, targets)
+	return targets
+}
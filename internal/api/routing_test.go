@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func TestRouteRequest(t *testing.T) {
+	ctx := context.Background()
+	route := config.Route{
+		Name:            "support",
+		Primary:         config.Target{Provider: "openai", Model: "gpt-4o"},
+		Fallbacks:       []config.Target{{Provider: "mistral", Model: "mistral-large"}},
+		MaxOutputTokens: 256,
+	}
+	h := &Handler{
+		tenants: map[string]config.Tenant{"acme-eu": {Name: "acme-eu", Residency: "eu"}},
+		providerOpts: map[string]config.ProviderOptions{
+			"openai":  {Region: "us"},
+			"mistral": {Region: "eu"},
+		},
+	}
+
+	req := ChatRequest{MaxTokens: 1000}
+	rt := &RoutingTrace{}
+	routing, refused := h.routeRequest(ctx, &req, route, "acme-eu", "support", "", 0, rt)
+	if refused != nil {
+		t.Fatalf("unexpected refusal %+v", refused)
+	}
+	if routing.residency != "eu" || routing.route.Primary.Provider != "mistral" || len(routing.route.Fallbacks) != 0 {
+		t.Errorf("expected residency to leave mistral, got %+v", routing)
+	}
+	if !routing.clamped || routing.maxOutput != 256 || req.MaxTokens != 256 {
+		t.Errorf("expected max_tokens clamped to the budget, got %+v and %d", routing, req.MaxTokens)
+	}
+	if len(rt.Filters) != 1 || rt.Filters[0].Name != "target_policy" {
+		t.Errorf("unexpected filters %+v", rt.Filters)
+	}
+
+	// A refused request is answered with the step's status and error.
+	_, refused = h.routeRequest(ctx, &ChatRequest{}, route, "acme-eu", "support", "us", 0, nil)
+	if refused == nil || refused.status != http.StatusForbidden || refused.cause != nil || refused.record != refused.message {
+		t.Errorf("expected the residency refused with 403, got %+v", refused)
+	}
+
+	// A route config the gateway cannot serve is a 500 with its cause.
+	broken := route
+	broken.Transform = &config.Transform{Set: map[string]interface{}{"max_tokens": "lots"}}
+	_, refused = h.routeRequest(ctx, &ChatRequest{}, broken, "acme-eu", "support", "", 0, nil)
+	if refused == nil || refused.status != http.StatusInternalServerError || refused.cause == nil || refused.message != "route has an invalid transform" {
+		t.Errorf("expected the invalid transform refused with 500, got %+v", refused)
+	}
+}
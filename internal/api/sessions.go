@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yewintnaing/ai-gateway/internal/cache"
+	"github.com/yewintnaing/ai-gateway/internal/compact"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// sessionSummaryTimeout bounds summarizing a conversation in the
+// background, and how long other requests leave it to one instance.
+const sessionSummaryTimeout = 2 * time.Minute
+
+// sessionSummary is a conversation's summary as stored. Summary stands in
+// for the first Covers messages that are not system messages, for requests
+// that resend them unchanged (Hash).
+type sessionSummary struct {
+	Covers  int    `json:"covers"`
+	Hash    string `json:"hash"`
+	Summary string `json:"summary"`
+}
+
+func sessionKey(tenant, conversation string) string {
+	return "session:" + tenant + ":" + conversation
+}
+
+// applySessionSummary replaces the turns of the conversation that its
+// stored summary covers, and has more of them summarized in the background
+// when the prompt is still over the route's budget. route is the request's
+// once its targets are held to the tenant's policy and residency.
+func (h *Handler) applySessionSummary(ctx context.Context, req *ChatRequest, route config.Route, tenant, residency, useCase, conversation, requestID string) {
+	policy := *route.SessionSummary
+	var stored sessionSummary
+	found, _, err := h.cache.GetWithAge(ctx, sessionKey(tenant, conversation), &stored)
+	if err != nil {
+		logError(requestID, "failed to read the session summary", err)
+	}
+	original := req.Messages
+	if found {
+		if messages, ok := withSummary(original, stored); ok {
+			req.Messages = messages
+			h.usage.LogEvent(ctx, requestID, usage.Event{
+				Kind:   "session_summary",
+				Detail: map[string]interface{}{"covers": stored.Covers},
+			})
+		} else {
+			stored = sessionSummary{} // the client sent a different history
+		}
+	}
+	if compact.Count(req.Messages) > policy.BudgetTokens {
+		summarizer, ok := h.summarizerRoute(route, policy.Summarizer, tenant, residency)
+		if !ok {
+			logError(requestID, "session summary skipped", fmt.Errorf("the tenant's policy rules out summarizer %s/%s", policy.Summarizer.Provider, policy.Summarizer.Model))
+			return
+		}
+		go h.summarizeSession(route, summarizer, tenant, useCase, conversation, requestID, original, stored)
+	}
+}
+
+// summarizeSession extends a conversation's summary over enough of its
+// oldest turns that its prompt fits the route's budget again, outside the
+// request. The summary call is logged as its own request in the
+// conversation.
+func (h *Handler) summarizeSession(route, summarizer config.Route, tenant, useCase, conversation, requestID string, messages []providers.Message, stored sessionSummary) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionSummaryTimeout)
+	defer cancel()
+	key := sessionKey(tenant, conversation)
+	if !h.cache.TryLock(ctx, key, sessionSummaryTimeout) {
+		return
+	}
+
+	policy := *route.SessionSummary
+	next, ok, err := planSessionSummary(ctx, messages, stored, policy.BudgetTokens, policy.SummaryLength(), func(ctx context.Context, turns []providers.Message) (string, error) {
+		record := usage.Record{
+			RequestID: uuid.New().String(), Tenant: tenant, UseCase: useCase, RouteName: route.Name, ConversationID: conversation,
+			Metadata: map[string]interface{}{"session_summary_of": requestID},
+		}
+		return h.summarize(ctx, summarizer, turns, policy.SummaryLength(), record)
+	})
+	if err != nil {
+		if !errors.Is(err, compact.ErrNoFit) {
+			logError(requestID, "session summary failed", err)
+		}
+		return
+	}
+	if !ok {
+		return
+	}
+	if err := h.cache.SetFor(ctx, key, cache.Tags{Tenant: tenant, Route: route.Name}, next, policy.TTL()); err != nil {
+		logError(requestID, "failed to store the session summary", err)
+	}
+}
+
+// planSessionSummary works out the summary that brings messages, a
+// conversation whose first turns stored may cover, within budget: stored's
+// summary and the oldest turns after it, summarized together. It reports
+// false when messages already fit.
+func planSessionSummary(ctx context.Context, messages []providers.Message, stored sessionSummary, budget, summaryTokens int, summarize func(context.Context, []providers.Message) (string, error)) (sessionSummary, bool, error) {
+	view, ok := withSummary(messages, sessionSummary{Covers: stored.Covers, Hash: stored.Hash})
+	if !ok {
+		view, stored = messages, sessionSummary{}
+	}
+	if stored.Summary != "" {
+		// The prompt carries the current summary until the new one replaces it.
+		budget -= compact.Count([]providers.Message{summaryMessage(stored.Summary)})
+	}
+	var covered int
+	var summary string
+	_, err := compact.Compact(ctx, view, compact.Options{
+		Strategy: compact.Summarize, Budget: budget, SummaryTokens: summaryTokens,
+		Summarize: func(ctx context.Context, turns []providers.Message) (string, error) {
+			covered = len(turns)
+			if stored.Summary != "" {
+				turns = append([]providers.Message{summaryMessage(stored.Summary)}, turns...)
+			}
+			var err error
+			summary, err = summarize(ctx, turns)
+			return summary, err
+		},
+	})
+	if err != nil || covered == 0 {
+		return sessionSummary{}, false, err
+	}
+	next := sessionSummary{Covers: stored.Covers + covered, Summary: strings.TrimSpace(summary)}
+	var turns []providers.Message
+	for _, m := range messages {
+		if m.Role != "system" && len(turns) < next.Covers {
+			turns = append(turns, m)
+		}
+	}
+	next.Hash = hashMessages(turns)
+	return next, true, nil
+}
+
+// withSummary returns messages with the first s.Covers messages that are
+// not system messages replaced by s's summary. It reports false unless
+// messages resend those turns unchanged and go on after them.
+func withSummary(messages []providers.Message, s sessionSummary) ([]providers.Message, bool) {
+	if s.Covers == 0 {
+		return messages, true
+	}
+	var out, covered []providers.Message
+	after := 0
+	for _, m := range messages {
+		switch {
+		case m.Role == "system":
+			out = append(out, m)
+		case len(covered) < s.Covers:
+			if len(covered) == 0 && s.Summary != "" {
+				out = append(out, summaryMessage(s.Summary))
+			}
+			covered = append(covered, m)
+		default:
+			out = append(out, m)
+			after++
+		}
+	}
+	if after == 0 || hashMessages(covered) != s.Hash {
+		return nil, false
+	}
+	return out, true
+}
+
+func summaryMessage(summary string) providers.Message {
+	return providers.Message{Role: "system", Content: compact.SummaryPrefix + summary}
+}
+
+// hashMessages identifies a run of messages.
+func hashMessages(messages []providers.Message) string {
+	b, _ := json.Marshal(messages)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
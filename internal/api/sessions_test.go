@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/cache"
+	"github.com/yewintnaing/ai-gateway/internal/compact"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/kv"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// session returns a conversation of n turns of about 100 tokens each,
+// after a system message.
+func session(n int) []providers.Message {
+	messages := []providers.Message{{Role: "system", Content: "Be helpful."}}
+	for i := range n {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages = append(messages, providers.Message{Role: role, Content: strings.Repeat(string(rune('a'+i)), 400)})
+	}
+	return messages
+}
+
+func TestPlanSessionSummary(t *testing.T) {
+	ctx := context.Background()
+	var summarized [][]providers.Message
+	summarize := func(ctx context.Context, turns []providers.Message) (string, error) {
+		summarized = append(summarized, turns)
+		return "summary " + string(rune('0'+len(summarized))), nil
+	}
+
+	if _, ok, err := planSessionSummary(ctx, session(4), sessionSummary{}, 1000, 50, summarize); ok || err != nil {
+		t.Fatalf("expected a short conversation left alone, got %v, %v", ok, err)
+	}
+
+	first, ok, err := planSessionSummary(ctx, session(9), sessionSummary{}, 600, 50, summarize)
+	if err != nil || !ok {
+		t.Fatalf("expected a summary, got %v, %v", ok, err)
+	}
+	if first.Covers == 0 || first.Covers != len(summarized[0]) || first.Summary != "summary 1" {
+		t.Fatalf("unexpected summary %+v of %d turns", first, len(summarized[0]))
+	}
+
+	// The next request resends the conversation with two more turns.
+	messages, ok := withSummary(session(11), first)
+	if !ok {
+		t.Fatal("expected the summary to apply to the resent conversation")
+	}
+	if messages[1].Content != compact.SummaryPrefix+"summary 1" || len(messages) != 11-first.Covers+2 {
+		t.Errorf("unexpected messages with the summary: %d, %q", len(messages), messages[1].Content)
+	}
+
+	second, ok, err := planSessionSummary(ctx, session(11), first, 600, 50, summarize)
+	if err != nil || !ok {
+		t.Fatalf("expected the summary extended, got %v, %v", ok, err)
+	}
+	if second.Covers <= first.Covers || summarized[1][0].Content != compact.SummaryPrefix+"summary 1" {
+		t.Errorf("expected the old summary summarized with the next turns, got %+v", second)
+	}
+	if _, ok := withSummary(session(11), second); !ok {
+		t.Error("expected the extended summary to apply")
+	}
+
+	edited := session(11)
+	edited[1].Content = "changed"
+	if _, ok := withSummary(edited, second); ok {
+		t.Error("expected a summary of a different history not to apply")
+	}
+	if _, ok := withSummary(session(second.Covers), second); ok {
+		t.Error("expected a summary covering every turn not to apply")
+	}
+}
+
+func TestApplySessionSummary(t *testing.T) {
+	store := &usage.Store{}
+	ctx, _ := store.Begin(context.Background(), "r1")
+	h := &Handler{usage: store, cache: cache.New(kv.NewMemoryStore(100), time.Hour)}
+	route := config.Route{Name: "chat", SessionSummary: &config.SessionSummary{BudgetTokens: 100000}}
+
+	stored := sessionSummary{Covers: 2, Hash: hashMessages(session(2)[1:]), Summary: "they said hello"}
+	if err := h.cache.SetFor(ctx, sessionKey("acme", "c1"), cache.Tags{Tenant: "acme"}, stored, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	req := ChatRequest{Messages: session(4)}
+	h.applySessionSummary(ctx, &req, route, "acme", "", "", "c1", "r1")
+	if len(req.Messages) != 4 || req.Messages[1].Content != compact.SummaryPrefix+"they said hello" {
+		t.Errorf("expected the stored summary in place of two turns, got %d messages", len(req.Messages))
+	}
+
+	req = ChatRequest{Messages: session(4)}
+	h.applySessionSummary(ctx, &req, route, "other", "", "", "c1", "r1")
+	if len(req.Messages) != 5 {
+		t.Error("expected another tenant's conversation left alone")
+	}
+}
+
+func TestSummarizerRoute(t *testing.T) {
+	h := &Handler{
+		tenants: map[string]config.Tenant{"acme-eu": {Name: "acme-eu", Residency: "eu"}, "private": {Name: "private", ZeroRetention: true}},
+		providerOpts: map[string]config.ProviderOptions{
+			"openai":  {Region: "us"},
+			"mistral": {Region: "eu"},
+		},
+	}
+	route := config.Route{Name: "chat", TimeoutMS: 5000}
+	openai := config.Target{Provider: "openai", Model: "gpt-4o-mini"}
+	mistral := config.Target{Provider: "mistral", Model: "mistral-small"}
+
+	if s, ok := h.summarizerRoute(route, openai, "acme", ""); !ok || s.Primary.Model != openai.Model || s.TimeoutMS != 5000 {
+		t.Errorf("expected a tenant without a policy summarized by openai, got %+v, %v", s, ok)
+	}
+	if _, ok := h.summarizerRoute(route, openai, "acme-eu", "eu"); ok {
+		t.Error("expected a US summarizer ruled out for EU data")
+	}
+	if s, ok := h.summarizerRoute(route, mistral, "acme-eu", "eu"); !ok || s.Primary.Model != mistral.Model {
+		t.Errorf("expected an EU summarizer allowed, got %+v, %v", s, ok)
+	}
+	if _, ok := h.summarizerRoute(route, mistral, "private", ""); ok {
+		t.Error("expected a summarizer without a zero-retention agreement ruled out")
+	}
+}
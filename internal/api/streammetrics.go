@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+// Outcomes a stream's duration is recorded with.
+const (
+	streamCompleted  = "completed"  // the provider finished the stream
+	streamTruncated  = "truncated"  // cut off at the completion budget
+	streamTerminated = "terminated" // stopped by a content policy
+	streamFailed     = "failed"     // the provider failed mid-stream
+	streamCancelled  = "cancelled"  // the client went away
+)
+
+// streamMetrics are histograms of how streams progress, which show a
+// provider slowing down mid-stream even when total latency looks normal.
+type streamMetrics struct {
+	tokensPerSecond metric.Float64Histogram
+	chunkGap        metric.Float64Histogram
+	duration        metric.Float64Histogram
+}
+
+func newStreamMetrics() streamMetrics {
+	meter := otel.Meter("gateway-handler")
+	var m streamMetrics
+	m.tokensPerSecond, _ = meter.Float64Histogram("gateway.stream.tokens_per_second",
+		metric.WithDescription("Completion tokens per second of completed streams, after the first token"),
+		metric.WithUnit("{token}/s"),
+		metric.WithExplicitBucketBoundaries(1, 5, 10, 20, 30, 50, 75, 100, 150, 200, 300, 500, 1000))
+	m.chunkGap, _ = meter.Float64Histogram("gateway.stream.chunk_gap",
+		metric.WithDescription("Time between consecutive streamed chunks"),
+		metric.WithUnit("ms"),
+		metric.WithExplicitBucketBoundaries(1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000))
+	m.duration, _ = meter.Float64Histogram("gateway.stream.duration",
+		metric.WithDescription("Time from the provider call to the end of a stream, by outcome"),
+		metric.WithUnit("ms"),
+		metric.WithExplicitBucketBoundaries(100, 250, 500, 1000, 2500, 5000, 10000, 20000, 30000, 60000, 120000, 300000))
+	return m
+}
+
+// streamMeter measures one streamed attempt.
+type streamMeter struct {
+	metrics *streamMetrics
+	attrs   metric.MeasurementOption
+	start   time.Time
+	last    time.Time // when the previous chunk arrived
+}
+
+func (m *streamMetrics) start(target config.Target, start time.Time) *streamMeter {
+	return &streamMeter{
+		metrics: m,
+		attrs: metric.WithAttributes(
+			attribute.String("provider", target.Provider),
+			attribute.String("model", target.Model),
+		),
+		start: start,
+	}
+}
+
+// chunk records the gap since the previous chunk. The first chunk's wait
+// is the time to first token, which is recorded separately.
+func (s *streamMeter) chunk(ctx context.Context, at time.Time) {
+	if !s.last.IsZero() && s.metrics.chunkGap != nil {
+		s.metrics.chunkGap.Record(ctx, float64(at.Sub(s.last))/float64(time.Millisecond), s.attrs)
+	}
+	s.last = at
+}
+
+// finish records the stream's duration and, for a completed stream, the
+// rate at which tokens were generated after the first one arrived.
+func (s *streamMeter) finish(ctx context.Context, outcome string, tokens int, ttft time.Duration) {
+	elapsed := time.Since(s.start)
+	if s.metrics.duration != nil {
+		s.metrics.duration.Record(ctx, float64(elapsed)/float64(time.Millisecond), s.attrs,
+			metric.WithAttributes(attribute.String("outcome", outcome)))
+	}
+	generating := elapsed - ttft
+	if outcome != streamCompleted || tokens < minSpeedSample || generating <= 0 || s.metrics.tokensPerSecond == nil {
+		return
+	}
+	s.metrics.tokensPerSecond.Record(ctx, float64(tokens)/generating.Seconds(), s.attrs)
+}
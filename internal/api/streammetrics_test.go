@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func TestStreamMeter(t *testing.T) {
+	reader := metric.NewManualReader()
+	meter := metric.NewMeterProvider(metric.WithReader(reader)).Meter("test")
+	var m streamMetrics
+	m.tokensPerSecond, _ = meter.Float64Histogram("tps")
+	m.chunkGap, _ = meter.Float64Histogram("gap")
+	m.duration, _ = meter.Float64Histogram("duration")
+	ctx := context.Background()
+
+	start := time.Now().Add(-2 * time.Second)
+	s := m.start(config.Target{Provider: "openai", Model: "gpt-4o"}, start)
+	s.chunk(ctx, start.Add(time.Second))
+	s.chunk(ctx, start.Add(1100*time.Millisecond))
+	s.chunk(ctx, start.Add(1300*time.Millisecond))
+	s.finish(ctx, streamCompleted, 100, time.Second)
+
+	// A failed stream counts towards duration only.
+	failed := m.start(config.Target{Provider: "openai", Model: "gpt-4o"}, start)
+	failed.finish(ctx, streamFailed, 100, time.Second)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]metricdata.Histogram[float64]{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, metric := range sm.Metrics {
+			got[metric.Name] = metric.Data.(metricdata.Histogram[float64])
+		}
+	}
+	if gap := got["gap"].DataPoints; len(gap) != 1 || gap[0].Count != 2 || gap[0].Sum < 299 || gap[0].Sum > 301 {
+		t.Errorf("expected two gaps totalling 300ms, got %+v", gap)
+	}
+	if d := got["duration"].DataPoints; len(d) != 2 {
+		t.Errorf("expected durations by outcome, got %+v", d)
+	}
+	// 100 tokens over the ~1s after the first token.
+	if tps := got["tps"].DataPoints; len(tps) != 1 || tps[0].Count != 1 || tps[0].Sum < 90 || tps[0].Sum > 101 {
+		t.Errorf("expected one rate near 100 tokens/s, got %+v", tps)
+	}
+}
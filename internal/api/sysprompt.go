@@ -0,0 +1,34 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// applySystemPrompt merges the route's managed system prompt with the
+// client's system messages into a single leading system message.
+func applySystemPrompt(messages []providers.Message, cfg config.SystemPrompt) []providers.Message {
+	var parts []string
+	if cfg.Content != "" {
+		parts = append(parts, cfg.Content)
+	}
+
+	rest := make([]providers.Message, 0, len(messages)+1)
+	for _, m := range messages {
+		if m.Role == "system" {
+			if cfg.Mode != "replace" {
+				parts = append(parts, m.Content)
+			}
+			continue
+		}
+		rest = append(rest, m)
+	}
+
+	system := cfg.Prefix + strings.Join(parts, "\n\n") + cfg.Suffix
+	if system == "" {
+		return rest
+	}
+	return append([]providers.Message{{Role: "system", Content: system}}, rest...)
+}
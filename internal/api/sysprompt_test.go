@@ -0,0 +1,42 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestApplySystemPrompt(t *testing.T) {
+	client := []providers.Message{
+		{Role: "system", Content: "Client rules."},
+		{Role: "user", Content: "Hi"},
+	}
+
+	tests := []struct {
+		name     string
+		messages []providers.Message
+		cfg      config.SystemPrompt
+		want     string
+	}{
+		{"Prepend", client, config.SystemPrompt{Content: "Managed."}, "Managed.\n\nClient rules."},
+		{"Replace", client, config.SystemPrompt{Content: "Managed.", Mode: "replace"}, "Managed."},
+		{"Prefix and suffix", client, config.SystemPrompt{Prefix: "[", Suffix: "]"}, "[Client rules.]"},
+		{"Inject when client has none", client[1:], config.SystemPrompt{Content: "Managed."}, "Managed."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := applySystemPrompt(tt.messages, tt.cfg)
+			if len(out) != 2 {
+				t.Fatalf("expected 2 messages, got %d", len(out))
+			}
+			if out[0].Role != "system" || out[0].Content != tt.want {
+				t.Errorf("expected system %q, got %q", tt.want, out[0].Content)
+			}
+			if out[1].Content != "Hi" {
+				t.Errorf("expected user message preserved, got %q", out[1].Content)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+const (
+	// assumedSpeed is the generation speed, in output tokens per second,
+	// assumed for a target before any of its completions have been timed.
+	assumedSpeed = 20.0
+	// assumedMaxTokens is the output length assumed when a request sets no
+	// max_tokens.
+	assumedMaxTokens = 4096
+	// assumedTTFT is the time to first token assumed for a target without
+	// samples.
+	assumedTTFT = 2 * time.Second
+	// timeoutHeadroom scales the expected generation time, so a target
+	// running somewhat slower than its average is not cut off.
+	timeoutHeadroom = 1.5
+	maxTimeout      = 10 * time.Minute
+	// minSpeedSample is the shortest completion timed for speed; shorter
+	// ones are dominated by fixed overhead.
+	minSpeedSample = 32
+)
+
+// attemptTimeout bounds one provider call: the target's time to first
+// token plus the time to generate maxTokens at its observed speed, with
+// headroom. It never drops below the route's timeout_ms (or the providers'
+// default) and never exceeds ten minutes.
+func attemptTimeout(route config.Route, maxTokens int, ttft time.Duration, speed float64) time.Duration {
+	floor := time.Duration(route.TimeoutMS) * time.Millisecond
+	if floor <= 0 {
+		floor = providers.DefaultTimeout
+	}
+	if maxTokens <= 0 {
+		maxTokens = assumedMaxTokens
+	}
+	if ttft <= 0 {
+		ttft = assumedTTFT
+	}
+	if speed <= 0 {
+		speed = assumedSpeed
+	}
+	generation := time.Duration(float64(maxTokens) / speed * timeoutHeadroom * float64(time.Second))
+	return min(max(ttft+generation, floor), max(maxTimeout, floor))
+}
+
+// targetTimeout is attemptTimeout with target's recorded statistics.
+func (h *Handler) targetTimeout(ctx context.Context, route config.Route, target config.Target, maxTokens int) time.Duration {
+	key := target.Provider + "/" + target.Model
+	ttft, _ := h.health.TTFT(ctx, key)
+	speed, _ := h.health.Speed(ctx, key)
+	return attemptTimeout(route, maxTokens, ttft, speed)
+}
+
+// recordSpeed reports how fast target generated tokens output tokens over
+// elapsed, less the time to first token when known. Without it the whole
+// call is timed, which errs on the slow side.
+func (h *Handler) recordSpeed(ctx context.Context, target config.Target, tokens int, elapsed, ttft time.Duration) {
+	if tokens < minSpeedSample {
+		return
+	}
+	if ttft > 0 && ttft < elapsed {
+		elapsed -= ttft
+	}
+	h.health.RecordSpeed(ctx, target.Provider+"/"+target.Model, tokens, elapsed)
+}
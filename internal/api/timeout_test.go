@@ -0,0 +1,33 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func TestAttemptTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		route     config.Route
+		maxTokens int
+		ttft      time.Duration
+		speed     float64
+		want      time.Duration
+	}{
+		{"short output keeps the default floor", config.Route{}, 100, time.Second, 50, 30 * time.Second},
+		{"route timeout is the floor", config.Route{TimeoutMS: 45000}, 100, time.Second, 50, 45 * time.Second},
+		// 1s + 4000 tokens / 50 tok/s * 1.5 = 121s
+		{"long output at observed speed", config.Route{}, 4000, time.Second, 50, 121 * time.Second},
+		// 2s + 4096 / 20 * 1.5 = 309.2s
+		{"no statistics or max_tokens", config.Route{}, 0, 0, 0, 309200 * time.Millisecond},
+		{"capped", config.Route{}, 100000, time.Second, 10, 10 * time.Minute},
+		{"route timeout above the cap", config.Route{TimeoutMS: 900000}, 100, time.Second, 50, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := attemptTimeout(tt.route, tt.maxTokens, tt.ttft, tt.speed); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
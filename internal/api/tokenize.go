@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// TokenizeRequest asks for the token count of text or of a chat request's
+// messages. The model is the request's, or else the primary target of the
+// route metadata.use_case selects.
+type TokenizeRequest struct {
+	Model    string                 `json:"model"`
+	Text     string                 `json:"text"`
+	Messages []providers.Message    `json:"messages"`
+	TokenIDs bool                   `json:"token_ids"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// TokenizeResponse counts the input as the gateway does for rate limits,
+// budgets and cost estimates. Token IDs and the context window come from
+// the backend's own tokenizer.
+type TokenizeResponse struct {
+	Model         string `json:"model"`
+	Provider      string `json:"provider,omitempty"`
+	Route         string `json:"route,omitempty"`
+	Tokens        int    `json:"tokens"`
+	TokenIDs      []int  `json:"token_ids,omitempty"`
+	ContextWindow int    `json:"context_window,omitempty"`
+}
+
+// tokenizeTimeout bounds a call to a backend's tokenizer.
+const tokenizeTimeout = 10 * time.Second
+
+// HandleTokenize counts the tokens of text or messages, so clients can
+// check a prompt against limits before sending it.
+func (h *Handler) HandleTokenize(w http.ResponseWriter, r *http.Request) {
+	var req TokenizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid request body", "")
+		return
+	}
+	if (req.Text == "") == (len(req.Messages) == 0) {
+		h.respondError(w, http.StatusBadRequest, "give either text or messages", "")
+		return
+	}
+
+	resp := TokenizeResponse{Model: req.Model}
+	if req.Model == "" {
+		useCase, _ := req.Metadata["use_case"].(string)
+		route := h.router.Route(useCase)
+		resp.Route, resp.Model, resp.Provider = route.Name, route.Primary.Model, route.Primary.Provider
+	} else {
+		resp.Provider = h.modelProvider(req.Model)
+	}
+	// The same count chat completions are charged with before the call.
+	if req.Text != "" {
+		resp.Tokens = usage.ApproximateTokens(req.Text)
+	} else {
+		resp.Tokens = usage.ApproximateTokens(fmt.Sprintf("%v", req.Messages))
+	}
+
+	if req.TokenIDs {
+		opts := h.providerOpts[resp.Provider]
+		if opts.TokenizeURL == "" {
+			h.respondError(w, http.StatusBadRequest, fmt.Sprintf("model %s has no tokenizer that returns token IDs", resp.Model), "")
+			return
+		}
+		ids, window, err := backendTokenize(r.Context(), opts, resp.Model, req)
+		if err != nil {
+			logError("", "backend tokenizer failed", err)
+			h.respondError(w, http.StatusBadGateway, "backend tokenizer failed: "+err.Error(), "")
+			return
+		}
+		resp.TokenIDs, resp.ContextWindow = ids, window
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// modelProvider returns the provider of the first route target serving
+// model, or "" when no route does.
+func (h *Handler) modelProvider(model string) string {
+	for _, route := range h.router.Routes() {
+		for _, t := range append([]config.Target{route.Primary}, route.Fallbacks...) {
+			if t.Model == model {
+				return t.Provider
+			}
+		}
+	}
+	return ""
+}
+
+// backendTokenize asks a backend's vLLM-style tokenizer for the token IDs
+// of req's input, and the context window it reports.
+func backendTokenize(ctx context.Context, opts config.ProviderOptions, model string, req TokenizeRequest) ([]int, int, error) {
+	in := map[string]interface{}{"model": model}
+	if req.Text != "" {
+		in["prompt"] = req.Text
+	} else {
+		in["messages"] = req.Messages
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, tokenizeTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.TokenizeURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if key := opts.APIKey(); key != "" {
+		header, scheme := opts.AuthHeader, opts.AuthScheme
+		if header == "" {
+			header = "Authorization"
+		}
+		switch scheme {
+		case "":
+			httpReq.Header.Set(header, "Bearer "+key)
+		case "none":
+			httpReq.Header.Set(header, key)
+		default:
+			httpReq.Header.Set(header, scheme+" "+key)
+		}
+	}
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, 0, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	var out struct {
+		Tokens      []int `json:"tokens"`
+		MaxModelLen int   `json:"max_model_len"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, 0, err
+	}
+	if out.Tokens == nil {
+		return nil, 0, errors.New("response has no tokens")
+	}
+	return out.Tokens, out.MaxModelLen, nil
+}
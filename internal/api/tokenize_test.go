@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/router"
+)
+
+func TestHandleTokenize(t *testing.T) {
+	var got map[string]interface{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-vllm" {
+			t.Errorf("unexpected auth %q", r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{"count":3,"max_model_len":8192,"tokens":[9906,11,1917]}`)
+	}))
+	defer backend.Close()
+
+	h := &Handler{
+		router: router.NewRouter([]config.Route{
+			{Name: "chat", Match: config.Match{UseCase: "chat"}, Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, Fallbacks: []config.Target{{Provider: "vllm", Model: "llama-3-8b"}}},
+		}),
+		providerOpts: map[string]config.ProviderOptions{"vllm": {TokenizeURL: backend.URL + "/tokenize", Key: "sk-vllm"}},
+	}
+	call := func(body string) (int, TokenizeResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.HandleTokenize(w, httptest.NewRequest("POST", "/v1/tokenize", strings.NewReader(body)))
+		var resp TokenizeResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	code, resp := call(`{"text":"Hello, world! How are you?","metadata":{"use_case":"chat"}}`)
+	if code != http.StatusOK || resp.Route != "chat" || resp.Model != "gpt-4o" || resp.Tokens != 6 || resp.TokenIDs != nil {
+		t.Errorf("unexpected count by route: %d %+v", code, resp)
+	}
+
+	code, resp = call(`{"model":"llama-3-8b","messages":[{"role":"user","content":"Hello, world"}],"token_ids":true}`)
+	if code != http.StatusOK || resp.Provider != "vllm" || len(resp.TokenIDs) != 3 || resp.ContextWindow != 8192 {
+		t.Errorf("unexpected backend tokenization: %d %+v", code, resp)
+	}
+	if got["model"] != "llama-3-8b" || got["messages"] == nil {
+		t.Errorf("unexpected backend request %v", got)
+	}
+
+	if code, _ := call(`{"model":"gpt-4o","text":"hi","token_ids":true}`); code != http.StatusBadRequest {
+		t.Errorf("expected token IDs of a model without a tokenizer to fail, got %d", code)
+	}
+	if code, _ := call(`{"model":"gpt-4o"}`); code != http.StatusBadRequest {
+		t.Errorf("expected a request without input to fail, got %d", code)
+	}
+}
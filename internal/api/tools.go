@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// toolLoopError is a model that was still calling gateway-owned tools when
+// the route's iteration limit was reached. The calls are the gateway's own,
+// so they are not handed to the client; the request moves on to the next
+// target instead.
+type toolLoopError struct {
+	iterations int
+}
+
+func (e *toolLoopError) Error() string {
+	return fmt.Sprintf("tool loop did not finish within %d iterations", e.iterations)
+}
+
+// runTools executes the gateway-owned tools the model calls and feeds the
+// results back until it answers without calling one. A response that also
+// calls a tool the gateway doesn't own is returned to the client as is; one
+// still calling only gateway tools at the iteration limit is a
+// toolLoopError. The model sees masked PII, so arguments are unmasked with
+// unmaskMap before a tool gets them, and results are masked before the
+// model does, adding their tokens to unmaskMap. Usage of every round is
+// folded into the returned response, and each call is recorded as a
+// tool_call event with the arguments as the model sent them.
+func (h *Handler) runTools(ctx context.Context, p providers.Provider, req providers.ChatRequest, resp *providers.ChatResponse, cfg config.ToolLoop, unmaskMap map[string]string, requestID string) (*providers.ChatResponse, error) {
+	maxIterations := cfg.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = 5
+	}
+
+	spent := resp.Usage
+	messages := append([]providers.Message(nil), req.Messages...)
+	for iteration := 1; iteration <= maxIterations; iteration++ {
+		if len(resp.Choices) == 0 || !h.ownsAll(resp.Choices[0].Message.ToolCalls, cfg.Enabled) {
+			break
+		}
+
+		assistant := resp.Choices[0].Message
+		messages = append(messages, assistant)
+		for _, call := range assistant.ToolCalls {
+			start := time.Now()
+			arguments := call.Function.Arguments
+			if h.detector != nil {
+				arguments = h.detector.Unmask(arguments, unmaskMap)
+			}
+			result, err := h.tools.Call(ctx, call.Function.Name, arguments)
+			detail := map[string]interface{}{
+				"tool":        call.Function.Name,
+				"arguments":   call.Function.Arguments,
+				"iteration":   iteration,
+				"duration_ms": time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				// The model gets to see the failure and decide what to do.
+				result = "error: " + err.Error()
+				detail["error"] = err.Error()
+			}
+			h.usage.LogEvent(ctx, requestID, usage.Event{Kind: "tool_call", Detail: detail})
+			messages = append(messages, providers.Message{Role: "tool", ToolCallID: call.ID, Content: h.maskInto(result, unmaskMap)})
+		}
+
+		next := req
+		next.Messages = messages
+		var err error
+		resp, err = p.Chat(next)
+		if err != nil {
+			return nil, err
+		}
+		spent.PromptTokens += resp.Usage.PromptTokens
+		spent.CompletionTokens += resp.Usage.CompletionTokens
+		spent.TotalTokens += resp.Usage.TotalTokens
+	}
+	if len(resp.Choices) > 0 && h.ownsAll(resp.Choices[0].Message.ToolCalls, cfg.Enabled) {
+		return nil, &toolLoopError{iterations: maxIterations}
+	}
+	resp.Usage = spent
+	return resp, nil
+}
+
+// maskInto masks PII in text and adds its tokens to unmaskMap. A token the
+// map already holds for a different value is renumbered, so that text
+// masked after the prompt unmasks to its own values.
+func (h *Handler) maskInto(text string, unmaskMap map[string]string) string {
+	if h.detector == nil || unmaskMap == nil {
+		return text
+	}
+	masked, m := h.detector.Mask(text)
+	for token, original := range m {
+		fresh := token
+		for n := 2; ; n++ {
+			if held, ok := unmaskMap[fresh]; !ok || held == original {
+				break
+			}
+			fresh = fmt.Sprintf("%s#%d]", strings.TrimSuffix(token, "]"), n)
+		}
+		masked = strings.ReplaceAll(masked, token, fresh)
+		unmaskMap[fresh] = original
+	}
+	return masked
+}
+
+// ownsAll reports whether calls is non-empty and every call is to a tool
+// the route enables and the gateway runs. A model may name any registered
+// tool, not only those it was offered.
+func (h *Handler) ownsAll(calls []providers.ToolCall, enabled []string) bool {
+	for _, call := range calls {
+		if !slices.Contains(enabled, call.Function.Name) || !h.tools.Has(call.Function.Name) {
+			return false
+		}
+	}
+	return len(calls) > 0
+}
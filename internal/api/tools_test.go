@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/governance"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/tools"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+func TestOwnsAll(t *testing.T) {
+	reg, err := tools.NewRegistry([]config.ToolDef{
+		{Name: "weather", HTTP: &config.HTTPTool{URL: "http://tools.internal/weather"}},
+		{Name: "refund", HTTP: &config.HTTPTool{URL: "http://tools.internal/refund"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h := &Handler{tools: reg}
+	enabled := []string{"weather"}
+	call := func(name string) providers.ToolCall {
+		return providers.ToolCall{ID: name, Type: "function", Function: providers.ToolCallFunction{Name: name}}
+	}
+
+	if h.ownsAll(nil, enabled) {
+		t.Errorf("expected no calls to mean nothing to run")
+	}
+	if !h.ownsAll([]providers.ToolCall{call("weather")}, enabled) {
+		t.Errorf("expected gateway tool to be run")
+	}
+	if h.ownsAll([]providers.ToolCall{call("weather"), call("client_side")}, enabled) {
+		t.Errorf("expected a client tool call to be handed back to the client")
+	}
+	if h.ownsAll([]providers.ToolCall{call("refund")}, enabled) {
+		t.Errorf("expected a registered tool the route does not enable not to be run")
+	}
+}
+
+// toolCallProvider answers every request with a call to the lookup tool,
+// for its first calls requests, and then with the last message's content.
+type toolCallProvider struct {
+	arguments string
+	calls     int
+	requests  []providers.ChatRequest
+}
+
+func (p *toolCallProvider) Chat(req providers.ChatRequest) (*providers.ChatResponse, error) {
+	p.requests = append(p.requests, req)
+	last := req.Messages[len(req.Messages)-1]
+	resp := completion(last.Content)
+	if len(p.requests) <= p.calls {
+		resp.Choices[0].Message = providers.Message{Role: "assistant", ToolCalls: []providers.ToolCall{
+			{ID: "call_1", Type: "function", Function: providers.ToolCallFunction{Name: "lookup", Arguments: p.arguments}},
+		}}
+	}
+	resp.Usage = providers.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	return resp, nil
+}
+
+func (p *toolCallProvider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatChunk, <-chan error) {
+	return nil, nil
+}
+
+func TestRunTools(t *testing.T) {
+	var received string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.Write([]byte(`{"owner": "bob@example.com"}`))
+	}))
+	defer srv.Close()
+	reg, err := tools.NewRegistry([]config.ToolDef{{Name: "lookup", HTTP: &config.HTTPTool{URL: srv.URL}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store := &usage.Store{}
+	ctx, _ := store.Begin(context.Background(), "r1")
+	h := &Handler{tools: reg, detector: governance.NewDetector(), usage: store}
+	cfg := config.ToolLoop{Enabled: []string{"lookup"}, MaxIterations: 2}
+
+	// The model sees the prompt's PII masked: the tool gets it unmasked, and
+	// the model gets the tool's PII masked under a token of its own.
+	messages, unmaskMap := h.maskMessages([]providers.Message{{Role: "user", Content: "Who owns alice@example.com's ticket?"}})
+	p := &toolCallProvider{arguments: `{"email": "[EMAIL_1]"}`, calls: 1}
+	req := providers.ChatRequest{Messages: messages}
+	first, _ := p.Chat(req)
+	resp, err := h.runTools(ctx, p, req, first, cfg, unmaskMap, "r1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received != `{"email": "alice@example.com"}` {
+		t.Errorf("expected the tool to get unmasked arguments, got %s", received)
+	}
+	result := p.requests[1].Messages[2].Content
+	if strings.Contains(result, "bob@example.com") || result != `{"owner": "[EMAIL_1#2]"}` {
+		t.Errorf("expected the tool result masked, got %s", result)
+	}
+	if got := h.detector.Unmask(resp.Choices[0].Message.Content, unmaskMap); got != `{"owner": "bob@example.com"}` {
+		t.Errorf("expected the completion to unmask to the tool's value, got %s", got)
+	}
+	if resp.Usage.TotalTokens != 30 {
+		t.Errorf("expected usage of both rounds, got %+v", resp.Usage)
+	}
+
+	// A model still calling gateway tools at the limit is not handed back
+	// with calls the client never declared.
+	p = &toolCallProvider{arguments: `{}`, calls: 10}
+	first, _ = p.Chat(req)
+	_, err = h.runTools(ctx, p, req, first, cfg, unmaskMap, "r1")
+	var loop *toolLoopError
+	if !errors.As(err, &loop) || len(p.requests) != 3 {
+		t.Errorf("expected a tool loop error after 2 iterations, got %v after %d requests", err, len(p.requests))
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"unicode/utf8"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceSnippets records the last user message and the completion as span
+// events, truncated and with PII and credentials redacted. It only does so
+// when snippets are enabled and the span will be exported: it is sampled or
+// has failed.
+func (h *Handler) traceSnippets(span trace.Span, messages []providers.Message, completion string) {
+	if h.snippets <= 0 || !span.IsRecording() {
+		return
+	}
+	if !span.SpanContext().IsSampled() && !spanFailed(span) {
+		return
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			span.AddEvent("prompt", trace.WithAttributes(attribute.String("snippet", h.snippet(messages[i].Content))))
+			break
+		}
+	}
+	if completion != "" {
+		span.AddEvent("completion", trace.WithAttributes(attribute.String("snippet", h.snippet(completion))))
+	}
+}
+
+func (h *Handler) snippet(text string) string {
+	if h.detector != nil {
+		text, _ = h.detector.Mask(text)
+	}
+	text, _ = h.secrets.Redact(text)
+	if utf8.RuneCountInString(text) <= h.snippets {
+		return text
+	}
+	return string([]rune(text)[:h.snippets]) + "…"
+}
+
+// spanFailed reports whether an error status has been set on span.
+func spanFailed(span trace.Span) bool {
+	ro, ok := span.(sdktrace.ReadOnlySpan)
+	return ok && ro.Status().Code == codes.Error
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/governance"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceSnippets(t *testing.T) {
+	h := &Handler{detector: governance.NewDetector(), secrets: governance.NewSecretScanner(), snippets: 24}
+	messages := []providers.Message{{Role: "user", Content: "Mail me at jane@example.com about the invoice please"}}
+
+	spans := func(sampler sdktrace.Sampler, fail bool) tracetest.SpanStubs {
+		rec := tracetest.NewSpanRecorder()
+		tracer := sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler), sdktrace.WithSpanProcessor(rec)).Tracer("test")
+		_, span := tracer.Start(context.Background(), "attempt")
+		if fail {
+			span.SetStatus(codes.Error, "boom")
+		}
+		h.traceSnippets(span, messages, "Sure.")
+		span.End()
+		return tracetest.SpanStubsFromReadOnlySpans(rec.Ended())
+	}
+
+	got := spans(sdktrace.AlwaysSample(), false)
+	if len(got) != 1 || len(got[0].Events) != 2 {
+		t.Fatalf("expected prompt and completion events, got %+v", got)
+	}
+	prompt := got[0].Events[0].Attributes[0].Value.AsString()
+	if prompt != "Mail me at [EMAIL_1] abo…" {
+		t.Errorf("expected truncated, masked prompt, got %q", prompt)
+	}
+
+	unsampled := recordOnly{}
+	if got := spans(unsampled, false); len(got[0].Events) != 0 {
+		t.Errorf("expected no events on an unsampled, successful span")
+	}
+	if got := spans(unsampled, true); len(got[0].Events) != 2 {
+		t.Errorf("expected events on an unsampled, failed span")
+	}
+}
+
+type recordOnly struct{}
+
+func (recordOnly) ShouldSample(sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return sdktrace.SamplingResult{Decision: sdktrace.RecordOnly}
+}
+
+func (recordOnly) Description() string { return "RecordOnly" }
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+// chatRequestFields are the JSON names of the fields ChatRequest decodes.
+var chatRequestFields = func() map[string]bool {
+	fields := map[string]bool{}
+	t := reflect.TypeOf(ChatRequest{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}()
+
+// fixedFields are the body fields a transform may not touch: they decide
+// what is asked and how it is answered, not how a provider is called.
+var fixedFields = map[string]bool{"messages": true, "stream": true, "metadata": true}
+
+// applyTransform rewrites req by a route's transform and returns the fields
+// it changed, metadata keys prefixed "metadata.". Metadata keys are
+// renamed first, then fields removed, defaults filled in and fields set.
+func applyTransform(req *ChatRequest, t *config.Transform) ([]string, error) {
+	if t == nil {
+		return nil, nil
+	}
+	var changed []string
+	for _, from := range sortedKeys(t.RenameMetadata) {
+		v, ok := req.Metadata[from]
+		if !ok {
+			continue
+		}
+		to := t.RenameMetadata[from]
+		delete(req.Metadata, from)
+		if _, exists := req.Metadata[to]; !exists {
+			req.Metadata[to] = v
+		}
+		changed = append(changed, "metadata."+from)
+	}
+
+	body, err := bodyFields(*req)
+	if err != nil {
+		return nil, err
+	}
+	field := func(name string) (map[string]interface{}, string) {
+		if key, ok := strings.CutPrefix(name, "metadata."); ok {
+			if req.Metadata == nil {
+				req.Metadata = map[string]interface{}{}
+			}
+			return req.Metadata, key
+		}
+		if chatRequestFields[name] {
+			return body, name
+		}
+		if req.extra == nil {
+			req.extra = map[string]interface{}{}
+		}
+		return req.extra, name
+	}
+	for _, name := range t.Remove {
+		m, key := field(name)
+		_, ok := m[key]
+		if _, passed := req.backend[name]; passed {
+			delete(req.backend, name)
+			ok = true
+		}
+		if ok {
+			delete(m, key)
+			changed = append(changed, name)
+		}
+	}
+	for _, name := range sortedKeys(t.Defaults) {
+		if m, key := field(name); isEmptyValue(m[key]) {
+			m[key] = t.Defaults[name]
+			changed = append(changed, name)
+		}
+	}
+	for _, name := range sortedKeys(t.Set) {
+		m, key := field(name)
+		m[key] = t.Set[name]
+		changed = append(changed, name)
+	}
+
+	for k, v := range body {
+		if v == nil {
+			delete(body, k) // a null would decode to a non-nil raw message
+		}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("transform: %w", err)
+	}
+	out := ChatRequest{Metadata: req.Metadata, inbound: req.inbound, extra: req.extra, backend: req.backend}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("transform: %w", err)
+	}
+	if len(out.extra) == 0 {
+		out.extra = nil
+	}
+	*req = out
+	return changed, nil
+}
+
+// bodyFields returns req's body fields other than metadata, by JSON name.
+func bodyFields(req ChatRequest) (map[string]interface{}, error) {
+	req.Metadata = nil
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	delete(body, "metadata")
+	return body, nil
+}
+
+// isEmptyValue reports whether a decoded JSON value counts as left out:
+// missing, null, zero, false or empty.
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// transformHeaders applies a route transform's header rules to outbound
+// headers.
+func transformHeaders(headers map[string]string, t *config.Transform, data headerData) map[string]string {
+	if t == nil || (len(t.Headers) == 0 && len(t.RemoveHeaders) == 0) {
+		return headers
+	}
+	headers = maps.Clone(headers)
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	for _, name := range t.RemoveHeaders {
+		delete(headers, http.CanonicalHeaderKey(name))
+	}
+	for name, value := range t.Headers {
+		rendered, err := renderHeader(value, data)
+		if err != nil {
+			logError(data.RequestID, "route header "+name+" not rendered", err)
+			continue
+		}
+		headers[http.CanonicalHeaderKey(name)] = rendered
+	}
+	return headers
+}
+
+// validateTransform rejects transforms that touch the fields a transform
+// may not, rename tenant or use_case, whose values do not fit the fields
+// they set, or whose header templates do not parse.
+func validateTransform(t *config.Transform) error {
+	if t == nil {
+		return nil
+	}
+	names := append(sortedKeys(t.Set), sortedKeys(t.Defaults)...)
+	for _, name := range append(names, t.Remove...) {
+		if key, ok := strings.CutPrefix(name, "metadata."); ok {
+			if key == "" || key == "tenant" || key == "use_case" {
+				return fmt.Errorf("transform may not change metadata key %q", key)
+			}
+			continue
+		}
+		if name == "" || fixedFields[name] {
+			return fmt.Errorf("transform may not change field %q", name)
+		}
+	}
+	for from, to := range t.RenameMetadata {
+		if from == "" || to == "" {
+			return errors.New("transform rename_metadata needs non-empty keys")
+		}
+		for _, key := range []string{from, to} {
+			if key == "tenant" || key == "use_case" {
+				return fmt.Errorf("transform may not rename metadata key %q", key)
+			}
+		}
+	}
+	if _, err := applyTransform(&ChatRequest{}, t); err != nil {
+		return err
+	}
+	for name, value := range t.Headers {
+		if name == "" {
+			return errors.New("transform headers need names")
+		}
+		if _, err := template.New("header").Funcs(headerFuncs).Parse(value); err != nil {
+			return fmt.Errorf("transform header %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func TestApplyTransform(t *testing.T) {
+	tr := &config.Transform{
+		Set:            map[string]interface{}{"temperature": 0.1, "safe_prompt": true, "metadata.source": "gateway"},
+		Defaults:       map[string]interface{}{"max_tokens": 256, "seed": 7, "metadata.team": "core"},
+		Remove:         []string{"logit_bias", "metadata.debug"},
+		RenameMetadata: map[string]string{"customer": "customer_id"},
+	}
+	req := ChatRequest{
+		Temperature: ptr(0.9),
+		Seed:        func() *int { v := 3; return &v }(),
+		LogitBias:   map[string]float64{"50256": -100},
+		Metadata:    map[string]interface{}{"tenant": "acme", "customer": "c-1", "debug": true},
+	}
+	changed, err := applyTransform(&req, tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *req.Temperature != 0.1 || req.MaxTokens != 256 || *req.Seed != 3 || req.LogitBias != nil || req.ToolChoice != nil {
+		t.Errorf("unexpected body %+v", req)
+	}
+	if req.Metadata["customer_id"] != "c-1" || req.Metadata["customer"] != nil || req.Metadata["debug"] != nil ||
+		req.Metadata["source"] != "gateway" || req.Metadata["team"] != "core" || req.Metadata["tenant"] != "acme" {
+		t.Errorf("unexpected metadata %v", req.Metadata)
+	}
+	want := "metadata.customer,logit_bias,metadata.debug,max_tokens,metadata.team,metadata.source,safe_prompt,temperature"
+	if got := strings.Join(changed, ","); got != want {
+		t.Errorf("expected changes %s, got %s", want, got)
+	}
+
+	// Fields the gateway does not know are sent to the provider as they are.
+	body, err := req.providerRequest("m", nil).MarshalBody(map[string]interface{}{"model": "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent map[string]interface{}
+	json.Unmarshal(body, &sent)
+	if sent["safe_prompt"] != true {
+		t.Errorf("expected safe_prompt sent, got %s", body)
+	}
+
+	if _, err := applyTransform(&ChatRequest{}, &config.Transform{Set: map[string]interface{}{"temperature": "hot"}}); err == nil {
+		t.Error("expected a value that does not fit its field to fail")
+	}
+}
+
+func TestValidateTransform(t *testing.T) {
+	for _, tr := range []*config.Transform{
+		{Set: map[string]interface{}{"stream": true}},
+		{Remove: []string{"messages"}},
+		{Defaults: map[string]interface{}{"metadata.tenant": "acme"}},
+		{RenameMetadata: map[string]string{"team": "use_case"}},
+		{Set: map[string]interface{}{"max_tokens": "many"}},
+		{Headers: map[string]string{"X-Caller": "{{.Tenant"}},
+	} {
+		if err := validateTransform(tr); err == nil {
+			t.Errorf("expected %+v rejected", tr)
+		}
+	}
+	ok := &config.Transform{Set: map[string]interface{}{"top_k": 40}, Headers: map[string]string{"X-Caller": "{{.Tenant}}"}}
+	if err := validateTransform(ok); err != nil {
+		t.Errorf("expected a valid transform, got %v", err)
+	}
+}
+
+func TestTransformHeaders(t *testing.T) {
+	h := &Handler{providerOpts: map[string]config.ProviderOptions{
+		"openai": {Headers: map[string]string{"X-Team": "platform"}, ForwardHeaders: []string{"X-Department"}},
+	}}
+	req := ChatRequest{inbound: http.Header{"X-Department": {"legal"}}}
+	data := headerData{Tenant: "acme", transform: &config.Transform{
+		Headers:       map[string]string{"x-team": "{{.Tenant}}-team", "X-Api-Version": "2"},
+		RemoveHeaders: []string{"x-department"},
+	}}
+
+	got := h.outboundHeaders(context.Background(), req, config.Target{Provider: "openai"}, data)
+	if got["X-Team"] != "acme-team" || got["X-Api-Version"] != "2" || got["X-Department"] != "" {
+		t.Errorf("unexpected headers %v", got)
+	}
+	// Route headers apply to providers without header options too.
+	if got := h.outboundHeaders(context.Background(), req, config.Target{Provider: "anthropic"}, data); got["X-Api-Version"] != "2" {
+		t.Errorf("expected the route header, got %v", got)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/health"
+)
+
+func TestOrderByTTFT(t *testing.T) {
+	ctx := context.Background()
+	h := &Handler{health: health.NewTracker(health.NewMemoryStore(), 0, 0)}
+	h.recordTTFT(ctx, config.Target{Provider: "openai", Model: "gpt-4o"}, 2*time.Second)
+	h.recordTTFT(ctx, config.Target{Provider: "anthropic", Model: "claude-3-5-sonnet"}, 300*time.Millisecond)
+
+	targets := []config.Target{
+		{Provider: "openai", Model: "gpt-4o"},
+		{Provider: "anthropic", Model: "claude-3-5-sonnet"},
+		{Provider: "mistral", Model: "mistral-large"},
+	}
+	got := h.orderByTTFT(ctx, targets, time.Second)
+	want := []string{"anthropic", "mistral", "openai"}
+	for i, w := range want {
+		if got[i].Provider != w {
+			t.Fatalf("expected order %v, got %+v", want, got)
+		}
+	}
+
+	if got := h.orderByTTFT(ctx, targets, 5*time.Second); got[0].Provider != "openai" {
+		t.Errorf("expected configured order when every target meets the SLO, got %+v", got)
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// HandleUsage serves GET /v1/usage: aggregated tokens and cost, filtered by
+// tenant, time range (from/to as RFC 3339 or YYYY-MM-DD) and meta.<key>
+// parameters, and grouped by a request column or metadata key (group_by).
+func (h *Handler) HandleUsage(w http.ResponseWriter, r *http.Request) {
+	q, err := parseUsageQuery(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error(), "")
+		return
+	}
+
+	rows, err := h.usage.QueryUsage(r.Context(), q)
+	if err != nil {
+		logError("", "failed to query usage", err)
+		h.respondError(w, http.StatusInternalServerError, "failed to query usage", "")
+		return
+	}
+	if rows == nil {
+		rows = []usage.UsageRow{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"group_by": q.GroupBy,
+		"rows":     rows,
+	})
+}
+
+func parseUsageQuery(r *http.Request) (usage.UsageQuery, error) {
+	params := r.URL.Query()
+	q := usage.UsageQuery{
+		Tenant:   params.Get("tenant"),
+		GroupBy:  params.Get("group_by"),
+		Metadata: map[string]string{},
+	}
+
+	var err error
+	if q.From, err = parseTime(params.Get("from")); err != nil {
+		return q, fmt.Errorf("invalid from: %w", err)
+	}
+	if q.To, err = parseTime(params.Get("to")); err != nil {
+		return q, fmt.Errorf("invalid to: %w", err)
+	}
+
+	for key, values := range params {
+		if name, ok := strings.CutPrefix(key, "meta."); ok && len(values) > 0 {
+			q.Metadata[name] = values[0]
+		}
+	}
+	return q, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
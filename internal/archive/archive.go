@@ -0,0 +1,231 @@
+// Package archive keeps the usage tables partitioned by month and moves
+// old partitions out of Postgres: each is exported as Parquet to a sink,
+// detached, and dropped after a grace period.
+package archive
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/reports"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+const archiveJob = "archive"
+
+// Store partitions, exports and drops the usage tables' partitions.
+type Store interface {
+	EnsurePartitions(ctx context.Context, table string, now time.Time, ahead int) ([]string, error)
+	Partitions(ctx context.Context, table string) ([]usage.Partition, error)
+	ExportPartition(ctx context.Context, p usage.Partition, columns func([]usage.ArchiveColumn) error, row func([]interface{}) error) error
+	RecordArchive(ctx context.Context, a usage.Archive) error
+	DetachPartition(ctx context.Context, p usage.Partition) error
+	DroppableArchives(ctx context.Context, cutoff time.Time) ([]usage.Archive, error)
+	DropArchived(ctx context.Context, a usage.Archive) error
+	ClaimReport(ctx context.Context, name, period string) (bool, error)
+}
+
+// Job creates partitions ahead and archives old ones.
+type Job struct {
+	store Store
+	sink  reports.Sink
+	cfg   config.Archive
+	now   func() time.Time
+}
+
+// New validates cfg and returns a job for it. Archives are kept past the
+// retention policies, so archiving must start after any policy purging a
+// partitioned table has removed its rows.
+func New(store Store, cfg config.Archive, retention []config.RetentionPolicy) (*Job, error) {
+	if cfg.MonthsAhead < 1 {
+		return nil, fmt.Errorf("PARTITION_MONTHS_AHEAD must be at least 1")
+	}
+	j := &Job{store: store, cfg: cfg, now: time.Now}
+	if cfg.AfterMonths <= 0 {
+		return j, nil
+	}
+	for _, p := range retention {
+		// A partition is archived once it ended AfterMonths ago, when its
+		// newest rows may be only 28 days a month old.
+		if p.Action == "purge" && slices.Contains(usage.PartitionedTables, p.Table) && p.TTLDays > cfg.AfterMonths*28 {
+			return nil, fmt.Errorf("ARCHIVE_AFTER_MONTHS=%d archives %s rows before the retention policy purges them after %d days", cfg.AfterMonths, p.Table, p.TTLDays)
+		}
+	}
+	switch cfg.Sink.Sink {
+	case "file", "s3", "gcs":
+	default:
+		return nil, fmt.Errorf("ARCHIVE_SINK must be file, s3 or gcs to archive, got %q", cfg.Sink.Sink)
+	}
+	if cfg.Sink.Sink != "file" && cfg.Sink.Bucket == "" {
+		return nil, fmt.Errorf("ARCHIVE_BUCKET is required for the %s sink", cfg.Sink.Sink)
+	}
+	sink, err := reports.NewSink(cfg.Sink)
+	if err != nil {
+		return nil, err
+	}
+	j.sink = sink
+	return j, nil
+}
+
+// Run creates the partitions due and, with archive, archives every
+// partition that ended AfterMonths or more months ago and drops those
+// archived DropAfterDays ago. A failing partition does not stop the others.
+func (j *Job) Run(ctx context.Context, archive bool) error {
+	now := j.now().UTC()
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, table := range usage.PartitionedTables {
+		created, err := j.store.EnsurePartitions(ctx, table, now, j.cfg.MonthsAhead)
+		for _, name := range created {
+			log.Printf("Archive: created partition %s", name)
+		}
+		if err != nil {
+			fail(fmt.Errorf("partitioning %s: %w", table, err))
+		}
+	}
+	if !archive || j.sink == nil {
+		return firstErr
+	}
+
+	cutoff := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -j.cfg.AfterMonths, 0)
+	for _, table := range usage.PartitionedTables {
+		parts, err := j.store.Partitions(ctx, table)
+		if err != nil {
+			fail(fmt.Errorf("listing partitions of %s: %w", table, err))
+			continue
+		}
+		for _, p := range parts {
+			if p.To.After(cutoff) {
+				continue
+			}
+			if err := j.archive(ctx, p, now); err != nil {
+				fail(fmt.Errorf("archiving %s: %w", p.Name, err))
+			}
+		}
+	}
+
+	drops, err := j.store.DroppableArchives(ctx, now.AddDate(0, 0, -j.cfg.DropAfterDays))
+	if err != nil {
+		fail(fmt.Errorf("listing archived partitions: %w", err))
+	}
+	for _, a := range drops {
+		if err := j.store.DropArchived(ctx, a); err != nil {
+			fail(fmt.Errorf("dropping %s: %w", a.Partition, err))
+			continue
+		}
+		log.Printf("Archive: dropped partition %s, archived as %s", a.Partition, a.Object)
+	}
+	return firstErr
+}
+
+// archive exports p to the sink, records it and detaches it. The export
+// is written to a temporary file, a row group at a time, and uploaded
+// from there before anything is detached, so a failure leaves the
+// partition in place to be retried.
+func (j *Job) archive(ctx context.Context, p usage.Partition, now time.Time) error {
+	f, err := os.CreateTemp("", "archive-*.parquet")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	var w *ParquetWriter
+	var rows int64
+	err = j.store.ExportPartition(ctx, p,
+		func(cols []usage.ArchiveColumn) error {
+			var err error
+			w, err = NewParquetWriter(f, parquetColumns(cols))
+			return err
+		},
+		func(row []interface{}) error {
+			rows++
+			return w.Write(row)
+		})
+	if err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	object := ObjectName(p)
+	if err := j.sink.PutFile(ctx, object, "application/vnd.apache.parquet", f.Name()); err != nil {
+		return err
+	}
+	if err := j.store.RecordArchive(ctx, usage.Archive{Table: p.Table, Partition: p.Name, Object: object, Rows: rows, ArchivedAt: now}); err != nil {
+		return err
+	}
+	if err := j.store.DetachPartition(ctx, p); err != nil {
+		return err
+	}
+	log.Printf("Archive: exported %d rows of %s to %s and detached it", rows, p.Name, object)
+	return nil
+}
+
+// ObjectName is where a partition's export is stored, e.g.
+// requests/2024-03.parquet, or requests/before-2024-03.parquet for the
+// rows from before partitioning.
+func ObjectName(p usage.Partition) string {
+	if p.From.IsZero() {
+		return fmt.Sprintf("%s/before-%s.parquet", p.Table, p.To.Format("2006-01"))
+	}
+	return fmt.Sprintf("%s/%s.parquet", p.Table, p.From.Format("2006-01"))
+}
+
+func parquetColumns(cols []usage.ArchiveColumn) []Column {
+	out := make([]Column, len(cols))
+	for i, c := range cols {
+		out[i] = Column{Name: c.Name, Type: String}
+		switch c.Type {
+		case "bigint":
+			out[i].Type = Int64
+		case "float8":
+			out[i].Type = Double
+		case "boolean":
+			out[i].Type = Boolean
+		case "timestamptz":
+			out[i].Type = Timestamp
+		}
+	}
+	return out
+}
+
+// Start keeps partitions created ahead on every instance, checking
+// hourly, and archives once an hour across all instances, until ctx is
+// cancelled.
+func (j *Job) Start(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		archive := false
+		if j.sink != nil {
+			period := j.now().UTC().Truncate(time.Hour).Format(time.RFC3339)
+			claimed, err := j.store.ClaimReport(ctx, archiveJob, period)
+			if err != nil {
+				log.Printf("Warning: archive claim failed: %v", err)
+			}
+			archive = claimed
+		}
+		if err := j.Run(ctx, archive); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
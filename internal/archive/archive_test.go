@@ -0,0 +1,132 @@
+package archive
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+type fakeStore struct {
+	ensured  []string
+	parts    map[string][]usage.Partition
+	recorded []usage.Archive
+	detached []string
+	dropped  []string
+	drops    []usage.Archive
+}
+
+func (f *fakeStore) EnsurePartitions(ctx context.Context, table string, now time.Time, ahead int) ([]string, error) {
+	f.ensured = append(f.ensured, table)
+	return nil, nil
+}
+
+func (f *fakeStore) Partitions(ctx context.Context, table string) ([]usage.Partition, error) {
+	return f.parts[table], nil
+}
+
+func (f *fakeStore) ExportPartition(ctx context.Context, p usage.Partition, columns func([]usage.ArchiveColumn) error, row func([]interface{}) error) error {
+	if err := columns([]usage.ArchiveColumn{{Name: "request_id", Type: "text"}, {Name: "total_tokens", Type: "bigint"}}); err != nil {
+		return err
+	}
+	for _, id := range []string{"r1", "r2"} {
+		if err := row([]interface{}{id, int64(10)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) RecordArchive(ctx context.Context, a usage.Archive) error {
+	f.recorded = append(f.recorded, a)
+	return nil
+}
+
+func (f *fakeStore) DetachPartition(ctx context.Context, p usage.Partition) error {
+	f.detached = append(f.detached, p.Name)
+	return nil
+}
+
+func (f *fakeStore) DroppableArchives(ctx context.Context, cutoff time.Time) ([]usage.Archive, error) {
+	return f.drops, nil
+}
+
+func (f *fakeStore) DropArchived(ctx context.Context, a usage.Archive) error {
+	f.dropped = append(f.dropped, a.Partition)
+	return nil
+}
+
+func (f *fakeStore) ClaimReport(ctx context.Context, name, period string) (bool, error) {
+	return true, nil
+}
+
+func month(y int, m time.Month) time.Time { return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC) }
+
+func TestRunArchivesOldPartitions(t *testing.T) {
+	dir := t.TempDir()
+	store := &fakeStore{
+		parts: map[string][]usage.Partition{
+			"requests": {
+				{Table: "requests", Name: "requests_before_y2024m01", To: month(2024, 1)},
+				{Table: "requests", Name: "requests_y2024m01", From: month(2024, 1), To: month(2024, 2)},
+				{Table: "requests", Name: "requests_y2024m04", From: month(2024, 4), To: month(2024, 5)},
+			},
+		},
+		drops: []usage.Archive{{Table: "requests", Partition: "requests_y2023m12"}},
+	}
+	job, err := New(store, config.Archive{MonthsAhead: 2, AfterMonths: 3, DropAfterDays: 30, Sink: config.ReportConfig{Sink: "file", Dir: dir}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.now = func() time.Time { return time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC) }
+
+	if err := job.Run(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(store.ensured, usage.PartitionedTables) {
+		t.Errorf("expected partitions ensured for every table, got %v", store.ensured)
+	}
+	// Partitions ending on or before February 1st are three months old.
+	if want := []string{"requests_before_y2024m01", "requests_y2024m01"}; !slices.Equal(store.detached, want) {
+		t.Errorf("expected %v detached, got %v", want, store.detached)
+	}
+	if len(store.recorded) != 2 || store.recorded[1].Object != "requests/2024-01.parquet" || store.recorded[1].Rows != 2 {
+		t.Errorf("unexpected archive records %+v", store.recorded)
+	}
+	for _, name := range []string{"requests/before-2024-01.parquet", "requests/2024-01.parquet"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s written: %v", name, err)
+		}
+	}
+	if !slices.Equal(store.dropped, []string{"requests_y2023m12"}) {
+		t.Errorf("expected the expired archive dropped, got %v", store.dropped)
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	for _, cfg := range []config.Archive{
+		{MonthsAhead: 0},
+		{MonthsAhead: 1, AfterMonths: 6},
+		{MonthsAhead: 1, AfterMonths: 6, Sink: config.ReportConfig{Sink: "s3"}},
+	} {
+		if _, err := New(&fakeStore{}, cfg, nil); err == nil {
+			t.Errorf("expected %+v to be refused", cfg)
+		}
+	}
+
+	// Archives would keep rows past a purge policy that removes them later.
+	cfg := config.Archive{MonthsAhead: 1, AfterMonths: 3, Sink: config.ReportConfig{Sink: "file"}}
+	if _, err := New(&fakeStore{}, cfg, []config.RetentionPolicy{{Table: "requests", TTLDays: 365, Action: "purge"}}); err == nil {
+		t.Error("expected a purge policy outlasting ARCHIVE_AFTER_MONTHS to be refused")
+	}
+	for _, p := range []config.RetentionPolicy{{Table: "requests", TTLDays: 60, Action: "purge"}, {Table: "requests", TTLDays: 365, Action: "anonymize"}, {Table: "request_events", TTLDays: 365, Action: "purge"}} {
+		if _, err := New(&fakeStore{}, cfg, []config.RetentionPolicy{p}); err != nil {
+			t.Errorf("expected %+v allowed: %v", p, err)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// ColumnType is the type of a Parquet column's values.
+type ColumnType int
+
+const (
+	String    ColumnType = iota // string, stored as UTF-8 BYTE_ARRAY
+	Int64                       // int64
+	Double                      // float64
+	Boolean                     // bool
+	Timestamp                   // time.Time, stored as INT64 microseconds (UTC)
+)
+
+// Column is one column of a Parquet file. Every column is optional, so
+// any value may be nil.
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Parquet physical types, converted types, encodings and page types, as
+// numbered in the format's Thrift definitions.
+const (
+	physBoolean   = 0
+	physInt64     = 2
+	physDouble    = 5
+	physByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	repetitionOptional = 1
+	pageData           = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// defaultRowGroupRows bounds the rows buffered before a row group is
+// written.
+const defaultRowGroupRows = 65536
+
+type columnChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+type rowGroup struct {
+	columns []columnChunk
+	rows    int64
+}
+
+// ParquetWriter writes rows to a Parquet file: uncompressed, PLAIN-encoded,
+// one data page per column per row group. That is enough for the
+// warehouse tools archives are loaded into, with no dependencies.
+type ParquetWriter struct {
+	w       io.Writer
+	offset  int64
+	columns []Column
+	maxRows int
+	pending [][]interface{} // by column
+	groups  []rowGroup
+	closed  bool
+}
+
+// NewParquetWriter starts a Parquet file with columns on w.
+func NewParquetWriter(w io.Writer, columns []Column) (*ParquetWriter, error) {
+	p := &ParquetWriter{w: w, columns: columns, maxRows: defaultRowGroupRows, pending: make([][]interface{}, len(columns))}
+	if err := p.write(parquetMagic); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Write adds a row, with one value per column. A row that does not match
+// the columns is refused whole.
+func (p *ParquetWriter) Write(row []interface{}) error {
+	if len(row) != len(p.columns) {
+		return fmt.Errorf("parquet: row has %d values for %d columns", len(row), len(p.columns))
+	}
+	for i, v := range row {
+		if err := checkValue(p.columns[i], v); err != nil {
+			return err
+		}
+	}
+	for i, v := range row {
+		p.pending[i] = append(p.pending[i], v)
+	}
+	if len(p.pending[0]) >= p.maxRows {
+		return p.flush()
+	}
+	return nil
+}
+
+// Close writes any buffered rows and the file footer. It does not close
+// the underlying writer.
+func (p *ParquetWriter) Close() error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if err := p.flush(); err != nil {
+		return err
+	}
+	footer := p.fileMetaData()
+	if err := p.write(footer); err != nil {
+		return err
+	}
+	if err := p.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))); err != nil {
+		return err
+	}
+	return p.write(parquetMagic)
+}
+
+func checkValue(c Column, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	ok := false
+	switch c.Type {
+	case String:
+		_, ok = v.(string)
+	case Int64:
+		_, ok = v.(int64)
+	case Double:
+		_, ok = v.(float64)
+	case Boolean:
+		_, ok = v.(bool)
+	case Timestamp:
+		_, ok = v.(time.Time)
+	}
+	if !ok {
+		return fmt.Errorf("parquet: column %s: unexpected value of type %T", c.Name, v)
+	}
+	return nil
+}
+
+func (p *ParquetWriter) write(b []byte) error {
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	return err
+}
+
+// flush writes the buffered rows as a row group.
+func (p *ParquetWriter) flush() error {
+	rows := len(p.pending[0])
+	if rows == 0 {
+		return nil
+	}
+	g := rowGroup{rows: int64(rows)}
+	for i, c := range p.columns {
+		page := encodePage(c, p.pending[i])
+		header := pageHeader(len(page), rows)
+		chunk := columnChunk{offset: p.offset, size: int64(len(header) + len(page)), numValues: int64(rows)}
+		if err := p.write(header); err != nil {
+			return err
+		}
+		if err := p.write(page); err != nil {
+			return err
+		}
+		g.columns = append(g.columns, chunk)
+		p.pending[i] = p.pending[i][:0]
+	}
+	p.groups = append(p.groups, g)
+	return nil
+}
+
+// encodePage returns a data page: the definition levels, RLE-encoded with
+// a length prefix, then the non-null values PLAIN-encoded.
+func encodePage(c Column, values []interface{}) []byte {
+	levels := definitionLevels(values)
+	var out bytes.Buffer
+	out.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(levels))))
+	out.Write(levels)
+
+	var bits []bool
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		switch c.Type {
+		case String:
+			s := v.(string)
+			out.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(s))))
+			out.WriteString(s)
+		case Int64:
+			out.Write(binary.LittleEndian.AppendUint64(nil, uint64(v.(int64))))
+		case Double:
+			out.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(v.(float64))))
+		case Timestamp:
+			out.Write(binary.LittleEndian.AppendUint64(nil, uint64(v.(time.Time).UnixMicro())))
+		case Boolean:
+			bits = append(bits, v.(bool))
+		}
+	}
+	if c.Type == Boolean {
+		packed := make([]byte, (len(bits)+7)/8)
+		for i, b := range bits {
+			if b {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		out.Write(packed)
+	}
+	return out.Bytes()
+}
+
+// definitionLevels encodes 1 for each present value and 0 for each nil as
+// RLE runs with a bit width of 1.
+func definitionLevels(values []interface{}) []byte {
+	var out []byte
+	for i := 0; i < len(values); {
+		present := values[i] != nil
+		j := i
+		for j < len(values) && (values[j] != nil) == present {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if present {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+func pageHeader(size, rows int) []byte {
+	var t thriftWriter
+	t.begin()
+	t.i32(1, pageData)
+	t.i32(2, int32(size)) // uncompressed
+	t.i32(3, int32(size)) // compressed
+	t.structField(5, func() {
+		t.i32(1, int32(rows))
+		t.i32(2, encodingPlain)
+		t.i32(3, encodingRLE) // definition levels
+		t.i32(4, encodingRLE) // repetition levels
+	})
+	t.end()
+	return t.buf.Bytes()
+}
+
+func (p *ParquetWriter) fileMetaData() []byte {
+	var rows int64
+	for _, g := range p.groups {
+		rows += g.rows
+	}
+	var t thriftWriter
+	t.begin()
+	t.i32(1, 1) // version
+	t.list(2, thriftStruct, len(p.columns)+1)
+	t.elem(func() {
+		t.str(4, "schema")
+		t.i32(5, int32(len(p.columns)))
+	})
+	for _, c := range p.columns {
+		t.elem(func() {
+			t.i32(1, physicalType(c.Type))
+			t.i32(3, repetitionOptional)
+			t.str(4, c.Name)
+			switch c.Type {
+			case String:
+				t.i32(6, convertedUTF8)
+			case Timestamp:
+				t.i32(6, convertedTimestampMicros)
+			}
+		})
+	}
+	t.i64(3, rows)
+	t.list(4, thriftStruct, len(p.groups))
+	for _, g := range p.groups {
+		t.elem(func() {
+			var total int64
+			t.list(1, thriftStruct, len(g.columns))
+			for i, chunk := range g.columns {
+				total += chunk.size
+				t.elem(func() {
+					t.i64(2, chunk.offset)
+					t.structField(3, func() {
+						t.i32(1, physicalType(p.columns[i].Type))
+						t.list(2, thriftI32, 2)
+						t.rawI32(encodingPlain)
+						t.rawI32(encodingRLE)
+						t.list(3, thriftBinary, 1)
+						t.rawStr(p.columns[i].Name)
+						t.i32(4, 0) // uncompressed
+						t.i64(5, chunk.numValues)
+						t.i64(6, chunk.size)
+						t.i64(7, chunk.size)
+						t.i64(9, chunk.offset)
+					})
+				})
+			}
+			t.i64(2, total)
+			t.i64(3, g.rows)
+		})
+	}
+	t.str(6, "ai-gateway")
+	t.end()
+	return t.buf.Bytes()
+}
+
+func physicalType(t ColumnType) int32 {
+	switch t {
+	case Int64, Timestamp:
+		return physInt64
+	case Double:
+		return physDouble
+	case Boolean:
+		return physBoolean
+	default:
+		return physByteArray
+	}
+}
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes Thrift structs in the compact protocol, which
+// Parquet uses for its metadata.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // last field id of each open struct
+}
+
+func (t *thriftWriter) begin() { t.last = append(t.last, 0) }
+
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	top := &t.last[len(t.last)-1]
+	if delta := id - *top; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(uint64(zigzag(int64(id))))
+	}
+	*top = id
+}
+
+func (t *thriftWriter) varint(v uint64) { t.buf.Write(binary.AppendUvarint(nil, v)) }
+
+func zigzag(v int64) uint64 { return uint64(v<<1) ^ uint64(v>>63) }
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.rawI32(v)
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) str(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.rawStr(s)
+}
+
+func (t *thriftWriter) rawI32(v int32) { t.varint(zigzag(int64(v))) }
+
+func (t *thriftWriter) rawStr(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) structField(id int16, fields func()) {
+	t.field(id, thriftStruct)
+	t.elem(fields)
+}
+
+// list starts a list field of n elements, which follow as raw values or
+// elem calls.
+func (t *thriftWriter) list(id int16, elemType byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xF0 | elemType)
+		t.varint(uint64(n))
+	}
+}
+
+// elem writes a struct inside a list or field.
+func (t *thriftWriter) elem(fields func()) {
+	t.begin()
+	fields()
+	t.end()
+}
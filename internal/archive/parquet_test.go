@@ -0,0 +1,57 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestParquetFile(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewParquetWriter(&buf, []Column{{"id", String}, {"tokens", Int64}, {"cost", Double}, {"truncated", Boolean}, {"created_at", Timestamp}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.maxRows = 2
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, row := range [][]interface{}{
+		{"a", int64(1), 0.5, true, at},
+		{nil, int64(2), nil, false, nil},
+		{"c", nil, 1.25, nil, at},
+	} {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Write([]interface{}{"d", 4, 1.0, true, at}); err == nil {
+		t.Error("expected an int for an Int64 column to be refused")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, parquetMagic) || !bytes.HasSuffix(data, parquetMagic) {
+		t.Fatal("expected PAR1 at both ends")
+	}
+	footer := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if footer <= 0 || footer > len(data)-12 {
+		t.Fatalf("footer length %d out of range", footer)
+	}
+	if len(w.groups) != 2 || w.groups[0].rows != 2 || w.groups[1].rows != 1 {
+		t.Errorf("expected row groups of 2 and 1 rows, got %+v", w.groups)
+	}
+	if first := w.groups[0].columns[0]; first.offset != int64(len(parquetMagic)) {
+		t.Errorf("expected the first column chunk right after the magic, got offset %d", first.offset)
+	}
+}
+
+func TestDefinitionLevels(t *testing.T) {
+	got := definitionLevels([]interface{}{"a", "b", nil, "c"})
+	// Runs of (count<<1, value): 2 present, 1 null, 1 present.
+	want := []byte{4, 1, 2, 0, 2, 1}
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -6,50 +6,89 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"github.com/yewintnaing/ai-gateway/internal/kv"
 )
 
 type Cache struct {
-	client *redis.Client
-	ttl    time.Duration
+	store kv.Store
+	ttl   time.Duration
+
+	mu    sync.Mutex
+	stats map[string]*Counts // by route
+}
+
+// New returns a cache keeping its entries in store.
+func New(store kv.Store, ttl time.Duration) *Cache {
+	return &Cache{store: store, ttl: ttl, stats: make(map[string]*Counts)}
 }
 
+// NewCache returns a cache in Redis, failing if Redis does not answer.
 func NewCache(redisURL string, ttl time.Duration) (*Cache, error) {
-	opts, err := redis.ParseURL(redisURL)
+	store, err := kv.NewRedisStore(redisURL)
 	if err != nil {
 		return nil, err
 	}
-	client := redis.NewClient(opts)
 	// Test connection
-	if err := client.Ping(context.Background()).Err(); err != nil {
+	if err := store.Ping(context.Background()); err != nil {
 		return nil, fmt.Errorf("failed to connect to redis for caching: %w", err)
 	}
-	return &Cache{client: client, ttl: ttl}, nil
+	return New(store, ttl), nil
 }
 
 func (c *Cache) Get(ctx context.Context, key string, target interface{}) (bool, error) {
-	if c.client == nil {
-		return false, nil
-	}
+	found, _, err := c.get(ctx, "cache:"+key, target)
+	return found, err
+}
 
-	val, err := c.client.Get(ctx, "cache:"+key).Result()
-	if err == redis.Nil {
-		return false, nil
-	} else if err != nil {
-		return false, err
-	}
+// Set stores value for the cache's TTL, tagged with the request it answered.
+func (c *Cache) Set(ctx context.Context, key string, tags Tags, value interface{}) error {
+	return c.put(ctx, "cache:"+key, tags, value, c.ttl)
+}
 
-	if err := json.Unmarshal([]byte(val), target); err != nil {
-		return false, err
-	}
+// Tags say which request an entry was stored for, so entries can be purged
+// by tenant, route, model or end user. An entry is shared by every request
+// with the same key; its tags are those of the request that stored it.
+type Tags struct {
+	Tenant string `json:"tenant,omitempty"`
+	Route  string `json:"route,omitempty"`
+	Model  string `json:"model,omitempty"`
+	// User is the SHA-256 of the end user the request was attributed to.
+	User string `json:"user,omitempty"`
+}
+
+// entry wraps a stored value with its tags and the time it was written.
+type entry struct {
+	StoredAt time.Time       `json:"stored_at"`
+	Tags     Tags            `json:"tags"`
+	Value    json.RawMessage `json:"value"`
+}
 
-	return true, nil
+// SetFor stores value with a TTL of ttl, for callers that need to know how
+// old an entry is when they read it back with GetWithAge.
+func (c *Cache) SetFor(ctx context.Context, key string, tags Tags, value interface{}, ttl time.Duration) error {
+	return c.put(ctx, "cache:aged:"+key, tags, value, ttl)
 }
 
-func (c *Cache) Set(ctx context.Context, key string, value interface{}) error {
-	if c.client == nil {
+// GetWithAge reads an entry stored by SetFor and reports how long ago it
+// was written.
+func (c *Cache) GetWithAge(ctx context.Context, key string, target interface{}) (bool, time.Duration, error) {
+	return c.get(ctx, "cache:aged:"+key, target)
+}
+
+// DeleteAged deletes the entry SetFor stored under key.
+func (c *Cache) DeleteAged(ctx context.Context, key string) error {
+	if c.store == nil {
+		return nil
+	}
+	return c.store.Delete(ctx, "cache:aged:"+key)
+}
+
+func (c *Cache) put(ctx context.Context, key string, tags Tags, value interface{}, ttl time.Duration) error {
+	if c.store == nil {
 		return nil
 	}
 
@@ -57,18 +96,169 @@ func (c *Cache) Set(ctx context.Context, key string, value interface{}) error {
 	if err != nil {
 		return err
 	}
+	wrapped, err := json.Marshal(entry{StoredAt: time.Now(), Tags: tags, Value: data})
+	if err != nil {
+		return err
+	}
 
-	return c.client.Set(ctx, "cache:"+key, string(data), c.ttl).Err()
+	return c.store.Set(ctx, key, wrapped, ttl)
 }
 
-func GenerateKey(model string, messages interface{}) (string, error) {
-	data, err := json.Marshal(messages)
-	if err != nil {
-		return "", err
+func (c *Cache) get(ctx context.Context, key string, target interface{}) (bool, time.Duration, error) {
+	if c.store == nil {
+		return false, 0, nil
+	}
+
+	val, found, err := c.store.Get(ctx, key)
+	if err != nil || !found {
+		return false, 0, err
+	}
+
+	var e entry
+	if err := json.Unmarshal(val, &e); err != nil {
+		return false, 0, err
+	}
+	if err := json.Unmarshal(e.Value, target); err != nil {
+		return false, 0, err
+	}
+
+	return true, time.Since(e.StoredAt), nil
+}
+
+// Filter selects cache entries to purge. Set fields must all match; an
+// entry's key is the prompt hash GenerateKey returns.
+type Filter struct {
+	Tenant    string
+	Route     string
+	Model     string
+	User      string
+	KeyPrefix string
+}
+
+func (f Filter) matches(key string, tags Tags) bool {
+	return strings.HasPrefix(key, f.KeyPrefix) &&
+		(f.Tenant == "" || f.Tenant == tags.Tenant) &&
+		(f.Route == "" || f.Route == tags.Route) &&
+		(f.Model == "" || f.Model == tags.Model) &&
+		(f.User == "" || f.User == tags.User)
+}
+
+// Purge deletes the entries f selects and returns how many it deleted. It
+// reads every entry, so it is meant for occasional use after a prompt or
+// template change.
+func (c *Cache) Purge(ctx context.Context, f Filter) (int, error) {
+	if c.store == nil {
+		return 0, nil
 	}
+	purged := 0
+	err := c.store.Scan(ctx, "cache:", func(storeKey string, value []byte) error {
+		key := strings.TrimPrefix(storeKey, "cache:")
+		if strings.HasPrefix(key, "lock:") {
+			return nil
+		}
+		key = strings.TrimPrefix(key, "aged:")
+		var e entry
+		if json.Unmarshal(value, &e) != nil || !f.matches(key, e.Tags) {
+			return nil
+		}
+		if err := c.store.Delete(ctx, storeKey); err != nil {
+			return err
+		}
+		purged++
+		return nil
+	})
+	return purged, err
+}
+
+// TryLock takes a short-lived lock on key across all gateway instances
+// sharing the store. It reports false if another holder has it or the store is unavailable.
+func (c *Cache) TryLock(ctx context.Context, key string, ttl time.Duration) bool {
+	if c.store == nil {
+		return false
+	}
+	ok, err := c.store.SetNX(ctx, "cache:lock:"+key, []byte("1"), ttl)
+	return err == nil && ok
+}
+
+// Counts are the lookups this instance made since it started.
+type Counts struct {
+	Hits   int64 `json:"hits"`
+	Stale  int64 `json:"stale"` // served stale while revalidating
+	Misses int64 `json:"misses"`
+}
+
+// HitRate is the fraction of lookups served from cache, stale or not.
+func (c Counts) HitRate() float64 {
+	total := c.Hits + c.Stale + c.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(c.Hits+c.Stale) / float64(total)
+}
 
+// Observe counts a lookup for route with its outcome.
+func (c *Cache) Observe(route string, f Freshness, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts, ok := c.stats[route]
+	if !ok {
+		counts = &Counts{}
+		c.stats[route] = counts
+	}
+	switch {
+	case !found || f == Expired:
+		counts.Misses++
+	case f == Stale:
+		counts.Stale++
+	default:
+		counts.Hits++
+	}
+}
+
+// Stats returns the lookup counts by route.
+func (c *Cache) Stats() map[string]Counts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]Counts, len(c.stats))
+	for route, counts := range c.stats {
+		out[route] = *counts
+	}
+	return out
+}
+
+// Freshness is how usable a cached entry of a given age is.
+type Freshness int
+
+const (
+	Fresh   Freshness = iota // serve as is
+	Stale                    // serve, but refresh in the background
+	Expired                  // do not serve
+)
+
+// Classify reports the freshness of an entry of age under a policy that
+// serves entries as-is for fresh and as stale for a further stale.
+func Classify(age, fresh, stale time.Duration) Freshness {
+	switch {
+	case age < fresh:
+		return Fresh
+	case age < fresh+stale:
+		return Stale
+	default:
+		return Expired
+	}
+}
+
+// GenerateKey hashes the model and the JSON of each part, in order, into a
+// cache key.
+func GenerateKey(model string, parts ...interface{}) (string, error) {
 	h := sha256.New()
 	h.Write([]byte(model))
-	h.Write(data)
+	for _, part := range parts {
+		data, err := json.Marshal(part)
+		if err != nil {
+			return "", err
+		}
+		h.Write(data)
+	}
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
@@ -1,7 +1,11 @@
 package cache
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/kv"
 )
 
 func TestGenerateKey(t *testing.T) {
@@ -36,3 +40,100 @@ func TestGenerateKey(t *testing.T) {
 		t.Errorf("Expected different keys for different messages, got same key %s", key1)
 	}
 }
+
+func TestClassify(t *testing.T) {
+	fresh, stale := time.Minute, time.Hour
+	tests := []struct {
+		age  time.Duration
+		want Freshness
+	}{
+		{0, Fresh},
+		{59 * time.Second, Fresh},
+		{time.Minute, Stale},
+		{30 * time.Minute, Stale},
+		{61 * time.Minute, Expired},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.age, fresh, stale); got != tt.want {
+			t.Errorf("age %s: expected %d, got %d", tt.age, tt.want, got)
+		}
+	}
+}
+
+func TestCacheInMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	c := New(kv.NewMemoryStore(10), time.Hour)
+
+	if err := c.Set(ctx, "k", Tags{}, map[string]string{"answer": "42"}); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if found, err := c.Get(ctx, "k", &got); err != nil || !found || got["answer"] != "42" {
+		t.Errorf("Get = %v, %v, %v", got, found, err)
+	}
+
+	if !c.TryLock(ctx, "k", time.Minute) {
+		t.Error("first TryLock failed")
+	}
+	if c.TryLock(ctx, "k", time.Minute) {
+		t.Error("second TryLock succeeded while the lock is held")
+	}
+
+	c.SetFor(ctx, "k", Tags{}, "aged", time.Hour)
+	if err := c.DeleteAged(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if found, _, _ := c.GetWithAge(ctx, "k", new(string)); found {
+		t.Error("DeleteAged left the entry")
+	}
+	if found, _ := c.Get(ctx, "k", &got); !found {
+		t.Error("DeleteAged removed the Set entry under the same key")
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	c := New(kv.NewMemoryStore(0), time.Hour)
+	c.Set(ctx, "aaa1", Tags{Tenant: "acme", Route: "support", Model: "gpt-4o"}, "1")
+	c.Set(ctx, "aaa2", Tags{Tenant: "globex", Route: "support", Model: "gpt-4o"}, "2")
+	c.Set(ctx, "ccc1", Tags{Tenant: "globex", Route: "support", Model: "gpt-4o", User: "u1"}, "4")
+	c.SetFor(ctx, "bbb1", Tags{Tenant: "acme", Route: "faq", Model: "claude"}, "3", time.Hour)
+	c.TryLock(ctx, "aaa1", time.Minute)
+
+	tests := []struct {
+		filter Filter
+		want   int
+	}{
+		{Filter{Tenant: "initech"}, 0},
+		{Filter{Tenant: "globex", User: "u2"}, 0},
+		{Filter{Tenant: "globex", User: "u1"}, 1},
+		{Filter{Tenant: "globex", User: "u1"}, 0},
+		{Filter{Tenant: "acme", Route: "support"}, 1},
+		{Filter{KeyPrefix: "aaa"}, 1},
+		{Filter{Model: "claude"}, 1},
+	}
+	for _, tt := range tests {
+		if got, err := c.Purge(ctx, tt.filter); err != nil || got != tt.want {
+			t.Errorf("Purge(%+v) = %d, %v; want %d", tt.filter, got, err, tt.want)
+		}
+	}
+	if c.TryLock(ctx, "aaa1", time.Minute) {
+		t.Error("Purge removed a lock")
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := New(kv.NewMemoryStore(0), time.Hour)
+	c.Observe("support", Fresh, true)
+	c.Observe("support", Stale, true)
+	c.Observe("support", Expired, true)
+	c.Observe("support", Fresh, false)
+
+	got := c.Stats()["support"]
+	if got != (Counts{Hits: 1, Stale: 1, Misses: 2}) {
+		t.Errorf("expected 1 hit, 1 stale and 2 misses, got %+v", got)
+	}
+	if got.HitRate() != 0.5 {
+		t.Errorf("expected hit rate 0.5, got %f", got.HitRate())
+	}
+}
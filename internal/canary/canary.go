@@ -0,0 +1,250 @@
+// Package canary splits a route's traffic between its current definition and
+// a candidate one, and rolls the candidate back when it does worse.
+package canary
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+// Rollback reports a canary stopped for regressing.
+type Rollback struct {
+	Route   string
+	Version string
+	Reason  string
+}
+
+// Stats summarize one side of a canary in the current window.
+type Stats struct {
+	Requests      int     `json:"requests"`
+	Errors        int     `json:"errors"`
+	ErrorRate     float64 `json:"error_rate"`
+	MeanLatencyMS float64 `json:"mean_latency_ms"`
+}
+
+// Status is the state of a route's canary.
+type Status struct {
+	Version     string    `json:"version"`
+	RolledBack  bool      `json:"rolled_back"`
+	Reason      string    `json:"reason,omitempty"`
+	WindowStart time.Time `json:"window_start"`
+	Stable      Stats     `json:"stable"`
+	Canary      Stats     `json:"canary"`
+}
+
+type arm struct {
+	requests int
+	errors   int
+	latency  time.Duration // summed over successful attempts
+}
+
+func (a arm) errorRate() float64 {
+	if a.requests == 0 {
+		return 0
+	}
+	return float64(a.errors) / float64(a.requests)
+}
+
+func (a arm) meanLatency() time.Duration {
+	if ok := a.requests - a.errors; ok > 0 {
+		return a.latency / time.Duration(ok)
+	}
+	return 0
+}
+
+func (a arm) stats() Stats {
+	return Stats{
+		Requests:      a.requests,
+		Errors:        a.errors,
+		ErrorRate:     a.errorRate(),
+		MeanLatencyMS: float64(a.meanLatency()) / float64(time.Millisecond),
+	}
+}
+
+type state struct {
+	version     string
+	windowStart time.Time
+	stable      arm
+	canary      arm
+	rolledBack  bool
+	reason      string
+}
+
+// Controller tracks the canaries of all routes. Each instance judges its own
+// traffic; a rollback is reported through the callback so it can be
+// persisted for the other instances.
+type Controller struct {
+	mu         sync.Mutex
+	states     map[string]*state
+	onRollback func(Rollback)
+	now        func() time.Time
+	roll       func() float64
+}
+
+func New(onRollback func(Rollback)) *Controller {
+	return &Controller{
+		states:     make(map[string]*state),
+		onRollback: onRollback,
+		now:        time.Now,
+		roll:       rand.Float64,
+	}
+}
+
+type ctxKey struct{}
+
+type assignment struct {
+	route  string
+	cfg    config.Canary
+	canary bool
+}
+
+// Select picks the definition of route that serves this request and records
+// the choice on the returned context for Observe.
+func (c *Controller) Select(ctx context.Context, route config.Route) (context.Context, config.Route) {
+	if c == nil || route.Canary == nil || route.Canary.Route == nil || route.Canary.Percent <= 0 {
+		return ctx, route
+	}
+	cfg := *route.Canary
+
+	c.mu.Lock()
+	st := c.state(route.Name, cfg.Version)
+	rolledBack := st.rolledBack
+	c.mu.Unlock()
+	if rolledBack {
+		return ctx, route
+	}
+
+	useCanary := c.roll()*100 < cfg.Percent
+	ctx = context.WithValue(ctx, ctxKey{}, assignment{route: route.Name, cfg: cfg, canary: useCanary})
+	if !useCanary {
+		return ctx, route
+	}
+	candidate := *cfg.Route
+	candidate.Name, candidate.Match, candidate.Canary = route.Name, route.Match, nil
+	return ctx, candidate
+}
+
+// Version returns the canary version serving the request, if any.
+func Version(ctx context.Context) (string, bool) {
+	a, ok := ctx.Value(ctxKey{}).(assignment)
+	if !ok || !a.canary {
+		return "", false
+	}
+	return a.cfg.Version, true
+}
+
+// Observe records the outcome of a provider attempt made for a request that
+// went through Select.
+func (c *Controller) Observe(ctx context.Context, latency time.Duration, failed bool) {
+	a, ok := ctx.Value(ctxKey{}).(assignment)
+	if c == nil || !ok {
+		return
+	}
+
+	c.mu.Lock()
+	st := c.state(a.route, a.cfg.Version)
+	if st.rolledBack {
+		c.mu.Unlock()
+		return
+	}
+	side := &st.stable
+	if a.canary {
+		side = &st.canary
+	}
+	side.requests++
+	if failed {
+		side.errors++
+	} else {
+		side.latency += latency
+	}
+
+	var rollback *Rollback
+	if c.now().Sub(st.windowStart) >= window(a.cfg) {
+		min := minRequests(a.cfg)
+		if st.canary.requests >= min && st.stable.requests >= min {
+			if reason := regression(st, a.cfg); reason != "" {
+				st.rolledBack, st.reason = true, reason
+				rollback = &Rollback{Route: a.route, Version: a.cfg.Version, Reason: reason}
+			} else {
+				st.windowStart = c.now()
+				st.stable, st.canary = arm{}, arm{}
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	if rollback != nil && c.onRollback != nil {
+		c.onRollback(*rollback)
+	}
+}
+
+// Status returns the state of the route's canary, if one has seen traffic.
+func (c *Controller) Status(route string) (Status, bool) {
+	if c == nil {
+		return Status{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.states[route]
+	if !ok {
+		return Status{}, false
+	}
+	return Status{
+		Version:     st.version,
+		RolledBack:  st.rolledBack,
+		Reason:      st.reason,
+		WindowStart: st.windowStart,
+		Stable:      st.stable.stats(),
+		Canary:      st.canary.stats(),
+	}, true
+}
+
+// state returns the route's state, starting over when the version changed.
+// Callers hold c.mu.
+func (c *Controller) state(route, version string) *state {
+	st, ok := c.states[route]
+	if !ok || st.version != version {
+		st = &state{version: version, windowStart: c.now()}
+		c.states[route] = st
+	}
+	return st
+}
+
+func regression(st *state, cfg config.Canary) string {
+	maxIncrease := cfg.MaxErrorRateIncrease
+	if maxIncrease <= 0 {
+		maxIncrease = 0.05
+	}
+	if diff := st.canary.errorRate() - st.stable.errorRate(); diff > maxIncrease {
+		return fmt.Sprintf("error rate %.1f%% vs %.1f%%", st.canary.errorRate()*100, st.stable.errorRate()*100)
+	}
+
+	maxRatio := cfg.MaxLatencyRatio
+	if maxRatio <= 0 {
+		maxRatio = 1.5
+	}
+	stable, candidate := st.stable.meanLatency(), st.canary.meanLatency()
+	if stable > 0 && float64(candidate) > float64(stable)*maxRatio {
+		return fmt.Sprintf("mean latency %s vs %s", candidate.Round(time.Millisecond), stable.Round(time.Millisecond))
+	}
+	return ""
+}
+
+func window(cfg config.Canary) time.Duration {
+	if cfg.WindowSec > 0 {
+		return time.Duration(cfg.WindowSec) * time.Second
+	}
+	return 5 * time.Minute
+}
+
+func minRequests(cfg config.Canary) int {
+	if cfg.MinRequests > 0 {
+		return cfg.MinRequests
+	}
+	return 20
+}
@@ -0,0 +1,129 @@
+package canary
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func canaryRoute(percent float64) config.Route {
+	return config.Route{
+		Name:    "support",
+		Match:   config.Match{UseCase: "support_summary"},
+		Primary: config.Target{Provider: "openai", Model: "gpt-4o-mini"},
+		Canary: &config.Canary{
+			Version:     "v2",
+			Percent:     percent,
+			Route:       &config.Route{Primary: config.Target{Provider: "anthropic", Model: "claude-3-5-sonnet"}},
+			WindowSec:   60,
+			MinRequests: 5,
+		},
+	}
+}
+
+func newTestController(now *time.Time, roll float64, rollbacks *[]Rollback) *Controller {
+	c := New(func(rb Rollback) { *rollbacks = append(*rollbacks, rb) })
+	c.now = func() time.Time { return *now }
+	c.roll = func() float64 { return roll }
+	return c
+}
+
+func TestSelect(t *testing.T) {
+	now := time.Now()
+	var rollbacks []Rollback
+
+	ctx, route := newTestController(&now, 0.05, &rollbacks).Select(context.Background(), canaryRoute(10))
+	if route.Primary.Provider != "anthropic" || route.Name != "support" || route.Match.UseCase != "support_summary" || route.Canary != nil {
+		t.Errorf("expected candidate under the route's name, got %+v", route)
+	}
+	if v, ok := Version(ctx); !ok || v != "v2" {
+		t.Errorf("expected canary version v2 on context, got %q %v", v, ok)
+	}
+
+	ctx, route = newTestController(&now, 0.5, &rollbacks).Select(context.Background(), canaryRoute(10))
+	if route.Primary.Provider != "openai" {
+		t.Errorf("expected current definition, got %s", route.Primary.Provider)
+	}
+	if _, ok := Version(ctx); ok {
+		t.Error("expected no canary version for stable traffic")
+	}
+}
+
+// drive sends n attempts through each side of the canary.
+func drive(c *Controller, n int, stableLatency, canaryLatency time.Duration, canaryFails bool) {
+	route := canaryRoute(50)
+	for i := 0; i < n; i++ {
+		c.roll = func() float64 { return 0.9 }
+		ctx, _ := c.Select(context.Background(), route)
+		c.Observe(ctx, stableLatency, false)
+
+		c.roll = func() float64 { return 0.1 }
+		ctx, _ = c.Select(context.Background(), route)
+		c.Observe(ctx, canaryLatency, canaryFails)
+	}
+}
+
+func TestRollbackOnErrorRate(t *testing.T) {
+	now := time.Now()
+	var rollbacks []Rollback
+	c := newTestController(&now, 0, &rollbacks)
+
+	drive(c, 10, 100*time.Millisecond, 100*time.Millisecond, true)
+	if len(rollbacks) != 0 {
+		t.Fatal("expected no rollback before the window ends")
+	}
+
+	now = now.Add(time.Minute)
+	drive(c, 1, 100*time.Millisecond, 100*time.Millisecond, true)
+	if len(rollbacks) != 1 || rollbacks[0].Route != "support" || rollbacks[0].Version != "v2" {
+		t.Fatalf("expected one rollback of support v2, got %+v", rollbacks)
+	}
+
+	c.roll = func() float64 { return 0 }
+	if _, route := c.Select(context.Background(), canaryRoute(50)); route.Primary.Provider != "openai" {
+		t.Error("expected rolled back canary to get no traffic")
+	}
+	status, _ := c.Status("support")
+	if !status.RolledBack || status.Reason == "" {
+		t.Errorf("expected rolled back status with reason, got %+v", status)
+	}
+}
+
+func TestRollbackOnLatency(t *testing.T) {
+	now := time.Now()
+	var rollbacks []Rollback
+	c := newTestController(&now, 0, &rollbacks)
+
+	drive(c, 10, 100*time.Millisecond, 400*time.Millisecond, false)
+	now = now.Add(time.Minute)
+	drive(c, 1, 100*time.Millisecond, 400*time.Millisecond, false)
+	if len(rollbacks) != 1 {
+		t.Fatalf("expected latency rollback, got %+v", rollbacks)
+	}
+}
+
+func TestHealthyCanaryStartsNewWindow(t *testing.T) {
+	now := time.Now()
+	var rollbacks []Rollback
+	c := newTestController(&now, 0, &rollbacks)
+
+	drive(c, 10, 100*time.Millisecond, 110*time.Millisecond, false)
+	now = now.Add(time.Minute)
+	drive(c, 1, 100*time.Millisecond, 110*time.Millisecond, false)
+	if len(rollbacks) != 0 {
+		t.Fatalf("expected no rollback, got %+v", rollbacks)
+	}
+	status, _ := c.Status("support")
+	if status.Stable.Requests != 0 || status.Canary.Requests != 1 || !status.WindowStart.Equal(now) {
+		t.Errorf("expected a fresh window, got %+v", status)
+	}
+
+	route := canaryRoute(50)
+	route.Canary.Version = "v3"
+	c.Select(context.Background(), route)
+	if status, _ := c.Status("support"); status.Version != "v3" {
+		t.Errorf("expected new version to reset state, got %s", status.Version)
+	}
+}
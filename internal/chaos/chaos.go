@@ -0,0 +1,98 @@
+package chaos
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// Provider wraps a real provider and injects latency, errors and mid-stream
+// aborts according to a route's chaos settings.
+type Provider struct {
+	name  string
+	inner providers.Provider
+	cfg   config.Chaos
+	roll  func() float64
+}
+
+func Wrap(name string, inner providers.Provider, cfg config.Chaos) *Provider {
+	if cfg.ErrorStatus == 0 {
+		cfg.ErrorStatus = 503
+	}
+	if cfg.AbortAfterChunks <= 0 {
+		cfg.AbortAfterChunks = 3
+	}
+	return &Provider{name: name, inner: inner, cfg: cfg, roll: rand.Float64}
+}
+
+func (p *Provider) delay() {
+	if p.cfg.LatencyMS > 0 && p.roll() < p.cfg.LatencyRate {
+		time.Sleep(time.Duration(p.cfg.LatencyMS) * time.Millisecond)
+	}
+}
+
+func (p *Provider) injectedError() error {
+	if p.roll() < p.cfg.ErrorRate {
+		return &providers.StatusError{Provider: p.name, Code: p.cfg.ErrorStatus, Message: "chaos: injected error"}
+	}
+	return nil
+}
+
+func (p *Provider) Chat(req providers.ChatRequest) (*providers.ChatResponse, error) {
+	p.delay()
+	if err := p.injectedError(); err != nil {
+		return nil, err
+	}
+	return p.inner.Chat(req)
+}
+
+func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatChunk, <-chan error) {
+	p.delay()
+
+	chunkCh := make(chan providers.ChatChunk)
+	errCh := make(chan error, 1)
+	if err := p.injectedError(); err != nil {
+		errCh <- err
+		return chunkCh, errCh
+	}
+
+	abort := p.roll() < p.cfg.AbortRate
+	innerChunks, innerErrs := p.inner.ChatStream(req)
+	if !abort {
+		return innerChunks, innerErrs
+	}
+
+	go func() {
+		defer close(chunkCh)
+		defer close(errCh)
+		sent := 0
+		for {
+			select {
+			case chunk, ok := <-innerChunks:
+				if !ok {
+					return
+				}
+				if sent == p.cfg.AbortAfterChunks {
+					errCh <- &providers.StatusError{Provider: p.name, Code: 502, Message: "chaos: stream aborted"}
+					// Drain so the upstream goroutine is not left blocked.
+					go func() {
+						for range innerChunks {
+						}
+					}()
+					return
+				}
+				chunkCh <- chunk
+				sent++
+			case err, ok := <-innerErrs:
+				if ok && err != nil {
+					errCh <- err
+					return
+				}
+				innerErrs = nil
+			}
+		}
+	}()
+	return chunkCh, errCh
+}
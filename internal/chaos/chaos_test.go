@@ -0,0 +1,46 @@
+package chaos
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/providers/synthetic"
+)
+
+func TestChaos(t *testing.T) {
+	inner := synthetic.NewProvider(synthetic.Options{Tokens: 10})
+	req := providers.ChatRequest{Model: "synthetic-1", Messages: []providers.Message{{Role: "user", Content: "hi"}}}
+
+	t.Run("Injects configured error status", func(t *testing.T) {
+		p := Wrap("synthetic", inner, config.Chaos{ErrorRate: 1, ErrorStatus: 429})
+		_, err := p.Chat(req)
+		var statusErr *providers.StatusError
+		if !errors.As(err, &statusErr) || statusErr.Code != 429 {
+			t.Fatalf("expected injected 429, got %v", err)
+		}
+	})
+
+	t.Run("Aborts stream after N chunks", func(t *testing.T) {
+		p := Wrap("synthetic", inner, config.Chaos{AbortRate: 1, AbortAfterChunks: 2})
+		chunks, errs := p.ChatStream(req)
+		got := 0
+		for range chunks {
+			got++
+		}
+		if got != 2 {
+			t.Errorf("expected 2 chunks before abort, got %d", got)
+		}
+		if err := <-errs; err == nil {
+			t.Error("expected abort error")
+		}
+	})
+
+	t.Run("Zero rates pass through", func(t *testing.T) {
+		p := Wrap("synthetic", inner, config.Chaos{})
+		if _, err := p.Chat(req); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
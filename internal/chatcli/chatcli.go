@@ -0,0 +1,321 @@
+// Package chatcli is the "gateway chat" subcommand, for smoke testing: it
+// sends one prompt through a running gateway, or with -offline through the
+// routes and providers this process is configured with, and prints the
+// reply as it arrives.
+package chatcli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	_ "github.com/yewintnaing/ai-gateway/internal/providers/builtin"
+	"github.com/yewintnaing/ai-gateway/internal/router"
+)
+
+type Options struct {
+	URL       string
+	APIKey    string
+	UseCase   string
+	Tenant    string
+	Model     string
+	System    string
+	Prompt    string
+	MaxTokens int
+	Stream    bool
+	// JSON prints the response, or each stream chunk, as JSON.
+	JSON bool
+
+	// Offline calls the providers from this process instead of a gateway.
+	// Route picks a route by name instead of by use case, and Target
+	// ("provider/model") replaces the route's targets; both need Offline.
+	Offline bool
+	Route   string
+	Target  string
+}
+
+// Summary describes how a prompt was served.
+type Summary struct {
+	Route    string
+	Provider string
+	Model    string
+	Usage    providers.Usage
+	Latency  time.Duration
+}
+
+func (s Summary) String() string {
+	line := fmt.Sprintf("route=%s provider=%s model=%s latency=%s", s.Route, s.Provider, s.Model, s.Latency.Round(time.Millisecond))
+	if s.Usage.TotalTokens > 0 {
+		line += fmt.Sprintf(" tokens=%d+%d", s.Usage.PromptTokens, s.Usage.CompletionTokens)
+	}
+	return line
+}
+
+// Main parses command-line flags and sends the prompt, printing the reply
+// to stdout and a summary to stderr. The prompt is the remaining arguments,
+// or stdin when there are none.
+func Main(args []string) error {
+	fs := flag.NewFlagSet("chat", flag.ContinueOnError)
+	opts := Options{}
+	fs.StringVar(&opts.URL, "url", "http://localhost:8080", "gateway base URL")
+	fs.StringVar(&opts.APIKey, "api-key", os.Getenv("GATEWAY_API_KEY"), "bearer token for the gateway (default $GATEWAY_API_KEY)")
+	fs.StringVar(&opts.UseCase, "use-case", "default", "metadata.use_case to route on")
+	fs.StringVar(&opts.Tenant, "tenant", "cli", "metadata.tenant to attribute usage to")
+	fs.StringVar(&opts.Model, "model", "", "model hint sent with the request")
+	fs.StringVar(&opts.System, "system", "", "system message to send before the prompt")
+	fs.IntVar(&opts.MaxTokens, "max-tokens", 0, "completion length limit")
+	fs.BoolVar(&opts.Stream, "stream", false, "stream the reply")
+	fs.BoolVar(&opts.JSON, "json", false, "print the response (or each chunk) as JSON")
+	fs.BoolVar(&opts.Offline, "offline", false, "call providers from this process, using its config, instead of a gateway")
+	fs.StringVar(&opts.Route, "route", "", "route name to use instead of matching the use case (with -offline)")
+	fs.StringVar(&opts.Target, "target", "", "provider/model to call instead of the route's targets (with -offline)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts.Prompt = strings.Join(fs.Args(), " ")
+	if opts.Prompt == "" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		opts.Prompt = strings.TrimSpace(string(data))
+	}
+	if opts.Prompt == "" {
+		return fmt.Errorf("no prompt given")
+	}
+
+	summary, err := Run(context.Background(), opts, os.Stdout)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, summary)
+	return nil
+}
+
+// Run sends the prompt and writes the reply to w.
+func Run(ctx context.Context, opts Options, w io.Writer) (Summary, error) {
+	if !opts.Offline {
+		if opts.Route != "" || opts.Target != "" {
+			return Summary{}, fmt.Errorf("-route and -target need -offline")
+		}
+		return online(ctx, opts, w)
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return Summary{}, err
+	}
+	registry, err := providers.Build(cfg.Providers)
+	if err != nil {
+		return Summary{}, err
+	}
+	return offline(opts, router.NewRouter(cfg.Routes), registry, w)
+}
+
+func (o Options) messages() []providers.Message {
+	var msgs []providers.Message
+	if o.System != "" {
+		msgs = append(msgs, providers.Message{Role: "system", Content: o.System})
+	}
+	return append(msgs, providers.Message{Role: "user", Content: o.Prompt})
+}
+
+func online(ctx context.Context, opts Options, w io.Writer) (Summary, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":      opts.Model,
+		"messages":   opts.messages(),
+		"max_tokens": opts.MaxTokens,
+		"stream":     opts.Stream,
+		"metadata":   map[string]string{"tenant": opts.Tenant, "use_case": opts.UseCase},
+	})
+	if err != nil {
+		return Summary{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(opts.URL, "/")+"/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return Summary{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.APIKey)
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer resp.Body.Close()
+
+	summary := Summary{
+		Route:    resp.Header.Get("x-gw-route"),
+		Provider: resp.Header.Get("x-gw-provider"),
+		Model:    resp.Header.Get("x-gw-model"),
+	}
+	if resp.StatusCode != http.StatusOK {
+		var envelope struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(data, &envelope) == nil && envelope.Error.Message != "" {
+			return summary, fmt.Errorf("gateway answered %d: %s", resp.StatusCode, envelope.Error.Message)
+		}
+		return summary, fmt.Errorf("gateway answered %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+
+	if !opts.Stream {
+		var chat providers.ChatResponse
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return summary, err
+		}
+		if err := json.Unmarshal(data, &chat); err != nil {
+			return summary, err
+		}
+		summary.Usage, summary.Latency = chat.Usage, time.Since(start)
+		return summary, printResponse(w, &chat, data, opts.JSON)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok && data != "[DONE]" {
+			var chunk providers.ChatChunk
+			if json.Unmarshal([]byte(data), &chunk) == nil {
+				printChunk(w, chunk, []byte(data), opts.JSON)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return summary, err
+		}
+	}
+	if !opts.JSON {
+		fmt.Fprintln(w)
+	}
+	summary.Latency = time.Since(start)
+	return summary, nil
+}
+
+// offline serves the prompt as the gateway would route it: each target in
+// turn, retrying those that fail with a retryable error.
+func offline(opts Options, rt *router.Router, registry providers.Registry, w io.Writer) (Summary, error) {
+	route := rt.Route(opts.UseCase)
+	if opts.Route != "" {
+		var ok bool
+		if route, ok = rt.Lookup(opts.Route); !ok {
+			return Summary{}, fmt.Errorf("no route named %q", opts.Route)
+		}
+	}
+	targets := append([]config.Target{route.Primary}, route.Fallbacks...)
+	if opts.Target != "" {
+		provider, model, ok := strings.Cut(opts.Target, "/")
+		if !ok {
+			return Summary{}, fmt.Errorf("-target must be provider/model")
+		}
+		targets = []config.Target{{Provider: provider, Model: model}}
+	}
+
+	var lastErr error
+	for _, target := range targets {
+		p, err := registry.Get(target.Provider)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		req := providers.ChatRequest{Model: target.Model, Messages: opts.messages(), MaxTokens: opts.MaxTokens, Stream: opts.Stream}
+		if route.TimeoutMS > 0 {
+			req.Timeout = time.Duration(route.TimeoutMS) * time.Millisecond
+		}
+		for i := 0; i <= route.Retries; i++ {
+			summary := Summary{Route: route.Name, Provider: target.Provider, Model: target.Model}
+			start := time.Now()
+			if opts.Stream {
+				err = stream(p, req, w, opts.JSON)
+			} else {
+				var resp *providers.ChatResponse
+				if resp, err = p.Chat(req); err == nil {
+					summary.Usage = resp.Usage
+					data, _ := json.Marshal(resp)
+					err = printResponse(w, resp, data, opts.JSON)
+				}
+			}
+			summary.Latency = time.Since(start)
+			if err == nil {
+				return summary, nil
+			}
+			fmt.Fprintf(os.Stderr, "%s/%s failed: %v\n", target.Provider, target.Model, err)
+			lastErr = err
+			if !router.IsRetryable(err) {
+				break
+			}
+		}
+	}
+	return Summary{}, fmt.Errorf("all targets failed: %w", lastErr)
+}
+
+// stream prints a streamed reply. An error partway through is returned
+// after what arrived was printed, and the target is not retried then.
+func stream(p providers.Provider, req providers.ChatRequest, w io.Writer, asJSON bool) error {
+	chunkCh, errCh := p.ChatStream(req)
+	printed := false
+	for chunk := range chunkCh {
+		data, _ := json.Marshal(chunk)
+		printChunk(w, chunk, data, asJSON)
+		printed = true
+	}
+	if !asJSON && printed {
+		fmt.Fprintln(w)
+	}
+	if err := <-errCh; err != nil {
+		if printed {
+			return fmt.Errorf("stream interrupted: %v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+func printResponse(w io.Writer, resp *providers.ChatResponse, raw []byte, asJSON bool) error {
+	if asJSON {
+		var out bytes.Buffer
+		if err := json.Indent(&out, raw, "", "  "); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, out.String())
+		return nil
+	}
+	if len(resp.Choices) == 0 {
+		return fmt.Errorf("response has no choices")
+	}
+	msg := resp.Choices[0].Message
+	fmt.Fprintln(w, msg.Content)
+	for _, call := range msg.ToolCalls {
+		fmt.Fprintf(w, "[tool call] %s(%s)\n", call.Function.Name, call.Function.Arguments)
+	}
+	return nil
+}
+
+func printChunk(w io.Writer, chunk providers.ChatChunk, raw []byte, asJSON bool) {
+	if asJSON {
+		fmt.Fprintf(w, "%s\n", raw)
+		return
+	}
+	for _, c := range chunk.Choices {
+		fmt.Fprint(w, c.Delta.Content)
+	}
+}
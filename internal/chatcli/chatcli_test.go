@@ -0,0 +1,96 @@
+package chatcli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/providers/mock"
+	"github.com/yewintnaing/ai-gateway/internal/router"
+)
+
+func TestOnlineStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Stream   bool              `json:"stream"`
+			Metadata map[string]string `json:"metadata"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if !body.Stream || body.Metadata["use_case"] != "support" {
+			t.Errorf("request = %+v", body)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("x-gw-route", "support")
+		w.Header().Set("x-gw-provider", "openai")
+		for _, word := range []string{"Hello", " there"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", word)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	summary, err := Run(context.Background(), Options{URL: srv.URL, APIKey: "secret", UseCase: "support", Prompt: "hi", Stream: true}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Hello there\n" {
+		t.Errorf("output = %q", out.String())
+	}
+	if summary.Route != "support" || summary.Provider != "openai" {
+		t.Errorf("summary = %+v", summary)
+	}
+}
+
+func TestOnlineError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"message":"rate limit exceeded"}}`)
+	}))
+	defer srv.Close()
+
+	_, err := Run(context.Background(), Options{URL: srv.URL, Prompt: "hi"}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "rate limit exceeded") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestOfflineFallback(t *testing.T) {
+	rt := router.NewRouter([]config.Route{{
+		Name:      "support",
+		Match:     config.Match{UseCase: "support"},
+		Primary:   config.Target{Provider: "down", Model: "m1"},
+		Fallbacks: []config.Target{{Provider: "up", Model: "m2"}},
+		Retries:   1,
+	}})
+	registry := providers.Registry{
+		"down": mock.NewProvider(mock.Options{ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable}),
+		"up":   mock.NewProvider(mock.Options{Responses: []string{"from fallback"}}),
+	}
+
+	var out bytes.Buffer
+	summary, err := offline(Options{UseCase: "support", Prompt: "hi"}, rt, registry, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "from fallback\n" {
+		t.Errorf("output = %q", out.String())
+	}
+	if summary.Route != "support" || summary.Provider != "up" || summary.Model != "m2" {
+		t.Errorf("summary = %+v", summary)
+	}
+
+	out.Reset()
+	if _, err := offline(Options{Target: "down/m1", Prompt: "hi"}, rt, registry, &out); err == nil {
+		t.Error("-target down/m1 succeeded, want the injected failure")
+	}
+}
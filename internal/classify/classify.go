@@ -0,0 +1,145 @@
+package classify
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// dims is the size of the hashed embedding. Collisions are rare enough at
+// this size for short prompts and a handful of categories.
+const dims = 512
+
+// DefaultMinSimilarity is the cosine similarity a prompt must reach with a
+// category's centroid to be assigned to it.
+const DefaultMinSimilarity = 0.2
+
+// Vector is a unit-length embedding.
+type Vector [dims]float32
+
+// Embed maps text to a hashed bag of words and character trigrams,
+// normalised to unit length. It is crude next to a learned embedding but
+// needs no model call, so it can run on every request.
+func Embed(text string) Vector {
+	var v Vector
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		add(&v, "w:"+word, 1)
+		padded := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			add(&v, string(padded[i:i+3]), 0.5)
+		}
+	}
+	normalize(&v)
+	return v
+}
+
+func add(v *Vector, feature string, weight float32) {
+	h := fnv.New32a()
+	h.Write([]byte(feature))
+	sum := h.Sum32()
+	// The top bit picks the sign so that collisions tend to cancel out.
+	if sum&(1<<31) != 0 {
+		weight = -weight
+	}
+	v[sum%dims] += weight
+}
+
+func normalize(v *Vector) {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range v {
+		v[i] *= scale
+	}
+}
+
+// Similarity is the cosine similarity of two unit vectors.
+func Similarity(a, b Vector) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+// Category is a named set of exemplar prompts.
+type Category struct {
+	Name      string
+	Exemplars []string
+}
+
+// Classifier assigns text to the category whose exemplar centroid is
+// nearest.
+type Classifier struct {
+	names         []string
+	centroids     []Vector
+	minSimilarity float64
+}
+
+// New embeds each category's exemplars and averages them into a centroid.
+// minSimilarity of 0 uses DefaultMinSimilarity.
+func New(categories []Category, minSimilarity float64) (*Classifier, error) {
+	if len(categories) == 0 {
+		return nil, errors.New("classifier needs at least one category")
+	}
+	if minSimilarity <= 0 {
+		minSimilarity = DefaultMinSimilarity
+	}
+	c := &Classifier{minSimilarity: minSimilarity}
+	seen := make(map[string]bool, len(categories))
+	for _, cat := range categories {
+		if cat.Name == "" {
+			return nil, errors.New("classifier category needs a name")
+		}
+		if seen[cat.Name] {
+			return nil, fmt.Errorf("duplicate classifier category %q", cat.Name)
+		}
+		seen[cat.Name] = true
+
+		var centroid Vector
+		n := 0
+		for _, ex := range cat.Exemplars {
+			if strings.TrimSpace(ex) == "" {
+				continue
+			}
+			v := Embed(ex)
+			for i := range centroid {
+				centroid[i] += v[i]
+			}
+			n++
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("classifier category %q needs at least one exemplar", cat.Name)
+		}
+		normalize(&centroid)
+		c.names = append(c.names, cat.Name)
+		c.centroids = append(c.centroids, centroid)
+	}
+	return c, nil
+}
+
+// Classify returns the nearest category and its similarity. ok is false
+// when no category reaches the minimum similarity.
+func (c *Classifier) Classify(text string) (name string, similarity float64, ok bool) {
+	v := Embed(text)
+	best := -1
+	for i, centroid := range c.centroids {
+		if s := Similarity(v, centroid); best < 0 || s > similarity {
+			best, similarity = i, s
+		}
+	}
+	if similarity < c.minSimilarity {
+		return "", similarity, false
+	}
+	return c.names[best], similarity, true
+}
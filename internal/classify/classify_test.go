@@ -0,0 +1,62 @@
+package classify
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	c, err := New([]Category{
+		{Name: "code", Exemplars: []string{
+			"Why does my Go function return a nil pointer error?",
+			"Write a Python script that parses a CSV file",
+			"How do I fix this compile error in my Rust code?",
+		}},
+		{Name: "legal", Exemplars: []string{
+			"Is this contract clause enforceable?",
+			"What are my rights as a tenant if the landlord breaks the lease?",
+			"Explain the liability terms in this agreement",
+		}},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		text string
+		want string
+		ok   bool
+	}{
+		{"My Python function throws a pointer error when parsing the file", "code", true},
+		{"Can my landlord end the lease under this contract clause?", "legal", true},
+		{"zzz qqq", "", false},
+	}
+	for _, tc := range cases {
+		got, sim, ok := c.Classify(tc.text)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("Classify(%q) = %q (%.2f, %v), want %q (%v)", tc.text, got, sim, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	bad := [][]Category{
+		nil,
+		{{Name: "", Exemplars: []string{"x"}}},
+		{{Name: "a"}},
+		{{Name: "a", Exemplars: []string{"x"}}, {Name: "a", Exemplars: []string{"y"}}},
+	}
+	for i, cats := range bad {
+		if _, err := New(cats, 0); err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+}
+
+func TestEmbedUnitLength(t *testing.T) {
+	v := Embed("hello there, general kenobi")
+	if s := Similarity(v, v); s < 0.999 || s > 1.001 {
+		t.Errorf("self similarity = %f, want 1", s)
+	}
+	var zero Vector
+	if Embed("") != zero {
+		t.Error("empty text should embed to the zero vector")
+	}
+}
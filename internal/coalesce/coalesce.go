@@ -0,0 +1,226 @@
+// Package coalesce shares one provider call between identical requests that
+// are in flight at the same time. Only deterministic requests qualify, since
+// for anything sampled each caller is owed its own completion.
+package coalesce
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// traceHeaders carry per-request trace context, which does not change the
+// completion.
+var traceHeaders = map[string]bool{"traceparent": true, "tracestate": true, "baggage": true}
+
+// Key identifies req to provider for coalescing. ok is false when req is not
+// deterministic: its temperature is not set to 0, or it asks for several
+// choices. A request that leaves temperature unset runs at the provider's
+// default, or its request defaults, and is sampled. Outbound headers other
+// than trace context are part of the key, since they can change how the
+// provider handles the call (zero-retention opt-outs, for one).
+func Key(provider string, req providers.ChatRequest) (key string, ok bool) {
+	if req.Temperature == nil || *req.Temperature != 0 || req.N > 1 {
+		return "", false
+	}
+	body, err := req.MarshalBody(req)
+	if err != nil {
+		return "", false
+	}
+	h := sha256.New()
+	h.Write([]byte(provider))
+	h.Write([]byte{0})
+	h.Write(body)
+	if req.Thinking != nil {
+		// Not part of the generic body, but it changes the completion.
+		thinking, _ := json.Marshal(req.Thinking)
+		h.Write(thinking)
+	}
+	// Calls billed to different accounts are not shared.
+	h.Write([]byte{0})
+	h.Write([]byte(req.Account))
+	names := make([]string, 0, len(req.Headers))
+	for name := range req.Headers {
+		if !traceHeaders[strings.ToLower(name)] {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		h.Write([]byte{0})
+		h.Write([]byte(strings.ToLower(name) + ": " + req.Headers[name]))
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// Group tracks the calls in flight. A nil Group never coalesces.
+type Group struct {
+	mu      sync.Mutex
+	calls   map[string]*call
+	streams map[string]*stream
+}
+
+func New() *Group {
+	return &Group{calls: make(map[string]*call), streams: make(map[string]*stream)}
+}
+
+type call struct {
+	done chan struct{}
+	resp *providers.ChatResponse
+	err  error
+}
+
+// Do runs fn for key unless a call for key is already in flight, in which
+// case it waits for that call's result. shared reports whether the result
+// came from another caller's call; each caller gets its own copy of the
+// response, so it may be modified freely.
+func (g *Group) Do(key string, fn func() (*providers.ChatResponse, error)) (resp *providers.ChatResponse, shared bool, err error) {
+	if g == nil || key == "" {
+		resp, err = fn()
+		return resp, false, err
+	}
+
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return cloneResponse(c.resp), true, c.err
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.resp, c.err = fn()
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(c.done)
+	return cloneResponse(c.resp), false, c.err
+}
+
+func cloneResponse(resp *providers.ChatResponse) *providers.ChatResponse {
+	if resp == nil {
+		return nil
+	}
+	out := *resp
+	out.Choices = slices.Clone(resp.Choices)
+	return &out
+}
+
+// stream fans one upstream stream out to any number of subscribers. Every
+// chunk is kept, so a subscriber joining late still sees the stream from
+// its start.
+type stream struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	chunks []providers.ChatChunk
+	err    error
+	done   bool
+}
+
+// Stream is Do for streamed calls: the first caller for key starts the
+// upstream stream with fn, and callers arriving before it ends receive the
+// same chunks from the beginning. A caller stops receiving when ctx is
+// done; the upstream stream runs on for the others.
+func (g *Group) Stream(ctx context.Context, key string, fn func() (<-chan providers.ChatChunk, <-chan error)) (<-chan providers.ChatChunk, <-chan error, bool) {
+	if g == nil || key == "" {
+		chunkCh, errCh := fn()
+		return chunkCh, errCh, false
+	}
+
+	g.mu.Lock()
+	if s, ok := g.streams[key]; ok {
+		g.mu.Unlock()
+		chunkCh, errCh := s.subscribe(ctx)
+		return chunkCh, errCh, true
+	}
+	s := &stream{}
+	s.cond = sync.NewCond(&s.mu)
+	g.streams[key] = s
+	g.mu.Unlock()
+
+	upChunks, upErrs := fn()
+	go func() {
+		// Providers may fail without closing chunkCh, so an error ends the
+		// stream as much as chunkCh closing does.
+		var err error
+		for chunks := upChunks; chunks != nil; {
+			select {
+			case chunk, ok := <-chunks:
+				if !ok {
+					chunks = nil
+					select {
+					case err = <-upErrs:
+					default:
+					}
+					continue
+				}
+				s.mu.Lock()
+				s.chunks = append(s.chunks, chunk)
+				s.mu.Unlock()
+				s.cond.Broadcast()
+			case e, ok := <-upErrs:
+				if !ok {
+					upErrs = nil
+					continue
+				}
+				err, chunks = e, nil
+			}
+		}
+
+		// Stop taking subscribers before the end is announced, so nobody
+		// joins a stream that has already finished.
+		g.mu.Lock()
+		delete(g.streams, key)
+		g.mu.Unlock()
+
+		s.mu.Lock()
+		s.err, s.done = err, true
+		s.mu.Unlock()
+		s.cond.Broadcast()
+	}()
+
+	chunkCh, errCh := s.subscribe(ctx)
+	return chunkCh, errCh, false
+}
+
+// subscribe returns channels that replay the stream from its first chunk,
+// shaped like a provider's ChatStream: chunkCh closes at the end and errCh
+// then carries the upstream error, if any.
+func (s *stream) subscribe(ctx context.Context) (<-chan providers.ChatChunk, <-chan error) {
+	chunkCh := make(chan providers.ChatChunk)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		defer close(chunkCh)
+		for i := 0; ; i++ {
+			s.mu.Lock()
+			for i >= len(s.chunks) && !s.done {
+				s.cond.Wait()
+			}
+			if i >= len(s.chunks) {
+				err := s.err
+				s.mu.Unlock()
+				if err != nil {
+					errCh <- err
+				}
+				return
+			}
+			chunk := s.chunks[i]
+			s.mu.Unlock()
+			chunk.Choices = slices.Clone(chunk.Choices)
+			select {
+			case chunkCh <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return chunkCh, errCh
+}
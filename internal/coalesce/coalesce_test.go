@@ -0,0 +1,183 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestKey(t *testing.T) {
+	req := providers.ChatRequest{Model: "gpt-4o", Messages: []providers.Message{{Role: "user", Content: "hi"}}}
+	if _, ok := Key("openai", req); ok {
+		t.Error("expected a request without a temperature not to be coalesced")
+	}
+	zero := 0.0
+	req.Temperature = &zero
+	a, ok := Key("openai", req)
+	if !ok {
+		t.Fatal("expected a temperature 0 request to be coalescable")
+	}
+	req.Headers = map[string]string{"traceparent": "00-abc", "tracestate": "v=1"}
+	if b, _ := Key("openai", req); b != a {
+		t.Error("expected trace context not to change the key")
+	}
+	req.Headers["X-Zero-Retention"] = "true"
+	if b, _ := Key("openai", req); b == a {
+		t.Error("expected other headers to change the key")
+	}
+	req.Headers = nil
+	if b, _ := Key("azure", req); b == a {
+		t.Error("expected the provider to be part of the key")
+	}
+	req.Account = "research"
+	if b, _ := Key("openai", req); b == a {
+		t.Error("expected the provider account to be part of the key")
+	}
+	req.Account = ""
+	req.Thinking = &providers.Thinking{Type: "enabled", BudgetTokens: 1024}
+	if b, _ := Key("openai", req); b == a {
+		t.Error("expected a thinking budget to change the key")
+	}
+	temperature := 0.7
+	req.Temperature = &temperature
+	if _, ok := Key("openai", req); ok {
+		t.Error("expected a sampled request not to be coalesced")
+	}
+}
+
+func TestDo(t *testing.T) {
+	g := New()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() (*providers.ChatResponse, error) {
+		calls.Add(1)
+		<-release
+		resp := &providers.ChatResponse{ID: "one"}
+		resp.Choices = append(resp.Choices, struct {
+			Index        int               `json:"index"`
+			Message      providers.Message `json:"message"`
+			FinishReason string            `json:"finish_reason"`
+		}{Message: providers.Message{Content: "answer"}})
+		return resp, nil
+	}
+
+	var wg sync.WaitGroup
+	var shared atomic.Int32
+	resps := make([]*providers.ChatResponse, 3)
+	for i := range resps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, s, err := g.Do("k", fn)
+			if err != nil {
+				t.Error(err)
+			}
+			if s {
+				shared.Add(1)
+			}
+			resps[i] = resp
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 || shared.Load() != 2 {
+		t.Fatalf("expected 1 call shared by 2 waiters, got %d calls and %d shared", calls.Load(), shared.Load())
+	}
+	resps[0].Choices[0].Message.Content = "changed"
+	if resps[1].Choices[0].Message.Content != "answer" {
+		t.Error("expected each caller to get its own copy")
+	}
+
+	// Once the call is over, the next one runs again.
+	g.Do("k", func() (*providers.ChatResponse, error) { calls.Add(1); return nil, nil })
+	if calls.Load() != 2 {
+		t.Error("expected a new call after the first finished")
+	}
+}
+
+func TestStream(t *testing.T) {
+	g := New()
+	ctx := context.Background()
+	up := make(chan providers.ChatChunk)
+	upErr := make(chan error, 1)
+	var calls int
+	fn := func() (<-chan providers.ChatChunk, <-chan error) {
+		calls++
+		return up, upErr
+	}
+	chunk := func(s string) providers.ChatChunk {
+		return providers.ChatChunk{Choices: []providers.ChunkChoice{{Delta: providers.ChunkDelta{Content: s}}}}
+	}
+
+	leader, leaderErr, shared := g.Stream(ctx, "k", fn)
+	if shared {
+		t.Fatal("expected the first caller to lead")
+	}
+	up <- chunk("Hel")
+	if got := <-leader; got.Choices[0].Delta.Content != "Hel" {
+		t.Fatalf("got %+v", got)
+	}
+
+	// A late joiner replays from the first chunk.
+	follower, followerErr, shared := g.Stream(ctx, "k", fn)
+	if !shared || calls != 1 {
+		t.Fatalf("expected to join the running stream, shared=%v calls=%d", shared, calls)
+	}
+	up <- chunk("lo")
+	upErr <- errors.New("upstream reset")
+	close(up)
+
+	read := func(ch <-chan providers.ChatChunk, errCh <-chan error) (string, error) {
+		var text string
+		for c := range ch {
+			text += c.Choices[0].Delta.Content
+		}
+		return text, <-errCh
+	}
+	if text, err := read(follower, followerErr); text != "Hello" || err == nil {
+		t.Errorf("follower got %q, %v", text, err)
+	}
+	if text, err := read(leader, leaderErr); text != "lo" || err == nil {
+		t.Errorf("leader got %q, %v", text, err)
+	}
+}
+
+func TestStreamEarlyError(t *testing.T) {
+	g := New()
+	ctx := context.Background()
+	var calls int
+	fn := func() (<-chan providers.ChatChunk, <-chan error) {
+		// Like a provider failing before it starts streaming: chunkCh is
+		// never closed.
+		calls++
+		errCh := make(chan error, 1)
+		errCh <- errors.New("no API key")
+		return make(chan providers.ChatChunk), errCh
+	}
+
+	chunkCh, errCh, _ := g.Stream(ctx, "k", fn)
+	for range chunkCh {
+	}
+	if err := <-errCh; err == nil {
+		t.Fatal("expected the upstream error")
+	}
+	// The failed stream must not be left for the next caller to join.
+	if _, _, shared := g.Stream(ctx, "k", fn); shared || calls != 2 {
+		t.Errorf("expected a new upstream call, shared=%v calls=%d", shared, calls)
+	}
+}
+
+func TestNilGroup(t *testing.T) {
+	var g *Group
+	_, shared, _ := g.Do("k", func() (*providers.ChatResponse, error) { return nil, nil })
+	if shared {
+		t.Error("expected a nil group not to coalesce")
+	}
+}
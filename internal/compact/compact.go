@@ -0,0 +1,226 @@
+// Package compact shortens chat prompts that do not fit a model's context
+// window. Every strategy keeps the system messages and the latest turn, the
+// last user message and what follows it, and drops whole turns, so a tool
+// call is never separated from its result.
+package compact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// Strategy picks which turns are dropped.
+type Strategy string
+
+const (
+	// SlidingWindow drops the oldest turns first.
+	SlidingWindow Strategy = "sliding_window"
+	// MiddleDrop keeps the first KeepFirst turns, which usually set the
+	// task, and drops the oldest of the turns after them.
+	MiddleDrop Strategy = "middle_drop"
+	// Importance drops the turns that score lowest: older before newer,
+	// tool results before assistant replies before user messages.
+	Importance Strategy = "importance"
+	// Summarize replaces the oldest turns with a summary of them, written
+	// by Options.Summarize.
+	Summarize Strategy = "summarize"
+)
+
+// Strategies are the strategies Compact knows.
+var Strategies = []Strategy{SlidingWindow, MiddleDrop, Importance, Summarize}
+
+// ErrNoFit is returned when the messages do not fit the budget even with
+// every turn that may be dropped gone.
+var ErrNoFit = errors.New("prompt does not fit the context window")
+
+// SummaryPrefix starts the system message a summary is sent in.
+const SummaryPrefix = "Summary of the earlier conversation:\n"
+
+// Options configure Compact.
+type Options struct {
+	Strategy Strategy
+	// Budget is how many tokens the messages must fit in.
+	Budget int
+	// KeepFirst is how many turns after the system messages MiddleDrop
+	// keeps; 0 means 1.
+	KeepFirst int
+	// SummaryTokens is the room left for a summary; 0 means 256.
+	SummaryTokens int
+	// Count counts the tokens of messages; nil counts them as the gateway
+	// does for rate limits.
+	Count func([]providers.Message) int
+	// Summarize condenses messages into a short text. Summarize needs it.
+	Summarize func(ctx context.Context, messages []providers.Message) (string, error)
+}
+
+// Result is a compacted prompt.
+type Result struct {
+	Messages []providers.Message
+	// Dropped counts the messages left out, summarized ones included.
+	Dropped    int
+	Summarized bool
+}
+
+// Count counts the tokens of messages as the gateway charges them.
+func Count(messages []providers.Message) int {
+	return usage.ApproximateTokens(fmt.Sprintf("%v", messages))
+}
+
+// turn is messages[start:end]: a system message, or a user or assistant
+// message with the tool results that follow it.
+type turn struct {
+	start, end int
+	tokens     int
+	pinned     bool
+	score      float64
+}
+
+// Compact returns messages fitted into opts.Budget, or messages themselves
+// when they already fit. It fails with ErrNoFit when they cannot be made
+// to, and with Summarize's error when summarizing fails.
+func Compact(ctx context.Context, messages []providers.Message, opts Options) (Result, error) {
+	count := opts.Count
+	if count == nil {
+		count = Count
+	}
+	if count(messages) <= opts.Budget {
+		return Result{Messages: messages}, nil
+	}
+
+	turns := split(messages, count)
+	budget := opts.Budget
+	var order []int // indexes of turns in the order they are dropped
+	switch opts.Strategy {
+	case SlidingWindow, Summarize:
+		order = droppable(turns, 0)
+	case MiddleDrop:
+		keep := opts.KeepFirst
+		if keep <= 0 {
+			keep = 1
+		}
+		order = droppable(turns, keep)
+	case Importance:
+		order = droppable(turns, 0)
+		sort.SliceStable(order, func(i, j int) bool { return turns[order[i]].score < turns[order[j]].score })
+	default:
+		return Result{}, fmt.Errorf("unknown compaction strategy %q", opts.Strategy)
+	}
+	if opts.Strategy == Summarize {
+		if opts.Summarize == nil {
+			return Result{}, errors.New("summarize needs a summarizer")
+		}
+		room := opts.SummaryTokens
+		if room <= 0 {
+			room = 256
+		}
+		budget -= room
+	}
+
+	total := 0
+	for _, t := range turns {
+		total += t.tokens
+	}
+	dropped := map[int]bool{}
+	for _, i := range order {
+		if total <= budget {
+			break
+		}
+		dropped[i] = true
+		total -= turns[i].tokens
+	}
+	if total > budget {
+		return Result{}, ErrNoFit
+	}
+
+	res := Result{}
+	var gone []providers.Message
+	summaryAt := -1
+	for i, t := range turns {
+		if !dropped[i] {
+			res.Messages = append(res.Messages, messages[t.start:t.end]...)
+			continue
+		}
+		if summaryAt < 0 {
+			summaryAt = len(res.Messages)
+		}
+		gone = append(gone, messages[t.start:t.end]...)
+	}
+	res.Dropped = len(gone)
+	if opts.Strategy != Summarize || len(gone) == 0 {
+		return res, nil
+	}
+
+	summary, err := opts.Summarize(ctx, gone)
+	if err != nil {
+		return Result{}, err
+	}
+	msg := providers.Message{Role: "system", Content: SummaryPrefix + strings.TrimSpace(summary)}
+	res.Messages = append(res.Messages[:summaryAt], append([]providers.Message{msg}, res.Messages[summaryAt:]...)...)
+	res.Summarized = true
+	if count(res.Messages) > opts.Budget {
+		return Result{}, ErrNoFit
+	}
+	return res, nil
+}
+
+// split groups messages into turns, pins the system messages and the
+// latest turn, and scores the rest for Importance.
+func split(messages []providers.Message, count func([]providers.Message) int) []turn {
+	var turns []turn
+	for i, m := range messages {
+		if m.Role == "tool" && len(turns) > 0 && messages[turns[len(turns)-1].start].Role != "system" {
+			turns[len(turns)-1].end = i + 1
+			continue
+		}
+		turns = append(turns, turn{start: i, end: i + 1})
+	}
+
+	last := len(turns) - 1
+	for i := last; i >= 0; i-- {
+		if messages[turns[i].start].Role == "user" {
+			last = i
+			break
+		}
+	}
+	for i := range turns {
+		t := &turns[i]
+		t.tokens = count(messages[t.start:t.end])
+		role := messages[t.start].Role
+		t.pinned = role == "system" || i >= last
+		// Recency counts for most; the role breaks ties between turns of
+		// about the same age.
+		t.score = float64(i) / float64(len(turns))
+		switch {
+		case role == "user":
+			t.score += 0.3
+		case t.end-t.start > 1:
+			t.score += 0.1 // an assistant turn that called tools
+		case role == "assistant":
+			t.score += 0.2
+		}
+	}
+	return turns
+}
+
+// droppable returns the turns that may be dropped, oldest first, keeping
+// the first keep turns that are not system messages.
+func droppable(turns []turn, keep int) []int {
+	var order []int
+	for i, t := range turns {
+		if t.pinned {
+			continue
+		}
+		if keep > 0 {
+			keep--
+			continue
+		}
+		order = append(order, i)
+	}
+	return order
+}
@@ -0,0 +1,136 @@
+package compact
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// countMessages counts every message as 10 tokens.
+func countMessages(ms []providers.Message) int { return 10 * len(ms) }
+
+func conversation() []providers.Message {
+	return []providers.Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "u1"},
+		{Role: "assistant", Content: "a1"},
+		{Role: "user", Content: "u2"},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{{ID: "c1"}}},
+		{Role: "tool", ToolCallID: "c1", Content: "t1"},
+		{Role: "assistant", Content: "a2"},
+		{Role: "user", Content: "u3"},
+	}
+}
+
+func contents(ms []providers.Message) []string {
+	var out []string
+	for _, m := range ms {
+		out = append(out, m.Role+":"+m.Content)
+	}
+	return out
+}
+
+func TestCompact(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		want     []string
+		wantDrop int
+	}{
+		{
+			name: "fits",
+			opts: Options{Strategy: SlidingWindow, Budget: 80},
+			want: contents(conversation()),
+		},
+		{
+			name:     "sliding window drops the oldest turns",
+			opts:     Options{Strategy: SlidingWindow, Budget: 40},
+			want:     []string{"system:sys", "assistant:a2", "user:u3"},
+			wantDrop: 5,
+		},
+		{
+			name:     "middle drop keeps the first turn",
+			opts:     Options{Strategy: MiddleDrop, Budget: 40},
+			want:     []string{"system:sys", "user:u1", "assistant:a2", "user:u3"},
+			wantDrop: 4,
+		},
+		{
+			name:     "importance drops tool turns before user messages",
+			opts:     Options{Strategy: Importance, Budget: 50},
+			want:     []string{"system:sys", "user:u2", "assistant:a2", "user:u3"},
+			wantDrop: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Count = countMessages
+			res, err := Compact(context.Background(), conversation(), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := contents(res.Messages); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if res.Dropped != tt.wantDrop {
+				t.Errorf("dropped %d, want %d", res.Dropped, tt.wantDrop)
+			}
+		})
+	}
+
+	t.Run("tool results go with their call", func(t *testing.T) {
+		res, err := Compact(context.Background(), conversation(), Options{Strategy: SlidingWindow, Budget: 50, Count: countMessages})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, m := range res.Messages {
+			if m.Role == "tool" && (i == 0 || len(res.Messages[i-1].ToolCalls) == 0) {
+				t.Errorf("tool result without its call in %v", contents(res.Messages))
+			}
+		}
+	})
+
+	t.Run("latest turn is never dropped", func(t *testing.T) {
+		_, err := Compact(context.Background(), conversation(), Options{Strategy: SlidingWindow, Budget: 10, Count: countMessages})
+		if !errors.Is(err, ErrNoFit) {
+			t.Errorf("expected ErrNoFit, got %v", err)
+		}
+	})
+
+	t.Run("unknown strategy", func(t *testing.T) {
+		if _, err := Compact(context.Background(), conversation(), Options{Strategy: "newest", Count: countMessages}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestCompactSummarize(t *testing.T) {
+	var summarized []providers.Message
+	opts := Options{
+		Strategy: Summarize, Budget: 50, SummaryTokens: 10, Count: countMessages,
+		Summarize: func(ctx context.Context, ms []providers.Message) (string, error) {
+			summarized = ms
+			return " the user asked twice ", nil
+		},
+	}
+	res, err := Compact(context.Background(), conversation(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"system:sys", "system:" + SummaryPrefix + "the user asked twice", "assistant:a2", "user:u3"}
+	if got := contents(res.Messages); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(summarized) != 5 || res.Dropped != 5 || !res.Summarized {
+		t.Errorf("summarized %d messages, dropped %d, summarized %v", len(summarized), res.Dropped, res.Summarized)
+	}
+
+	opts.Summarize = func(ctx context.Context, ms []providers.Message) (string, error) {
+		return "", errors.New("down")
+	}
+	if _, err := Compact(context.Background(), conversation(), opts); err == nil {
+		t.Error("expected the summarizer's error")
+	}
+}
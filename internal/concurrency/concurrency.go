@@ -0,0 +1,231 @@
+// Package concurrency caps the calls in flight to a provider target, either
+// per instance or across all replicas through Redis.
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrSaturated is returned when no slot frees up in time.
+var ErrSaturated = errors.New("concurrency limit reached")
+
+// Backend hands out slots. TryAcquire takes one of limit slots for key if one
+// is free, returning the function that gives it back.
+type Backend interface {
+	TryAcquire(ctx context.Context, key string, limit int) (release func(), ok bool, err error)
+}
+
+// Limiter waits for slots from a Backend. A nil Limiter never limits.
+//
+// Callers wait at the priority set on their context (see WithPriority).
+// Backends that queue waiters themselves honor it directly; for polled
+// backends a waiter only polls while no higher priority caller on this
+// instance is waiting for the same key.
+type Limiter struct {
+	backend Backend
+	poll    time.Duration
+
+	mu      sync.Mutex
+	waiting map[string]*[numPriorities]int
+}
+
+func NewLimiter(b Backend) *Limiter {
+	return &Limiter{backend: b, poll: 20 * time.Millisecond, waiting: make(map[string]*[numPriorities]int)}
+}
+
+// Acquire takes a slot for key, waiting up to wait for one to free up; a
+// zero wait fails fast. The backend being unavailable never blocks a call.
+func (l *Limiter) Acquire(ctx context.Context, key string, limit int, wait time.Duration) (func(), error) {
+	if l == nil || limit <= 0 {
+		return func() {}, nil
+	}
+	if w, ok := l.backend.(waiter); ok {
+		return w.acquire(ctx, key, limit, wait)
+	}
+
+	p := PriorityFrom(ctx)
+	l.wait(key, p, 1)
+	defer l.wait(key, p, -1)
+
+	deadline := time.Now().Add(wait)
+	for {
+		if !l.outranked(key, p) {
+			release, ok, err := l.backend.TryAcquire(ctx, key, limit)
+			if err != nil {
+				log.Printf("Warning: concurrency limiter unavailable: %v", err)
+				return func() {}, nil
+			}
+			if ok {
+				return release, nil
+			}
+		}
+		if !time.Now().Add(l.poll).Before(deadline) {
+			return nil, ErrSaturated
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(l.poll):
+		}
+	}
+}
+
+// wait adjusts the count of callers waiting for key at priority p.
+func (l *Limiter) wait(key string, p Priority, delta int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counts, ok := l.waiting[key]
+	if !ok {
+		counts = new([numPriorities]int)
+		l.waiting[key] = counts
+	}
+	counts[p] += delta
+	if *counts == [numPriorities]int{} {
+		delete(l.waiting, key)
+	}
+}
+
+// outranked reports whether a caller on this instance is waiting for key at
+// a higher priority than p.
+func (l *Limiter) outranked(key string, p Priority) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counts, ok := l.waiting[key]
+	if !ok {
+		return false
+	}
+	for q := int(p) + 1; q < numPriorities; q++ {
+		if counts[q] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// waiter is implemented by backends that can block for a slot themselves
+// instead of being polled.
+type waiter interface {
+	acquire(ctx context.Context, key string, limit int, wait time.Duration) (func(), error)
+}
+
+// Local counts the slots held per key in this instance. Waiters queue by
+// priority and, within a priority, in arrival order.
+type Local struct {
+	mu    sync.Mutex
+	slots map[string]*localSlot
+}
+
+type localSlot struct {
+	limit int
+	used  int
+	queue [numPriorities][]chan struct{}
+}
+
+func NewLocal() *Local {
+	return &Local{slots: make(map[string]*localSlot)}
+}
+
+// slot returns key's state with the caller holding s.mu. A changed limit
+// takes effect immediately; slots held beyond a lowered one are not
+// handed on when released.
+func (s *Local) slot(key string, limit int) *localSlot {
+	sl, ok := s.slots[key]
+	if !ok {
+		sl = &localSlot{}
+		s.slots[key] = sl
+	}
+	if sl.limit != limit {
+		sl.limit = limit
+		sl.dispatch()
+	}
+	return sl
+}
+
+// dispatch grants free slots to waiters, highest priority first.
+func (sl *localSlot) dispatch() {
+	for p := numPriorities - 1; p >= 0 && sl.used < sl.limit; p-- {
+		for len(sl.queue[p]) > 0 && sl.used < sl.limit {
+			close(sl.queue[p][0])
+			sl.queue[p] = sl.queue[p][1:]
+			sl.used++
+		}
+	}
+}
+
+// remove takes ch out of the queue, reporting false if it was already
+// granted a slot.
+func (sl *localSlot) remove(p Priority, ch chan struct{}) bool {
+	for i, c := range sl.queue[p] {
+		if c == ch {
+			sl.queue[p] = append(sl.queue[p][:i], sl.queue[p][i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Local) TryAcquire(_ context.Context, key string, limit int) (func(), bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sl := s.slot(key, limit)
+	if sl.used >= sl.limit {
+		return nil, false, nil
+	}
+	sl.used++
+	return s.releaser(sl), true, nil
+}
+
+func (s *Local) acquire(ctx context.Context, key string, limit int, wait time.Duration) (func(), error) {
+	s.mu.Lock()
+	sl := s.slot(key, limit)
+	if sl.used < sl.limit {
+		sl.used++
+		s.mu.Unlock()
+		return s.releaser(sl), nil
+	}
+	if wait <= 0 {
+		s.mu.Unlock()
+		return nil, ErrSaturated
+	}
+	p := PriorityFrom(ctx)
+	ch := make(chan struct{})
+	sl.queue[p] = append(sl.queue[p], ch)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-ch:
+		return s.releaser(sl), nil
+	case <-timer.C:
+		err = ErrSaturated
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	removed := sl.remove(p, ch)
+	s.mu.Unlock()
+	if !removed {
+		// Granted while giving up: pass the slot on.
+		s.releaser(sl)()
+	}
+	return nil, err
+}
+
+func (s *Local) releaser(sl *localSlot) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			sl.used--
+			sl.dispatch()
+		})
+	}
+}
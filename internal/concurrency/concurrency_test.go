@@ -0,0 +1,158 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLocalFailFast(t *testing.T) {
+	l := NewLimiter(NewLocal())
+	ctx := context.Background()
+
+	r1, err := l.Acquire(ctx, "openai/gpt-4o", 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(ctx, "openai/gpt-4o", 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(ctx, "openai/gpt-4o", 2, 0); !errors.Is(err, ErrSaturated) {
+		t.Fatalf("expected saturation, got %v", err)
+	}
+	if _, err := l.Acquire(ctx, "anthropic/claude-3-5-sonnet", 2, 0); err != nil {
+		t.Errorf("expected other targets to be unaffected, got %v", err)
+	}
+
+	r1()
+	r1() // releasing twice frees one slot only
+	if _, err := l.Acquire(ctx, "openai/gpt-4o", 2, 0); err != nil {
+		t.Fatalf("expected a freed slot, got %v", err)
+	}
+	if _, err := l.Acquire(ctx, "openai/gpt-4o", 2, 0); !errors.Is(err, ErrSaturated) {
+		t.Fatalf("expected double release to free one slot, got %v", err)
+	}
+}
+
+func TestLocalQueue(t *testing.T) {
+	l := NewLimiter(NewLocal())
+	ctx := context.Background()
+
+	release, _ := l.Acquire(ctx, "k", 1, 0)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		release()
+	}()
+	if _, err := l.Acquire(ctx, "k", 1, time.Second); err != nil {
+		t.Fatalf("expected queued call to get the freed slot, got %v", err)
+	}
+
+	start := time.Now()
+	if _, err := l.Acquire(ctx, "k", 1, 30*time.Millisecond); !errors.Is(err, ErrSaturated) {
+		t.Fatalf("expected queue timeout, got %v", err)
+	}
+	if time.Since(start) < 30*time.Millisecond {
+		t.Error("expected the call to wait for the queue timeout")
+	}
+}
+
+// fakeBackend has a fixed number of free slots and no waiting of its own.
+type fakeBackend struct {
+	free int
+	err  error
+}
+
+func (f *fakeBackend) TryAcquire(context.Context, string, int) (func(), bool, error) {
+	if f.err != nil || f.free == 0 {
+		return nil, false, f.err
+	}
+	f.free--
+	return func() { f.free++ }, true, nil
+}
+
+func TestPolledBackend(t *testing.T) {
+	ctx := context.Background()
+	b := &fakeBackend{free: 1}
+	l := NewLimiter(b)
+	l.poll = time.Millisecond
+
+	release, err := l.Acquire(ctx, "k", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(ctx, "k", 1, 10*time.Millisecond); !errors.Is(err, ErrSaturated) {
+		t.Fatalf("expected saturation after polling, got %v", err)
+	}
+	release()
+	if _, err := l.Acquire(ctx, "k", 1, 0); err != nil {
+		t.Fatalf("expected slot after release, got %v", err)
+	}
+
+	l = NewLimiter(&fakeBackend{err: errors.New("redis down")})
+	if _, err := l.Acquire(ctx, "k", 1, 0); err != nil {
+		t.Errorf("expected an unavailable backend not to block calls, got %v", err)
+	}
+}
+
+func TestNoLimit(t *testing.T) {
+	var l *Limiter
+	if _, err := l.Acquire(context.Background(), "k", 1, 0); err != nil {
+		t.Errorf("expected nil limiter to allow, got %v", err)
+	}
+}
+
+func TestLocalPriority(t *testing.T) {
+	l := NewLimiter(NewLocal())
+	ctx := context.Background()
+
+	release, _ := l.Acquire(ctx, "k", 1, 0)
+	order := make(chan Priority, 2)
+	queue := func(p Priority) {
+		r, err := l.Acquire(WithPriority(ctx, p), "k", 1, time.Second)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		order <- p
+		r()
+	}
+	go queue(Batch)
+	time.Sleep(10 * time.Millisecond)
+	go queue(Interactive)
+	time.Sleep(10 * time.Millisecond)
+
+	release()
+	if first, second := <-order, <-order; first != Interactive || second != Batch {
+		t.Errorf("served %v then %v, want interactive ahead of the earlier batch call", first, second)
+	}
+}
+
+func TestPolledPriority(t *testing.T) {
+	b := &fakeBackend{}
+	l := NewLimiter(b)
+	l.poll = time.Millisecond
+	ctx := context.Background()
+
+	l.wait("k", Interactive, 1)
+	b.free = 1
+	if _, err := l.Acquire(WithPriority(ctx, Batch), "k", 1, 5*time.Millisecond); !errors.Is(err, ErrSaturated) {
+		t.Fatalf("expected batch to yield to a waiting interactive call, got %v", err)
+	}
+	l.wait("k", Interactive, -1)
+	if _, err := l.Acquire(WithPriority(ctx, Batch), "k", 1, 0); err != nil {
+		t.Fatalf("expected batch to get the slot once nothing outranks it, got %v", err)
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	if p, err := ParsePriority(""); err != nil || p != Standard {
+		t.Errorf("empty = %v, %v; want standard", p, err)
+	}
+	if p, err := ParsePriority("interactive"); err != nil || p != Interactive {
+		t.Errorf("interactive = %v, %v", p, err)
+	}
+	if _, err := ParsePriority("urgent"); err == nil {
+		t.Error("expected an unknown priority to fail")
+	}
+}
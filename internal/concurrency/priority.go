@@ -0,0 +1,55 @@
+package concurrency
+
+import (
+	"context"
+	"fmt"
+)
+
+// Priority orders callers waiting for a slot: a freed slot goes to the
+// highest priority waiting, and within a priority to the earliest.
+type Priority int
+
+const (
+	Batch Priority = iota
+	Standard
+	Interactive
+
+	numPriorities = int(Interactive) + 1
+)
+
+var priorityNames = [numPriorities]string{"batch", "standard", "interactive"}
+
+func (p Priority) String() string {
+	if p < 0 || int(p) >= numPriorities {
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority reads a priority name; empty means Standard.
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return Standard, nil
+	}
+	for i, name := range priorityNames {
+		if s == name {
+			return Priority(i), nil
+		}
+	}
+	return Standard, fmt.Errorf("unknown priority %q (want batch, standard or interactive)", s)
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a context whose slot requests wait at priority p.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFrom returns the priority set on ctx, or Standard.
+func PriorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return Standard
+}
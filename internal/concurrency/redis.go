@@ -0,0 +1,61 @@
+package concurrency
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// lease bounds how long a slot held by a replica that died without
+// releasing it stays taken.
+const lease = 10 * time.Minute
+
+// acquireLua takes a slot in a sorted set of holders scored by lease expiry.
+// ARGV: limit, now (unix ms), lease (ms), holder id.
+var acquireLua = redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local now = tonumber(ARGV[2])
+local lease = tonumber(ARGV[3])
+
+redis.call("ZREMRANGEBYSCORE", key, "-inf", now)
+if redis.call("ZCARD", key) >= limit then
+    return 0
+end
+redis.call("ZADD", key, now + lease, ARGV[4])
+redis.call("PEXPIRE", key, lease)
+return 1
+`)
+
+// Redis shares slots between gateway replicas, so the cap holds for the
+// cluster as a whole.
+type Redis struct {
+	client *redis.Client
+}
+
+func NewRedis(redisURL string) (*Redis, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to redis for concurrency limits: %w", err)
+	}
+	return &Redis{client: client}, nil
+}
+
+func (r *Redis) TryAcquire(ctx context.Context, key string, limit int) (func(), bool, error) {
+	rkey := "inflight:" + key
+	holder := uuid.New().String()
+	ok, err := acquireLua.Run(ctx, r.client, []string{rkey}, limit, time.Now().UnixMilli(), lease.Milliseconds(), holder).Int()
+	if err != nil || ok != 1 {
+		return nil, false, err
+	}
+	return func() {
+		r.client.ZRem(context.Background(), rkey, holder)
+	}, true, nil
+}
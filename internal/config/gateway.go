@@ -0,0 +1,261 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ModelPricing is a model's rate per million tokens, as stored in
+// model_pricing.
+type ModelPricing struct {
+	Model        string  `yaml:"model"`
+	Provider     string  `yaml:"provider"`
+	InputRate1M  float64 `yaml:"input_rate_1m"`
+	OutputRate1M float64 `yaml:"output_rate_1m"`
+}
+
+// settingKeys maps the scalar settings of a gateway file, by section and
+// key, to the environment variables they stand in for.
+var settingKeys = map[string]map[string]string{
+	"server": {
+		"env":                          "GATEWAY_ENV",
+		"port":                         "PORT",
+		"database_url":                 "DATABASE_URL",
+		"redis_url":                    "REDIS_URL",
+		"compress_min_bytes":           "RESPONSE_COMPRESS_MIN_BYTES",
+		"stream_resume_window_seconds": "STREAM_RESUME_WINDOW_SECONDS",
+		"kv_store":                     "KV_STORE",
+		"kv_memory_max_entries":        "KV_MEMORY_MAX_ENTRIES",
+		"routes_poll_seconds":          "ROUTES_POLL_SECONDS",
+		"cluster_coordination":         "CLUSTER_COORDINATION",
+		"usage_staging":                "USAGE_STAGING",
+		"ready_critical":               "READY_CRITICAL",
+		"startup_requires":             "STARTUP_REQUIRES",
+		"startup_timeout_seconds":      "STARTUP_TIMEOUT_SECONDS",
+		"webhook_max_attempts":         "WEBHOOK_MAX_ATTEMPTS",
+	},
+	"limits": {
+		"tokens_per_minute":           "TOKENS_PER_MINUTE",
+		"rate_limit_store":            "RATE_LIMIT_STORE",
+		"retry_budget_ratio":          "RETRY_BUDGET_RATIO",
+		"retry_budget_window_seconds": "RETRY_BUDGET_WINDOW_SECONDS",
+		"retry_budget_min_retries":    "RETRY_BUDGET_MIN_RETRIES",
+		"circuit_failure_threshold":   "CIRCUIT_FAILURE_THRESHOLD",
+		"circuit_cooldown_seconds":    "CIRCUIT_COOLDOWN_SECONDS",
+	},
+	"tracing": {
+		"sample_rate":   "TRACE_SAMPLE_RATE",
+		"snippet_chars": "TRACE_SNIPPET_CHARS",
+	},
+	"metrics": {
+		"spend_max_tenants": "SPEND_METRICS_MAX_TENANTS",
+		"spend_max_models":  "SPEND_METRICS_MAX_MODELS",
+		"spend_top":         "SPEND_METRICS_TOP",
+	},
+	"auth": {
+		"mode":        "AUTH_MODE",
+		"admin_token": "ADMIN_TOKEN",
+		"jwt_secret":  "JWT_SECRET",
+	},
+	"secrets": {
+		"key_file":    "CONFIG_SECRETS_KEY_FILE",
+		"age_command": "CONFIG_SECRETS_AGE_COMMAND",
+		"kms_command": "CONFIG_SECRETS_KMS_COMMAND",
+	},
+	"payloads": {
+		"capture":    "PAYLOAD_CAPTURE",
+		"kms":        "PAYLOAD_KMS",
+		"master_key": "PAYLOAD_MASTER_KEY",
+	},
+	"reports": {
+		"sink":              "REPORT_SINK",
+		"format":            "REPORT_FORMAT",
+		"dir":               "REPORT_DIR",
+		"webhook_url":       "REPORT_WEBHOOK_URL",
+		"endpoint":          "REPORT_ENDPOINT",
+		"region":            "REPORT_REGION",
+		"bucket":            "REPORT_BUCKET",
+		"prefix":            "REPORT_PREFIX",
+		"access_key_id":     "REPORT_ACCESS_KEY_ID",
+		"secret_access_key": "REPORT_SECRET_ACCESS_KEY",
+		"cost_center_key":   "REPORT_COST_CENTER_KEY",
+	},
+	"archive": {
+		"partition_months_ahead": "PARTITION_MONTHS_AHEAD",
+		"after_months":           "ARCHIVE_AFTER_MONTHS",
+		"drop_after_days":        "ARCHIVE_DROP_AFTER_DAYS",
+		"sink":                   "ARCHIVE_SINK",
+		"dir":                    "ARCHIVE_DIR",
+		"endpoint":               "ARCHIVE_ENDPOINT",
+		"region":                 "ARCHIVE_REGION",
+		"bucket":                 "ARCHIVE_BUCKET",
+		"prefix":                 "ARCHIVE_PREFIX",
+		"access_key_id":          "ARCHIVE_ACCESS_KEY_ID",
+		"secret_access_key":      "ARCHIVE_SECRET_ACCESS_KEY",
+	},
+	"reconcile": {
+		"openai_admin_key":    "OPENAI_ADMIN_KEY",
+		"anthropic_admin_key": "ANTHROPIC_ADMIN_KEY",
+		"lookback_days":       "RECONCILE_LOOKBACK_DAYS",
+		"threshold":           "RECONCILE_THRESHOLD",
+	},
+	"replay": {
+		"mode": "REPLAY_MODE",
+		"dir":  "REPLAY_DIR",
+	},
+	"synthetic": {
+		"latency_dist":   "SYNTHETIC_LATENCY_DIST",
+		"latency_ms":     "SYNTHETIC_LATENCY_MS",
+		"jitter_ms":      "SYNTHETIC_JITTER_MS",
+		"error_rate":     "SYNTHETIC_ERROR_RATE",
+		"tokens":         "SYNTHETIC_TOKENS",
+		"token_delay_ms": "SYNTHETIC_TOKEN_DELAY_MS",
+	},
+}
+
+// gatewayFile is a loaded gateway file: the sections of the routes file,
+// model pricing, and the scalar settings by environment variable name.
+type gatewayFile struct {
+	routesFile `yaml:",inline"`
+	Pricing    []ModelPricing `yaml:"pricing"`
+
+	settings settings
+}
+
+// loadGatewayFiles reads and layers the gateway files at paths. Mappings
+// are merged key by key, so a later file only needs the values it
+// changes; any other value, lists included, replaces the earlier one.
+func loadGatewayFiles(paths []string) (*gatewayFile, error) {
+	var merged *yaml.Node
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s: expected a mapping at the top level", path)
+		}
+		interpolate(root)
+		if merged == nil {
+			merged = root
+		} else {
+			mergeNodes(merged, root)
+		}
+	}
+
+	gw := &gatewayFile{settings: settings{}}
+	if merged == nil {
+		return gw, nil
+	}
+	// Lift the scalar settings out before decoding the rest.
+	rest := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i+1 < len(merged.Content); i += 2 {
+		section, value := merged.Content[i].Value, merged.Content[i+1]
+		keys, ok := settingKeys[section]
+		if !ok {
+			rest.Content = append(rest.Content, merged.Content[i], value)
+			continue
+		}
+		if value.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s: expected a mapping", section)
+		}
+		for j := 0; j+1 < len(value.Content); j += 2 {
+			key, v := value.Content[j].Value, value.Content[j+1]
+			name, ok := keys[key]
+			if !ok {
+				return nil, fmt.Errorf("unknown setting %s.%s", section, key)
+			}
+			s, err := settingValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", section, key, err)
+			}
+			gw.settings[name] = s
+		}
+	}
+	if err := rest.Decode(gw); err != nil {
+		return nil, err
+	}
+	return gw, nil
+}
+
+// settingValue renders a scalar, or a list of scalars joined by commas, as
+// the environment variable would hold it.
+func settingValue(n *yaml.Node) (string, error) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return n.Value, nil
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(n.Content))
+		for _, item := range n.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("expected a list of scalars")
+			}
+			items = append(items, item.Value)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("expected a scalar or a list")
+}
+
+// mergeNodes merges the mapping src into dst.
+func mergeNodes(dst, src *yaml.Node) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		found := false
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value != key.Value {
+				continue
+			}
+			found = true
+			if dst.Content[j+1].Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+				mergeNodes(dst.Content[j+1], value)
+			} else {
+				dst.Content[j+1] = value
+			}
+			break
+		}
+		if !found {
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+}
+
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolate replaces ${NAME} in scalar values with the environment
+// variable, or with the default in ${NAME:-default} when it is unset or
+// empty. Unquoted values are re-typed afterwards, so ${PORT} can fill an
+// integer field.
+func interpolate(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode {
+		if !strings.Contains(n.Value, "${") {
+			return
+		}
+		n.Value = envRef.ReplaceAllStringFunc(n.Value, func(ref string) string {
+			m := envRef.FindStringSubmatch(ref)
+			if val := os.Getenv(m[1]); val != "" {
+				return val
+			}
+			return m[2]
+		})
+		if n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) == 0 {
+			n.Tag = ""
+		}
+		return
+	}
+	for _, c := range n.Content {
+		interpolate(c)
+	}
+}
@@ -0,0 +1,256 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/secrets"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadGatewayConfig(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "gateway.yaml", `
+server:
+  port: ${GW_TEST_PORT:-9090}
+  ready_critical: [postgres, redis]
+limits:
+  tokens_per_minute: 1000
+providers:
+  openai:
+    api_key: ${GW_TEST_OPENAI_KEY}
+    headers:
+      X-Team: core
+pricing:
+  - model: gpt-4o
+    provider: openai
+    input_rate_1m: 2.5
+    output_rate_1m: 10
+routes:
+  - name: default
+    match:
+      use_case: default
+    primary:
+      provider: openai
+      model: gpt-4o
+    timeout_ms: ${GW_TEST_TIMEOUT}
+`)
+	override := writeFile(t, dir, "gateway.prod.yaml", `
+limits:
+  rate_limit_store: postgres
+providers:
+  openai:
+    base_url: https://proxy.internal/v1
+`)
+	t.Setenv("GW_TEST_OPENAI_KEY", "sk-test")
+	t.Setenv("GW_TEST_TIMEOUT", "5000")
+	t.Setenv("GATEWAY_CONFIG", base+","+override)
+	t.Setenv("TOKENS_PER_MINUTE", "2000")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9090" {
+		t.Errorf("expected the interpolation default, got port %q", cfg.Port)
+	}
+	if cfg.TPM != 2000 {
+		t.Errorf("expected the environment to win over the file, got %d", cfg.TPM)
+	}
+	if cfg.RateLimitStore != "postgres" {
+		t.Errorf("expected the later file to add its setting, got %q", cfg.RateLimitStore)
+	}
+	if len(cfg.ReadyCritical) != 2 {
+		t.Errorf("got ready_critical %v", cfg.ReadyCritical)
+	}
+	openai := cfg.Providers["openai"]
+	if openai.APIKey() != "sk-test" || openai.BaseURL != "https://proxy.internal/v1" || openai.Headers["X-Team"] != "core" {
+		t.Errorf("expected layered provider options, got %+v", openai)
+	}
+	if len(cfg.Routes) != 1 || cfg.Routes[0].TimeoutMS != 5000 {
+		t.Errorf("expected an interpolated integer, got %+v", cfg.Routes)
+	}
+	if len(cfg.Pricing) != 1 || cfg.Pricing[0].OutputRate1M != 10 {
+		t.Errorf("got pricing %+v", cfg.Pricing)
+	}
+}
+
+func TestLoadGatewayConfigUnknownSetting(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "gateway.yaml", "server:\n  prot: 8080\n")
+	t.Setenv("GATEWAY_CONFIG", path)
+	if _, err := LoadConfig(); err == nil {
+		t.Error("expected a misspelled setting to be rejected")
+	}
+}
+
+func TestLoadGatewayConfigExample(t *testing.T) {
+	t.Setenv("GATEWAY_CONFIG", "../../configs/gateway.example.yaml")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Routes) == 0 || len(cfg.Pricing) != 1 {
+		t.Errorf("routes = %d, pricing = %d", len(cfg.Routes), len(cfg.Pricing))
+	}
+}
+
+func TestLoadGatewayConfigProfile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "gateway.yaml", `
+providers:
+  openai:
+    headers:
+      X-Team: core
+profiles:
+  dev:
+    providers:
+      openai:
+        mock: true
+      anthropic:
+        base_url: http://localhost:4010/v1
+        mock:
+          responses: [hello]
+  prod:
+    providers:
+      openai:
+        mock: false
+`)
+	t.Setenv("GATEWAY_CONFIG", path)
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+
+	t.Setenv("GATEWAY_ENV", "prod")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Providers["openai"].Mocked() {
+		t.Error("prod profile mocks openai")
+	}
+
+	t.Setenv("GATEWAY_ENV", "dev")
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	openai := cfg.Providers["openai"]
+	if !openai.Mocked() || openai.Headers["X-Team"] != "core" || openai.APIKey() != "sk-test" {
+		t.Errorf("openai = %+v", openai)
+	}
+	anthropic := cfg.Providers["anthropic"]
+	if anthropic.BaseURL != "http://localhost:4010/v1" || !anthropic.Mocked() || anthropic.Mock.Responses[0] != "hello" {
+		t.Errorf("anthropic = %+v", anthropic)
+	}
+
+	t.Setenv("GATEWAY_ENV", "qa")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("unknown profile accepted")
+	}
+}
+
+func TestLoadGatewayConfigEncryptedKeys(t *testing.T) {
+	dir := t.TempDir()
+	master := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	key, err := secrets.NewLocalKey(master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	openai, _ := key.Encrypt("sk-openai")
+	mistral, _ := key.Encrypt("sk-mistral")
+	path := writeFile(t, dir, "gateway.yaml", `
+secrets:
+  key_file: `+writeFile(t, dir, "secrets.key", master+"\n")+`
+providers:
+  openai:
+    api_key: `+openai+`
+  azure:
+    type: openai-compatible
+    base_url: http://localhost:4010/v1
+    api_key_env: AZURE_KEY
+`)
+	t.Setenv("GATEWAY_CONFIG", path)
+	t.Setenv("MISTRAL_API_KEY", mistral)
+	t.Setenv("AZURE_KEY", "plain")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Providers["openai"].APIKey(); got != "sk-openai" {
+		t.Errorf("openai key = %q", got)
+	}
+	if got := cfg.Providers["mistral"].APIKey(); got != "sk-mistral" {
+		t.Errorf("expected an encrypted environment key decrypted, got %q", got)
+	}
+	if got := cfg.Providers["azure"].APIKey(); got != "plain" {
+		t.Errorf("azure key = %q", got)
+	}
+
+	t.Setenv("MISTRAL_API_KEY", "enc:age:AAAA")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("expected a key of an unconfigured scheme to fail the load")
+	}
+}
+
+func TestLoadGatewayConfigAccounts(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := `
+providers:
+  openai:
+    organization: org-main
+    accounts:
+      research:
+        organization: org-research
+        project: proj-eval
+        api_key_env: GW_TEST_RESEARCH_KEY
+routes:
+  - name: evals
+    match:
+      use_case: evals
+    primary:
+      provider: openai
+      model: gpt-4o
+    accounts:
+      openai: research
+tenants:
+  - name: acme
+    accounts:
+      openai: %s
+`
+	t.Setenv("GW_TEST_RESEARCH_KEY", "sk-research")
+	t.Setenv("GATEWAY_CONFIG", writeFile(t, dir, "gateway.yaml", fmt.Sprintf(tmpl, "research")))
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := cfg.Providers["openai"]
+	if a := opts.Account(""); a.Organization != "org-main" || a.Project != "" {
+		t.Errorf("default account = %+v", a)
+	}
+	a := opts.Account(AccountFor(cfg.Routes[0], Tenant{}, "openai"))
+	if a.Organization != "org-research" || a.Project != "proj-eval" || a.APIKey() != "sk-research" {
+		t.Errorf("route account = %+v", a)
+	}
+	if got := AccountFor(Route{}, cfg.Tenants[0], "openai"); got != "research" {
+		t.Errorf("tenant account = %q", got)
+	}
+	if got := AccountFor(Route{Accounts: map[string]string{"openai": ""}}, cfg.Tenants[0], "openai"); got != "" {
+		t.Errorf("expected the route's mapping to win over the tenant's, got %q", got)
+	}
+
+	t.Setenv("GATEWAY_CONFIG", writeFile(t, dir, "gateway.yaml", fmt.Sprintf(tmpl, "marketing")))
+	if _, err := LoadConfig(); err == nil {
+		t.Error("expected a tenant naming an unknown account to fail the load")
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/secrets"
+)
+
+// Secrets configure how encrypted provider keys ("enc:<scheme>:<base64>")
+// are decrypted at startup. The local scheme needs the base64 master key,
+// given as Key or in the file KeyFile; the age and kms schemes run
+// AgeCommand and KMSCommand with the ciphertext on stdin.
+type Secrets struct {
+	Key        string
+	KeyFile    string
+	AgeCommand string
+	KMSCommand string
+}
+
+// Resolver returns the decrypters of the configured schemes.
+func (s Secrets) Resolver() (secrets.Resolver, error) {
+	r := secrets.Resolver{}
+	key := s.Key
+	if s.KeyFile != "" {
+		data, err := os.ReadFile(s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secrets key: %w", err)
+		}
+		key = string(data)
+	}
+	if key != "" {
+		local, err := secrets.NewLocalKey(key)
+		if err != nil {
+			return nil, err
+		}
+		r["local"] = local
+	}
+	if s.AgeCommand != "" {
+		r["age"] = secrets.Command(s.AgeCommand)
+	}
+	if s.KMSCommand != "" {
+		r["kms"] = secrets.Command(s.KMSCommand)
+	}
+	return r, nil
+}
+
+// decryptTimeout bounds the decryption of all provider keys.
+const decryptTimeout = 30 * time.Second
+
+// decryptProviderKeys replaces encrypted provider and account keys,
+// whether set in a config file or in the variable an api_key_env names,
+// with their plaintext.
+func (cfg *Config) decryptProviderKeys() error {
+	var resolver secrets.Resolver
+	ctx, cancel := context.WithTimeout(context.Background(), decryptTimeout)
+	defer cancel()
+	decrypt := func(key, keyEnv string) (string, error) {
+		if key == "" && keyEnv != "" {
+			key = os.Getenv(keyEnv)
+		}
+		if !secrets.IsEncrypted(key) {
+			return "", nil
+		}
+		if resolver == nil {
+			var err error
+			if resolver, err = cfg.Secrets.Resolver(); err != nil {
+				return "", err
+			}
+		}
+		return resolver.Resolve(ctx, key)
+	}
+	for name, opts := range cfg.Providers {
+		key, err := decrypt(opts.Key, opts.APIKeyEnv)
+		if err != nil {
+			return fmt.Errorf("provider %s: api key: %w", name, err)
+		}
+		if key != "" {
+			opts.Key = key
+		}
+		accounts := make(map[string]ProviderAccount, len(opts.Accounts))
+		for account, a := range opts.Accounts {
+			key, err := decrypt(a.Key, a.APIKeyEnv)
+			if err != nil {
+				return fmt.Errorf("provider %s: account %s: api key: %w", name, account, err)
+			}
+			if key != "" {
+				a.Key = key
+			}
+			accounts[account] = a
+		}
+		if opts.Accounts != nil {
+			opts.Accounts = accounts
+		}
+		cfg.Providers[name] = opts
+	}
+	return nil
+}
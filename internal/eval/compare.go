@@ -0,0 +1,72 @@
+package eval
+
+import (
+	"fmt"
+
+	"github.com/yewintnaing/ai-gateway/internal/classify"
+)
+
+// Comparison sets a candidate run beside a base run of the same dataset.
+type Comparison struct {
+	Base         Run              `json:"base"`
+	Candidate    Run              `json:"candidate"`
+	ScoreDelta   float64          `json:"score_delta"`
+	PassedDelta  int              `json:"passed_delta"`
+	LatencyDelta int              `json:"avg_latency_ms_delta"`
+	CostDelta    float64          `json:"cost_usd_delta"`
+	Regressions  []string         `json:"regressions"`  // cases that passed in base only
+	Improvements []string         `json:"improvements"` // cases that passed in candidate only
+	Cases        []CaseComparison `json:"cases"`
+}
+
+// CaseComparison is one case's outcome in both runs.
+type CaseComparison struct {
+	Case            string  `json:"case"`
+	BaseScore       float64 `json:"base_score"`
+	CandidateScore  float64 `json:"candidate_score"`
+	BasePassed      bool    `json:"base_passed"`
+	CandidatePassed bool    `json:"candidate_passed"`
+	// Agreement is the similarity of the two completions, from 0 to 1.
+	Agreement float64 `json:"agreement"`
+}
+
+// Compare compares two runs with their results, case by case. Cases only
+// one run has, such as those of an unfinished run, are left out.
+func Compare(base, candidate Run) (Comparison, error) {
+	if base.Dataset != candidate.Dataset {
+		return Comparison{}, fmt.Errorf("runs of datasets %s and %s cannot be compared", base.Dataset, candidate.Dataset)
+	}
+	cmp := Comparison{
+		ScoreDelta:   candidate.Score - base.Score,
+		PassedDelta:  candidate.Passed - base.Passed,
+		LatencyDelta: candidate.AvgLatencyMS - base.AvgLatencyMS,
+		CostDelta:    candidate.CostUSD - base.CostUSD,
+		Regressions:  []string{},
+		Improvements: []string{},
+		Cases:        []CaseComparison{},
+	}
+	byCase := make(map[string]Result, len(candidate.Results))
+	for _, r := range candidate.Results {
+		byCase[r.Case] = r
+	}
+	for _, b := range base.Results {
+		c, ok := byCase[b.Case]
+		if !ok {
+			continue
+		}
+		cmp.Cases = append(cmp.Cases, CaseComparison{
+			Case: b.Case, BaseScore: b.Score, CandidateScore: c.Score,
+			BasePassed: b.Passed, CandidatePassed: c.Passed,
+			Agreement: classify.Similarity(classify.Embed(b.Content), classify.Embed(c.Content)),
+		})
+		switch {
+		case b.Passed && !c.Passed:
+			cmp.Regressions = append(cmp.Regressions, b.Case)
+		case !b.Passed && c.Passed:
+			cmp.Improvements = append(cmp.Improvements, b.Case)
+		}
+	}
+	base.Results, candidate.Results = nil, nil
+	cmp.Base, cmp.Candidate = base, candidate
+	return cmp, nil
+}
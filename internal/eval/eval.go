@@ -0,0 +1,294 @@
+// Package eval runs golden datasets, prompts with the properties their
+// completions should have, against routes or models and scores the
+// results, so that a model change can be compared with what a route uses
+// today before it is rolled out.
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yewintnaing/ai-gateway/internal/classify"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// MaxCases bounds the cases of one dataset.
+const MaxCases = 1000
+
+// defaultMinSimilarity is how close a completion must be to a case's
+// expected answer when the case does not say.
+const defaultMinSimilarity = 0.8
+
+// Run statuses.
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// Dataset is a named set of cases.
+type Dataset struct {
+	Name  string `json:"name"`
+	Cases []Case `json:"cases"`
+}
+
+// Case is a prompt and the properties its completion should have. Every
+// property set is one check; a case's score is the fraction of its
+// checks that passed.
+type Case struct {
+	Name string `json:"name"`
+	// Prompt is sent as a user message, after Messages if both are set.
+	Prompt    string              `json:"prompt,omitempty"`
+	Messages  []providers.Message `json:"messages,omitempty"`
+	MaxTokens int                 `json:"max_tokens,omitempty"`
+	// Expected is a reference answer the completion is compared with.
+	Expected      string   `json:"expected,omitempty"`
+	MinSimilarity float64  `json:"min_similarity,omitempty"`
+	Contains      []string `json:"contains,omitempty"`
+	NotContains   []string `json:"not_contains,omitempty"`
+	Matches       string   `json:"matches,omitempty"`
+	JSON          bool     `json:"json,omitempty"`
+	MaxLatencyMS  int      `json:"max_latency_ms,omitempty"`
+}
+
+// Conversation is the messages the case sends.
+func (c Case) Conversation() []providers.Message {
+	messages := append([]providers.Message(nil), c.Messages...)
+	if c.Prompt != "" {
+		messages = append(messages, providers.Message{Role: "user", Content: c.Prompt})
+	}
+	return messages
+}
+
+// Target is what a dataset runs against: a route as configured, or the
+// route with Model (on Provider, by default the route's primary provider)
+// in place of its targets.
+type Target struct {
+	Route    string `json:"route"`
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// Completion is a case's answer from a target.
+type Completion struct {
+	Content          string
+	RequestID        string
+	Provider         string
+	Model            string
+	LatencyMS        int
+	PromptTokens     int
+	CompletionTokens int
+	CostUSD          float64
+}
+
+// Caller sends a case to target as part of run runID.
+type Caller func(ctx context.Context, runID string, target Target, c Case) (Completion, error)
+
+// Run is one dataset run against one target.
+type Run struct {
+	ID               string     `json:"id"`
+	Dataset          string     `json:"dataset"`
+	Route            string     `json:"route"`
+	Provider         string     `json:"provider,omitempty"`
+	Model            string     `json:"model,omitempty"`
+	Status           string     `json:"status"`
+	Error            string     `json:"error,omitempty"`
+	Cases            int        `json:"cases"`
+	Passed           int        `json:"passed"`
+	Score            float64    `json:"score"`
+	AvgLatencyMS     int        `json:"avg_latency_ms"`
+	PromptTokens     int        `json:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens"`
+	CostUSD          float64    `json:"cost_usd"`
+	CreatedAt        time.Time  `json:"created_at"`
+	FinishedAt       *time.Time `json:"finished_at,omitempty"`
+	Results          []Result   `json:"results,omitempty"`
+}
+
+// Result is a case's scored completion.
+type Result struct {
+	Case             string   `json:"case"`
+	RequestID        string   `json:"request_id,omitempty"`
+	Provider         string   `json:"provider,omitempty"`
+	Model            string   `json:"model,omitempty"`
+	Content          string   `json:"content"`
+	LatencyMS        int      `json:"latency_ms"`
+	PromptTokens     int      `json:"prompt_tokens"`
+	CompletionTokens int      `json:"completion_tokens"`
+	CostUSD          float64  `json:"cost_usd"`
+	Score            float64  `json:"score"`
+	Passed           bool     `json:"passed"`
+	Similarity       *float64 `json:"similarity,omitempty"`
+	Failures         []string `json:"failures,omitempty"`
+}
+
+// Store keeps runs and their results.
+type Store interface {
+	CreateEvalRun(ctx context.Context, run Run) error
+	RecordEvalResult(ctx context.Context, runID string, r Result) error
+	FinishEvalRun(ctx context.Context, run Run) error
+}
+
+// Runner runs datasets.
+type Runner struct {
+	store Store
+	call  Caller
+	now   func() time.Time
+}
+
+// New returns a runner that sends cases with call.
+func New(store Store, call Caller) *Runner {
+	return &Runner{store: store, call: call, now: time.Now}
+}
+
+// Validate checks that every case can be sent and scored.
+func (d Dataset) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("dataset needs a name")
+	}
+	if len(d.Cases) == 0 || len(d.Cases) > MaxCases {
+		return fmt.Errorf("dataset needs 1 to %d cases", MaxCases)
+	}
+	seen := map[string]bool{}
+	for _, c := range d.Cases {
+		if c.Name == "" {
+			return fmt.Errorf("every case needs a name")
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("case %s: duplicate name", c.Name)
+		}
+		seen[c.Name] = true
+		if len(c.Conversation()) == 0 {
+			return fmt.Errorf("case %s: needs a prompt or messages", c.Name)
+		}
+		if c.Matches != "" {
+			if _, err := regexp.Compile(c.Matches); err != nil {
+				return fmt.Errorf("case %s: matches: %w", c.Name, err)
+			}
+		}
+		if c.MinSimilarity < 0 || c.MinSimilarity > 1 {
+			return fmt.Errorf("case %s: min_similarity must be between 0 and 1", c.Name)
+		}
+	}
+	return nil
+}
+
+// Start records a run of ds for each target and runs them in the
+// background, the targets side by side and each one's cases in order.
+// The runs are returned as started; their results are in the store.
+func (r *Runner) Start(ctx context.Context, ds Dataset, targets []Target) ([]Run, error) {
+	if err := ds.Validate(); err != nil {
+		return nil, err
+	}
+	runs := make([]Run, 0, len(targets))
+	for _, t := range targets {
+		run := Run{
+			ID: uuid.New().String(), Dataset: ds.Name, Route: t.Route, Provider: t.Provider, Model: t.Model,
+			Status: StatusRunning, Cases: len(ds.Cases), CreatedAt: r.now().UTC(),
+		}
+		if err := r.store.CreateEvalRun(ctx, run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	for i := range runs {
+		go r.Run(context.WithoutCancel(ctx), ds, runs[i], targets[i])
+	}
+	return runs, nil
+}
+
+// Run sends every case of ds to target, scores and records the results,
+// and records the run's totals when it finishes.
+func (r *Runner) Run(ctx context.Context, ds Dataset, run Run, target Target) Run {
+	var latency int
+	for _, c := range ds.Cases {
+		if err := ctx.Err(); err != nil {
+			run.Status, run.Error = StatusFailed, err.Error()
+			break
+		}
+		completion, err := r.call(ctx, run.ID, target, c)
+		res := Score(c, completion, err)
+		if err := r.store.RecordEvalResult(ctx, run.ID, res); err != nil {
+			log.Printf("Warning: failed to record eval result %s of run %s: %v", c.Name, run.ID, err)
+		}
+		run.Results = append(run.Results, res)
+		if res.Passed {
+			run.Passed++
+		}
+		run.Score += res.Score
+		latency += res.LatencyMS
+		run.PromptTokens += res.PromptTokens
+		run.CompletionTokens += res.CompletionTokens
+		run.CostUSD += res.CostUSD
+	}
+	if n := len(run.Results); n > 0 {
+		run.Score /= float64(n)
+		run.AvgLatencyMS = latency / n
+	}
+	if run.Status == StatusRunning {
+		run.Status = StatusCompleted
+	}
+	finished := r.now().UTC()
+	run.FinishedAt = &finished
+	if err := r.store.FinishEvalRun(ctx, run); err != nil {
+		log.Printf("Warning: failed to record eval run %s: %v", run.ID, err)
+	}
+	return run
+}
+
+// Score checks completion against c. A failed call scores 0.
+func Score(c Case, completion Completion, err error) Result {
+	res := Result{
+		Case: c.Name, RequestID: completion.RequestID, Provider: completion.Provider, Model: completion.Model,
+		Content: completion.Content, LatencyMS: completion.LatencyMS,
+		PromptTokens: completion.PromptTokens, CompletionTokens: completion.CompletionTokens, CostUSD: completion.CostUSD,
+	}
+	if err != nil {
+		res.Failures = []string{err.Error()}
+		return res
+	}
+	checks := 0
+	check := func(ok bool, failure string, args ...interface{}) {
+		checks++
+		if !ok {
+			res.Failures = append(res.Failures, fmt.Sprintf(failure, args...))
+		}
+	}
+	content := completion.Content
+	for _, s := range c.Contains {
+		check(strings.Contains(content, s), "does not contain %q", s)
+	}
+	for _, s := range c.NotContains {
+		check(!strings.Contains(content, s), "contains %q", s)
+	}
+	if c.Matches != "" {
+		check(regexp.MustCompile(c.Matches).MatchString(content), "does not match %q", c.Matches)
+	}
+	if c.JSON {
+		check(json.Valid([]byte(strings.TrimSpace(content))), "is not valid JSON")
+	}
+	if c.Expected != "" {
+		similarity := classify.Similarity(classify.Embed(c.Expected), classify.Embed(content))
+		res.Similarity = &similarity
+		min := c.MinSimilarity
+		if min == 0 {
+			min = defaultMinSimilarity
+		}
+		check(similarity >= min, "similarity %.2f to the expected answer is under %.2f", similarity, min)
+	}
+	if c.MaxLatencyMS > 0 {
+		check(completion.LatencyMS <= c.MaxLatencyMS, "took %dms, over %dms", completion.LatencyMS, c.MaxLatencyMS)
+	}
+	res.Passed = len(res.Failures) == 0
+	res.Score = 1
+	if checks > 0 {
+		res.Score = float64(checks-len(res.Failures)) / float64(checks)
+	}
+	return res
+}
@@ -0,0 +1,153 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeStore struct {
+	created  []Run
+	results  map[string][]Result
+	finished []Run
+}
+
+func (f *fakeStore) CreateEvalRun(ctx context.Context, run Run) error {
+	f.created = append(f.created, run)
+	return nil
+}
+
+func (f *fakeStore) RecordEvalResult(ctx context.Context, runID string, r Result) error {
+	if f.results == nil {
+		f.results = map[string][]Result{}
+	}
+	f.results[runID] = append(f.results[runID], r)
+	return nil
+}
+
+func (f *fakeStore) FinishEvalRun(ctx context.Context, run Run) error {
+	f.finished = append(f.finished, run)
+	return nil
+}
+
+func TestScore(t *testing.T) {
+	c := Case{
+		Name: "json", Contains: []string{`"city"`}, NotContains: []string{"sorry"},
+		Matches: `Paris`, JSON: true, MaxLatencyMS: 1000,
+	}
+	res := Score(c, Completion{Content: `{"city": "Paris"}`, LatencyMS: 200}, nil)
+	if !res.Passed || res.Score != 1 {
+		t.Errorf("expected every check to pass, got %+v", res)
+	}
+
+	res = Score(c, Completion{Content: `sorry, the city is Paris`, LatencyMS: 1500}, nil)
+	if res.Passed || len(res.Failures) != 4 || res.Score != 0.2 {
+		t.Errorf("expected 4 of 5 checks to fail, got %+v", res)
+	}
+
+	res = Score(Case{Name: "free"}, Completion{Content: "anything"}, nil)
+	if !res.Passed || res.Score != 1 {
+		t.Errorf("expected a case without checks to pass, got %+v", res)
+	}
+
+	res = Score(c, Completion{RequestID: "req-1"}, errors.New("every target failed"))
+	if res.Passed || res.Score != 0 || res.RequestID != "req-1" || res.Failures[0] != "every target failed" {
+		t.Errorf("expected a failed call to score 0, got %+v", res)
+	}
+}
+
+func TestScoreExpected(t *testing.T) {
+	c := Case{Name: "capital", Expected: "The capital of France is Paris."}
+	res := Score(c, Completion{Content: "The capital of France is Paris."}, nil)
+	if !res.Passed || res.Similarity == nil || *res.Similarity < 0.99 {
+		t.Errorf("expected an identical answer to pass, got %+v", res)
+	}
+	res = Score(c, Completion{Content: "Quarterly revenue grew by twelve percent."}, nil)
+	if res.Passed || res.Similarity == nil || *res.Similarity >= defaultMinSimilarity {
+		t.Errorf("expected an unrelated answer to fail, got %+v", res)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ds := Dataset{Name: "smoke", Cases: []Case{
+		{Name: "a", Prompt: "say a", Contains: []string{"a"}},
+		{Name: "b", Prompt: "say b", Contains: []string{"b"}},
+		{Name: "c", Prompt: "say c"},
+	}}
+	call := func(ctx context.Context, runID string, target Target, c Case) (Completion, error) {
+		if c.Name == "c" {
+			return Completion{}, errors.New("upstream 503")
+		}
+		return Completion{Content: "a", LatencyMS: 100, PromptTokens: 10, CompletionTokens: 5, CostUSD: 0.01}, nil
+	}
+	store := &fakeStore{}
+	r := New(store, call)
+	run := r.Run(context.Background(), ds, Run{ID: "run-1", Dataset: "smoke", Status: StatusRunning, Cases: 3}, Target{Route: "chat"})
+
+	if run.Status != StatusCompleted || run.Passed != 1 || run.FinishedAt == nil {
+		t.Fatalf("unexpected run %+v", run)
+	}
+	if run.Score != 1.0/3 || run.AvgLatencyMS != 66 || run.PromptTokens != 20 || run.CostUSD != 0.02 {
+		t.Errorf("unexpected totals %+v", run)
+	}
+	if len(store.results["run-1"]) != 3 || len(store.finished) != 1 {
+		t.Errorf("expected 3 results and the run recorded, got %v and %v", store.results, store.finished)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := New(&fakeStore{}, nil)
+	run := r.Run(ctx, Dataset{Name: "smoke", Cases: []Case{{Name: "a", Prompt: "x"}}}, Run{ID: "run-1", Status: StatusRunning}, Target{})
+	if run.Status != StatusFailed || run.Error == "" {
+		t.Errorf("expected a failed run, got %+v", run)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, ds := range []Dataset{
+		{Cases: []Case{{Name: "a", Prompt: "x"}}},
+		{Name: "d"},
+		{Name: "d", Cases: []Case{{Prompt: "x"}}},
+		{Name: "d", Cases: []Case{{Name: "a"}}},
+		{Name: "d", Cases: []Case{{Name: "a", Prompt: "x"}, {Name: "a", Prompt: "y"}}},
+		{Name: "d", Cases: []Case{{Name: "a", Prompt: "x", Matches: "("}}},
+		{Name: "d", Cases: []Case{{Name: "a", Prompt: "x", MinSimilarity: 2}}},
+	} {
+		if err := ds.Validate(); err == nil {
+			t.Errorf("expected %+v to be refused", ds)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := Run{ID: "base", Dataset: "smoke", Passed: 2, Score: 0.75, AvgLatencyMS: 500, CostUSD: 0.10, Results: []Result{
+		{Case: "a", Passed: true, Score: 1, Content: "yes"},
+		{Case: "b", Passed: true, Score: 1, Content: "yes"},
+		{Case: "c", Passed: false, Score: 0.5, Content: "no"},
+	}}
+	candidate := Run{ID: "candidate", Dataset: "smoke", Passed: 2, Score: 0.5, AvgLatencyMS: 300, CostUSD: 0.04, Results: []Result{
+		{Case: "a", Passed: true, Score: 1, Content: "yes"},
+		{Case: "b", Passed: false, Score: 0, Content: "no"},
+		{Case: "c", Passed: true, Score: 1, Content: "yes"},
+	}}
+	cmp, err := Compare(base, candidate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmp.Regressions) != 1 || cmp.Regressions[0] != "b" || len(cmp.Improvements) != 1 || cmp.Improvements[0] != "c" {
+		t.Errorf("unexpected regressions %v and improvements %v", cmp.Regressions, cmp.Improvements)
+	}
+	if cmp.ScoreDelta != -0.25 || cmp.LatencyDelta != -200 || cmp.PassedDelta != 0 {
+		t.Errorf("unexpected deltas %+v", cmp)
+	}
+	if len(cmp.Cases) != 3 || cmp.Cases[0].Agreement < 0.99 || cmp.Base.Results != nil {
+		t.Errorf("unexpected cases %+v", cmp.Cases)
+	}
+
+	candidate.Dataset = "other"
+	if _, err := Compare(base, candidate); err == nil {
+		t.Error("expected runs of different datasets to be refused")
+	}
+}
@@ -0,0 +1,148 @@
+package governance
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// moderationCategories are the built-in fast checks: phrase patterns that
+// are cheap enough to run on every streamed chunk. They are deliberately
+// narrow; routes add their own terms for anything domain specific.
+var moderationCategories = map[string]string{
+	"self_harm": `(?i)\b(?:kill (?:myself|yourself)|end (?:my|your) life|(?:commit|commits|committing) suicide|suicide (?:method|methods|note)|ways to (?:self[- ]harm|hurt (?:myself|yourself)))\b`,
+	"violence":  `(?i)\b(?:(?:make|build|assemble) (?:a )?(?:pipe )?(?:bomb|explosive device)s?|mass shooting|kill (?:him|her|them) (?:with|using))\b`,
+	"weapons":   `(?i)\b(?:(?:3d[- ]print(?:ed)?|untraceable) (?:gun|firearm)s?|ghost gun|convert (?:a |an )?(?:ar-15|rifle|pistol) to (?:full[- ]auto|automatic))\b`,
+}
+
+// moderationWindow is the default number of characters a stream moderator
+// holds back so that a phrase split across chunks is still seen whole.
+const moderationWindow = 128
+
+type moderationCheck struct {
+	category string
+	re       *regexp.Regexp
+}
+
+// Moderator runs fast content checks on model output.
+type Moderator struct {
+	checks []moderationCheck
+	window int
+}
+
+// NewModerator compiles the built-in categories plus extra terms, which are
+// matched case-insensitively as whole words or phrases under the "custom"
+// category. window is how many characters a stream holds back (0 for the
+// default).
+func NewModerator(categories, terms []string, window int) (*Moderator, error) {
+	m := &Moderator{window: window}
+	if m.window <= 0 {
+		m.window = moderationWindow
+	}
+	for _, c := range categories {
+		pattern, ok := moderationCategories[c]
+		if !ok {
+			return nil, fmt.Errorf("unknown moderation category %q", c)
+		}
+		m.checks = append(m.checks, moderationCheck{category: c, re: regexp.MustCompile(pattern)})
+	}
+	var quoted []string
+	for _, t := range terms {
+		if t = strings.TrimSpace(t); t != "" {
+			quoted = append(quoted, regexp.QuoteMeta(t))
+		}
+	}
+	if len(quoted) > 0 {
+		re, err := regexp.Compile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+		if err != nil {
+			return nil, err
+		}
+		m.checks = append(m.checks, moderationCheck{category: "custom", re: re})
+	}
+	if len(m.checks) == 0 {
+		return nil, fmt.Errorf("moderation needs at least one category or term")
+	}
+	return m, nil
+}
+
+// Check returns the categories text violates.
+func (m *Moderator) Check(text string) []string {
+	var found []string
+	for _, c := range m.checks {
+		if c.re.MatchString(text) {
+			found = append(found, c.category)
+		}
+	}
+	return found
+}
+
+// Redact replaces disallowed content with [MODERATED] and returns the
+// categories found.
+func (m *Moderator) Redact(text string) (string, []string) {
+	var found []string
+	for _, c := range m.checks {
+		if c.re.MatchString(text) {
+			found = append(found, c.category)
+			text = c.re.ReplaceAllString(text, "[MODERATED]")
+		}
+	}
+	return text, found
+}
+
+// StreamModerator applies a Moderator to a stream of deltas over a sliding
+// window: the last window characters are held back until later text shows
+// whether they complete a disallowed phrase.
+type StreamModerator struct {
+	m     *Moderator
+	buf   strings.Builder
+	Found []string
+}
+
+func (m *Moderator) NewStream() *StreamModerator {
+	return &StreamModerator{m: m}
+}
+
+// Push adds a delta and returns the redacted text that is safe to emit now.
+// Violations reports the categories found anywhere in the window, including
+// text still held back, so a stream can be cut off before any of a
+// disallowed phrase reaches the client.
+func (sm *StreamModerator) Push(delta string) (out string, violations []string) {
+	sm.buf.WriteString(delta)
+	text := sm.buf.String()
+	violations = sm.m.Check(text)
+
+	hold := len(text) - sm.m.window
+	for hold > 0 && !utf8.RuneStart(text[hold]) {
+		hold--
+	}
+	if hold <= 0 {
+		return "", violations
+	}
+	// Never release part of a match: move the cut to its start.
+	for moved := true; moved; {
+		moved = false
+		for _, c := range sm.m.checks {
+			for _, loc := range c.re.FindAllStringIndex(text, -1) {
+				if loc[0] < hold && loc[1] > hold {
+					hold, moved = loc[0], true
+				}
+			}
+		}
+	}
+
+	out, found := sm.m.Redact(text[:hold])
+	sm.Found = append(sm.Found, found...)
+	rest := text[hold:]
+	sm.buf.Reset()
+	sm.buf.WriteString(rest)
+	return out, violations
+}
+
+// Flush redacts and returns everything still held back.
+func (sm *StreamModerator) Flush() string {
+	out, found := sm.m.Redact(sm.buf.String())
+	sm.Found = append(sm.Found, found...)
+	sm.buf.Reset()
+	return out
+}
@@ -0,0 +1,71 @@
+package governance
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewModeratorRejectsUnknownCategory(t *testing.T) {
+	if _, err := NewModerator([]string{"gossip"}, nil, 0); err == nil {
+		t.Error("expected unknown category to be rejected")
+	}
+	if _, err := NewModerator(nil, nil, 0); err == nil {
+		t.Error("expected an empty moderator to be rejected")
+	}
+}
+
+func TestModerator_Redact(t *testing.T) {
+	m, err := NewModerator([]string{"violence"}, []string{"Project Falcon"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, found := m.Redact("Step one: build a pipe bomb. Also project falcon ships Monday.")
+	if len(found) != 2 || found[0] != "violence" || found[1] != "custom" {
+		t.Fatalf("expected violence and custom, got %v", found)
+	}
+	if strings.Contains(out, "bomb") || strings.Contains(strings.ToLower(out), "falcon") {
+		t.Errorf("expected both matches redacted, got %q", out)
+	}
+	if got := m.Check("a perfectly ordinary answer"); len(got) != 0 {
+		t.Errorf("expected no violations, got %v", got)
+	}
+}
+
+func TestStreamModerator_SplitPhrase(t *testing.T) {
+	m, _ := NewModerator(nil, []string{"launch codes"}, 16)
+	sm := m.NewStream()
+	deltas := []string{"Sure, the laun", "ch codes are listed below, along with a lot of other text to move the window."}
+
+	var out strings.Builder
+	var violated bool
+	for _, d := range deltas {
+		o, v := sm.Push(d)
+		out.WriteString(o)
+		violated = violated || len(v) > 0
+	}
+	out.WriteString(sm.Flush())
+
+	if !violated {
+		t.Error("expected the split phrase to be reported")
+	}
+	if strings.Contains(out.String(), "laun") {
+		t.Errorf("phrase leaked across chunk boundary: %q", out.String())
+	}
+	if len(sm.Found) != 1 || sm.Found[0] != "custom" {
+		t.Errorf("expected custom to be found, got %v", sm.Found)
+	}
+	if !strings.HasSuffix(out.String(), "move the window.") {
+		t.Errorf("expected trailing text to survive, got %q", out.String())
+	}
+}
+
+func TestStreamModerator_HoldsBackWindow(t *testing.T) {
+	m, _ := NewModerator(nil, []string{"forbidden"}, 10)
+	sm := m.NewStream()
+	if out, _ := sm.Push("short"); out != "" {
+		t.Errorf("expected text within the window to be held back, got %q", out)
+	}
+	if out, _ := sm.Push(" and then some more"); out != "short and then" {
+		t.Errorf("expected all but the window to be released, got %q", out)
+	}
+}
@@ -0,0 +1,119 @@
+package governance
+
+import (
+	"regexp"
+	"strings"
+)
+
+type SecretType string
+
+const (
+	SecretOpenAIKey    SecretType = "OPENAI_KEY"
+	SecretAnthropicKey SecretType = "ANTHROPIC_KEY"
+	SecretAWSKey       SecretType = "AWS_ACCESS_KEY"
+	SecretGitHubToken  SecretType = "GITHUB_TOKEN"
+	SecretSlackToken   SecretType = "SLACK_TOKEN"
+	SecretGoogleKey    SecretType = "GOOGLE_API_KEY"
+	SecretJWT          SecretType = "JWT"
+	SecretPrivateKey   SecretType = "PRIVATE_KEY"
+)
+
+// secretHoldback is how many trailing characters a stream scanner keeps back
+// so that a secret split across chunks is still seen whole.
+const secretHoldback = 64
+
+const privateKeyBegin = "-----BEGIN "
+
+// SecretScanner finds credentials in model output.
+type SecretScanner struct {
+	order    []SecretType
+	patterns map[SecretType]*regexp.Regexp
+}
+
+func NewSecretScanner() *SecretScanner {
+	s := &SecretScanner{
+		patterns: map[SecretType]*regexp.Regexp{
+			// Anthropic keys also start with sk-, so they are checked first.
+			SecretAnthropicKey: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`),
+			SecretOpenAIKey:    regexp.MustCompile(`\bsk-(?:proj-)?[A-Za-z0-9_-]{20,}`),
+			SecretAWSKey:       regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+			SecretGitHubToken:  regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`),
+			SecretSlackToken:   regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),
+			SecretGoogleKey:    regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`),
+			SecretJWT:          regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`),
+			SecretPrivateKey:   regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?(?:-----END [A-Z ]*PRIVATE KEY-----|\z)`),
+		},
+	}
+	s.order = []SecretType{SecretPrivateKey, SecretAnthropicKey, SecretOpenAIKey, SecretAWSKey, SecretGitHubToken, SecretSlackToken, SecretGoogleKey, SecretJWT}
+	return s
+}
+
+// Redact replaces every credential in text with [REDACTED_<TYPE>] and returns
+// the types found.
+func (s *SecretScanner) Redact(text string) (string, []SecretType) {
+	var found []SecretType
+	for _, t := range s.order {
+		re := s.patterns[t]
+		if re.MatchString(text) {
+			found = append(found, t)
+			text = re.ReplaceAllString(text, "[REDACTED_"+string(t)+"]")
+		}
+	}
+	return text, found
+}
+
+// StreamScanner applies a SecretScanner to a stream of deltas. It holds back
+// a short tail of text (and everything from an unterminated private key
+// header onward) until it can be scanned in full.
+type StreamScanner struct {
+	scanner *SecretScanner
+	buf     strings.Builder
+	Found   []SecretType
+}
+
+func (s *SecretScanner) NewStream() *StreamScanner {
+	return &StreamScanner{scanner: s}
+}
+
+// Push adds a delta and returns the redacted text that is safe to emit now.
+func (ss *StreamScanner) Push(delta string) string {
+	ss.buf.WriteString(delta)
+	text := ss.buf.String()
+
+	// Never release part of a private key block before its END line.
+	hold := len(text) - secretHoldback
+	if i := strings.LastIndex(text, privateKeyBegin); i >= 0 && !strings.Contains(text[i:], "-----END ") {
+		if i < hold {
+			hold = i
+		}
+	}
+	if hold <= 0 {
+		return ""
+	}
+
+	// Only release up to a point that isn't inside a match, so redaction
+	// never splits a secret between what is emitted and what is kept.
+	redacted, found := ss.scanner.Redact(text[:hold])
+	for _, t := range ss.scanner.order {
+		for _, loc := range ss.scanner.patterns[t].FindAllStringIndex(text, -1) {
+			if loc[0] < hold && loc[1] > hold {
+				hold = loc[0]
+				redacted, found = ss.scanner.Redact(text[:hold])
+			}
+		}
+	}
+	ss.Found = append(ss.Found, found...)
+
+	rest := text[hold:]
+	ss.buf.Reset()
+	ss.buf.WriteString(rest)
+	return redacted
+}
+
+// Flush redacts and returns everything still held back.
+func (ss *StreamScanner) Flush() string {
+	redacted, found := ss.scanner.Redact(ss.buf.String())
+	ss.Found = append(ss.Found, found...)
+	ss.buf.Reset()
+	return redacted
+}
@@ -0,0 +1,333 @@
+package governance
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type WordListAction string
+
+const (
+	ActionBlock    WordListAction = "block"
+	ActionRedact   WordListAction = "redact"
+	ActionAnnotate WordListAction = "annotate"
+)
+
+// WordRule is a single tenant-defined list entry. Kind is "deny" or "allow";
+// MatchType is "term" (case-insensitive whole word or phrase), "regex", or
+// "topic" (Pattern is a comma-separated keyword list, Label names the topic).
+// Scope is "prompt", "completion" or "both".
+type WordRule struct {
+	ID        int64          `json:"id"`
+	Tenant    string         `json:"tenant"`
+	Kind      string         `json:"kind"`
+	MatchType string         `json:"match_type"`
+	Pattern   string         `json:"pattern"`
+	Label     string         `json:"label,omitempty"`
+	Action    WordListAction `json:"action"`
+	Scope     string         `json:"scope"`
+}
+
+// Validate checks a rule before it is stored.
+func (r WordRule) Validate() error {
+	if r.Kind != "deny" && r.Kind != "allow" {
+		return fmt.Errorf("kind must be deny or allow")
+	}
+	if r.Pattern == "" {
+		return fmt.Errorf("pattern is required")
+	}
+	if r.Kind == "deny" && r.Action != ActionBlock && r.Action != ActionRedact && r.Action != ActionAnnotate {
+		return fmt.Errorf("action must be block, redact or annotate")
+	}
+	switch r.Scope {
+	case "prompt", "completion", "both":
+	default:
+		return fmt.Errorf("scope must be prompt, completion or both")
+	}
+	_, err := compileRule(r)
+	return err
+}
+
+func compileRule(r WordRule) (*regexp.Regexp, error) {
+	switch r.MatchType {
+	case "term":
+		return regexp.Compile(`(?i)\b` + regexp.QuoteMeta(r.Pattern) + `\b`)
+	case "regex":
+		return regexp.Compile(r.Pattern)
+	case "topic":
+		var kws []string
+		for _, kw := range strings.Split(r.Pattern, ",") {
+			if kw = strings.TrimSpace(kw); kw != "" {
+				kws = append(kws, regexp.QuoteMeta(kw))
+			}
+		}
+		if len(kws) == 0 {
+			return nil, fmt.Errorf("topic needs at least one keyword")
+		}
+		return regexp.Compile(`(?i)\b(?:` + strings.Join(kws, "|") + `)\b`)
+	}
+	return nil, fmt.Errorf("match_type must be term, regex or topic")
+}
+
+type compiledRule struct {
+	WordRule
+	re *regexp.Regexp
+}
+
+// WordList is the compiled set of rules for one tenant.
+type WordList struct {
+	allow []compiledRule
+	deny  []compiledRule
+}
+
+// WordListResult is the outcome of evaluating text against a WordList.
+type WordListResult struct {
+	Text        string   // text with redactions applied
+	Blocked     bool     // a block rule matched
+	Matched     []string // labels (or patterns) of deny rules that matched
+	Annotations []string // labels of annotate rules that matched
+}
+
+func CompileWordList(rules []WordRule) *WordList {
+	wl := &WordList{}
+	for _, r := range rules {
+		re, err := compileRule(r)
+		if err != nil {
+			continue
+		}
+		c := compiledRule{WordRule: r, re: re}
+		if r.Kind == "allow" {
+			wl.allow = append(wl.allow, c)
+		} else {
+			wl.deny = append(wl.deny, c)
+		}
+	}
+	return wl
+}
+
+// Evaluate applies the deny rules in scope ("prompt" or "completion") to
+// text. Deny matches that fall entirely inside an allowlisted span are
+// ignored, so an allow entry can carve out exceptions.
+func (wl *WordList) Evaluate(text, scope string) WordListResult {
+	res := WordListResult{Text: text}
+	if wl == nil || len(wl.deny) == 0 {
+		return res
+	}
+
+	var allowed [][]int
+	for _, a := range wl.allow {
+		if a.Scope == scope || a.Scope == "both" {
+			allowed = append(allowed, a.re.FindAllStringIndex(text, -1)...)
+		}
+	}
+	inAllowed := func(loc []int) bool {
+		for _, a := range allowed {
+			if loc[0] >= a[0] && loc[1] <= a[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, d := range wl.deny {
+		if d.Scope != scope && d.Scope != "both" {
+			continue
+		}
+		var hits [][]int
+		for _, loc := range d.re.FindAllStringIndex(res.Text, -1) {
+			if !inAllowed(loc) {
+				hits = append(hits, loc)
+			}
+		}
+		if len(hits) == 0 {
+			continue
+		}
+
+		label := d.Label
+		if label == "" {
+			label = d.Pattern
+		}
+		res.Matched = append(res.Matched, label)
+
+		switch d.Action {
+		case ActionBlock:
+			res.Blocked = true
+		case ActionAnnotate:
+			res.Annotations = append(res.Annotations, label)
+		case ActionRedact:
+			// Replace from the end so earlier offsets stay valid.
+			for i := len(hits) - 1; i >= 0; i-- {
+				res.Text = res.Text[:hits[i][0]] + "[REDACTED]" + res.Text[hits[i][1]:]
+			}
+			// Allowed spans shift after redaction; recompute for later rules.
+			allowed = allowed[:0]
+			for _, a := range wl.allow {
+				if a.Scope == scope || a.Scope == "both" {
+					allowed = append(allowed, a.re.FindAllStringIndex(res.Text, -1)...)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// regexWordWindow is how many runes a stream holds back for a regex rule,
+// whose longest match cannot be known.
+const regexWordWindow = 64
+
+// StreamWordList applies a WordList's completion rules to a stream of
+// deltas. The last runes, as many as the longest listed word or phrase, are
+// held back until later text shows whether they are part of a match, so a
+// word split across deltas is still redacted.
+type StreamWordList struct {
+	wl          *WordList
+	window      int
+	buf         string
+	Matched     []string
+	Annotations []string
+}
+
+// NewStream returns a StreamWordList for wl, or nil when wl has no
+// completion rules.
+func (wl *WordList) NewStream() *StreamWordList {
+	if wl == nil {
+		return nil
+	}
+	sw := &StreamWordList{wl: wl}
+	for _, r := range append(append([]compiledRule{}, wl.deny...), wl.allow...) {
+		if r.Scope != "completion" && r.Scope != "both" {
+			continue
+		}
+		n := utf8.RuneCountInString(r.Pattern)
+		switch r.MatchType {
+		case "regex":
+			n = regexWordWindow
+		case "topic":
+			n = 0
+			for _, kw := range strings.Split(r.Pattern, ",") {
+				n = max(n, utf8.RuneCountInString(strings.TrimSpace(kw)))
+			}
+		}
+		sw.window = max(sw.window, n)
+	}
+	if sw.window == 0 {
+		return nil
+	}
+	return sw
+}
+
+// Push adds a delta and returns the redacted text that is safe to emit now.
+func (sw *StreamWordList) Push(delta string) string {
+	text := sw.buf + delta
+	hold := len(text)
+	for n := 0; n < sw.window && hold > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:hold])
+		hold -= size
+	}
+	// Never release part of a match, or cut inside a word: a word cut short
+	// could match a term it is only the start of.
+	outsideMatches := func() {
+		for moved := true; moved; {
+			moved = false
+			for _, rules := range [][]compiledRule{sw.wl.deny, sw.wl.allow} {
+				for _, r := range rules {
+					for _, loc := range r.re.FindAllStringIndex(text, -1) {
+						if loc[0] < hold && loc[1] > hold {
+							hold, moved = loc[0], true
+						}
+					}
+				}
+			}
+		}
+	}
+	outsideMatches()
+	for word := 0; hold > 0 && hold < len(text) && word < sw.window && isWordByte(text[hold-1]) && isWordByte(text[hold]); word++ {
+		hold--
+	}
+	outsideMatches()
+	if hold <= 0 {
+		sw.buf = text
+		return ""
+	}
+	sw.buf = text[hold:]
+	return sw.evaluate(text[:hold])
+}
+
+// Flush redacts and returns everything still held back.
+func (sw *StreamWordList) Flush() string {
+	text := sw.buf
+	sw.buf = ""
+	return sw.evaluate(text)
+}
+
+func (sw *StreamWordList) evaluate(text string) string {
+	res := sw.wl.Evaluate(text, "completion")
+	for _, m := range res.Matched {
+		if !slices.Contains(sw.Matched, m) {
+			sw.Matched = append(sw.Matched, m)
+		}
+	}
+	for _, a := range res.Annotations {
+		if !slices.Contains(sw.Annotations, a) {
+			sw.Annotations = append(sw.Annotations, a)
+		}
+	}
+	return res.Text
+}
+
+// isWordByte reports whether b is a word character as \b sees it.
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// WordListCache keeps compiled tenant word lists in memory, reloading them
+// from the loader after ttl or when invalidated by an admin change.
+type WordListCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	load    func(ctx context.Context, tenant string) ([]WordRule, error)
+	entries map[string]wordListEntry
+}
+
+type wordListEntry struct {
+	list     *WordList
+	loadedAt time.Time
+}
+
+func NewWordListCache(ttl time.Duration, load func(ctx context.Context, tenant string) ([]WordRule, error)) *WordListCache {
+	return &WordListCache{ttl: ttl, load: load, entries: make(map[string]wordListEntry)}
+}
+
+// Get returns the tenant's compiled list. On a load error the previous list,
+// if any, keeps being served.
+func (c *WordListCache) Get(ctx context.Context, tenant string) *WordList {
+	c.mu.Lock()
+	e, ok := c.entries[tenant]
+	c.mu.Unlock()
+	if ok && time.Since(e.loadedAt) < c.ttl {
+		return e.list
+	}
+
+	rules, err := c.load(ctx, tenant)
+	if err != nil {
+		return e.list
+	}
+	list := CompileWordList(rules)
+
+	c.mu.Lock()
+	c.entries[tenant] = wordListEntry{list: list, loadedAt: time.Now()}
+	c.mu.Unlock()
+	return list
+}
+
+func (c *WordListCache) Invalidate(tenant string) {
+	c.mu.Lock()
+	delete(c.entries, tenant)
+	c.mu.Unlock()
+}
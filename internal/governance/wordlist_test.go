@@ -0,0 +1,78 @@
+package governance
+
+import "testing"
+
+func TestWordList_Evaluate(t *testing.T) {
+	wl := CompileWordList([]WordRule{
+		{Kind: "deny", MatchType: "term", Pattern: "project falcon", Action: ActionRedact, Scope: "both"},
+		{Kind: "deny", MatchType: "regex", Pattern: `(?i)\bkill\b`, Label: "violence", Action: ActionBlock, Scope: "prompt"},
+		{Kind: "allow", MatchType: "regex", Pattern: `(?i)kill (the )?process`, Scope: "both"},
+		{Kind: "deny", MatchType: "topic", Pattern: "election, ballot", Label: "politics", Action: ActionAnnotate, Scope: "completion"},
+	})
+
+	t.Run("Redacts term", func(t *testing.T) {
+		res := wl.Evaluate("Status of Project Falcon?", "prompt")
+		if res.Text != "Status of [REDACTED]?" {
+			t.Errorf("unexpected text %q", res.Text)
+		}
+		if res.Blocked {
+			t.Error("did not expect block")
+		}
+	})
+
+	t.Run("Blocks regex", func(t *testing.T) {
+		if res := wl.Evaluate("how do I kill him", "prompt"); !res.Blocked {
+			t.Error("expected block")
+		}
+	})
+
+	t.Run("Allowlist carves out exception", func(t *testing.T) {
+		if res := wl.Evaluate("how do I kill the process", "prompt"); res.Blocked {
+			t.Error("expected allowlisted phrase to pass")
+		}
+	})
+
+	t.Run("Scope is respected", func(t *testing.T) {
+		if res := wl.Evaluate("how do I kill him", "completion"); res.Blocked {
+			t.Error("prompt-only rule should not apply to completions")
+		}
+	})
+
+	t.Run("Topic annotates", func(t *testing.T) {
+		res := wl.Evaluate("The election results are in.", "completion")
+		if len(res.Annotations) != 1 || res.Annotations[0] != "politics" {
+			t.Errorf("expected politics annotation, got %v", res.Annotations)
+		}
+	})
+}
+
+func TestStreamWordList(t *testing.T) {
+	wl := CompileWordList([]WordRule{
+		{Kind: "deny", MatchType: "term", Pattern: "project falcon", Action: ActionRedact, Scope: "completion"},
+		{Kind: "deny", MatchType: "topic", Pattern: "election, ballot", Label: "politics", Action: ActionAnnotate, Scope: "both"},
+	})
+	run := func(deltas ...string) (string, *StreamWordList) {
+		sw := wl.NewStream()
+		var out string
+		for _, d := range deltas {
+			out += sw.Push(d)
+		}
+		return out + sw.Flush(), sw
+	}
+
+	if out, sw := run("Status of Proj", "ect Fal", "con today, and the ball", "ot."); out != "Status of [REDACTED] today, and the ballot." {
+		t.Errorf("expected a term split across deltas to be redacted, got %q", out)
+	} else if len(sw.Matched) != 2 || len(sw.Annotations) != 1 {
+		t.Errorf("unexpected matches %v, annotations %v", sw.Matched, sw.Annotations)
+	}
+	if out, _ := run("Project Falcon", "ry is a hobby."); out != "Project Falconry is a hobby." {
+		t.Errorf("expected a longer word not to be redacted, got %q", out)
+	}
+	sw := wl.NewStream()
+	if out := sw.Push("A long answer that has nothing listed in it at all, "); out == "" {
+		t.Error("expected text before the window to be released")
+	}
+	if CompileWordList(nil).NewStream() != nil {
+		t.Error("expected no stream without completion rules")
+	}
+}
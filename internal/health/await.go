@@ -0,0 +1,38 @@
+package health
+
+import (
+	"context"
+	"time"
+)
+
+// awaitRetry is the first wait between startup probes; it doubles on each
+// failure up to maxAwaitRetry.
+var awaitRetry = 500 * time.Millisecond
+
+const maxAwaitRetry = 10 * time.Second
+
+// Await probes a dependency until it answers, backing off between tries.
+// It gives up with the last probe's error after timeout, or waits as long
+// as ctx allows when timeout is 0.
+func Await(ctx context.Context, probe func(ctx context.Context) error, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	wait := awaitRetry
+	for {
+		pctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := probe(pctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, maxAwaitRetry)
+	}
+}
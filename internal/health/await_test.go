@@ -0,0 +1,29 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAwait(t *testing.T) {
+	awaitRetry = time.Millisecond
+	ctx := context.Background()
+
+	var tries int
+	err := Await(ctx, func(ctx context.Context) error {
+		if tries++; tries < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}, time.Second)
+	if err != nil || tries != 3 {
+		t.Errorf("expected success on the third try, got %v after %d", err, tries)
+	}
+
+	err = Await(ctx, func(ctx context.Context) error { return errors.New("connection refused") }, 20*time.Millisecond)
+	if err == nil {
+		t.Error("expected the last error once the timeout passed")
+	}
+}
@@ -0,0 +1,295 @@
+// Package health tracks provider health: a circuit breaker fed by attempt
+// outcomes, a moving average of latency, and the queue depth self-hosted
+// backends report. State lives in a Store, kept per instance in memory or
+// shared by all replicas through Redis; queue depths are read by each
+// instance.
+package health
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// latencyAlpha weighs the newest sample in the latency moving average.
+const latencyAlpha = 0.2
+
+// Circuit states reported by Tracker.Snapshot.
+const (
+	Closed   = "closed"
+	Open     = "open"
+	HalfOpen = "half_open" // cooldown over, waiting for a probe
+)
+
+// State is a provider's health.
+type State struct {
+	Circuit   string    `json:"circuit"`
+	Failures  int       `json:"consecutive_failures"`
+	OpenUntil time.Time `json:"-"`
+	LatencyMS float64   `json:"latency_ewma_ms"`
+	Attempts  int64     `json:"attempts"`
+	Errors    int64     `json:"errors"`
+	// QueueWaiting is how many requests wait in a self-hosted backend's
+	// queue, when it reports that.
+	QueueWaiting *float64 `json:"queue_waiting,omitempty"`
+}
+
+// Policy configures the circuit breaker: Threshold consecutive failures
+// open the circuit for Cooldown.
+type Policy struct {
+	Threshold int
+	Cooldown  time.Duration
+}
+
+// Store holds provider states.
+type Store interface {
+	// Acquire reports whether a call to provider may go ahead. An open
+	// circuit refuses calls until its cooldown ends, then lets a single
+	// caller through as a probe and stays open for everybody else for
+	// another cooldown.
+	Acquire(ctx context.Context, provider string, p Policy, now time.Time) (bool, error)
+	// Record applies an attempt's outcome and reports whether it opened
+	// the circuit.
+	Record(ctx context.Context, provider string, p Policy, latency time.Duration, failed bool, now time.Time) (bool, error)
+	Snapshot(ctx context.Context) (map[string]State, error)
+	// RecordTTFT adds a time-to-first-token sample for a target, keyed
+	// "provider/model"; TTFT returns its moving average in milliseconds.
+	RecordTTFT(ctx context.Context, target string, ttft time.Duration) error
+	TTFT(ctx context.Context, target string) (float64, bool, error)
+	// RecordSpeed adds a generation speed sample for a target, in output
+	// tokens per second; Speed returns its moving average.
+	RecordSpeed(ctx context.Context, target string, tokensPerSec float64) error
+	Speed(ctx context.Context, target string) (float64, bool, error)
+}
+
+// Tracker applies a Policy over a Store. A nil Tracker allows everything.
+type Tracker struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+
+	queueMu sync.Mutex
+	queues  map[string]queueDepth // backend queue depths, by provider
+}
+
+// NewTracker returns a tracker whose circuits open after threshold
+// consecutive failures; a threshold of 0 only collects statistics.
+func NewTracker(store Store, threshold int, cooldown time.Duration) *Tracker {
+	return &Tracker{store: store, policy: Policy{Threshold: threshold, Cooldown: cooldown}, now: time.Now}
+}
+
+// Allow reports whether provider may be called. It fails open when the
+// store is unavailable.
+func (t *Tracker) Allow(ctx context.Context, provider string) bool {
+	if t == nil || t.policy.Threshold <= 0 {
+		return true
+	}
+	ok, err := t.store.Acquire(ctx, provider, t.policy, t.now())
+	if err != nil {
+		log.Printf("Warning: provider health unavailable: %v", err)
+		return true
+	}
+	return ok
+}
+
+// Record reports an attempt's outcome and whether it opened the circuit.
+// Only failures that say something about the provider's health (timeouts,
+// 5xx, 429) should be recorded as failed.
+func (t *Tracker) Record(ctx context.Context, provider string, latency time.Duration, failed bool) bool {
+	if t == nil {
+		return false
+	}
+	opened, err := t.store.Record(ctx, provider, t.policy, latency, failed, t.now())
+	if err != nil {
+		log.Printf("Warning: provider health unavailable: %v", err)
+	}
+	return opened
+}
+
+// Snapshot returns the state of every provider seen so far.
+func (t *Tracker) Snapshot(ctx context.Context) (map[string]State, error) {
+	if t == nil {
+		return map[string]State{}, nil
+	}
+	states, err := t.store.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := t.now()
+	for name, s := range states {
+		switch {
+		case t.policy.Threshold <= 0 || s.Failures < t.policy.Threshold:
+			s.Circuit = Closed
+		case now.Before(s.OpenUntil):
+			s.Circuit = Open
+		default:
+			s.Circuit = HalfOpen
+		}
+		states[name] = s
+	}
+	t.queueMu.Lock()
+	providers := make([]string, 0, len(t.queues))
+	for name := range t.queues {
+		providers = append(providers, name)
+	}
+	t.queueMu.Unlock()
+	for _, name := range providers {
+		if waiting, ok := t.QueueDepth(name); ok {
+			s, seen := states[name]
+			if !seen {
+				s.Circuit = Closed
+			}
+			s.QueueWaiting = &waiting
+			states[name] = s
+		}
+	}
+	return states, nil
+}
+
+// RecordTTFT reports how long target took to send its first token.
+func (t *Tracker) RecordTTFT(ctx context.Context, target string, ttft time.Duration) {
+	if t == nil {
+		return
+	}
+	if err := t.store.RecordTTFT(ctx, target, ttft); err != nil {
+		log.Printf("Warning: provider health unavailable: %v", err)
+	}
+}
+
+// TTFT returns target's average time to first token, if it has any samples.
+func (t *Tracker) TTFT(ctx context.Context, target string) (time.Duration, bool) {
+	if t == nil {
+		return 0, false
+	}
+	ms, ok, err := t.store.TTFT(ctx, target)
+	if err != nil || !ok {
+		return 0, false
+	}
+	return time.Duration(ms * float64(time.Millisecond)), true
+}
+
+// RecordSpeed reports that target generated tokens output tokens in
+// elapsed, after its first token.
+func (t *Tracker) RecordSpeed(ctx context.Context, target string, tokens int, elapsed time.Duration) {
+	if t == nil || tokens <= 0 || elapsed <= 0 {
+		return
+	}
+	if err := t.store.RecordSpeed(ctx, target, float64(tokens)/elapsed.Seconds()); err != nil {
+		log.Printf("Warning: provider health unavailable: %v", err)
+	}
+}
+
+// Speed returns target's average generation speed in output tokens per
+// second, if it has any samples.
+func (t *Tracker) Speed(ctx context.Context, target string) (float64, bool) {
+	if t == nil {
+		return 0, false
+	}
+	speed, ok, err := t.store.Speed(ctx, target)
+	if err != nil || !ok || speed <= 0 {
+		return 0, false
+	}
+	return speed, true
+}
+
+// MemoryStore keeps provider states for this instance only.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]*State
+	ttft   map[string]float64
+	speed  map[string]float64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]*State), ttft: make(map[string]float64), speed: make(map[string]float64)}
+}
+
+// movingAverage folds sample into avg, starting from the first sample.
+func movingAverage(avg, sample float64) float64 {
+	if avg == 0 {
+		return sample
+	}
+	return latencyAlpha*sample + (1-latencyAlpha)*avg
+}
+
+func (m *MemoryStore) state(provider string) *State {
+	s, ok := m.states[provider]
+	if !ok {
+		s = &State{}
+		m.states[provider] = s
+	}
+	return s
+}
+
+func (m *MemoryStore) Acquire(_ context.Context, provider string, p Policy, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.state(provider)
+	if s.Failures < p.Threshold {
+		return true, nil
+	}
+	if now.Before(s.OpenUntil) {
+		return false, nil
+	}
+	s.OpenUntil = now.Add(p.Cooldown)
+	return true, nil
+}
+
+func (m *MemoryStore) Record(_ context.Context, provider string, p Policy, latency time.Duration, failed bool, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.state(provider)
+	s.Attempts++
+	if failed {
+		s.Errors++
+		s.Failures++
+		if s.Failures == p.Threshold {
+			s.OpenUntil = now.Add(p.Cooldown)
+			return true, nil
+		}
+		return false, nil
+	}
+	s.Failures = 0
+	s.OpenUntil = time.Time{}
+	s.LatencyMS = movingAverage(s.LatencyMS, float64(latency)/float64(time.Millisecond))
+	return false, nil
+}
+
+func (m *MemoryStore) RecordTTFT(_ context.Context, target string, ttft time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ttft[target] = movingAverage(m.ttft[target], float64(ttft)/float64(time.Millisecond))
+	return nil
+}
+
+func (m *MemoryStore) TTFT(_ context.Context, target string) (float64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ms, ok := m.ttft[target]
+	return ms, ok, nil
+}
+
+func (m *MemoryStore) RecordSpeed(_ context.Context, target string, tokensPerSec float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.speed[target] = movingAverage(m.speed[target], tokensPerSec)
+	return nil
+}
+
+func (m *MemoryStore) Speed(_ context.Context, target string) (float64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	speed, ok := m.speed[target]
+	return speed, ok, nil
+}
+
+func (m *MemoryStore) Snapshot(context.Context) (map[string]State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]State, len(m.states))
+	for name, s := range m.states {
+		out[name] = *s
+	}
+	return out, nil
+}
@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	tr := NewTracker(NewMemoryStore(), 3, 30*time.Second)
+	tr.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if tr.Record(ctx, "openai", time.Second, true) {
+			t.Fatal("expected circuit to stay closed below the threshold")
+		}
+	}
+	if !tr.Allow(ctx, "openai") {
+		t.Fatal("expected calls below the threshold")
+	}
+	if !tr.Record(ctx, "openai", time.Second, true) {
+		t.Fatal("expected third failure to open the circuit")
+	}
+	if tr.Allow(ctx, "openai") {
+		t.Fatal("expected open circuit to refuse calls")
+	}
+	if !tr.Allow(ctx, "anthropic") {
+		t.Error("expected other providers to be unaffected")
+	}
+
+	now = now.Add(31 * time.Second)
+	if states, _ := tr.Snapshot(ctx); states["openai"].Circuit != HalfOpen {
+		t.Errorf("expected half-open after cooldown, got %s", states["openai"].Circuit)
+	}
+	if !tr.Allow(ctx, "openai") {
+		t.Fatal("expected one probe after the cooldown")
+	}
+	if tr.Allow(ctx, "openai") {
+		t.Fatal("expected only one probe per cooldown")
+	}
+
+	tr.Record(ctx, "openai", 200*time.Millisecond, false)
+	if !tr.Allow(ctx, "openai") {
+		t.Fatal("expected a successful probe to close the circuit")
+	}
+	states, _ := tr.Snapshot(ctx)
+	if s := states["openai"]; s.Circuit != Closed || s.Attempts != 4 || s.Errors != 3 || s.LatencyMS != 200 {
+		t.Errorf("unexpected state %+v", s)
+	}
+}
+
+func TestLatencyAverage(t *testing.T) {
+	ctx := context.Background()
+	tr := NewTracker(NewMemoryStore(), 0, 0)
+	tr.Record(ctx, "openai", 100*time.Millisecond, false)
+	tr.Record(ctx, "openai", 600*time.Millisecond, false)
+	tr.Record(ctx, "openai", 5*time.Second, true)
+
+	states, _ := tr.Snapshot(ctx)
+	if got := states["openai"].LatencyMS; got != 200 {
+		t.Errorf("expected moving average 200ms ignoring failures, got %v", got)
+	}
+	if !tr.Allow(ctx, "openai") {
+		t.Error("expected a zero threshold never to open the circuit")
+	}
+}
+
+func TestNilTrackerAllows(t *testing.T) {
+	var tr *Tracker
+	if !tr.Allow(context.Background(), "openai") || tr.Record(context.Background(), "openai", time.Second, true) {
+		t.Error("expected nil tracker to allow and never open")
+	}
+}
+
+func TestTTFT(t *testing.T) {
+	ctx := context.Background()
+	tr := NewTracker(NewMemoryStore(), 0, 0)
+	if _, ok := tr.TTFT(ctx, "openai/gpt-4o"); ok {
+		t.Fatal("expected no TTFT before any sample")
+	}
+	tr.RecordTTFT(ctx, "openai/gpt-4o", 500*time.Millisecond)
+	tr.RecordTTFT(ctx, "openai/gpt-4o", 1500*time.Millisecond)
+	if got, ok := tr.TTFT(ctx, "openai/gpt-4o"); !ok || got != 700*time.Millisecond {
+		t.Errorf("expected 700ms average, got %v %v", got, ok)
+	}
+}
+
+func TestSpeed(t *testing.T) {
+	ctx := context.Background()
+	tr := NewTracker(NewMemoryStore(), 0, 0)
+	if _, ok := tr.Speed(ctx, "openai/gpt-4o"); ok {
+		t.Fatal("expected no speed before any sample")
+	}
+	tr.RecordSpeed(ctx, "openai/gpt-4o", 100, 2*time.Second)
+	tr.RecordSpeed(ctx, "openai/gpt-4o", 0, time.Second) // ignored
+	if got, ok := tr.Speed(ctx, "openai/gpt-4o"); !ok || got != 50 {
+		t.Errorf("expected 50 tokens/s, got %v %v", got, ok)
+	}
+}
@@ -0,0 +1,136 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueueSource is where a self-hosted backend reports how many requests
+// wait in its queue: a gauge in its Prometheus metrics, read every
+// Interval.
+type QueueSource struct {
+	URL      string
+	Metric   string
+	Interval time.Duration
+}
+
+type queueDepth struct {
+	waiting float64
+	until   time.Time // when the reading goes stale
+}
+
+// WatchQueue reads provider's queue depth from src until ctx is done. A
+// reading is kept for three intervals, so a backend whose metrics cannot
+// be read soon counts as reporting nothing.
+func (t *Tracker) WatchQueue(ctx context.Context, provider string, src QueueSource) {
+	if t == nil {
+		return
+	}
+	client := &http.Client{Timeout: src.Interval}
+	read := func() {
+		waiting, err := readGauge(ctx, client, src.URL, src.Metric)
+		if err != nil {
+			log.Printf("Warning: failed to read the queue depth of %s: %v", provider, err)
+			return
+		}
+		t.setQueueDepth(provider, waiting, t.now().Add(3*src.Interval))
+	}
+
+	read()
+	ticker := time.NewTicker(src.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			read()
+		}
+	}
+}
+
+func (t *Tracker) setQueueDepth(provider string, waiting float64, until time.Time) {
+	t.queueMu.Lock()
+	defer t.queueMu.Unlock()
+	if t.queues == nil {
+		t.queues = map[string]queueDepth{}
+	}
+	t.queues[provider] = queueDepth{waiting: waiting, until: until}
+}
+
+// QueueDepth returns how many requests wait in provider's backend queue,
+// if it reported that recently.
+func (t *Tracker) QueueDepth(provider string) (float64, bool) {
+	if t == nil {
+		return 0, false
+	}
+	t.queueMu.Lock()
+	defer t.queueMu.Unlock()
+	d, ok := t.queues[provider]
+	if !ok || t.now().After(d.until) {
+		return 0, false
+	}
+	return d.waiting, true
+}
+
+// readGauge fetches Prometheus text metrics from url and returns the sum
+// of metric's series.
+func readGauge(ctx context.Context, client *http.Client, url, metric string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("metrics returned %s", resp.Status)
+	}
+	return parseGauge(bufio.NewScanner(resp.Body), metric)
+}
+
+func parseGauge(lines *bufio.Scanner, metric string) (float64, error) {
+	var sum float64
+	found := false
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		rest, ok := strings.CutPrefix(line, metric)
+		if !ok || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(rest, "{"):
+			end := strings.LastIndex(rest, "}")
+			if end < 0 {
+				continue
+			}
+			rest = rest[end+1:]
+		case !strings.HasPrefix(rest, " "):
+			continue // another metric sharing the prefix
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, fmt.Errorf("metric %s: %w", metric, err)
+		}
+		sum, found = sum+v, true
+	}
+	if err := lines.Err(); err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("metric %s not reported", metric)
+	}
+	return sum, nil
+}
@@ -0,0 +1,75 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const vllmMetrics = `# HELP vllm:num_requests_waiting Number of requests waiting to be processed.
+# TYPE vllm:num_requests_waiting gauge
+vllm:num_requests_waiting{model_name="llama-3-8b"} 7.0
+vllm:num_requests_waiting{model_name="qwen {2}"} 2.0
+vllm:num_requests_waiting_total 40
+vllm:num_requests_running{model_name="llama-3-8b"} 16.0
+`
+
+func TestParseGauge(t *testing.T) {
+	got, err := parseGauge(bufio.NewScanner(strings.NewReader(vllmMetrics)), "vllm:num_requests_waiting")
+	if err != nil || got != 9 {
+		t.Errorf("expected 9 waiting, got %v (%v)", got, err)
+	}
+	if _, err := parseGauge(bufio.NewScanner(strings.NewReader(vllmMetrics)), "tgi_queue_size"); err == nil {
+		t.Error("expected a missing metric to fail")
+	}
+}
+
+func TestWatchQueue(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, vllmMetrics)
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	tr := NewTracker(NewMemoryStore(), 0, 0)
+	tr.now = func() time.Time { return now }
+	if _, ok := tr.QueueDepth("vllm"); ok {
+		t.Fatal("expected no depth before the first read")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		tr.WatchQueue(ctx, "vllm", QueueSource{URL: srv.URL, Metric: "vllm:num_requests_running", Interval: time.Minute})
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if got, ok := tr.QueueDepth("vllm"); ok {
+			if got != 16 {
+				t.Errorf("expected 16 running, got %v", got)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("queue depth never read")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	states, _ := tr.Snapshot(context.Background())
+	if w := states["vllm"].QueueWaiting; w == nil || *w != 16 {
+		t.Errorf("expected the depth in the snapshot, got %+v", states["vllm"])
+	}
+	now = now.Add(4 * time.Minute)
+	if _, ok := tr.QueueDepth("vllm"); ok {
+		t.Error("expected a stale depth to be dropped")
+	}
+}
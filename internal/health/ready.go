@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Check probes one dependency of the gateway. Probe returns nil when the
+// dependency answered.
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of one check.
+type CheckResult struct {
+	Status    string  `json:"status"` // "up" or "down"
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of every check. Ready is false when a critical
+// dependency is down.
+type Report struct {
+	Ready     bool                   `json:"ready"`
+	Checks    map[string]CheckResult `json:"checks"`
+	CheckedAt time.Time              `json:"checked_at"`
+}
+
+// Readiness runs the gateway's dependency checks. Results are reused for
+// a few seconds, so frequent probes from an orchestrator do not turn into
+// a stream of calls to every provider.
+type Readiness struct {
+	checks   []Check
+	critical []string
+	timeout  time.Duration
+	cacheFor time.Duration
+
+	mu   sync.Mutex
+	last Report
+}
+
+// NewReadiness returns a Readiness over checks. The checks named in
+// critical make the gateway unready when they fail; each check gets at
+// most timeout.
+func NewReadiness(checks []Check, critical []string, timeout time.Duration) *Readiness {
+	return &Readiness{checks: checks, critical: critical, timeout: timeout, cacheFor: 5 * time.Second}
+}
+
+// Report runs the checks in parallel, or returns the last report if it is
+// recent enough.
+func (r *Readiness) Report(ctx context.Context) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.last.CheckedAt.IsZero() && time.Since(r.last.CheckedAt) < r.cacheFor {
+		return r.last
+	}
+
+	results := make([]CheckResult, len(r.checks))
+	var wg sync.WaitGroup
+	for i, c := range r.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+			start := time.Now()
+			err := c.Probe(cctx)
+			res := CheckResult{Status: "up", Critical: slices.Contains(r.critical, c.Name), LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				res.Status, res.Error = "down", err.Error()
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Ready: true, Checks: make(map[string]CheckResult, len(r.checks)), CheckedAt: time.Now()}
+	for i, c := range r.checks {
+		report.Checks[c.Name] = results[i]
+		if results[i].Critical && results[i].Status != "up" {
+			report.Ready = false
+		}
+	}
+	r.last = report
+	return report
+}
+
+// HTTPProbe checks that url answers HTTP at all: any response, including
+// an authentication error, shows the server is reachable.
+func HTTPProbe(url string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+}
@@ -0,0 +1,58 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	var redisDown bool
+	var calls int
+	checks := []Check{
+		{Name: "postgres", Probe: func(ctx context.Context) error { calls++; return nil }},
+		{Name: "redis", Probe: func(ctx context.Context) error {
+			if redisDown {
+				return errors.New("connection refused")
+			}
+			return nil
+		}},
+	}
+	r := NewReadiness(checks, []string{"postgres"}, time.Second)
+	ctx := context.Background()
+
+	redisDown = true
+	report := r.Report(ctx)
+	if !report.Ready {
+		t.Error("expected a non-critical dependency not to affect readiness")
+	}
+	if got := report.Checks["redis"]; got.Status != "down" || got.Error == "" || got.Critical {
+		t.Errorf("got redis %+v", got)
+	}
+	r.Report(ctx)
+	if calls != 1 {
+		t.Errorf("expected a recent report to be reused, probed %d times", calls)
+	}
+
+	r = NewReadiness(checks, []string{"postgres", "redis"}, time.Second)
+	if r.Report(ctx).Ready {
+		t.Error("expected a critical dependency being down to make the gateway unready")
+	}
+}
+
+func TestHTTPProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	url := srv.URL
+	if err := HTTPProbe(url)(context.Background()); err != nil {
+		t.Errorf("expected any HTTP answer to count as reachable, got %v", err)
+	}
+	srv.Close()
+	if err := HTTPProbe(url)(context.Background()); err == nil {
+		t.Error("expected a closed server to be unreachable")
+	}
+}
@@ -0,0 +1,185 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisPrefix    = "health:provider:"
+	redisProviders = "health:providers"
+	redisTTFT      = "health:ttft"
+	redisSpeed     = "health:speed"
+)
+
+// acquireLua implements Store.Acquire on a provider hash. ARGV: threshold,
+// now (unix ms), cooldown (ms).
+var acquireLua = redis.NewScript(`
+local key = KEYS[1]
+local threshold = tonumber(ARGV[1])
+local now = tonumber(ARGV[2])
+local cooldown = tonumber(ARGV[3])
+
+local failures = tonumber(redis.call("HGET", key, "failures") or "0")
+if failures < threshold then
+    return 1
+end
+local open_until = tonumber(redis.call("HGET", key, "open_until") or "0")
+if now < open_until then
+    return 0
+end
+redis.call("HSET", key, "open_until", now + cooldown)
+return 1
+`)
+
+// recordLua implements Store.Record. KEYS: provider hash, provider set.
+// ARGV: threshold, now (unix ms), cooldown (ms), failed (0/1), latency (ms),
+// moving average weight, provider name. Returns 1 if the circuit opened.
+var recordLua = redis.NewScript(`
+local key = KEYS[1]
+local threshold = tonumber(ARGV[1])
+local now = tonumber(ARGV[2])
+local cooldown = tonumber(ARGV[3])
+local failed = ARGV[4] == "1"
+local latency = tonumber(ARGV[5])
+local alpha = tonumber(ARGV[6])
+
+redis.call("SADD", KEYS[2], ARGV[7])
+redis.call("HINCRBY", key, "attempts", 1)
+if failed then
+    redis.call("HINCRBY", key, "errors", 1)
+    local failures = redis.call("HINCRBY", key, "failures", 1)
+    if failures == threshold then
+        redis.call("HSET", key, "open_until", now + cooldown)
+        return 1
+    end
+    return 0
+end
+
+local avg = tonumber(redis.call("HGET", key, "latency_ewma_ms") or "0")
+if avg == 0 then
+    avg = latency
+else
+    avg = alpha * latency + (1 - alpha) * avg
+end
+redis.call("HSET", key, "failures", 0, "open_until", 0, "latency_ewma_ms", tostring(avg))
+return 0
+`)
+
+// averageLua folds a sample into a field of a hash of moving averages.
+// ARGV: target, sample, moving average weight.
+var averageLua = redis.NewScript(`
+local sample = tonumber(ARGV[2])
+local alpha = tonumber(ARGV[3])
+local avg = tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0")
+if avg == 0 then
+    avg = sample
+else
+    avg = alpha * sample + (1 - alpha) * avg
+end
+redis.call("HSET", KEYS[1], ARGV[1], tostring(avg))
+return 1
+`)
+
+// RedisStore shares provider states between gateway replicas, so a
+// provider failing for one instance is avoided by all of them.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(redisURL string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to redis for provider health: %w", err)
+	}
+	return &RedisStore{client: client}, nil
+}
+
+func (r *RedisStore) Acquire(ctx context.Context, provider string, p Policy, now time.Time) (bool, error) {
+	ok, err := acquireLua.Run(ctx, r.client, []string{redisPrefix + provider},
+		p.Threshold, now.UnixMilli(), p.Cooldown.Milliseconds()).Int()
+	return ok == 1, err
+}
+
+func (r *RedisStore) Record(ctx context.Context, provider string, p Policy, latency time.Duration, failed bool, now time.Time) (bool, error) {
+	flag := 0
+	if failed {
+		flag = 1
+	}
+	opened, err := recordLua.Run(ctx, r.client, []string{redisPrefix + provider, redisProviders},
+		p.Threshold, now.UnixMilli(), p.Cooldown.Milliseconds(), flag, latency.Milliseconds(), latencyAlpha, provider).Int()
+	return opened == 1, err
+}
+
+func (r *RedisStore) Snapshot(ctx context.Context) (map[string]State, error) {
+	names, err := r.client.SMembers(ctx, redisProviders).Result()
+	if err != nil {
+		return nil, err
+	}
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(names))
+	for i, name := range names {
+		cmds[i] = pipe.HGetAll(ctx, redisPrefix+name)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]State, len(names))
+	for i, name := range names {
+		fields := cmds[i].Val()
+		var s State
+		s.Failures, _ = strconv.Atoi(fields["failures"])
+		s.Attempts, _ = strconv.ParseInt(fields["attempts"], 10, 64)
+		s.Errors, _ = strconv.ParseInt(fields["errors"], 10, 64)
+		s.LatencyMS, _ = strconv.ParseFloat(fields["latency_ewma_ms"], 64)
+		if ms, _ := strconv.ParseInt(fields["open_until"], 10, 64); ms > 0 {
+			s.OpenUntil = time.UnixMilli(ms)
+		}
+		out[name] = s
+	}
+	return out, nil
+}
+
+func (r *RedisStore) RecordTTFT(ctx context.Context, target string, ttft time.Duration) error {
+	return averageLua.Run(ctx, r.client, []string{redisTTFT}, target, ttft.Milliseconds(), latencyAlpha).Err()
+}
+
+func (r *RedisStore) TTFT(ctx context.Context, target string) (float64, bool, error) {
+	ms, err := r.client.HGet(ctx, redisTTFT, target).Float64()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	return ms, err == nil, err
+}
+
+func (r *RedisStore) RecordSpeed(ctx context.Context, target string, tokensPerSec float64) error {
+	return averageLua.Run(ctx, r.client, []string{redisSpeed}, target, tokensPerSec, latencyAlpha).Err()
+}
+
+func (r *RedisStore) Speed(ctx context.Context, target string) (float64, bool, error) {
+	speed, err := r.client.HGet(ctx, redisSpeed, target).Float64()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	return speed, err == nil, err
+}
+
+// RedisProbe returns a Check probe that pings the Redis server at
+// redisURL.
+func RedisProbe(redisURL string) (func(ctx context.Context) error, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	return func(ctx context.Context) error { return client.Ping(ctx).Err() }, nil
+}
@@ -0,0 +1,161 @@
+package health
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stats keeps rolling attempt counts and latencies per target in this
+// instance's memory, for windows of up to an hour. It needs neither Redis
+// nor the database.
+type Stats struct {
+	mu      sync.Mutex
+	targets map[string]*targetStats
+	now     func() time.Time
+}
+
+const (
+	statsBucket  = 10 * time.Second
+	statsBuckets = int(time.Hour / statsBucket)
+)
+
+// latencyBounds are the upper bounds, in milliseconds, of the latency
+// histogram bins; a last bin holds anything slower.
+var latencyBounds = []float64{50, 100, 250, 500, 1000, 2000, 4000, 8000, 15000, 30000, 60000}
+
+// StatsWindows are the windows Snapshot reports, by name.
+var StatsWindows = map[string]time.Duration{"1m": time.Minute, "5m": 5 * time.Minute, "1h": time.Hour}
+
+type statsBucketCounts struct {
+	index    int64 // the bucket's start, in statsBucket units since the epoch
+	attempts int64
+	errors   int64
+	latency  [12]int64 // len(latencyBounds)+1 bins
+}
+
+type targetStats struct {
+	buckets [statsBuckets]statsBucketCounts
+}
+
+// WindowStats are a target's attempts over a window. SuccessRate and
+// P95LatencyMS are nil without attempts.
+type WindowStats struct {
+	Attempts     int64    `json:"attempts"`
+	Errors       int64    `json:"errors"`
+	SuccessRate  *float64 `json:"success_rate"`
+	P95LatencyMS *float64 `json:"p95_latency_ms"`
+}
+
+// TargetStats are a target's attempts over each of StatsWindows.
+type TargetStats struct {
+	Provider string                 `json:"provider"`
+	Model    string                 `json:"model"`
+	Windows  map[string]WindowStats `json:"windows"`
+}
+
+func NewStats() *Stats {
+	return &Stats{targets: map[string]*targetStats{}, now: time.Now}
+}
+
+// Record adds an attempt on a provider's model.
+func (s *Stats) Record(provider, model string, latency time.Duration, failed bool) {
+	if s == nil {
+		return
+	}
+	index := s.now().UnixNano() / int64(statsBucket)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.targets[provider+"/"+model]
+	if !ok {
+		t = &targetStats{}
+		s.targets[provider+"/"+model] = t
+	}
+	b := &t.buckets[index%int64(statsBuckets)]
+	if b.index != index {
+		*b = statsBucketCounts{index: index}
+	}
+	b.attempts++
+	if failed {
+		b.errors++
+	}
+	ms := float64(latency) / float64(time.Millisecond)
+	b.latency[sort.SearchFloat64s(latencyBounds, ms)]++
+}
+
+// Snapshot returns the stats of every target with attempts in the last
+// hour, ordered by provider and model.
+func (s *Stats) Snapshot() []TargetStats {
+	if s == nil {
+		return nil
+	}
+	index := s.now().UnixNano() / int64(statsBucket)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []TargetStats
+	for key, t := range s.targets {
+		ts := TargetStats{Windows: map[string]WindowStats{}}
+		ts.Provider, ts.Model, _ = strings.Cut(key, "/")
+		for name, d := range StatsWindows {
+			ts.Windows[name] = t.window(index, int64(d/statsBucket))
+		}
+		if ts.Windows["1h"].Attempts > 0 {
+			out = append(out, ts)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+// window sums the n buckets up to and including index.
+func (t *targetStats) window(index, n int64) WindowStats {
+	var w WindowStats
+	var latency [12]int64
+	for _, b := range t.buckets {
+		if b.index <= index-n || b.index > index {
+			continue
+		}
+		w.Attempts += b.attempts
+		w.Errors += b.errors
+		for i, c := range b.latency {
+			latency[i] += c
+		}
+	}
+	if w.Attempts == 0 {
+		return w
+	}
+	rate := float64(w.Attempts-w.Errors) / float64(w.Attempts)
+	p95 := percentile(latency[:], w.Attempts, 0.95)
+	w.SuccessRate, w.P95LatencyMS = &rate, &p95
+	return w
+}
+
+// percentile estimates the q quantile of a latency histogram of total
+// samples, interpolating within the bin it falls in. Samples above the
+// last bound count as the last bound.
+func percentile(bins []int64, total int64, q float64) float64 {
+	rank := math.Ceil(q * float64(total))
+	var seen float64
+	for i, c := range bins {
+		if c == 0 || seen+float64(c) < rank {
+			seen += float64(c)
+			continue
+		}
+		if i == len(latencyBounds) {
+			return latencyBounds[i-1]
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = latencyBounds[i-1]
+		}
+		return lower + (latencyBounds[i]-lower)*(rank-seen)/float64(c)
+	}
+	return latencyBounds[len(latencyBounds)-1]
+}
@@ -0,0 +1,57 @@
+package health
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := NewStats()
+	s.now = func() time.Time { return now }
+
+	// Half an hour ago: 10 failures.
+	now = now.Add(-30 * time.Minute)
+	for i := 0; i < 10; i++ {
+		s.Record("openai", "gpt-4o", 5*time.Second, true)
+	}
+	// Three minutes ago and now: 90 fast successes, 10 slow ones.
+	now = now.Add(27 * time.Minute)
+	for i := 0; i < 45; i++ {
+		s.Record("openai", "gpt-4o", 80*time.Millisecond, false)
+	}
+	now = now.Add(3 * time.Minute)
+	for i := 0; i < 45; i++ {
+		s.Record("openai", "gpt-4o", 80*time.Millisecond, false)
+	}
+	for i := 0; i < 10; i++ {
+		s.Record("openai", "gpt-4o", 3*time.Second, false)
+	}
+	s.Record("anthropic", "claude-3-5-sonnet", time.Second, false)
+
+	snap := s.Snapshot()
+	if len(snap) != 2 || snap[0].Provider != "anthropic" || snap[1].Model != "gpt-4o" {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+	w := snap[1].Windows
+	if w["1m"].Attempts != 55 || *w["1m"].SuccessRate != 1 {
+		t.Errorf("unexpected 1m window %+v", w["1m"])
+	}
+	if w["5m"].Attempts != 100 || w["1h"].Attempts != 110 || w["1h"].Errors != 10 {
+		t.Errorf("unexpected 5m/1h windows %+v %+v", w["5m"], w["1h"])
+	}
+	if rate := *w["1h"].SuccessRate; math.Abs(rate-100.0/110) > 1e-9 {
+		t.Errorf("unexpected 1h success rate %v", rate)
+	}
+	// The 95th of 100 is among the 3s attempts, in the 2000-4000ms bin.
+	if p95 := *w["5m"].P95LatencyMS; p95 <= 2000 || p95 > 4000 {
+		t.Errorf("expected p95 between 2s and 4s, got %v", p95)
+	}
+
+	// An hour on, nothing is left.
+	now = now.Add(time.Hour)
+	if snap := s.Snapshot(); len(snap) != 0 {
+		t.Errorf("expected expired stats, got %+v", snap)
+	}
+}
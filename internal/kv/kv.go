@@ -0,0 +1,26 @@
+// Package kv is the key-value storage behind the response cache and other
+// gateway state that needs no schema of its own. The backend is picked by
+// KV_STORE: Redis shares entries between replicas, Postgres does so for
+// deployments without Redis, and the in-memory LRU keeps them per instance.
+package kv
+
+import (
+	"context"
+	"time"
+)
+
+// Store holds byte values under string keys. A ttl of zero keeps an entry
+// until it is deleted (or, for the memory store, evicted).
+type Store interface {
+	// Get returns key's value, and false if it is missing or expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX sets key only if it is missing or expired, reporting whether it
+	// did.
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, key string) error
+	// Scan calls fn with each live entry whose key starts with prefix, in
+	// no particular order, stopping at the first error fn returns. fn may
+	// delete the entry it is given.
+	Scan(ctx context.Context, prefix string, fn func(key string, value []byte) error) error
+}
@@ -0,0 +1,130 @@
+package kv
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps entries in this instance, evicting the least recently
+// used once it holds maxEntries.
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List // most recently used first
+	entries    map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time // zero for no expiry
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// NewMemoryStore returns a store of at most maxEntries entries; 0 or less
+// means no bound.
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{maxEntries: maxEntries, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*memoryEntry)
+	if e.expired(time.Now()) {
+		s.remove(el)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, value, ttl)
+	return nil
+}
+
+func (s *MemoryStore) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok && !el.Value.(*memoryEntry).expired(time.Now()) {
+		return false, nil
+	}
+	s.set(key, value, ttl)
+	return true, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Scan(_ context.Context, prefix string, fn func(key string, value []byte) error) error {
+	type entry struct {
+		key   string
+		value []byte
+	}
+	now := time.Now()
+	var matched []entry
+	s.mu.Lock()
+	for key, el := range s.entries {
+		if e := el.Value.(*memoryEntry); strings.HasPrefix(key, prefix) && !e.expired(now) {
+			matched = append(matched, entry{key, e.value})
+		}
+	}
+	s.mu.Unlock()
+
+	for _, m := range matched {
+		if err := fn(m.key, m.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries held, including expired ones not yet
+// dropped.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryStore) set(key string, value []byte, ttl time.Duration) {
+	e := &memoryEntry{key: key, value: value}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	if el, ok := s.entries[key]; ok {
+		el.Value = e
+		s.order.MoveToFront(el)
+		return
+	}
+	s.entries[key] = s.order.PushFront(e)
+	if s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		s.remove(s.order.Back())
+	}
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*memoryEntry).key)
+}
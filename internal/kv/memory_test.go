@@ -0,0 +1,49 @@
+package kv
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(2)
+	s.Set(ctx, "a", []byte("1"), 0)
+	s.Set(ctx, "b", []byte("2"), 0)
+	s.Get(ctx, "a") // b is now the least recently used
+	s.Set(ctx, "c", []byte("3"), 0)
+
+	if _, found, _ := s.Get(ctx, "b"); found {
+		t.Error("b was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, found, _ := s.Get(ctx, key); !found {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+	if s.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", s.Len())
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(0)
+	s.Set(ctx, "k", []byte("v"), 20*time.Millisecond)
+
+	if ok, _ := s.SetNX(ctx, "k", []byte("other"), 0); ok {
+		t.Error("SetNX replaced a live entry")
+	}
+	if v, found, _ := s.Get(ctx, "k"); !found || string(v) != "v" {
+		t.Errorf("expected v, got %q (found %v)", v, found)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, found, _ := s.Get(ctx, "k"); found {
+		t.Error("expired entry was returned")
+	}
+	if ok, _ := s.SetNX(ctx, "k", []byte("other"), 0); !ok {
+		t.Error("SetNX did not replace an expired entry")
+	}
+}
@@ -0,0 +1,135 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore shares entries between replicas through the kv_entries
+// table, for deployments without Redis.
+type PostgresStore struct {
+	db    *pgxpool.Pool
+	swept atomic.Int64 // unix seconds of the last cleanup of expired rows
+}
+
+func NewPostgresStore(databaseURL string) (*PostgresStore, error) {
+	db, err := pgxpool.New(context.Background(), databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var value []byte
+	err := s.db.QueryRow(ctx, `
+		SELECT value FROM kv_entries
+		WHERE key = $1 AND (expires_at IS NULL OR expires_at > NOW())
+	`, key).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s *PostgresStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.sweep()
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO kv_entries (key, value, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at
+	`, key, value, expiresAt(ttl))
+	return err
+}
+
+func (s *PostgresStore) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.sweep()
+	// An expired row still holds the key until it is swept, so it is
+	// replaced as if missing.
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO kv_entries (key, value, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at
+		WHERE kv_entries.expires_at IS NOT NULL AND kv_entries.expires_at <= NOW()
+		RETURNING key
+	`, key, value, expiresAt(ttl)).Scan(&key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, key string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM kv_entries WHERE key = $1`, key)
+	return err
+}
+
+func (s *PostgresStore) Scan(ctx context.Context, prefix string, fn func(key string, value []byte) error) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT key, value FROM kv_entries
+		WHERE starts_with(key, $1) AND (expires_at IS NULL OR expires_at > NOW())
+	`, prefix)
+	if err != nil {
+		return err
+	}
+	// Read every row first: fn may delete, and the pool connection is held
+	// until rows are closed.
+	type entry struct {
+		key   string
+		value []byte
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.key, &e.value); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := fn(e.key, e.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func expiresAt(ttl time.Duration) *time.Time {
+	if ttl <= 0 {
+		return nil
+	}
+	t := time.Now().Add(ttl)
+	return &t
+}
+
+// sweep deletes expired entries, at most once a minute per instance.
+func (s *PostgresStore) sweep() {
+	now := time.Now().Unix()
+	last := s.swept.Load()
+	if now-last < 60 || !s.swept.CompareAndSwap(last, now) {
+		return
+	}
+	go func() {
+		if _, err := s.db.Exec(context.Background(), `DELETE FROM kv_entries WHERE expires_at < NOW()`); err != nil {
+			log.Printf("Warning: failed to delete expired kv entries: %v", err)
+		}
+	}()
+}
+
+func (s *PostgresStore) Close() {
+	s.db.Close()
+}
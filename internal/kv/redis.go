@@ -0,0 +1,68 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore shares entries between replicas through Redis.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(redisURL string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStore{client: redis.NewClient(opts)}, nil
+}
+
+// Ping checks that Redis answers.
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	val, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *RedisStore) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, key).Err()
+}
+
+// Scan walks the keyspace with SCAN, so prefix must not contain glob
+// characters.
+func (s *RedisStore) Scan(ctx context.Context, prefix string, fn func(key string, value []byte) error) error {
+	iter := s.client.Scan(ctx, 0, prefix+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		value, found, err := s.Get(ctx, iter.Val())
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		if err := fn(iter.Val(), value); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
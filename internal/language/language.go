@@ -0,0 +1,117 @@
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Undetermined is returned when there is too little signal to pick a language.
+const Undetermined = "und"
+
+// Names maps the ISO 639-1 codes this package can detect to English names,
+// for use in instructions to the model.
+var Names = map[string]string{
+	"en": "English", "es": "Spanish", "fr": "French", "de": "German",
+	"pt": "Portuguese", "it": "Italian", "nl": "Dutch",
+	"ru": "Russian", "el": "Greek", "ar": "Arabic", "he": "Hebrew",
+	"hi": "Hindi", "th": "Thai", "zh": "Chinese", "ja": "Japanese", "ko": "Korean",
+}
+
+// stopwords are high-frequency function words used to tell Latin-script
+// languages apart.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "to", "of", "in", "that", "it", "you", "for", "with", "this", "was", "what", "how"},
+	"es": {"el", "la", "los", "las", "de", "que", "y", "en", "un", "una", "es", "por", "con", "para", "como", "está"},
+	"fr": {"le", "la", "les", "de", "des", "et", "est", "un", "une", "que", "en", "du", "pour", "dans", "pas", "vous"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "sie", "ich", "es", "auf", "wie"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "do", "da", "em", "um", "uma", "é", "não", "para", "com"},
+	"it": {"il", "la", "di", "che", "e", "è", "un", "una", "per", "non", "sono", "con", "del", "della", "gli", "come"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "met", "voor", "ik", "je", "wat"},
+}
+
+var stopwordIndex = func() map[string][]string {
+	idx := make(map[string][]string)
+	for lang, words := range stopwords {
+		for _, w := range words {
+			idx[w] = append(idx[w], lang)
+		}
+	}
+	return idx
+}()
+
+// Detect guesses the language of text, returning an ISO 639-1 code and a
+// confidence in [0, 1]. Non-Latin scripts are identified by Unicode block;
+// Latin-script languages by stopword frequency.
+func Detect(text string) (string, float64) {
+	scripts := map[string]int{}
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hangul, r):
+			scripts["ko"]++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			scripts["ja"]++
+		case unicode.Is(unicode.Han, r):
+			scripts["zh"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts["ru"]++
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Arabic, r):
+			scripts["ar"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		case unicode.Is(unicode.Devanagari, r):
+			scripts["hi"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		case unicode.Is(unicode.Latin, r):
+			scripts["latin"]++
+		}
+	}
+	if letters == 0 {
+		return Undetermined, 0
+	}
+
+	// Japanese text mixes kana with Han characters.
+	if scripts["ja"] > 0 {
+		scripts["ja"] += scripts["zh"]
+		scripts["zh"] = 0
+	}
+
+	best, bestN := "", 0
+	for s, n := range scripts {
+		if n > bestN {
+			best, bestN = s, n
+		}
+	}
+	if best != "latin" {
+		return best, float64(bestN) / float64(letters)
+	}
+
+	counts := map[string]int{}
+	total := 0
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		for _, lang := range stopwordIndex[w] {
+			counts[lang]++
+			total++
+		}
+	}
+	bestLang, bestCount, second := "", 0, 0
+	for lang, n := range counts {
+		if n > bestCount || (n == bestCount && lang < bestLang) {
+			second = bestCount
+			bestLang, bestCount = lang, n
+		} else if n > second {
+			second = n
+		}
+	}
+	if bestCount < 2 {
+		return Undetermined, 0
+	}
+	return bestLang, float64(bestCount-second) / float64(bestCount)
+}
@@ -0,0 +1,28 @@
+package language
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Can you tell me what the status of my order is and how long it will take?", "en"},
+		{"¿Puedes decirme cuál es el estado de mi pedido y cuánto tiempo va a tardar?", "es"},
+		{"Pouvez-vous me dire où en est ma commande et combien de temps cela va prendre pour la livraison ?", "fr"},
+		{"Können Sie mir sagen, wie der Status meiner Bestellung ist und wie lange es dauert?", "de"},
+		{"Скажите, пожалуйста, каков статус моего заказа?", "ru"},
+		{"注文の状況を教えてください。", "ja"},
+		{"请告诉我我的订单状态。", "zh"},
+		{"주문 상태를 알려주세요.", "ko"},
+		{"ok", Undetermined},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want+"/"+tt.text[:2], func(t *testing.T) {
+			if got, _ := Detect(tt.text); got != tt.want {
+				t.Errorf("Detect(%q) = %s, want %s", tt.text, got, tt.want)
+			}
+		})
+	}
+}
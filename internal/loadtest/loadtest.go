@@ -0,0 +1,223 @@
+package loadtest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	URL         string
+	Concurrency int
+	Requests    int
+	Duration    time.Duration
+	UseCase     string
+	Tenant      string
+	Prompt      string
+	Stream      bool
+}
+
+// Result is the outcome of a single request.
+type Result struct {
+	Status  int
+	Latency time.Duration
+	TTFB    time.Duration
+	Err     error
+}
+
+type Report struct {
+	Total      int
+	Errors     int
+	StatusHist map[int]int
+	Elapsed    time.Duration
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+	TTFBP50    time.Duration
+}
+
+func (r *Report) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Total) / r.Elapsed.Seconds()
+}
+
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "requests:   %d (%d errors)\n", r.Total, r.Errors)
+	fmt.Fprintf(w, "elapsed:    %s (%.1f req/s)\n", r.Elapsed.Round(time.Millisecond), r.Throughput())
+	fmt.Fprintf(w, "latency:    p50=%s p90=%s p99=%s max=%s\n", r.P50, r.P90, r.P99, r.Max)
+	fmt.Fprintf(w, "ttfb p50:   %s\n", r.TTFBP50)
+	codes := make([]int, 0, len(r.StatusHist))
+	for code := range r.StatusHist {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "status %3d: %d\n", code, r.StatusHist[code])
+	}
+}
+
+// Main parses command-line flags and runs a load test, printing the report to
+// stdout. It is the entry point of the "gateway loadtest" subcommand.
+func Main(args []string) error {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	opts := Options{}
+	fs.StringVar(&opts.URL, "url", "http://localhost:8080/v1/chat/completions", "gateway chat completions URL")
+	fs.IntVar(&opts.Concurrency, "c", 10, "number of concurrent workers")
+	fs.IntVar(&opts.Requests, "n", 100, "total requests (ignored when -d is set)")
+	fs.DurationVar(&opts.Duration, "d", 0, "run for this long instead of a fixed request count")
+	fs.StringVar(&opts.UseCase, "use-case", "loadtest", "metadata.use_case to route on (the loadtest route uses the synthetic provider)")
+	fs.StringVar(&opts.Tenant, "tenant", "loadtest", "metadata.tenant to attribute usage to")
+	fs.StringVar(&opts.Prompt, "prompt", "Say hello.", "user prompt to send")
+	fs.BoolVar(&opts.Stream, "stream", false, "use streaming completions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := Run(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	report.Print(os.Stdout)
+	return nil
+}
+
+// Run drives the gateway with the configured concurrency until either the
+// request count or the duration is exhausted.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.Concurrency <= 0 {
+		return nil, fmt.Errorf("concurrency must be positive")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"model":    "",
+		"messages": []map[string]string{{"role": "user", "content": opts.Prompt}},
+		"stream":   opts.Stream,
+		"metadata": map[string]string{"tenant": opts.Tenant, "use_case": opts.UseCase},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	jobs := make(chan struct{})
+	go func() {
+		defer close(jobs)
+		for i := 0; opts.Duration > 0 || i < opts.Requests; i++ {
+			select {
+			case jobs <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	client := &http.Client{}
+	var mu sync.Mutex
+	var results []Result
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				res := do(ctx, client, opts.URL, body, opts.Stream)
+				mu.Lock()
+				results = append(results, res)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return Summarize(results, time.Since(start)), nil
+}
+
+func do(ctx context.Context, client *http.Client, url string, body []byte, stream bool) Result {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return Result{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{Err: err, Latency: time.Since(start)}
+	}
+	defer resp.Body.Close()
+
+	res := Result{Status: resp.StatusCode}
+	if stream {
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if res.TTFB == 0 && strings.HasPrefix(line, "data: ") {
+				res.TTFB = time.Since(start)
+			}
+			if err != nil {
+				break
+			}
+		}
+	} else {
+		io.Copy(io.Discard, resp.Body)
+		res.TTFB = time.Since(start)
+	}
+	res.Latency = time.Since(start)
+	return res
+}
+
+// Summarize aggregates individual results into a report.
+func Summarize(results []Result, elapsed time.Duration) *Report {
+	r := &Report{Total: len(results), Elapsed: elapsed, StatusHist: map[int]int{}}
+	latencies := make([]time.Duration, 0, len(results))
+	ttfbs := make([]time.Duration, 0, len(results))
+	for _, res := range results {
+		if res.Err != nil || res.Status >= 400 {
+			r.Errors++
+		}
+		if res.Err == nil {
+			r.StatusHist[res.Status]++
+		}
+		latencies = append(latencies, res.Latency)
+		ttfbs = append(ttfbs, res.TTFB)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	sort.Slice(ttfbs, func(i, j int) bool { return ttfbs[i] < ttfbs[j] })
+
+	r.P50 = percentile(latencies, 0.50)
+	r.P90 = percentile(latencies, 0.90)
+	r.P99 = percentile(latencies, 0.99)
+	r.TTFBP50 = percentile(ttfbs, 0.50)
+	if len(latencies) > 0 {
+		r.Max = latencies[len(latencies)-1]
+	}
+	return r
+}
+
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(q * float64(len(sorted)-1))
+	return sorted[idx]
+}
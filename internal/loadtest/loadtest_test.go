@@ -0,0 +1,32 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	var results []Result
+	for i := 1; i <= 100; i++ {
+		results = append(results, Result{Status: 200, Latency: time.Duration(i) * time.Millisecond})
+	}
+	results[0].Status = 502
+
+	r := Summarize(results, 2*time.Second)
+
+	if r.Total != 100 {
+		t.Errorf("expected 100 total, got %d", r.Total)
+	}
+	if r.Errors != 1 {
+		t.Errorf("expected 1 error, got %d", r.Errors)
+	}
+	if r.P50 != 50*time.Millisecond {
+		t.Errorf("expected p50 50ms, got %s", r.P50)
+	}
+	if r.Max != 100*time.Millisecond {
+		t.Errorf("expected max 100ms, got %s", r.Max)
+	}
+	if r.Throughput() != 50 {
+		t.Errorf("expected 50 req/s, got %f", r.Throughput())
+	}
+}
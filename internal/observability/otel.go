@@ -16,7 +16,11 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 )
 
-func InitOTEL(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+// InitOTEL installs the global tracer and meter providers. sampleRate is the
+// fraction of new traces exported; incoming traces keep the caller's
+// decision. Spans that end in error are exported even when not sampled.
+// Metrics go to stdout and can be scraped through HandleMetrics.
+func InitOTEL(ctx context.Context, serviceName string, sampleRate float64) (func(context.Context) error, error) {
 	res, err := resource.New(ctx,
 		resource.WithAttributes(
 			semconv.ServiceName(serviceName),
@@ -37,10 +41,17 @@ func InitOTEL(ctx context.Context, serviceName string) (func(context.Context) er
 		return nil, err
 	}
 
-	tp := trace.NewTracerProvider(
+	opts := []trace.TracerProviderOption{
 		trace.WithBatcher(traceExporter),
 		trace.WithResource(res),
-	)
+	}
+	if sampleRate < 1 {
+		opts = append(opts,
+			trace.WithSampler(recordingSampler{trace.ParentBased(trace.TraceIDRatioBased(sampleRate))}),
+			trace.WithSpanProcessor(newErrorSpanProcessor(traceExporter)),
+		)
+	}
+	tp := trace.NewTracerProvider(opts...)
 	otel.SetTracerProvider(tp)
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 
@@ -50,11 +61,15 @@ func InitOTEL(ctx context.Context, serviceName string) (func(context.Context) er
 		return nil, err
 	}
 
+	// /metrics reads the same instruments on each scrape.
+	scrape := metric.NewManualReader()
 	mp := metric.NewMeterProvider(
 		metric.WithReader(metric.NewPeriodicReader(metricExporter)),
+		metric.WithReader(scrape),
 		metric.WithResource(res),
 	)
 	otel.SetMeterProvider(mp)
+	prometheusReader.Store(scrape)
 
 	return func(ctx context.Context) error {
 		if err := tp.Shutdown(ctx); err != nil {
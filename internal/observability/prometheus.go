@@ -0,0 +1,168 @@
+package observability
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// prometheusReader is the reader /metrics collects from, set by InitOTEL.
+var prometheusReader atomic.Pointer[metric.ManualReader]
+
+// HandleMetrics serves the gateway's metrics in the Prometheus text
+// exposition format. It answers 404 until InitOTEL has run.
+func HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	reader := prometheusReader.Load()
+	if reader == nil {
+		http.Error(w, "metrics are not enabled", http.StatusNotFound)
+		return
+	}
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(r.Context(), &rm); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	WritePrometheus(w, rm)
+}
+
+// WritePrometheus writes rm in the Prometheus text exposition format.
+// Names have dots turned into underscores and a unit suffix added, as
+// Prometheus' OpenTelemetry conventions do: gateway.provider.ttft in ms
+// becomes gateway_provider_ttft_milliseconds. Exponential histograms and
+// summaries are left out.
+func WritePrometheus(w io.Writer, rm metricdata.ResourceMetrics) {
+	b := bufio.NewWriter(w)
+	defer b.Flush()
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			name := promName(m.Name, m.Unit)
+			switch data := m.Data.(type) {
+			case metricdata.Histogram[float64]:
+				writeHistogram(b, name, m.Description, data.DataPoints)
+			case metricdata.Histogram[int64]:
+				writeHistogram(b, name, m.Description, data.DataPoints)
+			case metricdata.Sum[float64]:
+				writeSum(b, name, m.Description, data.IsMonotonic, data.DataPoints)
+			case metricdata.Sum[int64]:
+				writeSum(b, name, m.Description, data.IsMonotonic, data.DataPoints)
+			case metricdata.Gauge[float64]:
+				writePoints(b, name, m.Description, "gauge", data.DataPoints)
+			case metricdata.Gauge[int64]:
+				writePoints(b, name, m.Description, "gauge", data.DataPoints)
+			}
+		}
+	}
+}
+
+func writeHistogram[N int64 | float64](b *bufio.Writer, name, help string, points []metricdata.HistogramDataPoint[N]) {
+	writeHeader(b, name, help, "histogram")
+	for _, p := range points {
+		var cumulative uint64
+		for i, bound := range p.Bounds {
+			cumulative += p.BucketCounts[i]
+			fmt.Fprintf(b, "%s_bucket%s %d\n", name, labels(p.Attributes, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", name, labels(p.Attributes, "le", "+Inf"), p.Count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", name, labels(p.Attributes), formatFloat(float64(p.Sum)))
+		fmt.Fprintf(b, "%s_count%s %d\n", name, labels(p.Attributes), p.Count)
+	}
+}
+
+func writeSum[N int64 | float64](b *bufio.Writer, name, help string, monotonic bool, points []metricdata.DataPoint[N]) {
+	if !monotonic {
+		writePoints(b, name, help, "gauge", points)
+		return
+	}
+	writePoints(b, name+"_total", help, "counter", points)
+}
+
+func writePoints[N int64 | float64](b *bufio.Writer, name, help, typ string, points []metricdata.DataPoint[N]) {
+	writeHeader(b, name, help, typ)
+	for _, p := range points {
+		fmt.Fprintf(b, "%s%s %s\n", name, labels(p.Attributes), formatFloat(float64(p.Value)))
+	}
+}
+
+func writeHeader(b *bufio.Writer, name, help, typ string) {
+	if help != "" {
+		help = helpEscaper.Replace(help)
+		fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	}
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, typ)
+}
+
+// promUnits are the suffixes of the units the gateway's instruments use.
+var promUnits = map[string]string{
+	"ms": "milliseconds",
+	"s":  "seconds",
+	"By": "bytes",
+	"1":  "ratio",
+}
+
+// promName makes an instrument name a valid Prometheus metric name with
+// its unit as a suffix. Annotations such as {token}/s carry no unit.
+func promName(name, unit string) string {
+	name = sanitize(name)
+	if suffix, ok := promUnits[unit]; ok && !strings.HasSuffix(name, "_"+suffix) {
+		name += "_" + suffix
+	}
+	return name
+}
+
+func sanitize(s string) string {
+	var out strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			out.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				out.WriteByte('_')
+			}
+			out.WriteRune(r)
+		default:
+			out.WriteByte('_')
+		}
+	}
+	return out.String()
+}
+
+// labels renders a point's attributes, plus any extra name/value pairs,
+// as a Prometheus label set.
+func labels(set attribute.Set, extra ...string) string {
+	var pairs []string
+	iter := set.Iter()
+	for iter.Next() {
+		kv := iter.Attribute()
+		pairs = append(pairs, sanitize(string(kv.Key))+"="+quote(kv.Value.Emit()))
+	}
+	sort.Strings(pairs)
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"="+quote(extra[i+1]))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func quote(v string) string { return `"` + labelEscaper.Replace(v) + `"` }
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,64 @@
+package observability
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	reader := metric.NewManualReader()
+	meter := metric.NewMeterProvider(metric.WithReader(reader)).Meter("test")
+	ctx := context.Background()
+
+	latency, _ := meter.Float64Histogram("gateway.stream.chunk_gap", otelmetric.WithDescription("Gap\nbetween chunks"),
+		otelmetric.WithUnit("ms"), otelmetric.WithExplicitBucketBoundaries(10, 100))
+	attrs := otelmetric.WithAttributes(attribute.String("provider", "openai"), attribute.String("model", `gpt "4o"`))
+	latency.Record(ctx, 5, attrs)
+	latency.Record(ctx, 50, attrs)
+	latency.Record(ctx, 500, attrs)
+	denied, _ := meter.Int64Counter("gateway.retry_budget.exhausted")
+	denied.Add(ctx, 3)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	WritePrometheus(&out, rm)
+	text := out.String()
+
+	for _, line := range []string{
+		`# HELP gateway_stream_chunk_gap_milliseconds Gap\nbetween chunks`,
+		`# TYPE gateway_stream_chunk_gap_milliseconds histogram`,
+		`gateway_stream_chunk_gap_milliseconds_bucket{model="gpt \"4o\"",provider="openai",le="10"} 1`,
+		`gateway_stream_chunk_gap_milliseconds_bucket{model="gpt \"4o\"",provider="openai",le="100"} 2`,
+		`gateway_stream_chunk_gap_milliseconds_bucket{model="gpt \"4o\"",provider="openai",le="+Inf"} 3`,
+		`gateway_stream_chunk_gap_milliseconds_sum{model="gpt \"4o\"",provider="openai"} 555`,
+		`gateway_stream_chunk_gap_milliseconds_count{model="gpt \"4o\"",provider="openai"} 3`,
+		`# TYPE gateway_retry_budget_exhausted_total counter`,
+		`gateway_retry_budget_exhausted_total 3`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("missing line %s in:\n%s", line, text)
+		}
+	}
+}
+
+func TestPromName(t *testing.T) {
+	tests := map[[2]string]string{
+		{"gateway.provider.ttft", "ms"}:                   "gateway_provider_ttft_milliseconds",
+		{"gateway.stream.tokens_per_second", "{token}/s"}: "gateway_stream_tokens_per_second",
+		{"1xx-count", ""}:                                 "_1xx_count",
+	}
+	for in, want := range tests {
+		if got := promName(in[0], in[1]); got != want {
+			t.Errorf("promName(%q, %q) = %q, want %q", in[0], in[1], got, want)
+		}
+	}
+}
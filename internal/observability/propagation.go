@@ -0,0 +1,29 @@
+package observability
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// ExtractTraceContext continues any W3C trace (traceparent/tracestate) and
+// baggage the client sent, so gateway spans join the caller's trace.
+func ExtractTraceContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// TraceHeaders returns the propagation headers for the span in ctx, ready to
+// be set on an outbound request. It returns nil when there is nothing to send.
+func TraceHeaders(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
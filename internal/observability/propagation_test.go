@@ -0,0 +1,46 @@
+package observability
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTraceContextRoundTrip(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tracer := trace.NewTracerProvider().Tracer("test")
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var got map[string]string
+	h := ExtractTraceContext(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "child")
+		defer span.End()
+		got = TraceHeaders(ctx)
+	}))
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("traceparent", parent)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	tp := got["traceparent"]
+	if len(tp) != len(parent) {
+		t.Fatalf("expected a traceparent header, got %q", tp)
+	}
+	if tp[3:35] != parent[3:35] {
+		t.Errorf("expected trace id to be preserved, got %q", tp)
+	}
+	if tp[36:52] == parent[36:52] {
+		t.Errorf("expected the gateway span as parent, got %q", tp)
+	}
+}
+
+func TestTraceHeadersEmptyWithoutSpan(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if h := TraceHeaders(httptest.NewRequest("GET", "/", nil).Context()); h != nil {
+		t.Errorf("expected no headers, got %v", h)
+	}
+}
@@ -0,0 +1,82 @@
+package observability
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// recordingSampler records spans the wrapped sampler would drop instead of
+// discarding them, so errorSpanProcessor can still export the failures.
+// Unsampled spans are never exported by the regular batcher.
+type recordingSampler struct {
+	trace.Sampler
+}
+
+func (s recordingSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	res := s.Sampler.ShouldSample(p)
+	if res.Decision == trace.Drop {
+		res.Decision = trace.RecordOnly
+	}
+	return res
+}
+
+func (s recordingSampler) Description() string {
+	return "RecordOnDrop{" + s.Sampler.Description() + "}"
+}
+
+// errorSpanProcessor exports spans that were not sampled but ended with an
+// error status. Exports run on a single goroutine; if it falls behind, spans
+// are dropped rather than blocking requests.
+type errorSpanProcessor struct {
+	exporter trace.SpanExporter
+	queue    chan trace.ReadOnlySpan
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newErrorSpanProcessor(exporter trace.SpanExporter) *errorSpanProcessor {
+	p := &errorSpanProcessor{
+		exporter: exporter,
+		queue:    make(chan trace.ReadOnlySpan, 512),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *errorSpanProcessor) run() {
+	defer close(p.done)
+	for s := range p.queue {
+		if err := p.exporter.ExportSpans(context.Background(), []trace.ReadOnlySpan{s}); err != nil {
+			log.Printf("failed to export error span: %v", err)
+		}
+	}
+}
+
+func (p *errorSpanProcessor) OnStart(context.Context, trace.ReadWriteSpan) {}
+
+func (p *errorSpanProcessor) OnEnd(s trace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() || s.Status().Code != codes.Error {
+		return
+	}
+	select {
+	case p.queue <- s:
+	default:
+	}
+}
+
+func (p *errorSpanProcessor) Shutdown(ctx context.Context) error {
+	p.once.Do(func() { close(p.queue) })
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *errorSpanProcessor) ForceFlush(context.Context) error { return nil }
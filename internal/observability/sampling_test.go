@@ -0,0 +1,34 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestErroredSpansExportedWhenNotSampled(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := trace.NewTracerProvider(
+		trace.WithSyncer(exporter),
+		trace.WithSampler(recordingSampler{trace.NeverSample()}),
+		trace.WithSpanProcessor(newErrorSpanProcessor(exporter)),
+	)
+	tracer := tp.Tracer("test")
+
+	_, ok := tracer.Start(context.Background(), "ok")
+	ok.End()
+	_, failed := tracer.Start(context.Background(), "failed")
+	failed.SetStatus(codes.Error, "boom")
+	failed.End()
+
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "failed" {
+		t.Fatalf("expected only the failed span, got %v", spans)
+	}
+}
@@ -0,0 +1,74 @@
+package payloads
+
+import (
+	"context"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// KMS wraps the per-tenant data keys payloads are encrypted with. Wrap
+// returns a reference to the key-encryption key it used, stored alongside
+// the wrapped key so Unwrap can find it again after keys rotate. A cloud
+// KMS plugs in by implementing it.
+type KMS interface {
+	Wrap(ctx context.Context, tenant string, dataKey []byte) (keyRef string, wrapped []byte, err error)
+	Unwrap(ctx context.Context, keyRef string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKMS derives a key-encryption key per tenant from a master key held
+// by the gateway.
+type LocalKMS struct {
+	master []byte
+}
+
+const localRefPrefix = "local:"
+
+// NewLocalKMS takes a base64-encoded master key of at least 32 bytes.
+func NewLocalKMS(masterKey string) (*LocalKMS, error) {
+	master, err := base64.StdEncoding.DecodeString(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("master key must be base64: %w", err)
+	}
+	if len(master) < 32 {
+		return nil, fmt.Errorf("master key must be at least 32 bytes, got %d", len(master))
+	}
+	return &LocalKMS{master: master}, nil
+}
+
+func (k *LocalKMS) kek(tenant string) ([]byte, error) {
+	return hkdf.Key(sha256.New, k.master, nil, "ai-gateway payload kek "+tenant, 32)
+}
+
+func (k *LocalKMS) Wrap(ctx context.Context, tenant string, dataKey []byte) (string, []byte, error) {
+	kek, err := k.kek(tenant)
+	if err != nil {
+		return "", nil, err
+	}
+	wrapped, err := seal(kek, dataKey, []byte(tenant))
+	return localRefPrefix + tenant, wrapped, err
+}
+
+func (k *LocalKMS) Unwrap(ctx context.Context, keyRef string, wrapped []byte) ([]byte, error) {
+	tenant, ok := strings.CutPrefix(keyRef, localRefPrefix)
+	if !ok {
+		return nil, fmt.Errorf("key %q was not wrapped by the local KMS", keyRef)
+	}
+	kek, err := k.kek(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return open(kek, wrapped, []byte(tenant))
+}
+
+// NewKMS builds the KMS named by kind. Only "local" is built in.
+func NewKMS(kind, masterKey string) (KMS, error) {
+	switch kind {
+	case "", "local":
+		return NewLocalKMS(masterKey)
+	default:
+		return nil, fmt.Errorf("unknown payload KMS %q", kind)
+	}
+}
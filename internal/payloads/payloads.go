@@ -0,0 +1,145 @@
+// Package payloads encrypts captured prompts and completions at rest with
+// envelope encryption: each tenant's payloads are sealed with its own data
+// key, and data keys are stored only wrapped by a KMS.
+package payloads
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrNoDataKey is returned by a KeyStore when a tenant has no data key yet.
+var ErrNoDataKey = errors.New("no data key")
+
+// DataKey is a tenant's data key as stored: wrapped by the KMS key keyRef.
+type DataKey struct {
+	ID      int64
+	Tenant  string
+	KeyRef  string
+	Wrapped []byte
+}
+
+// KeyStore persists wrapped data keys.
+type KeyStore interface {
+	// ActiveDataKey returns the key new payloads of tenant are sealed with.
+	ActiveDataKey(ctx context.Context, tenant string) (DataKey, error)
+	// CreateDataKey stores k as tenant's active key. If another instance
+	// created one first, that key is returned instead.
+	CreateDataKey(ctx context.Context, k DataKey) (DataKey, error)
+	DataKey(ctx context.Context, id int64) (DataKey, error)
+}
+
+// Sealer encrypts and decrypts payloads. Unwrapped data keys are cached in
+// memory, so the KMS is called once per key and process.
+type Sealer struct {
+	kms  KMS
+	keys KeyStore
+
+	mu    sync.Mutex
+	plain map[int64][]byte
+}
+
+func NewSealer(kms KMS, keys KeyStore) *Sealer {
+	return &Sealer{kms: kms, keys: keys, plain: make(map[int64][]byte)}
+}
+
+// Seal encrypts plaintext for tenant and returns the id of the data key
+// used with the ciphertext.
+func (s *Sealer) Seal(ctx context.Context, tenant string, plaintext []byte) (int64, []byte, error) {
+	k, err := s.keys.ActiveDataKey(ctx, tenant)
+	if errors.Is(err, ErrNoDataKey) {
+		k, err = s.createKey(ctx, tenant)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	key, err := s.unwrap(ctx, k)
+	if err != nil {
+		return 0, nil, err
+	}
+	ciphertext, err := seal(key, plaintext, []byte(tenant))
+	return k.ID, ciphertext, err
+}
+
+// Open decrypts a payload sealed for tenant with data key keyID.
+func (s *Sealer) Open(ctx context.Context, tenant string, keyID int64, ciphertext []byte) ([]byte, error) {
+	k, err := s.keys.DataKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if k.Tenant != tenant {
+		return nil, fmt.Errorf("data key %d does not belong to tenant %q", keyID, tenant)
+	}
+	key, err := s.unwrap(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	return open(key, ciphertext, []byte(tenant))
+}
+
+func (s *Sealer) createKey(ctx context.Context, tenant string) (DataKey, error) {
+	plain := make([]byte, 32)
+	if _, err := rand.Read(plain); err != nil {
+		return DataKey{}, err
+	}
+	ref, wrapped, err := s.kms.Wrap(ctx, tenant, plain)
+	if err != nil {
+		return DataKey{}, fmt.Errorf("wrap data key: %w", err)
+	}
+	return s.keys.CreateDataKey(ctx, DataKey{Tenant: tenant, KeyRef: ref, Wrapped: wrapped})
+}
+
+func (s *Sealer) unwrap(ctx context.Context, k DataKey) ([]byte, error) {
+	s.mu.Lock()
+	key, ok := s.plain[k.ID]
+	s.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	key, err := s.kms.Unwrap(ctx, k.KeyRef, k.Wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key %d: %w", k.ID, err)
+	}
+	s.mu.Lock()
+	s.plain[k.ID] = key
+	s.mu.Unlock()
+	return key, nil
+}
+
+// seal encrypts with AES-256-GCM, binding aad, and prepends the nonce.
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, body := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, body, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
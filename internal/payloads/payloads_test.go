@@ -0,0 +1,98 @@
+package payloads
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+)
+
+type memKeys struct {
+	keys []DataKey
+}
+
+func (m *memKeys) ActiveDataKey(ctx context.Context, tenant string) (DataKey, error) {
+	for _, k := range m.keys {
+		if k.Tenant == tenant {
+			return k, nil
+		}
+	}
+	return DataKey{}, ErrNoDataKey
+}
+
+func (m *memKeys) CreateDataKey(ctx context.Context, k DataKey) (DataKey, error) {
+	k.ID = int64(len(m.keys) + 1)
+	m.keys = append(m.keys, k)
+	return k, nil
+}
+
+func (m *memKeys) DataKey(ctx context.Context, id int64) (DataKey, error) {
+	return m.keys[id-1], nil
+}
+
+type countingKMS struct {
+	KMS
+	unwraps int
+}
+
+func (c *countingKMS) Unwrap(ctx context.Context, ref string, wrapped []byte) ([]byte, error) {
+	c.unwraps++
+	return c.KMS.Unwrap(ctx, ref, wrapped)
+}
+
+func testKMS(t *testing.T, seed byte) *LocalKMS {
+	t.Helper()
+	kms, err := NewLocalKMS(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{seed}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kms
+}
+
+func TestSealOpenPerTenant(t *testing.T) {
+	ctx := context.Background()
+	keys := &memKeys{}
+	kms := &countingKMS{KMS: testKMS(t, 1)}
+	s := NewSealer(kms, keys)
+
+	acmeKey, ct, err := s.Seal(ctx, "acme", []byte("my prompt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(ct, []byte("my prompt")) {
+		t.Error("expected the payload to be encrypted")
+	}
+	globexKey, _, _ := s.Seal(ctx, "globex", []byte("other"))
+	if acmeKey == globexKey || len(keys.keys) != 2 {
+		t.Errorf("expected one data key per tenant, got %d keys", len(keys.keys))
+	}
+	if keys.keys[0].KeyRef != "local:acme" {
+		t.Errorf("unexpected key ref %q", keys.keys[0].KeyRef)
+	}
+
+	got, err := s.Open(ctx, "acme", acmeKey, ct)
+	if err != nil || string(got) != "my prompt" {
+		t.Fatalf("expected round trip, got %q, %v", got, err)
+	}
+	if _, err := s.Open(ctx, "globex", acmeKey, ct); err == nil {
+		t.Error("expected a payload not to open for another tenant")
+	}
+	if kms.unwraps != 2 {
+		t.Errorf("expected each data key to be unwrapped once, got %d unwraps", kms.unwraps)
+	}
+
+	// A fresh process with the wrong master key cannot read old payloads.
+	other := NewSealer(testKMS(t, 2), keys)
+	if _, err := other.Open(ctx, "acme", acmeKey, ct); err == nil {
+		t.Error("expected a different master key to fail")
+	}
+}
+
+func TestNewLocalKMSRejectsShortKeys(t *testing.T) {
+	if _, err := NewLocalKMS(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("expected a short master key to be rejected")
+	}
+	if _, err := NewKMS("vault", ""); err == nil {
+		t.Error("expected an unknown KMS to be rejected")
+	}
+}
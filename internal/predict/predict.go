@@ -0,0 +1,65 @@
+// Package predict estimates how long completions for a use case run, from
+// the completion lengths recorded for it, so that requests can be given a
+// max_tokens close to what they will actually use.
+package predict
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Query selects the history a prediction is made from: the Percentile
+// (0-1] of completion lengths recorded for UseCase over the last Lookback.
+type Query struct {
+	UseCase    string
+	Percentile float64
+	Lookback   time.Duration
+}
+
+// Loader returns the completion length at q's percentile and how many
+// completions it was computed from.
+type Loader func(ctx context.Context, useCase string, percentile float64, since time.Time) (tokens, samples int, err error)
+
+// Estimator caches predictions per query, reloading them after ttl.
+type Estimator struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	load    Loader
+	entries map[string]entry
+}
+
+type entry struct {
+	tokens   int
+	samples  int
+	loadedAt time.Time
+}
+
+func New(ttl time.Duration, load Loader) *Estimator {
+	return &Estimator{ttl: ttl, load: load, entries: make(map[string]entry)}
+}
+
+// Predict returns the completion length at q's percentile, if at least
+// minSamples completions back it. On a load error the previous prediction,
+// if any, keeps being served.
+func (e *Estimator) Predict(ctx context.Context, q Query, minSamples int) (int, bool) {
+	key := fmt.Sprintf("%s|%g|%s", q.UseCase, q.Percentile, q.Lookback)
+	e.mu.Lock()
+	cached, ok := e.entries[key]
+	e.mu.Unlock()
+
+	if !ok || time.Since(cached.loadedAt) >= e.ttl {
+		tokens, samples, err := e.load(ctx, q.UseCase, q.Percentile, time.Now().Add(-q.Lookback))
+		if err == nil {
+			cached = entry{tokens: tokens, samples: samples, loadedAt: time.Now()}
+			e.mu.Lock()
+			e.entries[key] = cached
+			e.mu.Unlock()
+		}
+	}
+	if cached.samples < minSamples || cached.tokens <= 0 {
+		return 0, false
+	}
+	return cached.tokens, true
+}
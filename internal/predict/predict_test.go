@@ -0,0 +1,43 @@
+package predict
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPredict(t *testing.T) {
+	var loads int
+	var fail bool
+	e := New(time.Hour, func(ctx context.Context, useCase string, percentile float64, since time.Time) (int, int, error) {
+		loads++
+		if fail {
+			return 0, 0, errors.New("db down")
+		}
+		if useCase == "chat" {
+			return 300, 120, nil
+		}
+		return 40, 3, nil
+	})
+	ctx := context.Background()
+	q := Query{UseCase: "chat", Percentile: 0.99, Lookback: 24 * time.Hour}
+
+	if tokens, ok := e.Predict(ctx, q, 50); !ok || tokens != 300 {
+		t.Fatalf("got %d, %v", tokens, ok)
+	}
+	e.Predict(ctx, q, 50)
+	if loads != 1 {
+		t.Errorf("expected the prediction to be cached, loaded %d times", loads)
+	}
+	if _, ok := e.Predict(ctx, Query{UseCase: "rare", Percentile: 0.99}, 50); ok {
+		t.Error("expected no prediction from too few samples")
+	}
+
+	// A failed reload keeps the last prediction.
+	e.ttl = 0
+	fail = true
+	if tokens, ok := e.Predict(ctx, q, 50); !ok || tokens != 300 {
+		t.Errorf("expected the cached prediction on a load error, got %d, %v", tokens, ok)
+	}
+}
@@ -0,0 +1,189 @@
+// Package probe sends configured synthetic prompts through the routes on a
+// schedule and checks the completions, so that a provider answering
+// slowly or wrongly is noticed before users report it.
+package probe
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/yewintnaing/ai-gateway/internal/alerting"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+const probeJob = "probes"
+
+// Result is the outcome of one probe sent to one target of a route.
+type Result struct {
+	Probe     string    `json:"probe"`
+	Route     string    `json:"route"`
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	RequestID string    `json:"request_id,omitempty"`
+	LatencyMS int       `json:"latency_ms"`
+	Passed    bool      `json:"passed"`
+	Failure   string    `json:"failure,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store keeps the results and claims intervals so that one instance
+// probes each.
+type Store interface {
+	RecordProbe(ctx context.Context, r Result) error
+	ClaimReport(ctx context.Context, name, period string) (bool, error)
+}
+
+// Caller sends prompt to target as a request of route, recorded as
+// probe, and returns the completion and the id the request was logged
+// under. It is expected to report call failures to provider health.
+type Caller func(ctx context.Context, probe string, route config.Route, target config.Target, prompt string, maxTokens int) (completion, requestID string, err error)
+
+type check struct {
+	config.Probe
+	matches *regexp.Regexp
+}
+
+// Runner runs the probes.
+type Runner struct {
+	store    Store
+	call     Caller
+	routes   func() []config.Route
+	alerts   *alerting.Alerter
+	checks   []check
+	interval time.Duration
+	latency  metric.Float64Histogram
+	now      func() time.Time
+}
+
+// New validates cfg and returns a runner for it, or nil when no probes
+// are configured. routes lists the routes in effect at each run.
+func New(store Store, cfg config.Probes, routes func() []config.Route, call Caller, alerts *alerting.Alerter) (*Runner, error) {
+	if len(cfg.Checks) == 0 {
+		return nil, nil
+	}
+	r := &Runner{store: store, call: call, routes: routes, alerts: alerts, now: time.Now}
+	seen := map[string]bool{}
+	for _, p := range cfg.Checks {
+		if p.Name == "" || p.Prompt == "" {
+			return nil, fmt.Errorf("probes need a name and a prompt")
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("probe %s: duplicate name", p.Name)
+		}
+		seen[p.Name] = true
+		c := check{Probe: p}
+		if p.Matches != "" {
+			re, err := regexp.Compile(p.Matches)
+			if err != nil {
+				return nil, fmt.Errorf("probe %s: matches: %w", p.Name, err)
+			}
+			c.matches = re
+		}
+		r.checks = append(r.checks, c)
+	}
+	r.interval = time.Duration(cfg.IntervalSec) * time.Second
+	if r.interval <= 0 {
+		r.interval = 5 * time.Minute
+	}
+	r.latency, _ = otel.Meter("gateway-probes").Float64Histogram("gateway.probe.latency",
+		metric.WithDescription("Latency of synthetic probes by probe, route, target and outcome"), metric.WithUnit("ms"))
+	return r, nil
+}
+
+// Run sends every probe to every target of its routes, one at a time,
+// and records the results. A failed probe is reported to alerting as a
+// probe_failure event of the target's provider.
+func (r *Runner) Run(ctx context.Context) []Result {
+	var results []Result
+	for _, c := range r.checks {
+		for _, route := range r.routes() {
+			if len(c.Routes) > 0 && !slices.Contains(c.Routes, route.Name) {
+				continue
+			}
+			for _, target := range append([]config.Target{route.Primary}, route.Fallbacks...) {
+				res := r.probe(ctx, c, route, target)
+				results = append(results, res)
+				if err := r.store.RecordProbe(ctx, res); err != nil {
+					log.Printf("Warning: failed to record probe %s: %v", c.Name, err)
+				}
+				if r.latency != nil {
+					r.latency.Record(ctx, float64(res.LatencyMS), metric.WithAttributes(
+						attribute.String("probe", c.Name), attribute.String("route", route.Name),
+						attribute.String("provider", target.Provider), attribute.String("model", target.Model),
+						attribute.Bool("passed", res.Passed),
+					))
+				}
+				if !res.Passed {
+					log.Printf("Probe %s failed on %s/%s of route %s: %s", c.Name, target.Provider, target.Model, route.Name, res.Failure)
+					r.alerts.Observe(alerting.Event{
+						Kind: alerting.KindProbeFailure, Provider: target.Provider, Route: route.Name, Failed: true,
+						Detail: fmt.Sprintf("probe %s on %s/%s: %s", c.Name, target.Provider, target.Model, res.Failure),
+					})
+				}
+			}
+		}
+	}
+	return results
+}
+
+func (r *Runner) probe(ctx context.Context, c check, route config.Route, target config.Target) Result {
+	start := r.now()
+	completion, requestID, err := r.call(ctx, c.Name, route, target, c.Prompt, c.MaxTokens)
+	res := Result{
+		Probe: c.Name, Route: route.Name, Provider: target.Provider, Model: target.Model,
+		RequestID: requestID, LatencyMS: int(r.now().Sub(start).Milliseconds()), CreatedAt: start,
+	}
+	res.Failure = c.verify(completion, res.LatencyMS, err)
+	res.Passed = res.Failure == ""
+	return res
+}
+
+// verify returns why a probe's outcome fails its assertions, or "".
+func (c check) verify(completion string, latencyMS int, err error) string {
+	switch {
+	case err != nil:
+		return err.Error()
+	case c.Contains != "" && !strings.Contains(completion, c.Contains):
+		return fmt.Sprintf("completion does not contain %q", c.Contains)
+	case c.matches != nil && !c.matches.MatchString(completion):
+		return fmt.Sprintf("completion does not match %q", c.Matches)
+	case c.MaxLatencyMS > 0 && latencyMS > c.MaxLatencyMS:
+		return fmt.Sprintf("took %dms, over %dms", latencyMS, c.MaxLatencyMS)
+	}
+	return ""
+}
+
+// Start runs the probes once per interval across all instances, until ctx
+// is cancelled.
+func (r *Runner) Start(ctx context.Context) {
+	ticker := time.NewTicker(min(r.interval, time.Minute))
+	defer ticker.Stop()
+	var last time.Time
+	for {
+		period := r.now().UTC().Truncate(r.interval)
+		if !period.Equal(last) {
+			if claimed, err := r.store.ClaimReport(ctx, probeJob, period.Format(time.RFC3339)); err != nil {
+				log.Printf("Warning: probe claim failed: %v", err)
+			} else {
+				last = period
+				if claimed {
+					r.Run(ctx)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+type fakeStore struct {
+	results []Result
+	claims  map[string]bool
+}
+
+func (f *fakeStore) RecordProbe(ctx context.Context, r Result) error {
+	f.results = append(f.results, r)
+	return nil
+}
+
+func (f *fakeStore) ClaimReport(ctx context.Context, name, period string) (bool, error) {
+	if f.claims[period] {
+		return false, nil
+	}
+	f.claims[period] = true
+	return true, nil
+}
+
+func TestRun(t *testing.T) {
+	routes := []config.Route{
+		{Name: "chat", Primary: config.Target{Provider: "openai", Model: "gpt-4o"}, Fallbacks: []config.Target{{Provider: "anthropic", Model: "claude"}}},
+		{Name: "code", Primary: config.Target{Provider: "mistral", Model: "codestral"}},
+	}
+	answers := map[string]string{"openai": "The capital is Paris.", "anthropic": "paris", "mistral": "Paris"}
+	call := func(ctx context.Context, probe string, route config.Route, target config.Target, prompt string, maxTokens int) (string, string, error) {
+		if target.Provider == "mistral" {
+			return "", "req-" + target.Provider, errors.New("upstream 503")
+		}
+		return answers[target.Provider], "req-" + target.Provider, nil
+	}
+	store := &fakeStore{}
+	r, err := New(store, config.Probes{Checks: []config.Probe{
+		{Name: "capital", Routes: []string{"chat"}, Prompt: "Capital of France?", Contains: "Paris"},
+		{Name: "any", Prompt: "Say hi", Matches: `(?i)^p`},
+	}}, func() []config.Route { return routes }, call, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := r.Run(context.Background())
+	type key struct{ probe, provider string }
+	got := map[key]Result{}
+	for _, res := range results {
+		got[key{res.Probe, res.Provider}] = res
+	}
+	if len(results) != 5 || len(store.results) != 5 {
+		t.Fatalf("expected 2 targets of chat for capital and 3 for any, got %+v", results)
+	}
+	if res := got[key{"capital", "openai"}]; !res.Passed || res.RequestID != "req-openai" {
+		t.Errorf("expected openai to pass capital, got %+v", res)
+	}
+	if res := got[key{"capital", "anthropic"}]; res.Passed || res.Failure != `completion does not contain "Paris"` {
+		t.Errorf("expected anthropic to fail capital, got %+v", res)
+	}
+	if res := got[key{"any", "openai"}]; res.Passed {
+		t.Errorf("expected openai not to match, got %+v", res)
+	}
+	if res := got[key{"any", "mistral"}]; res.Passed || res.Failure != "upstream 503" || res.Route != "code" {
+		t.Errorf("expected the call failure recorded, got %+v", res)
+	}
+}
+
+func TestMaxLatency(t *testing.T) {
+	c := check{Probe: config.Probe{MaxLatencyMS: 100}}
+	if reason := c.verify("ok", 250, nil); reason != "took 250ms, over 100ms" {
+		t.Errorf("unexpected reason %q", reason)
+	}
+	if reason := c.verify("ok", 50, nil); reason != "" {
+		t.Errorf("expected a pass, got %q", reason)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if r, err := New(&fakeStore{}, config.Probes{}, nil, nil, nil); r != nil || err != nil {
+		t.Errorf("expected no runner without probes, got %v, %v", r, err)
+	}
+	for _, checks := range [][]config.Probe{
+		{{Name: "a"}},
+		{{Name: "a", Prompt: "x"}, {Name: "a", Prompt: "y"}},
+		{{Name: "a", Prompt: "x", Matches: "("}},
+	} {
+		if _, err := New(&fakeStore{}, config.Probes{Checks: checks}, nil, nil, nil); err == nil {
+			t.Errorf("expected %+v to be refused", checks)
+		}
+	}
+}
+
+func TestStartClaimsEachInterval(t *testing.T) {
+	calls := 0
+	call := func(ctx context.Context, probe string, route config.Route, target config.Target, prompt string, maxTokens int) (string, string, error) {
+		calls++
+		return "ok", "", nil
+	}
+	store := &fakeStore{claims: map[string]bool{}}
+	routes := []config.Route{{Name: "chat", Primary: config.Target{Provider: "openai", Model: "gpt-4o"}}}
+	r, err := New(store, config.Probes{IntervalSec: 300, Checks: []config.Probe{{Name: "ok", Prompt: "x"}}}, func() []config.Route { return routes }, call, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.now = func() time.Time { return time.Date(2024, 3, 1, 12, 3, 0, 0, time.UTC) }
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.Start(ctx)
+	r.Start(ctx) // another instance, same interval
+	if calls != 1 || !store.claims["2024-03-01T12:00:00Z"] {
+		t.Errorf("expected one run for the 12:00 interval, got %d calls and claims %v", calls, store.claims)
+	}
+}
@@ -17,7 +17,7 @@ type Provider struct {
 	apiKey  string
 	baseURL string
 	version string
-	client  *http.Client
+	client  *providers.Client
 }
 
 func NewProvider(apiKey string, baseURL string, version string) *Provider {
@@ -25,44 +25,320 @@ func NewProvider(apiKey string, baseURL string, version string) *Provider {
 		apiKey:  apiKey,
 		baseURL: baseURL,
 		version: version,
-		client:  &http.Client{Timeout: 30 * time.Second},
+		client:  providers.NewClient(nil),
 	}
 }
 
+// messagesRequest is the wire format of the Anthropic Messages API.
+type messagesRequest struct {
+	Model         string      `json:"model"`
+	System        string      `json:"system,omitempty"`
+	Messages      []message   `json:"messages"`
+	MaxTokens     int         `json:"max_tokens"`
+	Temperature   float64     `json:"temperature"`
+	TopP          *float64    `json:"top_p,omitempty"`
+	StopSequences []string    `json:"stop_sequences,omitempty"`
+	Stream        bool        `json:"stream"`
+	Tools         []tool      `json:"tools,omitempty"`
+	ToolChoice    *toolChoice `json:"tool_choice,omitempty"`
+
+	Thinking *providers.Thinking `json:"thinking,omitempty"`
+}
+
+// message content is either a plain string or a list of content blocks.
+type message struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	Thinking  string          `json:"thinking,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+// effortBudgets are the thinking budgets OpenAI reasoning efforts map to.
+var effortBudgets = map[string]int{"low": 1024, "medium": 4096, "high": 16384}
+
+// thinkingFor returns the thinking config of a request, if it asks for any.
+func thinkingFor(req providers.ChatRequest) (*providers.Thinking, error) {
+	if req.Thinking != nil {
+		return req.Thinking, nil
+	}
+	if req.ReasoningEffort == "" {
+		return nil, nil
+	}
+	budget, ok := effortBudgets[req.ReasoningEffort]
+	if !ok {
+		return nil, &providers.UnsupportedParamError{Provider: "anthropic", Param: "reasoning_effort"}
+	}
+	return &providers.Thinking{Type: "enabled", BudgetTokens: budget}, nil
+}
+
+type tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type toolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// toMessagesRequest translates an OpenAI-shaped request into the Messages API
+// format. System messages are lifted into the top-level system field, and a
+// trailing assistant message is kept as a prefill for the completion. Tool
+// calls become tool_use blocks and tool messages tool_result blocks.
+// Parameters with no Anthropic equivalent are rejected rather than dropped.
+func toMessagesRequest(req providers.ChatRequest) (*messagesRequest, error) {
+	switch {
+	case len(req.LogitBias) > 0:
+		return nil, &providers.UnsupportedParamError{Provider: "anthropic", Param: "logit_bias"}
+	case req.PresencePenalty != nil:
+		return nil, &providers.UnsupportedParamError{Provider: "anthropic", Param: "presence_penalty"}
+	case req.FrequencyPenalty != nil:
+		return nil, &providers.UnsupportedParamError{Provider: "anthropic", Param: "frequency_penalty"}
+	case req.Seed != nil:
+		return nil, &providers.UnsupportedParamError{Provider: "anthropic", Param: "seed"}
+	case req.N > 1:
+		return nil, &providers.UnsupportedParamError{Provider: "anthropic", Param: "n"}
+	}
+
+	out := &messagesRequest{
+		Model:         req.Model,
+		MaxTokens:     req.MaxTokens,
+		Temperature:   req.TemperatureValue(),
+		TopP:          req.TopP,
+		StopSequences: req.Stop,
+		Stream:        req.Stream,
+	}
+
+	thinking, err := thinkingFor(req)
+	if err != nil {
+		return nil, err
+	}
+	if thinking != nil && thinking.Type == "enabled" {
+		// Thinking needs temperature 1 and room for the answer after the
+		// budget.
+		out.Thinking = thinking
+		out.Temperature = 1
+		out.TopP = nil
+		if out.MaxTokens <= thinking.BudgetTokens {
+			out.MaxTokens = thinking.BudgetTokens + 1024
+		}
+	}
+
+	if out.MaxTokens <= 0 {
+		return nil, &providers.ConstraintError{Provider: "anthropic", Constraint: "max_tokens; set it on the request or in the provider's request_defaults"}
+	}
+
+	for _, t := range req.Tools {
+		schema := t.Function.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		out.Tools = append(out.Tools, tool{Name: t.Function.Name, Description: t.Function.Description, InputSchema: schema})
+	}
+	if len(req.ToolChoice) > 0 {
+		choice, err := toToolChoice(req.ToolChoice)
+		if err != nil {
+			return nil, err
+		}
+		out.ToolChoice = choice
+	}
+
+	var system []string
+	var blocks [][]contentBlock
+	var roles []string
+	for _, m := range req.Messages {
+		var content []contentBlock
+		role := m.Role
+		switch {
+		case m.Role == "system":
+			system = append(system, m.Content)
+			continue
+		case m.Role == "tool":
+			role = "user"
+			content = []contentBlock{{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content}}
+		default:
+			// Earlier thinking goes back first, signed, so the model can
+			// continue from it; unsigned reasoning cannot be replayed.
+			if m.Role == "assistant" && m.Reasoning != "" && m.ReasoningSignature != "" {
+				content = append(content, contentBlock{Type: "thinking", Thinking: m.Reasoning, Signature: m.ReasoningSignature})
+			}
+			if m.Content != "" || len(m.ToolCalls) == 0 {
+				content = append(content, contentBlock{Type: "text", Text: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				if !json.Valid(input) {
+					return nil, fmt.Errorf("tool call %s has invalid arguments", tc.ID)
+				}
+				content = append(content, contentBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+			}
+		}
+
+		// Tool results must sit in a single user turn, followed by any text
+		// the user adds.
+		if n := len(blocks); n > 0 && role == "user" && roles[n-1] == "user" && hasToolResult(blocks[n-1]) {
+			blocks[n-1] = append(blocks[n-1], content...)
+			continue
+		}
+		blocks = append(blocks, content)
+		roles = append(roles, role)
+	}
+	out.System = strings.Join(system, "\n\n")
+	if len(blocks) == 0 {
+		return nil, &providers.ConstraintError{Provider: "anthropic", Constraint: "at least one user or assistant message"}
+	}
+
+	for i, content := range blocks {
+		if len(content) == 1 && content[0].Type == "text" {
+			out.Messages = append(out.Messages, message{Role: roles[i], Content: content[0].Text})
+		} else {
+			out.Messages = append(out.Messages, message{Role: roles[i], Content: content})
+		}
+	}
+
+	// Anthropic rejects a prefill that ends in whitespace.
+	if n := len(out.Messages); n > 0 && out.Messages[n-1].Role == "assistant" {
+		if text, ok := out.Messages[n-1].Content.(string); ok {
+			out.Messages[n-1].Content = strings.TrimRight(text, " \t\r\n")
+		}
+	}
+
+	return out, nil
+}
+
+func hasToolResult(blocks []contentBlock) bool {
+	for _, b := range blocks {
+		if b.Type == "tool_result" {
+			return true
+		}
+	}
+	return false
+}
+
+// toToolChoice maps OpenAI's tool_choice ("auto", "none", "required" or a
+// named function) onto Anthropic's.
+func toToolChoice(raw json.RawMessage) (*toolChoice, error) {
+	var mode string
+	if err := json.Unmarshal(raw, &mode); err == nil {
+		switch mode {
+		case "auto":
+			return &toolChoice{Type: "auto"}, nil
+		case "none":
+			return &toolChoice{Type: "none"}, nil
+		case "required":
+			return &toolChoice{Type: "any"}, nil
+		}
+		return nil, &providers.UnsupportedParamError{Provider: "anthropic", Param: "tool_choice"}
+	}
+
+	var named struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Type != "function" || named.Function.Name == "" {
+		return nil, &providers.UnsupportedParamError{Provider: "anthropic", Param: "tool_choice"}
+	}
+	return &toolChoice{Type: "tool", Name: named.Function.Name}, nil
+}
+
+// messagesResponse is a non-streaming Messages API reply.
+type messagesResponse struct {
+	ID         string                  `json:"id"`
+	Role       string                  `json:"role"`
+	Content    []contentBlock          `json:"content"`
+	Model      string                  `json:"model"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      providers.AntropicUsage `json:"usage"`
+}
+
+// toChatResponse joins the reply's text blocks into the message content and
+// turns tool_use blocks into tool calls. Thinking becomes the message's
+// reasoning; the API counts it in output tokens without breaking it out, so
+// the reasoning share is estimated from its length.
+func (m *messagesResponse) toChatResponse() *providers.ChatResponse {
+	msg := providers.Message{Role: m.Role}
+	var text, thinking []string
+	for _, b := range m.Content {
+		switch b.Type {
+		case "text":
+			text = append(text, b.Text)
+		case "thinking":
+			thinking = append(thinking, b.Thinking)
+			msg.ReasoningSignature = b.Signature
+		case "tool_use":
+			args := string(b.Input)
+			if args == "" {
+				args = "{}"
+			}
+			msg.ToolCalls = append(msg.ToolCalls, providers.ToolCall{
+				ID:       b.ID,
+				Type:     "function",
+				Function: providers.ToolCallFunction{Name: b.Name, Arguments: args},
+			})
+		}
+	}
+	msg.Content = strings.Join(text, "")
+	msg.Reasoning = strings.Join(thinking, "\n\n")
+
+	resp := &providers.ChatResponse{
+		ID:      m.ID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   m.Model,
+		Choices: make([]struct {
+			Index        int               `json:"index"`
+			Message      providers.Message `json:"message"`
+			FinishReason string            `json:"finish_reason"`
+		}, 1),
+		Usage: providers.Usage{
+			PromptTokens:     m.Usage.InputTokens,
+			CompletionTokens: m.Usage.OutputTokens,
+			TotalTokens:      m.Usage.InputTokens + m.Usage.OutputTokens,
+		},
+	}
+	if msg.Reasoning != "" {
+		resp.Usage.CompletionTokensDetails = &providers.CompletionTokensDetails{
+			ReasoningTokens: min(len(msg.Reasoning)/4, m.Usage.OutputTokens),
+		}
+	}
+	resp.Choices[0].Message = msg
+	resp.Choices[0].FinishReason = providers.NormalizeFinishReason(m.StopReason)
+	return resp
+}
+
+// statusError reads a failed response into a StatusError.
+func statusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &providers.StatusError{Provider: "anthropic", Code: resp.StatusCode, Message: string(msg)}
+}
+
 func (p *Provider) Chat(req providers.ChatRequest) (*providers.ChatResponse, error) {
 	if p.apiKey == "" {
 		return nil, fmt.Errorf("ANTHROPIC_API_KEY is not set")
 	}
 
-	if p.apiKey == "mock" {
-		return &providers.ChatResponse{
-			ID:      "mock-anthropic-id",
-			Object:  "chat.completion",
-			Created: time.Now().Unix(),
-			Model:   req.Model,
-			Choices: []struct {
-				Index        int               `json:"index"`
-				Message      providers.Message `json:"message"`
-				FinishReason string            `json:"finish_reason"`
-			}{
-				{
-					Index: 0,
-					Message: providers.Message{
-						Role:    "assistant",
-						Content: fmt.Sprintf("Anthropic Mock: %s", req.Messages[len(req.Messages)-1].Content),
-					},
-					FinishReason: "stop",
-				},
-			},
-			Usage: providers.Usage{
-				PromptTokens:     15,
-				CompletionTokens: 25,
-				TotalTokens:      40,
-			},
-		}, nil
+	msgReq, err := toMessagesRequest(req)
+	if err != nil {
+		return nil, err
 	}
 
-	body, err := json.Marshal(req)
+	body, err := req.MarshalBody(msgReq)
 	if err != nil {
 		return nil, err
 	}
@@ -72,27 +348,27 @@ func (p *Provider) Chat(req providers.ChatRequest) (*providers.ChatResponse, err
 		return nil, err
 	}
 
+	req.SetHeaders(httpReq.Header)
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-API-Key", p.apiKey)
 	httpReq.Header.Set("Anthropic-Version", p.version)
 
-	resp, err := p.client.Do(httpReq)
+	resp, err := p.client.Do(req, httpReq)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("anthropic API error: %d - %s", resp.StatusCode, string(bodyBytes))
+		return nil, statusError(resp)
 	}
 
-	var chatResponse providers.AnthropicResponse
+	var chatResponse messagesResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResponse); err != nil {
 		return nil, err
 	}
 
-	return chatResponse.ToChatResponse(), nil
+	return chatResponse.toChatResponse(), nil
 
 }
 
@@ -100,61 +376,14 @@ func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatC
 	chunkCh := make(chan providers.ChatChunk)
 	errCh := make(chan error, 1)
 
-	if p.apiKey == "mock" {
-		go func() {
-			defer close(chunkCh)
-			defer close(errCh)
-			content := fmt.Sprintf("Anthropic Mock Stream: %s", req.Messages[len(req.Messages)-1].Content)
-			words := strings.Split(content, " ")
-			for i, word := range words {
-				chunkCh <- providers.ChatChunk{
-					ID:      "mock-anth-stream-id",
-					Object:  "chat.completion.chunk",
-					Created: time.Now().Unix(),
-					Model:   req.Model,
-					Choices: []struct {
-						Index int `json:"index"`
-						Delta struct {
-							Content string `json:"content"`
-						} `json:"delta"`
-						FinishReason string `json:"finish_reason"`
-					}{
-						{
-							Index: 0,
-							Delta: struct {
-								Content string `json:"content"`
-							}{Content: word + " "},
-						},
-					},
-				}
-				if i == len(words)-1 {
-					chunkCh <- providers.ChatChunk{
-						ID:      "mock-anth-stream-id",
-						Object:  "chat.completion.chunk",
-						Created: time.Now().Unix(),
-						Model:   req.Model,
-						Choices: []struct {
-							Index int `json:"index"`
-							Delta struct {
-								Content string `json:"content"`
-							} `json:"delta"`
-							FinishReason string `json:"finish_reason"`
-						}{
-							{
-								Index:        0,
-								FinishReason: "stop",
-							},
-						},
-					}
-				}
-				time.Sleep(50 * time.Millisecond)
-			}
-		}()
+	req.Stream = true
+	msgReq, err := toMessagesRequest(req)
+	if err != nil {
+		errCh <- err
 		return chunkCh, errCh
 	}
 
-	req.Stream = true
-	body, err := json.Marshal(req)
+	body, err := req.MarshalBody(msgReq)
 	if err != nil {
 		errCh <- err
 		return chunkCh, errCh
@@ -164,17 +393,18 @@ func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatC
 		defer close(chunkCh)
 		defer close(errCh)
 
-		httpReq, err := http.NewRequest("POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(body))
+		httpReq, err := http.NewRequest("POST", p.baseURL+"/messages", bytes.NewBuffer(body))
 		if err != nil {
 			errCh <- err
 			return
 		}
 
+		req.SetHeaders(httpReq.Header)
 		httpReq.Header.Set("Content-Type", "application/json")
 		httpReq.Header.Set("X-API-Key", p.apiKey)
 		httpReq.Header.Set("Anthropic-Version", p.version)
 
-		resp, err := p.client.Do(httpReq)
+		resp, err := p.client.Do(req, httpReq)
 		if err != nil {
 			errCh <- err
 			return
@@ -182,9 +412,7 @@ func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatC
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
-			var errData map[string]interface{}
-			json.NewDecoder(resp.Body).Decode(&errData)
-			errCh <- fmt.Errorf("anthropic streaming error (status %d): %v", resp.StatusCode, errData)
+			errCh <- statusError(resp)
 			return
 		}
 
@@ -193,11 +421,30 @@ func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatC
 		var messageID string
 		var model string
 		var created int64
+		// toolIndex maps content block indexes to tool call indexes.
+		toolIndex := map[int]int{}
+		chunk := func(delta providers.ChunkDelta, finish string) providers.ChatChunk {
+			return providers.ChatChunk{
+				ID:      messageID,
+				Object:  "chat.completion.chunk",
+				Created: created,
+				Model:   model,
+				Choices: []providers.ChunkChoice{{Delta: delta, FinishReason: finish}},
+			}
+		}
+
+		// fail reports a read error; a stream ending at EOF is not one.
+		fail := func(err error) {
+			if err != io.EOF {
+				errCh <- err
+			}
+		}
 
 		for {
 			line, err := reader.ReadString('\n')
 			if err != nil {
-				break
+				fail(err)
+				return
 			}
 
 			line = strings.TrimSpace(line)
@@ -214,7 +461,8 @@ func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatC
 				// Read the next line for data
 				dataLine, err := reader.ReadString('\n')
 				if err != nil {
-					break
+					fail(err)
+					return
 				}
 				if after, ok := strings.CutPrefix(dataLine, "data: "); ok {
 					eventData = after
@@ -234,33 +482,43 @@ func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatC
 				model = msgStart.Message.Model
 				created = time.Now().Unix()
 
+			case "content_block_start":
+				var block providers.AnthropicContentBlockStart
+				if err := json.Unmarshal([]byte(eventData), &block); err != nil {
+					continue
+				}
+
+				// A tool_use block opens a tool call; its arguments follow as
+				// input_json_delta fragments.
+				if block.ContentBlock.Type == "tool_use" {
+					toolIndex[block.Index] = len(toolIndex)
+					chunkCh <- chunk(providers.ChunkDelta{ToolCalls: []providers.ToolCallDelta{{
+						Index:    toolIndex[block.Index],
+						ID:       block.ContentBlock.ID,
+						Type:     "function",
+						Function: providers.ToolCallFunction{Name: block.ContentBlock.Name},
+					}}}, "")
+				}
+
 			case "content_block_delta":
 				var delta providers.AnthropicContentBlockDelta
 				if err := json.Unmarshal([]byte(eventData), &delta); err != nil {
 					continue
 				}
 
-				// Only process text deltas
-				if delta.Delta.Type == "text_delta" {
-					chunkCh <- providers.ChatChunk{
-						ID:      messageID,
-						Object:  "chat.completion.chunk",
-						Created: created,
-						Model:   model,
-						Choices: []struct {
-							Index int `json:"index"`
-							Delta struct {
-								Content string `json:"content"`
-							} `json:"delta"`
-							FinishReason string `json:"finish_reason"`
-						}{
-							{
-								Index: delta.Index,
-								Delta: struct {
-									Content string `json:"content"`
-								}{Content: delta.Delta.Text},
-							},
-						},
+				switch delta.Delta.Type {
+				case "text_delta":
+					chunkCh <- chunk(providers.ChunkDelta{Content: delta.Delta.Text}, "")
+				case "thinking_delta":
+					chunkCh <- chunk(providers.ChunkDelta{Reasoning: delta.Delta.Thinking}, "")
+				case "signature_delta":
+					chunkCh <- chunk(providers.ChunkDelta{ReasoningSignature: delta.Delta.Signature}, "")
+				case "input_json_delta":
+					if i, ok := toolIndex[delta.Index]; ok && delta.Delta.PartialJSON != "" {
+						chunkCh <- chunk(providers.ChunkDelta{ToolCalls: []providers.ToolCallDelta{{
+							Index:    i,
+							Function: providers.ToolCallFunction{Arguments: delta.Delta.PartialJSON},
+						}}}, "")
 					}
 				}
 
@@ -272,24 +530,7 @@ func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatC
 
 				// Send final chunk with finish_reason
 				if msgDelta.Delta.StopReason != "" {
-					chunkCh <- providers.ChatChunk{
-						ID:      messageID,
-						Object:  "chat.completion.chunk",
-						Created: created,
-						Model:   model,
-						Choices: []struct {
-							Index int `json:"index"`
-							Delta struct {
-								Content string `json:"content"`
-							} `json:"delta"`
-							FinishReason string `json:"finish_reason"`
-						}{
-							{
-								Index:        0,
-								FinishReason: msgDelta.Delta.StopReason,
-							},
-						},
-					}
+					chunkCh <- chunk(providers.ChunkDelta{}, providers.NormalizeFinishReason(msgDelta.Delta.StopReason))
 				}
 
 			case "message_stop":
@@ -0,0 +1,264 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestToMessagesRequest(t *testing.T) {
+	t.Run("Lifts system prompt and keeps prefill", func(t *testing.T) {
+		topP := 0.9
+		req := providers.ChatRequest{
+			Model: "claude-3-5-sonnet",
+			Messages: []providers.Message{
+				{Role: "system", Content: "Be terse."},
+				{Role: "user", Content: "Name a colour."},
+				{Role: "assistant", Content: "The colour is "},
+			},
+			MaxTokens: 100,
+			TopP:      &topP,
+			Stop:      providers.StopSequences{"\n"},
+		}
+
+		out, err := toMessagesRequest(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.System != "Be terse." {
+			t.Errorf("expected system 'Be terse.', got '%s'", out.System)
+		}
+		if len(out.Messages) != 2 {
+			t.Fatalf("expected 2 messages, got %d", len(out.Messages))
+		}
+		if out.Messages[1].Content != "The colour is" {
+			t.Errorf("expected trimmed prefill, got '%s'", out.Messages[1].Content)
+		}
+		if len(out.StopSequences) != 1 || out.StopSequences[0] != "\n" {
+			t.Errorf("expected stop sequences to pass through, got %v", out.StopSequences)
+		}
+		if out.TopP == nil || *out.TopP != 0.9 {
+			t.Errorf("expected top_p 0.9, got %v", out.TopP)
+		}
+	})
+
+	t.Run("Rejects unsupported parameters", func(t *testing.T) {
+		seed := 42
+		req := providers.ChatRequest{
+			Model:    "claude-3-5-sonnet",
+			Messages: []providers.Message{{Role: "user", Content: "hi"}},
+			Seed:     &seed,
+		}
+
+		_, err := toMessagesRequest(req)
+		var unsupported *providers.UnsupportedParamError
+		if !errors.As(err, &unsupported) {
+			t.Fatalf("expected UnsupportedParamError, got %v", err)
+		}
+		if unsupported.Param != "seed" {
+			t.Errorf("expected param 'seed', got '%s'", unsupported.Param)
+		}
+	})
+
+	t.Run("Names the constraint a request breaks", func(t *testing.T) {
+		_, err := toMessagesRequest(providers.ChatRequest{Messages: []providers.Message{{Role: "user", Content: "hi"}}})
+		var constraint *providers.ConstraintError
+		if !errors.As(err, &constraint) || !strings.HasPrefix(err.Error(), "anthropic requires max_tokens") {
+			t.Fatalf("expected the max_tokens constraint, got %v", err)
+		}
+		_, err = toMessagesRequest(providers.ChatRequest{MaxTokens: 100, Messages: []providers.Message{{Role: "system", Content: "Be brief."}}})
+		if !errors.As(err, &constraint) || constraint.Constraint != "at least one user or assistant message" {
+			t.Errorf("expected the message constraint, got %v", err)
+		}
+	})
+}
+
+func TestToMessagesRequestTools(t *testing.T) {
+	req := providers.ChatRequest{
+		Model:     "claude-3-5-sonnet",
+		MaxTokens: 256,
+		Messages: []providers.Message{
+			{Role: "user", Content: "Weather in Paris and Rome?"},
+			{Role: "assistant", ToolCalls: []providers.ToolCall{
+				{ID: "call_1", Type: "function", Function: providers.ToolCallFunction{Name: "weather", Arguments: `{"city":"Paris"}`}},
+				{ID: "call_2", Type: "function", Function: providers.ToolCallFunction{Name: "weather", Arguments: `{"city":"Rome"}`}},
+			}},
+			{Role: "tool", ToolCallID: "call_1", Content: "18C"},
+			{Role: "tool", ToolCallID: "call_2", Content: "24C"},
+			{Role: "user", Content: "Which is warmer?"},
+		},
+		Tools: []providers.Tool{{Type: "function", Function: providers.ToolFunction{
+			Name: "weather", Parameters: json.RawMessage(`{"type":"object"}`),
+		}}},
+		ToolChoice: json.RawMessage(`{"type":"function","function":{"name":"weather"}}`),
+	}
+
+	out, err := toMessagesRequest(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out.Tools) != 1 || out.Tools[0].Name != "weather" || string(out.Tools[0].InputSchema) != `{"type":"object"}` {
+		t.Errorf("unexpected tools: %+v", out.Tools)
+	}
+	if out.ToolChoice == nil || out.ToolChoice.Type != "tool" || out.ToolChoice.Name != "weather" {
+		t.Errorf("unexpected tool_choice: %+v", out.ToolChoice)
+	}
+	if len(out.Messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(out.Messages))
+	}
+
+	calls, ok := out.Messages[1].Content.([]contentBlock)
+	if !ok || len(calls) != 2 || calls[0].Type != "tool_use" || calls[1].ID != "call_2" || string(calls[1].Input) != `{"city":"Rome"}` {
+		t.Errorf("unexpected assistant blocks: %+v", out.Messages[1].Content)
+	}
+
+	results, ok := out.Messages[2].Content.([]contentBlock)
+	if !ok || len(results) != 3 {
+		t.Fatalf("expected tool results and text in one user turn, got %+v", out.Messages[2].Content)
+	}
+	if results[0].Type != "tool_result" || results[0].ToolUseID != "call_1" || results[1].Content != "24C" || results[2].Text != "Which is warmer?" {
+		t.Errorf("unexpected user blocks: %+v", results)
+	}
+}
+
+func TestToToolChoice(t *testing.T) {
+	for raw, want := range map[string]string{`"auto"`: "auto", `"none"`: "none", `"required"`: "any"} {
+		got, err := toToolChoice(json.RawMessage(raw))
+		if err != nil || got.Type != want {
+			t.Errorf("%s: expected %s, got %+v (%v)", raw, want, got, err)
+		}
+	}
+	if _, err := toToolChoice(json.RawMessage(`"sometimes"`)); err == nil {
+		t.Error("expected unknown tool_choice to be rejected")
+	}
+}
+
+func TestMessagesResponseToChatResponse(t *testing.T) {
+	var m messagesResponse
+	body := `{"id":"msg_1","role":"assistant","model":"claude-3-5-sonnet","stop_reason":"tool_use",
+		"content":[{"type":"text","text":"Checking."},{"type":"tool_use","id":"toolu_1","name":"weather","input":{"city":"Paris"}}],
+		"usage":{"input_tokens":10,"output_tokens":5}}`
+	if err := json.Unmarshal([]byte(body), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := m.toChatResponse()
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" {
+		t.Errorf("expected finish reason tool_calls, got %s", choice.FinishReason)
+	}
+	if choice.Message.Content != "Checking." {
+		t.Errorf("unexpected content %q", choice.Message.Content)
+	}
+	if len(choice.Message.ToolCalls) != 1 || choice.Message.ToolCalls[0].ID != "toolu_1" || choice.Message.ToolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool calls: %+v", choice.Message.ToolCalls)
+	}
+	if resp.Usage.TotalTokens != 15 {
+		t.Errorf("expected 15 total tokens, got %d", resp.Usage.TotalTokens)
+	}
+}
+
+func TestChatStreamToolUse(t *testing.T) {
+	events := []string{
+		`event: message_start` + "\n" + `data: {"type":"message_start","message":{"id":"msg_1","model":"claude-3-5-sonnet"}}`,
+		`event: content_block_start` + "\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking."}}`,
+		`event: content_block_start` + "\n" + `data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"weather","input":{}}}`,
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+		`event: message_delta` + "\n" + `data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":12}}`,
+		`event: message_stop` + "\n" + `data: {"type":"message_stop"}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			fmt.Fprintf(w, "%s\n\n", e)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewProvider("key", srv.URL, "2023-06-01")
+	chunkCh, errCh := p.ChatStream(providers.ChatRequest{Model: "claude-3-5-sonnet", MaxTokens: 256, Messages: []providers.Message{{Role: "user", Content: "hi"}}})
+
+	var text, args, name, finish string
+	for c := range chunkCh {
+		d := c.Choices[0].Delta
+		text += d.Content
+		for _, tc := range d.ToolCalls {
+			if tc.Index != 0 {
+				t.Errorf("expected tool call index 0, got %d", tc.Index)
+			}
+			name += tc.Function.Name
+			args += tc.Function.Arguments
+		}
+		if c.Choices[0].FinishReason != "" {
+			finish = c.Choices[0].FinishReason
+		}
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != "Checking." || name != "weather" || args != `{"city":"Paris"}` || finish != "tool_calls" {
+		t.Errorf("unexpected stream: text=%q name=%q args=%q finish=%q", text, name, args, finish)
+	}
+}
+
+func TestThinking(t *testing.T) {
+	t.Run("Maps reasoning effort onto a budget", func(t *testing.T) {
+		out, err := toMessagesRequest(providers.ChatRequest{
+			Model: "claude-sonnet-4", Messages: []providers.Message{{Role: "user", Content: "hi"}},
+			MaxTokens: 1000, Temperature: func() *float64 { v := 0.2; return &v }(), ReasoningEffort: "medium",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Thinking == nil || out.Thinking.BudgetTokens != 4096 {
+			t.Fatalf("expected a 4096 token budget, got %+v", out.Thinking)
+		}
+		if out.Temperature != 1 || out.MaxTokens <= 4096 {
+			t.Errorf("expected temperature 1 and room after the budget, got %v and %d", out.Temperature, out.MaxTokens)
+		}
+		if _, err := toMessagesRequest(providers.ChatRequest{ReasoningEffort: "extreme"}); err == nil {
+			t.Error("expected an unknown effort to be rejected")
+		}
+	})
+
+	t.Run("Sends signed thinking back", func(t *testing.T) {
+		out, err := toMessagesRequest(providers.ChatRequest{MaxTokens: 256, Messages: []providers.Message{
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", Content: "Hello.", Reasoning: "They greeted me.", ReasoningSignature: "sig"},
+			{Role: "user", Content: "again"},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks, ok := out.Messages[1].Content.([]contentBlock)
+		if !ok || len(blocks) != 2 || blocks[0].Type != "thinking" || blocks[0].Signature != "sig" {
+			t.Errorf("expected a signed thinking block before the text, got %+v", out.Messages[1].Content)
+		}
+	})
+
+	t.Run("Returns thinking as reasoning", func(t *testing.T) {
+		var m messagesResponse
+		body := `{"id":"msg_1","role":"assistant","model":"claude-sonnet-4","stop_reason":"end_turn",
+			"content":[{"type":"thinking","thinking":"Two plus two is four, a simple sum.","signature":"sig"},{"type":"text","text":"4"}],
+			"usage":{"input_tokens":10,"output_tokens":20}}`
+		if err := json.Unmarshal([]byte(body), &m); err != nil {
+			t.Fatal(err)
+		}
+		resp := m.toChatResponse()
+		msg := resp.Choices[0].Message
+		if msg.Content != "4" || msg.Reasoning == "" || msg.ReasoningSignature != "sig" {
+			t.Errorf("unexpected message %+v", msg)
+		}
+		if got := resp.Usage.ReasoningTokens(); got != 8 {
+			t.Errorf("expected 8 estimated reasoning tokens, got %d", got)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/providers/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	event := func(w io.Writer, name string, data interface{}) {
+		body, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, body)
+	}
+	conformance.Run(t, conformance.Upstream{
+		New: func(baseURL string) providers.Provider {
+			return NewProvider("key", baseURL, "2023-06-01")
+		},
+		Reply: func(w http.ResponseWriter, content string, usage providers.Usage) {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":          "msg_1",
+				"type":        "message",
+				"role":        "assistant",
+				"content":     []interface{}{map[string]string{"type": "text", "text": content}},
+				"stop_reason": "end_turn",
+				"usage":       map[string]int{"input_tokens": usage.PromptTokens, "output_tokens": usage.CompletionTokens},
+			})
+		},
+		StreamStart: func(w io.Writer) {
+			event(w, "message_start", map[string]interface{}{
+				"type":    "message_start",
+				"message": map[string]string{"id": "msg_1", "model": "conformance-model"},
+			})
+			event(w, "content_block_start", map[string]interface{}{
+				"type":          "content_block_start",
+				"index":         0,
+				"content_block": map[string]string{"type": "text", "text": ""},
+			})
+		},
+		StreamDelta: func(w io.Writer, text string) {
+			event(w, "content_block_delta", map[string]interface{}{
+				"type":  "content_block_delta",
+				"index": 0,
+				"delta": map[string]string{"type": "text_delta", "text": text},
+			})
+		},
+		StreamEnd: func(w io.Writer) {
+			event(w, "content_block_stop", map[string]interface{}{"type": "content_block_stop", "index": 0})
+			event(w, "message_delta", map[string]interface{}{
+				"type":  "message_delta",
+				"delta": map[string]string{"stop_reason": "end_turn"},
+			})
+			event(w, "message_stop", map[string]string{"type": "message_stop"})
+		},
+		Error: func(w http.ResponseWriter, status int) {
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"api_error","message":"upstream failure"}}`)
+		},
+	})
+}
@@ -0,0 +1,23 @@
+package anthropic
+
+import (
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func init() {
+	// The Messages API requires max_tokens.
+	providers.RegisterDefaults("anthropic", map[string]interface{}{"max_tokens": 1024})
+	providers.Register("anthropic", func(name string, opts config.ProviderOptions) (providers.Provider, error) {
+		baseURL, version := opts.BaseURL, opts.APIVersion
+		if baseURL == "" {
+			baseURL = "https://api.anthropic.com/v1"
+		}
+		if version == "" {
+			version = "2023-06-01"
+		}
+		p := NewProvider(opts.APIKey(), baseURL, version)
+		p.client = providers.NewClient(opts.Timeouts)
+		return p, nil
+	})
+}
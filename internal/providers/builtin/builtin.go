@@ -0,0 +1,13 @@
+// Package builtin links in the provider types that ship with the gateway.
+// Import it for its side effects; a new provider package only needs a line
+// here.
+package builtin
+
+import (
+	_ "github.com/yewintnaing/ai-gateway/internal/providers/anthropic"
+	_ "github.com/yewintnaing/ai-gateway/internal/providers/cohere"
+	_ "github.com/yewintnaing/ai-gateway/internal/providers/mistral"
+	_ "github.com/yewintnaing/ai-gateway/internal/providers/mock"
+	_ "github.com/yewintnaing/ai-gateway/internal/providers/openai"
+	_ "github.com/yewintnaing/ai-gateway/internal/providers/synthetic"
+)
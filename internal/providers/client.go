@@ -0,0 +1,186 @@
+package providers
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+// DefaultTimeout bounds a provider call whose request sets no Timeout.
+const DefaultTimeout = 30 * time.Second
+
+// acceptEncoding is advertised on every provider call. Responses in either
+// encoding are decoded before the provider reads them, even when a
+// forwarded client header asked for something else.
+const acceptEncoding = "gzip, deflate"
+
+// Client sends a provider's HTTP calls under its configured timeouts.
+type Client struct {
+	http  *http.Client
+	idle  time.Duration
+	total time.Duration
+}
+
+// NewClient returns a client for a provider with the given timeouts, which
+// may be nil.
+func NewClient(t *config.ProviderTimeouts) *Client {
+	if t == nil {
+		return &Client{http: &http.Client{}}
+	}
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: ms(t.DialMS), KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = ms(t.TLSMS)
+	transport.ResponseHeaderTimeout = ms(t.ResponseHeaderMS)
+	return &Client{
+		http:  &http.Client{Transport: transport},
+		idle:  ms(t.IdleMS),
+		total: ms(t.TotalMS),
+	}
+}
+
+// Do sends httpReq for req. The call is bounded by req's Timeout, lowered
+// to the provider's total timeout; the deadline keeps running while the
+// response body is read and ends when it is closed.
+func (c *Client) Do(req ChatRequest, httpReq *http.Request) (*http.Response, error) {
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if c.total > 0 && c.total < timeout {
+		timeout = c.total
+	}
+	ctx, cancel := context.WithTimeout(httpReq.Context(), timeout)
+	httpReq.Header.Set("Accept-Encoding", acceptEncoding)
+	resp, err := c.http.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if c.idle > 0 {
+		resp.Body = newIdleBody(resp.Body, c.idle, cancel)
+	} else {
+		resp.Body = cancelOnClose{resp.Body, cancel}
+	}
+	decodeBody(resp)
+	return resp, nil
+}
+
+// decodeBody replaces a gzip or deflate encoded body with the decoded one.
+// The decoder is only created on the first read, so a stream's first chunk
+// is not waited for here.
+func decodeBody(resp *http.Response) {
+	var open func(io.Reader) (io.ReadCloser, error)
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		open = func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+	case "deflate":
+		open = zlib.NewReader
+	default:
+		return
+	}
+	resp.Body = &decodedBody{rc: resp.Body, open: open}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+type decodedBody struct {
+	rc   io.ReadCloser
+	open func(io.Reader) (io.ReadCloser, error)
+	dec  io.ReadCloser
+	err  error
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.dec == nil && b.err == nil {
+		b.dec, b.err = b.open(b.rc)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.dec.Read(p)
+}
+
+func (b *decodedBody) Close() error {
+	if b.dec != nil {
+		b.dec.Close()
+	}
+	return b.rc.Close()
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// errIdle is returned when a response goes quiet for longer than the
+// provider's idle timeout. It reports itself as a timeout, so the attempt
+// is retried or failed over like any other.
+type errIdle struct{}
+
+func (errIdle) Error() string   { return "provider sent nothing within the idle timeout" }
+func (errIdle) Timeout() bool   { return true }
+func (errIdle) Temporary() bool { return true }
+
+// idleBody cancels the call when a read waits longer than idle.
+type idleBody struct {
+	rc     io.ReadCloser
+	idle   time.Duration
+	cancel context.CancelFunc
+	timer  *time.Timer
+
+	mu    sync.Mutex
+	fired bool
+}
+
+func newIdleBody(rc io.ReadCloser, idle time.Duration, cancel context.CancelFunc) *idleBody {
+	b := &idleBody{rc: rc, idle: idle, cancel: cancel}
+	b.timer = time.AfterFunc(idle, b.expire)
+	b.timer.Stop()
+	return b
+}
+
+func (b *idleBody) expire() {
+	b.mu.Lock()
+	b.fired = true
+	b.mu.Unlock()
+	b.cancel()
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.idle)
+	n, err := b.rc.Read(p)
+	b.timer.Stop()
+	if err != nil {
+		b.mu.Lock()
+		fired := b.fired
+		b.mu.Unlock()
+		if fired {
+			return n, errIdle{}
+		}
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	err := b.rc.Close()
+	b.cancel()
+	return err
+}
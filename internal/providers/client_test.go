@@ -0,0 +1,118 @@
+package providers
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+// slowBody sends one chunk, then stalls until the client gives up.
+func slowBody(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("first"))
+	w.(http.Flusher).Flush()
+	select {
+	case <-r.Context().Done():
+	case <-time.After(time.Second):
+	}
+}
+
+func TestClientTotalTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(slowBody))
+	defer srv.Close()
+
+	httpReq, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := NewClient(nil).Do(ChatRequest{Timeout: 50 * time.Millisecond}, httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	// The deadline also covers reading the body.
+	if _, err := io.ReadAll(resp.Body); !os.IsTimeout(err) {
+		t.Errorf("expected a timeout while reading the body, got %v", err)
+	}
+
+	// The provider's total timeout lowers the request's.
+	c := NewClient(&config.ProviderTimeouts{TotalMS: 50})
+	httpReq, _ = http.NewRequest("GET", srv.URL, nil)
+	start := time.Now()
+	resp, err = c.Do(ChatRequest{Timeout: time.Minute}, httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	io.ReadAll(resp.Body)
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("expected total_ms to cut the call short, took %v", time.Since(start))
+	}
+}
+
+func TestClientIdleTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(slowBody))
+	defer srv.Close()
+
+	c := NewClient(&config.ProviderTimeouts{IdleMS: 50})
+	httpReq, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := c.Do(ChatRequest{Timeout: time.Minute}, httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if string(body) != "first" {
+		t.Errorf("expected the chunk sent before the stall, got %q", body)
+	}
+	if _, ok := err.(errIdle); !ok || !os.IsTimeout(err) {
+		t.Errorf("expected an idle timeout, got %v", err)
+	}
+}
+
+func TestClientResponseHeaderTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(&config.ProviderTimeouts{ResponseHeaderMS: 50})
+	httpReq, _ := http.NewRequest("GET", srv.URL, nil)
+	if _, err := c.Do(ChatRequest{Timeout: time.Minute}, httpReq); err == nil {
+		t.Fatal("expected a response header timeout")
+	}
+}
+
+func TestClientDecodesBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != acceptEncoding {
+			t.Errorf("got Accept-Encoding %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(`{"id":"chatcmpl-1"}`))
+		gz.Close()
+	}))
+	defer srv.Close()
+
+	httpReq, _ := http.NewRequest("POST", srv.URL, nil)
+	// A forwarded client header must not leave the body encoded.
+	httpReq.Header.Set("Accept-Encoding", "br")
+	resp, err := NewClient(nil).Do(ChatRequest{}, httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != `{"id":"chatcmpl-1"}` {
+		t.Errorf("got %q, %v", body, err)
+	}
+	if resp.Header.Get("Content-Encoding") != "" {
+		t.Error("expected Content-Encoding to be removed once decoded")
+	}
+}
@@ -0,0 +1,315 @@
+package cohere
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// Provider talks to Cohere's v2 chat API.
+type Provider struct {
+	apiKey  string
+	baseURL string
+	client  *providers.Client
+}
+
+func NewProvider(apiKey string, baseURL string) *Provider {
+	return &Provider{
+		apiKey:  apiKey,
+		baseURL: baseURL,
+		client:  providers.NewClient(nil),
+	}
+}
+
+// chatRequest is the wire format of Cohere's v2 chat API. Messages and tools
+// share OpenAI's shape.
+type chatRequest struct {
+	Model            string              `json:"model"`
+	Messages         []providers.Message `json:"messages"`
+	Temperature      float64             `json:"temperature"`
+	MaxTokens        int                 `json:"max_tokens,omitempty"`
+	Stream           bool                `json:"stream"`
+	P                *float64            `json:"p,omitempty"`
+	StopSequences    []string            `json:"stop_sequences,omitempty"`
+	PresencePenalty  *float64            `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64            `json:"frequency_penalty,omitempty"`
+	Seed             *int                `json:"seed,omitempty"`
+	Tools            []providers.Tool    `json:"tools,omitempty"`
+	ToolChoice       string              `json:"tool_choice,omitempty"`
+}
+
+// toChatRequest translates an OpenAI-shaped request. Cohere can only force
+// or forbid tool use, not pick a tool, and has no logit_bias or n.
+func toChatRequest(req providers.ChatRequest) (*chatRequest, error) {
+	switch {
+	case len(req.LogitBias) > 0:
+		return nil, &providers.UnsupportedParamError{Provider: "cohere", Param: "logit_bias"}
+	case req.N > 1:
+		return nil, &providers.UnsupportedParamError{Provider: "cohere", Param: "n"}
+	}
+
+	out := &chatRequest{
+		Model:            req.Model,
+		Messages:         req.Messages,
+		Temperature:      req.TemperatureValue(),
+		MaxTokens:        req.MaxTokens,
+		Stream:           req.Stream,
+		P:                req.TopP,
+		StopSequences:    req.Stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		Seed:             req.Seed,
+		Tools:            req.Tools,
+	}
+
+	if len(req.ToolChoice) > 0 {
+		var mode string
+		json.Unmarshal(req.ToolChoice, &mode)
+		switch mode {
+		case "auto":
+		case "none":
+			out.ToolChoice = "NONE"
+		case "required":
+			out.ToolChoice = "REQUIRED"
+		default:
+			return nil, &providers.UnsupportedParamError{Provider: "cohere", Param: "tool_choice"}
+		}
+	}
+	return out, nil
+}
+
+type usage struct {
+	BilledUnits struct {
+		InputTokens  float64 `json:"input_tokens"`
+		OutputTokens float64 `json:"output_tokens"`
+	} `json:"billed_units"`
+	Tokens struct {
+		InputTokens  float64 `json:"input_tokens"`
+		OutputTokens float64 `json:"output_tokens"`
+	} `json:"tokens"`
+}
+
+// toUsage prefers the token counts the model saw, falling back to billed
+// units when those are absent.
+func (u usage) toUsage() providers.Usage {
+	in, out := u.Tokens.InputTokens, u.Tokens.OutputTokens
+	if in == 0 && out == 0 {
+		in, out = u.BilledUnits.InputTokens, u.BilledUnits.OutputTokens
+	}
+	return providers.Usage{PromptTokens: int(in), CompletionTokens: int(out), TotalTokens: int(in + out)}
+}
+
+// chatResponse is a non-streaming v2 chat reply.
+type chatResponse struct {
+	ID           string `json:"id"`
+	FinishReason string `json:"finish_reason"`
+	Message      struct {
+		Role    string `json:"role"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		ToolCalls []providers.ToolCall `json:"tool_calls"`
+	} `json:"message"`
+	Usage usage `json:"usage"`
+}
+
+func (c *chatResponse) toChatResponse(model string) *providers.ChatResponse {
+	var text []string
+	for _, block := range c.Message.Content {
+		if block.Type == "text" {
+			text = append(text, block.Text)
+		}
+	}
+
+	resp := &providers.ChatResponse{
+		ID:      c.ID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Usage:   c.Usage.toUsage(),
+	}
+	resp.Choices = make([]struct {
+		Index        int               `json:"index"`
+		Message      providers.Message `json:"message"`
+		FinishReason string            `json:"finish_reason"`
+	}, 1)
+	resp.Choices[0].Message = providers.Message{Role: "assistant", Content: strings.Join(text, ""), ToolCalls: c.Message.ToolCalls}
+	resp.Choices[0].FinishReason = providers.NormalizeFinishReason(c.FinishReason)
+	return resp
+}
+
+// streamEvent is one event of a v2 chat stream. Which fields are set
+// depends on Type.
+type streamEvent struct {
+	Type  string `json:"type"`
+	ID    string `json:"id"`
+	Index int    `json:"index"`
+	Delta struct {
+		Message struct {
+			Content struct {
+				Text string `json:"text"`
+			} `json:"content"`
+			ToolCalls struct {
+				ID       string                     `json:"id"`
+				Function providers.ToolCallFunction `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+		Usage        usage  `json:"usage"`
+	} `json:"delta"`
+}
+
+func (p *Provider) post(req providers.ChatRequest, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequest("POST", p.baseURL+"/chat", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetHeaders(httpReq.Header)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.client.Do(req, httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &providers.StatusError{Provider: "cohere", Code: resp.StatusCode, Message: string(msg)}
+	}
+	return resp, nil
+}
+
+func (p *Provider) Chat(req providers.ChatRequest) (*providers.ChatResponse, error) {
+	if p.apiKey == "" {
+		return nil, fmt.Errorf("COHERE_API_KEY is not set")
+	}
+	chatReq, err := toChatRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	chatReq.Stream = false
+	body, err := req.MarshalBody(chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.post(req, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, err
+	}
+	return chatResp.toChatResponse(req.Model), nil
+}
+
+// ChatStream translates Cohere's typed stream events (content-delta,
+// tool-call-start, tool-call-delta, message-end, ...) into OpenAI-style
+// chunks.
+func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatChunk, <-chan error) {
+	chunkCh := make(chan providers.ChatChunk)
+	errCh := make(chan error, 1)
+
+	if p.apiKey == "" {
+		errCh <- fmt.Errorf("COHERE_API_KEY is not set")
+		return chunkCh, errCh
+	}
+	chatReq, err := toChatRequest(req)
+	if err != nil {
+		errCh <- err
+		return chunkCh, errCh
+	}
+	chatReq.Stream = true
+	body, err := req.MarshalBody(chatReq)
+	if err != nil {
+		errCh <- err
+		return chunkCh, errCh
+	}
+
+	go func() {
+		defer close(chunkCh)
+		defer close(errCh)
+
+		resp, err := p.post(req, body)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer resp.Body.Close()
+
+		var messageID string
+		created := time.Now().Unix()
+		// Tool calls are numbered by content index upstream; renumber them
+		// from zero as OpenAI does.
+		toolIndex := map[int]int{}
+		chunk := func(delta providers.ChunkDelta, finish string) providers.ChatChunk {
+			return providers.ChatChunk{
+				ID:      messageID,
+				Object:  "chat.completion.chunk",
+				Created: created,
+				Model:   req.Model,
+				Choices: []providers.ChunkChoice{{Delta: delta, FinishReason: finish}},
+			}
+		}
+
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				if err != io.EOF {
+					errCh <- err
+				}
+				return
+			}
+
+			data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+			if !ok {
+				continue
+			}
+			var event streamEvent
+			if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+				continue
+			}
+
+			switch event.Type {
+			case "message-start":
+				messageID = event.ID
+			case "content-delta":
+				chunkCh <- chunk(providers.ChunkDelta{Content: event.Delta.Message.Content.Text}, "")
+			case "tool-call-start":
+				call := event.Delta.Message.ToolCalls
+				toolIndex[event.Index] = len(toolIndex)
+				chunkCh <- chunk(providers.ChunkDelta{ToolCalls: []providers.ToolCallDelta{{
+					Index: toolIndex[event.Index], ID: call.ID, Type: "function", Function: call.Function,
+				}}}, "")
+			case "tool-call-delta":
+				if i, ok := toolIndex[event.Index]; ok {
+					chunkCh <- chunk(providers.ChunkDelta{ToolCalls: []providers.ToolCallDelta{{
+						Index: i, Function: providers.ToolCallFunction{Arguments: event.Delta.Message.ToolCalls.Function.Arguments},
+					}}}, "")
+				}
+			case "message-end":
+				if event.Delta.FinishReason == "ERROR" {
+					errCh <- &providers.StatusError{Provider: "cohere", Code: http.StatusBadGateway, Message: "stream ended with an error"}
+					return
+				}
+				chunkCh <- chunk(providers.ChunkDelta{}, providers.NormalizeFinishReason(event.Delta.FinishReason))
+				return
+			}
+		}
+	}()
+
+	return chunkCh, errCh
+}
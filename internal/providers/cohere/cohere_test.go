@@ -0,0 +1,96 @@
+package cohere
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestToChatRequest(t *testing.T) {
+	topP := 0.5
+	out, err := toChatRequest(providers.ChatRequest{
+		Model:      "command-r-plus",
+		Messages:   []providers.Message{{Role: "user", Content: "hi"}},
+		TopP:       &topP,
+		Stop:       providers.StopSequences{"END"},
+		ToolChoice: json.RawMessage(`"required"`),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.P == nil || *out.P != 0.5 || len(out.StopSequences) != 1 || out.ToolChoice != "REQUIRED" {
+		t.Errorf("unexpected request %+v", out)
+	}
+
+	if _, err := toChatRequest(providers.ChatRequest{ToolChoice: json.RawMessage(`{"type":"function","function":{"name":"x"}}`)}); err == nil {
+		t.Error("expected a named tool_choice to be rejected")
+	}
+}
+
+func TestChat(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"id":"c1","finish_reason":"COMPLETE",
+			"message":{"role":"assistant","content":[{"type":"text","text":"Bonjour"}]},
+			"usage":{"billed_units":{"input_tokens":5,"output_tokens":2},"tokens":{"input_tokens":12,"output_tokens":2}}}`)
+	}))
+	defer srv.Close()
+
+	resp, err := NewProvider("key", srv.URL).Chat(providers.ChatRequest{Model: "command-r-plus"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "Bonjour" || resp.Choices[0].FinishReason != "stop" {
+		t.Errorf("unexpected choice %+v", resp.Choices[0])
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.TotalTokens != 14 {
+		t.Errorf("expected token usage from tokens, got %+v", resp.Usage)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	events := []string{
+		`{"type":"message-start","id":"c1","delta":{"message":{"role":"assistant"}}}`,
+		`{"type":"content-delta","index":0,"delta":{"message":{"content":{"text":"Let me "}}}}`,
+		`{"type":"content-delta","index":0,"delta":{"message":{"content":{"text":"check."}}}}`,
+		`{"type":"tool-call-start","index":1,"delta":{"message":{"tool_calls":{"id":"call_1","type":"function","function":{"name":"weather","arguments":""}}}}}`,
+		`{"type":"tool-call-delta","index":1,"delta":{"message":{"tool_calls":{"function":{"arguments":"{\"city\":\"Paris\"}"}}}}}`,
+		`{"type":"tool-call-end","index":1}`,
+		`{"type":"message-end","delta":{"finish_reason":"TOOL_CALL","usage":{"tokens":{"input_tokens":9,"output_tokens":4}}}}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, e := range events {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", e)
+		}
+	}))
+	defer srv.Close()
+
+	chunkCh, errCh := NewProvider("key", srv.URL).ChatStream(providers.ChatRequest{Model: "command-r-plus"})
+	var text, args, finish, id string
+	for c := range chunkCh {
+		d := c.Choices[0].Delta
+		text += d.Content
+		for _, tc := range d.ToolCalls {
+			if tc.Index != 0 {
+				t.Errorf("expected tool call index 0, got %d", tc.Index)
+			}
+			id += tc.ID
+			args += tc.Function.Arguments
+		}
+		if c.Choices[0].FinishReason != "" {
+			finish = c.Choices[0].FinishReason
+		}
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != "Let me check." || id != "call_1" || args != `{"city":"Paris"}` || finish != "tool_calls" {
+		t.Errorf("unexpected stream: text=%q id=%q args=%q finish=%q", text, id, args, finish)
+	}
+}
@@ -0,0 +1,54 @@
+package cohere
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/providers/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	event := func(w io.Writer, data interface{}) {
+		body, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", data.(map[string]interface{})["type"], body)
+	}
+	conformance.Run(t, conformance.Upstream{
+		New: func(baseURL string) providers.Provider {
+			return NewProvider("key", baseURL)
+		},
+		Reply: func(w http.ResponseWriter, content string, usage providers.Usage) {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":            "c-1",
+				"finish_reason": "COMPLETE",
+				"message": map[string]interface{}{
+					"role":    "assistant",
+					"content": []interface{}{map[string]string{"type": "text", "text": content}},
+				},
+				"usage": map[string]interface{}{
+					"tokens": map[string]int{"input_tokens": usage.PromptTokens, "output_tokens": usage.CompletionTokens},
+				},
+			})
+		},
+		StreamStart: func(w io.Writer) {
+			event(w, map[string]interface{}{"type": "message-start", "id": "c-1"})
+		},
+		StreamDelta: func(w io.Writer, text string) {
+			event(w, map[string]interface{}{
+				"type":  "content-delta",
+				"index": 0,
+				"delta": map[string]interface{}{"message": map[string]interface{}{"content": map[string]string{"text": text}}},
+			})
+		},
+		StreamEnd: func(w io.Writer) {
+			event(w, map[string]interface{}{"type": "message-end", "delta": map[string]string{"finish_reason": "COMPLETE"}})
+		},
+		Error: func(w http.ResponseWriter, status int) {
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"message":"upstream failure"}`)
+		},
+	})
+}
@@ -0,0 +1,18 @@
+package cohere
+
+import (
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func init() {
+	providers.Register("cohere", func(name string, opts config.ProviderOptions) (providers.Provider, error) {
+		baseURL := opts.BaseURL
+		if baseURL == "" {
+			baseURL = "https://api.cohere.com/v2"
+		}
+		p := NewProvider(opts.APIKey(), baseURL)
+		p.client = providers.NewClient(opts.Timeouts)
+		return p, nil
+	})
+}
@@ -0,0 +1,112 @@
+package providers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Collect makes a streaming call to p and assembles the chunks into a single
+// response, for providers that are more reliable streaming than not. When
+// firstChunk is positive and nothing arrives within it, the call is
+// abandoned with a 504 StatusError so the caller can fail over without
+// waiting for a whole completion. Streams carry no usage, so Usage is left
+// zero; TTFT records when the first chunk arrived.
+func Collect(name string, p Provider, req ChatRequest, firstChunk time.Duration) (*ChatResponse, error) {
+	req.Stream = true
+	start := time.Now()
+	chunkCh, errCh := p.ChatStream(req)
+
+	var timeout <-chan time.Time
+	if firstChunk > 0 {
+		timer := time.NewTimer(firstChunk)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	resp := &ChatResponse{Object: "chat.completion", Model: req.Model}
+	resp.Choices = make([]struct {
+		Index        int     `json:"index"`
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	}, 1)
+	resp.Choices[0].Message.Role = "assistant"
+
+	var content, reasoning strings.Builder
+	var calls []ToolCall
+	callAt := map[int]int{} // stream tool call index -> position in calls
+	for {
+		select {
+		case chunk, ok := <-chunkCh:
+			if !ok {
+				select {
+				case err := <-errCh:
+					if err != nil {
+						return nil, err
+					}
+				default:
+				}
+				resp.Choices[0].Message.Content = content.String()
+				resp.Choices[0].Message.Reasoning = reasoning.String()
+				resp.Choices[0].Message.ToolCalls = calls
+				return resp, nil
+			}
+			timeout = nil
+			if resp.TTFT == 0 {
+				resp.TTFT = time.Since(start)
+			}
+			if resp.ID == "" {
+				resp.ID, resp.Created = chunk.ID, chunk.Created
+				if chunk.Model != "" {
+					resp.Model = chunk.Model
+				}
+			}
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+			choice := chunk.Choices[0]
+			content.WriteString(choice.Delta.Content)
+			reasoning.WriteString(choice.Delta.Reasoning)
+			if choice.Delta.ReasoningSignature != "" {
+				resp.Choices[0].Message.ReasoningSignature = choice.Delta.ReasoningSignature
+			}
+			for _, d := range choice.Delta.ToolCalls {
+				i, ok := callAt[d.Index]
+				if !ok {
+					i = len(calls)
+					callAt[d.Index] = i
+					calls = append(calls, ToolCall{Type: "function"})
+				}
+				if d.ID != "" {
+					calls[i].ID = d.ID
+				}
+				if d.Function.Name != "" {
+					calls[i].Function.Name = d.Function.Name
+				}
+				calls[i].Function.Arguments += d.Function.Arguments
+			}
+			if choice.FinishReason != "" {
+				resp.Choices[0].FinishReason = choice.FinishReason
+			}
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			if err != nil {
+				go func() {
+					for range chunkCh {
+					}
+				}()
+				return nil, err
+			}
+		case <-timeout:
+			go func() {
+				for range chunkCh {
+				}
+			}()
+			return nil, &StatusError{Provider: name, Code: http.StatusGatewayTimeout, Message: fmt.Sprintf("no stream chunk within %s", firstChunk)}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package providers
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// chunkProvider streams fixed chunks after an initial delay.
+type chunkProvider struct {
+	delay  time.Duration
+	chunks []ChatChunk
+	err    error
+}
+
+func (c chunkProvider) Chat(req ChatRequest) (*ChatResponse, error) {
+	return nil, errors.New("not used")
+}
+
+func (c chunkProvider) ChatStream(req ChatRequest) (<-chan ChatChunk, <-chan error) {
+	chunkCh := make(chan ChatChunk)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(chunkCh)
+		defer close(errCh)
+		time.Sleep(c.delay)
+		for _, chunk := range c.chunks {
+			chunkCh <- chunk
+		}
+		if c.err != nil {
+			errCh <- c.err
+		}
+	}()
+	return chunkCh, errCh
+}
+
+func TestCollect(t *testing.T) {
+	p := chunkProvider{chunks: []ChatChunk{
+		{ID: "c1", Created: 42, Model: "gpt-4o", Choices: []ChunkChoice{{Delta: ChunkDelta{Content: "Let me "}}}},
+		{ID: "c1", Choices: []ChunkChoice{{Delta: ChunkDelta{Content: "check.", ToolCalls: []ToolCallDelta{
+			{Index: 0, ID: "call_1", Type: "function", Function: ToolCallFunction{Name: "lookup"}},
+		}}}}},
+		{ID: "c1", Choices: []ChunkChoice{{Delta: ChunkDelta{ToolCalls: []ToolCallDelta{
+			{Index: 0, Function: ToolCallFunction{Arguments: `{"id":`}},
+		}}}}},
+		{ID: "c1", Choices: []ChunkChoice{{Delta: ChunkDelta{ToolCalls: []ToolCallDelta{
+			{Index: 0, Function: ToolCallFunction{Arguments: `7}`}},
+		}}, FinishReason: "tool_calls"}}},
+	}}
+
+	resp, err := Collect("openai", p, ChatRequest{Model: "gpt-4o"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "c1" || resp.Created != 42 || resp.Object != "chat.completion" || resp.TTFT <= 0 {
+		t.Errorf("unexpected envelope %+v", resp)
+	}
+	msg := resp.Choices[0].Message
+	if msg.Role != "assistant" || msg.Content != "Let me check." {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].ID != "call_1" || msg.ToolCalls[0].Function.Name != "lookup" || msg.ToolCalls[0].Function.Arguments != `{"id":7}` {
+		t.Errorf("unexpected tool calls %+v", msg.ToolCalls)
+	}
+	if resp.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("expected tool_calls finish, got %q", resp.Choices[0].FinishReason)
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	upstream := &StatusError{Provider: "openai", Code: 500, Message: "boom"}
+	p := chunkProvider{chunks: []ChatChunk{{Choices: []ChunkChoice{{Delta: ChunkDelta{Content: "par"}}}}}, err: upstream}
+	if _, err := Collect("openai", p, ChatRequest{}, 0); !errors.Is(err, upstream) {
+		t.Errorf("expected mid-stream error, got %v", err)
+	}
+
+	slow := chunkProvider{delay: 200 * time.Millisecond, chunks: []ChatChunk{{}}}
+	_, err := Collect("openai", slow, ChatRequest{}, 20*time.Millisecond)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != 504 {
+		t.Errorf("expected first-chunk timeout, got %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+// Package conformance checks a Provider against the behaviour the gateway
+// relies on, using a fake upstream built with httptest: completions and
+// their usage are read back, streams end cleanly, upstream failures become
+// StatusErrors the router can classify, and calls give up once their
+// timeout passes. A provider package runs it from a test, with an Upstream
+// that speaks its wire format:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, conformance.Upstream{New: ..., Reply: ..., ...})
+//	}
+package conformance
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// Upstream describes a provider's wire format to the fake server.
+type Upstream struct {
+	// New returns the provider under test, calling the server at baseURL.
+	New func(baseURL string) providers.Provider
+	// Reply writes a non-streamed completion of content with usage.
+	Reply func(w http.ResponseWriter, content string, usage providers.Usage)
+	// StreamStart, if set, writes the events that open a stream.
+	StreamStart func(w io.Writer)
+	// StreamDelta writes a stream event adding text to the content.
+	StreamDelta func(w io.Writer, text string)
+	// StreamEnd writes the events that end a stream with a normal stop.
+	StreamEnd func(w io.Writer)
+	// Error writes a failed response with status, with the body the
+	// provider sends on errors.
+	Error func(w http.ResponseWriter, status int)
+}
+
+// wait bounds every step of the suite, so a provider that never finishes
+// fails the test instead of hanging it.
+const wait = 5 * time.Second
+
+var deltas = []string{"Hello", " there,", " friend"}
+
+func request() providers.ChatRequest {
+	return providers.ChatRequest{
+		Model:     "conformance-model",
+		Messages:  []providers.Message{{Role: "user", Content: "Say hello."}},
+		MaxTokens: 16,
+	}
+}
+
+// Run checks the provider built by u.New.
+func Run(t *testing.T, u Upstream) {
+	t.Run("chat", func(t *testing.T) { testChat(t, u) })
+	t.Run("stream", func(t *testing.T) { testStream(t, u) })
+	t.Run("errors", func(t *testing.T) { testErrors(t, u) })
+	t.Run("timeout", func(t *testing.T) { testTimeout(t, u) })
+}
+
+func serve(t *testing.T, h http.HandlerFunc) string {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func (u Upstream) stream(w http.ResponseWriter, texts []string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	if u.StreamStart != nil {
+		u.StreamStart(w)
+	}
+	for _, text := range texts {
+		u.StreamDelta(w, text)
+		w.(http.Flusher).Flush()
+	}
+}
+
+// result is a drained stream.
+type result struct {
+	content string
+	finish  string
+	chunks  int
+	err     error
+}
+
+// drain reads a stream to the end, failing the test if either channel is
+// left open.
+func drain(t *testing.T, chunkCh <-chan providers.ChatChunk, errCh <-chan error) result {
+	t.Helper()
+	var r result
+	var content strings.Builder
+	deadline := time.After(wait)
+	for chunkCh != nil || errCh != nil {
+		select {
+		case chunk, ok := <-chunkCh:
+			if !ok {
+				chunkCh = nil
+				continue
+			}
+			r.chunks++
+			for _, c := range chunk.Choices {
+				content.WriteString(c.Delta.Content)
+				if c.FinishReason != "" {
+					r.finish = c.FinishReason
+				}
+			}
+		case err, ok := <-errCh:
+			if !ok {
+				errCh = nil
+				continue
+			}
+			r.err = err
+		case <-deadline:
+			t.Fatalf("stream still open after %s", wait)
+		}
+	}
+	r.content = content.String()
+	return r
+}
+
+func testChat(t *testing.T, u Upstream) {
+	want := providers.Usage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10}
+	url := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		u.Reply(w, strings.Join(deltas, ""), want)
+	})
+
+	resp, err := u.New(url).Chat(request())
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if len(resp.Choices) == 0 {
+		t.Fatal("Chat returned no choices")
+	}
+	if got := resp.Choices[0].Message.Content; got != strings.Join(deltas, "") {
+		t.Errorf("content = %q, want %q", got, strings.Join(deltas, ""))
+	}
+	if got := resp.Choices[0].FinishReason; got != providers.FinishStop {
+		t.Errorf("finish reason = %q, want %q", got, providers.FinishStop)
+	}
+	if resp.Usage.PromptTokens != want.PromptTokens || resp.Usage.CompletionTokens != want.CompletionTokens {
+		t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+	}
+}
+
+func testStream(t *testing.T, u Upstream) {
+	url := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		u.stream(w, deltas)
+		u.StreamEnd(w)
+	})
+
+	chunks, errs := u.New(url).ChatStream(request())
+	r := drain(t, chunks, errs)
+	if r.err != nil {
+		t.Fatalf("stream error: %v", r.err)
+	}
+	if r.content != strings.Join(deltas, "") {
+		t.Errorf("content = %q, want %q", r.content, strings.Join(deltas, ""))
+	}
+	if r.chunks < len(deltas) {
+		t.Errorf("got %d chunks, want at least %d", r.chunks, len(deltas))
+	}
+	if r.finish != providers.FinishStop {
+		t.Errorf("finish reason = %q, want %q", r.finish, providers.FinishStop)
+	}
+}
+
+func testErrors(t *testing.T, u Upstream) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		url := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			u.Error(w, status)
+		})
+		p := u.New(url)
+
+		_, err := p.Chat(request())
+		checkStatus(t, "Chat", err, status)
+
+		chunks, errs := p.ChatStream(request())
+		r := drain(t, chunks, errs)
+		checkStatus(t, "ChatStream", r.err, status)
+	}
+}
+
+func checkStatus(t *testing.T, call string, err error, status int) {
+	t.Helper()
+	var se *providers.StatusError
+	if !errors.As(err, &se) {
+		t.Errorf("%s: upstream %d gave %v, want a *providers.StatusError", call, status, err)
+		return
+	}
+	if se.Code != status {
+		t.Errorf("%s: StatusError code = %d, want %d", call, se.Code, status)
+	}
+}
+
+// testTimeout stalls the upstream, before the response and partway through
+// a stream, and expects the call to fail soon after its timeout.
+func testTimeout(t *testing.T, u Upstream) {
+	// Released before the servers close, which waits for their handlers.
+	release := make(chan struct{})
+	defer close(release)
+	stall := func(r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}
+
+	url := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		stall(r)
+	})
+	req := request()
+	req.Timeout = 200 * time.Millisecond
+	start := time.Now()
+	if _, err := u.New(url).Chat(req); err == nil {
+		t.Error("Chat against a stalled upstream succeeded")
+	}
+	if took := time.Since(start); took > wait {
+		t.Errorf("Chat took %s to give up", took)
+	}
+
+	url = serve(t, func(w http.ResponseWriter, r *http.Request) {
+		u.stream(w, deltas[:1])
+		stall(r)
+	})
+	chunks, errs := u.New(url).ChatStream(req)
+	r := drain(t, chunks, errs)
+	if r.err == nil {
+		t.Error("a stream cut off by its timeout ended without an error")
+	}
+	if r.content != deltas[0] {
+		t.Errorf("content before the stall = %q, want %q", r.content, deltas[0])
+	}
+}
@@ -0,0 +1,112 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+// Factory creates a provider instance called name from its settings.
+type Factory func(name string, opts config.ProviderOptions) (Provider, error)
+
+var factories = map[string]Factory{}
+
+// typeDefaults are the request defaults provider types need for their API.
+var typeDefaults = map[string]map[string]interface{}{}
+
+// Register makes a provider type available to Build. Provider packages call
+// it from init, so linking a package in is enough to offer its type.
+func Register(kind string, f Factory) {
+	if _, dup := factories[kind]; dup {
+		panic("providers: Register called twice for " + kind)
+	}
+	factories[kind] = f
+}
+
+// RegisterDefaults sets the request defaults of a provider type, used
+// unless a provider's request_defaults replace them. Like Register it is
+// called from init.
+func RegisterDefaults(kind string, defaults map[string]interface{}) {
+	typeDefaults[kind] = defaults
+}
+
+// Types lists the registered provider types.
+func Types() []string {
+	kinds := make([]string, 0, len(factories))
+	for kind := range factories {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Build instantiates every configured provider with its type's factory, or
+// the mock type's for a mocked provider.
+func Build(opts map[string]config.ProviderOptions) (Registry, error) {
+	reg := Registry{}
+	for name, o := range opts {
+		kind := o.Type
+		if o.Mocked() {
+			kind = "mock"
+		}
+		f, ok := factories[kind]
+		if !ok {
+			return nil, fmt.Errorf("provider %s: unknown type %q (have %v)", name, kind, Types())
+		}
+		p, err := f(name, o)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", name, err)
+		}
+		defaults := requestDefaults(kind, o.RequestDefaults)
+		if _, err := (ChatRequest{}).WithDefaults(defaults); err != nil {
+			return nil, fmt.Errorf("provider %s: %w", name, err)
+		}
+		if len(defaults) > 0 {
+			p = defaulted{p, defaults}
+		}
+		reg[name] = p
+	}
+	return reg, nil
+}
+
+// requestDefaults merges a provider's configured request defaults over its
+// type's.
+func requestDefaults(kind string, configured map[string]interface{}) map[string]interface{} {
+	if len(configured) == 0 {
+		return typeDefaults[kind]
+	}
+	merged := make(map[string]interface{}, len(typeDefaults[kind])+len(configured))
+	for k, v := range typeDefaults[kind] {
+		merged[k] = v
+	}
+	for k, v := range configured {
+		merged[k] = v
+	}
+	return merged
+}
+
+// defaulted applies request defaults before its provider translates a
+// request.
+type defaulted struct {
+	Provider
+	defaults map[string]interface{}
+}
+
+func (d defaulted) Chat(req ChatRequest) (*ChatResponse, error) {
+	req, err := req.WithDefaults(d.defaults)
+	if err != nil {
+		return nil, err
+	}
+	return d.Provider.Chat(req)
+}
+
+func (d defaulted) ChatStream(req ChatRequest) (<-chan ChatChunk, <-chan error) {
+	req, err := req.WithDefaults(d.defaults)
+	if err != nil {
+		errCh := make(chan error, 1)
+		errCh <- err
+		return make(chan ChatChunk), errCh
+	}
+	return d.Provider.ChatStream(req)
+}
@@ -0,0 +1,103 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func TestBuild(t *testing.T) {
+	Register("stub", func(name string, opts config.ProviderOptions) (Provider, error) {
+		return stubProvider{reason: name + ":" + opts.BaseURL}, nil
+	})
+
+	reg, err := Build(map[string]config.ProviderOptions{
+		"org-a": {Type: "stub", BaseURL: "https://a"},
+		"org-b": {Type: "stub", BaseURL: "https://b"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, url := range map[string]string{"org-a": "https://a", "org-b": "https://b"} {
+		p, err := reg.Get(name)
+		if err != nil {
+			t.Fatalf("expected %s to be registered: %v", name, err)
+		}
+		if got := p.(stubProvider).reason; got != name+":"+url {
+			t.Errorf("expected %s to be built with its own settings, got %s", name, got)
+		}
+	}
+
+	Register("mock", func(name string, opts config.ProviderOptions) (Provider, error) {
+		return stubProvider{reason: "mock"}, nil
+	})
+	reg, err = Build(map[string]config.ProviderOptions{"org-a": {Type: "stub", Mock: &config.MockConfig{}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p, _ := reg.Get("org-a"); p.(stubProvider).reason != "mock" {
+		t.Errorf("expected a mocked provider to be built by the mock factory")
+	}
+
+	_, err = Build(map[string]config.ProviderOptions{"mystery": {Type: "nope"}})
+	if err == nil || !strings.Contains(err.Error(), `unknown type "nope"`) {
+		t.Errorf("expected an unknown type error, got %v", err)
+	}
+}
+
+// recordingProvider keeps the last request it was sent.
+type recordingProvider struct{ last *ChatRequest }
+
+func (p recordingProvider) Chat(req ChatRequest) (*ChatResponse, error) {
+	*p.last = req
+	return &ChatResponse{}, nil
+}
+
+func (p recordingProvider) ChatStream(req ChatRequest) (<-chan ChatChunk, <-chan error) {
+	*p.last = req
+	return nil, nil
+}
+
+func TestBuildRequestDefaults(t *testing.T) {
+	var last ChatRequest
+	Register("recording", func(name string, opts config.ProviderOptions) (Provider, error) {
+		return recordingProvider{&last}, nil
+	})
+	RegisterDefaults("recording", map[string]interface{}{"max_tokens": 1024, "top_k": 40})
+
+	reg, err := Build(map[string]config.ProviderOptions{
+		"rec":   {Type: "recording"},
+		"rec-2": {Type: "recording", RequestDefaults: map[string]interface{}{"max_tokens": 4096}},
+		"rec-3": {Type: "recording", RequestDefaults: map[string]interface{}{"temperature": 0.7}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reg["rec"].Chat(ChatRequest{})
+	if last.MaxTokens != 1024 || last.Extra["top_k"] != 40 {
+		t.Errorf("expected the type's defaults, got %d and %v", last.MaxTokens, last.Extra)
+	}
+	reg["rec"].Chat(ChatRequest{MaxTokens: 50})
+	if last.MaxTokens != 50 {
+		t.Errorf("expected the request's max_tokens kept, got %d", last.MaxTokens)
+	}
+	reg["rec-3"].Chat(ChatRequest{})
+	if last.Temperature == nil || *last.Temperature != 0.7 {
+		t.Errorf("expected the default temperature for an unset one, got %v", last.Temperature)
+	}
+	zero := 0.0
+	reg["rec-3"].Chat(ChatRequest{Temperature: &zero})
+	if last.Temperature == nil || *last.Temperature != 0 {
+		t.Errorf("expected an explicit temperature of 0 kept, got %v", last.Temperature)
+	}
+	reg["rec-2"].ChatStream(ChatRequest{})
+	if last.MaxTokens != 4096 || last.Extra["top_k"] != 40 {
+		t.Errorf("expected configured defaults over the type's, got %d and %v", last.MaxTokens, last.Extra)
+	}
+
+	_, err = Build(map[string]config.ProviderOptions{"bad": {Type: "recording", RequestDefaults: map[string]interface{}{"max_tokens": "lots"}}})
+	if err == nil || !strings.Contains(err.Error(), "provider bad: invalid request defaults") {
+		t.Errorf("expected badly typed defaults rejected, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package providers
+
+import "strings"
+
+// Normalized finish reasons, as OpenAI reports them.
+const (
+	FinishStop          = "stop"
+	FinishLength        = "length"
+	FinishToolCalls     = "tool_calls"
+	FinishContentFilter = "content_filter"
+)
+
+// finishReasons maps provider-specific stop reasons, lowercased, onto the
+// normalized set.
+var finishReasons = map[string]string{
+	"stop":               FinishStop,
+	"end_turn":           FinishStop,
+	"stop_sequence":      FinishStop,
+	"eos":                FinishStop,
+	"complete":           FinishStop,
+	"length":             FinishLength,
+	"max_tokens":         FinishLength,
+	"model_length":       FinishLength,
+	"tool_calls":         FinishToolCalls,
+	"tool_use":           FinishToolCalls,
+	"tool_call":          FinishToolCalls,
+	"function_call":      FinishToolCalls,
+	"content_filter":     FinishContentFilter,
+	"refusal":            FinishContentFilter,
+	"safety":             FinishContentFilter,
+	"recitation":         FinishContentFilter,
+	"error_toxic":        FinishContentFilter,
+	"prohibited_content": FinishContentFilter,
+}
+
+// NormalizeFinishReason maps a provider's stop reason onto stop, length,
+// tool_calls or content_filter. Unknown reasons are passed through.
+func NormalizeFinishReason(reason string) string {
+	if normalized, ok := finishReasons[strings.ToLower(reason)]; ok {
+		return normalized
+	}
+	return reason
+}
+
+// NormalizeFinishReasons wraps every provider in the registry so that its
+// responses and stream chunks carry normalized finish reasons.
+func NormalizeFinishReasons(reg Registry) {
+	for name, p := range reg {
+		reg[name] = normalizer{inner: p}
+	}
+}
+
+type normalizer struct {
+	inner Provider
+}
+
+func (n normalizer) Chat(req ChatRequest) (*ChatResponse, error) {
+	resp, err := n.inner.Chat(req)
+	if err != nil {
+		return nil, err
+	}
+	for i := range resp.Choices {
+		resp.Choices[i].FinishReason = NormalizeFinishReason(resp.Choices[i].FinishReason)
+	}
+	return resp, nil
+}
+
+func (n normalizer) ChatStream(req ChatRequest) (<-chan ChatChunk, <-chan error) {
+	innerChunks, innerErrs := n.inner.ChatStream(req)
+	chunkCh := make(chan ChatChunk)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(chunkCh)
+		defer close(errCh)
+		for {
+			select {
+			case chunk, ok := <-innerChunks:
+				if !ok {
+					// Pass on an error reported just before the close.
+					select {
+					case err, ok := <-innerErrs:
+						if ok && err != nil {
+							errCh <- err
+						}
+					default:
+					}
+					return
+				}
+				for i := range chunk.Choices {
+					chunk.Choices[i].FinishReason = NormalizeFinishReason(chunk.Choices[i].FinishReason)
+				}
+				chunkCh <- chunk
+			case err, ok := <-innerErrs:
+				if ok && err != nil {
+					errCh <- err
+					// Drain so the upstream goroutine is not left blocked.
+					go func() {
+						for range innerChunks {
+						}
+					}()
+					return
+				}
+				innerErrs = nil
+			}
+		}
+	}()
+	return chunkCh, errCh
+}
@@ -0,0 +1,88 @@
+package providers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeFinishReason(t *testing.T) {
+	cases := map[string]string{
+		"end_turn":      "stop",
+		"stop_sequence": "stop",
+		"STOP":          "stop",
+		"max_tokens":    "length",
+		"MAX_TOKENS":    "length",
+		"tool_use":      "tool_calls",
+		"function_call": "tool_calls",
+		"SAFETY":        "content_filter",
+		"refusal":       "content_filter",
+		"":              "",
+		"pause_turn":    "pause_turn",
+	}
+	for in, want := range cases {
+		if got := NormalizeFinishReason(in); got != want {
+			t.Errorf("NormalizeFinishReason(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+type stubProvider struct {
+	reason string
+	err    error
+}
+
+func (s stubProvider) Chat(req ChatRequest) (*ChatResponse, error) {
+	resp := &ChatResponse{}
+	resp.Choices = make([]struct {
+		Index        int     `json:"index"`
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	}, 1)
+	resp.Choices[0].FinishReason = s.reason
+	return resp, nil
+}
+
+func (s stubProvider) ChatStream(req ChatRequest) (<-chan ChatChunk, <-chan error) {
+	chunkCh := make(chan ChatChunk)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(chunkCh)
+		defer close(errCh)
+		chunkCh <- ChatChunk{Choices: []ChunkChoice{{Delta: ChunkDelta{Content: "hi"}}}}
+		if s.err != nil {
+			errCh <- s.err
+			return
+		}
+		chunkCh <- ChatChunk{Choices: []ChunkChoice{{FinishReason: s.reason}}}
+	}()
+	return chunkCh, errCh
+}
+
+func TestNormalizeFinishReasons(t *testing.T) {
+	reg := Registry{"stub": stubProvider{reason: "end_turn"}}
+	NormalizeFinishReasons(reg)
+	p, _ := reg.Get("stub")
+
+	resp, _ := p.Chat(ChatRequest{})
+	if resp.Choices[0].FinishReason != "stop" {
+		t.Errorf("expected stop, got %q", resp.Choices[0].FinishReason)
+	}
+
+	chunkCh, errCh := p.ChatStream(ChatRequest{})
+	var last string
+	for c := range chunkCh {
+		last = c.Choices[0].FinishReason
+	}
+	if err := <-errCh; err != nil || last != "stop" {
+		t.Errorf("expected a clean stream ending in stop, got %q (%v)", last, err)
+	}
+
+	failing := Registry{"stub": stubProvider{err: errors.New("boom")}}
+	NormalizeFinishReasons(failing)
+	chunkCh, errCh = failing["stub"].ChatStream(ChatRequest{})
+	for range chunkCh {
+	}
+	if err := <-errCh; err == nil || err.Error() != "boom" {
+		t.Errorf("expected the stream error to pass through, got %v", err)
+	}
+}
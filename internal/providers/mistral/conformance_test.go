@@ -0,0 +1,44 @@
+package mistral
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/providers/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Upstream{
+		New: func(baseURL string) providers.Provider {
+			return NewProvider("key", baseURL)
+		},
+		Reply: func(w http.ResponseWriter, content string, usage providers.Usage) {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":     "cmpl-1",
+				"object": "chat.completion",
+				"choices": []interface{}{map[string]interface{}{
+					"index":         0,
+					"message":       map[string]string{"role": "assistant", "content": content},
+					"finish_reason": "stop",
+				}},
+				"usage": usage,
+			})
+		},
+		StreamDelta: func(w io.Writer, text string) {
+			delta, _ := json.Marshal(text)
+			fmt.Fprintf(w, "data: {\"id\":\"cmpl-1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%s}}]}\n\n", delta)
+		},
+		StreamEnd: func(w io.Writer) {
+			fmt.Fprint(w, "data: {\"id\":\"cmpl-1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		},
+		Error: func(w http.ResponseWriter, status int) {
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"object":"error","message":"upstream failure"}`)
+		},
+	})
+}
@@ -0,0 +1,181 @@
+package mistral
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// Provider talks to Mistral's chat completions API, which follows OpenAI's
+// format apart from a few parameter names.
+type Provider struct {
+	apiKey  string
+	baseURL string
+	client  *providers.Client
+}
+
+func NewProvider(apiKey string, baseURL string) *Provider {
+	return &Provider{
+		apiKey:  apiKey,
+		baseURL: baseURL,
+		client:  providers.NewClient(nil),
+	}
+}
+
+// chatRequest is the wire format of Mistral's chat completions API.
+type chatRequest struct {
+	Model            string              `json:"model"`
+	Messages         []providers.Message `json:"messages"`
+	Temperature      float64             `json:"temperature"`
+	MaxTokens        int                 `json:"max_tokens,omitempty"`
+	Stream           bool                `json:"stream"`
+	TopP             *float64            `json:"top_p,omitempty"`
+	Stop             []string            `json:"stop,omitempty"`
+	PresencePenalty  *float64            `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64            `json:"frequency_penalty,omitempty"`
+	RandomSeed       *int                `json:"random_seed,omitempty"`
+	N                int                 `json:"n,omitempty"`
+	Tools            []providers.Tool    `json:"tools,omitempty"`
+	ToolChoice       json.RawMessage     `json:"tool_choice,omitempty"`
+}
+
+// toChatRequest renames seed to random_seed and OpenAI's "required"
+// tool_choice to Mistral's "any". Mistral has no logit_bias.
+func toChatRequest(req providers.ChatRequest) (*chatRequest, error) {
+	if len(req.LogitBias) > 0 {
+		return nil, &providers.UnsupportedParamError{Provider: "mistral", Param: "logit_bias"}
+	}
+	out := &chatRequest{
+		Model:            req.Model,
+		Messages:         req.Messages,
+		Temperature:      req.TemperatureValue(),
+		MaxTokens:        req.MaxTokens,
+		Stream:           req.Stream,
+		TopP:             req.TopP,
+		Stop:             req.Stop,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		RandomSeed:       req.Seed,
+		N:                req.N,
+		Tools:            req.Tools,
+		ToolChoice:       req.ToolChoice,
+	}
+	if string(req.ToolChoice) == `"required"` {
+		out.ToolChoice = json.RawMessage(`"any"`)
+	}
+	return out, nil
+}
+
+func (p *Provider) post(req providers.ChatRequest, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequest("POST", p.baseURL+"/chat/completions", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetHeaders(httpReq.Header)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.client.Do(req, httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &providers.StatusError{Provider: "mistral", Code: resp.StatusCode, Message: string(msg)}
+	}
+	return resp, nil
+}
+
+func (p *Provider) Chat(req providers.ChatRequest) (*providers.ChatResponse, error) {
+	if p.apiKey == "" {
+		return nil, fmt.Errorf("MISTRAL_API_KEY is not set")
+	}
+	msgReq, err := toChatRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	msgReq.Stream = false
+	body, err := req.MarshalBody(msgReq)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.post(req, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp providers.ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, err
+	}
+	return &chatResp, nil
+}
+
+func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatChunk, <-chan error) {
+	chunkCh := make(chan providers.ChatChunk)
+	errCh := make(chan error, 1)
+
+	if p.apiKey == "" {
+		errCh <- fmt.Errorf("MISTRAL_API_KEY is not set")
+		return chunkCh, errCh
+	}
+	msgReq, err := toChatRequest(req)
+	if err != nil {
+		errCh <- err
+		return chunkCh, errCh
+	}
+	msgReq.Stream = true
+	body, err := req.MarshalBody(msgReq)
+	if err != nil {
+		errCh <- err
+		return chunkCh, errCh
+	}
+
+	go func() {
+		defer close(chunkCh)
+		defer close(errCh)
+
+		resp, err := p.post(req, body)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer resp.Body.Close()
+
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				if err != io.EOF {
+					errCh <- err
+				}
+				return
+			}
+
+			data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+			if !ok {
+				continue
+			}
+			if data == "[DONE]" {
+				return
+			}
+
+			var chunk providers.ChatChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				continue
+			}
+			chunkCh <- chunk
+		}
+	}()
+
+	return chunkCh, errCh
+}
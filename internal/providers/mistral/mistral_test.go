@@ -0,0 +1,52 @@
+package mistral
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestToChatRequest(t *testing.T) {
+	seed := 7
+	out, err := toChatRequest(providers.ChatRequest{
+		Model:      "mistral-large-latest",
+		Messages:   []providers.Message{{Role: "user", Content: "hi"}},
+		Seed:       &seed,
+		ToolChoice: json.RawMessage(`"required"`),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := json.Marshal(out)
+	var wire map[string]interface{}
+	json.Unmarshal(body, &wire)
+	if wire["random_seed"] != float64(7) || wire["seed"] != nil {
+		t.Errorf("expected seed to be sent as random_seed, got %s", body)
+	}
+	if wire["tool_choice"] != "any" {
+		t.Errorf("expected tool_choice any, got %v", wire["tool_choice"])
+	}
+
+	_, err = toChatRequest(providers.ChatRequest{LogitBias: map[string]float64{"1": 1}})
+	var unsupported *providers.UnsupportedParamError
+	if !errors.As(err, &unsupported) || unsupported.Param != "logit_bias" {
+		t.Errorf("expected logit_bias to be rejected, got %v", err)
+	}
+}
+
+func TestChatStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"rate limited"}`, http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	_, err := NewProvider("key", srv.URL).Chat(providers.ChatRequest{Model: "mistral-small-latest"})
+	var status *providers.StatusError
+	if !errors.As(err, &status) || status.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a 429 StatusError, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+// Package mock is a provider that answers calls itself, for development and
+// tests. Unlike the synthetic provider, which generates load-test filler,
+// it returns meaningful content: scripted responses or an echo of the
+// prompt.
+package mock
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// Options control the mock provider. Responses are returned in turn,
+// starting over after the last; with none, the provider echoes the last
+// message. Latency passes before the response, or the first chunk of a
+// stream, and ChunkDelay between chunks. A fraction ErrorRate of calls
+// fail with a StatusError carrying ErrorStatus.
+type Options struct {
+	Name        string
+	Responses   []string
+	Latency     time.Duration
+	ChunkDelay  time.Duration
+	ErrorRate   float64
+	ErrorStatus int
+}
+
+type Provider struct {
+	opts Options
+
+	mu   sync.Mutex
+	next int
+	rng  *rand.Rand
+}
+
+func NewProvider(opts Options) *Provider {
+	if opts.Name == "" {
+		opts.Name = "mock"
+	}
+	if opts.ErrorStatus == 0 {
+		opts.ErrorStatus = 503
+	}
+	return &Provider{opts: opts, rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// reply picks the content for req, or the injected error.
+func (p *Provider) reply(req providers.ChatRequest) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.opts.ErrorRate > 0 && p.rng.Float64() < p.opts.ErrorRate {
+		return "", &providers.StatusError{Provider: p.opts.Name, Code: p.opts.ErrorStatus, Message: "injected failure"}
+	}
+	if len(p.opts.Responses) > 0 {
+		content := p.opts.Responses[p.next%len(p.opts.Responses)]
+		p.next++
+		return content, nil
+	}
+	var last string
+	if len(req.Messages) > 0 {
+		last = req.Messages[len(req.Messages)-1].Content
+	}
+	return "Mock: " + last, nil
+}
+
+func usage(req providers.ChatRequest, content string) providers.Usage {
+	prompt := 0
+	for _, m := range req.Messages {
+		prompt += len(strings.Fields(m.Content))
+	}
+	completion := len(strings.Fields(content))
+	return providers.Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+}
+
+func (p *Provider) Chat(req providers.ChatRequest) (*providers.ChatResponse, error) {
+	time.Sleep(p.opts.Latency)
+	content, err := p.reply(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &providers.ChatResponse{
+		ID:      fmt.Sprintf("mock-%d", time.Now().UnixNano()),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Usage:   usage(req, content),
+	}
+	resp.Choices = append(resp.Choices, struct {
+		Index        int               `json:"index"`
+		Message      providers.Message `json:"message"`
+		FinishReason string            `json:"finish_reason"`
+	}{
+		Message:      providers.Message{Role: "assistant", Content: content},
+		FinishReason: "stop",
+	})
+	return resp, nil
+}
+
+// ChatStream sends the response a word at a time.
+func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatChunk, <-chan error) {
+	chunkCh := make(chan providers.ChatChunk)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(chunkCh)
+		defer close(errCh)
+
+		time.Sleep(p.opts.Latency)
+		content, err := p.reply(req)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		id := fmt.Sprintf("mock-stream-%d", time.Now().UnixNano())
+		created := time.Now().Unix()
+		chunk := func() providers.ChatChunk {
+			return providers.ChatChunk{
+				ID:      id,
+				Object:  "chat.completion.chunk",
+				Created: created,
+				Model:   req.Model,
+				Choices: make([]providers.ChunkChoice, 1),
+			}
+		}
+		for i, word := range strings.SplitAfter(content, " ") {
+			if i > 0 {
+				time.Sleep(p.opts.ChunkDelay)
+			}
+			c := chunk()
+			c.Choices[0].Delta.Content = word
+			chunkCh <- c
+		}
+		final := chunk()
+		final.Choices[0].FinishReason = "stop"
+		chunkCh <- final
+	}()
+
+	return chunkCh, errCh
+}
@@ -0,0 +1,59 @@
+package mock
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestScriptedResponses(t *testing.T) {
+	p := NewProvider(Options{Responses: []string{"one two", "three"}})
+	req := providers.ChatRequest{Model: "m", Messages: []providers.Message{{Role: "user", Content: "hi"}}}
+
+	for _, want := range []string{"one two", "three", "one two"} {
+		resp, err := p.Chat(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resp.Choices[0].Message.Content; got != want {
+			t.Errorf("content = %q, want %q", got, want)
+		}
+	}
+
+	resp, err := providers.Collect("mock", p, req, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Choices[0].Message.Content; got != "three" {
+		t.Errorf("streamed content = %q", got)
+	}
+	if resp.Choices[0].FinishReason != "stop" {
+		t.Errorf("finish reason = %q", resp.Choices[0].FinishReason)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	p := NewProvider(Options{})
+	resp, err := providers.Collect("mock", p, providers.ChatRequest{Messages: []providers.Message{{Role: "user", Content: "say this back"}}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Choices[0].Message.Content; got != "Mock: say this back" {
+		t.Errorf("content = %q", got)
+	}
+}
+
+func TestInjectedErrors(t *testing.T) {
+	p := NewProvider(Options{Name: "dev-openai", ErrorRate: 1, ErrorStatus: 429})
+	_, err := p.Chat(providers.ChatRequest{})
+	var se *providers.StatusError
+	if !errors.As(err, &se) || se.Code != 429 || se.Provider != "dev-openai" {
+		t.Fatalf("err = %v", err)
+	}
+
+	_, errCh := p.ChatStream(providers.ChatRequest{})
+	if err := <-errCh; !errors.As(err, &se) {
+		t.Fatalf("stream err = %v", err)
+	}
+}
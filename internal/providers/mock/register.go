@@ -0,0 +1,25 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func init() {
+	providers.Register("mock", func(name string, opts config.ProviderOptions) (providers.Provider, error) {
+		var m config.MockConfig
+		if opts.Mock != nil {
+			m = *opts.Mock
+		}
+		return NewProvider(Options{
+			Name:        name,
+			Responses:   m.Responses,
+			Latency:     time.Duration(m.LatencyMS) * time.Millisecond,
+			ChunkDelay:  time.Duration(m.ChunkDelayMS) * time.Millisecond,
+			ErrorRate:   m.ErrorRate,
+			ErrorStatus: m.ErrorStatus,
+		}), nil
+	})
+}
@@ -5,63 +5,112 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
-	"time"
 
+	"github.com/yewintnaing/ai-gateway/internal/config"
 	"github.com/yewintnaing/ai-gateway/internal/providers"
 )
 
 type Provider struct {
-	apiKey  string
-	baseURL string
-	version string
-	client  *http.Client
+	name       string
+	apiKey     string
+	baseURL    string
+	version    string
+	authHeader string
+	authScheme string
+	requireKey bool
+	client     *providers.Client
+	// accounts are the organizations and projects calls may be billed to,
+	// by name; "" is the provider's own.
+	accounts map[string]config.ProviderAccount
 }
 
 func NewProvider(apiKey string, baseURL string, version string) *Provider {
 	return &Provider{
-		apiKey:  apiKey,
-		baseURL: baseURL,
-		version: version,
-		client:  &http.Client{Timeout: 30 * time.Second},
+		name:       "openai",
+		apiKey:     apiKey,
+		baseURL:    baseURL,
+		version:    version,
+		authHeader: "Authorization",
+		authScheme: "Bearer",
+		requireKey: true,
+		client:     providers.NewClient(nil),
 	}
 }
 
+// NewCompatible returns a provider for any server that speaks OpenAI's chat
+// completions API. The key is sent in authHeader, prefixed with authScheme
+// unless that is "none"; an empty key sends no auth header at all.
+func NewCompatible(name, baseURL, apiKey, authHeader, authScheme string) *Provider {
+	if authHeader == "" {
+		authHeader = "Authorization"
+	}
+	if authScheme == "" {
+		authScheme = "Bearer"
+	}
+	return &Provider{
+		name:       name,
+		apiKey:     apiKey,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		authHeader: authHeader,
+		authScheme: authScheme,
+		client:     providers.NewClient(nil),
+	}
+}
+
+// setAuth adds the API key to an outbound request, with the organization
+// and project of the account it is billed to. An account without a key of
+// its own uses the provider's.
+func (p *Provider) setAuth(h http.Header, account string) {
+	a := p.accounts[account]
+	if a.Organization != "" {
+		h.Set("OpenAI-Organization", a.Organization)
+	}
+	if a.Project != "" {
+		h.Set("OpenAI-Project", a.Project)
+	}
+	key := p.key(account)
+	if key == "" {
+		return
+	}
+	if p.authScheme == "none" {
+		h.Set(p.authHeader, key)
+		return
+	}
+	h.Set(p.authHeader, p.authScheme+" "+key)
+}
+
+// key returns the API key of account, or the provider's.
+func (p *Provider) key(account string) string {
+	if key := p.accounts[account].APIKey(); key != "" {
+		return key
+	}
+	return p.apiKey
+}
+
+// SetAccounts sets the accounts calls may be billed to from the provider's
+// options.
+func (p *Provider) SetAccounts(opts config.ProviderOptions) {
+	p.accounts = map[string]config.ProviderAccount{"": opts.Account("")}
+	for name, a := range opts.Accounts {
+		p.accounts[name] = a
+	}
+}
+
+// statusError reads a failed response into a StatusError.
+func (p *Provider) statusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &providers.StatusError{Provider: p.name, Code: resp.StatusCode, Message: string(msg)}
+}
+
 func (p *Provider) Chat(req providers.ChatRequest) (*providers.ChatResponse, error) {
-	if p.apiKey == "" {
+	if p.key(req.Account) == "" && p.requireKey {
 		return nil, fmt.Errorf("OPENAI_API_KEY is not set")
 	}
 
-	if p.apiKey == "mock" {
-		return &providers.ChatResponse{
-			ID:      "mock-openai-id",
-			Object:  "chat.completion",
-			Created: time.Now().Unix(),
-			Model:   req.Model,
-			Choices: []struct {
-				Index        int               `json:"index"`
-				Message      providers.Message `json:"message"`
-				FinishReason string            `json:"finish_reason"`
-			}{
-				{
-					Index: 0,
-					Message: providers.Message{
-						Role:    "assistant",
-						Content: fmt.Sprintf("OpenAI Mock: %s", req.Messages[len(req.Messages)-1].Content),
-					},
-					FinishReason: "stop",
-				},
-			},
-			Usage: providers.Usage{
-				PromptTokens:     10,
-				CompletionTokens: 20,
-				TotalTokens:      30,
-			},
-		}, nil
-	}
-
-	body, err := json.Marshal(req)
+	body, err := req.MarshalBody(wireRequest(req))
 	if err != nil {
 		return nil, err
 	}
@@ -71,19 +120,18 @@ func (p *Provider) Chat(req providers.ChatRequest) (*providers.ChatResponse, err
 		return nil, err
 	}
 
+	req.SetHeaders(httpReq.Header)
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	p.setAuth(httpReq.Header, req.Account)
 
-	resp, err := p.client.Do(httpReq)
+	resp, err := p.client.Do(req, httpReq)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errData map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&errData)
-		return nil, fmt.Errorf("openai error (status %d): %v", resp.StatusCode, errData)
+		return nil, p.statusError(resp)
 	}
 
 	var chatResp providers.ChatResponse
@@ -98,61 +146,8 @@ func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatC
 	chunkCh := make(chan providers.ChatChunk)
 	errCh := make(chan error, 1)
 
-	if p.apiKey == "mock" {
-		go func() {
-			defer close(chunkCh)
-			defer close(errCh)
-			content := fmt.Sprintf("OpenAI Mock Stream: %s", req.Messages[len(req.Messages)-1].Content)
-			words := strings.Split(content, " ")
-			for i, word := range words {
-				chunkCh <- providers.ChatChunk{
-					ID:      "mock-stream-id",
-					Object:  "chat.completion.chunk",
-					Created: time.Now().Unix(),
-					Model:   req.Model,
-					Choices: []struct {
-						Index int `json:"index"`
-						Delta struct {
-							Content string `json:"content"`
-						} `json:"delta"`
-						FinishReason string `json:"finish_reason"`
-					}{
-						{
-							Index: 0,
-							Delta: struct {
-								Content string `json:"content"`
-							}{Content: word + " "},
-						},
-					},
-				}
-				if i == len(words)-1 {
-					chunkCh <- providers.ChatChunk{
-						ID:      "mock-stream-id",
-						Object:  "chat.completion.chunk",
-						Created: time.Now().Unix(),
-						Model:   req.Model,
-						Choices: []struct {
-							Index int `json:"index"`
-							Delta struct {
-								Content string `json:"content"`
-							} `json:"delta"`
-							FinishReason string `json:"finish_reason"`
-						}{
-							{
-								Index:        0,
-								FinishReason: "stop",
-							},
-						},
-					}
-				}
-				time.Sleep(50 * time.Millisecond)
-			}
-		}()
-		return chunkCh, errCh
-	}
-
 	req.Stream = true
-	body, err := json.Marshal(req)
+	body, err := req.MarshalBody(wireRequest(req))
 	if err != nil {
 		errCh <- err
 		return chunkCh, errCh
@@ -162,16 +157,17 @@ func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatC
 		defer close(chunkCh)
 		defer close(errCh)
 
-		httpReq, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(body))
+		httpReq, err := http.NewRequest("POST", p.baseURL+"/chat/completions", bytes.NewBuffer(body))
 		if err != nil {
 			errCh <- err
 			return
 		}
 
+		req.SetHeaders(httpReq.Header)
 		httpReq.Header.Set("Content-Type", "application/json")
-		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+		p.setAuth(httpReq.Header, req.Account)
 
-		resp, err := p.client.Do(httpReq)
+		resp, err := p.client.Do(req, httpReq)
 		if err != nil {
 			errCh <- err
 			return
@@ -179,9 +175,7 @@ func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatC
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
-			var errData map[string]interface{}
-			json.NewDecoder(resp.Body).Decode(&errData)
-			errCh <- fmt.Errorf("openai streaming error (status %d): %v", resp.StatusCode, errData)
+			errCh <- p.statusError(resp)
 			return
 		}
 
@@ -189,6 +183,9 @@ func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatC
 		for {
 			line, err := reader.ReadString('\n')
 			if err != nil {
+				if err != io.EOF {
+					errCh <- err
+				}
 				break
 			}
 
@@ -216,3 +213,13 @@ func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatC
 
 	return chunkCh, errCh
 }
+
+// wireRequest is req as sent: an unset temperature goes out as 0, as it
+// always has, rather than leaving the backend to pick its own default.
+func wireRequest(req providers.ChatRequest) providers.ChatRequest {
+	if req.Temperature == nil {
+		temperature := 0.0
+		req.Temperature = &temperature
+	}
+	return req
+}
@@ -0,0 +1,103 @@
+package openai
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func TestCompatibleAuth(t *testing.T) {
+	cases := []struct {
+		name, header, scheme, key string
+		wantHeader, wantValue     string
+	}{
+		{name: "default bearer", key: "k1", wantHeader: "Authorization", wantValue: "Bearer k1"},
+		{name: "custom header, bare key", header: "api-key", scheme: "none", key: "k2", wantHeader: "Api-Key", wantValue: "k2"},
+		{name: "custom scheme", scheme: "Token", key: "k3", wantHeader: "Authorization", wantValue: "Token k3"},
+		{name: "no key", wantHeader: "Authorization", wantValue: ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/chat/completions" {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				if got := r.Header.Get(tc.wantHeader); got != tc.wantValue {
+					t.Errorf("expected %s %q, got %q", tc.wantHeader, tc.wantValue, got)
+				}
+				fmt.Fprint(w, `{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)
+			}))
+			defer srv.Close()
+
+			p := NewCompatible("vllm", srv.URL+"/v1/", tc.key, tc.header, tc.scheme)
+			resp, err := p.Chat(providers.ChatRequest{Model: "llama", Messages: []providers.Message{{Role: "user", Content: "hi"}}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Choices[0].Message.Content != "ok" {
+				t.Errorf("unexpected response %+v", resp)
+			}
+		})
+	}
+}
+
+func TestCompatibleStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	chunkCh, errCh := NewCompatible("groq", srv.URL, "", "", "").ChatStream(providers.ChatRequest{Model: "llama"})
+	var text string
+	for c := range chunkCh {
+		text += c.Choices[0].Delta.Content
+	}
+	if err := <-errCh; err != nil || text != "Hello" {
+		t.Errorf("expected Hello, got %q (%v)", text, err)
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		fmt.Fprint(w, `{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+
+	p := NewProvider("sk-main", srv.URL, "")
+	p.SetAccounts(config.ProviderOptions{
+		Organization: "org-main",
+		Accounts: map[string]config.ProviderAccount{
+			"research": {Organization: "org-research", Project: "proj-eval", Key: "sk-research"},
+			"shared":   {Project: "proj-shared"},
+		},
+	})
+	cases := []struct {
+		account, key, org, project string
+	}{
+		{account: "", key: "sk-main", org: "org-main"},
+		{account: "research", key: "sk-research", org: "org-research", project: "proj-eval"},
+		{account: "shared", key: "sk-main", project: "proj-shared"},
+	}
+	for _, tc := range cases {
+		if _, err := p.Chat(providers.ChatRequest{Model: "gpt-4o", Account: tc.account}); err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.account, err)
+		}
+		if v := got.Get("Authorization"); v != "Bearer "+tc.key {
+			t.Errorf("%q: Authorization = %q", tc.account, v)
+		}
+		if v := got.Get("OpenAI-Organization"); v != tc.org {
+			t.Errorf("%q: OpenAI-Organization = %q, want %q", tc.account, v, tc.org)
+		}
+		if v := got.Get("OpenAI-Project"); v != tc.project {
+			t.Errorf("%q: OpenAI-Project = %q, want %q", tc.account, v, tc.project)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package openai
+
+import (
+	"fmt"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func init() {
+	providers.Register("openai", func(name string, opts config.ProviderOptions) (providers.Provider, error) {
+		baseURL := opts.BaseURL
+		if baseURL == "" {
+			baseURL = "https://api.openai.com/v1"
+		}
+		p := NewProvider(opts.APIKey(), baseURL, opts.APIVersion)
+		p.name = name
+		p.client = providers.NewClient(opts.Timeouts)
+		p.SetAccounts(opts)
+		return p, nil
+	})
+	providers.Register("openai-compatible", func(name string, opts config.ProviderOptions) (providers.Provider, error) {
+		if opts.BaseURL == "" {
+			return nil, fmt.Errorf("base_url is required")
+		}
+		p := NewCompatible(name, opts.BaseURL, opts.APIKey(), opts.AuthHeader, opts.AuthScheme)
+		p.client = providers.NewClient(opts.Timeouts)
+		p.SetAccounts(opts)
+		return p, nil
+	})
+}
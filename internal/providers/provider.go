@@ -1,21 +1,277 @@
 package providers
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"time"
 )
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	// Reasoning is the model's thinking or reasoning summary, as
+	// OpenAI-compatible reasoning models return it. ReasoningSignature is
+	// Anthropic's signature over a thinking block, which must be sent back
+	// unchanged with the block on later turns.
+	Reasoning          string `json:"reasoning_content,omitempty"`
+	ReasoningSignature string `json:"reasoning_signature,omitempty"`
+}
+
+// Tool is a function the model may call, in OpenAI's format.
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is the model asking for a tool to be run. Arguments is a JSON
+// object encoded as a string.
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 type ChatRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	Temperature float64   `json:"temperature"`
-	MaxTokens   int       `json:"max_tokens"`
-	Stream      bool      `json:"stream"`
+	Model            string             `json:"model"`
+	Messages         []Message          `json:"messages"`
+	Temperature      *float64           `json:"temperature,omitempty"`
+	MaxTokens        int                `json:"max_tokens"`
+	Stream           bool               `json:"stream"`
+	TopP             *float64           `json:"top_p,omitempty"`
+	Stop             StopSequences      `json:"stop,omitempty"`
+	PresencePenalty  *float64           `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64           `json:"frequency_penalty,omitempty"`
+	LogitBias        map[string]float64 `json:"logit_bias,omitempty"`
+	Seed             *int               `json:"seed,omitempty"`
+	N                int                `json:"n,omitempty"`
+	Tools            []Tool             `json:"tools,omitempty"`
+	ToolChoice       json.RawMessage    `json:"tool_choice,omitempty"`
+	// ReasoningEffort is OpenAI's "low", "medium" or "high"; providers with
+	// a thinking budget translate it.
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+	// Thinking is an explicit Anthropic thinking budget. It takes precedence
+	// over ReasoningEffort on providers that support it.
+	Thinking *Thinking `json:"-"`
+
+	// Headers are extra HTTP headers for the outbound provider call, such as
+	// trace context. They are not part of the request body.
+	Headers map[string]string `json:"-"`
+	// Extra are provider-specific body parameters, added to the provider's
+	// wire request by MarshalBody.
+	Extra map[string]interface{} `json:"-"`
+	// Account names the provider account the call is billed to, such as an
+	// OpenAI organization and project with its own key. Providers without
+	// accounts ignore it.
+	Account string `json:"-"`
+	// Timeout bounds the whole provider call, including reading a streamed
+	// response; zero means DefaultTimeout. A provider's total timeout
+	// lowers it.
+	Timeout time.Duration `json:"-"`
+}
+
+// TemperatureValue returns the request's temperature, 0 when unset.
+func (r ChatRequest) TemperatureValue() float64 {
+	if r.Temperature == nil {
+		return 0
+	}
+	return *r.Temperature
+}
+
+// overridable are the standard parameters a route target's params set on
+// the request itself, so that each provider translates them.
+var overridable = map[string]bool{
+	"temperature": true, "max_tokens": true, "top_p": true, "stop": true,
+	"presence_penalty": true, "frequency_penalty": true, "logit_bias": true,
+	"seed": true, "n": true, "tool_choice": true, "reasoning_effort": true,
+}
+
+// Thinking enables extended thinking with a token budget.
+type Thinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// WithParams applies a route target's params: standard parameters replace
+// the client's values and the rest are kept in Extra.
+func (r ChatRequest) WithParams(params map[string]interface{}) (ChatRequest, error) {
+	if len(params) == 0 {
+		return r, nil
+	}
+	extra := make(map[string]interface{}, len(r.Extra)+len(params))
+	for k, v := range r.Extra {
+		extra[k] = v
+	}
+	std := map[string]interface{}{}
+	for k, v := range params {
+		if overridable[k] {
+			std[k] = v
+		} else {
+			extra[k] = v
+		}
+	}
+	if len(std) > 0 {
+		data, err := json.Marshal(std)
+		if err == nil {
+			err = json.Unmarshal(data, &r)
+		}
+		if err != nil {
+			return r, fmt.Errorf("invalid target params: %w", err)
+		}
+	}
+	if len(extra) > 0 {
+		r.Extra = extra
+	}
+	return r, nil
+}
+
+// WithDefaults fills in a provider's request defaults: standard parameters
+// the request leaves unset, and others it has no Extra value for.
+func (r ChatRequest) WithDefaults(defaults map[string]interface{}) (ChatRequest, error) {
+	if len(defaults) == 0 {
+		return r, nil
+	}
+	var current map[string]interface{}
+	data, err := json.Marshal(r)
+	if err == nil {
+		err = json.Unmarshal(data, &current)
+	}
+	if err != nil {
+		return r, err
+	}
+	std := map[string]interface{}{}
+	var extra map[string]interface{}
+	for k, v := range defaults {
+		switch {
+		case overridable[k]:
+			// A zero max_tokens or n means unset; an explicit temperature
+			// of 0 is kept.
+			if cur, ok := current[k]; !ok || cur == nil || cur == 0.0 && k != "temperature" {
+				std[k] = v
+			}
+		case r.Extra[k] == nil:
+			if extra == nil {
+				extra = make(map[string]interface{}, len(r.Extra)+len(defaults))
+				for ek, ev := range r.Extra {
+					extra[ek] = ev
+				}
+			}
+			extra[k] = v
+		}
+	}
+	if len(std) > 0 {
+		data, err := json.Marshal(std)
+		if err == nil {
+			err = json.Unmarshal(data, &r)
+		}
+		if err != nil {
+			return r, fmt.Errorf("invalid request defaults: %w", err)
+		}
+	}
+	if extra != nil {
+		r.Extra = extra
+	}
+	return r, nil
+}
+
+// MarshalBody encodes a provider's wire request with the request's Extra
+// parameters merged in at the top level.
+func (r ChatRequest) MarshalBody(body interface{}) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil || len(r.Extra) == 0 {
+		return data, err
+	}
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for k, v := range r.Extra {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("param %s: %w", k, err)
+		}
+		merged[k] = raw
+	}
+	return json.Marshal(merged)
+}
+
+// SetHeaders copies the request's extra headers onto an outbound request.
+func (r ChatRequest) SetHeaders(h http.Header) {
+	for k, v := range r.Headers {
+		h.Set(k, v)
+	}
+}
+
+// StopSequences accepts either a single string or an array of strings, as the
+// OpenAI API does for the "stop" parameter.
+type StopSequences []string
+
+func (s *StopSequences) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		if single == "" {
+			*s = nil
+		} else {
+			*s = StopSequences{single}
+		}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("stop must be a string or an array of strings")
+	}
+	*s = many
+	return nil
+}
+
+// StatusError is an upstream failure that carries the HTTP status code the
+// provider (or something standing in for it) returned.
+type StatusError struct {
+	Provider string
+	Code     int
+	Message  string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s error (status %d): %s", e.Provider, e.Code, e.Message)
+}
+
+// UnsupportedParamError is returned when a request carries a parameter the
+// target provider cannot honour. It is never retryable.
+type UnsupportedParamError struct {
+	Provider string
+	Param    string
+}
+
+func (e *UnsupportedParamError) Error() string {
+	return fmt.Sprintf("%s does not support parameter %q", e.Provider, e.Param)
+}
+
+// ConstraintError is returned when a request breaks a rule of the target
+// provider's API that its request defaults do not settle, such as
+// Anthropic's required max_tokens. Like UnsupportedParamError it is the
+// client's to fix and never retryable.
+type ConstraintError struct {
+	Provider   string
+	Constraint string
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%s requires %s", e.Provider, e.Constraint)
 }
 
 type ChatResponse struct {
@@ -29,6 +285,9 @@ type ChatResponse struct {
 		FinishReason string  `json:"finish_reason"`
 	} `json:"choices"`
 	Usage Usage `json:"usage"`
+	// TTFT is how long the first chunk took when the response was
+	// assembled from a stream.
+	TTFT time.Duration `json:"-"`
 }
 
 type AnthropicResponse struct {
@@ -67,7 +326,7 @@ func (anthropicResponse *AnthropicResponse) ToChatResponse() *ChatResponse {
 					Role:    anthropicResponse.Role,
 					Content: contentText,
 				},
-				FinishReason: anthropicResponse.StopReason,
+				FinishReason: NormalizeFinishReason(anthropicResponse.StopReason),
 			},
 		},
 		Usage: Usage{
@@ -98,12 +357,25 @@ type AnthropicMessageStart struct {
 	} `json:"message"`
 }
 
+type AnthropicContentBlockStart struct {
+	Type         string `json:"type"`
+	Index        int    `json:"index"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+}
+
 type AnthropicContentBlockDelta struct {
 	Type  string `json:"type"`
 	Index int    `json:"index"`
 	Delta struct {
-		Type string `json:"type"`
-		Text string `json:"text"`
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		Thinking    string `json:"thinking"`
+		Signature   string `json:"signature"`
 	} `json:"delta"`
 }
 
@@ -122,20 +394,51 @@ type Usage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	// CompletionTokensDetails breaks completion tokens down; reasoning
+	// tokens are part of CompletionTokens and billed as output.
+	CompletionTokensDetails *CompletionTokensDetails `json:"completion_tokens_details,omitempty"`
+}
+
+type CompletionTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
+// ReasoningTokens returns how many completion tokens went to reasoning.
+func (u Usage) ReasoningTokens() int {
+	if u.CompletionTokensDetails == nil {
+		return 0
+	}
+	return u.CompletionTokensDetails.ReasoningTokens
 }
 
 type ChatChunk struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int64  `json:"created"`
-	Model   string `json:"model"`
-	Choices []struct {
-		Index int `json:"index"`
-		Delta struct {
-			Content string `json:"content"`
-		} `json:"delta"`
-		FinishReason string `json:"finish_reason"`
-	} `json:"choices"`
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []ChunkChoice `json:"choices"`
+}
+
+type ChunkChoice struct {
+	Index        int        `json:"index"`
+	Delta        ChunkDelta `json:"delta"`
+	FinishReason string     `json:"finish_reason"`
+}
+
+type ChunkDelta struct {
+	Content            string          `json:"content"`
+	ToolCalls          []ToolCallDelta `json:"tool_calls,omitempty"`
+	Reasoning          string          `json:"reasoning_content,omitempty"`
+	ReasoningSignature string          `json:"reasoning_signature,omitempty"`
+}
+
+// ToolCallDelta is a fragment of a streamed tool call. The first fragment for
+// a call carries its ID and name; later ones append to Arguments.
+type ToolCallDelta struct {
+	Index    int              `json:"index"`
+	ID       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function ToolCallFunction `json:"function"`
 }
 
 type Provider interface {
@@ -41,8 +41,8 @@ func TestAnthropicResponse_ToChatResponse(t *testing.T) {
 		if chatResp.Choices[0].Message.Role != "assistant" {
 			t.Errorf("Expected role 'assistant', got '%s'", chatResp.Choices[0].Message.Role)
 		}
-		if chatResp.Choices[0].FinishReason != "end_turn" {
-			t.Errorf("Expected finish reason 'end_turn', got '%s'", chatResp.Choices[0].FinishReason)
+		if chatResp.Choices[0].FinishReason != "stop" {
+			t.Errorf("Expected finish reason 'stop', got '%s'", chatResp.Choices[0].FinishReason)
 		}
 		if chatResp.Usage.PromptTokens != 10 {
 			t.Errorf("Expected 10 prompt tokens, got %d", chatResp.Usage.PromptTokens)
@@ -79,3 +79,37 @@ func TestAnthropicResponse_ToChatResponse(t *testing.T) {
 		}
 	})
 }
+
+func TestWithParams(t *testing.T) {
+	temperature := 0.2
+	req := ChatRequest{Model: "claude-3-7-sonnet", Temperature: &temperature, MaxTokens: 100}
+	out, err := req.WithParams(map[string]interface{}{
+		"temperature": 1,
+		"thinking":    map[string]interface{}{"type": "enabled", "budget_tokens": 2048},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *out.Temperature != 1 || out.MaxTokens != 100 {
+		t.Errorf("expected temperature overridden and max_tokens kept, got %v / %d", *out.Temperature, out.MaxTokens)
+	}
+	if _, ok := out.Extra["temperature"]; ok {
+		t.Error("standard params should not be passed through as extras")
+	}
+
+	body, err := out.MarshalBody(map[string]interface{}{"model": out.Model, "temperature": *out.Temperature})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"model":"claude-3-7-sonnet","temperature":1,"thinking":{"budget_tokens":2048,"type":"enabled"}}`
+	if string(body) != want {
+		t.Errorf("expected %s, got %s", want, body)
+	}
+
+	if _, err := req.WithParams(map[string]interface{}{"temperature": "hot"}); err == nil {
+		t.Error("expected a badly typed standard param to be rejected")
+	}
+	if req.Extra != nil {
+		t.Error("WithParams must not modify the original request")
+	}
+}
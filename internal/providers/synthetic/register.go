@@ -0,0 +1,25 @@
+package synthetic
+
+import (
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func init() {
+	providers.Register("synthetic", func(name string, opts config.ProviderOptions) (providers.Provider, error) {
+		var s config.SyntheticConfig
+		if opts.Synthetic != nil {
+			s = *opts.Synthetic
+		}
+		return NewProvider(Options{
+			Distribution: Distribution(s.Distribution),
+			Latency:      time.Duration(s.LatencyMS) * time.Millisecond,
+			Jitter:       time.Duration(s.JitterMS) * time.Millisecond,
+			ErrorRate:    s.ErrorRate,
+			Tokens:       s.Tokens,
+			TokenDelay:   time.Duration(s.TokenDelayMS) * time.Millisecond,
+		}), nil
+	})
+}
@@ -0,0 +1,163 @@
+package synthetic
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// Distribution selects how per-request latency is sampled.
+type Distribution string
+
+const (
+	DistFixed       Distribution = "fixed"
+	DistUniform     Distribution = "uniform"
+	DistNormal      Distribution = "normal"
+	DistExponential Distribution = "exponential"
+)
+
+// Options controls the behaviour of the synthetic provider. Latency is the
+// mean time-to-first-token; Jitter is the spread (half-width for uniform,
+// standard deviation for normal) and is ignored for fixed and exponential.
+type Options struct {
+	Distribution Distribution
+	Latency      time.Duration
+	Jitter       time.Duration
+	ErrorRate    float64
+	Tokens       int
+	TokenDelay   time.Duration
+}
+
+// Provider is a fake upstream with no network dependency, used to
+// capacity-plan the gateway itself under load.
+type Provider struct {
+	opts Options
+	mu   sync.Mutex
+	rng  *rand.Rand
+}
+
+func NewProvider(opts Options) *Provider {
+	if opts.Distribution == "" {
+		opts.Distribution = DistFixed
+	}
+	if opts.Tokens <= 0 {
+		opts.Tokens = 32
+	}
+	return &Provider{opts: opts, rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (p *Provider) sampleLatency() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	mean := float64(p.opts.Latency)
+	jitter := float64(p.opts.Jitter)
+	var d float64
+	switch p.opts.Distribution {
+	case DistUniform:
+		d = mean - jitter + p.rng.Float64()*2*jitter
+	case DistNormal:
+		d = mean + p.rng.NormFloat64()*jitter
+	case DistExponential:
+		d = p.rng.ExpFloat64() * mean
+	default:
+		d = mean
+	}
+	return time.Duration(math.Max(d, 0))
+}
+
+func (p *Provider) shouldFail() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rng.Float64() < p.opts.ErrorRate
+}
+
+func (p *Provider) words() []string {
+	words := make([]string, p.opts.Tokens)
+	for i := range words {
+		words[i] = fmt.Sprintf("tok%d", i)
+	}
+	return words
+}
+
+func promptTokens(req providers.ChatRequest) int {
+	n := 0
+	for _, m := range req.Messages {
+		n += len(m.Content) / 4
+	}
+	return n
+}
+
+func (p *Provider) Chat(req providers.ChatRequest) (*providers.ChatResponse, error) {
+	time.Sleep(p.sampleLatency() + time.Duration(p.opts.Tokens)*p.opts.TokenDelay)
+	if p.shouldFail() {
+		return nil, &providers.StatusError{Provider: "synthetic", Code: http.StatusServiceUnavailable, Message: "injected failure"}
+	}
+
+	resp := &providers.ChatResponse{
+		ID:      "synthetic-id",
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Usage: providers.Usage{
+			PromptTokens:     promptTokens(req),
+			CompletionTokens: p.opts.Tokens,
+			TotalTokens:      promptTokens(req) + p.opts.Tokens,
+		},
+	}
+	resp.Choices = append(resp.Choices, struct {
+		Index        int               `json:"index"`
+		Message      providers.Message `json:"message"`
+		FinishReason string            `json:"finish_reason"`
+	}{
+		Message:      providers.Message{Role: "assistant", Content: strings.Join(p.words(), " ")},
+		FinishReason: "stop",
+	})
+	return resp, nil
+}
+
+func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatChunk, <-chan error) {
+	chunkCh := make(chan providers.ChatChunk)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(chunkCh)
+		defer close(errCh)
+
+		time.Sleep(p.sampleLatency())
+		if p.shouldFail() {
+			errCh <- &providers.StatusError{Provider: "synthetic", Code: http.StatusServiceUnavailable, Message: "injected failure"}
+			return
+		}
+
+		created := time.Now().Unix()
+		for _, word := range p.words() {
+			var chunk providers.ChatChunk
+			chunk.ID = "synthetic-stream-id"
+			chunk.Object = "chat.completion.chunk"
+			chunk.Created = created
+			chunk.Model = req.Model
+			chunk.Choices = make([]providers.ChunkChoice, 1)
+			chunk.Choices[0].Delta.Content = word + " "
+			chunkCh <- chunk
+			time.Sleep(p.opts.TokenDelay)
+		}
+
+		var final providers.ChatChunk
+		final.ID = "synthetic-stream-id"
+		final.Object = "chat.completion.chunk"
+		final.Created = created
+		final.Model = req.Model
+		final.Choices = make([]providers.ChunkChoice, 1)
+		final.Choices[0].FinishReason = "stop"
+		chunkCh <- final
+	}()
+
+	return chunkCh, errCh
+}
@@ -0,0 +1,28 @@
+package synthetic
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/router"
+)
+
+func TestInjectedFailures(t *testing.T) {
+	p := NewProvider(Options{ErrorRate: 1})
+	req := providers.ChatRequest{Model: "m", Messages: []providers.Message{{Role: "user", Content: "hi"}}}
+
+	_, err := p.Chat(req)
+	var se *providers.StatusError
+	if !errors.As(err, &se) || se.Code != http.StatusServiceUnavailable || !router.IsRetryable(err) {
+		t.Errorf("expected a retryable 503, got %v", err)
+	}
+
+	chunks, errs := p.ChatStream(req)
+	for range chunks {
+	}
+	if err := <-errs; !errors.As(err, &se) || se.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a 503 from the stream, got %v", err)
+	}
+}
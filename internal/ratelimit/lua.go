@@ -2,18 +2,48 @@ package ratelimit
 
 import "github.com/redis/go-redis/v9"
 
+// IncrementAndCheckLua adds tokens to the window's counter unless that would
+// exceed the limit. It returns {allowed, used} where used is the counter
+// after the call.
 var IncrementAndCheckLua = redis.NewScript(`
 local key = KEYS[1]
 local tokens = tonumber(ARGV[1])
 local limit = tonumber(ARGV[2])
 local ttl = tonumber(ARGV[3])
 
-local current = redis.call("GET", key)
-if current and tonumber(current) + tokens > limit then
-    return 0
+local used = tonumber(redis.call("GET", key) or "0")
+if used + tokens > limit then
+    return {0, used}
 end
 
-redis.call("INCRBY", key, tokens)
+used = redis.call("INCRBY", key, tokens)
 redis.call("EXPIRE", key, ttl)
-return 1
+return {1, used}
+`)
+
+// SpendCreditLua takes tokens from a burst credit pool, a hash of its
+// balance and the time of its last refill in milliseconds, if the pool has
+// them. A missing pool starts full. It returns {spent, balance}.
+var SpendCreditLua = redis.NewScript(`
+local key = KEYS[1]
+local tokens = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local refill = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local ttl = tonumber(ARGV[5])
+
+local state = redis.call("HMGET", key, "balance", "at")
+local balance = tonumber(state[1]) or capacity
+local at = tonumber(state[2]) or now
+balance = math.min(capacity, balance + math.max(now - at, 0) / 1000 * refill)
+
+local spent = 0
+if balance >= tokens then
+    balance = balance - tokens
+    spent = 1
+end
+
+redis.call("HSET", key, "balance", tostring(balance), "at", now)
+redis.call("PEXPIRE", key, ttl)
+return {spent, math.floor(balance)}
 `)
@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Override relaxes the limit for one caller, e.g. for a launch event,
+// until ExpiresAt when set. An exempt caller is not limited at all. A
+// caller with a burst credit pool may go past the limit by spending from
+// the pool, which holds up to BurstTokens and refills at
+// BurstRefillPerMinute (never, when zero).
+type Override struct {
+	Tenant               string     `json:"tenant"`
+	Exempt               bool       `json:"exempt"`
+	BurstTokens          int        `json:"burst_tokens,omitempty"`
+	BurstRefillPerMinute int        `json:"burst_refill_per_minute,omitempty"`
+	ExpiresAt            *time.Time `json:"expires_at,omitempty"`
+	Reason               string     `json:"reason,omitempty"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+func (o Override) Validate() error {
+	if !o.Exempt && o.BurstTokens <= 0 {
+		return errors.New("an override needs exempt or burst_tokens")
+	}
+	if o.BurstTokens < 0 || o.BurstRefillPerMinute < 0 {
+		return errors.New("burst_tokens and burst_refill_per_minute may not be negative")
+	}
+	if o.BurstRefillPerMinute > 0 && o.BurstTokens == 0 {
+		return errors.New("burst_refill_per_minute needs burst_tokens")
+	}
+	return nil
+}
+
+// Active reports whether the override applies at now.
+func (o Override) Active(now time.Time) bool {
+	return o.ExpiresAt == nil || now.Before(*o.ExpiresAt)
+}
+
+// creditKey is the key of the override's pool. A new or changed override
+// starts with a full pool.
+func (o Override) creditKey(caller string) string {
+	return fmt.Sprintf("rl:credit:%s:%d", caller, o.UpdatedAt.UnixMilli())
+}
+
+// creditTTL is how long an untouched pool is kept: until it would be full
+// again, or until the override expires when it never refills.
+func (o Override) creditTTL(now time.Time) time.Duration {
+	if o.BurstRefillPerMinute > 0 {
+		return time.Duration(float64(o.BurstTokens)/float64(o.BurstRefillPerMinute)*float64(time.Minute)) + time.Minute
+	}
+	if o.ExpiresAt != nil {
+		return max(o.ExpiresAt.Sub(now), time.Minute)
+	}
+	return 30 * 24 * time.Hour
+}
+
+// CreditStore is a Store that also holds burst credit pools. Stores without
+// it give overrides no burst credit.
+type CreditStore interface {
+	// Spend takes tokens from key's pool if it has them, reporting whether
+	// it did and the balance afterwards. A pool starts full, holds up to
+	// capacity, refills at refill tokens per second, and is dropped ttl
+	// after it was last used.
+	Spend(ctx context.Context, key string, tokens, capacity int, refill float64, ttl time.Duration) (ok bool, balance int, err error)
+}
+
+// overrideTTL is how long an instance keeps a caller's override before
+// loading it again; changes made through this instance apply at once.
+const overrideTTL = 30 * time.Second
+
+// OverrideLoader loads a caller's override, reporting false when it has
+// none.
+type OverrideLoader func(ctx context.Context, caller string) (Override, bool, error)
+
+type overrideCache struct {
+	mu      sync.Mutex
+	load    OverrideLoader
+	entries map[string]cachedOverride
+}
+
+type cachedOverride struct {
+	override *Override
+	loaded   time.Time
+}
+
+// SetOverrides makes the limiter apply the overrides load returns.
+func (l *Limiter) SetOverrides(load OverrideLoader) {
+	l.overrides = &overrideCache{load: load, entries: map[string]cachedOverride{}}
+}
+
+// Invalidate drops the cached override of caller, after it was changed.
+func (l *Limiter) Invalidate(caller string) {
+	if l == nil || l.overrides == nil {
+		return
+	}
+	l.overrides.mu.Lock()
+	delete(l.overrides.entries, caller)
+	l.overrides.mu.Unlock()
+}
+
+// override returns caller's active override, if any. One that cannot be
+// loaded counts as none until it is loaded again.
+func (l *Limiter) override(ctx context.Context, caller string, now time.Time) *Override {
+	c := l.overrides
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	e, ok := c.entries[caller]
+	c.mu.Unlock()
+	if !ok || now.Sub(e.loaded) > overrideTTL {
+		o, found, err := c.load(ctx, caller)
+		if err != nil {
+			log.Printf("Warning: failed to load the rate limit override of %s: %v", caller, err)
+		}
+		e = cachedOverride{loaded: now}
+		if found && err == nil {
+			e.override = &o
+		}
+		c.mu.Lock()
+		c.entries[caller] = e
+		c.mu.Unlock()
+	}
+	if e.override == nil || !e.override.Active(now) {
+		return nil
+	}
+	return e.override
+}
+
+// BurstBalance returns what is left of caller's burst credit pool, and
+// false when it has none.
+func (l *Limiter) BurstBalance(ctx context.Context, caller string) (int, bool, error) {
+	if l == nil || l.store == nil {
+		return 0, false, nil
+	}
+	now := time.Now()
+	o := l.override(ctx, caller, now)
+	credits, ok := l.store.(CreditStore)
+	if o == nil || o.BurstTokens == 0 || !ok {
+		return 0, false, nil
+	}
+	_, balance, err := credits.Spend(ctx, o.creditKey(caller), 0, o.BurstTokens, float64(o.BurstRefillPerMinute)/60, o.creditTTL(now))
+	return balance, err == nil, err
+}
+
+// spendCredit takes tokens from caller's burst credit pool, if it has one
+// with enough left.
+func (l *Limiter) spendCredit(ctx context.Context, caller string, o *Override, tokens int, now time.Time) (bool, int, error) {
+	credits, ok := l.store.(CreditStore)
+	if o == nil || o.BurstTokens == 0 || !ok {
+		return false, 0, nil
+	}
+	return credits.Spend(ctx, o.creditKey(caller), tokens, o.BurstTokens, float64(o.BurstRefillPerMinute)/60, o.creditTTL(now))
+}
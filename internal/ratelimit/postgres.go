@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore shares counters between replicas through the
+// rate_limit_windows table, for deployments without Redis.
+type PostgresStore struct {
+	db    *pgxpool.Pool
+	swept atomic.Int64 // unix seconds of the last cleanup of expired rows
+}
+
+func NewPostgresStore(databaseURL string) (*PostgresStore, error) {
+	db, err := pgxpool.New(context.Background(), databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) Add(ctx context.Context, key string, tokens, limit int, ttl time.Duration) (bool, int, error) {
+	s.sweep()
+
+	// The conditional upsert locks the row, so concurrent adds to a window
+	// are applied one at a time against the latest count.
+	var used int
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO rate_limit_windows (key, used, expires_at)
+		SELECT $1, $2, NOW() + make_interval(secs => $4) WHERE $2 <= $3
+		ON CONFLICT (key) DO UPDATE SET used = rate_limit_windows.used + EXCLUDED.used
+		WHERE rate_limit_windows.used + EXCLUDED.used <= $3
+		RETURNING used
+	`, key, tokens, limit, ttl.Seconds()).Scan(&used)
+	if err == nil {
+		return true, used, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, 0, err
+	}
+
+	err = s.db.QueryRow(ctx, `SELECT used FROM rate_limit_windows WHERE key = $1`, key).Scan(&used)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, 0, err
+	}
+	return false, used, nil
+}
+
+func (s *PostgresStore) Spend(ctx context.Context, key string, tokens, capacity int, refill float64, ttl time.Duration) (bool, int, error) {
+	s.sweep()
+
+	// The pool's row is locked while it is refilled and spent from, so
+	// concurrent spends see each other's balance.
+	var spent bool
+	var balance float64
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO rate_limit_credits (key, balance, updated_at, expires_at)
+			VALUES ($1, $2, NOW(), NOW() + make_interval(secs => $3))
+			ON CONFLICT (key) DO NOTHING
+		`, key, capacity, ttl.Seconds())
+		if err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			WITH pool AS (
+				SELECT LEAST($3::float8, balance + EXTRACT(EPOCH FROM NOW() - updated_at) * $4::float8) AS balance
+				FROM rate_limit_credits WHERE key = $1 FOR UPDATE
+			)
+			UPDATE rate_limit_credits c
+			SET balance = CASE WHEN pool.balance >= $2 THEN pool.balance - $2 ELSE pool.balance END,
+				updated_at = NOW(),
+				expires_at = NOW() + make_interval(secs => $5)
+			FROM pool WHERE c.key = $1
+			RETURNING pool.balance >= $2, c.balance
+		`, key, tokens, capacity, refill, ttl.Seconds()).Scan(&spent, &balance)
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	return spent, int(balance), nil
+}
+
+// sweep deletes expired windows and credit pools, at most once a minute per
+// instance.
+func (s *PostgresStore) sweep() {
+	now := time.Now().Unix()
+	last := s.swept.Load()
+	if now-last < 60 || !s.swept.CompareAndSwap(last, now) {
+		return
+	}
+	go func() {
+		if _, err := s.db.Exec(context.Background(), `DELETE FROM rate_limit_windows WHERE expires_at < NOW()`); err != nil {
+			log.Printf("Warning: failed to delete expired rate limit windows: %v", err)
+		}
+		if _, err := s.db.Exec(context.Background(), `DELETE FROM rate_limit_credits WHERE expires_at < NOW()`); err != nil {
+			log.Printf("Warning: failed to delete expired burst credits: %v", err)
+		}
+	}()
+}
+
+func (s *PostgresStore) Close() {
+	s.db.Close()
+}
@@ -3,39 +3,96 @@ package ratelimit
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strconv"
 	"time"
-
-	"github.com/redis/go-redis/v9"
 )
 
 type Limiter struct {
-	client *redis.Client
-	limit  int
+	store     Store
+	limit     int
+	overrides *overrideCache
 }
 
-func NewLimiter(redisURL string, limit int) (*Limiter, error) {
-	opts, err := redis.ParseURL(redisURL)
-	if err != nil {
-		return nil, err
-	}
-	client := redis.NewClient(opts)
-	return &Limiter{client: client, limit: limit}, nil
+// Status is a caller's standing in the current one-minute window.
+type Status struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration // until the window rolls over
+	// Exempt is set for callers whose override exempts them; they have no
+	// window and a zero Limit.
+	Exempt bool
+	// Burst is set when the request went past the limit on burst credit,
+	// leaving BurstCredit in the caller's pool.
+	Burst       bool
+	BurstCredit int
+}
+
+func NewLimiter(store Store, limit int) *Limiter {
+	return &Limiter{store: store, limit: limit}
 }
 
 func (l *Limiter) Allow(ctx context.Context, caller string, tokens int) (bool, error) {
-	if l.client == nil {
-		return true, nil
+	s, err := l.Check(ctx, caller, tokens)
+	return s.Allowed, err
+}
+
+// Check charges tokens to caller's window if they fit and reports the
+// resulting status. Tokens that do not fit are taken from the caller's burst
+// credit pool instead, if it has one with enough left. A limiter without a
+// store allows everything and reports a zero Limit.
+func (l *Limiter) Check(ctx context.Context, caller string, tokens int) (Status, error) {
+	if l == nil || l.store == nil {
+		return Status{Allowed: true}, nil
 	}
 
 	now := time.Now()
+	o := l.override(ctx, caller, now)
+	if o != nil && o.Exempt {
+		return Status{Allowed: true, Exempt: true}, nil
+	}
 	key := fmt.Sprintf("rl:tokens:%s:%s", caller, now.Format("200601021504"))
 
-	// Atomic check and increment
-	res, err := IncrementAndCheckLua.Run(ctx, l.client, []string{key}, tokens, l.limit, 120).Result()
+	allowed, used, err := l.store.Add(ctx, key, tokens, l.limit, 2*time.Minute)
 	if err != nil {
-		return false, err
+		return Status{}, err
 	}
 
-	allowed := res.(int64) == 1
-	return allowed, nil
+	s := Status{
+		Allowed:   allowed,
+		Limit:     l.limit,
+		Remaining: max(l.limit-used, 0),
+		Reset:     now.Truncate(time.Minute).Add(time.Minute).Sub(now),
+	}
+	if !allowed {
+		spent, balance, err := l.spendCredit(ctx, caller, o, tokens, now)
+		if err != nil {
+			return Status{}, err
+		}
+		if spent {
+			s.Allowed, s.Burst, s.BurstCredit = true, true, balance
+		}
+	}
+	return s, nil
+}
+
+// SetHeaders writes the IETF draft RateLimit-* headers, plus Retry-After
+// when the request was rejected and x-gw-burst-credit when it went past the
+// limit on credit. Nothing is written without a limit.
+func (s Status) SetHeaders(h http.Header) {
+	if s.Limit <= 0 {
+		return
+	}
+	if s.Burst {
+		h.Set("x-gw-burst-credit", strconv.Itoa(s.BurstCredit))
+	}
+	reset := strconv.Itoa(int((s.Reset + time.Second - 1) / time.Second))
+	h.Set("RateLimit-Limit", strconv.Itoa(s.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(s.Remaining))
+	h.Set("RateLimit-Reset", reset)
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=60", s.Limit))
+	if !s.Allowed {
+		h.Set("Retry-After", reset)
+	}
 }
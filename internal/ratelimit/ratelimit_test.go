@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStatusSetHeaders(t *testing.T) {
+	h := http.Header{}
+	Status{Allowed: false, Limit: 1000, Remaining: 0, Reset: 12500 * time.Millisecond}.SetHeaders(h)
+
+	want := map[string]string{
+		"RateLimit-Limit":     "1000",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "13",
+		"RateLimit-Policy":    "1000;w=60",
+		"Retry-After":         "13",
+	}
+	for k, v := range want {
+		if got := h.Get(k); got != v {
+			t.Errorf("%s: expected %q, got %q", k, v, got)
+		}
+	}
+
+	h = http.Header{}
+	Status{Allowed: true, Limit: 1000, Remaining: 10}.SetHeaders(h)
+	if h.Get("Retry-After") != "" {
+		t.Errorf("expected no Retry-After on an allowed request")
+	}
+}
+
+func TestNilLimiterAllows(t *testing.T) {
+	var l *Limiter
+	s, err := l.Check(context.Background(), "acme", 10)
+	if err != nil || !s.Allowed {
+		t.Fatalf("expected a nil limiter to allow, got %+v, %v", s, err)
+	}
+	h := http.Header{}
+	s.SetHeaders(h)
+	if len(h) != 0 {
+		t.Errorf("expected no headers without a limit, got %v", h)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	if ok, used, _ := s.Add(ctx, "k", 60, 100, time.Minute); !ok || used != 60 {
+		t.Fatalf("first add: got %v, %d", ok, used)
+	}
+	if ok, used, _ := s.Add(ctx, "k", 50, 100, time.Minute); ok || used != 60 {
+		t.Fatalf("add past the limit: got %v, %d; want refused at 60", ok, used)
+	}
+	if ok, used, _ := s.Add(ctx, "k", 40, 100, time.Minute); !ok || used != 100 {
+		t.Fatalf("add up to the limit: got %v, %d", ok, used)
+	}
+
+	if ok, used, _ := s.Add(ctx, "short", 10, 100, time.Millisecond); !ok || used != 10 {
+		t.Fatalf("got %v, %d", ok, used)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, used, _ := s.Add(ctx, "short", 10, 100, time.Millisecond); used != 10 {
+		t.Errorf("expected an expired counter to restart, got %d", used)
+	}
+}
+
+func TestLimiterWithMemoryStore(t *testing.T) {
+	l := NewLimiter(NewMemoryStore(), 100)
+	ctx := context.Background()
+
+	s, err := l.Check(ctx, "acme", 70)
+	if err != nil || !s.Allowed || s.Remaining != 30 {
+		t.Fatalf("got %+v, %v; want allowed with 30 remaining", s, err)
+	}
+	if s, _ = l.Check(ctx, "acme", 40); s.Allowed || s.Remaining != 30 {
+		t.Errorf("got %+v; want refused with 30 remaining", s)
+	}
+	if s, _ = l.Check(ctx, "globex", 40); !s.Allowed {
+		t.Errorf("expected callers to have separate windows, got %+v", s)
+	}
+}
+
+func TestMemoryStoreSpend(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	if ok, balance, _ := s.Spend(ctx, "pool", 60, 100, 0, time.Minute); !ok || balance != 40 {
+		t.Fatalf("expected a new pool to start full, got %v, %d", ok, balance)
+	}
+	if ok, balance, _ := s.Spend(ctx, "pool", 50, 100, 0, time.Minute); ok || balance != 40 {
+		t.Fatalf("expected a spend past the balance refused, got %v, %d", ok, balance)
+	}
+
+	s.Spend(ctx, "refilling", 100, 100, 1000, time.Minute)
+	time.Sleep(20 * time.Millisecond)
+	if ok, _, _ := s.Spend(ctx, "refilling", 10, 100, 1000, time.Minute); !ok {
+		t.Error("expected the pool to refill")
+	}
+}
+
+func TestLimiterOverrides(t *testing.T) {
+	ctx := context.Background()
+	expired := time.Now().Add(-time.Hour)
+	overrides := map[string]Override{
+		"vip":    {Tenant: "vip", Exempt: true},
+		"launch": {Tenant: "launch", BurstTokens: 50},
+		"past":   {Tenant: "past", Exempt: true, ExpiresAt: &expired},
+	}
+	loads := 0
+	l := NewLimiter(NewMemoryStore(), 100)
+	l.SetOverrides(func(_ context.Context, caller string) (Override, bool, error) {
+		loads++
+		o, ok := overrides[caller]
+		return o, ok, nil
+	})
+
+	if s, _ := l.Check(ctx, "vip", 1000); !s.Allowed || !s.Exempt || s.Limit != 0 {
+		t.Errorf("expected an exempt caller allowed without a limit, got %+v", s)
+	}
+	if s, _ := l.Check(ctx, "past", 1000); s.Allowed {
+		t.Errorf("expected an expired exemption ignored, got %+v", s)
+	}
+
+	l.Check(ctx, "launch", 90)
+	s, _ := l.Check(ctx, "launch", 40)
+	if !s.Allowed || !s.Burst || s.BurstCredit != 10 || s.Remaining != 10 {
+		t.Fatalf("expected the request allowed on credit with 10 left, got %+v", s)
+	}
+	h := http.Header{}
+	s.SetHeaders(h)
+	if h.Get("x-gw-burst-credit") != "10" {
+		t.Errorf("expected the burst credit header, got %v", h)
+	}
+	if s, _ = l.Check(ctx, "launch", 40); s.Allowed {
+		t.Errorf("expected a refusal once the pool is spent, got %+v", s)
+	}
+	if balance, ok, _ := l.BurstBalance(ctx, "launch"); !ok || balance != 10 {
+		t.Errorf("expected a balance of 10, got %d, %v", balance, ok)
+	}
+
+	before := loads
+	l.Check(ctx, "vip", 1)
+	if loads != before {
+		t.Error("expected the override cached")
+	}
+	l.Invalidate("vip")
+	l.Check(ctx, "vip", 1)
+	if loads != before+1 {
+		t.Error("expected an invalidated override loaded again")
+	}
+}
+
+func TestOverrideValidate(t *testing.T) {
+	for _, o := range []Override{{}, {BurstTokens: -5, Exempt: true}, {Exempt: true, BurstRefillPerMinute: 10}} {
+		if o.Validate() == nil {
+			t.Errorf("expected %+v rejected", o)
+		}
+	}
+	if err := (Override{BurstTokens: 1000, BurstRefillPerMinute: 10}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
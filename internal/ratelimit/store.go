@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store holds the token counters of rate limit windows.
+type Store interface {
+	// Add adds tokens to key's counter unless that would take it past
+	// limit, reporting whether it did and the counter afterwards. A counter
+	// is dropped ttl after it was created.
+	Add(ctx context.Context, key string, tokens, limit int, ttl time.Duration) (allowed bool, used int, err error)
+}
+
+// RedisStore shares counters between replicas through Redis.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(redisURL string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStore{client: redis.NewClient(opts)}, nil
+}
+
+func (s *RedisStore) Add(ctx context.Context, key string, tokens, limit int, ttl time.Duration) (bool, int, error) {
+	res, err := IncrementAndCheckLua.Run(ctx, s.client, []string{key}, tokens, limit, int(ttl/time.Second)).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return res[0] == 1, int(res[1]), nil
+}
+
+func (s *RedisStore) Spend(ctx context.Context, key string, tokens, capacity int, refill float64, ttl time.Duration) (bool, int, error) {
+	res, err := SpendCreditLua.Run(ctx, s.client, []string{key}, tokens, capacity, refill, time.Now().UnixMilli(), ttl.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return res[0] == 1, int(res[1]), nil
+}
+
+// MemoryStore keeps counters in this instance, so each replica enforces
+// the limit on its own traffic.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]memoryCounter
+	credits  map[string]memoryCredit
+	swept    time.Time
+}
+
+type memoryCounter struct {
+	used    int
+	expires time.Time
+}
+
+type memoryCredit struct {
+	balance float64
+	at      time.Time // of the last refill
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]memoryCounter), credits: make(map[string]memoryCredit)}
+}
+
+func (s *MemoryStore) Add(_ context.Context, key string, tokens, limit int, ttl time.Duration) (bool, int, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.swept) > time.Minute {
+		for k, c := range s.counters {
+			if now.After(c.expires) {
+				delete(s.counters, k)
+			}
+		}
+		for k, c := range s.credits {
+			if now.After(c.expires) {
+				delete(s.credits, k)
+			}
+		}
+		s.swept = now
+	}
+
+	c, ok := s.counters[key]
+	if !ok || now.After(c.expires) {
+		c = memoryCounter{expires: now.Add(ttl)}
+	}
+	if c.used+tokens > limit {
+		return false, c.used, nil
+	}
+	c.used += tokens
+	s.counters[key] = c
+	return true, c.used, nil
+}
+
+func (s *MemoryStore) Spend(_ context.Context, key string, tokens, capacity int, refill float64, ttl time.Duration) (bool, int, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.credits[key]
+	if !ok || now.After(c.expires) {
+		c = memoryCredit{balance: float64(capacity), at: now}
+	}
+	c.balance = min(float64(capacity), c.balance+now.Sub(c.at).Seconds()*refill)
+	c.at, c.expires = now, now.Add(ttl)
+	spent := c.balance >= float64(tokens)
+	if spent {
+		c.balance -= float64(tokens)
+	}
+	s.credits[key] = c
+	return spent, int(c.balance), nil
+}
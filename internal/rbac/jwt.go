@@ -0,0 +1,76 @@
+package rbac
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Claims are the JWT claims the gateway reads. Roles are granted across
+// all tenants, except tenant-owner, which is scoped to Tenant.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	ExpiresAt int64    `json:"exp"`
+	Roles     []string `json:"roles"`
+	Tenant    string   `json:"tenant"`
+}
+
+// Grants turns the claimed roles into grants, skipping unknown ones.
+func (c Claims) Grants() []Grant {
+	var out []Grant
+	for _, r := range c.Roles {
+		g := Grant{Role: Role(r)}
+		if g.Role == TenantOwner {
+			g.Tenant = c.Tenant
+		}
+		if g.Validate() == nil {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+var errInvalidToken = errors.New("invalid token")
+
+// ParseJWT verifies an HS256 token signed with secret and returns its
+// claims. Expired tokens are rejected.
+func ParseJWT(token string, secret []byte, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, errInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return Claims{}, errInvalidToken
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, mac.Sum(nil)) {
+		return Claims{}, errInvalidToken
+	}
+
+	var c Claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return Claims{}, errInvalidToken
+	}
+	if c.ExpiresAt != 0 && now.Unix() >= c.ExpiresAt {
+		return Claims{}, errors.New("token expired")
+	}
+	return c, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
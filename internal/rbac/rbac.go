@@ -0,0 +1,102 @@
+// Package rbac decides what a caller of the admin and usage APIs may do.
+// Callers hold role grants, optionally scoped to one tenant, and each
+// endpoint requires a permission.
+package rbac
+
+import "fmt"
+
+type Role string
+
+const (
+	Admin       Role = "admin"
+	Operator    Role = "operator"
+	Viewer      Role = "viewer"
+	TenantOwner Role = "tenant-owner" // always scoped to a tenant
+	// PayloadReader may decrypt captured payloads. No other role can, so
+	// reading prompts and completions always takes an explicit grant.
+	PayloadReader Role = "payload-reader"
+)
+
+type Permission string
+
+const (
+	UsageRead   Permission = "usage:read"   // usage reports
+	AdminRead   Permission = "admin:read"   // request stories, routes, provider health
+	RoutesWrite Permission = "routes:write" // route changes
+	TenantRead  Permission = "tenant:read"  // a tenant's word rules and keys
+	TenantWrite Permission = "tenant:write"
+	RolesManage Permission = "roles:manage" // role assignments
+	DataManage  Permission = "data:manage"  // retention runs and other deletion of stored data
+	CacheManage Permission = "cache:manage" // purging cached responses
+	// PayloadDecrypt reads captured prompts and completions in clear text.
+	PayloadDecrypt Permission = "payload:decrypt"
+)
+
+var rolePermissions = map[Role][]Permission{
+	Admin:         {UsageRead, AdminRead, RoutesWrite, TenantRead, TenantWrite, RolesManage, DataManage, CacheManage},
+	Operator:      {UsageRead, AdminRead, RoutesWrite, TenantRead, TenantWrite, CacheManage},
+	Viewer:        {UsageRead, AdminRead, TenantRead},
+	TenantOwner:   {UsageRead, TenantRead, TenantWrite},
+	PayloadReader: {PayloadDecrypt},
+}
+
+// Grant gives a role, across all tenants when Tenant is empty.
+type Grant struct {
+	Role   Role   `json:"role"`
+	Tenant string `json:"tenant,omitempty"`
+}
+
+func (g Grant) Validate() error {
+	if _, ok := rolePermissions[g.Role]; !ok {
+		return fmt.Errorf("unknown role %q", g.Role)
+	}
+	if g.Role == TenantOwner && g.Tenant == "" {
+		return fmt.Errorf("role %s needs a tenant", TenantOwner)
+	}
+	return nil
+}
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Subject string  `json:"subject"`
+	Grants  []Grant `json:"grants"`
+}
+
+// Can reports whether p holds perm for tenant. An empty tenant asks for perm
+// across all tenants, which only unscoped grants give.
+func (p Principal) Can(perm Permission, tenant string) bool {
+	for _, g := range p.Grants {
+		if g.Tenant != "" && g.Tenant != tenant {
+			continue
+		}
+		if g.Role == TenantOwner && g.Tenant == "" {
+			continue
+		}
+		for _, have := range rolePermissions[g.Role] {
+			if have == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CanGrant reports whether p may give g: it must manage roles for g's
+// tenant and hold every permission g's role gives there. Decrypting
+// payloads is the exception, as no role but payload-reader includes it.
+func (p Principal) CanGrant(g Grant) bool {
+	if !p.Can(RolesManage, g.Tenant) {
+		return false
+	}
+	for _, perm := range rolePermissions[g.Role] {
+		if perm != PayloadDecrypt && !p.Can(perm, g.Tenant) {
+			return false
+		}
+	}
+	return true
+}
+
+// KeySubject is the subject roles are assigned to for an API key.
+func KeySubject(id int64) string {
+	return fmt.Sprintf("key:%d", id)
+}
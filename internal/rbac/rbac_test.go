@@ -0,0 +1,106 @@
+package rbac
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestCanScopesTenantOwner(t *testing.T) {
+	owner := Principal{Grants: []Grant{{Role: TenantOwner, Tenant: "acme"}}}
+	if !owner.Can(TenantWrite, "acme") {
+		t.Error("expected a tenant owner to manage its tenant")
+	}
+	if owner.Can(TenantWrite, "globex") || owner.Can(UsageRead, "") {
+		t.Error("expected a tenant owner to be limited to its tenant")
+	}
+	if owner.Can(AdminRead, "acme") {
+		t.Error("expected a tenant owner not to read gateway-wide admin data")
+	}
+
+	viewer := Principal{Grants: []Grant{{Role: Viewer}}}
+	if !viewer.Can(UsageRead, "") || !viewer.Can(TenantRead, "acme") {
+		t.Error("expected a viewer to read across tenants")
+	}
+	if viewer.Can(TenantWrite, "acme") || viewer.Can(RoutesWrite, "") {
+		t.Error("expected a viewer to be read-only")
+	}
+
+	admin := Principal{Grants: []Grant{{Role: Admin}}}
+	if admin.Can(PayloadDecrypt, "acme") {
+		t.Error("expected decrypting payloads to need an explicit grant, even for admins")
+	}
+	reader := Principal{Grants: []Grant{{Role: PayloadReader, Tenant: "acme"}}}
+	if !reader.Can(PayloadDecrypt, "acme") || reader.Can(PayloadDecrypt, "globex") {
+		t.Error("expected a scoped payload reader to decrypt only its tenant")
+	}
+
+	operator := Principal{Grants: []Grant{{Role: Operator}}}
+	if !operator.Can(RoutesWrite, "") || operator.Can(RolesManage, "") {
+		t.Error("expected an operator to manage routes but not roles")
+	}
+}
+
+func TestCanGrant(t *testing.T) {
+	admin := Principal{Grants: []Grant{{Role: Admin}}}
+	if !admin.CanGrant(Grant{Role: Admin}) || !admin.CanGrant(Grant{Role: PayloadReader, Tenant: "acme"}) {
+		t.Error("expected an admin to grant any role")
+	}
+	scoped := Principal{Grants: []Grant{{Role: Admin, Tenant: "acme"}}}
+	if !scoped.CanGrant(Grant{Role: Viewer, Tenant: "acme"}) {
+		t.Error("expected a scoped admin to grant within its tenant")
+	}
+	if scoped.CanGrant(Grant{Role: Admin}) || scoped.CanGrant(Grant{Role: Viewer, Tenant: "globex"}) {
+		t.Error("expected a scoped admin not to grant beyond its tenant")
+	}
+	operator := Principal{Grants: []Grant{{Role: Operator}, {Role: Viewer}}}
+	if operator.CanGrant(Grant{Role: Viewer}) {
+		t.Error("expected granting to need roles:manage")
+	}
+}
+
+func TestGrantValidate(t *testing.T) {
+	if err := (Grant{Role: TenantOwner}).Validate(); err == nil {
+		t.Error("expected tenant-owner without a tenant to be rejected")
+	}
+	if err := (Grant{Role: "root"}).Validate(); err == nil {
+		t.Error("expected an unknown role to be rejected")
+	}
+	if err := (Grant{Role: Viewer, Tenant: "acme"}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// signJWT builds an HS256 token for tests.
+func signJWT(t *testing.T, payload string, secret []byte) string {
+	t.Helper()
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString([]byte(payload))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseJWT(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Unix(1_700_000_000, 0)
+	token := signJWT(t, `{"sub":"alice","exp":1700000600,"roles":["tenant-owner","bogus"],"tenant":"acme"}`, secret)
+
+	c, err := ParseJWT(token, secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants := c.Grants()
+	if c.Subject != "alice" || len(grants) != 1 || grants[0] != (Grant{Role: TenantOwner, Tenant: "acme"}) {
+		t.Errorf("unexpected claims %+v, grants %+v", c, grants)
+	}
+
+	if _, err := ParseJWT(token, []byte("other"), now); err == nil {
+		t.Error("expected a bad signature to be rejected")
+	}
+	if _, err := ParseJWT(token, secret, now.Add(time.Hour)); err == nil {
+		t.Error("expected an expired token to be rejected")
+	}
+}
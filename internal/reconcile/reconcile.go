@@ -0,0 +1,167 @@
+// Package reconcile compares what providers actually billed with the
+// gateway's own cost estimates and flags the days they disagree.
+package reconcile
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+const reconcileJob = "reconciliation"
+
+// Store provides the gateway's estimates and keeps the results.
+type Store interface {
+	DailyCosts(ctx context.Context, providers []string, from, to time.Time) (map[string]float64, error)
+	SaveReconciliation(ctx context.Context, rows []usage.Reconciliation) error
+	ClaimReport(ctx context.Context, name, period string) (bool, error)
+}
+
+// Account is one provider account to reconcile: its billing source and the
+// gateway provider names whose traffic it is billed for.
+type Account struct {
+	Name      string
+	Source    Source
+	Providers []string
+}
+
+// Worker reconciles the accounts over the last lookback days.
+type Worker struct {
+	store     Store
+	accounts  []Account
+	lookback  int
+	threshold float64
+	now       func() time.Time
+}
+
+// New returns a worker flagging days where the two totals differ by more
+// than threshold (a fraction of the larger one).
+func New(store Store, accounts []Account, lookbackDays int, threshold float64) *Worker {
+	if lookbackDays <= 0 {
+		lookbackDays = 7
+	}
+	return &Worker{store: store, accounts: accounts, lookback: lookbackDays, threshold: threshold, now: time.Now}
+}
+
+// minFlagUSD keeps rounding noise on quiet days from being flagged.
+const minFlagUSD = 0.01
+
+// Compare lines up provider and gateway totals per day.
+func Compare(account string, billed, estimated map[string]float64, threshold float64, checkedAt time.Time) []usage.Reconciliation {
+	days := make(map[string]bool)
+	for d := range billed {
+		days[d] = true
+	}
+	for d := range estimated {
+		days[d] = true
+	}
+	var rows []usage.Reconciliation
+	for d := range days {
+		r := usage.Reconciliation{
+			Provider: account, Day: d, ProviderUSD: billed[d], GatewayUSD: estimated[d], CheckedAt: checkedAt,
+		}
+		r.DiffUSD = r.ProviderUSD - r.GatewayUSD
+		if larger := math.Max(r.ProviderUSD, r.GatewayUSD); larger > 0 {
+			r.DiffRatio = math.Abs(r.DiffUSD) / larger
+		}
+		r.Flagged = math.Abs(r.DiffUSD) >= minFlagUSD && r.DiffRatio > threshold
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Day < rows[j].Day })
+	return rows
+}
+
+// Run reconciles every account once. The current day is left out because
+// providers report it late.
+func (w *Worker) Run(ctx context.Context) ([]usage.Reconciliation, error) {
+	now := w.now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, -w.lookback)
+
+	var all []usage.Reconciliation
+	for _, a := range w.accounts {
+		billed, err := a.Source.DailyCosts(ctx, from, to)
+		if err != nil {
+			log.Printf("Warning: reconciliation of %s: %v", a.Name, err)
+			continue
+		}
+		estimated, err := w.store.DailyCosts(ctx, a.Providers, from, to)
+		if err != nil {
+			return all, err
+		}
+		rows := Compare(a.Name, billed, estimated, w.threshold, now)
+		if err := w.store.SaveReconciliation(ctx, rows); err != nil {
+			return all, err
+		}
+		for _, r := range rows {
+			if r.Flagged {
+				log.Printf("Warning: %s billed $%.2f on %s, gateway estimated $%.2f (%.0f%% apart)",
+					r.Provider, r.ProviderUSD, r.Day, r.GatewayUSD, r.DiffRatio*100)
+			}
+		}
+		all = append(all, rows...)
+	}
+	return all, nil
+}
+
+// Start reconciles once a day across all instances until ctx is cancelled.
+func (w *Worker) Start(ctx context.Context) {
+	if len(w.accounts) == 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		day := w.now().UTC().Format(time.DateOnly)
+		if claimed, err := w.store.ClaimReport(ctx, reconcileJob, day); err != nil {
+			log.Printf("Warning: reconciliation claim failed: %v", err)
+		} else if claimed {
+			if _, err := w.Run(ctx); err != nil {
+				log.Printf("Warning: reconciliation failed: %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Accounts returns the accounts with an admin key configured. Each covers
+// every provider of its type, so traffic of all OpenAI providers is
+// compared with the one OpenAI organization's bill.
+func Accounts(cfg config.Reconcile, providers map[string]config.ProviderOptions) []Account {
+	byType := func(typ string) (names []string, opts config.ProviderOptions) {
+		for name, p := range providers {
+			if p.Type == typ {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return names, providers[typ]
+	}
+	var accounts []Account
+	if cfg.OpenAIAdminKey != "" {
+		names, opts := byType("openai")
+		accounts = append(accounts, Account{
+			Name:      "openai",
+			Source:    OpenAISource{BaseURL: opts.BaseURL, AdminKey: cfg.OpenAIAdminKey},
+			Providers: names,
+		})
+	}
+	if cfg.AnthropicAdminKey != "" {
+		names, opts := byType("anthropic")
+		accounts = append(accounts, Account{
+			Name:      "anthropic",
+			Source:    AnthropicSource{BaseURL: opts.BaseURL, AdminKey: cfg.AnthropicAdminKey, Version: opts.APIVersion},
+			Providers: names,
+		})
+	}
+	return accounts
+}
@@ -0,0 +1,146 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+func TestOpenAISourceSumsBucketsAcrossPages(t *testing.T) {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/organization/costs" || r.Header.Get("Authorization") != "Bearer admin" {
+			t.Errorf("unexpected request %s with auth %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		if r.URL.Query().Get("page") == "" {
+			w.Write([]byte(`{"data":[{"start_time":1772409600,"results":[{"amount":{"value":1.25}},{"amount":{"value":0.75}}]}],"has_more":true,"next_page":"p2"}`))
+			return
+		}
+		w.Write([]byte(`{"data":[{"start_time":1772496000,"results":[{"amount":{"value":3}}]}],"has_more":false}`))
+	}))
+	defer srv.Close()
+
+	costs, err := OpenAISource{BaseURL: srv.URL, AdminKey: "admin"}.DailyCosts(context.Background(), day.AddDate(0, 0, -1), day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("DailyCosts: %v", err)
+	}
+	if costs["2026-03-02"] != 2 || costs["2026-03-03"] != 3 {
+		t.Errorf("unexpected costs %v", costs)
+	}
+}
+
+func TestAnthropicSourceConvertsCents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/organizations/cost_report" || r.Header.Get("x-api-key") != "admin" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"data":[{"starting_at":"2026-03-02T00:00:00Z","results":[{"amount":"123.5"},{"amount":"76.5"}]}],"has_more":false}`))
+	}))
+	defer srv.Close()
+
+	src := AnthropicSource{BaseURL: srv.URL, AdminKey: "admin", Version: "2023-06-01"}
+	costs, err := src.DailyCosts(context.Background(), time.Now().AddDate(0, 0, -7), time.Now())
+	if err != nil {
+		t.Fatalf("DailyCosts: %v", err)
+	}
+	if costs["2026-03-02"] != 2 {
+		t.Errorf("expected $2.00, got %v", costs)
+	}
+}
+
+func TestSourceReportsErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	if _, err := (OpenAISource{BaseURL: srv.URL}).DailyCosts(context.Background(), time.Now(), time.Now()); err == nil {
+		t.Error("expected an error for a 403")
+	}
+}
+
+func TestCompareFlagsDaysBeyondThreshold(t *testing.T) {
+	billed := map[string]float64{"2026-03-01": 10, "2026-03-02": 10, "2026-03-03": 0.004}
+	estimated := map[string]float64{"2026-03-01": 9.8, "2026-03-02": 8, "2026-03-04": 1}
+	rows := Compare("openai", billed, estimated, 0.05, time.Now())
+
+	flagged := map[string]bool{}
+	for _, r := range rows {
+		flagged[r.Day] = r.Flagged
+	}
+	want := map[string]bool{
+		"2026-03-01": false, // 2% apart
+		"2026-03-02": true,  // 20% apart
+		"2026-03-03": false, // under a cent
+		"2026-03-04": true,  // gateway recorded traffic the provider did not bill
+	}
+	for day, f := range want {
+		if flagged[day] != f {
+			t.Errorf("%s: flagged = %v, want %v", day, flagged[day], f)
+		}
+	}
+	if len(rows) != 4 || rows[0].Day != "2026-03-01" {
+		t.Fatalf("expected 4 rows sorted by day, got %+v", rows)
+	}
+	if math.Abs(rows[1].DiffUSD-2) > 1e-9 || math.Abs(rows[1].DiffRatio-0.2) > 1e-9 {
+		t.Errorf("unexpected diff %+v", rows[1])
+	}
+}
+
+type fakeSource struct {
+	costs map[string]float64
+	err   error
+}
+
+func (f fakeSource) DailyCosts(ctx context.Context, from, to time.Time) (map[string]float64, error) {
+	return f.costs, f.err
+}
+
+type fakeStore struct {
+	from, to  time.Time
+	providers []string
+	saved     []usage.Reconciliation
+}
+
+func (f *fakeStore) DailyCosts(ctx context.Context, providers []string, from, to time.Time) (map[string]float64, error) {
+	f.providers, f.from, f.to = providers, from, to
+	return map[string]float64{"2026-03-01": 5}, nil
+}
+
+func (f *fakeStore) SaveReconciliation(ctx context.Context, rows []usage.Reconciliation) error {
+	f.saved = append(f.saved, rows...)
+	return nil
+}
+
+func (f *fakeStore) ClaimReport(ctx context.Context, name, period string) (bool, error) {
+	return true, nil
+}
+
+func TestRunSkipsFailingSourcesAndStopsAtToday(t *testing.T) {
+	store := &fakeStore{}
+	w := New(store, []Account{
+		{Name: "anthropic", Source: fakeSource{err: errors.New("unauthorized")}, Providers: []string{"anthropic"}},
+		{Name: "openai", Source: fakeSource{costs: map[string]float64{"2026-03-01": 8}}, Providers: []string{"openai", "openai-eu"}},
+	}, 7, 0.05)
+	w.now = func() time.Time { return time.Date(2026, 3, 8, 15, 0, 0, 0, time.UTC) }
+
+	rows, err := w.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Provider != "openai" || !rows[0].Flagged || len(store.saved) != 1 {
+		t.Fatalf("unexpected rows %+v", rows)
+	}
+	if len(store.providers) != 2 {
+		t.Errorf("expected both openai providers to be summed, got %v", store.providers)
+	}
+	if !store.to.Equal(time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)) || !store.from.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected window %v - %v", store.from, store.to)
+	}
+}
@@ -0,0 +1,146 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Source reports what a provider billed per UTC day ("YYYY-MM-DD"), in USD.
+type Source interface {
+	DailyCosts(ctx context.Context, from, to time.Time) (map[string]float64, error)
+}
+
+// OpenAISource reads the organization costs API, which needs an admin key.
+type OpenAISource struct {
+	BaseURL  string // e.g. https://api.openai.com/v1
+	AdminKey string
+	Client   *http.Client
+}
+
+func (s OpenAISource) DailyCosts(ctx context.Context, from, to time.Time) (map[string]float64, error) {
+	costs := make(map[string]float64)
+	page := ""
+	for {
+		q := url.Values{
+			"start_time":   {strconv.FormatInt(from.Unix(), 10)},
+			"end_time":     {strconv.FormatInt(to.Unix(), 10)},
+			"bucket_width": {"1d"},
+			"limit":        {"31"},
+		}
+		if page != "" {
+			q.Set("page", page)
+		}
+		var out struct {
+			Data []struct {
+				StartTime int64 `json:"start_time"`
+				Results   []struct {
+					Amount struct {
+						Value float64 `json:"value"`
+					} `json:"amount"`
+				} `json:"results"`
+			} `json:"data"`
+			HasMore  bool   `json:"has_more"`
+			NextPage string `json:"next_page"`
+		}
+		err := getJSON(ctx, s.Client, s.BaseURL+"/organization/costs?"+q.Encode(), map[string]string{
+			"Authorization": "Bearer " + s.AdminKey,
+		}, &out)
+		if err != nil {
+			return nil, err
+		}
+		for _, bucket := range out.Data {
+			day := time.Unix(bucket.StartTime, 0).UTC().Format(time.DateOnly)
+			for _, r := range bucket.Results {
+				costs[day] += r.Amount.Value
+			}
+		}
+		if !out.HasMore || out.NextPage == "" {
+			return costs, nil
+		}
+		page = out.NextPage
+	}
+}
+
+// AnthropicSource reads the Admin API cost report, which needs an admin
+// key. Amounts come in cents.
+type AnthropicSource struct {
+	BaseURL  string // e.g. https://api.anthropic.com/v1
+	AdminKey string
+	Version  string
+	Client   *http.Client
+}
+
+func (s AnthropicSource) DailyCosts(ctx context.Context, from, to time.Time) (map[string]float64, error) {
+	costs := make(map[string]float64)
+	page := ""
+	for {
+		q := url.Values{
+			"starting_at":  {from.UTC().Format(time.RFC3339)},
+			"ending_at":    {to.UTC().Format(time.RFC3339)},
+			"bucket_width": {"1d"},
+		}
+		if page != "" {
+			q.Set("page", page)
+		}
+		var out struct {
+			Data []struct {
+				StartingAt time.Time `json:"starting_at"`
+				Results    []struct {
+					Amount string `json:"amount"`
+				} `json:"results"`
+			} `json:"data"`
+			HasMore  bool   `json:"has_more"`
+			NextPage string `json:"next_page"`
+		}
+		err := getJSON(ctx, s.Client, s.BaseURL+"/organizations/cost_report?"+q.Encode(), map[string]string{
+			"x-api-key":         s.AdminKey,
+			"anthropic-version": s.Version,
+		}, &out)
+		if err != nil {
+			return nil, err
+		}
+		for _, bucket := range out.Data {
+			day := bucket.StartingAt.UTC().Format(time.DateOnly)
+			for _, r := range bucket.Results {
+				cents, err := strconv.ParseFloat(r.Amount, 64)
+				if err != nil {
+					return nil, fmt.Errorf("anthropic cost report: bad amount %q", r.Amount)
+				}
+				costs[day] += cents / 100
+			}
+		}
+		if !out.HasMore || out.NextPage == "" {
+			return costs, nil
+		}
+		page = out.NextPage
+	}
+}
+
+func getJSON(ctx context.Context, client *http.Client, u string, headers map[string]string, out interface{}) error {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("usage API returned status %d: %s", resp.StatusCode, string(msg))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
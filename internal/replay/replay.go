@@ -0,0 +1,232 @@
+package replay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+type Mode string
+
+const (
+	ModeOff    Mode = ""
+	ModeRecord Mode = "record"
+	ModeReplay Mode = "replay"
+)
+
+// ParseMode validates a mode string from configuration.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case ModeOff, ModeRecord, ModeReplay:
+		return Mode(s), nil
+	}
+	return ModeOff, fmt.Errorf("unknown replay mode %q (want record or replay)", s)
+}
+
+// cassette is the on-disk representation of one recorded exchange.
+type cassette struct {
+	Provider string                  `json:"provider"`
+	Request  providers.ChatRequest   `json:"request"`
+	Response *providers.ChatResponse `json:"response,omitempty"`
+	Chunks   []providers.ChatChunk   `json:"chunks,omitempty"`
+}
+
+// Provider wraps another provider and either records its responses to disk or
+// serves previously recorded responses without calling it.
+type Provider struct {
+	name  string
+	inner providers.Provider
+	mode  Mode
+	dir   string
+}
+
+func Wrap(name string, inner providers.Provider, mode Mode, dir string) *Provider {
+	return &Provider{name: name, inner: inner, mode: mode, dir: dir}
+}
+
+// WrapRegistry wraps every provider in the registry. It is a no-op when mode
+// is off.
+func WrapRegistry(reg providers.Registry, mode Mode, dir string) error {
+	if mode == ModeOff {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create replay dir: %w", err)
+	}
+	for name, p := range reg {
+		reg[name] = Wrap(name, p, mode, dir)
+	}
+	return nil
+}
+
+// traceHeaders carry per-request trace context, which would otherwise give
+// every request its own recording.
+var traceHeaders = map[string]bool{"traceparent": true, "tracestate": true, "baggage": true}
+
+// Key hashes the provider name and the full request, so any change to model,
+// messages or sampling parameters produces a distinct recording. The fields
+// left out of the request's JSON (thinking, extra body params, outbound
+// headers other than trace context, and the billing account) are hashed
+// after it when set, so recordings of requests without them keep their key.
+func Key(provider string, req providers.ChatRequest) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	headers := make(map[string]string, len(req.Headers))
+	for name, value := range req.Headers {
+		if !traceHeaders[strings.ToLower(name)] {
+			headers[strings.ToLower(name)] = value
+		}
+	}
+	h := sha256.New()
+	h.Write([]byte(provider))
+	h.Write(data)
+	for _, field := range []struct {
+		name  string
+		value interface{}
+		set   bool
+	}{
+		{"thinking", req.Thinking, req.Thinking != nil},
+		{"extra", req.Extra, len(req.Extra) > 0},
+		{"headers", headers, len(headers) > 0},
+		{"account", req.Account, req.Account != ""},
+	} {
+		if !field.set {
+			continue
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+		h.Write([]byte(field.name))
+		h.Write(value)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (p *Provider) path(key string) string {
+	return filepath.Join(p.dir, key+".json")
+}
+
+func (p *Provider) load(req providers.ChatRequest) (*cassette, error) {
+	key, err := Key(p.name, req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("replay: no recording for %s request %s", p.name, key[:12])
+		}
+		return nil, err
+	}
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("replay: corrupt recording %s: %w", key[:12], err)
+	}
+	return &c, nil
+}
+
+func (p *Provider) save(req providers.ChatRequest, c cassette) error {
+	key, err := Key(p.name, req)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write-then-rename so a concurrent replay never reads a partial file.
+	tmp := p.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.path(key))
+}
+
+func (p *Provider) Chat(req providers.ChatRequest) (*providers.ChatResponse, error) {
+	if p.mode == ModeReplay {
+		c, err := p.load(req)
+		if err != nil {
+			return nil, err
+		}
+		if c.Response == nil {
+			return nil, fmt.Errorf("replay: recording is a stream, not a completion")
+		}
+		return c.Response, nil
+	}
+
+	resp, err := p.inner.Chat(req)
+	if err != nil || p.mode != ModeRecord {
+		return resp, err
+	}
+	if saveErr := p.save(req, cassette{Provider: p.name, Request: req, Response: resp}); saveErr != nil {
+		fmt.Fprintf(os.Stderr, "replay: failed to record response: %v\n", saveErr)
+	}
+	return resp, nil
+}
+
+func (p *Provider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatChunk, <-chan error) {
+	// Keep stream and non-stream recordings of the same prompt apart.
+	req.Stream = true
+
+	if p.mode == ModeReplay {
+		chunkCh := make(chan providers.ChatChunk)
+		errCh := make(chan error, 1)
+		c, err := p.load(req)
+		if err != nil {
+			errCh <- err
+			return chunkCh, errCh
+		}
+		go func() {
+			defer close(chunkCh)
+			defer close(errCh)
+			for _, chunk := range c.Chunks {
+				chunkCh <- chunk
+			}
+		}()
+		return chunkCh, errCh
+	}
+
+	innerChunks, innerErrs := p.inner.ChatStream(req)
+	if p.mode != ModeRecord {
+		return innerChunks, innerErrs
+	}
+
+	chunkCh := make(chan providers.ChatChunk)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(chunkCh)
+		defer close(errCh)
+		var recorded []providers.ChatChunk
+		for {
+			select {
+			case chunk, ok := <-innerChunks:
+				if !ok {
+					// Only complete streams are recorded.
+					if err := p.save(req, cassette{Provider: p.name, Request: req, Chunks: recorded}); err != nil {
+						fmt.Fprintf(os.Stderr, "replay: failed to record stream: %v\n", err)
+					}
+					return
+				}
+				recorded = append(recorded, chunk)
+				chunkCh <- chunk
+			case err, ok := <-innerErrs:
+				if ok && err != nil {
+					errCh <- err
+					return
+				}
+				innerErrs = nil
+			}
+		}
+	}()
+	return chunkCh, errCh
+}
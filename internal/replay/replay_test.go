@@ -0,0 +1,102 @@
+package replay
+
+import (
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+type countingProvider struct {
+	calls int
+}
+
+func (c *countingProvider) Chat(req providers.ChatRequest) (*providers.ChatResponse, error) {
+	c.calls++
+	return &providers.ChatResponse{ID: "live", Model: req.Model}, nil
+}
+
+func (c *countingProvider) ChatStream(req providers.ChatRequest) (<-chan providers.ChatChunk, <-chan error) {
+	c.calls++
+	chunkCh := make(chan providers.ChatChunk)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(chunkCh)
+		defer close(errCh)
+		chunkCh <- providers.ChatChunk{ID: "live-1"}
+		chunkCh <- providers.ChatChunk{ID: "live-2"}
+	}()
+	return chunkCh, errCh
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	live := &countingProvider{}
+	req := providers.ChatRequest{
+		Model:    "gpt-4o-mini",
+		Messages: []providers.Message{{Role: "user", Content: "hello"}},
+	}
+
+	recorder := Wrap("openai", live, ModeRecord, dir)
+	if _, err := recorder.Chat(req); err != nil {
+		t.Fatalf("record Chat failed: %v", err)
+	}
+	chunks, _ := recorder.ChatStream(req)
+	for range chunks {
+	}
+
+	player := Wrap("openai", live, ModeReplay, dir)
+	resp, err := player.Chat(req)
+	if err != nil {
+		t.Fatalf("replay Chat failed: %v", err)
+	}
+	if resp.ID != "live" {
+		t.Errorf("expected recorded ID 'live', got '%s'", resp.ID)
+	}
+
+	chunks, _ = player.ChatStream(req)
+	var ids []string
+	for c := range chunks {
+		ids = append(ids, c.ID)
+	}
+	if len(ids) != 2 || ids[0] != "live-1" {
+		t.Errorf("expected recorded chunks, got %v", ids)
+	}
+
+	if live.calls != 2 {
+		t.Errorf("expected replay to skip the live provider, got %d live calls", live.calls)
+	}
+
+	other := req
+	other.Messages = []providers.Message{{Role: "user", Content: "different"}}
+	if _, err := player.Chat(other); err == nil {
+		t.Error("expected error for unrecorded request")
+	}
+}
+
+func TestKeyUnmarshaledFields(t *testing.T) {
+	req := providers.ChatRequest{Model: "m", Messages: []providers.Message{{Role: "user", Content: "hi"}}}
+	base, err := Key("openai", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	variants := map[string]func(*providers.ChatRequest){
+		"thinking": func(r *providers.ChatRequest) { r.Thinking = &providers.Thinking{BudgetTokens: 1024} },
+		"extra":    func(r *providers.ChatRequest) { r.Extra = map[string]interface{}{"top_k": 5} },
+		"headers":  func(r *providers.ChatRequest) { r.Headers = map[string]string{"OpenAI-Beta": "assistants=v2"} },
+		"account":  func(r *providers.ChatRequest) { r.Account = "eu" },
+	}
+	for name, change := range variants {
+		changed := req
+		change(&changed)
+		if key, _ := Key("openai", changed); key == base {
+			t.Errorf("expected %s to change the key", name)
+		}
+	}
+
+	traced := req
+	traced.Headers = map[string]string{"traceparent": "00-abc-def-01"}
+	if key, _ := Key("openai", traced); key != base {
+		t.Error("expected trace context to leave the key unchanged")
+	}
+}
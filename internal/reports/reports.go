@@ -0,0 +1,142 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+const chargebackReport = "chargeback"
+
+// Source provides the data a report is built from.
+type Source interface {
+	Chargeback(ctx context.Context, from, to time.Time, costCenterKey string) ([]usage.ChargebackRow, error)
+	ClaimReport(ctx context.Context, name, period string) (bool, error)
+	ReleaseReport(ctx context.Context, name, period string) error
+}
+
+// Generator builds monthly chargeback reports and delivers them to a sink.
+type Generator struct {
+	source        Source
+	sink          Sink
+	format        string
+	costCenterKey string
+}
+
+func NewGenerator(source Source, sink Sink, format, costCenterKey string) *Generator {
+	if format != "json" {
+		format = "csv"
+	}
+	return &Generator{source: source, sink: sink, format: format, costCenterKey: costCenterKey}
+}
+
+// MonthBounds returns [start, end) of the month named "YYYY-MM" in UTC.
+func MonthBounds(period string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01", period)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("period must be YYYY-MM: %w", err)
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// PreviousMonth returns the "YYYY-MM" period before the month containing now.
+func PreviousMonth(now time.Time) string {
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return first.AddDate(0, -1, 0).Format("2006-01")
+}
+
+// Build renders the chargeback report for period and returns the file name,
+// content type and body.
+func (g *Generator) Build(ctx context.Context, period, format string) (string, string, []byte, error) {
+	from, to, err := MonthBounds(period)
+	if err != nil {
+		return "", "", nil, err
+	}
+	rows, err := g.source.Chargeback(ctx, from, to, g.costCenterKey)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if format == "" {
+		format = g.format
+	}
+
+	name := fmt.Sprintf("chargeback-%s.%s", period, format)
+	if format == "json" {
+		if rows == nil {
+			rows = []usage.ChargebackRow{}
+		}
+		body, err := json.MarshalIndent(map[string]interface{}{
+			"period":          period,
+			"cost_center_key": g.costCenterKey,
+			"rows":            rows,
+		}, "", "  ")
+		return name, "application/json", body, err
+	}
+	body, err := RenderCSV(rows)
+	return name, "text/csv", body, err
+}
+
+// RenderCSV writes chargeback rows as CSV with a header line.
+func RenderCSV(rows []usage.ChargebackRow) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"tenant", "cost_center", "requests", "prompt_tokens", "completion_tokens", "total_tokens", "cost_estimate_usd"})
+	for _, r := range rows {
+		w.Write([]string{
+			r.Tenant,
+			r.CostCenter,
+			strconv.FormatInt(r.Requests, 10),
+			strconv.FormatInt(r.PromptTokens, 10),
+			strconv.FormatInt(r.CompletionTokens, 10),
+			strconv.FormatInt(r.TotalTokens, 10),
+			strconv.FormatFloat(r.CostEstimate, 'f', 6, 64),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// Run generates and delivers the report for period unless another run has
+// already claimed it.
+func (g *Generator) Run(ctx context.Context, period string) error {
+	claimed, err := g.source.ClaimReport(ctx, chargebackReport, period)
+	if err != nil || !claimed {
+		return err
+	}
+
+	name, contentType, body, err := g.Build(ctx, period, "")
+	if err == nil {
+		err = g.sink.Put(ctx, name, contentType, body)
+	}
+	if err != nil {
+		// Let the next tick retry.
+		g.source.ReleaseReport(ctx, chargebackReport, period)
+		return err
+	}
+	log.Printf("Chargeback report %s delivered", name)
+	return nil
+}
+
+// Start checks hourly whether last month's report has been delivered and
+// generates it if not. It returns when ctx is cancelled.
+func (g *Generator) Start(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if err := g.Run(ctx, PreviousMonth(time.Now().UTC())); err != nil {
+			log.Printf("Warning: chargeback report failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
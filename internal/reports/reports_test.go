@@ -0,0 +1,69 @@
+package reports
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+func TestPreviousMonth(t *testing.T) {
+	if got := PreviousMonth(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)); got != "2023-12" {
+		t.Errorf("expected 2023-12, got %s", got)
+	}
+	from, to, err := MonthBounds("2024-02")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !to.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || !from.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected bounds %s - %s", from, to)
+	}
+}
+
+func TestRenderCSV(t *testing.T) {
+	body, err := RenderCSV([]usage.ChargebackRow{
+		{Tenant: "acme", CostCenter: "eng", Requests: 3, PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30, CostEstimate: 0.0123},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and one row, got %d lines", len(lines))
+	}
+	if lines[1] != "acme,eng,3,10,20,30,0.012300" {
+		t.Errorf("unexpected row %q", lines[1])
+	}
+}
+
+func TestObjectSinkPutFile(t *testing.T) {
+	var got struct {
+		path, hash string
+		length     int64
+		body       string
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got.path, got.hash, got.length, got.body = r.URL.Path, r.Header.Get("X-Amz-Content-Sha256"), r.ContentLength, string(b)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "export.parquet")
+	if err := os.WriteFile(path, []byte("rows"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sink := ObjectSink{Endpoint: srv.URL, Region: "us-east-1", Bucket: "archive", Prefix: "usage/"}
+	if err := sink.PutFile(context.Background(), "requests/2024-01.parquet", "application/vnd.apache.parquet", path); err != nil {
+		t.Fatal(err)
+	}
+	if got.path != "/archive/usage/requests/2024-01.parquet" || got.body != "rows" || got.length != 4 || got.hash != sha256Hex([]byte("rows")) {
+		t.Errorf("unexpected upload %+v", got)
+	}
+}
@@ -0,0 +1,270 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+// Sink is where finished reports are delivered.
+type Sink interface {
+	Put(ctx context.Context, name, contentType string, body []byte) error
+	// PutFile delivers the file at path, for bodies too large to hold in
+	// memory.
+	PutFile(ctx context.Context, name, contentType, path string) error
+}
+
+// FileSink writes reports to a local directory.
+type FileSink struct {
+	Dir string
+}
+
+func (s FileSink) Put(ctx context.Context, name, contentType string, body []byte) error {
+	path := filepath.Join(s.Dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, body, 0o644)
+}
+
+func (s FileSink) PutFile(ctx context.Context, name, contentType, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst := filepath.Join(s.Dir, name)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WebhookSink POSTs reports to a URL, e.g. an email relay. The file name is
+// sent in the X-Report-Name header.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s WebhookSink) Put(ctx context.Context, name, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Report-Name", name)
+	return do(s.Client, req)
+}
+
+func (s WebhookSink) PutFile(ctx context.Context, name, contentType, path string) error {
+	f, size, err := openFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	req, err := http.NewRequestWithContext(ctx, "POST", s.URL, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Report-Name", name)
+	return do(s.Client, req)
+}
+
+// ObjectSink uploads reports to an S3-compatible bucket using AWS Signature
+// Version 4. Google Cloud Storage accepts the same requests at
+// https://storage.googleapis.com when given HMAC interoperability keys.
+type ObjectSink struct {
+	Endpoint        string // e.g. https://s3.us-east-1.amazonaws.com
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	Client          *http.Client
+	now             func() time.Time
+}
+
+func (s ObjectSink) Put(ctx context.Context, name, contentType string, body []byte) error {
+	return s.put(ctx, name, contentType, bytes.NewReader(body), int64(len(body)), sha256Hex(body))
+}
+
+// PutFile uploads the file at path in one request, reading it once to
+// sign it and again to send it.
+func (s ObjectSink) PutFile(ctx context.Context, name, contentType, path string) error {
+	f, size, err := openFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return s.put(ctx, name, contentType, f, size, hex.EncodeToString(h.Sum(nil)))
+}
+
+func (s ObjectSink) put(ctx context.Context, name, contentType string, body io.Reader, size int64, payloadHash string) error {
+	key := strings.TrimPrefix(s.Prefix+name, "/")
+	u, err := url.Parse(strings.TrimRight(s.Endpoint, "/") + "/" + s.Bucket + "/" + key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "PUT", u.String(), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	signV4(req, payloadHash, s.Region, "s3", s.AccessKeyID, s.SecretAccessKey, now().UTC())
+	return do(s.Client, req)
+}
+
+// openFile opens the file at path and returns its size.
+func openFile(path string) (*os.File, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+func do(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("report upload failed (status %d): %s", resp.StatusCode, string(msg))
+	}
+	return nil
+}
+
+// signV4 adds AWS Signature Version 4 headers to req, whose body has the
+// SHA-256 payloadHash.
+func signV4(req *http.Request, payloadHash, region, service, accessKey, secretKey string, t time.Time) {
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "content-type:" + req.Header.Get("Content-Type") + "\n" +
+		"host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// NewSink builds the sink described by cfg. It returns nil when scheduled
+// reports are disabled.
+func NewSink(cfg config.ReportConfig) (Sink, error) {
+	switch cfg.Sink {
+	case "":
+		return nil, nil
+	case "file":
+		return FileSink{Dir: cfg.Dir}, nil
+	case "webhook":
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("REPORT_WEBHOOK_URL is required for the webhook sink")
+		}
+		return WebhookSink{URL: cfg.WebhookURL}, nil
+	case "s3", "gcs":
+		if cfg.Bucket == "" {
+			return nil, fmt.Errorf("REPORT_BUCKET is required for the %s sink", cfg.Sink)
+		}
+		endpoint, region := cfg.Endpoint, cfg.Region
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+			if cfg.Sink == "gcs" {
+				endpoint = "https://storage.googleapis.com"
+			}
+		}
+		if cfg.Sink == "gcs" {
+			region = "auto"
+		}
+		return ObjectSink{
+			Endpoint:        endpoint,
+			Region:          region,
+			Bucket:          cfg.Bucket,
+			Prefix:          cfg.Prefix,
+			AccessKeyID:     cfg.AccessKeyID,
+			SecretAccessKey: cfg.SecretAccessKey,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown report sink %q", cfg.Sink)
+	}
+}
@@ -0,0 +1,139 @@
+// Package retention runs the configured data retention policies: rows
+// older than their table's TTL are purged or anonymized, and every run is
+// recorded as a deletion report.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+const retentionJob = "retention"
+
+// Store applies retention to stored records and keeps the reports.
+type Store interface {
+	ApplyRetention(ctx context.Context, table, action string, cutoff time.Time, dryRun bool) (int64, error)
+	RecordRetentionRun(ctx context.Context, r usage.RetentionRun) error
+	ClaimReport(ctx context.Context, name, period string) (bool, error)
+}
+
+// Job applies the retention policies.
+type Job struct {
+	store    Store
+	policies []config.RetentionPolicy
+	dryRun   bool
+	interval time.Duration
+	now      func() time.Time
+}
+
+// New validates the policies and returns a job for them.
+func New(store Store, cfg config.Retention) (*Job, error) {
+	seen := map[string]bool{}
+	for _, p := range cfg.Policies {
+		if err := usage.CheckRetention(p.Table, p.Action); err != nil {
+			return nil, err
+		}
+		if p.TTLDays <= 0 {
+			return nil, fmt.Errorf("retention policy for %s: ttl_days must be positive", p.Table)
+		}
+		if seen[p.Table+"/"+p.Action] {
+			return nil, fmt.Errorf("retention policy for %s: duplicate %s", p.Table, p.Action)
+		}
+		seen[p.Table+"/"+p.Action] = true
+	}
+	interval := time.Duration(cfg.IntervalMin) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &Job{store: store, policies: cfg.Policies, dryRun: cfg.DryRun, interval: interval, now: time.Now}, nil
+}
+
+// Policies returns the configured policies; none for a nil job.
+func (j *Job) Policies() []config.RetentionPolicy {
+	if j == nil {
+		return nil
+	}
+	return j.policies
+}
+
+// Run applies every policy once and returns its reports. Anonymizing runs
+// before purging so a table with both policies is handled consistently. A
+// failing policy does not stop the others.
+func (j *Job) Run(ctx context.Context, dryRun bool) ([]usage.RetentionRun, error) {
+	now := j.now().UTC()
+	var runs []usage.RetentionRun
+	var firstErr error
+	for _, action := range []string{"anonymize", "purge"} {
+		for _, p := range j.policies {
+			if p.Action != action {
+				continue
+			}
+			run := usage.RetentionRun{
+				Table:  p.Table,
+				Action: p.Action,
+				Cutoff: now.AddDate(0, 0, -p.TTLDays),
+				DryRun: dryRun,
+				RanAt:  now,
+			}
+			n, err := j.store.ApplyRetention(ctx, run.Table, run.Action, run.Cutoff, dryRun)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("retention %s of %s: %w", run.Action, run.Table, err)
+				}
+				continue
+			}
+			run.RowsAffected = n
+			if err := j.store.RecordRetentionRun(ctx, run); err != nil {
+				log.Printf("Warning: failed to record retention report: %v", err)
+			}
+			runs = append(runs, run)
+		}
+	}
+	return runs, firstErr
+}
+
+// Start runs the policies every interval, once per interval across all
+// instances, until ctx is cancelled.
+func (j *Job) Start(ctx context.Context) {
+	if len(j.policies) == 0 {
+		return
+	}
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		j.tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *Job) tick(ctx context.Context) {
+	period := j.now().UTC().Truncate(j.interval).Format(time.RFC3339)
+	claimed, err := j.store.ClaimReport(ctx, retentionJob, period)
+	if err != nil {
+		log.Printf("Warning: retention claim failed: %v", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	runs, err := j.Run(ctx, j.dryRun)
+	for _, r := range runs {
+		verb := r.Action + "d"
+		if r.DryRun {
+			verb = "would be " + verb
+		}
+		log.Printf("Retention: %d %s rows older than %s %s", r.RowsAffected, r.Table, r.Cutoff.Format(time.DateOnly), verb)
+	}
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
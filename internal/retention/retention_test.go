@@ -0,0 +1,120 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+type fakeStore struct {
+	applied  []string
+	reports  []usage.RetentionRun
+	failing  string
+	claimed  map[string]bool
+	affected int64
+}
+
+func (f *fakeStore) ApplyRetention(ctx context.Context, table, action string, cutoff time.Time, dryRun bool) (int64, error) {
+	if table == f.failing {
+		return 0, errors.New("boom")
+	}
+	f.applied = append(f.applied, action+" "+table)
+	return f.affected, nil
+}
+
+func (f *fakeStore) RecordRetentionRun(ctx context.Context, r usage.RetentionRun) error {
+	f.reports = append(f.reports, r)
+	return nil
+}
+
+func (f *fakeStore) ClaimReport(ctx context.Context, name, period string) (bool, error) {
+	if f.claimed[period] {
+		return false, nil
+	}
+	f.claimed[period] = true
+	return true, nil
+}
+
+func TestNewRejectsInvalidPolicies(t *testing.T) {
+	for _, p := range []config.RetentionPolicy{
+		{Table: "users", TTLDays: 30, Action: "purge"},
+		{Table: "requests", TTLDays: 30, Action: "shred"},
+		{Table: "requests", TTLDays: 0, Action: "purge"},
+	} {
+		if _, err := New(&fakeStore{}, config.Retention{Policies: []config.RetentionPolicy{p}}); err == nil {
+			t.Errorf("expected policy %+v to be rejected", p)
+		}
+	}
+}
+
+func TestRunAnonymizesBeforePurgingAndReports(t *testing.T) {
+	store := &fakeStore{affected: 7}
+	job, err := New(store, config.Retention{Policies: []config.RetentionPolicy{
+		{Table: "requests", TTLDays: 365, Action: "purge"},
+		{Table: "requests", TTLDays: 30, Action: "anonymize"},
+		{Table: "request_events", TTLDays: 90, Action: "purge"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	job.now = func() time.Time { return now }
+
+	runs, err := job.Run(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"anonymize requests", "purge requests", "purge request_events"}
+	if len(store.applied) != len(want) {
+		t.Fatalf("expected %v, got %v", want, store.applied)
+	}
+	for i := range want {
+		if store.applied[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, store.applied)
+		}
+	}
+	if len(runs) != 3 || len(store.reports) != 3 {
+		t.Fatalf("expected 3 reports, got %d returned and %d recorded", len(runs), len(store.reports))
+	}
+	if r := runs[0]; !r.DryRun || r.RowsAffected != 7 || !r.Cutoff.Equal(now.AddDate(0, 0, -30)) {
+		t.Errorf("unexpected report %+v", r)
+	}
+}
+
+func TestRunContinuesPastFailures(t *testing.T) {
+	store := &fakeStore{failing: "requests"}
+	job, _ := New(store, config.Retention{Policies: []config.RetentionPolicy{
+		{Table: "requests", TTLDays: 30, Action: "purge"},
+		{Table: "request_events", TTLDays: 30, Action: "purge"},
+	}})
+	runs, err := job.Run(context.Background(), false)
+	if err == nil {
+		t.Error("expected the failing policy to be reported")
+	}
+	if len(runs) != 1 || runs[0].Table != "request_events" {
+		t.Errorf("expected the other policy to still run, got %+v", runs)
+	}
+}
+
+func TestTickRunsOncePerInterval(t *testing.T) {
+	store := &fakeStore{claimed: map[string]bool{}}
+	job, _ := New(store, config.Retention{Policies: []config.RetentionPolicy{{Table: "requests", TTLDays: 30, Action: "purge"}}})
+	now := time.Date(2024, 6, 1, 12, 10, 0, 0, time.UTC)
+	job.now = func() time.Time { return now }
+
+	job.tick(context.Background())
+	now = now.Add(20 * time.Minute) // same hour
+	job.tick(context.Background())
+	if len(store.applied) != 1 {
+		t.Errorf("expected one run within the hour, got %d", len(store.applied))
+	}
+	now = now.Add(time.Hour)
+	job.tick(context.Background())
+	if len(store.applied) != 2 {
+		t.Errorf("expected a second run in the next hour, got %d", len(store.applied))
+	}
+}
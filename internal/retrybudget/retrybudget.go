@@ -0,0 +1,132 @@
+// Package retrybudget caps retries at a share of recent requests, across
+// the gateway and per tenant, so that retrying cannot multiply the load on
+// a provider that is already failing.
+package retrybudget
+
+import (
+	"sync"
+	"time"
+)
+
+// buckets is how many slices a window is counted in; it slides one slice
+// at a time.
+const buckets = 10
+
+// Scopes name the budget a refused retry ran out of.
+const (
+	ScopeGlobal = "global"
+	ScopeTenant = "tenant"
+)
+
+type counter struct {
+	requests [buckets]int
+	retries  [buckets]int
+	slices   [buckets]int64
+}
+
+// at returns the bucket for slice, clearing it if it last held an older one.
+func (c *counter) at(slice int64) int {
+	i := int(slice % buckets)
+	if c.slices[i] != slice {
+		c.slices[i], c.requests[i], c.retries[i] = slice, 0, 0
+	}
+	return i
+}
+
+func (c *counter) totals(slice int64) (requests, retries int) {
+	for i := range c.slices {
+		if slice-c.slices[i] < buckets {
+			requests += c.requests[i]
+			retries += c.retries[i]
+		}
+	}
+	return requests, retries
+}
+
+// Budget allows retries while they stay under Ratio of the requests seen in
+// the last window, plus a floor of MinRetries so quiet tenants can still
+// retry. Counts are kept per instance. A nil Budget allows every retry.
+type Budget struct {
+	ratio      float64
+	minRetries int
+	slice      time.Duration
+
+	mu      sync.Mutex
+	global  counter
+	tenants map[string]*counter
+	swept   int64
+	now     func() time.Time
+}
+
+// New returns a budget, or nil (no limit) when ratio is not positive.
+func New(ratio float64, window time.Duration, minRetries int) *Budget {
+	if ratio <= 0 {
+		return nil
+	}
+	if window <= 0 {
+		window = time.Minute
+	}
+	return &Budget{
+		ratio:      ratio,
+		minRetries: minRetries,
+		slice:      window / buckets,
+		tenants:    make(map[string]*counter),
+		now:        time.Now,
+	}
+}
+
+func (b *Budget) tenant(name string, slice int64) *counter {
+	if slice-b.swept >= buckets {
+		for k, c := range b.tenants {
+			if n, r := c.totals(slice); n == 0 && r == 0 {
+				delete(b.tenants, k)
+			}
+		}
+		b.swept = slice
+	}
+	c, ok := b.tenants[name]
+	if !ok {
+		c = &counter{}
+		b.tenants[name] = c
+	}
+	return c
+}
+
+// Request counts a request by tenant toward the budgets.
+func (b *Budget) Request(tenant string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	slice := b.now().UnixNano() / int64(b.slice)
+	b.global.requests[b.global.at(slice)]++
+	t := b.tenant(tenant, slice)
+	t.requests[t.at(slice)]++
+}
+
+// Retry spends a retry for tenant if both budgets allow it. When they do
+// not, scope names the one exhausted.
+func (b *Budget) Retry(tenant string) (ok bool, scope string) {
+	if b == nil {
+		return true, ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	slice := b.now().UnixNano() / int64(b.slice)
+	t := b.tenant(tenant, slice)
+	if !b.allows(t, slice) {
+		return false, ScopeTenant
+	}
+	if !b.allows(&b.global, slice) {
+		return false, ScopeGlobal
+	}
+	b.global.retries[b.global.at(slice)]++
+	t.retries[t.at(slice)]++
+	return true, ""
+}
+
+func (b *Budget) allows(c *counter, slice int64) bool {
+	requests, retries := c.totals(slice)
+	return float64(retries) < float64(b.minRetries)+b.ratio*float64(requests)
+}
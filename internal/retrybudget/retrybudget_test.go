@@ -0,0 +1,53 @@
+package retrybudget
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := New(0.2, time.Minute, 1)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		b.Request("acme")
+	}
+	// 1 + 20% of 10 requests = 3 retries.
+	for i := 0; i < 3; i++ {
+		if ok, scope := b.Retry("acme"); !ok {
+			t.Fatalf("retry %d refused (%s)", i+1, scope)
+		}
+	}
+	if ok, scope := b.Retry("acme"); ok || scope != ScopeTenant {
+		t.Fatalf("expected the tenant budget to be exhausted, got %v %q", ok, scope)
+	}
+
+	// Old retries age out of the window.
+	now = now.Add(61 * time.Second)
+	if ok, scope := b.Retry("acme"); !ok {
+		t.Fatalf("expected a retry once the window slid, got %q", scope)
+	}
+}
+
+func TestGlobalBudget(t *testing.T) {
+	// Each tenant's floor allows a retry, but the gateway's allows one in all.
+	b := New(0.0001, time.Minute, 1)
+	if ok, _ := b.Retry("acme"); !ok {
+		t.Fatal("expected acme's retry to be allowed")
+	}
+	if ok, scope := b.Retry("globex"); ok || scope != ScopeGlobal {
+		t.Fatalf("expected the global budget to be exhausted, got %v %q", ok, scope)
+	}
+}
+
+func TestNilBudget(t *testing.T) {
+	b := New(0, time.Minute, 0)
+	if b != nil {
+		t.Fatal("expected a zero ratio to disable the budget")
+	}
+	b.Request("acme")
+	if ok, _ := b.Retry("acme"); !ok {
+		t.Error("expected a nil budget to allow retries")
+	}
+}
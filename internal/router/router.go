@@ -1,32 +1,104 @@
 package router
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net"
 	"net/http"
 	"os"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
 )
 
 type Router struct {
+	mu     sync.RWMutex
+	static []config.Route
 	routes []config.Route
 }
 
 func NewRouter(routes []config.Route) *Router {
-	return &Router{routes: routes}
+	return &Router{static: routes, routes: routes}
+}
+
+// SetDynamic replaces the routes managed outside the config file. They take
+// precedence over file routes: one with the same name replaces the file
+// route, and all of them are matched before the remaining file routes.
+func (r *Router) SetDynamic(dynamic []config.Route) {
+	names := make(map[string]bool, len(dynamic))
+	merged := make([]config.Route, 0, len(dynamic)+len(r.static))
+	for _, route := range dynamic {
+		names[route.Name] = true
+		merged = append(merged, route)
+	}
+	for _, route := range r.static {
+		if !names[route.Name] {
+			merged = append(merged, route)
+		}
+	}
+
+	r.mu.Lock()
+	r.routes = merged
+	r.mu.Unlock()
+}
+
+// Sync loads the dynamic routes now and then every interval until ctx is
+// done. A failed load keeps the previous routes.
+func (r *Router) Sync(ctx context.Context, interval time.Duration, load func(ctx context.Context) ([]config.Route, error)) {
+	reload := func() {
+		routes, err := load(ctx)
+		if err != nil {
+			log.Printf("Warning: failed to load routes: %v", err)
+			return
+		}
+		r.SetDynamic(routes)
+	}
+
+	reload()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reload()
+		}
+	}
+}
+
+func (r *Router) snapshot() []config.Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.routes
 }
 
 func (r *Router) Route(useCase string) config.Route {
-	for _, route := range r.routes {
+	route, _ := r.Match(useCase)
+	return route
+}
+
+// Match returns the route for useCase and the rule that picked it:
+// MatchedUseCase, MatchedDefault for the route named "default", or
+// MatchedBuiltin when no route is configured for it.
+func (r *Router) Match(useCase string) (config.Route, string) {
+	routes := r.snapshot()
+	for _, route := range routes {
 		if route.Match.UseCase == useCase {
-			return route
+			return route, MatchedUseCase
 		}
 	}
 
-	for _, route := range r.routes {
+	for _, route := range routes {
 		if route.Name == "default" {
-			return route
+			return route, MatchedDefault
 		}
 	}
 
@@ -35,7 +107,40 @@ func (r *Router) Route(useCase string) config.Route {
 		Name:    "default",
 		Primary: config.Target{Provider: "openai", Model: "gpt-4o-mini"},
 		Retries: 1,
+	}, MatchedBuiltin
+}
+
+// The rules Match picks routes by.
+const (
+	MatchedUseCase = "match.use_case"
+	MatchedDefault = "default_route"
+	MatchedBuiltin = "builtin_default"
+)
+
+// Lookup returns the configured route with the given name.
+func (r *Router) Lookup(name string) (config.Route, bool) {
+	for _, route := range r.snapshot() {
+		if route.Name == name {
+			return route, true
+		}
 	}
+	return config.Route{}, false
+}
+
+// Routes returns the routes in effect, stored ones first.
+func (r *Router) Routes() []config.Route {
+	return r.snapshot()
+}
+
+// UseCases lists the use cases routes match on, in config order.
+func (r *Router) UseCases() []string {
+	var out []string
+	for _, route := range r.snapshot() {
+		if route.Match.UseCase != "" {
+			out = append(out, route.Match.UseCase)
+		}
+	}
+	return out
 }
 
 func IsRetryable(err error) bool {
@@ -54,6 +159,11 @@ func IsRetryable(err error) bool {
 		return true
 	}
 
+	var statusErr *providers.StatusError
+	if errors.As(err, &statusErr) {
+		return StatusCodeIsRetryable(statusErr.Code)
+	}
+
 	return false
 }
 
@@ -1,10 +1,13 @@
 package router
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
 )
 
 func TestRouter_Route(t *testing.T) {
@@ -48,6 +51,18 @@ func TestRouter_Route(t *testing.T) {
 			t.Errorf("expected default, got %s", rt.Name)
 		}
 	})
+
+	t.Run("Matched rule", func(t *testing.T) {
+		if _, rule := r.Match("support_summary"); rule != MatchedUseCase {
+			t.Errorf("expected %s, got %s", MatchedUseCase, rule)
+		}
+		if _, rule := r.Match("unknown"); rule != MatchedDefault {
+			t.Errorf("expected %s, got %s", MatchedDefault, rule)
+		}
+		if _, rule := NewRouter(nil).Match("unknown"); rule != MatchedBuiltin {
+			t.Errorf("expected %s, got %s", MatchedBuiltin, rule)
+		}
+	})
 }
 
 func TestIsRetryable(t *testing.T) {
@@ -70,6 +85,15 @@ func TestIsRetryable(t *testing.T) {
 		}
 	})
 
+	t.Run("Status errors follow the status code", func(t *testing.T) {
+		if !IsRetryable(&providers.StatusError{Provider: "openai", Code: 503}) {
+			t.Error("expected 503 status error to be retryable")
+		}
+		if IsRetryable(fmt.Errorf("wrapped: %w", &providers.StatusError{Provider: "openai", Code: 400})) {
+			t.Error("expected 400 status error to NOT be retryable")
+		}
+	})
+
 	t.Run("Unknown errors should not be retryable", func(t *testing.T) {
 		err := fmt.Errorf("some unknown error")
 		if IsRetryable(err) {
@@ -77,3 +101,46 @@ func TestIsRetryable(t *testing.T) {
 		}
 	})
 }
+
+func TestRouter_SetDynamic(t *testing.T) {
+	r := NewRouter([]config.Route{
+		{Name: "support", Match: config.Match{UseCase: "support_summary"}, Primary: config.Target{Provider: "openai", Model: "gpt-4o-mini"}},
+		{Name: "default", Primary: config.Target{Provider: "openai", Model: "gpt-4o"}},
+	})
+
+	r.SetDynamic([]config.Route{
+		{Name: "support", Match: config.Match{UseCase: "support_summary"}, Primary: config.Target{Provider: "anthropic", Model: "claude-3-5-sonnet"}},
+		{Name: "triage", Match: config.Match{UseCase: "triage"}, Primary: config.Target{Provider: "mistral", Model: "mistral-small"}},
+	})
+	if got := r.Route("support_summary").Primary.Provider; got != "anthropic" {
+		t.Errorf("expected stored route to replace file route, got provider %s", got)
+	}
+	if got := r.Route("triage").Name; got != "triage" {
+		t.Errorf("expected triage route, got %s", got)
+	}
+	if _, ok := r.Lookup("default"); !ok {
+		t.Error("expected file routes to remain")
+	}
+
+	r.SetDynamic(nil)
+	if got := r.Route("support_summary").Primary.Provider; got != "openai" {
+		t.Errorf("expected file route back after removal, got provider %s", got)
+	}
+	if got := r.Route("triage").Name; got != "default" {
+		t.Errorf("expected removed route to fall back to default, got %s", got)
+	}
+}
+
+func TestRouter_SyncKeepsRoutesOnError(t *testing.T) {
+	r := NewRouter(nil)
+	stored := []config.Route{{Name: "triage", Match: config.Match{UseCase: "triage"}}}
+	r.Sync(context.Background(), 0, func(context.Context) ([]config.Route, error) { return stored, nil })
+	if got := r.Route("triage").Name; got != "triage" {
+		t.Fatalf("expected loaded route, got %s", got)
+	}
+
+	r.Sync(context.Background(), 0, func(context.Context) ([]config.Route, error) { return nil, errors.New("db down") })
+	if got := r.Route("triage").Name; got != "triage" {
+		t.Errorf("expected previous routes after failed load, got %s", got)
+	}
+}
@@ -0,0 +1,173 @@
+// Package secrets decrypts secrets kept encrypted in configuration, so
+// config files holding provider keys can live in version control. An
+// encrypted value reads "enc:<scheme>:<base64 ciphertext>". The local
+// scheme is AES-256-GCM under a key derived from a master key held by the
+// gateway; other schemes, such as age or a cloud KMS, are decrypted by a
+// command that reads the ciphertext on stdin and writes the plaintext.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Prefix marks an encrypted value.
+const Prefix = "enc:"
+
+// IsEncrypted reports whether v is an encrypted value.
+func IsEncrypted(v string) bool {
+	return strings.HasPrefix(v, Prefix)
+}
+
+// Decrypter decrypts the ciphertext of one scheme.
+type Decrypter interface {
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// Resolver decrypts encrypted values with the decrypter of their scheme.
+type Resolver map[string]Decrypter
+
+// Resolve returns v decrypted, or v itself when it is not encrypted.
+func (r Resolver) Resolve(ctx context.Context, v string) (string, error) {
+	rest, ok := strings.CutPrefix(v, Prefix)
+	if !ok {
+		return v, nil
+	}
+	scheme, blob, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", errors.New(`encrypted value must read "enc:<scheme>:<base64>"`)
+	}
+	d, ok := r[scheme]
+	if !ok {
+		return "", fmt.Errorf("no decrypter configured for scheme %q", scheme)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(blob)
+	if err != nil {
+		return "", fmt.Errorf("encrypted value must be base64: %w", err)
+	}
+	plaintext, err := d.Decrypt(ctx, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s value: %w", scheme, err)
+	}
+	return strings.TrimRight(string(plaintext), "\r\n"), nil
+}
+
+// LocalKey encrypts values with AES-256-GCM under a key derived from a
+// master key.
+type LocalKey struct {
+	key []byte
+}
+
+// NewLocalKey takes a base64-encoded master key of at least 32 bytes.
+func NewLocalKey(masterKey string) (*LocalKey, error) {
+	master, err := base64.StdEncoding.DecodeString(strings.TrimSpace(masterKey))
+	if err != nil {
+		return nil, fmt.Errorf("secrets key must be base64: %w", err)
+	}
+	if len(master) < 32 {
+		return nil, fmt.Errorf("secrets key must be at least 32 bytes, got %d", len(master))
+	}
+	key, err := hkdf.Key(sha256.New, master, nil, "ai-gateway config secret", 32)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalKey{key: key}, nil
+}
+
+// Encrypt returns plaintext as an encrypted value of the local scheme.
+func (k *LocalKey) Encrypt(plaintext string) (string, error) {
+	gcm, err := k.gcm()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return Prefix + "local:" + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func (k *LocalKey) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	gcm, err := k.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, body := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, body, nil)
+}
+
+func (k *LocalKey) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Command decrypts by running a shell command with the ciphertext on its
+// stdin, e.g. "age --decrypt -i /run/secrets/age.txt".
+type Command string
+
+func (c Command) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", string(c))
+	cmd.Stdin = bytes.NewReader(ciphertext)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// Main runs the encrypt-secret command, which prints the secret read from
+// stdin as an encrypted value of the local scheme, under the master key in
+// CONFIG_SECRETS_KEY or the file named by -key-file.
+func Main(args []string) error {
+	fs := flag.NewFlagSet("encrypt-secret", flag.ContinueOnError)
+	keyFile := fs.String("key-file", os.Getenv("CONFIG_SECRETS_KEY_FILE"), "file holding the base64 master key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	master := os.Getenv("CONFIG_SECRETS_KEY")
+	if *keyFile != "" {
+		data, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		master = string(data)
+	}
+	key, err := NewLocalKey(master)
+	if err != nil {
+		return err
+	}
+	plaintext, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	value, err := key.Encrypt(strings.TrimRight(string(plaintext), "\r\n"))
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
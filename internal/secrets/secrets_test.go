@@ -0,0 +1,49 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var testKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	key, err := NewLocalKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := key.Encrypt("sk-secret")
+	if err != nil || !strings.HasPrefix(value, "enc:local:") || strings.Contains(value, "sk-secret") {
+		t.Fatalf("unexpected encrypted value %q, %v", value, err)
+	}
+
+	r := Resolver{"local": key, "age": Command("base64 -d")}
+	if got, err := r.Resolve(ctx, value); err != nil || got != "sk-secret" {
+		t.Errorf("expected the local value decrypted, got %q, %v", got, err)
+	}
+	if got, err := r.Resolve(ctx, "sk-plain"); err != nil || got != "sk-plain" {
+		t.Errorf("expected a plain value kept, got %q, %v", got, err)
+	}
+	blob := base64.StdEncoding.EncodeToString([]byte(base64.StdEncoding.EncodeToString([]byte("sk-age\n"))))
+	if got, err := r.Resolve(ctx, "enc:age:"+blob); err != nil || got != "sk-age" {
+		t.Errorf("expected the command's output, got %q, %v", got, err)
+	}
+
+	for _, bad := range []string{"enc:kms:AAAA", "enc:local", "enc:local:!!", value[:len(value)-4] + "AAAA"} {
+		if _, err := r.Resolve(ctx, bad); err == nil {
+			t.Errorf("expected %q rejected", bad)
+		}
+	}
+	if _, err := (Resolver{"kms": Command("exit 1")}).Resolve(ctx, "enc:kms:AAAA"); err == nil {
+		t.Error("expected a failing command reported")
+	}
+}
+
+func TestNewLocalKeyRejectsShortKey(t *testing.T) {
+	if _, err := NewLocalKey(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("expected a short key rejected")
+	}
+}
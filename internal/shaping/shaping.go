@@ -0,0 +1,94 @@
+package shaping
+
+import (
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+)
+
+// Apply returns a channel that re-emits chunks from in, merging content
+// deltas that arrive within the coalescing window into a single chunk and
+// pacing output to at most MaxTokensPerSecond. The returned channel is closed
+// once in is closed and everything buffered has been flushed.
+func Apply(in <-chan providers.ChatChunk, cfg config.StreamShaping) <-chan providers.ChatChunk {
+	if cfg.CoalesceMS <= 0 && cfg.MaxTokensPerSecond <= 0 {
+		return in
+	}
+
+	out := make(chan providers.ChatChunk)
+	go func() {
+		defer close(out)
+
+		p := pacer{rate: cfg.MaxTokensPerSecond, start: time.Now()}
+		send := func(c providers.ChatChunk) {
+			if len(c.Choices) > 0 {
+				p.wait(usage.ApproximateTokens(c.Choices[0].Delta.Content))
+			}
+			out <- c
+		}
+
+		if cfg.CoalesceMS <= 0 {
+			for c := range in {
+				send(c)
+			}
+			return
+		}
+
+		window := time.Duration(cfg.CoalesceMS) * time.Millisecond
+		var pending *providers.ChatChunk
+		var flush <-chan time.Time
+		for {
+			select {
+			case c, ok := <-in:
+				if !ok {
+					if pending != nil {
+						send(*pending)
+					}
+					return
+				}
+				// Content-only deltas are merged; anything carrying a finish
+				// reason or tool calls flushes what is pending and goes out
+				// as is.
+				if len(c.Choices) != 1 || c.Choices[0].FinishReason != "" || len(c.Choices[0].Delta.ToolCalls) > 0 {
+					if pending != nil {
+						send(*pending)
+						pending, flush = nil, nil
+					}
+					send(c)
+					continue
+				}
+				if pending == nil {
+					pending = &c
+					flush = time.After(window)
+					continue
+				}
+				pending.Choices[0].Delta.Content += c.Choices[0].Delta.Content
+			case <-flush:
+				send(*pending)
+				pending, flush = nil, nil
+			}
+		}
+	}()
+	return out
+}
+
+// pacer spaces output so the cumulative token count never runs ahead of
+// rate tokens per second since the stream started.
+type pacer struct {
+	rate    int
+	start   time.Time
+	emitted int
+}
+
+func (p *pacer) wait(tokens int) {
+	if p.rate <= 0 {
+		return
+	}
+	p.emitted += tokens
+	due := p.start.Add(time.Duration(float64(p.emitted) / float64(p.rate) * float64(time.Second)))
+	if d := time.Until(due); d > 0 {
+		time.Sleep(d)
+	}
+}
@@ -0,0 +1,60 @@
+package shaping
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+func chunk(content, finish string) providers.ChatChunk {
+	var c providers.ChatChunk
+	c.Choices = make([]providers.ChunkChoice, 1)
+	c.Choices[0].Delta.Content = content
+	c.Choices[0].FinishReason = finish
+	return c
+}
+
+func TestApply_Coalesces(t *testing.T) {
+	in := make(chan providers.ChatChunk)
+	go func() {
+		defer close(in)
+		in <- chunk("a", "")
+		in <- chunk("b", "")
+		in <- chunk("c", "")
+		in <- chunk("", "stop")
+	}()
+
+	var got []providers.ChatChunk
+	for c := range Apply(in, config.StreamShaping{CoalesceMS: 1000}) {
+		got = append(got, c)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(got))
+	}
+	if got[0].Choices[0].Delta.Content != "abc" {
+		t.Errorf("expected merged content 'abc', got '%s'", got[0].Choices[0].Delta.Content)
+	}
+	if got[1].Choices[0].FinishReason != "stop" {
+		t.Errorf("expected finish chunk last, got '%s'", got[1].Choices[0].FinishReason)
+	}
+}
+
+func TestApply_Paces(t *testing.T) {
+	in := make(chan providers.ChatChunk, 3)
+	// 8 characters is 2 approximate tokens per chunk.
+	in <- chunk("12345678", "")
+	in <- chunk("12345678", "")
+	in <- chunk("12345678", "")
+	close(in)
+
+	start := time.Now()
+	for range Apply(in, config.StreamShaping{MaxTokensPerSecond: 60}) {
+	}
+	// 6 tokens at 60 tokens/s is 100ms.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected pacing to take ~100ms, took %s", elapsed)
+	}
+}
@@ -0,0 +1,165 @@
+package streambuf
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Buffer keeps the SSE events of recent streams in memory so a client that
+// drops mid-stream can reconnect with Last-Event-ID and resume where it left
+// off. Finished streams are kept for the configured window, then discarded.
+//
+// Streams are keyed on a random resume token rather than the request ID,
+// which shows up in logs and usage records, and each is bound to the tenant
+// that started it; a resume only succeeds for the same tenant.
+type Buffer struct {
+	mu        sync.Mutex
+	window    time.Duration
+	streams   map[string]*stream
+	byRequest map[string]string // request ID -> resume token
+}
+
+type stream struct {
+	requestID string
+	tenant    string
+	events    [][]byte
+	done      bool
+	updated   chan struct{}
+}
+
+func New(window time.Duration) *Buffer {
+	return &Buffer{window: window, streams: make(map[string]*stream), byRequest: make(map[string]string)}
+}
+
+// Open starts buffering a stream for a request and returns its resume token.
+func (b *Buffer) Open(requestID, tenant string) string {
+	var raw [16]byte
+	rand.Read(raw[:])
+	token := hex.EncodeToString(raw[:])
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &stream{requestID: requestID, tenant: tenant, updated: make(chan struct{})}
+	b.streams[token] = s
+	b.byRequest[requestID] = token
+	// Bound the lifetime of streams that are never finished.
+	time.AfterFunc(b.window+10*time.Minute, func() { b.drop(token, s) })
+	return token
+}
+
+// Resume reports whether a stream is buffered and owned by tenant, and
+// returns the request it belongs to. A stream of another tenant is reported
+// as missing.
+func (b *Buffer) Resume(token, tenant string) (requestID string, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.streams[token]
+	if !ok || s.tenant != tenant {
+		return "", false
+	}
+	return s.requestID, true
+}
+
+// Append stores an event and returns its sequence number (starting at 1),
+// or 0 when the stream is no longer buffered.
+func (b *Buffer) Append(token string, data []byte) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.streams[token]
+	if !ok {
+		return 0
+	}
+	s.events = append(s.events, data)
+	close(s.updated)
+	s.updated = make(chan struct{})
+	return len(s.events)
+}
+
+// Finish marks a stream complete and schedules it for removal.
+func (b *Buffer) Finish(token string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.streams[token]
+	if !ok || s.done {
+		return
+	}
+	s.done = true
+	close(s.updated)
+	s.updated = make(chan struct{})
+	time.AfterFunc(b.window, func() { b.drop(token, s) })
+}
+
+// Remove discards a stream's buffered events right away and reports whether
+// there were any. A client still reading it sees the stream end.
+func (b *Buffer) Remove(requestID string) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	token, ok := b.byRequest[requestID]
+	if !ok {
+		return false
+	}
+	s := b.streams[token]
+	delete(b.streams, token)
+	delete(b.byRequest, requestID)
+	if !s.done {
+		s.done = true
+		close(s.updated)
+		s.updated = make(chan struct{})
+	}
+	return true
+}
+
+func (b *Buffer) drop(token string, s *stream) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.streams[token] == s {
+		delete(b.streams, token)
+		delete(b.byRequest, s.requestID)
+	}
+}
+
+// Since returns the events after sequence number after. When the stream is
+// still live and no new events exist yet, wait is closed once more arrive.
+func (b *Buffer) Since(token string, after int) (events [][]byte, done bool, wait <-chan struct{}, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.streams[token]
+	if !ok {
+		return nil, false, nil, false
+	}
+	if after < len(s.events) {
+		events = append(events, s.events[after:]...)
+	}
+	return events, s.done, s.updated, true
+}
+
+// EventID formats the SSE id for an event.
+func EventID(token string, seq int) string {
+	return fmt.Sprintf("%s:%d", token, seq)
+}
+
+// ParseEventID splits a Last-Event-ID header into resume token and sequence.
+func ParseEventID(id string) (string, int, error) {
+	i := strings.LastIndex(id, ":")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("malformed event id %q", id)
+	}
+	seq, err := strconv.Atoi(id[i+1:])
+	if err != nil || seq < 0 {
+		return "", 0, fmt.Errorf("malformed event id %q", id)
+	}
+	return id[:i], seq, nil
+}
@@ -0,0 +1,90 @@
+package streambuf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuffer(t *testing.T) {
+	b := New(time.Minute)
+
+	token := b.Open("req-1", "acme")
+	b.Append(token, []byte("a"))
+	b.Append(token, []byte("b"))
+	seq := b.Append(token, []byte("c"))
+	if seq != 3 {
+		t.Errorf("expected seq 3, got %d", seq)
+	}
+
+	if id, ok := b.Resume(token, "acme"); !ok || id != "req-1" {
+		t.Errorf("expected the owner to resume req-1, got %q %v", id, ok)
+	}
+	if _, ok := b.Resume(token, "globex"); ok {
+		t.Error("expected another tenant to be refused")
+	}
+	if _, ok := b.Resume("req-1", "acme"); ok {
+		t.Error("expected the request ID not to resume the stream")
+	}
+
+	events, done, wait, ok := b.Since(token, 1)
+	if !ok {
+		t.Fatal("expected stream to be buffered")
+	}
+	if len(events) != 2 || string(events[0]) != "b" {
+		t.Errorf("expected events after seq 1, got %q", events)
+	}
+	if done {
+		t.Error("expected stream to still be live")
+	}
+
+	b.Finish(token)
+	select {
+	case <-wait:
+	default:
+		t.Error("expected wait channel to close on finish")
+	}
+
+	if _, _, _, ok := b.Since("unknown", 0); ok {
+		t.Error("expected unknown stream to be missing")
+	}
+}
+
+func TestParseEventID(t *testing.T) {
+	id, seq, err := ParseEventID(EventID("a:b-c", 7))
+	if err != nil || id != "a:b-c" || seq != 7 {
+		t.Errorf("unexpected parse result %q %d %v", id, seq, err)
+	}
+	if _, _, err := ParseEventID("nocolon"); err == nil {
+		t.Error("expected error for malformed id")
+	}
+}
+
+func TestBufferRemove(t *testing.T) {
+	b := New(time.Minute)
+	token := b.Open("req-1", "acme")
+	b.Append(token, []byte("a"))
+	_, _, wait, _ := b.Since(token, 1)
+
+	if !b.Remove("req-1") {
+		t.Fatal("expected the stream to be removed")
+	}
+	select {
+	case <-wait:
+	default:
+		t.Error("expected a live reader to be released")
+	}
+	if _, _, _, ok := b.Since(token, 0); ok {
+		t.Error("expected the removed stream's events to be gone")
+	}
+	if seq := b.Append(token, []byte("b")); seq != 0 {
+		t.Errorf("expected appends after removal to be dropped, got seq %d", seq)
+	}
+	if b.Remove("req-1") {
+		t.Error("expected a second remove to find nothing")
+	}
+
+	var none *Buffer
+	if none.Remove("req-1") {
+		t.Error("expected a nil buffer to hold nothing")
+	}
+}
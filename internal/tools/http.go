@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// httpTool POSTs the call's arguments to a URL and returns the response body.
+type httpTool struct {
+	url     string
+	headers map[string]string
+}
+
+func (t *httpTool) call(ctx context.Context, arguments json.RawMessage) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", t.url, bytes.NewReader(arguments))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResultBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("tool returned status %d: %s", resp.StatusCode, body)
+	}
+	return string(body), nil
+}
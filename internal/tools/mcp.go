@@ -0,0 +1,262 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// mcpProtocolVersion is the MCP revision the client speaks.
+const mcpProtocolVersion = "2025-03-26"
+
+// errSessionExpired means the server no longer knows our session.
+var errSessionExpired = errors.New("mcp session expired")
+
+// mcpClient is a minimal MCP client over the streamable HTTP transport:
+// JSON-RPC requests are POSTed and answered with either a JSON body or an
+// SSE stream carrying the response.
+type mcpClient struct {
+	url     string
+	headers map[string]string
+
+	mu        sync.Mutex
+	session   string
+	ready     bool
+	nextID    int64
+	toolCache map[string]mcpToolInfo
+}
+
+type mcpToolInfo struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func newMCPClient(url string, headers map[string]string) *mcpClient {
+	return &mcpClient{url: url, headers: headers}
+}
+
+// request sends a JSON-RPC call, initialising the session first if needed
+// and once more if the server has forgotten it.
+func (c *mcpClient) request(ctx context.Context, method string, params, result interface{}) error {
+	for attempt := 0; ; attempt++ {
+		if err := c.ensureReady(ctx); err != nil {
+			return err
+		}
+		err := c.send(ctx, method, params, result)
+		if errors.Is(err, errSessionExpired) && attempt == 0 {
+			c.mu.Lock()
+			c.ready, c.session = false, ""
+			c.mu.Unlock()
+			continue
+		}
+		return err
+	}
+}
+
+func (c *mcpClient) ensureReady(ctx context.Context) error {
+	c.mu.Lock()
+	ready := c.ready
+	c.mu.Unlock()
+	if ready {
+		return nil
+	}
+
+	params := map[string]interface{}{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": "ai-gateway", "version": "1.0"},
+	}
+	if err := c.send(ctx, "initialize", params, nil); err != nil {
+		return fmt.Errorf("mcp initialize: %w", err)
+	}
+	if err := c.send(ctx, "notifications/initialized", nil, nil); err != nil {
+		return fmt.Errorf("mcp initialized notification: %w", err)
+	}
+	c.mu.Lock()
+	c.ready = true
+	c.mu.Unlock()
+	return nil
+}
+
+// send posts one message. Methods under notifications/ carry no id and
+// expect no response.
+func (c *mcpClient) send(ctx context.Context, method string, params, result interface{}) error {
+	msg := rpcMessage{JSONRPC: "2.0", Method: method, Params: params}
+	notification := strings.HasPrefix(method, "notifications/")
+	c.mu.Lock()
+	if !notification {
+		c.nextID++
+		id := c.nextID
+		msg.ID = &id
+	}
+	session := c.session
+	c.mu.Unlock()
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if session != "" {
+		req.Header.Set("Mcp-Session-Id", session)
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && session != "" {
+		return errSessionExpired
+	}
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("mcp server returned status %d: %s", resp.StatusCode, b)
+	}
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		c.mu.Lock()
+		c.session = id
+		c.mu.Unlock()
+	}
+	if notification {
+		return nil
+	}
+
+	reply, err := readReply(resp, *msg.ID)
+	if err != nil {
+		return err
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("mcp error %d: %s", reply.Error.Code, reply.Error.Message)
+	}
+	if result != nil {
+		return json.Unmarshal(reply.Result, result)
+	}
+	return nil
+}
+
+// readReply extracts the response to id from a JSON or SSE body.
+func readReply(resp *http.Response, id int64) (*rpcMessage, error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		var msg rpcMessage
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+			return nil, err
+		}
+		return &msg, nil
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 4<<20)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if after, ok := strings.CutPrefix(line, "data:"); ok {
+			data.WriteString(strings.TrimPrefix(after, " "))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+		// End of an event: is it our response?
+		var msg rpcMessage
+		if err := json.Unmarshal([]byte(data.String()), &msg); err == nil && msg.ID != nil && *msg.ID == id {
+			return &msg, nil
+		}
+		data.Reset()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("mcp stream ended without a response")
+}
+
+// describe returns the server's definition of a tool.
+func (c *mcpClient) describe(ctx context.Context, name string) (mcpToolInfo, error) {
+	c.mu.Lock()
+	info, ok := c.toolCache[name]
+	c.mu.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	var list struct {
+		Tools []mcpToolInfo `json:"tools"`
+	}
+	if err := c.request(ctx, "tools/list", map[string]interface{}{}, &list); err != nil {
+		return mcpToolInfo{}, err
+	}
+	c.mu.Lock()
+	c.toolCache = make(map[string]mcpToolInfo, len(list.Tools))
+	for _, t := range list.Tools {
+		c.toolCache[t.Name] = t
+	}
+	info, ok = c.toolCache[name]
+	c.mu.Unlock()
+	if !ok {
+		return mcpToolInfo{}, fmt.Errorf("mcp server does not offer tool %q", name)
+	}
+	return info, nil
+}
+
+// mcpTool calls one tool on an MCP server.
+type mcpTool struct {
+	client *mcpClient
+	name   string
+}
+
+func (t *mcpTool) call(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	params := map[string]interface{}{"name": t.name, "arguments": arguments}
+	if err := t.client.request(ctx, "tools/call", params, &result); err != nil {
+		return "", err
+	}
+
+	var parts []string
+	for _, c := range result.Content {
+		if c.Type == "text" {
+			parts = append(parts, c.Text)
+		}
+	}
+	out := strings.Join(parts, "\n")
+	if result.IsError {
+		return "", fmt.Errorf("%s", out)
+	}
+	return out, nil
+}
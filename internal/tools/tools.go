@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+)
+
+// maxResultBytes caps how much of a tool's output is fed back to the model.
+const maxResultBytes = 64 << 10
+
+// executor runs one tool call. arguments is the JSON object the model sent.
+type executor interface {
+	call(ctx context.Context, arguments json.RawMessage) (string, error)
+}
+
+// Registry holds the tools the gateway can execute.
+type Registry struct {
+	defs  map[string]config.ToolDef
+	execs map[string]executor
+
+	mu     sync.Mutex
+	schema map[string]providers.Tool // resolved definitions, by name
+}
+
+// NewRegistry builds executors for defs. Every tool needs a name and
+// exactly one backend.
+func NewRegistry(defs []config.ToolDef) (*Registry, error) {
+	r := &Registry{
+		defs:   make(map[string]config.ToolDef),
+		execs:  make(map[string]executor),
+		schema: make(map[string]providers.Tool),
+	}
+	servers := make(map[string]*mcpClient)
+	for _, d := range defs {
+		if d.Name == "" {
+			return nil, fmt.Errorf("tool without a name")
+		}
+		if (d.HTTP == nil) == (d.MCP == nil) {
+			return nil, fmt.Errorf("tool %q must set exactly one of http or mcp", d.Name)
+		}
+		switch {
+		case d.HTTP != nil:
+			r.execs[d.Name] = &httpTool{url: d.HTTP.URL, headers: d.HTTP.Headers}
+		case d.MCP != nil:
+			client := servers[d.MCP.URL]
+			if client == nil {
+				client = newMCPClient(d.MCP.URL, d.MCP.Headers)
+				servers[d.MCP.URL] = client
+			}
+			remote := d.MCP.Tool
+			if remote == "" {
+				remote = d.Name
+			}
+			r.execs[d.Name] = &mcpTool{client: client, name: remote}
+		}
+		r.defs[d.Name] = d
+	}
+	return r, nil
+}
+
+// Has reports whether the gateway owns the named tool.
+func (r *Registry) Has(name string) bool {
+	if r == nil {
+		return false
+	}
+	_, ok := r.execs[name]
+	return ok
+}
+
+// Definitions returns the model-facing definitions of the named tools,
+// asking MCP servers for any description or schema not set in config.
+// Unknown names are skipped.
+func (r *Registry) Definitions(ctx context.Context, names []string) []providers.Tool {
+	if r == nil {
+		return nil
+	}
+	var out []providers.Tool
+	for _, name := range names {
+		if t, ok := r.definition(ctx, name); ok {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (r *Registry) definition(ctx context.Context, name string) (providers.Tool, bool) {
+	r.mu.Lock()
+	t, ok := r.schema[name]
+	r.mu.Unlock()
+	if ok {
+		return t, true
+	}
+
+	d, ok := r.defs[name]
+	if !ok {
+		return providers.Tool{}, false
+	}
+	fn := providers.ToolFunction{Name: name, Description: d.Description}
+	if d.Parameters != nil {
+		fn.Parameters, _ = json.Marshal(d.Parameters)
+	}
+	if m, isMCP := r.execs[name].(*mcpTool); isMCP && (fn.Description == "" || fn.Parameters == nil) {
+		remote, err := m.client.describe(ctx, m.name)
+		if err != nil {
+			// Try again on the next request rather than caching a guess.
+			fn.Parameters = json.RawMessage(`{"type":"object"}`)
+			return providers.Tool{Type: "function", Function: fn}, true
+		}
+		if fn.Description == "" {
+			fn.Description = remote.Description
+		}
+		if fn.Parameters == nil {
+			fn.Parameters = remote.InputSchema
+		}
+	}
+	if fn.Parameters == nil {
+		fn.Parameters = json.RawMessage(`{"type":"object"}`)
+	}
+
+	t = providers.Tool{Type: "function", Function: fn}
+	r.mu.Lock()
+	r.schema[name] = t
+	r.mu.Unlock()
+	return t, true
+}
+
+// Call runs the named tool with the model's arguments and returns its
+// output, truncated to a size the model can take back.
+func (r *Registry) Call(ctx context.Context, name, arguments string) (string, error) {
+	exec, ok := r.execs[name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", name)
+	}
+	if arguments == "" {
+		arguments = "{}"
+	}
+	if !json.Valid([]byte(arguments)) {
+		return "", fmt.Errorf("arguments are not valid JSON")
+	}
+
+	timeout := 30 * time.Second
+	if ms := r.defs[name].TimeoutMS; ms > 0 {
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	out, err := exec.call(ctx, json.RawMessage(arguments))
+	if len(out) > maxResultBytes {
+		out = out[:maxResultBytes]
+	}
+	return out, err
+}
@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+func TestHTTPTool(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "weather for %s: sunny", body)
+	}))
+	defer srv.Close()
+
+	reg, err := NewRegistry([]config.ToolDef{{Name: "weather", HTTP: &config.HTTPTool{URL: srv.URL}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := reg.Call(context.Background(), "weather", `{"city":"Oslo"}`)
+	if err != nil || out != `weather for {"city":"Oslo"}: sunny` {
+		t.Fatalf("unexpected result %q, %v", out, err)
+	}
+	if _, err := reg.Call(context.Background(), "weather", `{not json`); err == nil {
+		t.Errorf("expected invalid arguments to be rejected")
+	}
+
+	defs := reg.Definitions(context.Background(), []string{"weather", "missing"})
+	if len(defs) != 1 || string(defs[0].Function.Parameters) != `{"type":"object"}` {
+		t.Errorf("unexpected definitions %+v", defs)
+	}
+}
+
+func TestRegistryValidation(t *testing.T) {
+	if _, err := NewRegistry([]config.ToolDef{{Name: "x"}}); err == nil {
+		t.Errorf("expected a tool without a backend to be rejected")
+	}
+}
+
+// fakeMCP is a streamable-HTTP MCP server with one tool, "search".
+func fakeMCP(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg rpcMessage
+		json.NewDecoder(r.Body).Decode(&msg)
+		if msg.Method != "initialize" && r.Header.Get("Mcp-Session-Id") != "s1" {
+			t.Errorf("%s sent without session", msg.Method)
+		}
+		if msg.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		var result interface{}
+		switch msg.Method {
+		case "initialize":
+			w.Header().Set("Mcp-Session-Id", "s1")
+			result = map[string]interface{}{"protocolVersion": mcpProtocolVersion}
+		case "tools/list":
+			result = map[string]interface{}{"tools": []map[string]interface{}{
+				{"name": "search", "description": "Search docs", "inputSchema": map[string]string{"type": "object"}},
+			}}
+		case "tools/call":
+			params := msg.Params.(map[string]interface{})
+			args, _ := json.Marshal(params["arguments"])
+			result = map[string]interface{}{"content": []map[string]string{{"type": "text", "text": "found " + string(args)}}}
+			// Answer this one as an event stream.
+			data, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+	}))
+}
+
+func TestMCPTool(t *testing.T) {
+	srv := fakeMCP(t)
+	defer srv.Close()
+
+	reg, err := NewRegistry([]config.ToolDef{{Name: "docs_search", MCP: &config.MCPTool{URL: srv.URL, Tool: "search"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defs := reg.Definitions(context.Background(), []string{"docs_search"})
+	if len(defs) != 1 || defs[0].Function.Name != "docs_search" || defs[0].Function.Description != "Search docs" {
+		t.Fatalf("expected the server's description, got %+v", defs)
+	}
+
+	out, err := reg.Call(context.Background(), "docs_search", `{"q":"refunds"}`)
+	if err != nil || !strings.Contains(out, `"q":"refunds"`) {
+		t.Fatalf("unexpected result %q, %v", out, err)
+	}
+}
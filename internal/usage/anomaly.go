@@ -0,0 +1,43 @@
+package usage
+
+import (
+	"context"
+	"time"
+)
+
+// TenantInterval is one tenant's traffic in one interval. Errors are
+// requests that failed with a 5xx status.
+type TenantInterval struct {
+	Tenant   string
+	Start    time.Time
+	Requests int
+	Errors   int
+	Tokens   int
+}
+
+// TenantIntervals totals each tenant's requests from from to to in intervals
+// of the given length, aligned to the Unix epoch. Intervals without
+// requests are left out.
+func (s *Store) TenantIntervals(ctx context.Context, from, to time.Time, interval time.Duration) ([]TenantInterval, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT COALESCE(tenant, ''), to_timestamp(floor(extract(epoch FROM created_at) / $3) * $3) AS start,
+			count(*), count(*) FILTER (WHERE status_code >= 500), COALESCE(sum(total_tokens), 0)
+		FROM requests
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY 1, 2
+		ORDER BY 2
+	`, from, to, interval.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []TenantInterval
+	for rows.Next() {
+		var ti TenantInterval
+		if err := rows.Scan(&ti.Tenant, &ti.Start, &ti.Requests, &ti.Errors, &ti.Tokens); err != nil {
+			return nil, err
+		}
+		out = append(out, ti)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,135 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// maxBuffered bounds the usage writes held while the database is
+// unreachable; writes beyond it are dropped.
+const maxBuffered = 10000
+
+// bufferRetry is the first wait before buffered writes are retried. It
+// doubles on each failure, up to maxBufferRetry.
+var bufferRetry = time.Second
+
+const maxBufferRetry = 30 * time.Second
+
+// writeBuffer holds usage writes that could not reach the database and
+// replays them in order once it is back, so a database outage costs
+// neither requests nor their records.
+type writeBuffer struct {
+	mu       sync.Mutex
+	pending  []func(ctx context.Context) error
+	draining bool
+	dropped  int
+}
+
+// write runs fn, or queues it behind earlier writes still waiting for the
+// database. A write that fails because the database could not be reached
+// is queued too, and reported as done.
+func (s *Store) write(ctx context.Context, fn func(ctx context.Context) error) error {
+	s.buffer.mu.Lock()
+	waiting := len(s.buffer.pending) > 0
+	s.buffer.mu.Unlock()
+	if !waiting {
+		err := fn(ctx)
+		if err == nil || rejected(err) || ctx.Err() != nil {
+			return err
+		}
+	}
+	s.enqueue(fn)
+	return nil
+}
+
+func (s *Store) enqueue(fn func(ctx context.Context) error) {
+	b := &s.buffer
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.pending) >= maxBuffered {
+		if b.dropped == 0 {
+			log.Printf("Warning: usage write buffer full, dropping records until the database is back")
+		}
+		b.dropped++
+		return
+	}
+	b.pending = append(b.pending, fn)
+	if !b.draining {
+		b.draining = true
+		go s.drain()
+	}
+}
+
+// drain replays buffered writes, backing off while the database stays
+// unreachable.
+func (s *Store) drain() {
+	wait := bufferRetry
+	for {
+		time.Sleep(wait)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := s.flush(ctx)
+		cancel()
+		if err == nil {
+			return
+		}
+		wait = min(wait*2, maxBufferRetry)
+	}
+}
+
+// flush runs buffered writes in order until the buffer is empty or the
+// database is unreachable again. Writes the database rejects are dropped.
+func (s *Store) flush(ctx context.Context) error {
+	b := &s.buffer
+	for {
+		b.mu.Lock()
+		if len(b.pending) == 0 {
+			if b.dropped > 0 {
+				log.Printf("Usage write buffer drained; %d records were dropped while it was full", b.dropped)
+			}
+			b.draining, b.dropped = false, 0
+			b.mu.Unlock()
+			return nil
+		}
+		fn := b.pending[0]
+		b.mu.Unlock()
+
+		if err := fn(ctx); err != nil {
+			if !rejected(err) {
+				return err
+			}
+			log.Printf("Warning: dropping buffered usage write: %v", err)
+		}
+		b.mu.Lock()
+		b.pending = b.pending[1:]
+		b.mu.Unlock()
+	}
+}
+
+// Buffered returns how many usage writes are waiting for the database.
+func (s *Store) Buffered() int {
+	s.buffer.mu.Lock()
+	defer s.buffer.mu.Unlock()
+	return len(s.buffer.pending)
+}
+
+// errNoRequest is an attempt or event whose request has no row to attach
+// to, which is recorded nowhere.
+var errNoRequest = errors.New("usage: no request row for the write")
+
+// rejected reports whether a write failed for good, so that retrying it
+// cannot help: the database refused it or its request has no row.
+func rejected(err error) bool {
+	return isServerError(err) || errors.Is(err, errNoRequest)
+}
+
+// isServerError reports whether err came from the database itself, as
+// opposed to the database not being reached.
+func isServerError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr)
+}
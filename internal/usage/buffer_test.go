@@ -0,0 +1,58 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestWriteBuffer(t *testing.T) {
+	bufferRetry = time.Millisecond
+	s := &Store{}
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	down := true
+	var written []int
+	write := func(n int) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if down {
+				return errors.New("dial tcp: connection refused")
+			}
+			written = append(written, n)
+			return nil
+		}
+	}
+
+	if err := s.write(ctx, write(1)); err != nil {
+		t.Fatalf("expected an unreachable database to buffer the write, got %v", err)
+	}
+	s.write(ctx, write(2))
+	if err := s.write(ctx, func(ctx context.Context) error { return &pgconn.PgError{Code: "23505"} }); err != nil {
+		t.Fatalf("expected writes behind the buffer to queue, got %v", err)
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	deadline := time.Now().Add(time.Second)
+	for s.Buffered() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if s.Buffered() != 0 || len(written) != 2 || written[0] != 1 || written[1] != 2 {
+		t.Errorf("expected buffered writes replayed in order and rejected ones dropped, got %v (%d left)", written, s.Buffered())
+	}
+
+	// With nothing buffered, an error from the database itself is returned.
+	if err := s.write(ctx, func(ctx context.Context) error { return &pgconn.PgError{Code: "23505"} }); err == nil {
+		t.Error("expected a server error to be returned")
+	}
+}
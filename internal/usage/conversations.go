@@ -0,0 +1,168 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrConversationNotFound is returned for a conversation without requests.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// ConversationQuery selects conversations by their requests.
+type ConversationQuery struct {
+	Tenant   string
+	From     time.Time
+	To       time.Time
+	Metadata map[string]string // exact-match filters on metadata keys
+	Limit    int
+}
+
+// ConversationSummary is the usage of one conversation of a tenant.
+type ConversationSummary struct {
+	ConversationID   string    `json:"conversation_id"`
+	Tenant           string    `json:"tenant"`
+	Requests         int64     `json:"requests"`
+	Errors           int64     `json:"errors"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	TotalTokens      int64     `json:"total_tokens"`
+	CostEstimate     float64   `json:"cost_estimate_usd"`
+	Models           []string  `json:"models"`
+	FirstAt          time.Time `json:"first_at"`
+	LastAt           time.Time `json:"last_at"`
+}
+
+// ConversationTotals sums the conversations a query selects, for analytics
+// such as the average cost of a conversation.
+type ConversationTotals struct {
+	Conversations int64   `json:"conversations"`
+	Requests      int64   `json:"requests"`
+	TotalTokens   int64   `json:"total_tokens"`
+	CostEstimate  float64 `json:"cost_estimate_usd"`
+	AvgRequests   float64 `json:"avg_requests"`
+	AvgTokens     float64 `json:"avg_tokens"`
+	AvgCost       float64 `json:"avg_cost_usd"`
+}
+
+// ConversationRequest is one request of a conversation.
+type ConversationRequest struct {
+	RequestID        string    `json:"request_id"`
+	UseCase          string    `json:"use_case"`
+	RouteName        string    `json:"route_name"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	CostEstimate     float64   `json:"cost_estimate_usd"`
+	LatencyMS        int       `json:"latency_ms"`
+	StatusCode       int       `json:"status_code"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Conversation is a conversation's summary with its requests.
+type Conversation struct {
+	ConversationSummary
+	RequestList []ConversationRequest `json:"request_list"`
+}
+
+const conversationColumns = `conversation_id, COALESCE(tenant, ''), COUNT(*), COUNT(*) FILTER (WHERE status_code >= 400),
+	COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(total_tokens), 0),
+	COALESCE(SUM(cost_estimate_usd), 0)::float8,
+	COALESCE(array_agg(DISTINCT model) FILTER (WHERE model IS NOT NULL AND model <> ''), '{}'),
+	MIN(created_at), MAX(created_at)`
+
+func scanConversation(row pgx.CollectableRow) (ConversationSummary, error) {
+	var c ConversationSummary
+	err := row.Scan(&c.ConversationID, &c.Tenant, &c.Requests, &c.Errors, &c.PromptTokens, &c.CompletionTokens, &c.TotalTokens,
+		&c.CostEstimate, &c.Models, &c.FirstAt, &c.LastAt)
+	return c, err
+}
+
+// conversationFilter is the WHERE clause and arguments of q.
+func conversationFilter(q ConversationQuery) (string, []interface{}) {
+	where := []string{"conversation_id IS NOT NULL"}
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if q.Tenant != "" {
+		where = append(where, "tenant = "+arg(q.Tenant))
+	}
+	if !q.From.IsZero() {
+		where = append(where, "created_at >= "+arg(q.From))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "created_at < "+arg(q.To))
+	}
+	for k, v := range q.Metadata {
+		where = append(where, fmt.Sprintf("metadata->>%s = %s", arg(k), arg(v)))
+	}
+	return " WHERE " + strings.Join(where, " AND "), args
+}
+
+// ListConversations returns the conversations with requests in the query's
+// window, most recently active first, and the totals over all of them.
+// Requests outside the window are not counted.
+func (s *Store) ListConversations(ctx context.Context, q ConversationQuery) ([]ConversationSummary, ConversationTotals, error) {
+	where, args := conversationFilter(q)
+	var t ConversationTotals
+	err := s.db.QueryRow(ctx, `
+		SELECT COUNT(DISTINCT (tenant, conversation_id)), COUNT(*), COALESCE(SUM(total_tokens), 0), COALESCE(SUM(cost_estimate_usd), 0)::float8
+		FROM requests`+where, args...).Scan(&t.Conversations, &t.Requests, &t.TotalTokens, &t.CostEstimate)
+	if err != nil {
+		return nil, t, err
+	}
+	if t.Conversations > 0 {
+		n := float64(t.Conversations)
+		t.AvgRequests, t.AvgTokens, t.AvgCost = float64(t.Requests)/n, float64(t.TotalTokens)/n, t.CostEstimate/n
+	}
+
+	rows, err := s.db.Query(ctx, `SELECT `+conversationColumns+` FROM requests`+where+`
+		GROUP BY tenant, conversation_id ORDER BY MAX(created_at) DESC LIMIT `+fmt.Sprint(q.Limit), args...)
+	if err != nil {
+		return nil, t, err
+	}
+	list, err := pgx.CollectRows(rows, scanConversation)
+	return list, t, err
+}
+
+// GetConversation returns a tenant's conversation with its requests in
+// the order they were made.
+func (s *Store) GetConversation(ctx context.Context, tenant, id string) (Conversation, error) {
+	var c Conversation
+	rows, err := s.db.Query(ctx, `SELECT `+conversationColumns+` FROM requests
+		WHERE tenant = $1 AND conversation_id = $2 GROUP BY tenant, conversation_id`, tenant, id)
+	if err != nil {
+		return c, err
+	}
+	c.ConversationSummary, err = pgx.CollectExactlyOneRow(rows, scanConversation)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c, ErrConversationNotFound
+	}
+	if err != nil {
+		return c, err
+	}
+	rows, err = s.db.Query(ctx, `
+		SELECT request_id, COALESCE(use_case, ''), COALESCE(route_name, ''), COALESCE(provider, ''), COALESCE(model, ''),
+			COALESCE(prompt_tokens, 0), COALESCE(completion_tokens, 0), COALESCE(cost_estimate_usd, 0)::float8,
+			COALESCE(latency_ms, 0), COALESCE(status_code, 0), created_at
+		FROM requests WHERE tenant = $1 AND conversation_id = $2
+		ORDER BY created_at
+	`, tenant, id)
+	if err != nil {
+		return c, err
+	}
+	c.RequestList, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (ConversationRequest, error) {
+		var r ConversationRequest
+		err := row.Scan(&r.RequestID, &r.UseCase, &r.RouteName, &r.Provider, &r.Model, &r.PromptTokens, &r.CompletionTokens,
+			&r.CostEstimate, &r.LatencyMS, &r.StatusCode, &r.CreatedAt)
+		return r, err
+	})
+	return c, err
+}
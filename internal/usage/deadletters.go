@@ -0,0 +1,72 @@
+package usage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yewintnaing/ai-gateway/internal/webhook"
+)
+
+// ErrNoDeadLetter is returned for unknown dead letter ids.
+var ErrNoDeadLetter = errors.New("dead letter not found")
+
+func (s *Store) SaveDeadLetter(ctx context.Context, d webhook.DeadLetter) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO webhook_dead_letters (event_id, tenant, url, event, payload, secret_env, attempts, last_error, created_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, NULLIF($6, ''), $7, $8, $9)
+	`, d.EventID, d.Tenant, d.URL, d.Event, d.Payload, d.SecretEnv, d.Attempts, d.LastError, d.CreatedAt)
+	return err
+}
+
+// ListDeadLetters returns undelivered events, newest first, only those of
+// tenant unless it is empty.
+func (s *Store) ListDeadLetters(ctx context.Context, tenant string, limit int) ([]webhook.DeadLetter, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, event_id, COALESCE(tenant, ''), url, event, payload, COALESCE(secret_env, ''), attempts, last_error, created_at
+		FROM webhook_dead_letters WHERE $1 = '' OR tenant = $1
+		ORDER BY created_at DESC, id DESC LIMIT $2
+	`, tenant, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanDeadLetter)
+}
+
+// GetDeadLetter returns a dead letter without removing it.
+func (s *Store) GetDeadLetter(ctx context.Context, id int64) (webhook.DeadLetter, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, event_id, COALESCE(tenant, ''), url, event, payload, COALESCE(secret_env, ''), attempts, last_error, created_at
+		FROM webhook_dead_letters WHERE id = $1
+	`, id)
+	if err != nil {
+		return webhook.DeadLetter{}, err
+	}
+	d, err := pgx.CollectExactlyOneRow(rows, scanDeadLetter)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return d, ErrNoDeadLetter
+	}
+	return d, err
+}
+
+// TakeDeadLetter removes a dead letter and returns it for redelivery.
+func (s *Store) TakeDeadLetter(ctx context.Context, id int64) (webhook.DeadLetter, error) {
+	rows, err := s.db.Query(ctx, `
+		DELETE FROM webhook_dead_letters WHERE id = $1
+		RETURNING id, event_id, COALESCE(tenant, ''), url, event, payload, COALESCE(secret_env, ''), attempts, last_error, created_at
+	`, id)
+	if err != nil {
+		return webhook.DeadLetter{}, err
+	}
+	d, err := pgx.CollectExactlyOneRow(rows, scanDeadLetter)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return d, ErrNoDeadLetter
+	}
+	return d, err
+}
+
+func scanDeadLetter(row pgx.CollectableRow) (webhook.DeadLetter, error) {
+	var d webhook.DeadLetter
+	err := row.Scan(&d.ID, &d.EventID, &d.Tenant, &d.URL, &d.Event, &d.Payload, &d.SecretEnv, &d.Attempts, &d.LastError, &d.CreatedAt)
+	return d, err
+}
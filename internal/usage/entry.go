@@ -0,0 +1,158 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type entryKey struct{}
+
+// Entry collects the usage of one request, its record, attempts, events
+// and captured payload, and writes it in one transaction on Commit. The attempts and events then
+// always have their request row, the final record is the one written, and
+// replaying a commit, e.g. from the stage, writes nothing twice.
+type Entry struct {
+	store     *Store
+	requestID string
+	mu        sync.Mutex
+	data      entryData
+	logged    bool // anything was added
+	committed bool
+}
+
+// entryData is what an entry writes, in the form it is staged in.
+type entryData struct {
+	Record   Record
+	Attempts []Attempt
+	Events   []Event
+	Payload  *SealedPayload
+}
+
+// Begin starts an entry for request requestID. Until it is committed, Log,
+// LogAttempt and LogEvent calls for the request with the returned context
+// or one derived from it go into the entry instead of the database.
+func (s *Store) Begin(ctx context.Context, requestID string) (context.Context, *Entry) {
+	e := &Entry{store: s, requestID: requestID, data: entryData{Record: Record{RequestID: requestID}}}
+	return context.WithValue(ctx, entryKey{}, e), e
+}
+
+// addToEntry runs fn on the entry of ctx for requestID, and reports whether
+// there was one still open.
+func addToEntry(ctx context.Context, requestID string, fn func(*entryData)) bool {
+	e, _ := ctx.Value(entryKey{}).(*Entry)
+	if e == nil || e.requestID != requestID {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.committed {
+		return false
+	}
+	fn(&e.data)
+	e.logged = true
+	return true
+}
+
+// merge replaces an entry's record by a later one. Like the update of an
+// existing row, it keeps the system prompt version, metadata and
+// conversation of the earlier record when the later one has none, and
+// keeps the earlier warnings.
+func (d *entryData) merge(r Record) {
+	prev := d.Record
+	if r.SystemPromptVersion == "" {
+		r.SystemPromptVersion = prev.SystemPromptVersion
+	}
+	if r.Metadata == nil {
+		r.Metadata = prev.Metadata
+	}
+	if r.ConversationID == "" {
+		r.ConversationID = prev.ConversationID
+	}
+	warnings := prev.Warnings
+	for _, w := range r.Warnings {
+		warnings = appendNew(warnings, w)
+	}
+	r.Warnings = warnings
+	d.Record = r
+}
+
+// Commit writes the entry, staging or buffering it like other writes. Usage
+// logged for the request afterwards goes to the database on its own.
+// Committing twice writes nothing more.
+func (e *Entry) Commit(ctx context.Context) error {
+	e.mu.Lock()
+	if e.committed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.committed = true
+	data, logged := e.data, e.logged
+	e.mu.Unlock()
+	if !logged {
+		return nil
+	}
+
+	s := e.store
+	if s.staged(ctx, stagedWrite{RequestID: data.Record.RequestID, Entry: &data}) {
+		return nil
+	}
+	return s.write(ctx, func(ctx context.Context) error { return s.commit(ctx, data) })
+}
+
+// commit writes an entry in one transaction, unless a commit of the same
+// request already did.
+func (s *Store) commit(ctx context.Context, data entryData) error {
+	var written bool
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		if err := lockRequest(ctx, tx, data.Record.RequestID); err != nil {
+			return err
+		}
+		var committed bool
+		err := tx.QueryRow(ctx, `SELECT usage_committed FROM requests WHERE request_id = $1 LIMIT 1 FOR UPDATE`, data.Record.RequestID).Scan(&committed)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if committed {
+			return nil
+		}
+		if err := s.log(ctx, tx, data.Record); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE requests SET usage_committed = true WHERE request_id = $1`, data.Record.RequestID); err != nil {
+			return err
+		}
+		for _, a := range data.Attempts {
+			if err := s.logAttempt(ctx, tx, data.Record.RequestID, a); err != nil {
+				return err
+			}
+		}
+		for _, ev := range data.Events {
+			if err := s.logEvent(ctx, tx, data.Record.RequestID, ev); err != nil {
+				return err
+			}
+		}
+		if data.Payload != nil {
+			if err := savePayload(ctx, tx, data.Record.RequestID, *data.Payload); err != nil {
+				return err
+			}
+		}
+		written = true
+		return nil
+	})
+	if err == nil && written {
+		s.logged(ctx, data.Record)
+	}
+	return err
+}
+
+// appendNew appends s to list unless it is already there.
+func appendNew(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
@@ -0,0 +1,57 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestEntry(t *testing.T) {
+	stage := &memoryStage{}
+	s := &Store{}
+	s.stage.stage = stage
+	ctx, entry := s.Begin(context.Background(), "r1")
+
+	if err := entry.Commit(ctx); err != nil || len(stage.writes) != 0 {
+		t.Fatalf("expected an empty entry to write nothing, got %v and %d writes", err, len(stage.writes))
+	}
+
+	ctx, entry = s.Begin(context.Background(), "r1")
+	s.Log(ctx, Record{RequestID: "r1", Tenant: "acme", SystemPromptVersion: "v2", Metadata: map[string]interface{}{"team": "core"}})
+	s.LogAttempt(ctx, "r1", Attempt{AttemptNo: 1, StatusCode: 503})
+	s.LogAttempt(ctx, "r1", Attempt{AttemptNo: 2, StatusCode: 200})
+	s.LogEvent(ctx, "r1", Event{Kind: "repair"})
+	s.SavePayload(ctx, "r1", SealedPayload{Tenant: "acme", DataKeyID: 4})
+	s.Log(ctx, Record{RequestID: "r1", Tenant: "acme", Provider: "openai", StatusCode: 200})
+	if addToEntry(ctx, "r2", func(*entryData) {}) {
+		t.Error("expected another request's usage kept out of the entry")
+	}
+	if len(stage.writes) != 0 {
+		t.Fatal("expected nothing written before the commit")
+	}
+
+	if err := entry.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	entry.Commit(ctx)
+	if len(stage.writes) != 1 {
+		t.Fatalf("expected the entry staged once, got %d writes", len(stage.writes))
+	}
+	var w stagedWrite
+	if err := json.Unmarshal(stage.writes[0].Data, &w); err != nil || w.Entry == nil {
+		t.Fatalf("unexpected staged write %s", stage.writes[0].Data)
+	}
+	r := w.Entry.Record
+	if r.Provider != "openai" || r.StatusCode != 200 || r.SystemPromptVersion != "v2" || r.Metadata["team"] != "core" {
+		t.Errorf("expected the final record with the earlier prompt version and metadata, got %+v", r)
+	}
+	if len(w.Entry.Attempts) != 2 || w.Entry.Attempts[1].AttemptNo != 2 || len(w.Entry.Events) != 1 || w.Entry.Payload == nil {
+		t.Errorf("unexpected entry %+v", w.Entry)
+	}
+
+	// Once committed, usage of the request is written on its own.
+	s.LogEvent(ctx, "r1", Event{Kind: "late"})
+	if len(stage.writes) != 2 {
+		t.Errorf("expected a late event staged on its own, got %d writes", len(stage.writes))
+	}
+}
@@ -0,0 +1,94 @@
+package usage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yewintnaing/ai-gateway/internal/eval"
+)
+
+// ErrEvalRunNotFound is returned for an unknown eval run id.
+var ErrEvalRunNotFound = errors.New("eval run not found")
+
+func (s *Store) CreateEvalRun(ctx context.Context, run eval.Run) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO eval_runs (id, dataset, route_name, provider, model, status, cases, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8)
+	`, run.ID, run.Dataset, run.Route, run.Provider, run.Model, run.Status, run.Cases, run.CreatedAt)
+	return err
+}
+
+func (s *Store) RecordEvalResult(ctx context.Context, runID string, r eval.Result) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO eval_results (run_id, case_name, request_id, provider, model, content, latency_ms,
+			prompt_tokens, completion_tokens, cost_usd, score, passed, similarity, failures)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8, $9, $10, $11, $12, $13, COALESCE($14::text[], '{}'))
+	`, runID, r.Case, r.RequestID, r.Provider, r.Model, r.Content, r.LatencyMS,
+		r.PromptTokens, r.CompletionTokens, r.CostUSD, r.Score, r.Passed, r.Similarity, r.Failures)
+	return err
+}
+
+func (s *Store) FinishEvalRun(ctx context.Context, run eval.Run) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE eval_runs SET status = $2, error = NULLIF($3, ''), passed = $4, score = $5, avg_latency_ms = $6,
+			prompt_tokens = $7, completion_tokens = $8, cost_usd = $9, finished_at = $10
+		WHERE id = $1
+	`, run.ID, run.Status, run.Error, run.Passed, run.Score, run.AvgLatencyMS,
+		run.PromptTokens, run.CompletionTokens, run.CostUSD, run.FinishedAt)
+	return err
+}
+
+const evalRunColumns = `id, dataset, route_name, COALESCE(provider, ''), COALESCE(model, ''), status, COALESCE(error, ''),
+	cases, passed, score, avg_latency_ms, prompt_tokens, completion_tokens, cost_usd, created_at, finished_at`
+
+func scanEvalRun(row pgx.CollectableRow) (eval.Run, error) {
+	var r eval.Run
+	err := row.Scan(&r.ID, &r.Dataset, &r.Route, &r.Provider, &r.Model, &r.Status, &r.Error,
+		&r.Cases, &r.Passed, &r.Score, &r.AvgLatencyMS, &r.PromptTokens, &r.CompletionTokens, &r.CostUSD, &r.CreatedAt, &r.FinishedAt)
+	return r, err
+}
+
+// ListEvalRuns returns eval runs without their results, newest first, only
+// those of dataset when it is not empty.
+func (s *Store) ListEvalRuns(ctx context.Context, dataset string, limit int) ([]eval.Run, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+evalRunColumns+` FROM eval_runs
+		WHERE ($1 = '' OR dataset = $1)
+		ORDER BY created_at DESC LIMIT $2
+	`, dataset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanEvalRun)
+}
+
+// GetEvalRun returns an eval run with the results recorded so far.
+func (s *Store) GetEvalRun(ctx context.Context, id string) (eval.Run, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+evalRunColumns+` FROM eval_runs WHERE id = $1`, id)
+	if err != nil {
+		return eval.Run{}, err
+	}
+	run, err := pgx.CollectExactlyOneRow(rows, scanEvalRun)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return run, ErrEvalRunNotFound
+	}
+	if err != nil {
+		return run, err
+	}
+	rows, err = s.db.Query(ctx, `
+		SELECT case_name, COALESCE(request_id, ''), COALESCE(provider, ''), COALESCE(model, ''), content, latency_ms,
+			prompt_tokens, completion_tokens, cost_usd, score, passed, similarity, failures
+		FROM eval_results WHERE run_id = $1 ORDER BY id
+	`, id)
+	if err != nil {
+		return run, err
+	}
+	run.Results, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (eval.Result, error) {
+		var r eval.Result
+		err := row.Scan(&r.Case, &r.RequestID, &r.Provider, &r.Model, &r.Content, &r.LatencyMS,
+			&r.PromptTokens, &r.CompletionTokens, &r.CostUSD, &r.Score, &r.Passed, &r.Similarity, &r.Failures)
+		return r, err
+	})
+	return run, err
+}
@@ -0,0 +1,99 @@
+package usage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// APIKey is a tenant API key as listed to its owner; the secret itself is
+// only returned when the key is created.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Tenant     string     `json:"tenant"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ErrInvalidKey is returned for unknown or revoked API keys.
+var ErrInvalidKey = errors.New("invalid API key")
+
+// keyPrefix marks gateway keys so they are easy to spot in leaked text.
+const keyPrefix = "gwk_"
+
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey issues a key for tenant and returns it with its secret. Only
+// a hash of the secret is stored.
+func (s *Store) CreateAPIKey(ctx context.Context, tenant, name string) (APIKey, string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return APIKey{}, "", err
+	}
+	secret := keyPrefix + hex.EncodeToString(buf)
+	k := APIKey{Tenant: tenant, Name: name, Prefix: secret[:len(keyPrefix)+8]}
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO tenant_api_keys (tenant, name, prefix, key_hash) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, tenant, name, k.Prefix, hashKey(secret)).Scan(&k.ID, &k.CreatedAt)
+	return k, secret, err
+}
+
+func (s *Store) ListAPIKeys(ctx context.Context, tenant string) ([]APIKey, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, tenant, name, prefix, created_at, last_used_at, revoked_at
+		FROM tenant_api_keys WHERE tenant = $1 ORDER BY id
+	`, tenant)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (APIKey, error) {
+		var k APIKey
+		err := row.Scan(&k.ID, &k.Tenant, &k.Name, &k.Prefix, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+		return k, err
+	})
+}
+
+// RevokeAPIKey disables a key and reports whether an active one existed.
+func (s *Store) RevokeAPIKey(ctx context.Context, tenant string, id int64) (bool, error) {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE tenant_api_keys SET revoked_at = NOW()
+		WHERE tenant = $1 AND id = $2 AND revoked_at IS NULL
+	`, tenant, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// AuthenticateAPIKey returns the active key matching secret, recording its
+// use.
+func (s *Store) AuthenticateAPIKey(ctx context.Context, secret string) (APIKey, error) {
+	var k APIKey
+	err := s.db.QueryRow(ctx, `
+		UPDATE tenant_api_keys SET last_used_at = NOW()
+		WHERE key_hash = $1 AND revoked_at IS NULL
+		RETURNING id, tenant, name, prefix, created_at, last_used_at
+	`, hashKey(secret)).Scan(&k.ID, &k.Tenant, &k.Name, &k.Prefix, &k.CreatedAt, &k.LastUsedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return APIKey{}, ErrInvalidKey
+	}
+	return k, err
+}
+
+// IsAPIKey reports whether a bearer credential looks like a gateway key.
+func IsAPIKey(secret string) bool {
+	return strings.HasPrefix(secret, keyPrefix)
+}
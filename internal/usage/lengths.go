@@ -0,0 +1,20 @@
+package usage
+
+import (
+	"context"
+	"time"
+)
+
+// CompletionLength returns the completion length at percentile among
+// successful requests for useCase since since, and how many requests it
+// was computed from. Completions the gateway cut short are left out, as
+// their length says nothing about what the request needed.
+func (s *Store) CompletionLength(ctx context.Context, useCase string, percentile float64, since time.Time) (tokens, samples int, err error) {
+	err = s.db.QueryRow(ctx, `
+		SELECT COALESCE(percentile_disc($2) WITHIN GROUP (ORDER BY completion_tokens), 0), COUNT(*)
+		FROM requests
+		WHERE use_case = $1 AND created_at >= $3 AND status_code = 200
+			AND completion_tokens > 0 AND NOT COALESCE(truncated, false)
+	`, useCase, percentile, since).Scan(&tokens, &samples)
+	return tokens, samples, err
+}
@@ -0,0 +1,315 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PartitionedTables are the usage tables partitioned by month of
+// created_at.
+var PartitionedTables = []string{"requests", "provider_attempts"}
+
+// Partition is one partition of a usage table, covering rows created from
+// From up to To. From is zero for the partition holding the rows from
+// before partitioning.
+type Partition struct {
+	Table string
+	Name  string
+	From  time.Time
+	To    time.Time
+}
+
+// monthlyPartition returns table's partition for the month starting at
+// month.
+func monthlyPartition(table string, month time.Time) Partition {
+	return Partition{
+		Table: table,
+		Name:  fmt.Sprintf("%s_y%04dm%02d", table, month.Year(), month.Month()),
+		From:  month,
+		To:    month.AddDate(0, 1, 0),
+	}
+}
+
+// parsePartition reads a partition's range from its name: table_yYYYYmMM
+// for a month, or table_before_yYYYYmMM for the rows before one.
+func parsePartition(table, name string) (Partition, bool) {
+	rest, ok := strings.CutPrefix(name, table+"_")
+	if !ok {
+		return Partition{}, false
+	}
+	rest, before := strings.CutPrefix(rest, "before_")
+	month, err := time.Parse("y2006m01", rest)
+	if err != nil {
+		return Partition{}, false
+	}
+	if before {
+		return Partition{Table: table, Name: name, To: month}, true
+	}
+	return monthlyPartition(table, month), true
+}
+
+// Partitions lists table's partitions, oldest first. A table that is not
+// partitioned has none.
+func (s *Store) Partitions(ctx context.Context, table string) ([]Partition, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass($1)
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var parts []Partition
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if p, ok := parsePartition(table, name); ok {
+			parts = append(parts, p)
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].To.Before(parts[j].To) })
+	return parts, rows.Err()
+}
+
+// EnsurePartitions creates table's monthly partitions from the end of the
+// last one through ahead months after now's, and returns the names of
+// those it created. It does nothing for a table that is not partitioned.
+func (s *Store) EnsurePartitions(ctx context.Context, table string, now time.Time, ahead int) ([]string, error) {
+	var partitioned bool
+	err := s.db.QueryRow(ctx, `SELECT COALESCE((SELECT relkind = 'p' FROM pg_class WHERE oid = to_regclass($1)), false)`, table).Scan(&partitioned)
+	if err != nil || !partitioned {
+		return nil, err
+	}
+	parts, err := s.Partitions(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	now = now.UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	until := month.AddDate(0, ahead+1, 0)
+	if len(parts) > 0 {
+		month = parts[len(parts)-1].To
+	}
+	var created []string
+	for ; month.Before(until); month = month.AddDate(0, 1, 0) {
+		p := monthlyPartition(table, month)
+		if err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error { return createPartition(ctx, tx, p) }); err != nil {
+			return created, fmt.Errorf("creating partition %s: %w", p.Name, err)
+		}
+		created = append(created, p.Name)
+	}
+	return created, nil
+}
+
+// createPartition adds p to its table, moving into it any rows of its
+// range the table's default partition took before it existed.
+func createPartition(ctx context.Context, tx pgx.Tx, p Partition) error {
+	name, table := pgx.Identifier{p.Name}.Sanitize(), pgx.Identifier{p.Table}.Sanitize()
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS)`, name, table)); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, fmt.Sprintf(`
+		WITH moved AS (DELETE FROM %s WHERE created_at >= $1 AND created_at < $2 RETURNING *)
+		INSERT INTO %s SELECT * FROM moved
+	`, pgx.Identifier{p.Table + "_default"}.Sanitize(), name), p.From, p.To)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, fmt.Sprintf(`ALTER TABLE %s ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')`,
+		table, name, p.From.Format(time.RFC3339), p.To.Format(time.RFC3339)))
+	return err
+}
+
+// ArchiveColumn is a column of an exported partition. Type is the type
+// its values are read as: "text" (string), "bigint" (int64), "float8"
+// (float64), "boolean" (bool) or "timestamptz" (time.Time).
+type ArchiveColumn struct {
+	Name string
+	Type string
+}
+
+// archiveType returns the type a column of dataType is exported as.
+func archiveType(dataType string) string {
+	switch dataType {
+	case "smallint", "integer", "bigint":
+		return "bigint"
+	case "numeric", "real", "double precision":
+		return "float8"
+	case "boolean":
+		return "boolean"
+	case "timestamp with time zone", "timestamp without time zone":
+		return "timestamptz"
+	default:
+		return "text"
+	}
+}
+
+// ExportPartition reads every row of p, with the columns retention
+// anonymizes left NULL. columns is called once with the partition's
+// columns, then row with each row's values, nil for NULL.
+func (s *Store) ExportPartition(ctx context.Context, p Partition, columns func([]ArchiveColumn) error, row func([]interface{}) error) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT column_name, data_type FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position
+	`, p.Name)
+	if err != nil {
+		return err
+	}
+	var cols []ArchiveColumn
+	var selects []string
+	for rows.Next() {
+		var name, dataType string
+		if err := rows.Scan(&name, &dataType); err != nil {
+			rows.Close()
+			return err
+		}
+		c := ArchiveColumn{Name: name, Type: archiveType(dataType)}
+		cols = append(cols, c)
+		if slices.Contains(retentionTables[p.Table].personal, name) {
+			selects = append(selects, "NULL::"+c.Type)
+			continue
+		}
+		selects = append(selects, pgx.Identifier{name}.Sanitize()+"::"+c.Type)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(cols) == 0 {
+		return fmt.Errorf("partition %s not found", p.Name)
+	}
+	if err := columns(cols); err != nil {
+		return err
+	}
+
+	rows, err = s.db.Query(ctx, fmt.Sprintf(`SELECT %s FROM %s`, strings.Join(selects, ", "), pgx.Identifier{p.Name}.Sanitize()))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return err
+		}
+		if err := row(values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Archive records a partition exported to the archive sink.
+type Archive struct {
+	Table      string     `json:"table"`
+	Partition  string     `json:"partition"`
+	Object     string     `json:"object"`
+	Rows       int64      `json:"rows"`
+	ArchivedAt time.Time  `json:"archived_at"`
+	DroppedAt  *time.Time `json:"dropped_at,omitempty"`
+}
+
+// RecordArchive stores a, replacing an earlier record of the same
+// partition.
+func (s *Store) RecordArchive(ctx context.Context, a Archive) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO usage_archives (table_name, partition_name, object_name, row_count, archived_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (partition_name) DO UPDATE SET
+			object_name = EXCLUDED.object_name, row_count = EXCLUDED.row_count, archived_at = EXCLUDED.archived_at
+	`, a.Table, a.Partition, a.Object, a.Rows, a.ArchivedAt)
+	return err
+}
+
+// DetachPartition takes p out of its table, so its rows no longer appear
+// in queries but can still be read, or attached again, until it is
+// dropped. Its rows are anonymized first, as their export is, and for
+// requests their payloads deleted and events anonymized: end-user
+// deletion no longer finds them once they are detached.
+func (s *Store) DetachPartition(ctx context.Context, p Partition) error {
+	t := retentionTables[p.Table]
+	name := pgx.Identifier{p.Name}.Sanitize()
+	return pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`UPDATE %s SET %s WHERE %s`, name, t.anonymizeSet(), t.anonymizeWhere())); err != nil {
+			return err
+		}
+		for _, child := range t.children {
+			if slices.Contains(PartitionedTables, child) {
+				continue // archived on its own
+			}
+			c := retentionTables[child]
+			stmt := fmt.Sprintf(`DELETE FROM %s WHERE request_id IN (SELECT id FROM %s)`, child, name)
+			if len(c.personal) > 0 {
+				stmt = fmt.Sprintf(`UPDATE %s SET %s WHERE request_id IN (SELECT id FROM %s) AND %s`, child, c.anonymizeSet(), name, c.anonymizeWhere())
+			}
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(ctx, fmt.Sprintf(`ALTER TABLE %s DETACH PARTITION %s`, pgx.Identifier{p.Table}.Sanitize(), name))
+		return err
+	})
+}
+
+// DroppableArchives returns the archived partitions not yet dropped that
+// were archived before cutoff.
+func (s *Store) DroppableArchives(ctx context.Context, cutoff time.Time) ([]Archive, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT table_name, partition_name, object_name, row_count, archived_at FROM usage_archives
+		WHERE dropped_at IS NULL AND archived_at < $1
+		ORDER BY archived_at
+	`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Archive
+	for rows.Next() {
+		var a Archive
+		if err := rows.Scan(&a.Table, &a.Partition, &a.Object, &a.Rows, &a.ArchivedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// DropArchived drops an archived partition and records when it was
+// dropped.
+func (s *Store) DropArchived(ctx context.Context, a Archive) error {
+	if _, err := s.db.Exec(ctx, fmt.Sprintf(`DROP TABLE IF EXISTS %s`, pgx.Identifier{a.Partition}.Sanitize())); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(ctx, `UPDATE usage_archives SET dropped_at = NOW() WHERE partition_name = $1`, a.Partition)
+	return err
+}
+
+// ListArchives returns the archived partitions, newest first.
+func (s *Store) ListArchives(ctx context.Context) ([]Archive, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT table_name, partition_name, object_name, row_count, archived_at, dropped_at FROM usage_archives
+		ORDER BY archived_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Archive
+	for rows.Next() {
+		var a Archive
+		if err := rows.Scan(&a.Table, &a.Partition, &a.Object, &a.Rows, &a.ArchivedAt, &a.DroppedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,30 @@
+package usage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePartition(t *testing.T) {
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		want Partition
+		ok   bool
+	}{
+		{"requests_y2024m03", Partition{Table: "requests", Name: "requests_y2024m03", From: march, To: march.AddDate(0, 1, 0)}, true},
+		{"requests_before_y2024m03", Partition{Table: "requests", Name: "requests_before_y2024m03", To: march}, true},
+		{"requests_archive", Partition{}, false},
+		{"requests_default", Partition{}, false},
+		{"provider_attempts_y2024m03", Partition{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePartition("requests", tt.name)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parsePartition(%q) = %+v, %v; want %+v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+	if p := monthlyPartition("requests", march); p.Name != "requests_y2024m03" {
+		t.Errorf("unexpected name %s", p.Name)
+	}
+}
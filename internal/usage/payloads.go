@@ -0,0 +1,85 @@
+package usage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yewintnaing/ai-gateway/internal/payloads"
+)
+
+// ErrNoPayload is returned when no payload was captured for a request.
+var ErrNoPayload = errors.New("no payload captured")
+
+// SealedPayload is a captured request payload as stored, encrypted with the
+// tenant's data key DataKeyID.
+type SealedPayload struct {
+	Tenant     string
+	DataKeyID  int64
+	Ciphertext []byte
+}
+
+func (s *Store) ActiveDataKey(ctx context.Context, tenant string) (payloads.DataKey, error) {
+	k := payloads.DataKey{Tenant: tenant}
+	err := s.db.QueryRow(ctx, `
+		SELECT id, key_ref, wrapped_key FROM tenant_data_keys
+		WHERE tenant = $1 AND retired_at IS NULL
+	`, tenant).Scan(&k.ID, &k.KeyRef, &k.Wrapped)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return k, payloads.ErrNoDataKey
+	}
+	return k, err
+}
+
+func (s *Store) CreateDataKey(ctx context.Context, k payloads.DataKey) (payloads.DataKey, error) {
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO tenant_data_keys (tenant, key_ref, wrapped_key) VALUES ($1, $2, $3)
+		ON CONFLICT (tenant) WHERE retired_at IS NULL DO NOTHING
+		RETURNING id
+	`, k.Tenant, k.KeyRef, k.Wrapped).Scan(&k.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Another instance created the tenant's key first.
+		return s.ActiveDataKey(ctx, k.Tenant)
+	}
+	return k, err
+}
+
+func (s *Store) DataKey(ctx context.Context, id int64) (payloads.DataKey, error) {
+	k := payloads.DataKey{ID: id}
+	err := s.db.QueryRow(ctx, `
+		SELECT tenant, key_ref, wrapped_key FROM tenant_data_keys WHERE id = $1
+	`, id).Scan(&k.Tenant, &k.KeyRef, &k.Wrapped)
+	return k, err
+}
+
+// SavePayload stores the encrypted payload of a logged request, in the
+// request's entry when ctx carries one still open.
+func (s *Store) SavePayload(ctx context.Context, requestID string, p SealedPayload) error {
+	if addToEntry(ctx, requestID, func(d *entryData) { d.Payload = &p }) {
+		return nil
+	}
+	return savePayload(ctx, s.db, requestID, p)
+}
+
+func savePayload(ctx context.Context, db execer, requestID string, p SealedPayload) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO request_payloads (request_id, tenant, data_key_id, ciphertext)
+		SELECT id, $2, $3, $4 FROM requests WHERE request_id = $1 LIMIT 1
+		ON CONFLICT (request_id) DO UPDATE SET
+			tenant = EXCLUDED.tenant, data_key_id = EXCLUDED.data_key_id, ciphertext = EXCLUDED.ciphertext
+	`, requestID, p.Tenant, p.DataKeyID, p.Ciphertext)
+	return err
+}
+
+func (s *Store) Payload(ctx context.Context, requestID string) (SealedPayload, error) {
+	var p SealedPayload
+	err := s.db.QueryRow(ctx, `
+		SELECT p.tenant, p.data_key_id, p.ciphertext
+		FROM request_payloads p JOIN requests r ON r.id = p.request_id
+		WHERE r.request_id = $1
+	`, requestID).Scan(&p.Tenant, &p.DataKeyID, &p.Ciphertext)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return p, ErrNoPayload
+	}
+	return p, err
+}
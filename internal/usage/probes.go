@@ -0,0 +1,35 @@
+package usage
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yewintnaing/ai-gateway/internal/probe"
+)
+
+func (s *Store) RecordProbe(ctx context.Context, r probe.Result) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO probe_results (probe, route_name, provider, model, request_id, latency_ms, passed, failure, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, NULLIF($8, ''), $9)
+	`, r.Probe, r.Route, r.Provider, r.Model, r.RequestID, r.LatencyMS, r.Passed, r.Failure, r.CreatedAt)
+	return err
+}
+
+// ListProbeResults returns probe results, newest first, only those of
+// probe and route when they are not empty, and only failures if failed.
+func (s *Store) ListProbeResults(ctx context.Context, probeName, route string, failed bool, limit int) ([]probe.Result, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT probe, route_name, provider, model, COALESCE(request_id, ''), latency_ms, passed, COALESCE(failure, ''), created_at
+		FROM probe_results
+		WHERE ($1 = '' OR probe = $1) AND ($2 = '' OR route_name = $2) AND (NOT $3 OR NOT passed)
+		ORDER BY created_at DESC, id DESC LIMIT $4
+	`, probeName, route, failed, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (probe.Result, error) {
+		var r probe.Result
+		err := row.Scan(&r.Probe, &r.Route, &r.Provider, &r.Model, &r.RequestID, &r.LatencyMS, &r.Passed, &r.Failure, &r.CreatedAt)
+		return r, err
+	})
+}
@@ -0,0 +1,68 @@
+package usage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yewintnaing/ai-gateway/internal/ratelimit"
+)
+
+const overrideColumns = `tenant, exempt, burst_tokens, burst_refill_per_minute, expires_at, reason, updated_at`
+
+func scanOverride(row pgx.CollectableRow) (ratelimit.Override, error) {
+	var o ratelimit.Override
+	err := row.Scan(&o.Tenant, &o.Exempt, &o.BurstTokens, &o.BurstRefillPerMinute, &o.ExpiresAt, &o.Reason, &o.UpdatedAt)
+	return o, err
+}
+
+// ListRateLimitOverrides returns every tenant's override, expired ones
+// included.
+func (s *Store) ListRateLimitOverrides(ctx context.Context) ([]ratelimit.Override, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+overrideColumns+` FROM rate_limit_overrides ORDER BY tenant`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, scanOverride)
+}
+
+// GetRateLimitOverride returns a tenant's override and whether it has one.
+// It is a ratelimit.OverrideLoader.
+func (s *Store) GetRateLimitOverride(ctx context.Context, tenant string) (ratelimit.Override, bool, error) {
+	rows, err := s.db.Query(ctx, `SELECT `+overrideColumns+` FROM rate_limit_overrides WHERE tenant = $1`, tenant)
+	if err != nil {
+		return ratelimit.Override{}, false, err
+	}
+	o, err := pgx.CollectExactlyOneRow(rows, scanOverride)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return o, false, nil
+	}
+	return o, err == nil, err
+}
+
+// PutRateLimitOverride creates or replaces a tenant's override, which
+// starts a fresh burst credit pool, and returns it as stored.
+func (s *Store) PutRateLimitOverride(ctx context.Context, o ratelimit.Override) (ratelimit.Override, error) {
+	rows, err := s.db.Query(ctx, `
+		INSERT INTO rate_limit_overrides (tenant, exempt, burst_tokens, burst_refill_per_minute, expires_at, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (tenant) DO UPDATE SET exempt = EXCLUDED.exempt, burst_tokens = EXCLUDED.burst_tokens,
+			burst_refill_per_minute = EXCLUDED.burst_refill_per_minute, expires_at = EXCLUDED.expires_at,
+			reason = EXCLUDED.reason, updated_at = NOW()
+		RETURNING `+overrideColumns,
+		o.Tenant, o.Exempt, o.BurstTokens, o.BurstRefillPerMinute, o.ExpiresAt, o.Reason)
+	if err != nil {
+		return ratelimit.Override{}, err
+	}
+	return pgx.CollectExactlyOneRow(rows, scanOverride)
+}
+
+// DeleteRateLimitOverride removes a tenant's override and reports whether
+// it existed.
+func (s *Store) DeleteRateLimitOverride(ctx context.Context, tenant string) (bool, error) {
+	tag, err := s.db.Exec(ctx, `DELETE FROM rate_limit_overrides WHERE tenant = $1`, tenant)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,88 @@
+package usage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Reconciliation compares one provider account's billed cost for a UTC day
+// with the gateway's estimate.
+type Reconciliation struct {
+	Provider    string    `json:"provider"`
+	Day         string    `json:"day"`
+	ProviderUSD float64   `json:"provider_usd"`
+	GatewayUSD  float64   `json:"gateway_usd"`
+	DiffUSD     float64   `json:"diff_usd"`
+	DiffRatio   float64   `json:"diff_ratio"`
+	Flagged     bool      `json:"flagged"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// DailyCosts sums the estimated cost of requests served by providers per
+// UTC day. Consensus requests count through their billed attempts.
+func (s *Store) DailyCosts(ctx context.Context, providers []string, from, to time.Time) (map[string]float64, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD'), COALESCE(SUM(cost), 0)::float8
+		FROM (
+			SELECT created_at, cost_estimate_usd AS cost FROM requests
+			WHERE provider = ANY($1) AND created_at >= $2 AND created_at < $3
+			UNION ALL
+			SELECT a.created_at, a.cost_estimate_usd FROM provider_attempts a
+			JOIN requests r ON r.id = a.request_id
+			WHERE r.provider = 'consensus' AND a.provider = ANY($1) AND a.created_at >= $2 AND a.created_at < $3
+		) costs
+		GROUP BY 1
+	`, providers, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	costs := make(map[string]float64)
+	for rows.Next() {
+		var day string
+		var cost float64
+		if err := rows.Scan(&day, &cost); err != nil {
+			return nil, err
+		}
+		costs[day] = cost
+	}
+	return costs, rows.Err()
+}
+
+// SaveReconciliation stores results, replacing earlier checks of the same
+// provider and day.
+func (s *Store) SaveReconciliation(ctx context.Context, recs []Reconciliation) error {
+	batch := &pgx.Batch{}
+	for _, r := range recs {
+		batch.Queue(`
+			INSERT INTO usage_reconciliations (provider, day, provider_usd, gateway_usd, diff_usd, diff_ratio, flagged, checked_at)
+			VALUES ($1, $2::date, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (provider, day) DO UPDATE SET
+				provider_usd = EXCLUDED.provider_usd, gateway_usd = EXCLUDED.gateway_usd,
+				diff_usd = EXCLUDED.diff_usd, diff_ratio = EXCLUDED.diff_ratio,
+				flagged = EXCLUDED.flagged, checked_at = EXCLUDED.checked_at
+		`, r.Provider, r.Day, r.ProviderUSD, r.GatewayUSD, r.DiffUSD, r.DiffRatio, r.Flagged, r.CheckedAt)
+	}
+	return s.db.SendBatch(ctx, batch).Close()
+}
+
+// ListReconciliations returns the latest results, newest day first, only
+// flagged ones if flaggedOnly.
+func (s *Store) ListReconciliations(ctx context.Context, flaggedOnly bool, limit int) ([]Reconciliation, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT provider, to_char(day, 'YYYY-MM-DD'), provider_usd::float8, gateway_usd::float8,
+			diff_usd::float8, diff_ratio::float8, flagged, checked_at
+		FROM usage_reconciliations WHERE NOT $1 OR flagged
+		ORDER BY day DESC, provider LIMIT $2
+	`, flaggedOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Reconciliation, error) {
+		var r Reconciliation
+		err := row.Scan(&r.Provider, &r.Day, &r.ProviderUSD, &r.GatewayUSD, &r.DiffUSD, &r.DiffRatio, &r.Flagged, &r.CheckedAt)
+		return r, err
+	})
+}
@@ -0,0 +1,122 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	stageStream   = "usage:writes"
+	stageGroup    = "drain"
+	stageConsumer = "drainer"
+	stageLeaseKey = "usage:writes:lease"
+)
+
+// renewLeaseLua takes the drain lease when it is free or already held by
+// the caller. KEYS: lease. ARGV: owner, ttl (ms).
+var renewLeaseLua = redis.NewScript(`
+local holder = redis.call("GET", KEYS[1])
+if holder and holder ~= ARGV[1] then
+    return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return 1
+`)
+
+// RedisStage stages usage writes in a Redis stream. Drained writes are
+// deleted from it, so its length is the backlog. All drainers read as one
+// consumer of one group: the lease decides which of them that is, and the
+// group's pending list keeps what a drainer read but never acknowledged
+// for the next.
+type RedisStage struct {
+	client *redis.Client
+}
+
+func NewRedisStage(redisURL string) (*RedisStage, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStage{client: redis.NewClient(opts)}, nil
+}
+
+func (s *RedisStage) Add(ctx context.Context, data []byte) error {
+	return s.client.XAdd(ctx, &redis.XAddArgs{Stream: stageStream, Values: []string{"w", string(data)}}).Err()
+}
+
+func (s *RedisStage) Lease(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	n, err := renewLeaseLua.Run(ctx, s.client, []string{stageLeaseKey}, owner, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (s *RedisStage) Read(ctx context.Context, n int, block time.Duration) ([]StagedWrite, error) {
+	err := s.client.XGroupCreateMkStream(ctx, stageStream, stageGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, err
+	}
+	// Writes read before but never acknowledged, then new ones.
+	for _, start := range []string{"0", ">"} {
+		args := &redis.XReadGroupArgs{Group: stageGroup, Consumer: stageConsumer, Streams: []string{stageStream, start}, Count: int64(n), Block: -1}
+		if start == ">" {
+			args.Block = block
+		}
+		streams, err := s.client.XReadGroup(ctx, args).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var out []StagedWrite
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				data, _ := msg.Values["w"].(string)
+				out = append(out, StagedWrite{ID: msg.ID, Data: []byte(data)})
+			}
+		}
+		if len(out) > 0 {
+			return out, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *RedisStage) Ack(ctx context.Context, ids ...string) error {
+	pipe := s.client.TxPipeline()
+	pipe.XAck(ctx, stageStream, stageGroup, ids...)
+	pipe.XDel(ctx, stageStream, ids...)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisStage) Lag(ctx context.Context) (StageLag, error) {
+	var lag StageLag
+	n, err := s.client.XLen(ctx, stageStream).Result()
+	if err != nil || n == 0 {
+		return lag, err
+	}
+	lag.Pending = n
+	first, err := s.client.XRangeN(ctx, stageStream, "-", "+", 1).Result()
+	if err != nil || len(first) == 0 {
+		return lag, err
+	}
+	// Stream IDs start with the unix milliseconds they were added at.
+	ms, _, _ := strings.Cut(first[0].ID, "-")
+	if added, err := strconv.ParseInt(ms, 10, 64); err == nil {
+		lag.OldestAge = max(time.Since(time.UnixMilli(added)), 0)
+	}
+	return lag, nil
+}
+
+// Ping checks that Redis answers.
+func (s *RedisStage) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *RedisStage) Close() {
+	s.client.Close()
+}
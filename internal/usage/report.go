@@ -0,0 +1,151 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// groupColumns are the request columns usage can be grouped by directly.
+// Any other group key is looked up in the metadata JSONB.
+var groupColumns = map[string]bool{
+	"tenant": true, "use_case": true, "route_name": true, "provider": true, "model": true,
+	"conversation_id": true,
+}
+
+// UsageQuery selects and groups request rows for usage reporting.
+type UsageQuery struct {
+	Tenant   string
+	From     time.Time
+	To       time.Time
+	GroupBy  string
+	Metadata map[string]string // exact-match filters on metadata keys
+}
+
+type UsageRow struct {
+	Group            string  `json:"group"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	CostEstimate     float64 `json:"cost_estimate_usd"`
+	// Reasoning is the part of the completion tokens and cost spent on
+	// reasoning.
+	ReasoningTokens int64   `json:"reasoning_tokens"`
+	ReasoningCost   float64 `json:"reasoning_cost_usd"`
+	// The estimated split of prompt tokens by role, and the cost of the
+	// system share: the prompt overhead repeated on every request.
+	SystemTokens  int64   `json:"system_tokens"`
+	UserTokens    int64   `json:"user_tokens"`
+	HistoryTokens int64   `json:"history_tokens"`
+	SystemCost    float64 `json:"system_cost_usd"`
+}
+
+// ChargebackRow is one tenant/cost-center line of a chargeback report.
+type ChargebackRow struct {
+	Tenant           string  `json:"tenant"`
+	CostCenter       string  `json:"cost_center"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	CostEstimate     float64 `json:"cost_estimate_usd"`
+}
+
+// Chargeback aggregates usage in [from, to) per tenant and per value of the
+// given metadata key.
+func (s *Store) Chargeback(ctx context.Context, from, to time.Time, costCenterKey string) ([]ChargebackRow, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT COALESCE(tenant, ''), COALESCE(metadata->>$3, ''), COUNT(*),
+			COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(total_tokens), 0),
+			COALESCE(SUM(cost_estimate_usd), 0)::float8
+		FROM requests
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY 1, 2 ORDER BY 1, 2
+	`, from, to, costCenterKey)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (ChargebackRow, error) {
+		var c ChargebackRow
+		err := row.Scan(&c.Tenant, &c.CostCenter, &c.Requests, &c.PromptTokens, &c.CompletionTokens, &c.TotalTokens, &c.CostEstimate)
+		return c, err
+	})
+}
+
+// ClaimReport records that the report for period is being generated. It
+// returns false if another run (or instance) already claimed it.
+func (s *Store) ClaimReport(ctx context.Context, name, period string) (bool, error) {
+	tag, err := s.db.Exec(ctx, `
+		INSERT INTO report_runs (name, period) VALUES ($1, $2)
+		ON CONFLICT (name, period) DO NOTHING
+	`, name, period)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseReport removes a claim so a failed run can be retried.
+func (s *Store) ReleaseReport(ctx context.Context, name, period string) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM report_runs WHERE name = $1 AND period = $2`, name, period)
+	return err
+}
+
+// QueryUsage aggregates tokens and cost over the selected requests.
+func (s *Store) QueryUsage(ctx context.Context, q UsageQuery) ([]UsageRow, error) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.Tenant != "" {
+		where = append(where, "tenant = "+arg(q.Tenant))
+	}
+	if !q.From.IsZero() {
+		where = append(where, "created_at >= "+arg(q.From))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "created_at < "+arg(q.To))
+	}
+	for k, v := range q.Metadata {
+		where = append(where, fmt.Sprintf("metadata->>%s = %s", arg(k), arg(v)))
+	}
+
+	group := "'all'"
+	switch {
+	case q.GroupBy == "":
+	case groupColumns[q.GroupBy]:
+		group = q.GroupBy
+	default:
+		group = "metadata->>" + arg(q.GroupBy)
+	}
+
+	sql := `SELECT COALESCE(` + group + `::text, ''), COUNT(*),
+		COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(total_tokens), 0),
+		COALESCE(SUM(cost_estimate_usd), 0)::float8,
+		COALESCE(SUM(reasoning_tokens), 0), COALESCE(SUM(reasoning_cost_usd), 0)::float8,
+		COALESCE(SUM(system_tokens), 0), COALESCE(SUM(user_tokens), 0), COALESCE(SUM(history_tokens), 0),
+		COALESCE(SUM(system_cost_usd), 0)::float8
+		FROM requests`
+	if len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	sql += " GROUP BY 1 ORDER BY 6 DESC"
+
+	rows, err := s.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (UsageRow, error) {
+		var u UsageRow
+		err := row.Scan(&u.Group, &u.Requests, &u.PromptTokens, &u.CompletionTokens, &u.TotalTokens, &u.CostEstimate, &u.ReasoningTokens, &u.ReasoningCost,
+			&u.SystemTokens, &u.UserTokens, &u.HistoryTokens, &u.SystemCost)
+		return u, err
+	})
+}
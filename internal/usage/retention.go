@@ -0,0 +1,138 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// retentionTable says how retention treats one table. Rows are selected by
+// created_at; anonymize clears the personal columns, which may identify a
+// person or hold content, and keeps the rest for billing. Tables without
+// personal columns can only be purged.
+type retentionTable struct {
+	personal []string
+	// children reference rows of this table by request_id and are purged
+	// with them.
+	children []string
+}
+
+var retentionTables = map[string]retentionTable{
+	"requests": {
+		personal: []string{"metadata", "error_message", "conversation_id"},
+		children: []string{"provider_attempts", "request_events", "request_payloads"},
+	},
+	"provider_attempts": {personal: []string{"error_message"}},
+	"request_events":    {personal: []string{"detail"}},
+	"request_payloads":  {},
+	"probe_results":     {},
+}
+
+// anonymizeSet is the SET clause that clears t's personal columns.
+func (t retentionTable) anonymizeSet() string {
+	set := make([]string, len(t.personal))
+	for i, c := range t.personal {
+		set[i] = c + " = NULL"
+	}
+	return strings.Join(set, ", ")
+}
+
+// anonymizeWhere selects the rows with a personal column still set.
+func (t retentionTable) anonymizeWhere() string {
+	where := make([]string, len(t.personal))
+	for i, c := range t.personal {
+		where[i] = c + " IS NOT NULL"
+	}
+	return "(" + strings.Join(where, " OR ") + ")"
+}
+
+// CheckRetention reports whether retention can apply action to table.
+func CheckRetention(table, action string) error {
+	t, ok := retentionTables[table]
+	if !ok {
+		return fmt.Errorf("retention is not supported for table %q", table)
+	}
+	if action != "purge" && action != "anonymize" {
+		return fmt.Errorf("retention action must be purge or anonymize, got %q", action)
+	}
+	if action == "anonymize" && len(t.personal) == 0 {
+		return fmt.Errorf("table %s can only be purged", table)
+	}
+	return nil
+}
+
+// RetentionRun is the report of one retention policy applied once.
+type RetentionRun struct {
+	ID           int64     `json:"id,omitempty"`
+	Table        string    `json:"table"`
+	Action       string    `json:"action"`
+	Cutoff       time.Time `json:"cutoff"`
+	RowsAffected int64     `json:"rows_affected"`
+	DryRun       bool      `json:"dry_run"`
+	RanAt        time.Time `json:"ran_at"`
+}
+
+// ApplyRetention purges or anonymizes the rows of table created before
+// cutoff and returns how many there were. A dry run only counts them.
+// Purging requests also purges their attempts and events.
+func (s *Store) ApplyRetention(ctx context.Context, table, action string, cutoff time.Time, dryRun bool) (int64, error) {
+	if err := CheckRetention(table, action); err != nil {
+		return 0, err
+	}
+	t := retentionTables[table]
+	where := "created_at < $1"
+	if action == "anonymize" {
+		where += " AND " + t.anonymizeWhere()
+	}
+
+	if dryRun {
+		var n int64
+		err := s.db.QueryRow(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", table, where), cutoff).Scan(&n)
+		return n, err
+	}
+	if action == "anonymize" {
+		tag, err := s.db.Exec(ctx, fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, t.anonymizeSet(), where), cutoff)
+		return tag.RowsAffected(), err
+	}
+
+	var n int64
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		for _, child := range t.children {
+			if _, err := tx.Exec(ctx, fmt.Sprintf(
+				"DELETE FROM %s WHERE request_id IN (SELECT id FROM %s WHERE %s)", child, table, where), cutoff); err != nil {
+				return err
+			}
+		}
+		tag, err := tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s", table, where), cutoff)
+		n = tag.RowsAffected()
+		return err
+	})
+	return n, err
+}
+
+func (s *Store) RecordRetentionRun(ctx context.Context, r RetentionRun) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO retention_runs (table_name, action, cutoff, rows_affected, dry_run, ran_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, r.Table, r.Action, r.Cutoff, r.RowsAffected, r.DryRun, r.RanAt)
+	return err
+}
+
+// ListRetentionRuns returns the most recent retention reports first.
+func (s *Store) ListRetentionRuns(ctx context.Context, limit int) ([]RetentionRun, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, table_name, action, cutoff, rows_affected, dry_run, ran_at
+		FROM retention_runs ORDER BY ran_at DESC, id DESC LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (RetentionRun, error) {
+		var r RetentionRun
+		err := row.Scan(&r.ID, &r.Table, &r.Action, &r.Cutoff, &r.RowsAffected, &r.DryRun, &r.RanAt)
+		return r, err
+	})
+}
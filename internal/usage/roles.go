@@ -0,0 +1,70 @@
+package usage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yewintnaing/ai-gateway/internal/rbac"
+)
+
+// RoleAssignment grants a role to a subject: an API key ("key:<id>") or the
+// sub claim of a JWT.
+type RoleAssignment struct {
+	ID        int64     `json:"id"`
+	Subject   string    `json:"subject"`
+	Role      rbac.Role `json:"role"`
+	Tenant    string    `json:"tenant,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListRoleAssignments lists assignments, only those of subject unless it is
+// empty.
+func (s *Store) ListRoleAssignments(ctx context.Context, subject string) ([]RoleAssignment, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, subject, role, tenant, created_at
+		FROM role_assignments WHERE $1 = '' OR subject = $1 ORDER BY id
+	`, subject)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (RoleAssignment, error) {
+		var a RoleAssignment
+		err := row.Scan(&a.ID, &a.Subject, &a.Role, &a.Tenant, &a.CreatedAt)
+		return a, err
+	})
+}
+
+// CreateRoleAssignment grants g to subject. Granting a role the subject
+// already holds returns the existing assignment.
+func (s *Store) CreateRoleAssignment(ctx context.Context, subject string, g rbac.Grant) (RoleAssignment, error) {
+	a := RoleAssignment{Subject: subject, Role: g.Role, Tenant: g.Tenant}
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO role_assignments (subject, role, tenant) VALUES ($1, $2, $3)
+		ON CONFLICT (subject, role, tenant) DO UPDATE SET subject = EXCLUDED.subject
+		RETURNING id, created_at
+	`, subject, string(g.Role), g.Tenant).Scan(&a.ID, &a.CreatedAt)
+	return a, err
+}
+
+// DeleteRoleAssignment removes an assignment and reports whether it existed.
+func (s *Store) DeleteRoleAssignment(ctx context.Context, id int64) (bool, error) {
+	tag, err := s.db.Exec(ctx, `DELETE FROM role_assignments WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// RoleGrants returns the roles assigned to subject.
+func (s *Store) RoleGrants(ctx context.Context, subject string) ([]rbac.Grant, error) {
+	assignments, err := s.ListRoleAssignments(ctx, subject)
+	if err != nil || subject == "" {
+		return nil, err
+	}
+	grants := make([]rbac.Grant, 0, len(assignments))
+	for _, a := range assignments {
+		grants = append(grants, rbac.Grant{Role: a.Role, Tenant: a.Tenant})
+	}
+	return grants, nil
+}
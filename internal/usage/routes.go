@@ -0,0 +1,57 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Routes are stored as their YAML definition, the same form they take in
+// configs/routes.yaml, so any route the file accepts can live in the database.
+
+func (s *Store) ListRoutes(ctx context.Context) ([]config.Route, error) {
+	rows, err := s.db.Query(ctx, `SELECT name, definition FROM routes ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (config.Route, error) {
+		var name, def string
+		if err := row.Scan(&name, &def); err != nil {
+			return config.Route{}, err
+		}
+		var route config.Route
+		if err := yaml.Unmarshal([]byte(def), &route); err != nil {
+			return config.Route{}, fmt.Errorf("route %q: %w", name, err)
+		}
+		route.Name = name
+		return route, nil
+	})
+}
+
+// PutRoute creates or replaces the route with the same name and reports
+// whether it was created.
+func (s *Store) PutRoute(ctx context.Context, route config.Route) (bool, error) {
+	def, err := yaml.Marshal(route)
+	if err != nil {
+		return false, err
+	}
+	var created bool
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO routes (name, definition) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET definition = EXCLUDED.definition, updated_at = NOW()
+		RETURNING xmax = 0
+	`, route.Name, string(def)).Scan(&created)
+	return created, err
+}
+
+// DeleteRoute removes a route and reports whether it existed.
+func (s *Store) DeleteRoute(ctx context.Context, name string) (bool, error) {
+	tag, err := s.db.Exec(ctx, `DELETE FROM routes WHERE name = $1`, name)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,159 @@
+package usage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+)
+
+// otherLabel stands in for the tenants and models past the label caps, and
+// for the spend outside the top N.
+const otherLabel = "other"
+
+// spendMeter exports the estimated cost and tokens of written usage by
+// tenant and model, so spend dashboards can be built on /metrics alone.
+// Each instance counts what it writes to the database; summed over the
+// instances the counters cover every request.
+type spendMeter struct {
+	limits config.SpendMetrics
+	cost   metric.Float64Counter
+	tokens metric.Int64Counter
+
+	mu      sync.Mutex
+	tenants map[string]bool // tenants with a label of their own
+	models  map[string]bool
+	day     string // UTC date of today
+	today   map[spendKey]float64
+}
+
+type spendKey struct{ tenant, model string }
+
+// UseSpendMetrics reports the cost and tokens of the usage written by this
+// instance as the gateway.spend.cost and gateway.spend.tokens counters,
+// and the largest spenders of the day as the gateway.spend.today gauge.
+// The first MaxTenants tenants and MaxModels models seen get a label of
+// their own and the rest count as "other", so a flood of tenants cannot
+// blow up the number of series.
+func (s *Store) UseSpendMetrics(limits config.SpendMetrics) {
+	s.spend = newSpendMeter(otel.Meter("gateway-usage"), limits)
+}
+
+func newSpendMeter(meter metric.Meter, limits config.SpendMetrics) *spendMeter {
+	m := &spendMeter{limits: limits, tenants: map[string]bool{}, models: map[string]bool{}, today: map[spendKey]float64{}}
+	m.cost, _ = meter.Float64Counter("gateway.spend.cost",
+		metric.WithDescription("Estimated cost in USD of the requests written, by tenant and model"),
+		metric.WithUnit("{USD}"))
+	m.tokens, _ = meter.Int64Counter("gateway.spend.tokens",
+		metric.WithDescription("Prompt and completion tokens of the requests written, by tenant, model and kind"),
+		metric.WithUnit("{token}"))
+	today, _ := meter.Float64ObservableGauge("gateway.spend.today",
+		metric.WithDescription("Estimated cost in USD of the top tenant and model pairs since midnight UTC, with the rest as other"),
+		metric.WithUnit("{USD}"))
+	meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		for _, p := range m.top(time.Now()) {
+			o.ObserveFloat64(today, p.cost, metric.WithAttributes(attribute.String("tenant", p.tenant), attribute.String("model", p.model)))
+		}
+		return nil
+	}, today)
+	return m
+}
+
+// logged counts a record written to the database.
+func (s *Store) logged(ctx context.Context, r Record) {
+	if s.spend != nil {
+		s.spend.add(ctx, r, s.recordCost(ctx, r))
+	}
+}
+
+// add counts a written record.
+func (m *spendMeter) add(ctx context.Context, r Record, cost float64) {
+	if m == nil || (cost == 0 && r.PromptTokens == 0 && r.CompletionTokens == 0) {
+		return
+	}
+	key := m.label(r.Tenant, r.Model, time.Now())
+	attrs := []attribute.KeyValue{attribute.String("tenant", key.tenant), attribute.String("model", key.model)}
+	m.cost.Add(ctx, cost, metric.WithAttributes(attrs...))
+	m.tokens.Add(ctx, int64(r.PromptTokens), metric.WithAttributes(append(attrs, attribute.String("kind", "prompt"))...))
+	m.tokens.Add(ctx, int64(r.CompletionTokens), metric.WithAttributes(append(attrs, attribute.String("kind", "completion"))...))
+
+	m.mu.Lock()
+	m.today[key] += cost
+	m.mu.Unlock()
+}
+
+// label returns the labels a tenant and model are counted under, and
+// starts the day's totals afresh after midnight.
+func (m *spendMeter) label(tenant, model string, now time.Time) spendKey {
+	if tenant == "" {
+		tenant = "none"
+	}
+	if model == "" {
+		model = "none"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if day := now.UTC().Format(time.DateOnly); day != m.day {
+		m.day, m.today = day, map[spendKey]float64{}
+	}
+	return spendKey{capLabel(m.tenants, tenant, m.limits.MaxTenants), capLabel(m.models, model, m.limits.MaxModels)}
+}
+
+// capLabel admits v to seen while it holds fewer than limit values, and
+// returns v if it was admitted and otherLabel if not.
+func capLabel(seen map[string]bool, v string, limit int) string {
+	if seen[v] {
+		return v
+	}
+	if len(seen) >= limit {
+		return otherLabel
+	}
+	seen[v] = true
+	return v
+}
+
+type spendPoint struct {
+	tenant, model string
+	cost          float64
+}
+
+// top returns the day's Top largest tenant and model pairs, and the sum of
+// the others as one more pair.
+func (m *spendMeter) top(now time.Time) []spendPoint {
+	m.mu.Lock()
+	var points []spendPoint
+	if m.day == now.UTC().Format(time.DateOnly) {
+		for k, cost := range m.today {
+			points = append(points, spendPoint{k.tenant, k.model, cost})
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].cost != points[j].cost {
+			return points[i].cost > points[j].cost
+		}
+		return points[i].tenant+"/"+points[i].model < points[j].tenant+"/"+points[j].model
+	})
+	if len(points) <= m.limits.Top {
+		return points
+	}
+	var rest float64
+	for _, p := range points[m.limits.Top:] {
+		rest += p.cost
+	}
+	points = points[:m.limits.Top]
+	for i, p := range points {
+		if p.tenant == otherLabel && p.model == otherLabel {
+			points[i].cost += rest
+			return points
+		}
+	}
+	return append(points, spendPoint{otherLabel, otherLabel, rest})
+}
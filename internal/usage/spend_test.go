@@ -0,0 +1,57 @@
+package usage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/observability"
+)
+
+func TestSpendMeter(t *testing.T) {
+	reader := metric.NewManualReader()
+	m := newSpendMeter(metric.NewMeterProvider(metric.WithReader(reader)).Meter("test"), config.SpendMetrics{MaxTenants: 2, MaxModels: 5, Top: 2})
+	ctx := context.Background()
+
+	m.add(ctx, Record{Tenant: "acme", Model: "gpt-4o", PromptTokens: 100, CompletionTokens: 20}, 3)
+	m.add(ctx, Record{Tenant: "acme", Model: "gpt-4o", PromptTokens: 50}, 1)
+	m.add(ctx, Record{Tenant: "globex", Model: "claude", PromptTokens: 10}, 2)
+	m.add(ctx, Record{Tenant: "initech", Model: "gpt-4o", PromptTokens: 10}, 0.5)
+	m.add(ctx, Record{Tenant: "umbrella", Model: "claude", PromptTokens: 10}, 0.25)
+	m.add(ctx, Record{Tenant: "acme", StatusCode: 429}, 0)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	observability.WritePrometheus(&out, rm)
+	text := out.String()
+
+	for _, line := range []string{
+		`gateway_spend_cost_total{model="gpt-4o",tenant="acme"} 4`,
+		`gateway_spend_cost_total{model="gpt-4o",tenant="other"} 0.5`,
+		`gateway_spend_cost_total{model="claude",tenant="other"} 0.25`,
+		`gateway_spend_tokens_total{kind="prompt",model="gpt-4o",tenant="acme"} 150`,
+		`gateway_spend_tokens_total{kind="completion",model="gpt-4o",tenant="acme"} 20`,
+		`gateway_spend_today{model="gpt-4o",tenant="acme"} 4`,
+		`gateway_spend_today{model="claude",tenant="globex"} 2`,
+		`gateway_spend_today{model="other",tenant="other"} 0.75`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("missing line %s in:\n%s", line, text)
+		}
+	}
+	if strings.Contains(text, "initech") || strings.Contains(text, `tenant="none"`) {
+		t.Errorf("expected tenants past the cap and requests without usage left out:\n%s", text)
+	}
+
+	if got := m.top(time.Now().Add(24 * time.Hour)); len(got) != 0 {
+		t.Errorf("expected nothing spent tomorrow yet, got %v", got)
+	}
+}
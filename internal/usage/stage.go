@@ -0,0 +1,210 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Stage is a durable queue usage writes pass through on their way to the
+// database, so that neither a database outage nor a slow database holds up
+// requests or loses their records. Writes are delivered at least once: a
+// drainer that dies between writing and acknowledging leaves them to be
+// written again by the next.
+type Stage interface {
+	Add(ctx context.Context, data []byte) error
+	// Lease takes or renews, for ttl, the right to drain the stage. Only
+	// one drainer at a time holds it, which keeps writes in order.
+	Lease(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+	// Read returns up to n writes in the order they were added, those read
+	// before but never acknowledged first, waiting up to block for new ones.
+	Read(ctx context.Context, n int, block time.Duration) ([]StagedWrite, error)
+	Ack(ctx context.Context, ids ...string) error
+	Lag(ctx context.Context) (StageLag, error)
+}
+
+// StagedWrite is a write read from a stage.
+type StagedWrite struct {
+	ID   string
+	Data []byte
+}
+
+// StageLag is how far the database is behind a stage: the writes staged but
+// not yet written, and how long the oldest of them has waited.
+type StageLag struct {
+	Pending   int64
+	OldestAge time.Duration
+}
+
+// stagedWrite is the form Log, LogAttempt, LogEvent, Warn and Commit
+// stage writes in.
+type stagedWrite struct {
+	RequestID string     `json:"request_id"`
+	Record    *Record    `json:"record,omitempty"`
+	Attempt   *Attempt   `json:"attempt,omitempty"`
+	Event     *Event     `json:"event,omitempty"`
+	Entry     *entryData `json:"entry,omitempty"`
+	Warning   string     `json:"warning,omitempty"`
+}
+
+const (
+	stageBatch = 100
+	stageBlock = 2 * time.Second
+	stageLease = 30 * time.Second
+)
+
+// stageState is the stage of a Store, if it has one.
+type stageState struct {
+	stage Stage
+	down  atomic.Bool // the last Add failed
+}
+
+// UseStage sends usage writes through stage, to be written to the database
+// by DrainStage. While stage cannot be reached, writes go to the database
+// directly as before. It also reports the stage's lag as the
+// gateway.usage.staged and gateway.usage.staged_age metrics.
+func (s *Store) UseStage(stage Stage) {
+	s.stage.stage = stage
+	meter := otel.Meter("gateway-usage")
+	pending, _ := meter.Int64ObservableGauge("gateway.usage.staged",
+		metric.WithDescription("Usage writes staged but not yet written to the database"),
+		metric.WithUnit("{write}"))
+	age, _ := meter.Float64ObservableGauge("gateway.usage.staged_age",
+		metric.WithDescription("How long the oldest staged usage write has waited for the database"),
+		metric.WithUnit("s"))
+	meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		lag, err := stage.Lag(ctx)
+		if err != nil {
+			return nil
+		}
+		o.ObserveInt64(pending, lag.Pending)
+		o.ObserveFloat64(age, lag.OldestAge.Seconds())
+		return nil
+	}, pending, age)
+}
+
+// StageLag returns how far the database is behind the stage; zero without
+// one.
+func (s *Store) StageLag(ctx context.Context) (StageLag, error) {
+	if s.stage.stage == nil {
+		return StageLag{}, nil
+	}
+	return s.stage.stage.Lag(ctx)
+}
+
+// staged adds w to the stage and reports whether it did.
+func (s *Store) staged(ctx context.Context, w stagedWrite) bool {
+	if s.stage.stage == nil {
+		return false
+	}
+	data, err := json.Marshal(w)
+	if err != nil {
+		return false
+	}
+	if err := s.stage.stage.Add(ctx, data); err != nil {
+		if !s.stage.down.Swap(true) {
+			log.Printf("Warning: usage stage not available, writing usage to the database directly: %v", err)
+		}
+		return false
+	}
+	if s.stage.down.Swap(false) {
+		log.Printf("Usage stage is available again")
+	}
+	return true
+}
+
+// DrainStage writes staged usage to the database until ctx is done. Across
+// replicas only the one holding the stage's lease drains it; the others
+// stand by and take over, starting with the writes left unacknowledged,
+// when it stops renewing. While the database is unreachable writes stay
+// staged and are retried with backoff, so an outage is caught up on once
+// it ends.
+func (s *Store) DrainStage(ctx context.Context) {
+	owner := uuid.NewString()
+	wait := bufferRetry
+	for ctx.Err() == nil {
+		err := s.drainStage(ctx, owner)
+		if err == nil {
+			wait = bufferRetry
+			continue
+		}
+		if !errors.Is(err, errNotLeased) && ctx.Err() == nil {
+			log.Printf("Warning: draining usage stage: %v", err)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+		if !errors.Is(err, errNotLeased) {
+			wait = min(wait*2, maxBufferRetry)
+		}
+	}
+}
+
+var errNotLeased = errors.New("usage stage leased by another instance")
+
+// drainStage writes one batch of staged writes, acknowledging each once it
+// is written or the database rejected it. It stops at the first write that
+// could not reach the database.
+func (s *Store) drainStage(ctx context.Context, owner string) error {
+	stage := s.stage.stage
+	if ok, err := stage.Lease(ctx, owner, stageLease); err != nil {
+		return err
+	} else if !ok {
+		return errNotLeased
+	}
+	batch, err := stage.Read(ctx, stageBatch, stageBlock)
+	if err != nil {
+		return err
+	}
+	for _, w := range batch {
+		writeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := s.applyStaged(writeCtx, w.Data)
+		cancel()
+		if err != nil && !rejected(err) {
+			return err
+		}
+		if err != nil {
+			log.Printf("Warning: dropping staged usage write %s: %v", w.ID, err)
+		}
+		if err := stage.Ack(ctx, w.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyStaged writes a staged write to the database. Writes that do not
+// decode are dropped.
+func (s *Store) applyStaged(ctx context.Context, data []byte) error {
+	var w stagedWrite
+	if err := json.Unmarshal(data, &w); err != nil {
+		log.Printf("Warning: dropping undecodable staged usage write: %v", err)
+		return nil
+	}
+	switch {
+	case w.Entry != nil:
+		return s.commit(ctx, *w.Entry)
+	case w.Record != nil:
+		if err := s.log(ctx, s.db, *w.Record); err != nil {
+			return err
+		}
+		s.logged(ctx, *w.Record)
+		return nil
+	case w.Attempt != nil:
+		return s.logAttempt(ctx, s.db, w.RequestID, *w.Attempt)
+	case w.Event != nil:
+		return s.logEvent(ctx, s.db, w.RequestID, *w.Event)
+	case w.Warning != "":
+		return s.warn(ctx, s.db, w.RequestID, w.Warning)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// memoryStage is a Stage in memory.
+type memoryStage struct {
+	down   bool
+	holder string
+	writes []StagedWrite
+	acked  []string
+	next   int
+}
+
+func (s *memoryStage) Add(ctx context.Context, data []byte) error {
+	if s.down {
+		return errors.New("connection refused")
+	}
+	s.next++
+	s.writes = append(s.writes, StagedWrite{ID: fmt.Sprint(s.next), Data: data})
+	return nil
+}
+
+func (s *memoryStage) Lease(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	if s.holder != "" && s.holder != owner {
+		return false, nil
+	}
+	s.holder = owner
+	return true, nil
+}
+
+func (s *memoryStage) Read(ctx context.Context, n int, block time.Duration) ([]StagedWrite, error) {
+	return s.writes[:min(n, len(s.writes))], nil
+}
+
+func (s *memoryStage) Ack(ctx context.Context, ids ...string) error {
+	for _, id := range ids {
+		for i, w := range s.writes {
+			if w.ID == id {
+				s.writes = append(s.writes[:i:i], s.writes[i+1:]...)
+				break
+			}
+		}
+	}
+	s.acked = append(s.acked, ids...)
+	return nil
+}
+
+func (s *memoryStage) Lag(ctx context.Context) (StageLag, error) {
+	return StageLag{Pending: int64(len(s.writes))}, nil
+}
+
+func TestStagedWrites(t *testing.T) {
+	stage := &memoryStage{}
+	s := &Store{}
+	ctx := context.Background()
+	if s.staged(ctx, stagedWrite{RequestID: "r1", Record: &Record{RequestID: "r1"}}) {
+		t.Fatal("expected nothing staged without a stage")
+	}
+
+	s.stage.stage = stage
+	if !s.staged(ctx, stagedWrite{RequestID: "r1", Event: &Event{Kind: "repair"}}) {
+		t.Fatal("expected the write staged")
+	}
+	var w stagedWrite
+	if err := json.Unmarshal(stage.writes[0].Data, &w); err != nil || w.RequestID != "r1" || w.Event == nil || w.Event.Kind != "repair" {
+		t.Errorf("unexpected staged write %s", stage.writes[0].Data)
+	}
+
+	stage.down = true
+	if s.staged(ctx, stagedWrite{RequestID: "r2", Event: &Event{Kind: "repair"}}) {
+		t.Error("expected an unreachable stage to leave the write to the database")
+	}
+	if lag, _ := s.StageLag(ctx); lag.Pending != 1 {
+		t.Errorf("expected 1 staged write, got %d", lag.Pending)
+	}
+}
+
+func TestDrainStage(t *testing.T) {
+	stage := &memoryStage{holder: "other", writes: []StagedWrite{
+		{ID: "1", Data: []byte("not json")},
+		{ID: "2", Data: []byte(`{"request_id":"r1"}`)},
+	}}
+	s := &Store{}
+	s.stage.stage = stage
+	ctx := context.Background()
+
+	if err := s.drainStage(ctx, "me"); !errors.Is(err, errNotLeased) || len(stage.acked) != 0 {
+		t.Fatalf("expected nothing drained under another instance's lease, got %v", err)
+	}
+
+	// Once the lease is free, writes are taken over; ones that cannot be
+	// written are dropped rather than blocking the stage.
+	stage.holder = ""
+	if err := s.drainStage(ctx, "me"); err != nil {
+		t.Fatal(err)
+	}
+	if len(stage.writes) != 0 || len(stage.acked) != 2 || stage.acked[0] != "1" {
+		t.Errorf("expected both writes acknowledged in order, got %v", stage.acked)
+	}
+}
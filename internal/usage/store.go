@@ -5,6 +5,8 @@ import (
 	"math"
 	"sync"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -24,10 +26,36 @@ type Record struct {
 	PromptTokens     int
 	CompletionTokens int
 	TotalTokens      int
-	CostEstimate     float64
-	LatencyMS        int
-	StatusCode       int
-	ErrorMessage     string
+	// ReasoningTokens are the completion tokens spent on reasoning.
+	ReasoningTokens int
+	// PromptRoles splits PromptTokens by the role of the content.
+	PromptRoles  PromptRoles
+	CostEstimate float64 // overrides the estimate from Model when non-zero
+	LatencyMS    int
+	StatusCode   int
+	ErrorMessage string
+	Truncated    bool
+	// SystemPromptVersion is the managed system prompt applied, if any.
+	SystemPromptVersion string
+	// Metadata is the validated client metadata, stored as JSONB.
+	Metadata map[string]interface{}
+	// Region is where Provider processed the request, if it declares one.
+	Region string
+	// ConversationID groups the requests of one conversation or session,
+	// when the client names it. It is kept once set.
+	ConversationID string
+	// Warnings note what the request went without, such as a dependency
+	// that failed open. They are kept once set.
+	Warnings []string
+}
+
+// PromptRoles is how a prompt's tokens divide between system content
+// (system messages, managed system prompts and tool definitions), user
+// messages, and conversation history (assistant turns and tool results).
+type PromptRoles struct {
+	System  int
+	User    int
+	History int
 }
 
 type Attempt struct {
@@ -38,11 +66,32 @@ type Attempt struct {
 	LatencyMS    int
 	StatusCode   int
 	ErrorMessage string
+	// Token usage, recorded when attempts run side by side (consensus
+	// routes) and each one is billed.
+	PromptTokens     int
+	CompletionTokens int
+	// TTFTMS is the time to the first streamed chunk, for attempts that
+	// stream.
+	TTFTMS int
+	// Organization and Project are the OpenAI account the attempt was
+	// billed to, when it was not the provider's default.
+	Organization string
+	Project      string
+}
+
+// Event is a notable action taken on a request outside of provider calls,
+// such as a guardrail masking or blocking content.
+type Event struct {
+	Kind   string
+	Detail map[string]interface{}
 }
 
 type Store struct {
 	db           *pgxpool.Pool
 	pricingCache sync.Map // map[string]Pricing
+	buffer       writeBuffer
+	stage        stageState
+	spend        *spendMeter
 }
 
 func NewStore(connString string) (*Store, error) {
@@ -78,38 +127,206 @@ func (s *Store) getPricing(ctx context.Context, model string) Pricing {
 	return p
 }
 
-func (s *Store) Log(ctx context.Context, r Record) error {
-	p := s.getPricing(ctx, r.Model)
-	cost := s.EstimateCost(p, r.PromptTokens, r.CompletionTokens)
+// Pricing returns model's rates, falling back to defaults for unknown models.
+func (s *Store) Pricing(ctx context.Context, model string) Pricing {
+	return s.getPricing(ctx, model)
+}
 
+// SetPricing stores a model's rates, replacing any earlier ones.
+func (s *Store) SetPricing(ctx context.Context, provider string, p Pricing) error {
 	_, err := s.db.Exec(ctx, `
-		INSERT INTO requests (request_id, tenant, use_case, route_name, provider, model, prompt_tokens, completion_tokens, total_tokens, cost_estimate_usd, latency_ms, status_code, error_message)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
-		ON CONFLICT (request_id) DO UPDATE SET
-			tenant = EXCLUDED.tenant,
-			use_case = EXCLUDED.use_case,
-			route_name = EXCLUDED.route_name,
+		INSERT INTO model_pricing (model, provider, input_rate_1m, output_rate_1m) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (model) DO UPDATE SET
 			provider = EXCLUDED.provider,
-			model = EXCLUDED.model,
-			prompt_tokens = EXCLUDED.prompt_tokens,
-			completion_tokens = EXCLUDED.completion_tokens,
-			total_tokens = EXCLUDED.total_tokens,
-			cost_estimate_usd = EXCLUDED.cost_estimate_usd,
-			latency_ms = EXCLUDED.latency_ms,
-			status_code = EXCLUDED.status_code,
-			error_message = EXCLUDED.error_message
-	`, r.RequestID, r.Tenant, r.UseCase, r.RouteName, r.Provider, r.Model, r.PromptTokens, r.CompletionTokens, r.TotalTokens, cost, r.LatencyMS, r.StatusCode, r.ErrorMessage)
+			input_rate_1m = EXCLUDED.input_rate_1m,
+			output_rate_1m = EXCLUDED.output_rate_1m,
+			updated_at = CURRENT_TIMESTAMP
+	`, p.Model, provider, p.InputRate1M, p.OutputRate1M)
+	if err == nil {
+		s.pricingCache.Delete(p.Model)
+	}
+	return err
+}
+
+// Cost estimates the cost of a call to model.
+func (s *Store) Cost(ctx context.Context, model string, promptTokens, completionTokens int) float64 {
+	return s.EstimateCost(s.getPricing(ctx, model), promptTokens, completionTokens)
+}
+
+// Log records a request. Like LogAttempt and LogEvent, it adds to the
+// request's entry when ctx carries one still open, and otherwise stages the
+// write when the store has a stage, and buffers it while the database is
+// unreachable.
+func (s *Store) Log(ctx context.Context, r Record) error {
+	if addToEntry(ctx, r.RequestID, func(d *entryData) { d.merge(r) }) {
+		return nil
+	}
+	if s.staged(ctx, stagedWrite{RequestID: r.RequestID, Record: &r}) {
+		return nil
+	}
+	return s.write(ctx, func(ctx context.Context) error {
+		if err := s.log(ctx, s.db, r); err != nil {
+			return err
+		}
+		s.logged(ctx, r)
+		return nil
+	})
+}
+
+// execer runs statements on the pool or in a transaction.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// beginner starts a transaction on the pool, or a savepoint in one.
+type beginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// recordCost is the estimated cost of r, unless it has its own.
+func (s *Store) recordCost(ctx context.Context, r Record) float64 {
+	if r.CostEstimate != 0 {
+		return r.CostEstimate
+	}
+	return s.Cost(ctx, r.Model, r.PromptTokens, r.CompletionTokens)
+}
+
+func (s *Store) log(ctx context.Context, db beginner, r Record) error {
+	cost := s.recordCost(ctx, r)
+	var reasoningCost, systemCost float64
+	if r.ReasoningTokens > 0 {
+		reasoningCost = s.Cost(ctx, r.Model, 0, r.ReasoningTokens)
+	}
+	if r.PromptRoles.System > 0 {
+		systemCost = s.Cost(ctx, r.Model, r.PromptRoles.System, 0)
+	}
+
+	// An update then an insert rather than ON CONFLICT: request_id is not
+	// unique in a table partitioned by created_at, so the lock keeps two
+	// writers from both inserting.
+	return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+		if err := lockRequest(ctx, tx, r.RequestID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			WITH updated AS (
+				UPDATE requests SET
+					tenant = $2,
+					use_case = $3,
+					route_name = $4,
+					provider = $5,
+					model = $6,
+					prompt_tokens = $7,
+					completion_tokens = $8,
+					total_tokens = $9,
+					cost_estimate_usd = $10,
+					latency_ms = $11,
+					status_code = $12,
+					error_message = $13,
+					truncated = $14,
+					system_prompt_version = COALESCE(NULLIF($15, ''), system_prompt_version),
+					metadata = COALESCE($16, metadata),
+					reasoning_tokens = $17,
+					reasoning_cost_usd = $18,
+					system_tokens = $19,
+					user_tokens = $20,
+					history_tokens = $21,
+					system_cost_usd = $22,
+					region = NULLIF($23, ''),
+					conversation_id = COALESCE(NULLIF($24, ''), conversation_id),
+					warnings = COALESCE($25, warnings)
+				WHERE request_id = $1
+				RETURNING 1
+			)
+			INSERT INTO requests (request_id, tenant, use_case, route_name, provider, model, prompt_tokens, completion_tokens, total_tokens, cost_estimate_usd, latency_ms, status_code, error_message, truncated, system_prompt_version, metadata, reasoning_tokens, reasoning_cost_usd, system_tokens, user_tokens, history_tokens, system_cost_usd, region, conversation_id, warnings)
+			SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16, $17, $18, $19, $20, $21, $22, NULLIF($23, ''), NULLIF($24, ''), $25
+			WHERE NOT EXISTS (SELECT 1 FROM updated)
+		`, r.RequestID, r.Tenant, r.UseCase, r.RouteName, r.Provider, r.Model, r.PromptTokens, r.CompletionTokens, r.TotalTokens, cost, r.LatencyMS, r.StatusCode, r.ErrorMessage, r.Truncated, r.SystemPromptVersion, r.Metadata, r.ReasoningTokens, reasoningCost,
+			r.PromptRoles.System, r.PromptRoles.User, r.PromptRoles.History, systemCost, r.Region, r.ConversationID, r.Warnings)
+		return err
+	})
+}
+
+// lockRequest holds off other writers of requestID's row until tx ends.
+func lockRequest(ctx context.Context, tx pgx.Tx, requestID string) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, requestID)
+	return err
+}
+
+// Warn adds a warning to a request's record.
+func (s *Store) Warn(ctx context.Context, reqCorrelationID, warning string) error {
+	if addToEntry(ctx, reqCorrelationID, func(d *entryData) { d.Record.Warnings = appendNew(d.Record.Warnings, warning) }) {
+		return nil
+	}
+	if s.staged(ctx, stagedWrite{RequestID: reqCorrelationID, Warning: warning}) {
+		return nil
+	}
+	return s.write(ctx, func(ctx context.Context) error { return s.warn(ctx, s.db, reqCorrelationID, warning) })
+}
+
+func (s *Store) warn(ctx context.Context, db execer, reqCorrelationID, warning string) error {
+	tag, err := db.Exec(ctx, `
+		UPDATE requests SET warnings = array_append(COALESCE(warnings, '{}'), $2)
+		WHERE request_id = $1 AND NOT ($2 = ANY(COALESCE(warnings, '{}')))
+	`, reqCorrelationID, warning)
+	if err == nil && tag.RowsAffected() == 0 {
+		return errNoRequest
+	}
 	return err
 }
 
 func (s *Store) LogAttempt(ctx context.Context, reqCorrelationID string, a Attempt) error {
-	_, err := s.db.Exec(ctx, `
-		INSERT INTO provider_attempts (request_id, attempt_no, provider, model, latency_ms, status_code, error_message)
-		SELECT id, $2, $3, $4, $5, $6, $7 FROM requests WHERE request_id = $1 LIMIT 1
-	`, reqCorrelationID, a.AttemptNo, a.Provider, a.Model, a.LatencyMS, a.StatusCode, a.ErrorMessage)
+	if addToEntry(ctx, reqCorrelationID, func(d *entryData) { d.Attempts = append(d.Attempts, a) }) {
+		return nil
+	}
+	if s.staged(ctx, stagedWrite{RequestID: reqCorrelationID, Attempt: &a}) {
+		return nil
+	}
+	return s.write(ctx, func(ctx context.Context) error { return s.logAttempt(ctx, s.db, reqCorrelationID, a) })
+}
+
+func (s *Store) logAttempt(ctx context.Context, db execer, reqCorrelationID string, a Attempt) error {
+	var cost *float64
+	if a.PromptTokens > 0 || a.CompletionTokens > 0 {
+		c := s.Cost(ctx, a.Model, a.PromptTokens, a.CompletionTokens)
+		cost = &c
+	}
+	tag, err := db.Exec(ctx, `
+		INSERT INTO provider_attempts (request_id, attempt_no, provider, model, latency_ms, status_code, error_message, prompt_tokens, completion_tokens, cost_estimate_usd, ttft_ms, organization, project)
+		SELECT id, $2, $3, $4, $5, $6, $7, NULLIF($8, 0), NULLIF($9, 0), $10, NULLIF($11, 0), NULLIF($12, ''), NULLIF($13, '') FROM requests WHERE request_id = $1 LIMIT 1
+	`, reqCorrelationID, a.AttemptNo, a.Provider, a.Model, a.LatencyMS, a.StatusCode, a.ErrorMessage, a.PromptTokens, a.CompletionTokens, cost, a.TTFTMS, a.Organization, a.Project)
+	if err == nil && tag.RowsAffected() == 0 {
+		return errNoRequest
+	}
 	return err
 }
 
+func (s *Store) LogEvent(ctx context.Context, reqCorrelationID string, e Event) error {
+	if addToEntry(ctx, reqCorrelationID, func(d *entryData) { d.Events = append(d.Events, e) }) {
+		return nil
+	}
+	if s.staged(ctx, stagedWrite{RequestID: reqCorrelationID, Event: &e}) {
+		return nil
+	}
+	return s.write(ctx, func(ctx context.Context) error { return s.logEvent(ctx, s.db, reqCorrelationID, e) })
+}
+
+func (s *Store) logEvent(ctx context.Context, db execer, reqCorrelationID string, e Event) error {
+	tag, err := db.Exec(ctx, `
+		INSERT INTO request_events (request_id, kind, detail)
+		SELECT id, $2, $3 FROM requests WHERE request_id = $1 LIMIT 1
+	`, reqCorrelationID, e.Kind, e.Detail)
+	if err == nil && tag.RowsAffected() == 0 {
+		return errNoRequest
+	}
+	return err
+}
+
+// Ping checks that the database answers.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.Ping(ctx)
+}
+
 func (s *Store) Close() {
 	s.db.Close()
 }
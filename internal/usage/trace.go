@@ -0,0 +1,139 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrNotFound = errors.New("request not found")
+
+// RequestTrace is everything the database knows about a single request.
+type RequestTrace struct {
+	RequestID        string         `json:"request_id"`
+	Tenant           string         `json:"tenant"`
+	UseCase          string         `json:"use_case"`
+	RouteName        string         `json:"route_name"`
+	Provider         string         `json:"provider"`
+	Model            string         `json:"model"`
+	PromptTokens     int            `json:"prompt_tokens"`
+	CompletionTokens int            `json:"completion_tokens"`
+	TotalTokens      int            `json:"total_tokens"`
+	CostEstimate     float64        `json:"cost_estimate_usd"`
+	LatencyMS        int            `json:"latency_ms"`
+	StatusCode       int            `json:"status_code"`
+	ErrorMessage     string         `json:"error_message,omitempty"`
+	Truncated        bool           `json:"truncated"`
+	SystemPromptVer  string         `json:"system_prompt_version,omitempty"`
+	Region           string         `json:"region,omitempty"`
+	Warnings         []string       `json:"warnings,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	Attempts         []AttemptTrace `json:"attempts"`
+	Events           []EventTrace   `json:"events"`
+}
+
+type AttemptTrace struct {
+	AttemptNo    int       `json:"attempt_no"`
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	LatencyMS    int       `json:"latency_ms"`
+	StatusCode   int       `json:"status_code"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	PromptTokens     int      `json:"prompt_tokens,omitempty"`
+	CompletionTokens int      `json:"completion_tokens,omitempty"`
+	CostEstimate     *float64 `json:"cost_estimate_usd,omitempty"`
+	Organization     string   `json:"organization,omitempty"`
+	Project          string   `json:"project,omitempty"`
+}
+
+type EventTrace struct {
+	Kind      string                 `json:"kind"`
+	Detail    map[string]interface{} `json:"detail,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// GetTrace assembles the request row, its provider attempts and its events.
+func (s *Store) GetTrace(ctx context.Context, requestID string) (*RequestTrace, error) {
+	var t RequestTrace
+	var id string
+	var tenant, useCase, routeName, provider, model, errMsg *string
+	var prompt, completion, total, latency, status *int
+	var cost *float64
+
+	err := s.db.QueryRow(ctx, `
+		SELECT id::text, request_id, tenant, use_case, route_name, provider, model,
+			prompt_tokens, completion_tokens, total_tokens, cost_estimate_usd::float8,
+			latency_ms, status_code, error_message, COALESCE(truncated, false), COALESCE(system_prompt_version, ''), COALESCE(region, ''), COALESCE(warnings, '{}'), created_at
+		FROM requests WHERE request_id = $1
+	`, requestID).Scan(&id, &t.RequestID, &tenant, &useCase, &routeName, &provider, &model,
+		&prompt, &completion, &total, &cost, &latency, &status, &errMsg, &t.Truncated, &t.SystemPromptVer, &t.Region, &t.Warnings, &t.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.Tenant, t.UseCase, t.RouteName = deref(tenant), deref(useCase), deref(routeName)
+	t.Provider, t.Model, t.ErrorMessage = deref(provider), deref(model), deref(errMsg)
+	t.PromptTokens, t.CompletionTokens, t.TotalTokens = derefInt(prompt), derefInt(completion), derefInt(total)
+	t.LatencyMS, t.StatusCode = derefInt(latency), derefInt(status)
+	if cost != nil {
+		t.CostEstimate = *cost
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT attempt_no, provider, model, COALESCE(latency_ms, 0), COALESCE(status_code, 0),
+			COALESCE(error_message, ''), created_at,
+			COALESCE(prompt_tokens, 0), COALESCE(completion_tokens, 0), cost_estimate_usd::float8,
+			COALESCE(organization, ''), COALESCE(project, '')
+		FROM provider_attempts WHERE request_id = $1::uuid ORDER BY attempt_no, created_at
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	t.Attempts, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (AttemptTrace, error) {
+		var a AttemptTrace
+		err := row.Scan(&a.AttemptNo, &a.Provider, &a.Model, &a.LatencyMS, &a.StatusCode, &a.ErrorMessage, &a.CreatedAt,
+			&a.PromptTokens, &a.CompletionTokens, &a.CostEstimate, &a.Organization, &a.Project)
+		return a, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(ctx, `
+		SELECT kind, detail, created_at
+		FROM request_events WHERE request_id = $1::uuid ORDER BY created_at
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	t.Events, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (EventTrace, error) {
+		var e EventTrace
+		err := row.Scan(&e.Kind, &e.Detail, &e.CreatedAt)
+		return e, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func derefInt(n *int) int {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
@@ -0,0 +1,115 @@
+package usage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// UserDeletion is the manifest of an end user's data being deleted or
+// anonymized: the requests attributed to them and the rows touched per
+// table.
+type UserDeletion struct {
+	ID         int64    `json:"id,omitempty"`
+	Tenant     string   `json:"tenant"`
+	UserHash   string   `json:"user_sha256"`
+	Mode       string   `json:"mode"`
+	RequestIDs []string `json:"request_ids"`
+	// ConversationIDs are the conversations those requests belonged to,
+	// whose session summaries are deleted with them.
+	ConversationIDs []string         `json:"conversation_ids,omitempty"`
+	Rows            map[string]int64 `json:"rows"`
+	// StreamBuffers counts resumable streams dropped from memory on the
+	// instance that served the deletion.
+	StreamBuffers int `json:"stream_buffers"`
+	// CacheEntries counts the cached responses stored for the user's
+	// requests that were purged.
+	CacheEntries int       `json:"cache_entries"`
+	RequestedBy  string    `json:"requested_by,omitempty"`
+	CompletedAt  time.Time `json:"completed_at"`
+}
+
+// HashUser is how end-user identifiers appear in deletion logs.
+func HashUser(user string) string {
+	sum := sha256.Sum256([]byte(user))
+	return hex.EncodeToString(sum[:])
+}
+
+// DeleteUserData removes, or with anonymize clears the personal and content
+// columns of, every stored record of tenant whose metadata key equals user.
+// Anonymizing keeps token counts and cost so billing totals still add up;
+// captured payloads are deleted either way. Everything happens in one
+// transaction.
+func (s *Store) DeleteUserData(ctx context.Context, tenant, key, user string, anonymize bool) (UserDeletion, error) {
+	d := UserDeletion{Tenant: tenant, UserHash: HashUser(user), Mode: "delete", Rows: map[string]int64{}}
+	if anonymize {
+		d.Mode = "anonymize"
+	}
+
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			SELECT id::text, request_id, COALESCE(conversation_id, '') FROM requests
+			WHERE tenant = $1 AND metadata->>$2 = $3
+			ORDER BY created_at FOR UPDATE
+		`, tenant, key, user)
+		if err != nil {
+			return err
+		}
+		var ids []string
+		for rows.Next() {
+			var id, requestID, conversation string
+			if err := rows.Scan(&id, &requestID, &conversation); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+			d.RequestIDs = append(d.RequestIDs, requestID)
+			if conversation != "" && !slices.Contains(d.ConversationIDs, conversation) {
+				d.ConversationIDs = append(d.ConversationIDs, conversation)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil || len(ids) == 0 {
+			return err
+		}
+
+		statements := []struct{ table, sql string }{
+			{"request_payloads", `DELETE FROM request_payloads WHERE request_id = ANY($1::uuid[])`},
+			{"request_events", `DELETE FROM request_events WHERE request_id = ANY($1::uuid[])`},
+			{"provider_attempts", `DELETE FROM provider_attempts WHERE request_id = ANY($1::uuid[])`},
+			{"requests", `DELETE FROM requests WHERE id = ANY($1::uuid[])`},
+		}
+		if anonymize {
+			statements = []struct{ table, sql string }{
+				{"request_payloads", `DELETE FROM request_payloads WHERE request_id = ANY($1::uuid[])`},
+				{"request_events", `UPDATE request_events SET detail = NULL WHERE request_id = ANY($1::uuid[])`},
+				{"provider_attempts", `UPDATE provider_attempts SET error_message = NULL WHERE request_id = ANY($1::uuid[])`},
+				{"requests", `UPDATE requests SET metadata = NULL, error_message = NULL, conversation_id = NULL WHERE id = ANY($1::uuid[])`},
+			}
+		}
+		for _, st := range statements {
+			tag, err := tx.Exec(ctx, st.sql, ids)
+			if err != nil {
+				return err
+			}
+			d.Rows[st.table] = tag.RowsAffected()
+		}
+		return nil
+	})
+	if d.RequestIDs == nil {
+		d.RequestIDs = []string{}
+	}
+	return d, err
+}
+
+// RecordUserDeletion keeps the manifest of a completed deletion.
+func (s *Store) RecordUserDeletion(ctx context.Context, d *UserDeletion) error {
+	return s.db.QueryRow(ctx, `
+		INSERT INTO data_deletions (tenant, user_hash, mode, manifest, requested_by, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6) RETURNING id
+	`, d.Tenant, d.UserHash, d.Mode, d, d.RequestedBy, d.CompletedAt).Scan(&d.ID)
+}
@@ -0,0 +1,43 @@
+package usage
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/yewintnaing/ai-gateway/internal/governance"
+)
+
+func (s *Store) ListWordRules(ctx context.Context, tenant string) ([]governance.WordRule, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, tenant, kind, match_type, pattern, COALESCE(label, ''), COALESCE(action, ''), scope
+		FROM tenant_word_rules WHERE tenant = $1 ORDER BY id
+	`, tenant)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (governance.WordRule, error) {
+		var r governance.WordRule
+		var action string
+		err := row.Scan(&r.ID, &r.Tenant, &r.Kind, &r.MatchType, &r.Pattern, &r.Label, &action, &r.Scope)
+		r.Action = governance.WordListAction(action)
+		return r, err
+	})
+}
+
+func (s *Store) CreateWordRule(ctx context.Context, r governance.WordRule) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO tenant_word_rules (tenant, kind, match_type, pattern, label, action, scope)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id
+	`, r.Tenant, r.Kind, r.MatchType, r.Pattern, r.Label, string(r.Action), r.Scope).Scan(&id)
+	return id, err
+}
+
+// DeleteWordRule removes a rule and reports whether it existed.
+func (s *Store) DeleteWordRule(ctx context.Context, tenant string, id int64) (bool, error) {
+	tag, err := s.db.Exec(ctx, `DELETE FROM tenant_word_rules WHERE tenant = $1 AND id = $2`, tenant, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
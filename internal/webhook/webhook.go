@@ -0,0 +1,224 @@
+// Package webhook delivers events to HTTP callbacks. Bodies are signed with
+// HMAC-SHA256 when a secret is configured, failed deliveries are retried
+// with exponential backoff, and events that still cannot be delivered are
+// kept as dead letters.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Headers sent with every delivery. The signature header reads
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+const (
+	SignatureHeader = "X-Gateway-Signature"
+	EventHeader     = "X-Gateway-Event"
+	EventIDHeader   = "X-Gateway-Event-Id"
+)
+
+// Delivery is one event for one callback URL.
+type Delivery struct {
+	Tenant string
+	URL    string
+	Event  string
+	Body   []byte
+	// SecretEnv names the environment variable holding the signing secret.
+	// It is read on every attempt, so a rotated secret applies to retries
+	// and redeliveries. Empty sends the event unsigned.
+	SecretEnv string
+}
+
+// DeadLetter is an event that could not be delivered.
+type DeadLetter struct {
+	ID        int64           `json:"id"`
+	EventID   string          `json:"event_id"`
+	Tenant    string          `json:"tenant,omitempty"`
+	URL       string          `json:"url"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	SecretEnv string          `json:"secret_env,omitempty"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// DeadLetters stores undeliverable events.
+type DeadLetters interface {
+	SaveDeadLetter(ctx context.Context, d DeadLetter) error
+}
+
+// Deliverer posts deliveries. A nil *Deliverer drops them.
+type Deliverer struct {
+	client      *http.Client
+	store       DeadLetters
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	now         func() time.Time
+	sleep       func(ctx context.Context, d time.Duration) error
+}
+
+// New returns a Deliverer that tries each event up to maxAttempts times
+// (6 if zero), waiting 1s, 2s, 4s... (capped at 5 minutes) in between.
+func New(store DeadLetters, maxAttempts int) *Deliverer {
+	if maxAttempts <= 0 {
+		maxAttempts = 6
+	}
+	return &Deliverer{
+		client:      &http.Client{Timeout: 10 * time.Second},
+		store:       store,
+		maxAttempts: maxAttempts,
+		baseDelay:   time.Second,
+		maxDelay:    5 * time.Minute,
+		now:         time.Now,
+		sleep:       sleepCtx,
+	}
+}
+
+// Sign returns the signature header value for body sent at ts.
+func Sign(secret []byte, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%d.", ts)
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Verify checks a signature header against body, rejecting timestamps more
+// than tolerance away from now. Receivers can use it as is.
+func Verify(secret []byte, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts int64
+	var sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts, _ = strconv.ParseInt(v, 10, 64)
+		case "v1":
+			sig = v
+		}
+	}
+	if ts == 0 || sig == "" {
+		return errors.New("malformed signature header")
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return errors.New("signature timestamp outside tolerance")
+	}
+	_, want, _ := strings.Cut(Sign(secret, ts, body), ",v1=")
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// Deliver posts d until the receiver accepts it, the attempts run out or
+// the receiver rejects it outright (a 4xx other than 408 and 429). An
+// undelivered event is stored as a dead letter. Deliver blocks through the
+// retries; callers run it in its own goroutine.
+func (w *Deliverer) Deliver(ctx context.Context, d Delivery) error {
+	if w == nil {
+		return nil
+	}
+	eventID := uuid.NewString()
+	var err error
+	attempts := 0
+	for attempts < w.maxAttempts {
+		if attempts > 0 {
+			if sErr := w.sleep(ctx, w.backoff(attempts)); sErr != nil {
+				err = sErr
+				break
+			}
+		}
+		attempts++
+		var retry bool
+		if retry, err = w.post(ctx, d, eventID); err == nil {
+			return nil
+		} else if !retry {
+			break
+		}
+	}
+
+	log.Printf("Warning: webhook %s to %s undeliverable after %d attempts: %v", d.Event, d.URL, attempts, err)
+	dl := DeadLetter{
+		EventID: eventID, Tenant: d.Tenant, URL: d.URL, Event: d.Event, Payload: d.Body,
+		SecretEnv: d.SecretEnv, Attempts: attempts, LastError: err.Error(), CreatedAt: w.now(),
+	}
+	if !json.Valid(dl.Payload) {
+		dl.Payload, _ = json.Marshal(string(d.Body))
+	}
+	if w.store != nil {
+		if sErr := w.store.SaveDeadLetter(context.WithoutCancel(ctx), dl); sErr != nil {
+			log.Printf("Warning: failed to store webhook dead letter: %v", sErr)
+		}
+	}
+	return err
+}
+
+// post makes one attempt and reports whether a failure is worth retrying.
+func (w *Deliverer) post(ctx context.Context, d Delivery, eventID string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(EventIDHeader, eventID)
+	if d.SecretEnv != "" {
+		secret := os.Getenv(d.SecretEnv)
+		if secret == "" {
+			return false, fmt.Errorf("signing secret %s is not set", d.SecretEnv)
+		}
+		req.Header.Set(SignatureHeader, Sign([]byte(secret), w.now().Unix(), d.Body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+	if resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("status %d", resp.StatusCode)
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, err
+}
+
+// backoff is the wait before attempt n+1: baseDelay doubled per attempt,
+// capped at maxDelay, with up to 20% jitter so receivers coming back up
+// are not hit all at once.
+func (w *Deliverer) backoff(n int) time.Duration {
+	d := w.maxDelay
+	if n < 30 && w.baseDelay<<(n-1) < w.maxDelay {
+		d = w.baseDelay << (n - 1)
+	}
+	return d + time.Duration(rand.Int64N(int64(d)/5+1))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
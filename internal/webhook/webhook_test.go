@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type memDeadLetters struct {
+	letters []DeadLetter
+}
+
+func (m *memDeadLetters) SaveDeadLetter(ctx context.Context, d DeadLetter) error {
+	m.letters = append(m.letters, d)
+	return nil
+}
+
+func testDeliverer(store DeadLetters, attempts int) (*Deliverer, *[]time.Duration) {
+	d := New(store, attempts)
+	var waits []time.Duration
+	d.sleep = func(ctx context.Context, wait time.Duration) error {
+		waits = append(waits, wait)
+		return nil
+	}
+	return d, &waits
+}
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	header := Sign([]byte("s3cret"), now.Unix(), []byte(`{"a":1}`))
+
+	if err := Verify([]byte("s3cret"), header, []byte(`{"a":1}`), now, 5*time.Minute); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+	if err := Verify([]byte("s3cret"), header, []byte(`{"a":2}`), now, 5*time.Minute); err == nil {
+		t.Error("expected a tampered body to fail")
+	}
+	if err := Verify([]byte("other"), header, []byte(`{"a":1}`), now, 5*time.Minute); err == nil {
+		t.Error("expected a wrong secret to fail")
+	}
+	if err := Verify([]byte("s3cret"), header, []byte(`{"a":1}`), now.Add(time.Hour), 5*time.Minute); err == nil {
+		t.Error("expected a stale signature to fail")
+	}
+}
+
+func TestDeliverSignsAndRetries(t *testing.T) {
+	t.Setenv("TENANT_HOOK_SECRET", "s3cret")
+	var calls atomic.Int32
+	var eventIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify([]byte("s3cret"), r.Header.Get(SignatureHeader), body, time.Now(), time.Minute); err != nil {
+			t.Errorf("bad signature: %v", err)
+		}
+		eventIDs = append(eventIDs, r.Header.Get(EventIDHeader))
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	store := &memDeadLetters{}
+	d, waits := testDeliverer(store, 5)
+	err := d.Deliver(context.Background(), Delivery{Tenant: "acme", URL: srv.URL, Event: "alert.budget", Body: []byte(`{}`), SecretEnv: "TENANT_HOOK_SECRET"})
+	if err != nil {
+		t.Fatalf("expected delivery on the third attempt, got %v", err)
+	}
+	if calls.Load() != 3 || len(store.letters) != 0 {
+		t.Errorf("expected 3 calls and no dead letter, got %d calls, %d letters", calls.Load(), len(store.letters))
+	}
+	if len(*waits) != 2 || (*waits)[0] < time.Second || (*waits)[1] < 2*time.Second || (*waits)[1] > 3*time.Second {
+		t.Errorf("expected exponential backoff from 1s, got %v", *waits)
+	}
+	if eventIDs[0] == "" || eventIDs[0] != eventIDs[2] {
+		t.Errorf("expected retries to keep the event id, got %v", eventIDs)
+	}
+}
+
+func TestDeliverDeadLetters(t *testing.T) {
+	status := http.StatusInternalServerError
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	store := &memDeadLetters{}
+	d, _ := testDeliverer(store, 4)
+	if err := d.Deliver(context.Background(), Delivery{URL: srv.URL, Event: "alert.budget", Body: []byte(`{"x":1}`)}); err == nil {
+		t.Error("expected delivery to fail")
+	}
+	if calls.Load() != 4 || len(store.letters) != 1 || store.letters[0].Attempts != 4 {
+		t.Fatalf("expected 4 attempts then a dead letter, got %d calls, %+v", calls.Load(), store.letters)
+	}
+	if string(store.letters[0].Payload) != `{"x":1}` || store.letters[0].LastError != "status 500" {
+		t.Errorf("unexpected dead letter %+v", store.letters[0])
+	}
+
+	// A receiver rejecting the event outright is not retried.
+	status = http.StatusBadRequest
+	calls.Store(0)
+	d.Deliver(context.Background(), Delivery{URL: srv.URL, Event: "alert.budget", Body: []byte(`{}`)})
+	if calls.Load() != 1 || len(store.letters) != 2 {
+		t.Errorf("expected a single attempt for a 400, got %d", calls.Load())
+	}
+
+	// A missing secret is a configuration error, never sent unsigned.
+	calls.Store(0)
+	d.Deliver(context.Background(), Delivery{URL: srv.URL, Event: "alert.budget", Body: []byte(`{}`), SecretEnv: "UNSET_HOOK_SECRET"})
+	if calls.Load() != 0 || len(store.letters) != 3 {
+		t.Errorf("expected no unsigned delivery, got %d calls", calls.Load())
+	}
+}
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS request_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    request_id UUID REFERENCES requests(id),
+    kind TEXT NOT NULL,
+    detail JSONB,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_events_request_id ON request_events(request_id);
+CREATE INDEX IF NOT EXISTS idx_provider_attempts_request_id ON provider_attempts(request_id);
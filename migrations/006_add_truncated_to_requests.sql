@@ -0,0 +1 @@
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS truncated BOOLEAN DEFAULT FALSE;
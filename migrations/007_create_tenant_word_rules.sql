@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS tenant_word_rules (
+    id BIGSERIAL PRIMARY KEY,
+    tenant TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    match_type TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    label TEXT,
+    action TEXT,
+    scope TEXT NOT NULL DEFAULT 'both',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tenant_word_rules_tenant ON tenant_word_rules(tenant);
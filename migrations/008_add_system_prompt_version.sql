@@ -0,0 +1 @@
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS system_prompt_version TEXT;
@@ -0,0 +1,4 @@
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_requests_metadata ON requests USING GIN (metadata);
+CREATE INDEX IF NOT EXISTS idx_requests_tenant_created_at ON requests(tenant, created_at);
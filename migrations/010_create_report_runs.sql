@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS report_runs (
+    name TEXT NOT NULL,
+    period TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (name, period)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE provider_attempts ADD COLUMN IF NOT EXISTS prompt_tokens INT;
+ALTER TABLE provider_attempts ADD COLUMN IF NOT EXISTS completion_tokens INT;
+ALTER TABLE provider_attempts ADD COLUMN IF NOT EXISTS cost_estimate_usd NUMERIC(12, 6);
@@ -0,0 +1 @@
+ALTER TABLE provider_attempts ADD COLUMN IF NOT EXISTS ttft_ms INT;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS role_assignments (
+    id BIGSERIAL PRIMARY KEY,
+    -- "key:<id>" for a gateway API key, the JWT sub claim otherwise
+    subject TEXT NOT NULL,
+    role TEXT NOT NULL,
+    -- empty grants the role across all tenants
+    tenant TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (subject, role, tenant)
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_assignments_subject ON role_assignments(subject);
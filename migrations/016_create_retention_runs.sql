@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS retention_runs (
+    id BIGSERIAL PRIMARY KEY,
+    table_name TEXT NOT NULL,
+    action TEXT NOT NULL,
+    cutoff TIMESTAMPTZ NOT NULL,
+    rows_affected BIGINT NOT NULL,
+    dry_run BOOLEAN NOT NULL,
+    ran_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_runs_ran_at ON retention_runs(ran_at);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS tenant_data_keys (
+    id BIGSERIAL PRIMARY KEY,
+    tenant TEXT NOT NULL,
+    -- the KMS key the data key is wrapped with
+    key_ref TEXT NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    retired_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenant_data_keys_active ON tenant_data_keys(tenant) WHERE retired_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS request_payloads (
+    request_id UUID PRIMARY KEY REFERENCES requests(id),
+    tenant TEXT NOT NULL,
+    data_key_id BIGINT NOT NULL REFERENCES tenant_data_keys(id),
+    ciphertext BYTEA NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_payloads_created_at ON request_payloads(created_at);
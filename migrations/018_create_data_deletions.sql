@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS data_deletions (
+    id BIGSERIAL PRIMARY KEY,
+    tenant TEXT NOT NULL,
+    -- sha256 of the end-user identifier, so the log itself holds no personal data
+    user_hash TEXT NOT NULL,
+    mode TEXT NOT NULL,
+    manifest JSONB NOT NULL,
+    requested_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_deletions_tenant ON data_deletions(tenant, created_at);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS webhook_dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    event_id TEXT NOT NULL,
+    tenant TEXT,
+    url TEXT NOT NULL,
+    event TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    -- environment variable holding the signing secret, re-read on redelivery
+    secret_env TEXT,
+    attempts INT NOT NULL,
+    last_error TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_dead_letters_created_at ON webhook_dead_letters(created_at);
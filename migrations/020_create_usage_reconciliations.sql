@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS usage_reconciliations (
+    provider TEXT NOT NULL,
+    day DATE NOT NULL,
+    provider_usd NUMERIC(14,6) NOT NULL,
+    gateway_usd NUMERIC(14,6) NOT NULL,
+    diff_usd NUMERIC(14,6) NOT NULL,
+    diff_ratio NUMERIC(10,6) NOT NULL,
+    flagged BOOLEAN NOT NULL,
+    checked_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (provider, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_reconciliations_flagged ON usage_reconciliations(day) WHERE flagged;
@@ -0,0 +1,3 @@
+-- Reasoning tokens are part of completion_tokens; these break them out.
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS reasoning_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS reasoning_cost_usd NUMERIC(12,6) NOT NULL DEFAULT 0;
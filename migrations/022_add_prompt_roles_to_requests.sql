@@ -0,0 +1,6 @@
+-- Estimated split of prompt_tokens by message role. system_tokens includes
+-- tool definitions; history_tokens covers assistant turns and tool results.
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS system_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS user_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS history_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS system_cost_usd NUMERIC(12,6) NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS rate_limit_windows (
+    key TEXT PRIMARY KEY,
+    used INTEGER NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_windows_expires_at ON rate_limit_windows(expires_at);
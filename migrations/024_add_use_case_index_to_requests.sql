@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_requests_use_case_created_at ON requests(use_case, created_at);
@@ -0,0 +1,2 @@
+-- Region of the provider that served the request, for data residency audits.
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS region TEXT;
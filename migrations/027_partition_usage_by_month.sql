@@ -0,0 +1,59 @@
+-- Partition requests and provider_attempts by month of created_at. The
+-- existing rows stay where they are, attached as one partition named for
+-- the month it ends before; the gateway creates the monthly partitions
+-- after it, and a default partition takes rows no monthly one covers yet.
+-- Primary keys gain created_at, which partitioned tables require. For the
+-- same reason request_id can no longer be unique on its own: it is indexed
+-- instead, and the gateway writes each request's row under an advisory
+-- lock on it. The foreign keys to requests(id) are dropped too: rows are
+-- deleted together in code.
+DO $$
+DECLARE
+    bound TIMESTAMPTZ := date_trunc('month', NOW(), 'UTC') + INTERVAL '1 month';
+    suffix TEXT := to_char(bound AT TIME ZONE 'UTC', '"before_y"YYYY"m"MM');
+BEGIN
+    IF (SELECT relkind FROM pg_class WHERE oid = 'requests'::regclass) = 'p' THEN
+        RETURN;
+    END IF;
+
+    ALTER TABLE provider_attempts DROP CONSTRAINT IF EXISTS provider_attempts_request_id_fkey;
+    ALTER TABLE request_events DROP CONSTRAINT IF EXISTS request_events_request_id_fkey;
+    ALTER TABLE request_payloads DROP CONSTRAINT IF EXISTS request_payloads_request_id_fkey;
+
+    -- requests
+    UPDATE requests SET created_at = NOW() WHERE created_at IS NULL;
+    EXECUTE format('ALTER TABLE requests RENAME TO %I', 'requests_' || suffix);
+    EXECUTE format('ALTER INDEX requests_pkey RENAME TO %I', 'requests_' || suffix || '_pkey');
+    EXECUTE format('ALTER INDEX IF EXISTS requests_request_id_key RENAME TO %I', 'requests_' || suffix || '_request_id_key');
+    EXECUTE format('ALTER INDEX IF EXISTS idx_requests_use_case_created_at RENAME TO %I', 'idx_requests_' || suffix || '_use_case');
+    EXECUTE format('ALTER TABLE %I ALTER COLUMN created_at SET NOT NULL', 'requests_' || suffix);
+    EXECUTE format('CREATE TABLE requests (LIKE %I INCLUDING DEFAULTS) PARTITION BY RANGE (created_at)', 'requests_' || suffix);
+    ALTER TABLE requests ADD PRIMARY KEY (id, created_at);
+    CREATE INDEX idx_requests_request_id ON requests(request_id);
+    CREATE INDEX idx_requests_use_case_created_at ON requests(use_case, created_at);
+    EXECUTE format('ALTER TABLE requests ATTACH PARTITION %I FOR VALUES FROM (MINVALUE) TO (%L)', 'requests_' || suffix, bound);
+    CREATE TABLE requests_default PARTITION OF requests DEFAULT;
+
+    -- provider_attempts
+    UPDATE provider_attempts SET created_at = NOW() WHERE created_at IS NULL;
+    EXECUTE format('ALTER TABLE provider_attempts RENAME TO %I', 'provider_attempts_' || suffix);
+    EXECUTE format('ALTER INDEX provider_attempts_pkey RENAME TO %I', 'provider_attempts_' || suffix || '_pkey');
+    EXECUTE format('ALTER INDEX IF EXISTS idx_provider_attempts_request_id RENAME TO %I', 'idx_provider_attempts_' || suffix || '_request_id');
+    EXECUTE format('ALTER TABLE %I ALTER COLUMN created_at SET NOT NULL', 'provider_attempts_' || suffix);
+    EXECUTE format('CREATE TABLE provider_attempts (LIKE %I INCLUDING DEFAULTS) PARTITION BY RANGE (created_at)', 'provider_attempts_' || suffix);
+    ALTER TABLE provider_attempts ADD PRIMARY KEY (id, created_at);
+    CREATE INDEX idx_provider_attempts_request_id ON provider_attempts(request_id);
+    EXECUTE format('ALTER TABLE provider_attempts ATTACH PARTITION %I FOR VALUES FROM (MINVALUE) TO (%L)', 'provider_attempts_' || suffix, bound);
+    CREATE TABLE provider_attempts_default PARTITION OF provider_attempts DEFAULT;
+END $$;
+
+-- Partitions archived to the configured sink, and when they were dropped.
+CREATE TABLE IF NOT EXISTS usage_archives (
+    id BIGSERIAL PRIMARY KEY,
+    table_name TEXT NOT NULL,
+    partition_name TEXT NOT NULL UNIQUE,
+    object_name TEXT NOT NULL,
+    row_count BIGINT NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    dropped_at TIMESTAMPTZ
+);
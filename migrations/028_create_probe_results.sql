@@ -0,0 +1,15 @@
+-- Outcomes of the synthetic probes sent through routes on a schedule.
+CREATE TABLE IF NOT EXISTS probe_results (
+    id BIGSERIAL PRIMARY KEY,
+    probe TEXT NOT NULL,
+    route_name TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    model TEXT NOT NULL,
+    request_id TEXT,
+    latency_ms INT NOT NULL,
+    passed BOOLEAN NOT NULL,
+    failure TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_probe_results_created_at ON probe_results(created_at);
@@ -0,0 +1,41 @@
+-- Golden dataset runs against routes and models, and their scored cases.
+CREATE TABLE IF NOT EXISTS eval_runs (
+    id TEXT PRIMARY KEY,
+    dataset TEXT NOT NULL,
+    route_name TEXT NOT NULL,
+    provider TEXT,
+    model TEXT,
+    status TEXT NOT NULL,
+    error TEXT,
+    cases INT NOT NULL,
+    passed INT NOT NULL DEFAULT 0,
+    score DOUBLE PRECISION NOT NULL DEFAULT 0,
+    avg_latency_ms INT NOT NULL DEFAULT 0,
+    prompt_tokens INT NOT NULL DEFAULT 0,
+    completion_tokens INT NOT NULL DEFAULT 0,
+    cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_eval_runs_dataset ON eval_runs(dataset, created_at);
+
+CREATE TABLE IF NOT EXISTS eval_results (
+    id BIGSERIAL PRIMARY KEY,
+    run_id TEXT NOT NULL REFERENCES eval_runs(id) ON DELETE CASCADE,
+    case_name TEXT NOT NULL,
+    request_id TEXT,
+    provider TEXT,
+    model TEXT,
+    content TEXT NOT NULL,
+    latency_ms INT NOT NULL,
+    prompt_tokens INT NOT NULL,
+    completion_tokens INT NOT NULL,
+    cost_usd DOUBLE PRECISION NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    passed BOOLEAN NOT NULL,
+    similarity DOUBLE PRECISION,
+    failures TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS idx_eval_results_run_id ON eval_results(run_id);
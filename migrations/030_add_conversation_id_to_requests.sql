@@ -0,0 +1,3 @@
+-- Conversation (session) a request belongs to, for per-conversation usage.
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS conversation_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_requests_conversation_id ON requests(tenant, conversation_id, created_at) WHERE conversation_id IS NOT NULL;
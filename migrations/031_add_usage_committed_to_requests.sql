@@ -0,0 +1,3 @@
+-- Whether a request's usage entry was committed, so that replaying the
+-- commit does not write its attempts and events twice.
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS usage_committed BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,2 @@
+-- What a request went without, such as a dependency that failed open.
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS warnings TEXT[];
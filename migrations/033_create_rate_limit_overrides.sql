@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS rate_limit_overrides (
+    tenant TEXT PRIMARY KEY,
+    exempt BOOLEAN NOT NULL DEFAULT false,
+    burst_tokens INTEGER NOT NULL DEFAULT 0,
+    burst_refill_per_minute INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ,
+    reason TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS rate_limit_credits (
+    key TEXT PRIMARY KEY,
+    balance DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_credits_expires_at ON rate_limit_credits(expires_at);
@@ -0,0 +1,3 @@
+-- The OpenAI organization and project an attempt was billed to, if any.
+ALTER TABLE provider_attempts ADD COLUMN IF NOT EXISTS organization TEXT;
+ALTER TABLE provider_attempts ADD COLUMN IF NOT EXISTS project TEXT;
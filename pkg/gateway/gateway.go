@@ -0,0 +1,501 @@
+// Package gateway builds the whole gateway, from its routes and providers
+// to rate limiting and usage accounting, as an http.Handler, so a Go
+// service can embed it instead of running the gateway as its own process.
+//
+//	cfg, err := gateway.LoadConfig()
+//	...
+//	gw, err := gateway.New(ctx, cfg, gateway.Options{MigrationsDir: "migrations"})
+//	...
+//	defer gw.Close()
+//	mux.Handle("/llm/", http.StripPrefix("/llm", gw))
+//
+// cmd/gateway is itself a thin wrapper around this package.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/yewintnaing/ai-gateway/internal/alerting"
+	"github.com/yewintnaing/ai-gateway/internal/anomaly"
+	"github.com/yewintnaing/ai-gateway/internal/api"
+	"github.com/yewintnaing/ai-gateway/internal/archive"
+	"github.com/yewintnaing/ai-gateway/internal/cache"
+	"github.com/yewintnaing/ai-gateway/internal/concurrency"
+	"github.com/yewintnaing/ai-gateway/internal/config"
+	"github.com/yewintnaing/ai-gateway/internal/governance"
+	"github.com/yewintnaing/ai-gateway/internal/health"
+	"github.com/yewintnaing/ai-gateway/internal/kv"
+	"github.com/yewintnaing/ai-gateway/internal/observability"
+	"github.com/yewintnaing/ai-gateway/internal/payloads"
+	"github.com/yewintnaing/ai-gateway/internal/probe"
+	"github.com/yewintnaing/ai-gateway/internal/providers"
+	_ "github.com/yewintnaing/ai-gateway/internal/providers/builtin"
+	"github.com/yewintnaing/ai-gateway/internal/ratelimit"
+	"github.com/yewintnaing/ai-gateway/internal/rbac"
+	"github.com/yewintnaing/ai-gateway/internal/reconcile"
+	"github.com/yewintnaing/ai-gateway/internal/replay"
+	"github.com/yewintnaing/ai-gateway/internal/reports"
+	"github.com/yewintnaing/ai-gateway/internal/retention"
+	"github.com/yewintnaing/ai-gateway/internal/retrybudget"
+	"github.com/yewintnaing/ai-gateway/internal/router"
+	"github.com/yewintnaing/ai-gateway/internal/streambuf"
+	"github.com/yewintnaing/ai-gateway/internal/tools"
+	"github.com/yewintnaing/ai-gateway/internal/usage"
+	"github.com/yewintnaing/ai-gateway/internal/webhook"
+)
+
+// The configuration types, so embedders can build or adjust a Config
+// without importing the gateway's internal packages.
+type (
+	Config          = config.Config
+	ProviderOptions = config.ProviderOptions
+	Route           = config.Route
+	Match           = config.Match
+	Target          = config.Target
+	Tenant          = config.Tenant
+)
+
+// LoadConfig reads the configuration as the gateway binary does, from the
+// environment and the files GATEWAY_CONFIG names.
+func LoadConfig() (*Config, error) {
+	return config.LoadConfig()
+}
+
+// migrations are applied in order at startup.
+var migrations = []string{
+	"001_create_requests.sql",
+	"002_create_provider_attempts.sql",
+	"003_add_unique_to_requests.sql",
+	"004_create_model_pricing.sql",
+	"005_create_request_events.sql",
+	"006_add_truncated_to_requests.sql",
+	"007_create_tenant_word_rules.sql",
+	"008_add_system_prompt_version.sql",
+	"009_add_metadata_to_requests.sql",
+	"010_create_report_runs.sql",
+	"011_add_usage_to_provider_attempts.sql",
+	"012_create_routes.sql",
+	"013_add_ttft_to_provider_attempts.sql",
+	"014_create_tenant_api_keys.sql",
+	"015_create_role_assignments.sql",
+	"016_create_retention_runs.sql",
+	"017_create_request_payloads.sql",
+	"018_create_data_deletions.sql",
+	"019_create_webhook_dead_letters.sql",
+	"020_create_usage_reconciliations.sql",
+	"021_add_reasoning_to_requests.sql",
+	"022_add_prompt_roles_to_requests.sql",
+	"023_create_rate_limit_windows.sql",
+	"024_add_use_case_index_to_requests.sql",
+	"025_create_kv_entries.sql",
+	"026_add_region_to_requests.sql",
+	"027_partition_usage_by_month.sql",
+	"028_create_probe_results.sql",
+	"029_create_eval_runs.sql",
+	"030_add_conversation_id_to_requests.sql",
+	"031_add_usage_committed_to_requests.sql",
+	"032_add_warnings_to_requests.sql",
+	"033_create_rate_limit_overrides.sql",
+	"034_create_rate_limit_credits.sql",
+	"035_add_account_to_provider_attempts.sql",
+}
+
+// Options adjust how New builds the gateway.
+type Options struct {
+	// MigrationsDir is the directory holding the repository's migrations/
+	// files, applied once Postgres can be reached. Empty leaves the schema
+	// to be managed elsewhere.
+	MigrationsDir string
+}
+
+// Gateway serves the gateway's API. Its background jobs (reports,
+// retention, reconciliation and route reloading) run until Close.
+type Gateway struct {
+	handler http.Handler
+	cancel  context.CancelFunc
+	closers []func()
+}
+
+// errRequired marks a dependency listed in STARTUP_REQUIRES that could not
+// be reached.
+var errRequired = errors.New("required dependency not available")
+
+// New builds the gateway from cfg. As the binary does, it starts without
+// Postgres or Redis unless they are listed in cfg.StartupRequires, and it
+// fails on configuration errors.
+func New(ctx context.Context, cfg *Config, opts Options) (*Gateway, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	g := &Gateway{cancel: cancel}
+	if err := g.build(ctx, cfg, opts); err != nil {
+		g.Close()
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.handler.ServeHTTP(w, r)
+}
+
+// Close stops the background jobs and closes the database connections.
+func (g *Gateway) Close() {
+	g.cancel()
+	for i := len(g.closers) - 1; i >= 0; i-- {
+		g.closers[i]()
+	}
+	g.closers = nil
+}
+
+func (g *Gateway) build(ctx context.Context, cfg *Config, opts Options) error {
+	// Usage Store (Postgres)
+	store, err := usage.NewStore(cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	g.closers = append(g.closers, store.Close)
+	store.UseSpendMetrics(cfg.SpendMetrics)
+
+	// Migrations, now or once Postgres can be reached
+	if err := awaitDependency(ctx, cfg, "postgres", store.Ping); errors.Is(err, errRequired) {
+		return err
+	} else if err != nil {
+		log.Printf("Warning: Postgres not available, starting degraded with usage records buffered: %v", err)
+		go func() {
+			if health.Await(ctx, store.Ping, 0) == nil {
+				log.Printf("Postgres is available, running migrations")
+				migrate(ctx, store, opts.MigrationsDir, cfg.Pricing)
+			}
+		}()
+	} else {
+		migrate(ctx, store, opts.MigrationsDir, cfg.Pricing)
+	}
+
+	// Usage staging, so usage writes wait in Redis rather than in memory
+	// while Postgres is slow or down
+	switch cfg.UsageStaging {
+	case "":
+	case "redis":
+		if stage, err := usage.NewRedisStage(cfg.RedisURL); err != nil {
+			log.Printf("Warning: Redis not available, usage writes go to Postgres directly: %v", err)
+		} else {
+			g.closers = append(g.closers, stage.Close)
+			store.UseStage(stage)
+			go store.DrainStage(ctx)
+		}
+	default:
+		return fmt.Errorf("invalid USAGE_STAGING %q: want redis or empty", cfg.UsageStaging)
+	}
+
+	// Rate Limiter
+	if probe, err := health.RedisProbe(cfg.RedisURL); err == nil {
+		if err := awaitDependency(ctx, cfg, "redis", probe); errors.Is(err, errRequired) {
+			return err
+		} else if err != nil {
+			log.Printf("Warning: Redis not available at startup: %v", err)
+		}
+	}
+	var limitStore ratelimit.Store
+	switch cfg.RateLimitStore {
+	case "redis":
+		if rs, err := ratelimit.NewRedisStore(cfg.RedisURL); err != nil {
+			log.Printf("Warning: Redis not available, rate limiting disabled: %v", err)
+		} else {
+			limitStore = rs
+		}
+	case "postgres":
+		ps, err := ratelimit.NewPostgresStore(cfg.DatabaseURL)
+		if err != nil {
+			return fmt.Errorf("failed to connect to database for rate limits: %w", err)
+		}
+		g.closers = append(g.closers, ps.Close)
+		limitStore = ps
+	case "memory":
+		limitStore = ratelimit.NewMemoryStore()
+	default:
+		return fmt.Errorf("invalid RATE_LIMIT_STORE %q: want redis, postgres or memory", cfg.RateLimitStore)
+	}
+	limiter := ratelimit.NewLimiter(limitStore, cfg.TPM)
+	limiter.SetOverrides(store.GetRateLimitOverride)
+
+	// Cache, in the configured key-value store
+	var c *cache.Cache
+	switch cfg.KVStore {
+	case "redis":
+		if c, err = cache.NewCache(cfg.RedisURL, 1*time.Hour); err != nil {
+			log.Printf("Warning: Redis not available, caching disabled: %v", err)
+		}
+	case "postgres":
+		ps, err := kv.NewPostgresStore(cfg.DatabaseURL)
+		if err != nil {
+			return fmt.Errorf("failed to connect to database for the KV store: %w", err)
+		}
+		g.closers = append(g.closers, ps.Close)
+		c = cache.New(ps, 1*time.Hour)
+	case "memory":
+		c = cache.New(kv.NewMemoryStore(cfg.KVMemoryEntries), 1*time.Hour)
+	default:
+		return fmt.Errorf("invalid KV_STORE %q: want redis, postgres or memory", cfg.KVStore)
+	}
+
+	// Provider health and concurrency limits, shared through Redis when
+	// coordinating a cluster
+	var healthStore health.Store = health.NewMemoryStore()
+	var slots concurrency.Backend = concurrency.NewLocal()
+	switch cfg.Coordination {
+	case "":
+	case "redis":
+		if rs, err := health.NewRedisStore(cfg.RedisURL); err != nil {
+			log.Printf("Warning: Redis not available, provider health is per instance: %v", err)
+		} else {
+			healthStore = rs
+		}
+		if rs, err := concurrency.NewRedis(cfg.RedisURL); err != nil {
+			log.Printf("Warning: Redis not available, concurrency limits are per instance: %v", err)
+		} else {
+			slots = rs
+		}
+	default:
+		return fmt.Errorf("invalid CLUSTER_COORDINATION %q: want redis or empty", cfg.Coordination)
+	}
+	providerHealth := health.NewTracker(healthStore, cfg.CircuitThreshold, time.Duration(cfg.CircuitCooldown)*time.Second)
+	for name, opts := range cfg.Providers {
+		if q := opts.Queue; q != nil {
+			go providerHealth.WatchQueue(ctx, name, health.QueueSource{URL: q.MetricsURL, Metric: q.MetricName(), Interval: q.Interval()})
+		}
+	}
+
+	// Governance
+	detector := governance.NewDetector()
+
+	// Providers
+	registry, err := providers.Build(cfg.Providers)
+	if err != nil {
+		return fmt.Errorf("invalid provider config: %w", err)
+	}
+
+	replayMode, err := replay.ParseMode(cfg.ReplayMode)
+	if err != nil {
+		return fmt.Errorf("invalid replay config: %w", err)
+	}
+	if err := replay.WrapRegistry(registry, replayMode, cfg.ReplayDir); err != nil {
+		return fmt.Errorf("failed to initialize replay: %w", err)
+	}
+	if replayMode != replay.ModeOff {
+		log.Printf("Replay mode %q enabled, recordings in %s", replayMode, cfg.ReplayDir)
+	}
+	providers.NormalizeFinishReasons(registry)
+
+	var streams *streambuf.Buffer
+	if cfg.StreamResumeSec > 0 {
+		streams = streambuf.New(time.Duration(cfg.StreamResumeSec) * time.Second)
+	}
+
+	if sink, err := reports.NewSink(cfg.Reports); err != nil {
+		return fmt.Errorf("invalid report config: %w", err)
+	} else if sink != nil {
+		gen := reports.NewGenerator(store, sink, cfg.Reports.Format, cfg.Reports.CostCenterKey)
+		go gen.Start(ctx)
+	}
+
+	retentionJob, err := retention.New(store, cfg.Retention)
+	if err != nil {
+		return fmt.Errorf("invalid retention config: %w", err)
+	}
+	go retentionJob.Start(ctx)
+
+	archiveJob, err := archive.New(store, cfg.Archive, cfg.Retention.Policies)
+	if err != nil {
+		return fmt.Errorf("invalid archive config: %w", err)
+	}
+	go archiveJob.Start(ctx)
+
+	accounts := reconcile.Accounts(cfg.Reconcile, cfg.Providers)
+	go reconcile.New(store, accounts, cfg.Reconcile.LookbackDays, cfg.Reconcile.Threshold).Start(ctx)
+
+	var sealer *payloads.Sealer
+	if cfg.Payloads.Capture {
+		kms, err := payloads.NewKMS(cfg.Payloads.KMS, cfg.Payloads.MasterKey)
+		if err != nil {
+			return fmt.Errorf("invalid payload capture config: %w", err)
+		}
+		sealer = payloads.NewSealer(kms, store)
+	}
+
+	toolRegistry, err := tools.NewRegistry(cfg.Tools)
+	if err != nil {
+		return fmt.Errorf("invalid tool config: %w", err)
+	}
+
+	// Components
+	rt := router.NewRouter(cfg.Routes)
+	go rt.Sync(ctx, time.Duration(cfg.RoutesPollSec)*time.Second, store.ListRoutes)
+	webhooks := webhook.New(store, cfg.WebhookAttempts)
+	alerter := alerting.New(cfg.Alerts, cfg.Tenants, webhooks)
+	if cfg.Alerts.Anomalies != nil {
+		analyzer, err := anomaly.New(store, alerter, *cfg.Alerts.Anomalies)
+		if err != nil {
+			return fmt.Errorf("invalid anomaly detection config: %w", err)
+		}
+		go analyzer.Start(ctx)
+	}
+
+	h := api.NewHandler(rt, registry, store, limiter, c, detector, streams, cfg.Tenants, cfg.MetadataSchema, cfg.TraceSnippetLen, alerter, toolRegistry, cfg.Providers, providerHealth, concurrency.NewLimiter(slots), retrybudget.New(cfg.RetryBudget.Ratio, time.Duration(cfg.RetryBudget.WindowSec)*time.Second, cfg.RetryBudget.MinRetries), cfg.Auth, retentionJob, sealer, webhooks)
+
+	probes, err := probe.New(store, cfg.Probes, rt.Routes, h.Probe, alerter)
+	if err != nil {
+		return fmt.Errorf("invalid probe config: %w", err)
+	}
+	if probes != nil {
+		go probes.Start(ctx)
+	}
+
+	// HTTP routes
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(observability.ExtractTraceContext)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(api.Compress(cfg.CompressMinBytes))
+
+	r.Post("/v1/chat/completions", h.HandleChat)
+	r.Post("/v1/messages", h.HandleMessages)
+	r.Post("/v1/tokenize", h.HandleTokenize)
+	r.Post("/v1/estimate", h.HandleEstimate)
+	r.With(h.RequireTenantQuery(rbac.UsageRead)).Get("/v1/usage", h.HandleUsage)
+	r.With(h.RequireTenantQuery(rbac.UsageRead)).Get("/v1/usage/conversations", h.HandleListConversations)
+	r.With(h.RequireTenantQuery(rbac.UsageRead)).Get("/v1/usage/conversations/{id}", h.HandleGetConversation)
+	r.Route("/v1/me", func(r chi.Router) {
+		r.Use(h.RequireTenantKey)
+		r.Get("/usage", h.HandleMyUsage)
+		r.Get("/conversations", h.HandleMyConversations)
+		r.Get("/conversations/{id}", h.HandleMyConversation)
+		r.Get("/limits", h.HandleMyLimits)
+		r.Get("/keys", h.HandleMyKeys)
+	})
+	r.HandleFunc("/mcp", h.HandleMCP)
+	r.Route("/admin", func(r chi.Router) {
+		read := h.Require(rbac.AdminRead)
+		r.Get("/requests/{request_id}", h.HandleGetRequest)
+		r.Get("/requests/{request_id}/payload", h.HandleGetPayload)
+		r.Post("/requests/{request_id}/replay", h.HandleReplayRequest)
+		r.With(read).Get("/routes", h.HandleListRoutes)
+		r.With(read).Get("/routes/{name}", h.HandleGetRoute)
+		r.With(read).Get("/routes/{name}/canary", h.HandleGetCanary)
+		r.With(read).Get("/providers/health", h.HandleProviderHealth)
+		r.With(read).Get("/providers/stats", h.HandleProviderStats)
+		r.With(read).Get("/rate-limits", h.HandleListRateLimitOverrides)
+		r.With(read).Post("/route/test", h.HandleRouteTest)
+		r.With(h.Require(rbac.RoutesWrite)).Put("/routes/{name}", h.HandlePutRoute)
+		r.With(h.Require(rbac.RoutesWrite)).Delete("/routes/{name}", h.HandleDeleteRoute)
+
+		r.Route("/tenants/{tenant}", func(r chi.Router) {
+			r.With(h.Require(rbac.TenantRead)).Get("/word-rules", h.HandleListWordRules)
+			r.With(h.Require(rbac.TenantWrite)).Post("/word-rules", h.HandleCreateWordRule)
+			r.With(h.Require(rbac.TenantWrite)).Delete("/word-rules/{id}", h.HandleDeleteWordRule)
+			r.With(h.Require(rbac.TenantRead)).Get("/keys", h.HandleListAPIKeys)
+			r.With(h.Require(rbac.TenantWrite)).Post("/keys", h.HandleCreateAPIKey)
+			r.With(h.Require(rbac.TenantWrite)).Delete("/keys/{id}", h.HandleRevokeAPIKey)
+			r.With(h.Require(rbac.TenantRead)).Get("/rate-limit", h.HandleGetRateLimitOverride)
+			r.With(h.Require(rbac.TenantWrite)).Put("/rate-limit", h.HandlePutRateLimitOverride)
+			r.With(h.Require(rbac.TenantWrite)).Delete("/rate-limit", h.HandleDeleteRateLimitOverride)
+		})
+
+		r.With(read).Get("/retention", h.HandleGetRetention)
+		r.With(read).Get("/archives", h.HandleListArchives)
+		r.With(h.Require(rbac.DataManage)).Post("/retention/run", h.HandleRunRetention)
+		r.With(h.RequireTenantQuery(rbac.DataManage)).Delete("/data", h.HandleDeleteUserData)
+		r.With(h.RequireTenantQuery(rbac.TenantRead)).Get("/webhooks/dead-letters", h.HandleListDeadLetters)
+		r.With(read).Get("/reconciliation", h.HandleListReconciliations)
+		r.With(read).Get("/probes", h.HandleListProbes)
+		r.With(read).Get("/evals", h.HandleListEvals)
+		r.With(read).Get("/evals/compare", h.HandleCompareEvals)
+		r.With(read).Get("/evals/{id}", h.HandleGetEval)
+		r.With(h.Require(rbac.RoutesWrite)).Post("/evals", h.HandleStartEval)
+		r.With(read).Get("/cache/stats", h.HandleCacheStats)
+		r.With(h.Require(rbac.CacheManage)).Delete("/cache", h.HandlePurgeCache)
+		r.Post("/webhooks/dead-letters/{id}/redeliver", h.HandleRedeliverDeadLetter)
+
+		r.Route("/roles", func(r chi.Router) {
+			r.Use(h.Require(rbac.RolesManage))
+			r.Get("/", h.HandleListRoles)
+			r.Post("/", h.HandleAssignRole)
+			r.Delete("/{id}", h.HandleDeleteRole)
+		})
+	})
+	r.Get("/health/live", api.HandleLive)
+	r.Get("/health/ready", api.HandleReady(readiness(cfg, store, registry)))
+	r.Get("/openapi.json", api.HandleOpenAPI)
+	r.Get("/metrics", observability.HandleMetrics)
+
+	g.handler = r
+	return nil
+}
+
+// readiness checks the database, Redis and every configured provider that
+// is reached over HTTP.
+func readiness(cfg *config.Config, store *usage.Store, registry providers.Registry) *health.Readiness {
+	checks := []health.Check{{Name: "postgres", Probe: store.Ping}}
+	if probe, err := health.RedisProbe(cfg.RedisURL); err != nil {
+		log.Printf("Warning: invalid REDIS_URL, Redis is left out of readiness: %v", err)
+	} else {
+		checks = append(checks, health.Check{Name: "redis", Probe: probe})
+	}
+	names := make([]string, 0, len(cfg.Providers))
+	for name := range cfg.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := registry[name]; ok && cfg.Providers[name].BaseURL != "" && !cfg.Providers[name].Mocked() {
+			checks = append(checks, health.Check{Name: "provider:" + name, Probe: health.HTTPProbe(cfg.Providers[name].BaseURL)})
+		}
+	}
+	return health.NewReadiness(checks, cfg.ReadyCritical, 2*time.Second)
+}
+
+// migrate applies the migrations in dir, if any, then the model pricing
+// from the config, logging what fails.
+func migrate(ctx context.Context, store *usage.Store, dir string, pricing []config.ModelPricing) {
+	if dir != "" {
+		for _, name := range migrations {
+			if err := store.Migrate(ctx, filepath.Join(dir, name)); err != nil {
+				log.Printf("Warning: Migration %s failed: %v", strings.SplitN(name, "_", 2)[0], err)
+			}
+		}
+	}
+	for _, p := range pricing {
+		if err := store.SetPricing(ctx, p.Provider, usage.Pricing{Model: p.Model, InputRate1M: p.InputRate1M, OutputRate1M: p.OutputRate1M}); err != nil {
+			log.Printf("Warning: failed to store pricing for %s: %v", p.Model, err)
+		}
+	}
+}
+
+// awaitDependency checks a dependency at startup. One listed in
+// STARTUP_REQUIRES is waited for, with backoff, for up to
+// STARTUP_TIMEOUT_SECONDS, and an error wrapping errRequired is returned
+// if it is still down; any other is tried once, and the probe's error
+// returned for the gateway to start without it.
+func awaitDependency(ctx context.Context, cfg *config.Config, name string, probe func(ctx context.Context) error) error {
+	if !slices.Contains(cfg.StartupRequires, name) {
+		pctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		return probe(pctx)
+	}
+	if err := health.Await(ctx, probe, time.Duration(cfg.StartupTimeout)*time.Second); err != nil {
+		return fmt.Errorf("%w: %s: %v", errRequired, name, err)
+	}
+	return nil
+}